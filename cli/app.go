@@ -44,7 +44,9 @@ var (
 	blobCommands        = app.Command("blob", "Commands to manipulate BLOBs.").Hidden()
 	indexCommands       = app.Command("index", "Commands to manipulate content index.").Hidden()
 	benchmarkCommands   = app.Command("benchmark", "Commands to test performance of algorithms.").Hidden()
+	debugCommands       = app.Command("debug", "Commands to diagnose problems with the repository.").Hidden()
 	maintenanceCommands = app.Command("maintenance", "Maintenance commands.").Hidden().Alias("gc")
+	multiCommands       = app.Command("multi", "Commands operating on all repositories configured in the config directory.")
 )
 
 func helpFullAction(ctx *kingpin.ParseContext) error {
@@ -143,6 +145,8 @@ func maybeRepositoryAction(act func(ctx context.Context, rep repo.Repository) er
 				return errors.Wrap(err, "open repository")
 			}
 
+			maybePrintStaleSnapshotWarnings(ctx, rep)
+
 			err = act(ctx, rep)
 
 			if err == nil && rep != nil {
@@ -155,6 +159,8 @@ func maybeRepositoryAction(act func(ctx context.Context, rep repo.Repository) er
 				}
 			}
 
+			maybePrintStorageStats()
+
 			return err
 		})
 	}
@@ -165,6 +171,11 @@ func maybeRunMaintenance(ctx context.Context, rep repo.Repository) error {
 		return nil
 	}
 
+	if err := ensureFreeCacheSpace(rep); err != nil {
+		log(ctx).Warningf("skipping automatic maintenance: %v", err)
+		return nil
+	}
+
 	return snapshotmaintenance.Run(ctx, rep, maintenance.ModeAuto)
 }
 
@@ -10,6 +10,7 @@ import (
 
 	"github.com/fatih/color"
 
+	"github.com/kopia/kopia/internal/i18n"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
@@ -17,6 +18,7 @@ import (
 var (
 	enableProgress         = app.Flag("progress", "Enable progress bar").Hidden().Default("true").Bool()
 	progressUpdateInterval = app.Flag("progress-update-interval", "How ofter to update progress information").Hidden().Default("300ms").Duration()
+	plainStatusInterval    = app.Flag("plain-status-interval", "How often to print status lines in plain output mode").Hidden().Default("10s").Duration()
 )
 
 const spinner = `|/-\`
@@ -47,6 +49,9 @@ type cliProgress struct {
 	previousFileCount int
 	previousTotalSize int64
 
+	// path of the file which started hashing most recently.
+	currentFile atomic.Value
+
 	// indicates shared instance that does not reset counters at the beginning of upload.
 	shared bool
 
@@ -55,6 +60,7 @@ type cliProgress struct {
 
 func (p *cliProgress) HashingFile(fname string) {
 	atomic.AddInt32(&p.inProgressHashing, 1)
+	p.currentFile.Store(fname)
 }
 
 func (p *cliProgress) FinishedHashingFile(fname string, totalSize int64) {
@@ -77,7 +83,7 @@ func (p *cliProgress) HashedBytes(numBytes int64) {
 
 func (p *cliProgress) IgnoredError(path string, err error) {
 	atomic.AddInt32(&p.errorCount, 1)
-	p.output(warningColor, fmt.Sprintf("Ignored error when processing \"%v\": %v\n", path, err))
+	p.output(warningColor, i18n.Sprintf("Ignored error when processing \"%v\": %v\n", path, err))
 }
 
 func (p *cliProgress) CachedFile(fname string, numBytes int64) {
@@ -112,8 +118,13 @@ func (p *cliProgress) maybeOutput() {
 	var shouldOutput bool
 
 	nextOutputTimeUnixNano := atomic.LoadInt64(&p.nextOutputTimeUnixNano)
+	interval := *progressUpdateInterval
+	if *plainOutput {
+		interval = *plainStatusInterval
+	}
+
 	if nowNano := time.Now().UnixNano(); nowNano > nextOutputTimeUnixNano {
-		if atomic.CompareAndSwapInt64(&p.nextOutputTimeUnixNano, nextOutputTimeUnixNano, nowNano+progressUpdateInterval.Nanoseconds()) {
+		if atomic.CompareAndSwapInt64(&p.nextOutputTimeUnixNano, nextOutputTimeUnixNano, nowNano+interval.Nanoseconds()) {
 			shouldOutput = true
 		}
 	}
@@ -136,10 +147,8 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 	uploadedFiles := atomic.LoadInt32(&p.uploadedFiles)
 	errorCount := atomic.LoadInt32(&p.errorCount)
 
-	line := fmt.Sprintf(
-		" %v %v hashing, %v hashed (%v), %v cached (%v), %v uploaded (%v), %v errors",
-		p.spinnerCharacter(),
-
+	line := i18n.Sprintf(
+		"%v hashing, %v hashed (%v), %v cached (%v), %v uploaded (%v), %v errors",
 		inProgressHashing,
 
 		hashedFiles,
@@ -156,7 +165,7 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 
 	if msg != "" {
 		prefix := "\n ! "
-		if !*enableProgress {
+		if !*enableProgress || *plainOutput {
 			prefix = ""
 		}
 
@@ -174,8 +183,27 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 		}
 
 		line += fmt.Sprintf(" %.1f%%", percent)
+
+		if eta := estimatedTimeRemaining(time.Since(p.uploadStartTime), percent); eta > 0 && atomic.LoadInt32(&p.uploadFinished) == 0 {
+			line += fmt.Sprintf(" (ETA %v)", eta.Round(time.Second))
+		}
+	}
+
+	if *plainOutput {
+		// status lines are printed periodically on separate lines instead of replacing the previous one.
+		if msg == "" {
+			if f, ok := p.currentFile.Load().(string); ok && inProgressHashing > 0 {
+				line += ", hashing " + f
+			}
+
+			printStderr("%v\n", line)
+		}
+
+		return
 	}
 
+	line = fmt.Sprintf(" %v %v", p.spinnerCharacter(), line)
+
 	var extraSpaces string
 
 	if len(line) < p.lastLineLength {
@@ -187,6 +215,18 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 	printStderr("\r%v%v", line, extraSpaces)
 }
 
+// estimatedTimeRemaining extrapolates the time remaining until completion from the time elapsed so far,
+// returns zero if there's not enough progress to estimate it.
+func estimatedTimeRemaining(elapsed time.Duration, percent float64) time.Duration {
+	const minPercent = 1
+
+	if percent < minPercent || percent >= hundredPercent {
+		return 0
+	}
+
+	return time.Duration(float64(elapsed) * (hundredPercent - percent) / percent)
+}
+
 func (p *cliProgress) spinnerCharacter() string {
 	if atomic.LoadInt32(&p.uploadFinished) == 1 {
 		return "*"
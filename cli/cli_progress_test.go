@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimatedTimeRemaining(t *testing.T) {
+	cases := []struct {
+		elapsed time.Duration
+		percent float64
+		want    time.Duration
+	}{
+		{time.Minute, 0, 0},
+		{time.Minute, 0.5, 0},
+		{time.Minute, 25, 3 * time.Minute},
+		{time.Minute, 50, time.Minute},
+		{time.Minute, 100, 0},
+	}
+
+	for _, tc := range cases {
+		if got := estimatedTimeRemaining(tc.elapsed, tc.percent); got != tc.want {
+			t.Errorf("unexpected estimate for %v at %v%%: %v, want %v", tc.elapsed, tc.percent, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/diff"
+	"github.com/kopia/kopia/internal/units"
+)
+
+var (
+	applyBundleCommand    = app.Command("apply-bundle", "Apply the bundle of changes exported with 'diff --export-bundle' to a local directory, does not require repository connection")
+	applyBundleFile       = applyBundleCommand.Arg("bundle", "Bundle file").Required().ExistingFile()
+	applyBundleTargetPath = applyBundleCommand.Arg("target-path", "Directory holding the restored contents of the first snapshot").Required().ExistingDir()
+)
+
+func runApplyBundleCommand(ctx context.Context) error {
+	f, err := os.Open(*applyBundleFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bundle")
+	}
+	defer f.Close() //nolint:errcheck
+
+	stats, err := diff.ApplyBundle(ctx, f, *applyBundleTargetPath)
+	if err != nil {
+		return err
+	}
+
+	printStderr("Applied %v files (%v), %v attribute changes, %v symlinks, %v directories and %v removals.\n",
+		stats.Files, units.BytesStringBase10(stats.Bytes), stats.Attributes, stats.Symlinks, stats.Directories, stats.Removed)
+
+	return nil
+}
+
+func init() {
+	applyBundleCommand.Action(noRepositoryAction(runApplyBundleCommand))
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	benchmarkKDFCommand   = benchmarkCommands.Command("kdf", "Select key derivation parameters taking the target time on this machine")
+	benchmarkKDFTarget    = benchmarkKDFCommand.Flag("target", "Target duration of key derivation").Default("1s").Duration()
+	benchmarkKDFAlgorithm = benchmarkKDFCommand.Flag("algorithm", "Key derivation algorithm to benchmark (all by default)").Enum(keyDerivationScrypt, keyDerivationArgon2id, keyDerivationPBKDF2)
+)
+
+func runBenchmarkKDFAction(ctx *kingpin.ParseContext) error {
+	kinds := []string{keyDerivationScrypt, keyDerivationArgon2id, keyDerivationPBKDF2}
+	if *benchmarkKDFAlgorithm != "" {
+		kinds = []string{*benchmarkKDFAlgorithm}
+	}
+
+	for _, kind := range kinds {
+		printStderr("Benchmarking %v...\n", kind)
+
+		b, err := repo.BenchmarkKeyDerivation(kind, *benchmarkKDFTarget)
+		if err != nil {
+			if len(kinds) > 1 {
+				printStderr("  %v\n", err)
+				continue
+			}
+
+			return errors.Wrap(err, "unable to benchmark key derivation")
+		}
+
+		printStdout("%-30v %v\n", b.Algorithm, b.Duration.Round(time.Millisecond))
+	}
+
+	printStderr("Create repository with parameters selected for target time using 'kopia repository create --key-derivation=ALGO --key-derivation-target=%v'.\n", *benchmarkKDFTarget)
+
+	return nil
+}
+
+func init() {
+	benchmarkKDFCommand.Action(runBenchmarkKDFAction)
+}
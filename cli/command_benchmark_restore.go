@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var (
+	benchmarkRestoreCommand  = benchmarkCommands.Command("restore", "Measure restore throughput from the repository")
+	benchmarkRestoreSnapshot = benchmarkRestoreCommand.Flag("snapshot", "Snapshot manifest ID or directory object ID (optionally with path) to restore").Required().String()
+	benchmarkRestoreSample   = benchmarkRestoreCommand.Flag("sample", "Amount of data to restore").Default("1GB").Bytes()
+	benchmarkRestoreTarget   = benchmarkRestoreCommand.Flag("target", "Directory where sample data is temporarily restored (default: system temporary directory)").String()
+)
+
+// restoreBenchmark restores files sequentially, so that time spent in each stage adds up to the total time.
+type restoreBenchmark struct {
+	targetDir string
+	remaining int64
+
+	files     int
+	bytes     int64
+	writeTime time.Duration
+}
+
+type timedWriter struct {
+	w io.Writer
+	d *time.Duration
+}
+
+func (w timedWriter) Write(p []byte) (int, error) {
+	t0 := time.Now()
+	n, err := w.w.Write(p)
+	*w.d += time.Since(t0)
+
+	return n, err
+}
+
+func (b *restoreBenchmark) restoreEntry(ctx context.Context, e fs.Entry) error {
+	if b.remaining <= 0 {
+		return nil
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		entries, err := e.Readdir(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read directory %v", e.Name())
+		}
+
+		for _, child := range entries {
+			if err := b.restoreEntry(ctx, child); err != nil {
+				return err
+			}
+		}
+
+	case fs.File:
+		return b.restoreFile(ctx, e)
+	}
+
+	return nil
+}
+
+func (b *restoreBenchmark) restoreFile(ctx context.Context, f fs.File) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %v", f.Name())
+	}
+	defer r.Close() //nolint:errcheck
+
+	// file names don't matter, restored files are deleted afterwards.
+	out, err := os.Create(filepath.Join(b.targetDir, strconv.Itoa(b.files)))
+	if err != nil {
+		return errors.Wrap(err, "unable to create file")
+	}
+	defer out.Close() //nolint:errcheck
+
+	n, err := io.Copy(timedWriter{out, &b.writeTime}, io.LimitReader(r, b.remaining))
+	if err != nil {
+		return errors.Wrapf(err, "unable to restore %v", f.Name())
+	}
+
+	t0 := time.Now()
+
+	if err := out.Sync(); err != nil {
+		return errors.Wrap(err, "unable to sync file")
+	}
+
+	b.writeTime += time.Since(t0)
+
+	b.files++
+	b.bytes += n
+	b.remaining -= n
+
+	return nil
+}
+
+func benchmarkRestoreRoot(ctx context.Context, rep repo.Repository, id string) (fs.Entry, error) {
+	if man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(id)); err == nil {
+		return snapshotfs.SnapshotRoot(rep, man)
+	}
+
+	oid, err := parseObjectID(ctx, rep, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v is neither a snapshot ID nor an object ID", id)
+	}
+
+	return snapshotfs.DirectoryEntry(rep, oid, nil), nil
+}
+
+func runBenchmarkRestoreAction(ctx context.Context, rep *repo.DirectRepository) error {
+	root, err := benchmarkRestoreRoot(ctx, rep, *benchmarkRestoreSnapshot)
+	if err != nil {
+		return err
+	}
+
+	targetDir, err := ioutil.TempDir(*benchmarkRestoreTarget, "kopia-benchmark-restore")
+	if err != nil {
+		return errors.Wrap(err, "unable to create target directory")
+	}
+	defer os.RemoveAll(targetDir) //nolint:errcheck
+
+	b := &restoreBenchmark{
+		targetDir: targetDir,
+		remaining: int64(*benchmarkRestoreSample),
+	}
+
+	printStderr("Restoring up to %v from %v to %v\n", units.BytesStringBase10(b.remaining), *benchmarkRestoreSnapshot, targetDir)
+
+	rep.Content.Stats.Reset()
+	rep.Objects.Stats.Reset()
+
+	t0 := time.Now()
+
+	if err := b.restoreEntry(ctx, root); err != nil {
+		return err
+	}
+
+	total := time.Since(t0)
+
+	printStdout("Restored %v files, %v in %v (%v / second)\n",
+		b.files,
+		units.BytesStringBase10(b.bytes),
+		total.Truncate(time.Millisecond),
+		units.BytesStringBase10(int64(float64(b.bytes)/total.Seconds())))
+
+	displayRestoreBreakdown(total, []restoreStage{
+		{"network (including cache)", rep.Content.Stats.FetchDuration()},
+		{"decrypt", rep.Content.Stats.DecryptDuration()},
+		{"decompress", rep.Objects.Stats.DecompressDuration()},
+		{"disk write", b.writeTime},
+	})
+
+	return nil
+}
+
+type restoreStage struct {
+	name     string
+	duration time.Duration
+}
+
+func displayRestoreBreakdown(total time.Duration, stages []restoreStage) {
+	var (
+		accounted  time.Duration
+		bottleneck restoreStage
+	)
+
+	printStdout("\nBreakdown:\n")
+
+	for _, s := range stages {
+		printStdout("  %-30v %12v %5.1f%%\n", s.name, s.duration.Truncate(time.Millisecond), percentOf(s.duration, total))
+
+		accounted += s.duration
+
+		if s.duration > bottleneck.duration {
+			bottleneck = s
+		}
+	}
+
+	if other := total - accounted; other > 0 {
+		printStdout("  %-30v %12v %5.1f%%\n", "other", other.Truncate(time.Millisecond), percentOf(other, total))
+	}
+
+	if bottleneck.duration > 0 {
+		printStdout("\nBottleneck: %v\n", bottleneck.name)
+	}
+}
+
+func percentOf(d, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return 100 * float64(d) / float64(total) //nolint:gomnd
+}
+
+func init() {
+	benchmarkRestoreCommand.Action(directRepositoryAction(runBenchmarkRestoreAction))
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobaudit"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var (
+	blobAuditCommands = blobCommands.Command("audit", "Commands to export and verify signed manifests of all blobs.")
+
+	blobAuditExportCommand  = blobAuditCommands.Command("export", "Export a signed list of all blobs with their sizes and hashes, which can be verified without repository credentials. Enables recording of blobs deleted by kopia.")
+	blobAuditExportFile     = blobAuditExportCommand.Flag("output", "Name of the manifest file").Short('o').Required().String()
+	blobAuditExportParallel = blobAuditExportCommand.Flag("parallel", "Number of blobs to hash in parallel").Default("16").Int()
+
+	// 'blob audit verify <provider>' subcommands are registered by RegisterStorageConnectFlags.
+	blobAuditVerifyCommand   = blobAuditCommands.Command("verify", "Verify that no blob in a signed manifest was removed or altered. Requires only storage credentials.")
+	blobAuditVerifyFile      = blobAuditVerifyCommand.Flag("manifest", "Name of the manifest file").Required().ExistingFile()
+	blobAuditVerifyPublicKey = blobAuditVerifyCommand.Flag("public-key", "Hex-encoded public key printed by 'blob audit export'").Required().String()
+	blobAuditVerifyParallel  = blobAuditVerifyCommand.Flag("parallel", "Number of blobs to hash in parallel").Default("16").Int()
+)
+
+func runBlobAuditExportCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	key := blobaudit.SigningKey(rep.DeriveKey)
+
+	m, err := blobaudit.Create(ctx, rep.Blobs, key, rep.Time(), *blobAuditExportParallel)
+	if err != nil {
+		return errors.Wrap(err, "unable to create blob manifest")
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize blob manifest")
+	}
+
+	if err := ioutil.WriteFile(*blobAuditExportFile, b, 0600); err != nil {
+		return errors.Wrap(err, "unable to write blob manifest")
+	}
+
+	printStderr("Exported %v blobs to %v.\n", len(m.Blobs), *blobAuditExportFile)
+	printStderr("Public key: %v\n", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	return nil
+}
+
+func runBlobAuditVerifyCommandWithStorage(ctx context.Context, st blob.Storage) error {
+	defer st.Close(ctx) //nolint:errcheck
+
+	pub, err := hex.DecodeString(*blobAuditVerifyPublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.Errorf("invalid public key")
+	}
+
+	b, err := ioutil.ReadFile(*blobAuditVerifyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to read blob manifest")
+	}
+
+	m := &blobaudit.Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return errors.Wrap(err, "invalid blob manifest")
+	}
+
+	if err := m.VerifySignature(pub); err != nil {
+		return err
+	}
+
+	r, err := blobaudit.Verify(ctx, st, m, *blobAuditVerifyParallel)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify blobs")
+	}
+
+	for _, id := range r.Missing {
+		printStdout("MISSING %v\n", id)
+	}
+
+	for _, id := range r.Altered {
+		printStdout("ALTERED %v\n", id)
+	}
+
+	printStderr("Verified %v blobs created before %v, %v missing, %v altered, %v deleted by kopia and %v added since.\n",
+		r.Verified, formatTimestamp(m.Created), len(r.Missing), len(r.Altered), len(r.Deleted), len(r.Added))
+
+	if len(r.Missing)+len(r.Altered) > 0 {
+		return errors.Errorf("found %v missing and %v altered blobs", len(r.Missing), len(r.Altered))
+	}
+
+	return nil
+}
+
+func init() {
+	blobAuditExportCommand.Action(directRepositoryAction(runBlobAuditExportCommand))
+}
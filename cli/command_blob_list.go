@@ -3,9 +3,14 @@ package cli
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
 )
 
 var (
@@ -13,21 +18,34 @@ var (
 	blobListPrefix  = blobListCommand.Flag("prefix", "Blob ID prefix").String()
 	blobListMinSize = blobListCommand.Flag("min-size", "Minimum size").Int64()
 	blobListMaxSize = blobListCommand.Flag("max-size", "Maximum size").Int64()
+
+	blobListParallel = blobListCommand.Flag("parallel", "Number of concurrent list operations").Default(strconv.Itoa(blob.DefaultListParallelism)).Int()
 )
 
 func runBlobList(ctx context.Context, rep *repo.DirectRepository) error {
-	return rep.Blobs.ListBlobs(ctx, blob.ID(*blobListPrefix), func(b blob.Metadata) error {
+	blobs, err := blob.ListAllBlobsInParallel(ctx, *blobListParallel, rep.Blobs, blob.ID(*blobListPrefix), content.BlobIDAlphabet)
+	if err != nil {
+		return errors.Wrap(err, "unable to list blobs")
+	}
+
+	// parallel listing does not preserve the order returned by the storage.
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].BlobID < blobs[j].BlobID
+	})
+
+	for _, b := range blobs {
 		if *blobListMaxSize != 0 && b.Length > *blobListMaxSize {
-			return nil
+			continue
 		}
 
 		if *blobListMinSize != 0 && b.Length < *blobListMinSize {
-			return nil
+			continue
 		}
 
 		fmt.Printf("%-70v %10v %v\n", b.BlobID, b.Length, formatTimestamp(b.Timestamp))
-		return nil
-	})
+	}
+
+	return nil
 }
 
 func init() {
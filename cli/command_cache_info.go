@@ -31,6 +31,7 @@ func runCacheInfoCommand(ctx context.Context, rep *repo.DirectRepository) error
 	path2Limit := map[string]int64{
 		"contents": rep.Content.CachingOptions.MaxCacheSizeBytes,
 		"metadata": rep.Content.CachingOptions.MaxMetadataCacheSizeBytes,
+		"blobs":    rep.Content.CachingOptions.MaxBlobCacheSizeBytes,
 	}
 
 	for _, ent := range entries {
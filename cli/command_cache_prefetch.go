@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var (
+	cachePrefetchCommand  = cacheCommands.Command("prefetch", "Downloads metadata and optionally data of a snapshot directory into local cache.")
+	cachePrefetchPath     = cachePrefetchCommand.Arg("directory", "Directory ID optionally followed by path (ID/path) or source path in the snapshot closest to given time (path#time)").Required().String()
+	cachePrefetchData     = cachePrefetchCommand.Flag("data", "Also download contents of files").Bool()
+	cachePrefetchParallel = cachePrefetchCommand.Flag("parallel", "Number of objects to download in parallel").Default("16").Int()
+)
+
+func runCachePrefetchCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	if rep.Content.CachingOptions.CacheDirectory == "" {
+		return errors.Errorf("caching is not enabled")
+	}
+
+	oid, err := parseObjectID(ctx, rep, *cachePrefetchPath)
+	if err != nil {
+		return err
+	}
+
+	var dirCount, fileCount, dataBytes int64
+
+	w := snapshotfs.NewTreeWalker()
+	w.Parallelism = *cachePrefetchParallel
+	w.RootEntries = []fs.Entry{snapshotfs.DirectoryEntry(rep, oid, nil)}
+	w.EntryID = func(e fs.Entry) interface{} { return e.(object.HasObjectID).ObjectID() }
+
+	// directories are read by the walker, which caches their metadata.
+	w.ObjectCallback = func(e fs.Entry) error {
+		if e.IsDir() {
+			atomic.AddInt64(&dirCount, 1)
+			return nil
+		}
+
+		if _, ok := e.(fs.File); !ok {
+			return nil
+		}
+
+		atomic.AddInt64(&fileCount, 1)
+
+		fileOID := e.(object.HasObjectID).ObjectID()
+
+		if !*cachePrefetchData {
+			// reads indexes of large files.
+			_, err := rep.VerifyObject(ctx, fileOID)
+			return errors.Wrapf(err, "error reading %v", fileOID)
+		}
+
+		n, err := prefetchObject(ctx, rep, fileOID)
+		if err != nil {
+			return errors.Wrapf(err, "error reading %v", fileOID)
+		}
+
+		atomic.AddInt64(&dataBytes, n)
+
+		return nil
+	}
+
+	if err := w.Run(ctx); err != nil {
+		return errors.Wrap(err, "error prefetching")
+	}
+
+	printStderr("Prefetched %v directories and %v files.\n", dirCount, fileCount)
+
+	if *cachePrefetchData {
+		printStderr("Downloaded %v of file data.\n", units.BytesStringBase10(dataBytes))
+
+		if limit := rep.Content.CachingOptions.MaxCacheSizeBytes; dataBytes > limit {
+			printStderr("WARNING: data exceeds the maximum content cache size of %v and will not stay cached, increase it with 'kopia cache set --content-cache-size-mb'.\n", units.BytesStringBase10(limit))
+		}
+	}
+
+	return nil
+}
+
+func prefetchObject(ctx context.Context, rep *repo.DirectRepository, oid object.ID) (int64, error) {
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	return iocopy.Copy(ioutil.Discard, r)
+}
+
+func init() {
+	cachePrefetchCommand.Action(directRepositoryAction(runCachePrefetchCommand))
+}
@@ -15,6 +15,7 @@ var (
 	cacheSetDirectory              = cacheSetParamsCommand.Flag("cache-directory", "Directory where to store cache files").String()
 	cacheSetContentCacheSizeMB     = cacheSetParamsCommand.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("-1").Int64()
 	cacheSetMaxMetadataCacheSizeMB = cacheSetParamsCommand.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("-1").Int64()
+	cacheSetMaxBlobCacheSizeMB     = cacheSetParamsCommand.Flag("blob-cache-size-mb", "Size of local cache of recently used blobs").PlaceHolder("MB").Default("-1").Int64()
 	cacheSetMaxListCacheDuration   = cacheSetParamsCommand.Flag("max-list-cache-duration", "Duration of index cache").Default("-1ns").Duration()
 )
 
@@ -43,6 +44,13 @@ func runCacheSetCommand(ctx context.Context, rep *repo.DirectRepository) error {
 		changed++
 	}
 
+	if v := *cacheSetMaxBlobCacheSizeMB; v != -1 {
+		v *= 1e6 // convert MB to bytes
+		log(ctx).Infof("changing blob cache size to %v", units.BytesStringBase10(v))
+		opts.MaxBlobCacheSizeBytes = v
+		changed++
+	}
+
 	if v := *cacheSetMaxListCacheDuration; v != -1 {
 		log(ctx).Infof("changing list cache duration to %v", v)
 		opts.MaxListCacheDurationSec = int(v.Seconds())
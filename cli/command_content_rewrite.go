@@ -15,7 +15,10 @@ var (
 	contentRewriteParallelism = contentRewriteCommand.Flag("parallelism", "Number of parallel workers").Default("16").Int()
 
 	contentRewriteShortPacks    = contentRewriteCommand.Flag("short", "Rewrite contents from short packs").Bool()
+	contentRewriteLowUtil       = contentRewriteCommand.Flag("low-utilization", "Rewrite live contents from packs with utilization below given percentage").Int()
+	contentRewriteMaxSpeed      = contentRewriteCommand.Flag("max-bytes-per-second", "Maximum number of bytes per second to rewrite").Int64()
 	contentRewriteFormatVersion = contentRewriteCommand.Flag("format-version", "Rewrite contents using the provided format version").Default("-1").Int()
+	contentRewriteOldKeyEpochs  = contentRewriteCommand.Flag("old-key-epochs", "Rewrite contents encrypted with keys of previous key epochs").Bool()
 	contentRewritePackPrefix    = contentRewriteCommand.Flag("pack-prefix", "Only rewrite contents from pack blobs with a given prefix").String()
 	contentRewriteDryRun        = contentRewriteCommand.Flag("dry-run", "Do not actually rewrite, only print what would happen").Short('n').Bool()
 	contentRewriteMinAge        = contentRewriteCommand.Flag("min-age", "Only rewrite contents above given age").Default("1h").Duration()
@@ -31,6 +34,11 @@ func runContentRewriteCommand(ctx context.Context, rep *repo.DirectRepository) e
 		Parallel:       *contentRewriteParallelism,
 		ShortPacks:     *contentRewriteShortPacks,
 		DryRun:         *contentRewriteDryRun,
+		OldKeyEpochs:   *contentRewriteOldKeyEpochs,
+
+		LowUtilizationPacks:   *contentRewriteLowUtil > 0,
+		MaxUtilizationPercent: *contentRewriteLowUtil,
+		MaxBytesPerSecond:     *contentRewriteMaxSpeed,
 	})
 }
 
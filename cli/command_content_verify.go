@@ -22,9 +22,9 @@ func runContentVerifyCommand(ctx context.Context, rep *repo.DirectRepository) er
 	blobMap := map[blob.ID]blob.Metadata{}
 
 	if !*contentVerifyFull {
-		printStderr("Listing blobs...\n")
+		printStderr("Listing pack blobs...\n")
 
-		if err := rep.Blobs.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if err := blob.IterateAllBlobsInParallel(ctx, *contentVerifyParallel, rep.Blobs, content.PackBlobIDPrefixes, content.BlobIDAlphabet, func(bm blob.Metadata) error {
 			blobMap[bm.BlobID] = bm
 			if len(blobMap)%10000 == 0 {
 				printStderr("  %v blobs...\n", len(blobMap))
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/selftest"
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	debugSelfTestCommand    = debugCommands.Command("selftest", "Write, read back and delete test data in the repository to validate its storage, test objects are removed by maintenance.")
+	debugSelfTestIterations = debugSelfTestCommand.Flag("iterations", "Number of randomly sized items tested in each layer").Default("10").Int()
+	debugSelfTestMaxSize    = debugSelfTestCommand.Flag("max-size", "Maximum size of test blobs and objects").Default("20MB").Bytes()
+	debugSelfTestSeed       = debugSelfTestCommand.Flag("seed", "Random seed (0 for random)").Default("0").Int64()
+)
+
+func runDebugSelfTest(ctx context.Context, rep *repo.DirectRepository) error {
+	seed := *debugSelfTestSeed
+	if seed == 0 {
+		seed = rep.Time().UnixNano()
+	}
+
+	printStderr("Running self-test with seed %v...\n", seed)
+
+	r, err := selftest.Run(ctx, rep, selftest.Options{
+		Iterations: *debugSelfTestIterations,
+		MaxSize:    int(*debugSelfTestMaxSize),
+		Seed:       seed,
+	})
+
+	printStderr("Tested %v blobs, %v objects and %v manifests.\n", r.Blobs, r.Objects, r.Manifests)
+
+	if err != nil {
+		return errors.Wrapf(err, "self-test failed, repeat it using --seed=%v", seed)
+	}
+
+	printStderr("Self-test passed.\n")
+
+	return nil
+}
+
+func init() {
+	debugSelfTestCommand.Action(directRepositoryAction(runDebugSelfTest))
+}
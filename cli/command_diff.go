@@ -7,7 +7,9 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/diff"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
@@ -18,6 +20,7 @@ var (
 	diffSecondObjectPath = diffCommand.Arg("object-path2", "Second object/path").Required().String()
 	diffCompareFiles     = diffCommand.Flag("files", "Compare files by launching diff command for all pairs of (old,new)").Short('f').Bool()
 	diffCommandCommand   = diffCommand.Flag("diff-command", "Displays differences between two repository objects (files or directories)").Default(defaultDiffCommand()).Envar("KOPIA_DIFF").String()
+	diffExportBundle     = diffCommand.Flag("export-bundle", "Write a bundle of changes that can be applied to a restored copy of the first directory with 'apply-bundle'").PlaceHolder("FILE").String()
 )
 
 func runDiffCommand(ctx context.Context, rep repo.Repository) error {
@@ -38,6 +41,17 @@ func runDiffCommand(ctx context.Context, rep repo.Repository) error {
 		return errors.New("arguments do diff must both be directories or both non-directories")
 	}
 
+	if *diffExportBundle != "" {
+		if !isDir1 {
+			return errors.New("bundles can only be exported between two directories")
+		}
+
+		return exportDiffBundle(ctx,
+			snapshotfs.DirectoryEntry(rep, oid1, nil),
+			snapshotfs.DirectoryEntry(rep, oid2, nil),
+			*diffExportBundle)
+	}
+
 	d, err := diff.NewComparer(os.Stdout)
 	if err != nil {
 		return err
@@ -61,6 +75,28 @@ func runDiffCommand(ctx context.Context, rep repo.Repository) error {
 	return errors.New("comparing files not implemented yet")
 }
 
+func exportDiffBundle(ctx context.Context, dir1, dir2 fs.Directory, fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "unable to create bundle file")
+	}
+
+	stats, err := diff.WriteBundle(ctx, f, dir1, dir2)
+	if err != nil {
+		f.Close() //nolint:errcheck
+		return errors.Wrap(err, "unable to write bundle")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close bundle file")
+	}
+
+	printStderr("Exported %v files (%v), %v attribute changes, %v symlinks, %v directories and %v removals to %v.\n",
+		stats.Files, units.BytesStringBase10(stats.Bytes), stats.Attributes, stats.Symlinks, stats.Directories, stats.Removed, fname)
+
+	return nil
+}
+
 func defaultDiffCommand() string {
 	if isWindows() {
 		return "cmp"
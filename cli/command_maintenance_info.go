@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/maintenance"
 )
@@ -43,6 +44,9 @@ func runMaintenanceInfoCommand(ctx context.Context, rep *repo.DirectRepository)
 	printStdout("Full Cycle:\n")
 	displayCycleInfo(&p.FullCycle, s.NextFullMaintenanceTime, rep)
 
+	printStdout("Defragmentation:\n")
+	displayDefragmentInfo(&p.Defragment)
+
 	printStdout("Recent Maintenance Runs:\n")
 
 	for run, timings := range s.Runs {
@@ -84,3 +88,21 @@ func displayCycleInfo(c *maintenance.CycleParams, t time.Time, rep *repo.DirectR
 func init() {
 	maintenanceInfoCommand.Action(directRepositoryAction(runMaintenanceInfoCommand))
 }
+
+func displayDefragmentInfo(d *maintenance.DefragmentParams) {
+	printStdout("  enabled: %v\n", d.Enabled)
+
+	if d.Enabled {
+		if d.MaxUtilizationPercent > 0 {
+			printStdout("  max utilization: %v%%\n", d.MaxUtilizationPercent)
+		}
+
+		if d.MaxBytes > 0 {
+			printStdout("  max bytes per run: %v\n", units.BytesStringBase10(d.MaxBytes))
+		}
+
+		if d.MaxBytesPerSecond > 0 {
+			printStdout("  max speed: %v/s\n", units.BytesStringBase10(d.MaxBytesPerSecond))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
+)
+
+var (
+	maintenancePlanCommands = maintenanceCommands.Command("plan", "Commands to expire snapshots and delete unreferenced contents in separately verified steps.")
+
+	maintenancePlanCreateCommand = maintenancePlanCommands.Command("create", "Create a plan of snapshots to expire and contents to delete without deleting anything.")
+	maintenancePlanCreateFile    = maintenancePlanCreateCommand.Arg("file", "Plan file").Required().String()
+	maintenancePlanCreateMinAge  = maintenancePlanCreateCommand.Flag("min-age", "Minimum content age to allow deletion").Default("24h").Duration()
+
+	maintenancePlanVerifyCommand = maintenancePlanCommands.Command("verify", "Verify that no retained snapshot references contents deleted by the plan and sign it.")
+	maintenancePlanVerifyFile    = maintenancePlanVerifyCommand.Arg("file", "Plan file").Required().ExistingFile()
+
+	maintenancePlanApplyCommand = maintenancePlanCommands.Command("apply", "Delete snapshots and contents according to a verified plan.")
+	maintenancePlanApplyFile    = maintenancePlanApplyCommand.Arg("file", "Plan file").Required().ExistingFile()
+)
+
+func runMaintenancePlanCreateCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	p, err := snapshotgc.CreatePlan(ctx, rep, maintenance.SnapshotGCParams{
+		MinContentAge: *maintenancePlanCreateMinAge,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to create plan")
+	}
+
+	if err := writePlan(*maintenancePlanCreateFile, p); err != nil {
+		return err
+	}
+
+	printStderr("Plan to expire %v snapshots and delete %v contents written to %v, verify it with 'kopia maintenance plan verify'.\n",
+		len(p.ExpiredSnapshots), len(p.DeletedContents), *maintenancePlanCreateFile)
+
+	return nil
+}
+
+func runMaintenancePlanVerifyCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	p, err := readPlan(*maintenancePlanVerifyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := snapshotgc.VerifyPlan(ctx, rep, p); err != nil {
+		return errors.Wrap(err, "plan verification failed")
+	}
+
+	if err := writePlan(*maintenancePlanVerifyFile, p); err != nil {
+		return err
+	}
+
+	printStderr("Verified that %v retained snapshots do not reference any of %v contents to be deleted, apply the plan with 'kopia maintenance plan apply'.\n",
+		len(p.RetainedSnapshots), len(p.DeletedContents))
+
+	return nil
+}
+
+func runMaintenancePlanApplyCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	p, err := readPlan(*maintenancePlanApplyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := snapshotgc.ApplyPlan(ctx, rep, p); err != nil {
+		return errors.Wrap(err, "unable to apply plan")
+	}
+
+	printStderr("Deleted %v snapshots and %v contents.\n", len(p.ExpiredSnapshots), len(p.DeletedContents))
+
+	return nil
+}
+
+func readPlan(fname string) (*snapshotgc.Plan, error) {
+	b, err := ioutil.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read plan")
+	}
+
+	p := &snapshotgc.Plan{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, errors.Wrap(err, "invalid plan")
+	}
+
+	return p, nil
+}
+
+func writePlan(fname string, p *snapshotgc.Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize plan")
+	}
+
+	return errors.Wrap(ioutil.WriteFile(fname, b, 0600), "unable to write plan")
+}
+
+func init() {
+	maintenancePlanCreateCommand.Action(directRepositoryAction(runMaintenancePlanCreateCommand))
+	maintenancePlanVerifyCommand.Action(directRepositoryAction(runMaintenancePlanVerifyCommand))
+	maintenancePlanApplyCommand.Action(directRepositoryAction(runMaintenancePlanApplyCommand))
+}
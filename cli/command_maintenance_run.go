@@ -19,6 +19,10 @@ func runMaintenanceCommand(ctx context.Context, rep *repo.DirectRepository) erro
 		mode = maintenance.ModeFull
 	}
 
+	if err := rep.CheckFreeCacheSpace(); err != nil {
+		return err
+	}
+
 	return snapshotmaintenance.Run(ctx, rep, mode)
 }
 
@@ -23,6 +23,11 @@ var (
 
 	maintenanceSetPauseQuick = maintenanceSetCommand.Flag("pause-quick", "Pause quick maintenance for a specified duration").DurationList()
 	maintenanceSetPauseFull  = maintenanceSetCommand.Flag("pause-full", "Pause full maintenance for a specified duration").DurationList()
+
+	maintenanceSetEnableDefragment         = maintenanceSetCommand.Flag("enable-defragment", "Enable or disable defragmentation of low-utilization packs during full maintenance").BoolList()
+	maintenanceSetDefragmentMaxUtilization = maintenanceSetCommand.Flag("defragment-max-utilization", "Defragment packs where live contents use less than given percentage of pack size").Ints()
+	maintenanceSetDefragmentMaxBytes       = maintenanceSetCommand.Flag("defragment-max-bytes", "Maximum number of bytes rewritten by defragmentation in a single run (0 - unlimited)").Int64List()
+	maintenanceSetDefragmentMaxSpeed       = maintenanceSetCommand.Flag("defragment-max-bytes-per-second", "Maximum number of bytes per second rewritten by defragmentation (0 - unlimited)").Int64List()
 )
 
 func setMaintenanceOwnerFromFlags(p *maintenance.Params, rep *repo.DirectRepository, changed *bool) {
@@ -63,6 +68,40 @@ func setMaintenanceEnabledAndIntervalFromFlags(c *maintenance.CycleParams, cycle
 	}
 }
 
+func setMaintenanceDefragmentFromFlags(d *maintenance.DefragmentParams, changed *bool) {
+	if v := *maintenanceSetEnableDefragment; len(v) > 0 {
+		d.Enabled = v[len(v)-1]
+		*changed = true
+
+		if d.Enabled {
+			printStderr("Defragmentation of low-utilization packs enabled.\n")
+		} else {
+			printStderr("Defragmentation of low-utilization packs disabled.\n")
+		}
+	}
+
+	if v := *maintenanceSetDefragmentMaxUtilization; len(v) > 0 {
+		d.MaxUtilizationPercent = v[len(v)-1]
+		*changed = true
+
+		printStderr("Packs with utilization below %v%% will be defragmented.\n", d.MaxUtilizationPercent)
+	}
+
+	if v := *maintenanceSetDefragmentMaxBytes; len(v) > 0 {
+		d.MaxBytes = v[len(v)-1]
+		*changed = true
+
+		printStderr("Maximum number of bytes defragmented in a single run set to %v.\n", d.MaxBytes)
+	}
+
+	if v := *maintenanceSetDefragmentMaxSpeed; len(v) > 0 {
+		d.MaxBytesPerSecond = v[len(v)-1]
+		*changed = true
+
+		printStderr("Maximum defragmentation speed set to %v bytes per second.\n", d.MaxBytesPerSecond)
+	}
+}
+
 func runMaintenanceSetParams(ctx context.Context, rep *repo.DirectRepository) error {
 	p, err := maintenance.GetParams(ctx, rep)
 	if err != nil {
@@ -79,6 +118,7 @@ func runMaintenanceSetParams(ctx context.Context, rep *repo.DirectRepository) er
 	setMaintenanceOwnerFromFlags(p, rep, &changedParams)
 	setMaintenanceEnabledAndIntervalFromFlags(&p.QuickCycle, "quick", *maintenanceSetEnableQuick, *maintenanceSetQuickFrequency, &changedParams)
 	setMaintenanceEnabledAndIntervalFromFlags(&p.FullCycle, "full", *maintenanceSetEnableFull, *maintenanceSetFullFrequency, &changedParams)
+	setMaintenanceDefragmentFromFlags(&p.Defragment, &changedParams)
 
 	if v := *maintenanceSetPauseQuick; len(v) > 0 {
 		pauseDuration := v[len(v)-1]
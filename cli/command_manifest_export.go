@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	manifestExportCommand = manifestCommands.Command("export", "Export all manifest items to a file encrypted with a password, which can be imported into another repository.")
+	manifestExportFile    = manifestExportCommand.Arg("file", "Name of the exported file").Required().String()
+
+	manifestImportCommand = manifestCommands.Command("import", "Import manifest items from a file created by 'kopia manifest export'.")
+	manifestImportFile    = manifestImportCommand.Arg("file", "Name of the exported file").Required().ExistingFile()
+
+	manifestExportPassword = app.Flag("export-password", "Password of exported manifests.").Envar("KOPIA_EXPORT_PASSWORD").Hidden().String()
+)
+
+func runManifestExportCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	pass := *manifestExportPassword
+	if pass == "" {
+		p, err := askForNewPassword("Enter password to protect exported manifests: ")
+		if err != nil {
+			return errors.Wrap(err, "getting password")
+		}
+
+		pass = p
+	}
+
+	var buf bytes.Buffer
+
+	if err := rep.ExportManifests(ctx, &buf, pass); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(*manifestExportFile, buf.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "unable to write exported manifests")
+	}
+
+	printStderr("Exported manifests to %v.\n", *manifestExportFile)
+
+	return nil
+}
+
+func runManifestImportCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	b, err := ioutil.ReadFile(*manifestImportFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to read exported manifests")
+	}
+
+	pass := *manifestExportPassword
+	if pass == "" {
+		if pass, err = askPass("Enter password of exported manifests: "); err != nil {
+			return errors.Wrap(err, "getting password")
+		}
+	}
+
+	n, err := rep.ImportManifests(ctx, bytes.NewReader(b), pass)
+	if err != nil {
+		return err
+	}
+
+	printStderr("Imported %v manifests.\n", n)
+
+	return nil
+}
+
+func init() {
+	manifestExportCommand.Action(directRepositoryAction(runManifestExportCommand))
+	manifestImportCommand.Action(directRepositoryAction(runManifestImportCommand))
+}
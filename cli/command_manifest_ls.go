@@ -9,12 +9,14 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 )
 
 var (
 	manifestListCommand = manifestCommands.Command("list", "List manifest items").Alias("ls").Default()
 	manifestListFilter  = manifestListCommand.Flag("filter", "List of key:value pairs").Strings()
 	manifestListSort    = manifestListCommand.Flag("sort", "List of keys to sort by").Strings()
+	manifestListVersion = manifestListCommand.Flag("versions", "List previous versions of deleted or replaced items (snapshot manifests are not versioned)").Bool()
 )
 
 func init() {
@@ -33,7 +35,7 @@ func listManifestItems(ctx context.Context, rep repo.Repository) error {
 		filter[kv[0:p]] = kv[p+1:]
 	}
 
-	items, err := rep.FindManifests(ctx, filter)
+	items, err := findManifestItems(ctx, rep, filter)
 	if err != nil {
 		return err
 	}
@@ -56,6 +58,19 @@ func listManifestItems(ctx context.Context, rep repo.Repository) error {
 	return nil
 }
 
+func findManifestItems(ctx context.Context, rep repo.Repository, filter map[string]string) ([]*manifest.EntryMetadata, error) {
+	if !*manifestListVersion {
+		return rep.FindManifests(ctx, filter)
+	}
+
+	mm, err := directRepositoryManifests(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	return mm.ListVersions(ctx, filter)
+}
+
 func sortedMapValues(m map[string]string) string {
 	var result []string
 
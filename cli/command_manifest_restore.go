@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+var (
+	manifestRestoreCommand = manifestCommands.Command("restore", "Restore previous version of a deleted or replaced manifest item as a new item")
+	manifestRestoreItem    = manifestRestoreCommand.Arg("item", "ID of the previous version").Required().String()
+	manifestRestoreReplace = manifestRestoreCommand.Flag("replace", "Delete current items with the same labels, such as the policy that replaced it").Bool()
+)
+
+func runManifestRestoreCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	var payload json.RawMessage
+
+	md, err := rep.Manifests.GetVersion(ctx, manifest.ID(*manifestRestoreItem), &payload)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get previous version %q", *manifestRestoreItem)
+	}
+
+	if *manifestRestoreReplace {
+		current, err := rep.FindManifests(ctx, md.Labels)
+		if err != nil {
+			return errors.Wrap(err, "unable to find current items")
+		}
+
+		for _, c := range current {
+			if !reflect.DeepEqual(c.Labels, md.Labels) {
+				continue
+			}
+
+			if err := rep.DeleteManifest(ctx, c.ID); err != nil {
+				return errors.Wrapf(err, "unable to delete %v", c.ID)
+			}
+		}
+	}
+
+	id, err := rep.PutManifest(ctx, md.Labels, payload)
+	if err != nil {
+		return errors.Wrap(err, "unable to restore previous version")
+	}
+
+	printStderr("Restored previous version %v as %v.\n", md.ID, id)
+
+	return nil
+}
+
+// directRepositoryManifests returns the manifest manager of the direct repository, which provides previous versions of items.
+func directRepositoryManifests(rep repo.Repository) (*manifest.Manager, error) {
+	dr, ok := rep.(*repo.DirectRepository)
+	if !ok {
+		return nil, errors.New("previous versions are only available when connected directly to the repository")
+	}
+
+	return dr.Manifests, nil
+}
+
+func init() {
+	manifestRestoreCommand.Action(directRepositoryAction(runManifestRestoreCommand))
+}
@@ -14,6 +14,7 @@ import (
 var (
 	manifestShowCommand = manifestCommands.Command("show", "Show manifest items")
 	manifestShowItems   = manifestShowCommand.Arg("item", "List of items").Required().Strings()
+	manifestShowVersion = manifestShowCommand.Flag("versions", "Show previous versions of deleted or replaced items").Bool()
 )
 
 func init() {
@@ -30,11 +31,24 @@ func toManifestIDs(s []string) []manifest.ID {
 	return result
 }
 
+func getManifestItem(ctx context.Context, rep repo.Repository, id manifest.ID, data interface{}) (*manifest.EntryMetadata, error) {
+	if !*manifestShowVersion {
+		return rep.GetManifest(ctx, id, data)
+	}
+
+	mm, err := directRepositoryManifests(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	return mm.GetVersion(ctx, id, data)
+}
+
 func showManifestItems(ctx context.Context, rep repo.Repository) error {
 	for _, it := range toManifestIDs(*manifestShowItems) {
 		var b json.RawMessage
 
-		md, err := rep.GetManifest(ctx, it, &b)
+		md, err := getManifestItem(ctx, rep, it, &b)
 		if err != nil {
 			return errors.Wrapf(err, "error getting metadata for %q", it)
 		}
@@ -42,6 +56,7 @@ func showManifestItems(ctx context.Context, rep repo.Repository) error {
 		printStderr("// id: %v\n", it)
 		printStderr("// length: %v\n", md.Length)
 		printStderr("// modified: %v\n", formatTimestamp(md.ModTime))
+		printStderr("// created: %v\n", formatTimestamp(md.Created))
 
 		for k, v := range md.Labels {
 			printStderr("// label %v:%v\n", k, v)
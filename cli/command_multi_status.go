@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var (
+	multiStatusCommand   = multiCommands.Command("status", "Display the status of all repositories configured in the config directory.")
+	multiStatusConfigDir = multiStatusCommand.Flag("config-dir", "Directory with repository config files, defaults to the directory of the config file").String()
+	multiStatusJSON      = multiStatusCommand.Flag("json", "Show raw JSON data").Short('j').Bool()
+)
+
+// repositoryStatusSummary is the status of a single repository displayed by 'multi status'.
+type repositoryStatusSummary struct {
+	ConfigFile       string               `json:"configFile"`
+	Storage          string               `json:"storage,omitempty"`
+	Sources          int                  `json:"sources"`
+	Snapshots        int                  `json:"snapshots"`
+	LastSnapshot     *time.Time           `json:"lastSnapshot,omitempty"`
+	TotalSize        int64                `json:"totalSize"`
+	LastVerification *maintenance.RunInfo `json:"lastVerification,omitempty"`
+	Error            string               `json:"error,omitempty"`
+}
+
+func runMultiStatusCommand(ctx context.Context) error {
+	dir := *multiStatusConfigDir
+	if dir == "" {
+		dir = filepath.Dir(repositoryConfigFileName())
+	}
+
+	configFiles, err := filepath.Glob(filepath.Join(dir, "*.config"))
+	if err != nil {
+		return errors.Wrap(err, "unable to list config files")
+	}
+
+	var summaries []*repositoryStatusSummary
+
+	for _, cf := range configFiles {
+		s := &repositoryStatusSummary{ConfigFile: cf}
+
+		if err := summarizeRepository(ctx, cf, s); err != nil {
+			s.Error = err.Error()
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	if *multiStatusJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+
+		return e.Encode(summaries)
+	}
+
+	for _, s := range summaries {
+		printRepositoryStatusSummary(s)
+	}
+
+	return nil
+}
+
+// summarizeRepository opens the repository without prompting for password and fills in its status.
+func summarizeRepository(ctx context.Context, configFile string, s *repositoryStatusSummary) error {
+	pass, ok := repo.GetPersistedPassword(ctx, configFile)
+	if !ok {
+		pass = *password
+	}
+
+	rep, err := repo.Open(ctx, configFile, pass, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to open repository")
+	}
+
+	defer rep.Close(ctx) //nolint:errcheck
+
+	if dr, ok := rep.(*repo.DirectRepository); ok {
+		s.Storage = dr.Blobs.ConnectionInfo().Type
+
+		sched, err := maintenance.GetSchedule(ctx, dr)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance schedule")
+		}
+
+		if runs := sched.Runs[snapshotVerifyRunType]; len(runs) > 0 {
+			s.LastVerification = &runs[0]
+		}
+	}
+
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to list snapshots")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return errors.Wrap(err, "unable to load snapshots")
+	}
+
+	s.Snapshots = len(manifests)
+
+	for _, group := range snapshot.GroupBySource(manifests) {
+		s.Sources++
+
+		latest := snapshot.SortByTime(group, true)[0]
+		s.TotalSize += latest.Stats.TotalFileSize
+
+		if s.LastSnapshot == nil || latest.StartTime.After(*s.LastSnapshot) {
+			t := latest.StartTime
+			s.LastSnapshot = &t
+		}
+	}
+
+	return nil
+}
+
+func printRepositoryStatusSummary(s *repositoryStatusSummary) {
+	printStdout("%v\n", s.ConfigFile)
+
+	if s.Error != "" {
+		printStdout("  ERROR: %v\n", s.Error)
+		return
+	}
+
+	if s.Storage != "" {
+		printStdout("  storage:           %v\n", s.Storage)
+	}
+
+	printStdout("  sources:           %v\n", s.Sources)
+	printStdout("  snapshots:         %v\n", s.Snapshots)
+
+	if s.LastSnapshot != nil {
+		printStdout("  last snapshot:     %v (%v ago)\n", formatTimestamp(*s.LastSnapshot), time.Since(*s.LastSnapshot).Truncate(time.Second)) // allow:no-inject-time
+	}
+
+	printStdout("  total size:        %v\n", units.BytesStringBase10(s.TotalSize))
+
+	switch v := s.LastVerification; {
+	case v == nil:
+		printStdout("  last verification: never\n")
+	case v.Success:
+		printStdout("  last verification: %v SUCCESS\n", formatTimestamp(v.Start))
+	default:
+		printStdout("  last verification: %v ERROR: %v\n", formatTimestamp(v.Start), v.Error)
+	}
+}
+
+func init() {
+	multiStatusCommand.Action(noRepositoryAction(runMultiStatusCommand))
+}
@@ -65,6 +65,11 @@ func editPolicy(ctx context.Context, rep repo.Repository) error {
 	}
 
 	for _, target := range targets {
+		expected, err := policy.DefinedPolicyManifestIDs(ctx, rep, target)
+		if err != nil {
+			return errors.Wrap(err, "could not get defined policy")
+		}
+
 		original, err := policy.GetDefinedPolicy(ctx, rep, target)
 		if err == policy.ErrPolicyNotFound {
 			original = &policy.Policy{}
@@ -102,7 +107,8 @@ func editPolicy(ctx context.Context, rep repo.Repository) error {
 		fmt.Scanf("%v", &shouldSave) //nolint:errcheck
 
 		if strings.HasPrefix(strings.ToLower(shouldSave), "y") {
-			if err := policy.SetPolicy(ctx, rep, target, updated); err != nil {
+			// the policy may have been changed by another client while it was edited.
+			if err := policy.SetPolicyIfMatch(ctx, rep, target, updated, expected); err != nil {
 				return errors.Wrapf(err, "can't save policy for %v", target)
 			}
 		}
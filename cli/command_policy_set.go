@@ -10,6 +10,7 @@ import (
 
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/splitter"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
@@ -23,6 +24,7 @@ var (
 	// Frequency
 	policySetInterval   = policySetCommand.Flag("snapshot-interval", "Interval between snapshots").DurationList()
 	policySetTimesOfDay = policySetCommand.Flag("snapshot-time", "Times of day when to take snapshot (HH:mm)").Strings()
+	policySetStaleAfter = policySetCommand.Flag("stale-after", "Warn when the last successful snapshot is older than the provided age").DurationList()
 
 	// Expiration policies.
 	policySetKeepLatest  = policySetCommand.Flag("keep-latest", "Number of most recent backups to keep per source (or 'inherit')").PlaceHolder("N").String()
@@ -42,6 +44,9 @@ var (
 	policySetCompressionMinSize   = policySetCommand.Flag("compression-min-size", "Min size of file to attempt compression for").String()
 	policySetCompressionMaxSize   = policySetCommand.Flag("compression-max-size", "Max size of file to attempt compression for").String()
 
+	// Splitter used to break files into chunks.
+	policySetSplitter = policySetCommand.Flag("splitter", "Splitter used to break files into chunks, the repository default when not set (or 'inherit')").Enum(append([]string{inheritPolicyString}, splitter.SupportedAlgorithms()...)...)
+
 	// Files to only compress.
 	policySetAddOnlyCompress    = policySetCommand.Flag("add-only-compress", "List of extensions to add to the only-compress list").PlaceHolder("PATTERN").Strings()
 	policySetRemoveOnlyCompress = policySetCommand.Flag("remove-only-compress", "List of extensions to remove from the only-compress list").PlaceHolder("PATTERN").Strings()
@@ -57,10 +62,28 @@ var (
 	policySetRemoveDotIgnore = policySetCommand.Flag("remove-dot-ignore", "List of paths to remove from the dot-ignore list").PlaceHolder("FILENAME").Strings()
 	policySetClearDotIgnore  = policySetCommand.Flag("clear-dot-ignore", "Clear list of paths in the dot-ignore list").Bool()
 	policySetMaxFileSize     = policySetCommand.Flag("max-file-size", "Exclude files above given size").PlaceHolder("N").String()
+	policySetIgnoreSpecial   = policySetCommand.Flag("ignore-special-files", "Exclude named pipes, sockets and device nodes ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetIgnoreRepos     = policySetCommand.Flag("ignore-repositories", "Exclude Kopia cache and configuration directories and Kopia, restic or Borg repositories ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetIgnoreCacheDirs = policySetCommand.Flag("ignore-cache-dirs", "Exclude directories tagged with CACHEDIR.TAG or containing a .nobackup file ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetFollowSymlinks  = policySetCommand.Flag("follow-symlinks", "Store contents of files pointed to by symbolic links instead of the links ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetExtendedAttrs   = policySetCommand.Flag("extended-attributes", "Capture user and security extended attributes of files and directories ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetOneFileSystem   = policySetCommand.Flag("one-file-system", "Stay on the file system of the snapshot source and skip mount points of other file systems ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
 
 	// Error handling behavior.
 	policyIgnoreFileErrors      = policySetCommand.Flag("ignore-file-errors", "Ignore errors reading files while traversing ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
 	policyIgnoreDirectoryErrors = policySetCommand.Flag("ignore-dir-errors", "Ignore errors reading directories while traversing ('true', 'false', 'inherit").Enum(booleanEnumValues...)
+	policyRetryFileErrors       = policySetCommand.Flag("retry-file-errors", "Number of times reading a file is retried after an error (or 'inherit')").PlaceHolder("N").String()
+
+	// Upload behavior.
+	policySetMaxUploadSpeed      = policySetCommand.Flag("max-upload-speed", "Maximum rate at which data is written to the storage in bytes per second (or 'inherit')").PlaceHolder("N").String()
+	policySetCompareChangeInfo   = policySetCommand.Flag("compare-change-info", "Read files from previous snapshots again when their status change time or inode number changed, detecting modifications that preserve modification time ('true', 'false', 'inherit')").Enum(booleanEnumValues...)
+	policySetRehashUnchangedPerc = policySetCommand.Flag("rehash-unchanged-percentage", "Percentage of files unchanged since previous snapshots to read and hash anyway in each snapshot (or 'inherit')").PlaceHolder("N").String()
+
+	// Actions.
+	policySetBeforeSnapshotRootAction = policySetCommand.Flag("before-snapshot-root-action", "Shell command run before snapshotting the source, which may print KOPIA_SNAPSHOT_PATH=<path> to snapshot another directory (or 'inherit'), only on machines connected with --enable-actions").PlaceHolder("COMMAND").String()
+	policySetAfterSnapshotRootAction  = policySetCommand.Flag("after-snapshot-root-action", "Shell command run after snapshotting the source (or 'inherit')").PlaceHolder("COMMAND").String()
+	policySetActionTimeout            = policySetCommand.Flag("action-timeout", "Maximum time in seconds each action is allowed to run (or 'inherit')").PlaceHolder("SECONDS").String()
+	policySetActionFailureMode        = policySetCommand.Flag("action-failure-mode", "Whether snapshot fails when an action fails ('fail', 'continue', 'inherit')").Enum(string(policy.ActionFailureModeFail), string(policy.ActionFailureModeContinue), inheritPolicyString)
 
 	// General policy.
 	policySetInherit = policySetCommand.Flag(inheritPolicyString, "Enable or disable inheriting policies from the parent").BoolList()
@@ -81,6 +104,11 @@ func setPolicy(ctx context.Context, rep repo.Repository) error {
 	}
 
 	for _, target := range targets {
+		expected, err := policy.DefinedPolicyManifestIDs(ctx, rep, target)
+		if err != nil {
+			return errors.Wrap(err, "could not get defined policy")
+		}
+
 		p, err := policy.GetDefinedPolicy(ctx, rep, target)
 
 		switch {
@@ -101,7 +129,8 @@ func setPolicy(ctx context.Context, rep repo.Repository) error {
 			return errors.New("no changes specified")
 		}
 
-		if err := policy.SetPolicy(ctx, rep, target, p); err != nil {
+		// fail instead of overwriting changes made by other clients since the policy was read.
+		if err := policy.SetPolicyIfMatch(ctx, rep, target, p, expected); err != nil {
 			return errors.Wrapf(err, "can't save policy for %v", target)
 		}
 	}
@@ -128,10 +157,56 @@ func setPolicyFromFlags(p *policy.Policy, changeCount *int) error {
 		return errors.Wrap(err, "scheduling policy")
 	}
 
+	applyPolicyString("splitter", &p.SplitterPolicy.Algorithm, *policySetSplitter, changeCount)
+
 	if err := applyPolicyNumber64("maximum file size", &p.FilesPolicy.MaxFileSize, *policySetMaxFileSize, changeCount); err != nil {
 		return errors.Wrap(err, "maximum file size")
 	}
 
+	if err := applyPolicyBool("ignore special files", &p.FilesPolicy.IgnoreSpecialFiles, *policySetIgnoreSpecial, changeCount); err != nil {
+		return errors.Wrap(err, "ignore special files")
+	}
+
+	if err := applyPolicyBool("ignore repositories", &p.FilesPolicy.IgnoreRepositories, *policySetIgnoreRepos, changeCount); err != nil {
+		return errors.Wrap(err, "ignore repositories")
+	}
+
+	if err := applyPolicyBool("ignore cache directories", &p.FilesPolicy.IgnoreCacheDirs, *policySetIgnoreCacheDirs, changeCount); err != nil {
+		return errors.Wrap(err, "ignore cache directories")
+	}
+
+	if err := applyPolicyBool("follow symlinks", &p.FilesPolicy.FollowSymlinks, *policySetFollowSymlinks, changeCount); err != nil {
+		return errors.Wrap(err, "follow symlinks")
+	}
+
+	if err := applyPolicyBool("extended attributes", &p.FilesPolicy.ExtendedAttributes, *policySetExtendedAttrs, changeCount); err != nil {
+		return errors.Wrap(err, "extended attributes")
+	}
+
+	if err := applyPolicyBool("one file system", &p.FilesPolicy.OneFileSystem, *policySetOneFileSystem, changeCount); err != nil {
+		return errors.Wrap(err, "one file system")
+	}
+
+	if err := applyPolicyNumber64("maximum upload speed", &p.UploadPolicy.MaxUploadBytesPerSecond, *policySetMaxUploadSpeed, changeCount); err != nil {
+		return errors.Wrap(err, "maximum upload speed")
+	}
+
+	if err := applyPolicyBool("compare change info", &p.UploadPolicy.CompareChangeInfo, *policySetCompareChangeInfo, changeCount); err != nil {
+		return errors.Wrap(err, "compare change info")
+	}
+
+	if err := applyPolicyNumber("percentage of unchanged files to re-hash", &p.UploadPolicy.RehashUnchangedPercentage, *policySetRehashUnchangedPerc, changeCount); err != nil {
+		return errors.Wrap(err, "percentage of unchanged files to re-hash")
+	}
+
+	if v := p.UploadPolicy.RehashUnchangedPercentage; v != nil && (*v < 0 || *v > 100) {
+		return errors.Errorf("percentage of unchanged files to re-hash must be between 0 and 100")
+	}
+
+	if err := setActionsPolicyFromFlags(&p.ActionsPolicy, changeCount); err != nil {
+		return errors.Wrap(err, "actions policy")
+	}
+
 	// It's not really a list, just optional boolean, last one wins.
 	for _, inherit := range *policySetInherit {
 		*changeCount++
@@ -207,7 +282,7 @@ func setErrorHandlingPolicyFromFlags(fp *policy.ErrorHandlingPolicy, changeCount
 		printStderr(" - setting ignore directory read errors to %v\n", val)
 	}
 
-	return nil
+	return applyPolicyNumber("number of file read retries", &fp.RetryFileErrors, *policyRetryFileErrors, changeCount)
 }
 
 func setRetentionPolicyFromFlags(rp *policy.RetentionPolicy, changeCount *int) error {
@@ -244,6 +319,16 @@ func setSchedulingPolicyFromFlags(sp *policy.SchedulingPolicy, changeCount *int)
 		break
 	}
 
+	// It's not really a list, just optional value.
+	for _, staleAfter := range *policySetStaleAfter {
+		*changeCount++
+
+		sp.SetStaleAfter(staleAfter)
+		printStderr(" - setting stale snapshot age to %v\n", sp.StaleAfter())
+
+		break
+	}
+
 	if len(*policySetTimesOfDay) > 0 {
 		var timesOfDay []policy.TimeOfDay
 
@@ -355,6 +440,37 @@ func addRemoveDedupeAndSort(desc string, base, add, remove []string, changeCount
 	return s
 }
 
+func setActionsPolicyFromFlags(ap *policy.ActionsPolicy, changeCount *int) error {
+	applyPolicyString("before snapshot root action", &ap.BeforeSnapshotRoot, *policySetBeforeSnapshotRootAction, changeCount)
+	applyPolicyString("after snapshot root action", &ap.AfterSnapshotRoot, *policySetAfterSnapshotRootAction, changeCount)
+
+	failureMode := string(ap.FailureMode)
+	applyPolicyString("action failure mode", &failureMode, *policySetActionFailureMode, changeCount)
+	ap.FailureMode = policy.ActionFailureMode(failureMode)
+
+	return applyPolicyNumber64("action timeout", &ap.TimeoutSeconds, *policySetActionTimeout, changeCount)
+}
+
+func applyPolicyString(desc string, val *string, str string, changeCount *int) {
+	if str == "" {
+		// not changed
+		return
+	}
+
+	*changeCount++
+
+	if str == inheritPolicyString || str == "default" {
+		printStderr(" - resetting %v to a default value inherited from parent.\n", desc)
+
+		*val = ""
+
+		return
+	}
+
+	printStderr(" - setting %v to %q.\n", desc, str)
+	*val = str
+}
+
 func applyPolicyNumber(desc string, val **int, str string, changeCount *int) error {
 	if str == "" {
 		// not changed
@@ -385,6 +501,35 @@ func applyPolicyNumber(desc string, val **int, str string, changeCount *int) err
 	return nil
 }
 
+func applyPolicyBool(desc string, val **bool, str string, changeCount *int) error {
+	if str == "" {
+		// not changed
+		return nil
+	}
+
+	if str == inheritPolicyString || str == "default" {
+		*changeCount++
+
+		printStderr(" - resetting %v to a default value inherited from parent.\n", desc)
+
+		*val = nil
+
+		return nil
+	}
+
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return errors.Wrapf(err, "can't parse the %v %q", desc, str)
+	}
+
+	*changeCount++
+
+	printStderr(" - setting %v to %v.\n", desc, b)
+	*val = &b
+
+	return nil
+}
+
 func applyPolicyNumber64(desc string, val *int64, str string, changeCount *int) error {
 	if str == "" {
 		// not changed
@@ -84,6 +84,12 @@ func printPolicy(p *policy.Policy, parents []*policy.Policy) {
 	printSchedulingPolicy(p, parents)
 	printStdout("\n")
 	printCompressionPolicy(p, parents)
+	printStdout("\n")
+	printSplitterPolicy(p, parents)
+	printStdout("\n")
+	printUploadPolicy(p, parents)
+	printStdout("\n")
+	printActionsPolicy(p, parents)
 }
 
 func printRetentionPolicy(p *policy.Policy, parents []*policy.Policy) {
@@ -154,6 +160,42 @@ func printFilesPolicy(p *policy.Policy, parents []*policy.Policy) {
 				return pol.FilesPolicy.MaxFileSize != 0
 			}))
 	}
+
+	printStdout("  Ignore special files:  %5v   %v\n",
+		p.FilesPolicy.IgnoreSpecialFilesOrDefault(false),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.IgnoreSpecialFiles != nil
+		}))
+
+	printStdout("  Ignore repositories:   %5v   %v\n",
+		p.FilesPolicy.IgnoreRepositoriesOrDefault(true),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.IgnoreRepositories != nil
+		}))
+
+	printStdout("  Ignore cache dirs:     %5v   %v\n",
+		p.FilesPolicy.IgnoreCacheDirsOrDefault(false),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.IgnoreCacheDirs != nil
+		}))
+
+	printStdout("  Follow symlinks:       %5v   %v\n",
+		p.FilesPolicy.FollowSymlinksOrDefault(false),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.FollowSymlinks != nil
+		}))
+
+	printStdout("  Extended attributes:   %5v   %v\n",
+		p.FilesPolicy.ExtendedAttributesOrDefault(true),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.ExtendedAttributes != nil
+		}))
+
+	printStdout("  One file system:       %5v   %v\n",
+		p.FilesPolicy.OneFileSystemOrDefault(false),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.OneFileSystem != nil
+		}))
 }
 
 func printErrorHandlingPolicy(p *policy.Policy, parents []*policy.Policy) {
@@ -170,6 +212,12 @@ func printErrorHandlingPolicy(p *policy.Policy, parents []*policy.Policy) {
 		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
 			return pol.ErrorHandlingPolicy.IgnoreDirectoryErrors != nil
 		}))
+
+	printStdout("  Retry file read errors:        %5v       %v\n",
+		p.ErrorHandlingPolicy.RetryFileErrorsOrDefault(0),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.ErrorHandlingPolicy.RetryFileErrors != nil
+		}))
 }
 
 func printSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
@@ -185,6 +233,14 @@ func printSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
 		any = true
 	}
 
+	if p.SchedulingPolicy.StaleAfter() != 0 {
+		printStdout("  Stale after:         %10v  %v\n", p.SchedulingPolicy.StaleAfter(), getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.SchedulingPolicy.StaleAfter() != 0
+		}))
+
+		any = true
+	}
+
 	if len(p.SchedulingPolicy.TimesOfDay) > 0 {
 		printStdout("  Snapshot times:\n")
 
@@ -209,6 +265,74 @@ func printSchedulingPolicy(p *policy.Policy, parents []*policy.Policy) {
 	}
 }
 
+func printUploadPolicy(p *policy.Policy, parents []*policy.Policy) {
+	printStdout("Upload:\n")
+
+	if rate := p.UploadPolicy.MaxUploadBytesPerSecond; rate > 0 {
+		printStdout("  Max upload speed:    %10v/s %v\n",
+			units.BytesStringBase10(rate),
+			getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+				return pol.UploadPolicy.MaxUploadBytesPerSecond != 0
+			}))
+	} else {
+		printStdout("  Max upload speed:     unlimited\n")
+	}
+
+	printStdout("  Compare change info:  %v %v\n",
+		p.UploadPolicy.CompareChangeInfoOrDefault(false),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.UploadPolicy.CompareChangeInfo != nil
+		}))
+
+	printStdout("  Re-hash unchanged:    %v%% %v\n",
+		p.UploadPolicy.RehashUnchangedPercentageOrDefault(0),
+		getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.UploadPolicy.RehashUnchangedPercentage != nil
+		}))
+}
+
+func printActionsPolicy(p *policy.Policy, parents []*policy.Policy) {
+	ap := p.ActionsPolicy
+
+	if ap.BeforeSnapshotRoot == "" && ap.AfterSnapshotRoot == "" {
+		printStdout("No actions defined.\n")
+		return
+	}
+
+	printStdout("Actions:\n")
+
+	if ap.BeforeSnapshotRoot != "" {
+		printStdout("  Before snapshot root: %q %v\n", ap.BeforeSnapshotRoot, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.ActionsPolicy.BeforeSnapshotRoot != ""
+		}))
+	}
+
+	if ap.AfterSnapshotRoot != "" {
+		printStdout("  After snapshot root:  %q %v\n", ap.AfterSnapshotRoot, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+			return pol.ActionsPolicy.AfterSnapshotRoot != ""
+		}))
+	}
+
+	printStdout("  Timeout:              %vs %v\n", ap.TimeoutSeconds, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+		return pol.ActionsPolicy.TimeoutSeconds != 0
+	}))
+
+	printStdout("  Failure mode:         %v %v\n", ap.FailureModeOrDefault(), getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+		return pol.ActionsPolicy.FailureMode != ""
+	}))
+}
+
+func printSplitterPolicy(p *policy.Policy, parents []*policy.Policy) {
+	if p.SplitterPolicy.Algorithm == "" {
+		printStdout("Splitter: repository default.\n")
+		return
+	}
+
+	printStdout("Splitter: %q %v\n", p.SplitterPolicy.Algorithm, getDefinitionPoint(parents, func(pol *policy.Policy) bool {
+		return pol.SplitterPolicy.Algorithm != ""
+	}))
+}
+
 func printCompressionPolicy(p *policy.Policy, parents []*policy.Policy) {
 	if p.CompressionPolicy.CompressorName != "" && p.CompressionPolicy.CompressorName != "none" {
 		printStdout("Compression:\n")
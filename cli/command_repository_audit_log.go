@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	auditLogCommands = repositoryCommands.Command("audit-log", "Commands to manage the log of changes to policies, snapshots and other manifests recorded in the repository.")
+
+	auditLogListCommand = auditLogCommands.Command("list", "List recorded changes.").Alias("ls").Default()
+
+	auditLogVerifyCommand = auditLogCommands.Command("verify", "Verify that no records were removed, modified or inserted.")
+
+	auditLogEnableCommand   = auditLogCommands.Command("enable", "Start recording changes made by all clients of the repository.")
+	auditLogEnableRetention = auditLogEnableCommand.Flag("retention", "Remove records older than this during full maintenance (0 == keep forever).").Default("0s").Duration()
+
+	auditLogDisableCommand = auditLogCommands.Command("disable", "Stop recording changes, existing records are kept.")
+)
+
+func runAuditLogListCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	h, err := rep.AuditLog.Head(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to read audit log")
+	}
+
+	if h == nil || !h.Enabled {
+		printStderr("Audit log is not enabled, use 'kopia repository audit-log enable' to start recording changes.\n")
+	}
+
+	records, err := rep.AuditLog.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to read audit log")
+	}
+
+	for _, r := range records {
+		for _, c := range r.Changes {
+			printStdout("%6v %v %v@%v %-6v %v %v %v\n",
+				r.Sequence, formatTimestamp(r.Time), r.Username, r.Hostname, c.Operation, c.ID, c.Labels["type"], sortedMapValues(c.Labels))
+		}
+	}
+
+	return nil
+}
+
+func runAuditLogVerifyCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	problems, err := rep.AuditLog.Verify(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify audit log")
+	}
+
+	for _, p := range problems {
+		printStderr("%v\n", p)
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("found %v problems in audit log", len(problems))
+	}
+
+	h, err := rep.AuditLog.Head(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to read audit log")
+	}
+
+	if h != nil && h.LatestID != "" {
+		// removal of the newest records together with rollback of the head can only be detected
+		// by comparing with a copy of the latest record kept outside of the repository.
+		printStderr("Audit log verified, latest record is %v (sequence number %v, hash %v).\n", h.LatestID, h.Sequence, h.LatestHash)
+		return nil
+	}
+
+	printStderr("Audit log verified.\n")
+
+	return nil
+}
+
+func runAuditLogEnableCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	if err := rep.AuditLog.Enable(ctx, *auditLogEnableRetention); err != nil {
+		return errors.Wrap(err, "unable to enable audit log")
+	}
+
+	printStderr("Audit log enabled.\n")
+
+	return nil
+}
+
+func runAuditLogDisableCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	if err := rep.AuditLog.Disable(ctx); err != nil {
+		return errors.Wrap(err, "unable to disable audit log")
+	}
+
+	printStderr("Audit log disabled.\n")
+
+	return nil
+}
+
+func init() {
+	auditLogListCommand.Action(directRepositoryAction(runAuditLogListCommand))
+	auditLogVerifyCommand.Action(directRepositoryAction(runAuditLogVerifyCommand))
+	auditLogEnableCommand.Action(directRepositoryAction(runAuditLogEnableCommand))
+	auditLogDisableCommand.Action(directRepositoryAction(runAuditLogDisableCommand))
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	changePasswordCommand     = repositoryCommands.Command("change-password", "Change repository password without re-encrypting its data (not supported in repositories created by older versions, where the master key is derived from the original password).")
+	changePasswordNewPassword = changePasswordCommand.Flag("new-password", "New repository password.").Envar("KOPIA_NEW_PASSWORD").String()
+)
+
+func runChangePasswordCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	newPassword := strings.TrimSpace(*changePasswordNewPassword)
+
+	if newPassword == "" {
+		p, err := askForNewPassword("Enter new password for the repository: ")
+		if err != nil {
+			return errors.Wrap(err, "getting new password")
+		}
+
+		newPassword = p
+	}
+
+	if err := rep.ChangePassword(ctx, newPassword); err != nil {
+		return errors.Wrap(err, "unable to change password")
+	}
+
+	printStderr("Password changed. Other clients connected to the repository should reconnect using the new password.\n")
+
+	return nil
+}
+
+func init() {
+	changePasswordCommand.Action(directRepositoryAction(runChangePasswordCommand))
+}
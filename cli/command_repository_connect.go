@@ -20,9 +20,15 @@ var (
 	connectMaxCacheSizeMB         int64
 	connectMaxMetadataCacheSizeMB int64
 	connectMaxListCacheDuration   time.Duration
+	connectMaxBlobCacheSizeMB     int64
+	connectStorageQuotaMB         int64
+	connectIndexStore             string
 	connectHostname               string
 	connectUsername               string
 	connectCheckForUpdates        bool
+	connectUseKMS                 bool
+	connectKeyFile                string
+	connectEnableActions          bool
 )
 
 func setupConnectOptions(cmd *kingpin.CmdClause) {
@@ -32,9 +38,15 @@ func setupConnectOptions(cmd *kingpin.CmdClause) {
 	cmd.Flag("cache-directory", "Cache directory").PlaceHolder("PATH").StringVar(&connectCacheDirectory)
 	cmd.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("5000").Int64Var(&connectMaxCacheSizeMB)
 	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("5000").Int64Var(&connectMaxMetadataCacheSizeMB)
+	cmd.Flag("blob-cache-size-mb", "Size of local cache of recently used blobs (0 to disable)").PlaceHolder("MB").Default("0").Int64Var(&connectMaxBlobCacheSizeMB)
+	cmd.Flag("storage-quota-mb", "Approximate maximum size of the repository storage, new data is refused above it (0 to disable)").PlaceHolder("MB").Default("0").Int64Var(&connectStorageQuotaMB)
+	cmd.Flag("index-store", "Local store of content index, 'consolidated' combines index blobs into a single local index for faster lookups in large repositories").EnumVar(&connectIndexStore, content.IndexStoreConsolidated)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").Default("600s").Hidden().DurationVar(&connectMaxListCacheDuration)
 	cmd.Flag("override-hostname", "Override hostname used by this repository connection").Hidden().StringVar(&connectHostname)
 	cmd.Flag("override-username", "Override username used by this repository connection").Hidden().StringVar(&connectUsername)
+	cmd.Flag("use-kms", "Open the repository using KMS credentials instead of a password").BoolVar(&connectUseKMS)
+	cmd.Flag("key-file", "Open the repository using the key file instead of a password, which is used as its passphrase if it's protected").PlaceHolder("PATH").StringVar(&connectKeyFile)
+	cmd.Flag("enable-actions", "Allow running commands defined in snapshot actions of policies on this machine").BoolVar(&connectEnableActions)
 	cmd.Flag("check-for-updates", "Periodically check for Kopia updates on GitHub").Default("true").Envar(checkForUpdatesEnvar).BoolVar(&connectCheckForUpdates)
 }
 
@@ -46,9 +58,17 @@ func connectOptions() *repo.ConnectOptions {
 			MaxCacheSizeBytes:         connectMaxCacheSizeMB << 20,         //nolint:gomnd
 			MaxMetadataCacheSizeBytes: connectMaxMetadataCacheSizeMB << 20, //nolint:gomnd
 			MaxListCacheDurationSec:   int(connectMaxListCacheDuration.Seconds()),
+			MaxBlobCacheSizeBytes:     connectMaxBlobCacheSizeMB << 20, //nolint:gomnd
+			IndexStore:                connectIndexStore,
 		},
-		HostnameOverride: connectHostname,
-		UsernameOverride: connectUsername,
+		HostnameOverride:   connectHostname,
+		UsernameOverride:   connectUsername,
+		StorageQuotaBytes:  connectStorageQuotaMB << 20, //nolint:gomnd
+		UseKMS:             connectUseKMS,
+		HardwareKeyCommand: *hardwareKeyCommand,
+		KeyFile:            connectKeyFile,
+		EnableActions:      connectEnableActions,
+		RecoveryCode:       *recoveryCode,
 	}
 }
 
@@ -57,6 +77,19 @@ func init() {
 }
 
 func runConnectCommandWithStorage(ctx context.Context, st blob.Storage) error {
+	if connectUseKMS || *recoveryCode != "" {
+		return runConnectCommandWithStorageAndPassword(ctx, st, "")
+	}
+
+	if connectKeyFile != "" {
+		passphrase, err := getKeyFilePassphrase(ctx, connectKeyFile, false)
+		if err != nil {
+			return errors.Wrap(err, "getting key file passphrase")
+		}
+
+		return runConnectCommandWithStorageAndPassword(ctx, st, passphrase)
+	}
+
 	password, err := getPasswordFromFlags(ctx, false, false)
 	if err != nil {
 		return errors.Wrap(err, "getting password")
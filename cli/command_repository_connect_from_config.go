@@ -13,6 +13,7 @@ import (
 
 var connectFromConfigFile string
 var connectFromConfigToken string
+var connectFromRecoveryKey string
 
 func connectToStorageFromConfig(ctx context.Context, isNew bool) (blob.Storage, error) {
 	if isNew {
@@ -27,7 +28,11 @@ func connectToStorageFromConfig(ctx context.Context, isNew bool) (blob.Storage,
 		return connectToStorageFromConfigToken(ctx)
 	}
 
-	return nil, errors.New("either --file or --token must be provided")
+	if connectFromRecoveryKey != "" {
+		return connectToStorageFromRecoveryKey(ctx, connectFromRecoveryKey)
+	}
+
+	return nil, errors.New("either --file, --token or --recovery-key must be provided")
 }
 
 func connectToStorageFromConfigFile(ctx context.Context) (blob.Storage, error) {
@@ -64,6 +69,7 @@ func init() {
 		func(cmd *kingpin.CmdClause) {
 			cmd.Flag("file", "Path to the configuration file").StringVar(&connectFromConfigFile)
 			cmd.Flag("token", "Configuration token").StringVar(&connectFromConfigToken)
+			cmd.Flag("recovery-key", "Recovery key produced by 'kopia repository export-recovery-key'").StringVar(&connectFromRecoveryKey)
 		},
 		connectToStorageFromConfig)
 }
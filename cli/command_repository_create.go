@@ -2,9 +2,12 @@ package cli
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
@@ -19,27 +22,95 @@ import (
 var (
 	createCommand = repositoryCommands.Command("create", "Create new repository in a specified location.")
 
-	createBlockHashFormat       = createCommand.Flag("block-hash", "Content hash algorithm.").PlaceHolder("ALGO").Default(hashing.DefaultAlgorithm).Enum(hashing.SupportedAlgorithms()...)
+	createBlockHashFormat       = createCommand.Flag("block-hash", "Content hash algorithm.").PlaceHolder("ALGO").Default(hashing.DefaultAlgorithm).PreAction(createFlagSetByUser("block-hash")).Enum(hashing.SupportedAlgorithms()...)
 	createBlockEncryptionFormat = createCommand.Flag("encryption", "Content encryption algorithm.").PlaceHolder("ALGO").Default(encryption.DefaultAlgorithm).Enum(encryption.SupportedAlgorithms(false)...)
+	createFormatEncryption      = createCommand.Flag("format-encryption", "Encryption algorithm of the repository format blob.").PlaceHolder("ALGO").Default(repo.FormatEncryptionAES256GCM).Enum(repo.SupportedFormatEncryptionAlgorithms...)
+	createKeyDerivation         = createCommand.Flag("key-derivation", "Algorithm used to derive the master key from password.").Default(keyDerivationScrypt).PreAction(createFlagSetByUser("key-derivation")).Enum(keyDerivationScrypt, keyDerivationArgon2id, keyDerivationPBKDF2)
+	createArgon2idMemoryKiB     = createCommand.Flag("argon2id-memory", "Amount of memory (in KiB) used by Argon2id key derivation.").Default(strconv.Itoa(repo.DefaultArgon2idMemoryKiB)).Uint32()
+	createArgon2idIterations    = createCommand.Flag("argon2id-iterations", "Number of iterations of Argon2id key derivation.").Default(strconv.Itoa(repo.DefaultArgon2idIterations)).Uint32()
+	createArgon2idParallelism   = createCommand.Flag("argon2id-parallelism", "Degree of parallelism of Argon2id key derivation.").Default(strconv.Itoa(repo.DefaultArgon2idParallelism)).Uint8()
+	createPBKDF2Iterations      = createCommand.Flag("pbkdf2-iterations", "Number of iterations of PBKDF2 key derivation.").Default(strconv.Itoa(repo.DefaultPBKDF2Iterations)).Int()
+	createScryptCost            = createCommand.Flag("scrypt-cost", "CPU/memory cost of scrypt key derivation, power of two.").Default("65536").Int()
+	createKeyDerivationTarget   = createCommand.Flag("key-derivation-target", "Select parameters of key derivation taking approximately given time on this machine (see 'kopia benchmark kdf').").Duration()
 	createSplitter              = createCommand.Flag("object-splitter", "The splitter to use for new objects in the repository").Default(splitter.DefaultAlgorithm).Enum(splitter.SupportedAlgorithms()...)
+	createAvgChunkSize          = createCommand.Flag("avg-chunk-size", "Average size of chunks produced by the dynamic splitter, power of two (default implied by splitter name).").Bytes()
+	createMinChunkSize          = createCommand.Flag("min-chunk-size", "Minimum size of chunks produced by the dynamic splitter (default half of average).").Bytes()
+	createMaxChunkSize          = createCommand.Flag("max-chunk-size", "Maximum size of chunks produced by the dynamic splitter (default twice the average).").Bytes()
+
+	createRecoveryShares    = createCommand.Flag("recovery-shares", "Split the master key into the number of recovery shares printed after creation (0 to disable).").Default("0").Int()
+	createRecoveryThreshold = createCommand.Flag("recovery-threshold", "Number of recovery shares required to open the repository.").Default("3").Int()
+	createRecoveryCode      = createCommand.Flag("generate-recovery-code", "Print a recovery code which can open the repository with --recovery-code when the password is lost.").Bool()
 
 	createOnly = createCommand.Flag("create-only", "Create repository, but don't connect to it.").Short('c').Bool()
 )
 
+const (
+	keyDerivationScrypt   = "scrypt"
+	keyDerivationArgon2id = "argon2id"
+	keyDerivationPBKDF2   = "pbkdf2"
+
+	// fipsDefaultBlockHash is the content hash algorithm used in FIPS mode unless specified.
+	fipsDefaultBlockHash = "HMAC-SHA256-128"
+)
+
+// createFlagsSetByUser contains names of flags of 'repository create' which were explicitly provided.
+var createFlagsSetByUser = map[string]bool{}
+
+func createFlagSetByUser(name string) kingpin.Action {
+	return func(*kingpin.ParseContext) error {
+		createFlagsSetByUser[name] = true
+		return nil
+	}
+}
+
 func init() {
 	setupConnectOptions(createCommand)
 }
 
+func keyDerivationKindFromFlags() string {
+	if repo.FIPSMode() && !createFlagsSetByUser["key-derivation"] {
+		return keyDerivationPBKDF2
+	}
+
+	return *createKeyDerivation
+}
+
+func keyDerivationAlgorithmFromFlags() string {
+	switch keyDerivationKindFromFlags() {
+	case keyDerivationArgon2id:
+		return repo.Argon2idKeyDerivationAlgorithm(*createArgon2idMemoryKiB, *createArgon2idIterations, *createArgon2idParallelism)
+	case keyDerivationPBKDF2:
+		return repo.PBKDF2KeyDerivationAlgorithm(*createPBKDF2Iterations)
+	default:
+		return repo.ScryptKeyDerivationAlgorithm(*createScryptCost, 8, 1) //nolint:gomnd
+	}
+}
+
+func blockHashFromFlags() string {
+	// in FIPS mode the default hash is replaced, but explicitly requested one is validated instead.
+	if repo.FIPSMode() && !createFlagsSetByUser["block-hash"] {
+		return fipsDefaultBlockHash
+	}
+
+	return *createBlockHashFormat
+}
+
 func newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
 	return &repo.NewRepositoryOptions{
 		BlockFormat: content.FormattingOptions{
-			Hash:       *createBlockHashFormat,
+			Hash:       blockHashFromFlags(),
 			Encryption: *createBlockEncryptionFormat,
 		},
 
 		ObjectFormat: object.Format{
-			Splitter: *createSplitter,
+			Splitter:     *createSplitter,
+			MinChunkSize: int(*createMinChunkSize),
+			AvgChunkSize: int(*createAvgChunkSize),
+			MaxChunkSize: int(*createMaxChunkSize),
 		},
+
+		FormatEncryption:       *createFormatEncryption,
+		KeyDerivationAlgorithm: keyDerivationAlgorithmFromFlags(),
 	}
 }
 
@@ -64,6 +135,25 @@ func runCreateCommandWithStorage(ctx context.Context, st blob.Storage) error {
 
 	options := newRepositoryOptionsFromFlags()
 
+	if target := *createKeyDerivationTarget; target > 0 {
+		printStderr("Selecting %v parameters taking %v...\n", keyDerivationKindFromFlags(), target)
+
+		b, err := repo.BenchmarkKeyDerivation(keyDerivationKindFromFlags(), target)
+		if err != nil {
+			return errors.Wrap(err, "unable to benchmark key derivation")
+		}
+
+		options.KeyDerivationAlgorithm = b.Algorithm
+	}
+
+	if err := repo.ValidateKeyDerivationAlgorithm(options.KeyDerivationAlgorithm); err != nil {
+		return errors.Wrap(err, "invalid key derivation")
+	}
+
+	if err := repo.ValidateFIPSOptions(options); err != nil {
+		return errors.Wrap(err, "invalid repository options")
+	}
+
 	password, err := getPasswordFromFlags(ctx, true, false)
 	if err != nil {
 		return errors.Wrap(err, "getting password")
@@ -72,12 +162,26 @@ func runCreateCommandWithStorage(ctx context.Context, st blob.Storage) error {
 	printStderr("Initializing repository with:\n")
 	printStderr("  block hash:          %v\n", options.BlockFormat.Hash)
 	printStderr("  encryption:          %v\n", options.BlockFormat.Encryption)
+	printStderr("  format encryption:   %v\n", options.FormatEncryption)
+	printStderr("  key derivation:      %v\n", options.KeyDerivationAlgorithm)
 	printStderr("  splitter:            %v\n", options.ObjectFormat.Splitter)
 
+	if options.ObjectFormat.AvgChunkSize != 0 {
+		printStderr("  average chunk size:  %v\n", units.BytesStringBase2(int64(options.ObjectFormat.AvgChunkSize)))
+	}
+
+	if *createRecoveryCode {
+		options.RecoveryCode = repo.GenerateRecoveryCode()
+	}
+
 	if err := repo.Initialize(ctx, st, options, password); err != nil {
 		return errors.Wrap(err, "cannot initialize repository")
 	}
 
+	if options.RecoveryCode != "" {
+		printRecoveryCode(options.RecoveryCode)
+	}
+
 	if *createOnly {
 		return nil
 	}
@@ -112,6 +216,14 @@ func populateRepository(ctx context.Context, password string) error {
 		if err := maintenance.SetParams(ctx, dr, &p); err != nil {
 			return errors.Wrap(err, "unable to set maintenance params")
 		}
+
+		if *createRecoveryShares > 0 {
+			printStdout("\n")
+
+			if err := printRecoveryShares(dr, *createRecoveryShares, *createRecoveryThreshold); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
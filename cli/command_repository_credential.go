@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	credentialCommands = repositoryCommands.Command("credential", "Commands to manage credentials that can open the repository.").Alias("credentials")
+
+	credentialListCommand = credentialCommands.Command("list", "List credentials.").Alias("ls")
+
+	credentialAddCommand  = credentialCommands.Command("add", "Add a named credential with its own password, KMS key, key file or recovery code.")
+	credentialAddName     = credentialAddCommand.Arg("name", "Name of the credential").Required().String()
+	credentialAddPassword = credentialAddCommand.Flag("credential-password", "Password of the new credential.").Envar("KOPIA_CREDENTIAL_PASSWORD").String()
+	credentialAddHardware = credentialAddCommand.Flag("hardware-key", "Require the hardware key configured with --hardware-key-command in addition to the password of the new credential.").Bool()
+	credentialAddKMS      = credentialAddCommand.Flag("kms", "URL of the KMS key protecting the new credential instead of a password (awskms://, gcpkms:// or azurekeyvault://).").PlaceHolder("URL").String()
+	credentialAddKeyFile  = credentialAddCommand.Flag("key-file", "Key file protecting the new credential instead of a password, created with 'kopia repository generate-keyfile'.").PlaceHolder("PATH").String()
+	credentialAddCode     = credentialAddCommand.Flag("generate-recovery-code", "Protect the new credential with a printed recovery code instead of a password.").Bool()
+
+	credentialRemoveCommand = credentialCommands.Command("remove", "Revoke a credential, so that its password can no longer open the repository. The master key is not rotated, so whoever kept a copy of it can still decrypt the data.").Alias("rm")
+	credentialRemoveName    = credentialRemoveCommand.Arg("name", "Name of the credential").Required().String()
+)
+
+func runCredentialListCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	for _, name := range rep.Credentials() {
+		desc := name
+
+		if kms := rep.CredentialKMSKeyURL(name); kms != "" {
+			desc += " kms:" + kms
+		}
+
+		if rep.CredentialUsesKeyFile(name) {
+			desc += " (key file)"
+		}
+
+		if rep.CredentialUsesRecoveryCode(name) {
+			desc += " (recovery code)"
+		}
+
+		if rep.CredentialUsesHardwareKey(name) {
+			desc += " (hardware key)"
+		}
+
+		if name == rep.CurrentCredential() {
+			desc += " (current)"
+		}
+
+		printStdout("%v\n", desc)
+	}
+
+	return nil
+}
+
+func runCredentialAddCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	if *credentialAddKMS != "" {
+		if err := rep.AddKMSCredential(ctx, *credentialAddName, *credentialAddKMS); err != nil {
+			return errors.Wrap(err, "unable to add KMS credential")
+		}
+
+		printStderr("Added KMS credential %v, connect using it with 'kopia repository connect --use-kms'.\n", *credentialAddName)
+
+		return nil
+	}
+
+	if *credentialAddCode {
+		code, err := rep.AddRecoveryCodeCredential(ctx, *credentialAddName)
+		if err != nil {
+			return errors.Wrap(err, "unable to add recovery code credential")
+		}
+
+		printRecoveryCode(code)
+
+		return nil
+	}
+
+	if *credentialAddKeyFile != "" {
+		passphrase, err := getKeyFilePassphrase(ctx, *credentialAddKeyFile, false)
+		if err != nil {
+			return errors.Wrap(err, "getting key file passphrase")
+		}
+
+		if err := rep.AddKeyFileCredential(ctx, *credentialAddName, *credentialAddKeyFile, passphrase); err != nil {
+			return errors.Wrap(err, "unable to add key file credential")
+		}
+
+		printStderr("Added key file credential %v, connect using it with 'kopia repository connect --key-file=%v'.\n", *credentialAddName, *credentialAddKeyFile)
+
+		return nil
+	}
+
+	pass := strings.TrimSpace(*credentialAddPassword)
+
+	if pass == "" {
+		p, err := askForNewPassword("Enter password for the new credential: ")
+		if err != nil {
+			return errors.Wrap(err, "getting password")
+		}
+
+		pass = p
+	}
+
+	if *credentialAddHardware {
+		printStderr("Touch the hardware key if it's blinking.\n")
+
+		if err := rep.AddHardwareKeyCredential(ctx, *credentialAddName, pass); err != nil {
+			return errors.Wrap(err, "unable to add hardware key credential")
+		}
+	} else if err := rep.AddCredential(ctx, *credentialAddName, pass); err != nil {
+		return errors.Wrap(err, "unable to add credential")
+	}
+
+	printStderr("Added credential %v.\n", *credentialAddName)
+
+	return nil
+}
+
+func runCredentialRemoveCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	if err := rep.RemoveCredential(ctx, *credentialRemoveName); err != nil {
+		return errors.Wrap(err, "unable to remove credential")
+	}
+
+	printStderr("Removed credential %v.\n", *credentialRemoveName)
+	printStderr("NOTE: The master key is not changed, anyone who used this credential and kept a copy of the master key can still decrypt all data.\n")
+
+	return nil
+}
+
+func init() {
+	credentialListCommand.Action(directRepositoryAction(runCredentialListCommand))
+	credentialAddCommand.Action(directRepositoryAction(runCredentialAddCommand))
+	credentialRemoveCommand.Action(directRepositoryAction(runCredentialRemoveCommand))
+}
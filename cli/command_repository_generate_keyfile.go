@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var (
+	generateKeyFileCommand = repositoryCommands.Command("generate-keyfile", "Generate a key file with a random key, which can be added as a credential to open the repository on unattended servers.")
+	generateKeyFilePath    = generateKeyFileCommand.Arg("path", "Path of the new key file").Required().String()
+	generateKeyFileProtect = generateKeyFileCommand.Flag("protect", "Protect the key file with a passphrase.").Bool()
+	generateKeyFileTPM     = generateKeyFileCommand.Flag("tpm", "Seal the key to the TPM of this machine using tpm2-tools, so that the key file can't be used elsewhere.").Bool()
+	generateKeyFileTPMPCRs = generateKeyFileCommand.Flag("tpm-pcrs", "PCRs whose current values are required to unseal the key, such as 'sha256:0,2,4,7' (empty to disable).").Default(repo.DefaultTPMPCRs).String()
+
+	keyFilePassphrase = app.Flag("key-file-passphrase", "Passphrase protecting the key file.").Envar("KOPIA_KEY_FILE_PASSPHRASE").Hidden().String()
+)
+
+func runGenerateKeyFileCommand(ctx context.Context) error {
+	if *generateKeyFileTPM {
+		if *generateKeyFileProtect {
+			return errors.New("TPM-sealed key file can't be protected with a passphrase")
+		}
+
+		if err := repo.GenerateTPMKeyFile(ctx, *generateKeyFilePath, *generateKeyFileTPMPCRs); err != nil {
+			return errors.Wrap(err, "unable to generate key file")
+		}
+
+		printStderr("Generated key file %v sealed to the TPM, add it as a credential with 'kopia repository credential add NAME --key-file=%v'.\n", *generateKeyFilePath, *generateKeyFilePath)
+
+		return nil
+	}
+
+	passphrase := *keyFilePassphrase
+
+	if *generateKeyFileProtect && passphrase == "" {
+		p, err := askForNewPassword("Enter passphrase to protect the key file: ")
+		if err != nil {
+			return errors.Wrap(err, "getting passphrase")
+		}
+
+		passphrase = p
+	}
+
+	if err := repo.GenerateKeyFile(*generateKeyFilePath, passphrase); err != nil {
+		return errors.Wrap(err, "unable to generate key file")
+	}
+
+	printStderr("Generated key file %v, add it as a credential with 'kopia repository credential add NAME --key-file=%v'.\n", *generateKeyFilePath, *generateKeyFilePath)
+
+	return nil
+}
+
+// getKeyFilePassphrase returns the passphrase of the provided key file, asking for it if it's protected
+// and was neither persisted nor provided using --key-file-passphrase.
+func getKeyFilePassphrase(ctx context.Context, path string, allowPersistent bool) (string, error) {
+	protected, err := repo.KeyFileRequiresPassphrase(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !protected {
+		return "", nil
+	}
+
+	if allowPersistent {
+		if pass, ok := repo.GetPersistedPassword(ctx, repositoryConfigFileName()); ok {
+			return pass, nil
+		}
+	}
+
+	if *keyFilePassphrase != "" {
+		return *keyFilePassphrase, nil
+	}
+
+	return askPass("Enter key file passphrase: ")
+}
+
+func init() {
+	generateKeyFileCommand.Action(noRepositoryAction(runGenerateKeyFileCommand))
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/content"
+)
+
+var (
+	lifecyclePolicyCommand                = repositoryCommands.Command("lifecycle-policy", "Generate storage-side lifecycle rules that are safe to use with the repository.")
+	lifecyclePolicyProvider               = lifecyclePolicyCommand.Flag("provider", "Storage provider (defaults to the provider of the connected repository).").String()
+	lifecyclePolicyPrefix                 = lifecyclePolicyCommand.Flag("prefix", "Prefix of objects of the repository in the bucket (defaults to the prefix of the connected repository).").String()
+	lifecyclePolicyAbortIncompleteUploads = lifecyclePolicyCommand.Flag("abort-incomplete-uploads-days", "Abort incomplete multipart uploads after given number of days (0 disables).").Default("7").Int()
+	lifecyclePolicyTransitionDays         = lifecyclePolicyCommand.Flag("transition-packs-days", "Move data packs to another storage class after given number of days (0 disables).").Int()
+	lifecyclePolicyTransitionStorageClass = lifecyclePolicyCommand.Flag("transition-storage-class", "Storage class to move data packs to.").Default("STANDARD_IA").String()
+	lifecyclePolicyApply                  = lifecyclePolicyCommand.Flag("apply", "Apply lifecycle rules to the bucket of the connected repository instead of printing them.").Bool()
+)
+
+func runLifecyclePolicyCommand(ctx context.Context, rep repo.Repository) error {
+	p := &blob.LifecyclePolicy{
+		Prefix:                          *lifecyclePolicyPrefix,
+		AbortIncompleteUploadsAfterDays: *lifecyclePolicyAbortIncompleteUploads,
+		TransitionAfterDays:             *lifecyclePolicyTransitionDays,
+		TransitionStorageClass:          *lifecyclePolicyTransitionStorageClass,
+
+		// metadata packs and indexes are read by every client and maintenance, only data packs are moved.
+		TransitionBlobPrefix: content.PackBlobIDPrefixRegular,
+	}
+
+	provider := *lifecyclePolicyProvider
+
+	if dr, ok := rep.(*repo.DirectRepository); ok {
+		ci := dr.Blobs.ConnectionInfo()
+
+		if provider == "" {
+			provider = ci.Type
+		}
+
+		if o, ok := ci.Config.(*s3.Options); ok && p.Prefix == "" {
+			p.Prefix = o.Prefix
+		}
+
+		if *lifecyclePolicyApply {
+			return applyLifecyclePolicy(ctx, ci, p)
+		}
+	} else if *lifecyclePolicyApply {
+		return errors.Errorf("lifecycle policy can only be applied when connected directly to the repository")
+	}
+
+	if provider == "" {
+		return errors.Errorf("storage provider must be specified when not connected to a repository")
+	}
+
+	b, err := blob.GenerateLifecyclePolicy(provider, p)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate lifecycle policy")
+	}
+
+	printStdout("%s\n", b)
+
+	return nil
+}
+
+func applyLifecyclePolicy(ctx context.Context, ci blob.ConnectionInfo, p *blob.LifecyclePolicy) error {
+	// connect to the storage directly, since storage of the repository may be wrapped in caching or logging.
+	st, err := blob.NewStorage(ctx, ci)
+	if err != nil {
+		return errors.Wrap(err, "unable to open storage")
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	if err := blob.ApplyLifecyclePolicy(ctx, st, p); err != nil {
+		return errors.Wrap(err, "unable to apply lifecycle policy")
+	}
+
+	printStderr("Lifecycle policy applied.\n")
+
+	return nil
+}
+
+func init() {
+	lifecyclePolicyCommand.Action(optionalRepositoryAction(runLifecyclePolicyCommand))
+}
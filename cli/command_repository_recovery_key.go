@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var (
+	exportRecoveryKeyCommand = repositoryCommands.Command("export-recovery-key", "Exports encrypted recovery key containing repository connection info, format and master key, suitable for printing or encoding as a QR code.")
+
+	exportRecoverySharesCommand   = repositoryCommands.Command("export-recovery-shares", "Split the master key into recovery shares, any threshold of which can open the repository with --recovery-share when passwords are lost.")
+	exportRecoverySharesCount     = exportRecoverySharesCommand.Flag("shares", "Number of recovery shares.").Default("5").Int()
+	exportRecoverySharesThreshold = exportRecoverySharesCommand.Flag("threshold", "Number of recovery shares required to open the repository.").Default("3").Int()
+
+	recoveryPassphrase = app.Flag("recovery-passphrase", "Passphrase protecting the recovery key.").Envar("KOPIA_RECOVERY_PASSPHRASE").Hidden().String()
+)
+
+func runExportRecoveryKeyCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	passphrase, err := getRecoveryPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	k, err := rep.ExportRecoveryKey(passphrase)
+	if err != nil {
+		return errors.Wrap(err, "unable to export recovery key")
+	}
+
+	printStderr("Store the following recovery key in a safe place, it can be used with 'kopia repository connect from-config --recovery-key'\n")
+	printStdout("%v\n", k)
+
+	return nil
+}
+
+func runExportRecoverySharesCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	return printRecoveryShares(rep, *exportRecoverySharesCount, *exportRecoverySharesThreshold)
+}
+
+func printRecoveryShares(rep *repo.DirectRepository, n, threshold int) error {
+	shares, err := rep.ExportRecoveryShares(n, threshold)
+	if err != nil {
+		return errors.Wrap(err, "unable to export recovery shares")
+	}
+
+	printStderr("Give each of the following %v recovery shares to a different person, any %v of them can open the repository using 'kopia --recovery-share=SHARE ...' and reset its password with 'kopia repository change-password'.\n", n, threshold)
+
+	for _, s := range shares {
+		printStdout("%v\n", s)
+	}
+
+	return nil
+}
+
+func printRecoveryCode(code string) {
+	printStderr("Write down the following recovery code and keep it in a safe place, it can open the repository using 'kopia --recovery-code=CODE ...' and reset its password with 'kopia repository change-password'.\n")
+	printStdout("%v\n", code)
+}
+
+func getRecoveryPassphrase(isNew bool) (string, error) {
+	if *recoveryPassphrase != "" {
+		return *recoveryPassphrase, nil
+	}
+
+	if !isNew {
+		return askPass("Enter recovery key passphrase: ")
+	}
+
+	for {
+		p1, err := askPass("Enter passphrase to protect the recovery key: ")
+		if err != nil {
+			return "", errors.Wrap(err, "passphrase entry")
+		}
+
+		p2, err := askPass("Re-enter passphrase for verification: ")
+		if err != nil {
+			return "", errors.Wrap(err, "passphrase verification")
+		}
+
+		if p1 == p2 {
+			return p1, nil
+		}
+
+		fmt.Println("Passphrases don't match!")
+	}
+}
+
+func connectToStorageFromRecoveryKey(ctx context.Context, recoveryKey string) (blob.Storage, error) {
+	passphrase, err := getRecoveryPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := repo.DecryptRecoveryKey(recoveryKey, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := blob.NewStorage(ctx, k.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to storage")
+	}
+
+	_, err = st.GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	if err == nil {
+		return st, nil
+	}
+
+	if err != blob.ErrBlobNotFound {
+		st.Close(ctx) //nolint:errcheck
+		return nil, errors.Wrap(err, "unable to read format blob")
+	}
+
+	log(ctx).Infof("format blob not found, restoring it from recovery key...")
+
+	if err := k.RestoreFormatBlob(ctx, st); err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func init() {
+	exportRecoveryKeyCommand.Action(directRepositoryAction(runExportRecoveryKeyCommand))
+	exportRecoverySharesCommand.Action(directRepositoryAction(runExportRecoverySharesCommand))
+}
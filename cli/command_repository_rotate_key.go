@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+var (
+	rotateKeyCommand    = repositoryCommands.Command("rotate-key", "Start encrypting new contents with a new random key, optionally using a different encryption algorithm.")
+	rotateKeyEncryption = rotateKeyCommand.Flag("encryption", "Content encryption algorithm for the new key (defaults to the current algorithm).").PlaceHolder("ALGO").Enum(encryption.SupportedAlgorithms(false)...)
+)
+
+func runRotateKeyCommand(ctx context.Context, rep *repo.DirectRepository) error {
+	epoch, err := rep.RotateEncryptionKey(ctx, *rotateKeyEncryption)
+	if err != nil {
+		return errors.Wrap(err, "unable to rotate encryption key")
+	}
+
+	printStderr("Rotated encryption key, new contents will be encrypted using key epoch %v.\n", epoch)
+	printStderr("Existing contents will be re-encrypted by full maintenance, to re-encrypt them now use 'kopia content rewrite --old-key-epochs'.\n")
+	printStderr("NOTE: Other clients that keep the repository open, such as 'kopia server', must be restarted to read contents encrypted with the new key.\n")
+	printStderr("NOTE: The repository can no longer be opened by versions of kopia that don't support key rotation.\n")
+
+	return nil
+}
+
+func init() {
+	rotateKeyCommand.Action(directRepositoryAction(runRotateKeyCommand))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/kopia/kopia/internal/scrubber"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/encryption"
 )
 
 var (
@@ -37,11 +38,30 @@ func runStatusCommand(ctx context.Context, rep *repo.DirectRepository) error {
 	fmt.Printf("Username:            %v\n", rep.Username())
 	fmt.Println()
 	fmt.Printf("Hash:                %v\n", rep.Content.Format.Hash)
-	fmt.Printf("Encryption:          %v\n", rep.Content.Format.Encryption)
+	fmt.Printf("Encryption:          %v\n", rep.Content.Format.CurrentEncryption())
+
+	if epoch := rep.Content.Format.CurrentKeyEpoch(); epoch > 0 {
+		fmt.Printf("Key epoch:           %v\n", epoch)
+	}
+
+	fmt.Printf("Format encryption:   %v\n", rep.FormatEncryption())
+	fmt.Printf("Key derivation:      %v\n", rep.KeyDerivationAlgorithm())
 	fmt.Printf("Splitter:            %v\n", rep.Objects.Format.Splitter)
+
+	if f := rep.Objects.Format; f.AvgChunkSize != 0 {
+		fmt.Printf("Chunk sizes:         min %v, avg %v, max %v\n",
+			units.BytesStringBase2(int64(f.MinChunkSize)),
+			units.BytesStringBase2(int64(f.AvgChunkSize)),
+			units.BytesStringBase2(int64(f.MaxChunkSize)))
+	}
+
 	fmt.Printf("Format version:      %v\n", rep.Content.Format.Version)
 	fmt.Printf("Max pack length:     %v\n", units.BytesStringBase2(int64(rep.Content.Format.MaxPackSize)))
 
+	if enc := rep.Content.Format.CurrentEncryption(); encryption.IsDeprecated(enc) {
+		fmt.Printf("\nNOTICE: Encryption algorithm %v is deprecated, to use authenticated encryption rotate the encryption key using 'kopia repository rotate-key --encryption=%v'.\n", enc, encryption.DefaultAlgorithm)
+	}
+
 	if *statusReconnectToken {
 		pass := ""
 
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var validateConnectionCommand = repositoryCommands.Command("validate-connection", "Validate that storage can be used for a repository by writing, listing, reading and deleting a probe blob.")
+
+func runValidateConnectionCommandWithStorage(ctx context.Context, st blob.Storage) error {
+	defer st.Close(ctx) //nolint:errcheck
+
+	printStderr("Validating storage connection...\n")
+
+	res, err := blob.ValidateStorage(ctx, st, nil)
+	if err != nil {
+		return errors.Wrap(err, "storage validation failed")
+	}
+
+	printStdout("Write:        %v\n", res.PutLatency)
+	printStdout("Get metadata: %v\n", res.GetMetadataLatency)
+	printStdout("List:         %v\n", res.ListLatency)
+	printStdout("Read:         %v\n", res.GetLatency)
+	printStdout("Delete:       %v\n", res.DeleteLatency)
+	printStdout("Clock skew:   %v\n", res.ClockSkew.Round(time.Second))
+
+	if res.ClockSkew > blob.MaxClockSkew || res.ClockSkew < -blob.MaxClockSkew {
+		return errors.Errorf("clock skew between local machine and storage exceeds %v, synchronize local clock", blob.MaxClockSkew)
+	}
+
+	printStderr("Storage connection is valid.\n")
+
+	return nil
+}
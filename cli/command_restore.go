@@ -2,11 +2,20 @@ package cli
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
@@ -45,19 +54,202 @@ var (
 
 	restoreOverwriteDirectories = true
 	restoreOverwriteFiles       = true
+	restoreScanCommand          string
+	restoreRehydrate            bool
+	restoreRehydratePoll        time.Duration
+	restorePlan                 bool
+	restoreConfirmAboveMB       int64
+	restoreYes                  bool
+	restoreCheckFreeSpace       bool
+	restoreSkipXattrs           bool
 )
 
+// scanCommandRejectExitCode is the exit code of the scan command which indicates that the file should not be restored,
+// which matches the convention used by virus scanners, such as clamscan.
+const scanCommandRejectExitCode = 1
+
 func addRestoreFlags(cmd *kingpin.CmdClause) {
 	cmd.Flag("overwrite-directories", "Overwrite existing directories").BoolVar(&restoreOverwriteDirectories)
 	cmd.Flag("overwrite-files", "Specifies whether or not to overwrite already existing files").
 		BoolVar(&restoreOverwriteFiles)
+	cmd.Flag("scan-command", "Command (with shell-style quoting) invoked with the path of each file before it is restored, exit code 1 prevents the file from being restored").
+		StringVar(&restoreScanCommand)
+	cmd.Flag("rehydrate", "Rehydrate data archived in cold storage tier and wait until it's readable before restoring").BoolVar(&restoreRehydrate)
+	cmd.Flag("rehydrate-poll-interval", "How often to check whether archived data has been rehydrated").Default("5m").DurationVar(&restoreRehydratePoll)
+	cmd.Flag("plan", "Display the amount of data to be downloaded and estimated time before restoring, which requires reading all directories first").BoolVar(&restorePlan)
+	cmd.Flag("confirm-above-mb", "Ask for confirmation before restoring with --plan when more than the provided amount of data needs to be downloaded").Default("10240").Int64Var(&restoreConfirmAboveMB)
+	cmd.Flag("yes", "Do not ask for confirmation before restoring").Short('y').BoolVar(&restoreYes)
+	cmd.Flag("check-free-space", "Check that the target has enough free space for restored files before restoring").Default("true").BoolVar(&restoreCheckFreeSpace)
+	cmd.Flag("skip-extended-attributes", "Do not restore extended attributes of files and directories").BoolVar(&restoreSkipXattrs)
+}
+
+// maybeShowRestorePlan displays the plan of restoring the provided entry and asks for confirmation
+// if it requires downloading a lot of data. Returns false if the restore should not proceed.
+func maybeShowRestorePlan(ctx context.Context, rep repo.Repository, root fs.Entry) (bool, error) {
+	if !restorePlan {
+		return true, nil
+	}
+
+	dr, ok := rep.(*repo.DirectRepository)
+	if !ok {
+		// restore plan requires information about contents, which is not available over API server.
+		return true, nil
+	}
+
+	plan, err := snapshotfs.PlanRestore(ctx, dr, root)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to compute restore plan")
+	}
+
+	printStderr("Restoring %v files (%v) in %v directories.\n", plan.Files, units.BytesStringBase10(plan.TotalBytes), plan.Directories)
+	printStderr("Downloading %v from %v contents in %v pack blobs", units.BytesStringBase10(plan.DownloadBytes), plan.Contents, plan.PackBlobs)
+
+	if eta := plan.EstimatedDuration(); eta > 0 {
+		printStderr(", estimated time %v at %v/s", eta.Round(time.Second), units.BytesStringBase10(int64(plan.Throughput)))
+	}
+
+	printStderr(".\n")
+
+	if restoreYes || plan.DownloadBytes <= restoreConfirmAboveMB<<20 {
+		return true, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Proceed with restore? (y/N) ")
+
+	var answer string
+
+	fmt.Scanf("%v", &answer) //nolint:errcheck
+
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// maybeRehydrate rehydrates data needed to restore the provided directory, if requested.
+func maybeRehydrate(ctx context.Context, rep repo.Repository, oid object.ID) error {
+	if !restoreRehydrate {
+		return nil
+	}
+
+	dr, ok := rep.(*repo.DirectRepository)
+	if !ok {
+		return errors.New("rehydration requires direct repository connection")
+	}
+
+	printStderr("Rehydrating archived data, this may take several hours...\n")
+
+	return snapshotfs.RehydrateRoot(ctx, dr, oid, restoreRehydratePoll, func(ready, total int) {
+		printStderr("%v out of %v pack blobs are readable\n", ready, total)
+	})
+}
+
+func restoreOptions() (localfs.CopyOptions, error) {
+	opt := localfs.CopyOptions{
+		OverwriteDirectories:   restoreOverwriteDirectories,
+		OverwriteFiles:         restoreOverwriteFiles,
+		SkipExtendedAttributes: restoreSkipXattrs,
+	}
+
+	if restoreScanCommand != "" {
+		scan, err := commandFileScanner(restoreScanCommand)
+		if err != nil {
+			return opt, err
+		}
+
+		opt.ScanFile = scan
+	}
+
+	return opt, nil
+}
+
+// commandFileScanner returns localfs.FileScanner which invokes the provided command with the path
+// of the temporary file holding file contents appended to its arguments.
+func commandFileScanner(cmdLine string) (localfs.FileScanner, error) {
+	parts, err := splitCommandLine(cmdLine)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid scan command")
+	}
+
+	if len(parts) == 0 {
+		return nil, errors.New("empty scan command")
+	}
+
+	return func(ctx context.Context, targetPath, contentPath string) (bool, error) {
+		args := append(append([]string(nil), parts[1:]...), contentPath)
+
+		cmd := exec.CommandContext(ctx, parts[0], args...) // nolint:gosec
+		cmd.Env = append(os.Environ(), "KOPIA_RESTORE_TARGET_PATH="+targetPath)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+
+		err := cmd.Run()
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == scanCommandRejectExitCode {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, errors.Wrap(err, "scan command failed")
+		}
+
+		return true, nil
+	}, nil
 }
 
-func restoreOptions() localfs.CopyOptions {
-	return localfs.CopyOptions{
-		OverwriteDirectories: restoreOverwriteDirectories,
-		OverwriteFiles:       restoreOverwriteFiles,
+// splitCommandLine splits the command line into arguments separated by whitespace, honoring
+// single and double quotes and backslash escapes like a POSIX shell, without performing any expansion.
+func splitCommandLine(cmdLine string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, ch := range cmdLine {
+		switch {
+		case escaped:
+			current.WriteRune(ch)
+
+			escaped = false
+
+		case ch == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				current.WriteRune(ch)
+			}
+
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+
+				inArg = false
+			}
+
+		default:
+			current.WriteRune(ch)
+
+			inArg = true
+		}
+	}
+
+	if escaped || quote != 0 {
+		return nil, errors.Errorf("unterminated quote or escape in %q", cmdLine)
+	}
+
+	if inArg {
+		args = append(args, current.String())
 	}
+
+	return args, nil
 }
 
 func runRestoreCommand(ctx context.Context, rep repo.Repository) error {
@@ -66,7 +258,37 @@ func runRestoreCommand(ctx context.Context, rep repo.Repository) error {
 		return err
 	}
 
-	return snapshotfs.RestoreRoot(ctx, rep, *restoreCommandTargetPath, oid, restoreOptions())
+	if err := maybeRehydrate(ctx, rep, oid); err != nil {
+		return err
+	}
+
+	// the summary provides the size of the directory, which is otherwise only known for snapshot roots.
+	summary, err := snapshotfs.ReadDirectorySummary(ctx, rep, oid)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %v", oid)
+	}
+
+	root := snapshotfs.DirectoryEntry(rep, oid, summary)
+
+	if err := ensureRestoreFreeSpace(ctx, root, *restoreCommandTargetPath); err != nil {
+		return err
+	}
+
+	proceed, err := maybeShowRestorePlan(ctx, rep, root)
+	if err != nil {
+		return err
+	}
+
+	if !proceed {
+		return errors.New("restore canceled")
+	}
+
+	opt, err := restoreOptions()
+	if err != nil {
+		return err
+	}
+
+	return snapshotfs.RestoreRoot(ctx, rep, *restoreCommandTargetPath, oid, opt)
 }
 
 func init() {
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "clamscan", want: []string{"clamscan"}},
+		{input: "clamscan  --quiet\t--no-summary", want: []string{"clamscan", "--quiet", "--no-summary"}},
+		{input: `"/opt/my scanner/scan" --db '/var/lib/scan db'`, want: []string{"/opt/my scanner/scan", "--db", "/var/lib/scan db"}},
+		{input: `scan a\ b "say \"hi\"" 'it''s'`, want: []string{"scan", "a b", `say "hi"`, "its"}},
+		{input: `scan ""`, want: []string{"scan", ""}},
+		{input: "  ", want: nil},
+		{input: `scan "unterminated`, wantErr: true},
+		{input: `scan trailing\`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := splitCommandLine(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %q: %v", tc.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("invalid result for %q: %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+var (
+	serverShareCommand    = serverCommands.Command("share", "Creates expiring public link for downloading a single file from a snapshot")
+	serverShareObjectID   = serverShareCommand.Arg("object-id", "Object ID of the file to share").Required().String()
+	serverShareFileName   = serverShareCommand.Flag("file-name", "File name presented to the recipient").String()
+	serverShareExpiration = serverShareCommand.Flag("expiration", "Link expiration").Default("24h").Duration()
+)
+
+func init() {
+	serverShareCommand.Action(serverAction(runServerShare))
+}
+
+func runServerShare(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	resp, err := serverapi.CreateShareLink(ctx, cli, &serverapi.CreateShareLinkRequest{
+		ObjectID:          *serverShareObjectID,
+		FileName:          *serverShareFileName,
+		ExpirationSeconds: int(serverShareExpiration.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	printStderr("Link expires at %v\n", formatTimestamp(resp.Expires))
+	printStdout("%v%v\n", strings.TrimSuffix(*serverAddress, "/"), resp.Path)
+
+	return nil
+}
@@ -31,6 +31,7 @@ var (
 	serverStartHTMLPath        = serverStartCommand.Flag("html", "Server the provided HTML at the root URL").ExistingDir()
 	serverStartUI              = serverStartCommand.Flag("ui", "Start the server with HTML UI").Default("true").Bool()
 	serverStartRefreshInterval = serverStartCommand.Flag("refresh-interval", "Frequency for refreshing repository status").Default("10s").Duration()
+	serverStartRequestTimeout  = serverStartCommand.Flag("request-timeout", "Deadline for handling API requests (0 - no deadline)").Duration()
 
 	serverStartRandomPassword = serverStartCommand.Flag("random-password", "Generate random password and print to stderr").Hidden().Bool()
 	serverStartAutoShutdown   = serverStartCommand.Flag("auto-shutdown", "Auto shutdown the server if API requests not received within given time").Hidden().Duration()
@@ -47,6 +48,7 @@ func runServer(ctx context.Context, rep repo.Repository) error {
 		ConfigFile:      repositoryConfigFileName(),
 		ConnectOptions:  connectOptions(),
 		RefreshInterval: *serverStartRefreshInterval,
+		RequestTimeout:  *serverStartRequestTimeout,
 	})
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize server")
@@ -83,6 +85,9 @@ func runServer(ctx context.Context, rep repo.Repository) error {
 		return errors.Wrap(err, "unable to setup credentials")
 	}
 
+	// share links carry their own signature and must be accessible without credentials.
+	mux.Handle("/share/", srv.ShareLinkHandlers())
+
 	// init prometheus after adding interceptors that require credentials, so that this
 	// handler can be called without auth
 	if err = initPrometheus(mux); err != nil {
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"os"
 	"path/filepath"
@@ -9,7 +10,15 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/fs/selectfs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/internal/vss"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -18,6 +27,7 @@ import (
 const (
 	maxSnapshotDescriptionLength = 1024
 	timeFormat                   = "2006-01-02 15:04:05 MST"
+	shadowCopyDeleteTimeout      = time.Minute
 )
 
 var (
@@ -27,16 +37,38 @@ var (
 	snapshotCreateAll                     = snapshotCreateCommand.Flag("all", "Create snapshots for files or directories previously backed up by this user on this computer").Bool()
 	snapshotCreateCheckpointUploadLimitMB = snapshotCreateCommand.Flag("upload-limit-mb", "Stop the backup process after the specified amount of data (in MB) has been uploaded.").PlaceHolder("MB").Default("0").Int64()
 	snapshotCreateCheckpointInterval      = snapshotCreateCommand.Flag("checkpoint-interval", "Frequency for creating periodic checkpoint.").Duration()
+	snapshotCreateDeferLargeFilesMB       = snapshotCreateCommand.Flag("defer-large-files-mb", "Upload files of at least the specified size (in MB) after checkpointing all other files and directories.").PlaceHolder("MB").Default("0").Int64()
 	snapshotCreateDescription             = snapshotCreateCommand.Flag("description", "Free-form snapshot description.").String()
 	snapshotCreateForceHash               = snapshotCreateCommand.Flag("force-hash", "Force hashing of source files for a given percentage of files [0..100]").Default("0").Int()
 	snapshotCreateParallelUploads         = snapshotCreateCommand.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").Int()
 	snapshotCreateStartTime               = snapshotCreateCommand.Flag("start-time", "Override snapshot start timestamp.").String()
 	snapshotCreateEndTime                 = snapshotCreateCommand.Flag("end-time", "Override snapshot end timestamp.").String()
+	snapshotCreateFilesFrom               = snapshotCreateCommand.Flag("files-from", "Snapshot only the paths listed in the file (one per line, relative to the source directory).").PlaceHolder("FILE").ExistingFile()
+	snapshotCreateParent                  = snapshotCreateCommand.Flag("parent", "ID of the previous snapshot to use as the parent instead of the latest one, such as an older baseline after a rollback.").PlaceHolder("ID").String()
+	snapshotCreateClassify                = snapshotCreateCommand.Flag("classify", "Store breakdown of files by category and extension in snapshot statistics.").Bool()
+	snapshotCreateMaxUploadSpeed          = snapshotCreateCommand.Flag("max-upload-speed", "Maximum rate at which data is written to the storage in bytes per second, overrides the policy").PlaceHolder("N").Int64()
+	snapshotCreateProgressFormat          = snapshotCreateCommand.Flag("progress-format", "Format of progress output, 'jsonl' additionally emits one JSON event per processed file or directory").Default("text").Enum("text", "jsonl")
+	snapshotCreateProgressFile            = snapshotCreateCommand.Flag("progress-file", "Write JSONL progress events to the provided file instead of stdout").String()
+	snapshotCreateResume                  = snapshotCreateCommand.Flag("resume", "Resume interrupted snapshots by reusing directories completed in their checkpoints, if none of their entries changed, without reading files again.").Bool()
+	snapshotCreateStdinName               = snapshotCreateCommand.Flag("stdin-name", "Create a single-file snapshot of standard input stored under the provided file name, the snapshot source is the file name in the current directory.").PlaceHolder("NAME").String()
+	snapshotCreateShadowCopy              = snapshotCreateCommand.Flag("shadow-copy", "Snapshot from a Volume Shadow Copy of the source volume to consistently capture open and locked files (Windows only, requires administrator privileges).").Bool()
 )
 
 func runSnapshotCommand(ctx context.Context, rep repo.Repository) error {
 	sources := *snapshotCreateSources
 
+	if *snapshotCreateStdinName != "" {
+		if len(sources) > 0 || *snapshotCreateAll || *snapshotCreateFilesFrom != "" {
+			return errors.New("--stdin-name can't be combined with other snapshot sources")
+		}
+
+		if *snapshotCreateShadowCopy {
+			return errors.New("--stdin-name can't be combined with --shadow-copy")
+		}
+
+		sources = []string{*snapshotCreateStdinName}
+	}
+
 	if *snapshotCreateAll {
 		local, err := getLocalBackupPaths(ctx, rep)
 		if err != nil {
@@ -50,6 +82,14 @@ func runSnapshotCommand(ctx context.Context, rep repo.Repository) error {
 		return errors.New("no snapshot sources")
 	}
 
+	if *snapshotCreateFilesFrom != "" && len(sources) != 1 {
+		return errors.New("--files-from requires exactly one source directory")
+	}
+
+	if *snapshotCreateParent != "" && len(sources) != 1 {
+		return errors.New("--parent requires exactly one source directory")
+	}
+
 	if err := validateStartEndTime(*snapshotCreateStartTime, *snapshotCreateEndTime); err != nil {
 		return err
 	}
@@ -58,8 +98,21 @@ func runSnapshotCommand(ctx context.Context, rep repo.Repository) error {
 		return errors.New("description too long")
 	}
 
+	if err := ensureFreeCacheSpace(rep); err != nil {
+		return err
+	}
+
 	u := setupUploader(rep)
 
+	if *snapshotCreateProgressFormat == "jsonl" {
+		closeProgress, err := setupJSONLProgress(u)
+		if err != nil {
+			return err
+		}
+
+		defer closeProgress()
+	}
+
 	var finalErrors []string
 
 	for _, snapshotDir := range sources {
@@ -112,6 +165,7 @@ func validateStartEndTime(st, et string) error {
 func setupUploader(rep repo.Repository) *snapshotfs.Uploader {
 	u := snapshotfs.NewUploader(rep)
 	u.MaxUploadBytes = *snapshotCreateCheckpointUploadLimitMB << 20 //nolint:gomnd
+	u.DeferredFileSize = *snapshotCreateDeferLargeFilesMB << 20     //nolint:gomnd
 
 	if interval := *snapshotCreateCheckpointInterval; interval != 0 {
 		u.CheckpointInterval = interval
@@ -119,6 +173,9 @@ func setupUploader(rep repo.Repository) *snapshotfs.Uploader {
 
 	u.ForceHashPercentage = *snapshotCreateForceHash
 	u.ParallelUploads = *snapshotCreateParallelUploads
+	u.ClassifyFiles = *snapshotCreateClassify
+	u.ResumeCompletedDirectories = *snapshotCreateResume
+	u.MaxUploadBytesPerSecond = *snapshotCreateMaxUploadSpeed
 	onCtrlC(u.Cancel)
 
 	u.Progress = progress
@@ -126,6 +183,26 @@ func setupUploader(rep repo.Repository) *snapshotfs.Uploader {
 	return u
 }
 
+// setupJSONLProgress makes the uploader emit JSONL progress events to stdout or the progress file
+// and returns the function closing the output.
+func setupJSONLProgress(u *snapshotfs.Uploader) (func(), error) {
+	if *snapshotCreateProgressFile == "" {
+		u.Progress = newJSONLProgress(u.Progress, os.Stdout)
+		return func() {}, nil
+	}
+
+	f, err := os.Create(*snapshotCreateProgressFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create progress file")
+	}
+
+	u.Progress = newJSONLProgress(u.Progress, f)
+
+	return func() {
+		f.Close() //nolint:errcheck
+	}, nil
+}
+
 func parseTimestamp(timestamp string) (time.Time, error) {
 	if timestamp == "" {
 		return time.Time{}, nil
@@ -140,29 +217,53 @@ func startTimeAfterEndTime(startTime, endTime time.Time) bool {
 		startTime.After(endTime)
 }
 
-func snapshotSingleSource(ctx context.Context, rep repo.Repository, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo) error {
+func snapshotSingleSource(ctx context.Context, rep repo.Repository, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo) (rerr error) {
 	printStderr("Snapshotting %v ...\n", sourceInfo)
 
 	t0 := time.Now()
 
-	localEntry, err := getLocalFSEntry(ctx, sourceInfo.Path)
+	policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
 	if err != nil {
-		return errors.Wrap(err, "unable to get local filesystem entry")
+		return errors.Wrap(err, "unable to get policy tree")
 	}
 
-	previous, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo, nil)
+	actions, err := snapshotfs.BeginSnapshotRoot(ctx, policyTree.EffectivePolicy().ActionsPolicy, sourceInfo, rep.ActionsEnabled())
 	if err != nil {
 		return err
 	}
 
-	policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
+	defer func() {
+		if aerr := actions.End(ctx); aerr != nil && rerr == nil {
+			rerr = aerr
+		}
+	}()
+
+	localEntry, release, err := snapshotSourceEntry(ctx, actions.SnapshotPath)
 	if err != nil {
-		return errors.Wrap(err, "unable to get policy tree")
+		return errors.Wrap(err, "unable to get local filesystem entry")
+	}
+
+	defer release()
+
+	if *snapshotCreateFilesFrom != "" {
+		localEntry, err = selectFilesFrom(localEntry, sourceInfo.Path, *snapshotCreateFilesFrom)
+		if err != nil {
+			return err
+		}
+	}
+
+	previous, err := findParentSnapshotManifests(ctx, rep, sourceInfo)
+	if err != nil {
+		return err
 	}
 
 	log(ctx).Debugf("uploading %v using %v previous manifests", sourceInfo, len(previous))
 
 	manifest, err := u.Upload(ctx, localEntry, policyTree, sourceInfo, previous...)
+	if errors.Is(err, blob.ErrQuotaExceeded) {
+		return errors.Wrap(err, "snapshot not created, run maintenance to reclaim space or reconnect with larger --storage-quota-mb")
+	}
+
 	if err != nil {
 		return err
 	}
@@ -190,6 +291,12 @@ func snapshotSingleSource(ctx context.Context, rep repo.Repository, u *snapshotf
 		manifest.EndTime = endTimeOverride
 	}
 
+	if err = actions.End(ctx); err != nil {
+		return err
+	}
+
+	manifest.ActionOutput = actions.Output()
+
 	snapID, err := snapshot.SaveSnapshot(ctx, rep, manifest)
 	if err != nil {
 		return errors.Wrap(err, "cannot save manifest")
@@ -213,14 +320,153 @@ func snapshotSingleSource(ctx context.Context, rep repo.Repository, u *snapshotf
 	if ds := manifest.RootEntry.DirSummary; ds != nil {
 		if ds.NumFailed > 0 {
 			errorColor.Fprintf(os.Stderr, "\nIgnored %v errors while snapshotting.", ds.NumFailed) //nolint:errcheck
+
+			for _, fe := range ds.FailedEntries {
+				errorColor.Fprintf(os.Stderr, "\n  %v: %v", fe.EntryPath, fe.Error) //nolint:errcheck
+			}
 		}
 	}
 
+	if n := manifest.Stats.UnstableFiles; n > 0 {
+		errorColor.Fprintf(os.Stderr, "\n%v files changed while being snapshotted, their contents may be inconsistent.", n) //nolint:errcheck
+	}
+
+	if saved, ratio := manifest.Stats.CompressionSavings(); saved > 0 {
+		printStderr("\nCompression saved %v (%.1f%%) of uploaded file contents.", units.BytesStringBase10(saved), ratio*100) //nolint:gomnd
+	}
+
 	printStderr("\nCreated%v snapshot with root %v and ID %v in %v\n", maybePartial, manifest.RootObjectID(), snapID, time.Since(t0).Truncate(time.Second))
 
 	return err
 }
 
+// snapshotSourceEntry returns the entry to snapshot for the provided source path, which is either
+// the local file system entry, its shadow copy when --shadow-copy is used or standard input when --stdin-name is used.
+// The returned function releases resources associated with the entry after the snapshot completes.
+func snapshotSourceEntry(ctx context.Context, sourcePath string) (fs.Entry, func(), error) {
+	if *snapshotCreateStdinName != "" {
+		return virtualfs.StreamingFileFromReader(filepath.Base(sourcePath), os.Stdin, time.Now()), func() {}, nil
+	}
+
+	if *snapshotCreateShadowCopy {
+		return shadowCopyEntry(ctx, sourcePath)
+	}
+
+	e, err := getLocalFSEntry(ctx, sourcePath)
+
+	return e, func() {}, err
+}
+
+// shadowCopyEntry creates a shadow copy of the volume containing the provided path and returns the entry
+// corresponding to the path inside the shadow copy. The shadow copy is deleted by the returned function.
+func shadowCopyEntry(ctx context.Context, sourcePath string) (fs.Entry, func(), error) {
+	path, err := filepath.EvalSymlinks(sourcePath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "evaluate symlink")
+	}
+
+	sc, err := vss.Create(ctx, filepath.VolumeName(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	printStderr("Created shadow copy %v of %v\n", sc.ID, sc.Volume)
+
+	release := func() {
+		// the shadow copy must be deleted even if the snapshot was canceled.
+		dctx, cancel := context.WithTimeout(context.Background(), shadowCopyDeleteTimeout)
+		defer cancel()
+
+		if derr := sc.Delete(dctx); derr != nil {
+			log(ctx).Warningf("unable to delete shadow copy: %v", derr)
+		}
+	}
+
+	shadowPath, err := sc.Path(path)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	log(ctx).Debugf("snapshotting %v from shadow copy %v at %v", path, sc.ID, shadowPath)
+
+	e, err := localfs.NewEntry(shadowPath)
+	if err != nil {
+		release()
+		return nil, nil, errors.Wrap(err, "can't get shadow copy entry")
+	}
+
+	return e, release, nil
+}
+
+// selectFilesFrom restricts the provided directory to paths listed in the given file.
+// Empty lines and lines starting with '#' are ignored.
+func selectFilesFrom(e fs.Entry, rootPath, listFile string) (fs.Entry, error) {
+	dir, ok := e.(fs.Directory)
+	if !ok {
+		return nil, errors.Errorf("--files-from requires source to be a directory: %v", rootPath)
+	}
+
+	f, err := os.Open(listFile) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open list of files")
+	}
+	defer f.Close() //nolint:errcheck
+
+	var paths []string
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := line
+		if filepath.IsAbs(p) {
+			if p, err = filepath.Rel(rootPath, p); err != nil {
+				return nil, errors.Wrapf(err, "invalid path %q", line)
+			}
+		}
+
+		if _, err := os.Lstat(filepath.Join(rootPath, p)); err != nil {
+			return nil, errors.Wrapf(err, "invalid path %q", line)
+		}
+
+		paths = append(paths, filepath.ToSlash(p))
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to read list of files")
+	}
+
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no paths listed in %v", listFile)
+	}
+
+	return selectfs.New(dir, paths)
+}
+
+// findParentSnapshotManifests returns the snapshot selected with --parent or the previous snapshots of the source.
+func findParentSnapshotManifests(ctx context.Context, rep repo.Repository, sourceInfo snapshot.SourceInfo) ([]*snapshot.Manifest, error) {
+	if *snapshotCreateParent == "" {
+		return findPreviousSnapshotManifest(ctx, rep, sourceInfo, nil)
+	}
+
+	parent, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(*snapshotCreateParent))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load parent snapshot %v", *snapshotCreateParent)
+	}
+
+	if parent.Source != sourceInfo {
+		log(ctx).Warningf("parent snapshot %v is a snapshot of a different source %v", *snapshotCreateParent, parent.Source)
+	}
+
+	log(ctx).Debugf("using parent snapshot %v from %v", *snapshotCreateParent, parent.StartTime)
+
+	return []*snapshot.Manifest{parent}, nil
+}
+
 // findPreviousSnapshotManifest returns the list of previous snapshots for a given source, including
 // last complete snapshot and possibly some number of incomplete snapshots following it.
 func findPreviousSnapshotManifest(ctx context.Context, rep repo.Repository, sourceInfo snapshot.SourceInfo, noLaterThan *time.Time) ([]*snapshot.Manifest, error) {
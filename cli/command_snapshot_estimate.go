@@ -3,17 +3,18 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
-	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 var (
@@ -76,53 +77,63 @@ func runSnapshotEstimateCommand(ctx context.Context, rep repo.Repository) error
 		UserName: rep.Username(),
 	}
 
-	var stats snapshot.Stats
-
 	ib := makeBuckets()
 	eb := makeBuckets()
 
-	onIgnoredFile := func(relativePath string, e fs.Entry) {
-		log(ctx).Infof("ignoring %v", relativePath)
-		eb.add(relativePath, e.Size())
-
-		if e.IsDir() {
-			stats.ExcludedDirCount++
-		} else {
-			stats.ExcludedFileCount++
-			stats.ExcludedTotalFileSize += e.Size()
-		}
-	}
-
 	entry, err := getLocalFSEntry(ctx, path)
 	if err != nil {
 		return err
 	}
 
-	if dir, ok := entry.(fs.Directory); ok {
-		policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
-		if err != nil {
-			return err
-		}
+	policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
+	if err != nil {
+		return err
+	}
 
-		entry = ignorefs.New(dir, policyTree, ignorefs.ReportIgnoredFiles(onIgnoredFile))
+	previous, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo, nil)
+	if err != nil {
+		return err
 	}
 
-	if err := estimate(ctx, ".", entry, &stats, ib); err != nil {
+	est, err := snapshotfs.Estimate(ctx, rep, entry, policyTree, sourceInfo, previous,
+		snapshotfs.EstimateReportDirectory(func(relativePath string) {
+			if !*snapshotEstimateQuiet {
+				printStderr("Scanning %v\n", relativePath)
+			}
+		}),
+		snapshotfs.EstimateReportFile(func(relativePath string, e fs.File, cached bool) {
+			ib.add(relativePath, e.Size())
+		}),
+		snapshotfs.EstimateReportIgnored(func(relativePath string, e fs.Entry) {
+			log(ctx).Infof("ignoring %v", relativePath)
+			eb.add(relativePath, e.Size())
+		}))
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Snapshot includes %v files, total size %v\n", stats.TotalFileCount, units.BytesStringBase10(stats.TotalFileSize))
+	fmt.Printf("Snapshot includes %v files, total size %v\n", est.Files, units.BytesStringBase10(est.TotalBytes))
 	showBuckets(ib)
 	fmt.Println()
 
-	fmt.Printf("Snapshot excludes %v directories and %v files with total size %v\n", stats.ExcludedDirCount, stats.ExcludedFileCount, units.BytesStringBase10(stats.ExcludedTotalFileSize))
+	fmt.Printf("Snapshot excludes %v directories and %v files with total size %v\n", est.ExcludedDirs, est.ExcludedFiles, units.BytesStringBase10(est.ExcludedBytes))
 	showBuckets(eb)
+	fmt.Println()
+
+	if len(previous) > 0 {
+		fmt.Printf("Unchanged since previous snapshot: %v files, total size %v\n", est.CachedFiles, units.BytesStringBase10(est.CachedBytes))
+	}
 
-	megabits := float64(stats.TotalFileSize) * 8 / 1000000 //nolint:gomnd
-	seconds := megabits / *snapshotEstimateUploadSpeed
+	fmt.Printf("Files to read and hash: %v files, total size %v (upper bound of uploaded data)\n", est.ReadFiles, units.BytesStringBase10(est.ReadBytes))
+
+	if est.Errors > 0 {
+		errorColor.Fprintf(os.Stderr, "Unable to read %v directories, the estimate is incomplete.\n", est.Errors) //nolint:errcheck
+	}
+
+	bytesPerSecond := *snapshotEstimateUploadSpeed * 1000000 / 8 //nolint:gomnd
 
 	fmt.Println()
-	fmt.Printf("Estimated upload time: %v at %v Mbit/s\n", time.Duration(seconds)*time.Second, *snapshotEstimateUploadSpeed)
+	fmt.Printf("Estimated upload time: %v at %v Mbit/s\n", est.ReadDuration(int64(bytesPerSecond)).Round(time.Second), *snapshotEstimateUploadSpeed)
 
 	return nil
 }
@@ -143,33 +154,6 @@ func showBuckets(b buckets) {
 	}
 }
 
-func estimate(ctx context.Context, relativePath string, entry fs.Entry, stats *snapshot.Stats, ib buckets) error {
-	switch entry := entry.(type) {
-	case fs.Directory:
-		if !*snapshotEstimateQuiet {
-			printStderr("Scanning %v\n", relativePath)
-		}
-
-		children, err := entry.Readdir(ctx)
-		if err != nil {
-			return err
-		}
-
-		for _, child := range children {
-			if err := estimate(ctx, filepath.Join(relativePath, child.Name()), child, stats, ib); err != nil {
-				return err
-			}
-		}
-
-	case fs.File:
-		ib.add(relativePath, entry.Size())
-		stats.TotalFileCount++
-		stats.TotalFileSize += entry.Size()
-	}
-
-	return nil
-}
-
 func init() {
 	snapshotEstimate.Action(repositoryAction(runSnapshotEstimateCommand))
 }
@@ -32,6 +32,10 @@ var (
 	shapshotListShowOwner            = snapshotListCommand.Flag("owner", "Include owner").Bool()
 	snapshotListShowIdentical        = snapshotListCommand.Flag("show-identical", "Show identical snapshots").Short('l').Bool()
 	snapshotListShowAll              = snapshotListCommand.Flag("all", "Show all shapshots (not just current username/host)").Short('a').Bool()
+	snapshotListShowClassification   = snapshotListCommand.Flag("classification", "Include breakdown of files by category, if computed during snapshot").Bool()
+	snapshotListShowActionOutput     = snapshotListCommand.Flag("action-output", "Include output of actions run before and after the snapshot").Bool()
+	snapshotListShowUploadStats      = snapshotListCommand.Flag("upload-stats", "Include number of files and bytes cached, hashed and written to the repository by the snapshot").Bool()
+	snapshotListShowCompression      = snapshotListCommand.Flag("compression", "Include space saved by compressing file contents uploaded by the snapshot").Bool()
 	maxResultsPerPath                = snapshotListCommand.Flag("max-results", "Maximum number of entries per source.").Default("100").Short('n').Int()
 )
 
@@ -146,6 +150,13 @@ func outputManifestGroups(ctx context.Context, rep repo.Repository, manifests []
 	return nil
 }
 
+func uploadStatsSummary(s *snapshot.Stats) string {
+	return fmt.Sprintf("cached %v files (%v), hashed %v files (%v), new %v, deduplicated %v",
+		s.CachedFiles, units.BytesStringBase10(s.CachedBytes),
+		s.NonCachedFiles, units.BytesStringBase10(s.HashedBytes),
+		units.BytesStringBase10(s.NewBytes), units.BytesStringBase10(s.DeduplicatedBytes))
+}
+
 //nolint:gocyclo,funlen
 func outputManifestFromSingleSource(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, parts []string) error {
 	var (
@@ -211,6 +222,25 @@ func outputManifestFromSingleSource(ctx context.Context, rep repo.Repository, ma
 
 		col.Print(fmt.Sprintf("  %v %v %v\n", formatTimestamp(m.StartTime), oid, strings.Join(bits, " "))) //nolint:errcheck
 
+		// classification describes the entire snapshot, not nested entries.
+		if *snapshotListShowClassification && strings.Join(parts, "") == "" && m.Stats.Classification != nil {
+			fmt.Printf("    %v\n", classificationSummary(m.Stats.Classification))
+		}
+
+		if *snapshotListShowUploadStats && strings.Join(parts, "") == "" {
+			fmt.Printf("    %v\n", uploadStatsSummary(&m.Stats))
+		}
+
+		if *snapshotListShowCompression && strings.Join(parts, "") == "" {
+			saved, ratio := m.Stats.CompressionSavings()
+			fmt.Printf("    compression saved %v (%.1f%%) of %v\n", units.BytesStringBase10(saved), ratio*100, units.BytesStringBase10(m.Stats.CompressionInputBytes)) //nolint:gomnd
+		}
+
+		if *snapshotListShowActionOutput && strings.Join(parts, "") == "" && m.ActionOutput != nil {
+			printActionOutput("before", m.ActionOutput.BeforeSnapshotRoot)
+			printActionOutput("after", m.ActionOutput.AfterSnapshotRoot)
+		}
+
 		count++
 
 		if m.IncompleteReason == "" {
@@ -273,6 +303,29 @@ func entryBits(m *snapshot.Manifest, ent fs.Entry, lastTotalFileSize int64) (bit
 	return bits, col
 }
 
+func classificationSummary(c *snapshot.Classification) string {
+	var bits []string
+
+	for _, cat := range c.SortedCategories() {
+		st := c.Categories[cat]
+		bits = append(bits, fmt.Sprintf("%v:%v (%v files)", cat, maybeHumanReadableBytes(*snapshotListShowHumanReadable, st.TotalFileSize), st.FileCount))
+	}
+
+	if len(bits) == 0 {
+		return "no files"
+	}
+
+	return strings.Join(bits, " ")
+}
+
+func printActionOutput(prefix, output string) {
+	for _, l := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if l != "" {
+			fmt.Printf("    %v: %v\n", prefix, l)
+		}
+	}
+}
+
 func deltaBytes(b int64) string {
 	if b > 0 {
 		return "(+" + units.BytesStringBase10(b) + ")"
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var (
+	snapshotReportCommand = snapshotCommands.Command("report", "Display the report recorded when creating a snapshot.")
+	snapshotReportID      = snapshotReportCommand.Arg("id", "Snapshot ID").Required().String()
+)
+
+func runSnapshotReportCommand(ctx context.Context, rep repo.Repository) error {
+	m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(*snapshotReportID))
+	if err != nil {
+		return errors.Wrapf(err, "error loading snapshot %v", *snapshotReportID)
+	}
+
+	r, err := snapshot.LoadReport(ctx, rep, m)
+	if err != nil {
+		return err
+	}
+
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+
+	return e.Encode(r)
+}
+
+func init() {
+	snapshotReportCommand.Action(repositoryAction(runSnapshotReportCommand))
+}
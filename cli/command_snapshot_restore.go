@@ -3,8 +3,11 @@ package cli
 import (
 	"context"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
@@ -15,7 +18,39 @@ var (
 )
 
 func runSnapRestoreCommand(ctx context.Context, rep repo.Repository) error {
-	return snapshotfs.Restore(ctx, rep, *snapshotRestoreTargetPath, manifest.ID(*snapshotRestoreSnapID), restoreOptions())
+	m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(*snapshotRestoreSnapID))
+	if err != nil {
+		return err
+	}
+
+	if err := maybeRehydrate(ctx, rep, m.RootObjectID()); err != nil {
+		return err
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, m)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureRestoreFreeSpace(ctx, root, *snapshotRestoreTargetPath); err != nil {
+		return err
+	}
+
+	proceed, err := maybeShowRestorePlan(ctx, rep, root)
+	if err != nil {
+		return err
+	}
+
+	if !proceed {
+		return errors.New("restore canceled")
+	}
+
+	opt, err := restoreOptions()
+	if err != nil {
+		return err
+	}
+
+	return snapshotfs.Restore(ctx, rep, *snapshotRestoreTargetPath, manifest.ID(*snapshotRestoreSnapID), opt)
 }
 
 func init() {
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var (
+	snapshotSyncCommand    = snapshotCommands.Command("sync", "Make an existing directory match a snapshot, only downloading files which have changed.")
+	snapshotSyncSnapID     = snapshotSyncCommand.Arg("id", "Snapshot ID to synchronize the directory with").Required().String()
+	snapshotSyncTargetPath = snapshotSyncCommand.Arg("target-path", "Path of the directory to synchronize").Required().String()
+	snapshotSyncDelete     = snapshotSyncCommand.Flag("delete", "Delete files and directories which don't exist in the snapshot").Bool()
+	snapshotSyncScan       = snapshotSyncCommand.Flag("scan-command", "Command invoked with the path of each changed file before it is written, exit code 1 prevents the file from being written").String()
+)
+
+func runSnapshotSyncCommand(ctx context.Context, rep repo.Repository) error {
+	m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(*snapshotSyncSnapID))
+	if err != nil {
+		return err
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, m)
+	if err != nil {
+		return err
+	}
+
+	// like 'snapshot create', files with the same size and modification time are considered unchanged.
+	opt := localfs.CopyOptions{
+		OverwriteDirectories: true,
+		OverwriteFiles:       true,
+		SkipUnchangedFiles:   true,
+		DeleteExtraneous:     *snapshotSyncDelete,
+	}
+
+	if *snapshotSyncScan != "" {
+		scan, err := commandFileScanner(*snapshotSyncScan)
+		if err != nil {
+			return err
+		}
+
+		opt.ScanFile = scan
+	}
+
+	return localfs.Copy(ctx, *snapshotSyncTargetPath, root, opt)
+}
+
+func init() {
+	snapshotSyncCommand.Action(repositoryAction(runSnapshotSyncCommand))
+}
@@ -10,10 +10,12 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/parallelwork"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -131,9 +133,18 @@ func (v *verifier) doVerifyDirectory(ctx context.Context, oid object.ID, path st
 			break
 		}
 
+		if _, ok := e.(fs.SpecialFile); ok {
+			// named pipes, sockets and device nodes are metadata-only entries without contents.
+			continue
+		}
+
 		objectID := e.(object.HasObjectID).ObjectID()
 		childPath := path + "/" + e.Name()
 
+		if hd, ok := e.(snapshot.HasDirEntry); ok && hd.DirEntry().Unstable {
+			log(ctx).Warningf("%v changed while being snapshotted, its contents may be inconsistent", childPath)
+		}
+
 		if e.IsDir() {
 			v.enqueueVerifyDirectory(ctx, objectID, childPath)
 		} else {
@@ -177,7 +188,21 @@ func (v *verifier) readEntireObject(ctx context.Context, oid object.ID, path str
 	return err
 }
 
+// snapshotVerifyRunType is the type of run reported to maintenance schedule by snapshot verification.
+const snapshotVerifyRunType = "snapshot-verify"
+
 func runVerifyCommand(ctx context.Context, rep repo.Repository) error {
+	// record the outcome of verification, so that it's shown by 'multi status'.
+	if dr, ok := rep.(maintenance.MaintainableRepository); ok {
+		return maintenance.ReportRun(ctx, dr, snapshotVerifyRunType, func() error {
+			return verifySnapshots(ctx, rep)
+		})
+	}
+
+	return verifySnapshots(ctx, rep)
+}
+
+func verifySnapshots(ctx context.Context, rep repo.Repository) error {
 	v := &verifier{
 		rep:       rep,
 		startTime: time.Now(),
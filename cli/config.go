@@ -7,33 +7,46 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/fs/loggingfs"
+	"github.com/kopia/kopia/internal/i18n"
 	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/repo"
 )
 
 var (
 	traceStorage       = app.Flag("trace-storage", "Enables tracing of storage operations.").Default("true").Hidden().Bool()
+	storageStats       = app.Flag("storage-stats", "Print summary of storage operations performed by the command.").Envar("KOPIA_STORAGE_STATS").Bool()
 	traceObjectManager = app.Flag("trace-object-manager", "Enables tracing of object manager operations.").Envar("KOPIA_TRACE_OBJECT_MANAGER").Bool()
 	traceLocalFS       = app.Flag("trace-localfs", "Enables tracing of local filesystem operations").Envar("KOPIA_TRACE_FS").Bool()
 	enableCaching      = app.Flag("caching", "Enables caching of objects (disable with --no-caching)").Default("true").Hidden().Bool()
 	enableListCaching  = app.Flag("list-caching", "Enables caching of list results (disable with --no-list-caching)").Default("true").Hidden().Bool()
 	metricsListenAddr  = app.Flag("metrics-listen-addr", "Expose Prometheus metrics on a given host:port").Hidden().String()
+	hardwareKeyCommand = app.Flag("hardware-key-command", "Command computing hex-encoded response of the hardware key to the hex-encoded challenge appended to it, such as 'ykchalresp -2 -x'").Envar("KOPIA_HARDWARE_KEY_COMMAND").String()
+
+	recoveryShares = app.Flag("recovery-share", "Recovery share used to open the repository instead of the password, repeat for the required number of shares").PlaceHolder("SHARE").Strings()
+	recoveryCode   = app.Flag("recovery-code", "Recovery code used to open or connect to the repository instead of the password").PlaceHolder("CODE").Envar("KOPIA_RECOVERY_CODE").String()
 
 	configPath = app.Flag("config-file", "Specify the config file to use.").Default(defaultConfigFileName()).Envar("KOPIA_CONFIG_PATH").String()
 )
 
 func printStderr(msg string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, msg, args...) //nolint:errcheck
+	if *plainOutput {
+		// messages replacing the progress line start with carriage return, which is not needed
+		// when the progress is printed on separate lines.
+		msg = strings.TrimPrefix(msg, "\r")
+	}
+
+	fmt.Fprintf(os.Stderr, i18n.T(msg), args...) //nolint:errcheck
 }
 
 func printStdout(msg string, args ...interface{}) {
-	fmt.Fprintf(os.Stdout, msg, args...) //nolint:errcheck
+	fmt.Fprintf(os.Stdout, i18n.T(msg), args...) //nolint:errcheck
 }
 
 func onCtrlC(f func()) {
@@ -66,7 +79,7 @@ func openRepository(ctx context.Context, opts *repo.Options, required bool) (rep
 
 	maybePrintUpdateNotification(ctx)
 
-	pass, err := getPasswordFromFlags(ctx, false, true)
+	pass, err := getConnectionPassword(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "get password")
 	}
@@ -79,6 +92,30 @@ func openRepository(ctx context.Context, opts *repo.Options, required bool) (rep
 	return r, err
 }
 
+// getConnectionPassword returns the password used to open the connected repository, which is empty
+// when opening using recovery shares or KMS credentials and the passphrase of the key file when connected using a key file.
+func getConnectionPassword(ctx context.Context) (string, error) {
+	var lc repo.LocalConfig
+
+	if f, err := os.Open(repositoryConfigFileName()); err == nil {
+		err = lc.Load(f)
+		f.Close() //nolint:errcheck
+
+		if err != nil {
+			lc = repo.LocalConfig{}
+		}
+	}
+
+	switch {
+	case len(*recoveryShares) > 0, *recoveryCode != "", lc.UseKMS:
+		return "", nil
+	case lc.KeyFile != "":
+		return getKeyFilePassphrase(ctx, lc.KeyFile, true)
+	default:
+		return getPasswordFromFlags(ctx, false, true)
+	}
+}
+
 func applyOptionsFromFlags(ctx context.Context, opts *repo.Options) *repo.Options {
 	if opts == nil {
 		opts = &repo.Options{}
@@ -88,10 +125,21 @@ func applyOptionsFromFlags(ctx context.Context, opts *repo.Options) *repo.Option
 		opts.TraceStorage = log(ctx).Debugf
 	}
 
+	if *storageStats {
+		opts.StorageStats = &storageCounters
+	}
+
 	if *traceObjectManager {
 		opts.ObjectManagerOptions.Trace = log(ctx).Debugf
 	}
 
+	if *hardwareKeyCommand != "" {
+		opts.HardwareKey = repo.CommandHardwareKey(*hardwareKeyCommand)
+	}
+
+	opts.RecoveryShares = *recoveryShares
+	opts.RecoveryCode = *recoveryCode
+
 	return opts
 }
 
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/repo"
+)
+
+var fipsMode = app.Flag("fips", "Restrict cryptographic algorithms to FIPS-approved ones (AES-GCM, SHA-2, SHA-3, PBKDF2 and HKDF).").Envar("KOPIA_FIPS").Bool()
+
+func initializeFIPSMode(ctx *kingpin.ParseContext) error {
+	if *fipsMode {
+		repo.SetFIPSMode(true)
+	}
+
+	return nil
+}
+
+func init() {
+	app.PreAction(initializeFIPSMode)
+}
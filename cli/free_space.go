@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/diskspace"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+// ensureFreeCacheSpace fails when the cache directory of the repository is almost full.
+func ensureFreeCacheSpace(rep repo.Repository) error {
+	if dr, ok := rep.(*repo.DirectRepository); ok {
+		return dr.CheckFreeCacheSpace()
+	}
+
+	return nil
+}
+
+// ensureRestoreFreeSpace fails when the filesystem of the target path does not have enough free space
+// for the contents of the entry being restored.
+func ensureRestoreFreeSpace(ctx context.Context, root fs.Entry, targetPath string) error {
+	if !restoreCheckFreeSpace {
+		return nil
+	}
+
+	avail, err := diskspace.Available(targetPath)
+	if err != nil {
+		log(ctx).Debugf("unable to determine free space in %v: %v", targetPath, err)
+		return nil
+	}
+
+	if required := root.Size(); avail < required {
+		return errors.Errorf("not enough free space to restore to %v: %v required, %v available (skip this check with --no-check-free-space)",
+			targetPath, units.BytesStringBase10(required), units.BytesStringBase10(avail))
+	}
+
+	return nil
+}
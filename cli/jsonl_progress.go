@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// progressEvent is a single line of the JSONL progress output describing the outcome of a file or directory.
+type progressEvent struct {
+	Time   time.Time              `json:"time"`
+	Path   string                 `json:"path"`
+	Action snapshotfs.EntryAction `json:"action"`
+	Bytes  int64                  `json:"bytes"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// jsonlProgress emits one JSON event per processed file or directory and forwards all progress to the wrapped progress.
+type jsonlProgress struct {
+	snapshotfs.UploadProgress
+
+	mu     sync.Mutex
+	enc    *json.Encoder
+	failed bool
+}
+
+func (p *jsonlProgress) FinishedEntry(path string, action snapshotfs.EntryAction, numBytes int64, err error) {
+	p.UploadProgress.FinishedEntry(path, action, numBytes, err)
+
+	ev := progressEvent{
+		Time:   time.Now().UTC(), // allow:no-inject-time
+		Path:   path,
+		Action: action,
+		Bytes:  numBytes,
+	}
+
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failed {
+		return
+	}
+
+	if err := p.enc.Encode(ev); err != nil {
+		// report only the first failure, the output is most likely gone.
+		p.failed = true
+
+		printStderr("unable to write progress event: %v\n", err)
+	}
+}
+
+func newJSONLProgress(base snapshotfs.UploadProgress, w io.Writer) *jsonlProgress {
+	return &jsonlProgress{
+		UploadProgress: base,
+		enc:            json.NewEncoder(w),
+	}
+}
+
+var _ snapshotfs.UploadProgress = (*jsonlProgress)(nil)
@@ -3,17 +3,39 @@ package cli
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
+// snapshotTimeSeparator separates source path from time when referring to a path in the snapshot taken
+// closest to the given time, as in 'user@host:/path#2020-01-02T10:00'.
+const snapshotTimeSeparator = "#"
+
+// snapshotTimeFormats are supported formats of time in snapshot references, interpreted in local time zone unless specified.
+var snapshotTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
 // ParseObjectID interprets the given ID string and returns corresponding object.ID.
+// The ID is either an object ID optionally followed by a path within the directory, or a path in the
+// snapshot closest to the provided time, such as 'user@host:/path#2020-01-02T10:00'.
 func parseObjectID(ctx context.Context, rep repo.Repository, id string) (object.ID, error) {
+	if path, t, ok := splitSnapshotTimeReference(id); ok {
+		return parseSnapshotPathAtTime(ctx, rep, path, t)
+	}
+
 	parts := strings.Split(id, "/")
 
 	oid, err := object.ParseID(parts[0])
@@ -30,6 +52,163 @@ func parseObjectID(ctx context.Context, rep repo.Repository, id string) (object.
 	return parseNestedObjectID(ctx, dir, parts[1:])
 }
 
+// splitSnapshotTimeReference splits the reference to a path in the snapshot closest to the given time
+// into the path and time. References starting with an object ID, and those where the text after the separator
+// is not a valid time, are not time references, since file names may contain the separator.
+func splitSnapshotTimeReference(id string) (string, time.Time, bool) {
+	p := strings.LastIndex(id, snapshotTimeSeparator)
+	if p < 0 {
+		return "", time.Time{}, false
+	}
+
+	t, err := parseSnapshotTime(id[p+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	path := id[0:p]
+
+	if _, err := object.ParseID(strings.Split(path, "/")[0]); err == nil {
+		return "", time.Time{}, false
+	}
+
+	return path, t, true
+}
+
+// parseSnapshotPathAtTime returns object ID of the provided path in the snapshot taken closest to the provided time.
+func parseSnapshotPathAtTime(ctx context.Context, rep repo.Repository, path string, t time.Time) (object.ID, error) {
+	si, err := snapshot.ParseSourceInfo(path, rep.Hostname(), rep.Username())
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid source %q", path)
+	}
+
+	if si.Path == "" {
+		return "", errors.Errorf("path must be specified in %q", path)
+	}
+
+	src, relativePath, err := findSourceContainingPath(ctx, rep, si)
+	if err != nil {
+		return "", err
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, rep, src)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list snapshots of %v", src)
+	}
+
+	man, err := closestSnapshot(snapshots, t)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to find snapshot of %v", src)
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return "", err
+	}
+
+	return parseNestedObjectID(ctx, root, strings.FieldsFunc(relativePath, isPathSeparator))
+}
+
+func parseSnapshotTime(timestamp string) (time.Time, error) {
+	for _, f := range snapshotTimeFormats {
+		if t, err := time.ParseInLocation(f, timestamp, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("invalid snapshot time %q, expected format such as %v", timestamp, strings.Join(snapshotTimeFormats[1:], " or "))
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// isWithinPath determines whether the path p is the same as the directory dir or is located under it.
+func isWithinPath(p, dir string) bool {
+	if dir == "" || !strings.HasPrefix(p, dir) {
+		return false
+	}
+
+	rest := p[len(dir):]
+
+	return rest == "" || isPathSeparator(rune(rest[0])) || isPathSeparator(rune(dir[len(dir)-1]))
+}
+
+// findSourceContainingPath returns the snapshot source which includes the provided path and the path relative to it.
+// When sources are nested, the innermost one is used.
+func findSourceContainingPath(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) (snapshot.SourceInfo, string, error) {
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return snapshot.SourceInfo{}, "", errors.Wrap(err, "unable to list sources")
+	}
+
+	var (
+		best  snapshot.SourceInfo
+		found bool
+	)
+
+	for _, src := range sources {
+		if src.Host != si.Host || src.UserName != si.UserName {
+			continue
+		}
+
+		if !isWithinPath(si.Path, src.Path) {
+			continue
+		}
+
+		if !found || len(src.Path) > len(best.Path) {
+			best = src
+			found = true
+		}
+	}
+
+	if !found {
+		return snapshot.SourceInfo{}, "", errors.Errorf("no snapshots of %v found", si)
+	}
+
+	return best, si.Path[len(best.Path):], nil
+}
+
+// closestSnapshot returns the complete snapshot which was started closest to the provided time.
+func closestSnapshot(snapshots []*snapshot.Manifest, t time.Time) (*snapshot.Manifest, error) {
+	var best []*snapshot.Manifest
+
+	for _, m := range snapshots {
+		if m.IncompleteReason != "" {
+			continue
+		}
+
+		switch {
+		case len(best) == 0 || timeDistance(m.StartTime, t) < timeDistance(best[0].StartTime, t):
+			best = []*snapshot.Manifest{m}
+		case timeDistance(m.StartTime, t) == timeDistance(best[0].StartTime, t):
+			best = append(best, m)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return nil, errors.New("no complete snapshots")
+	case 1:
+		return best[0], nil
+	default:
+		var desc []string
+		for _, m := range best {
+			desc = append(desc, string(m.ID)+" ("+formatTimestamp(m.StartTime)+")")
+		}
+
+		return nil, errors.Errorf("%v is equally close to snapshots %v, specify the time more precisely or use snapshot ID", formatTimestamp(t), strings.Join(desc, " and "))
+	}
+}
+
+func timeDistance(t1, t2 time.Time) time.Duration {
+	if d := t1.Sub(t2); d >= 0 {
+		return d
+	}
+
+	return t2.Sub(t1)
+}
+
 func getNestedEntry(ctx context.Context, startingDir fs.Entry, parts []string) (fs.Entry, error) {
 	current := startingDir
 
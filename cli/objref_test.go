@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestClosestSnapshot(t *testing.T) {
+	base := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)
+	snapshots := []*snapshot.Manifest{
+		{ID: "s1", StartTime: base},
+		{ID: "s2", StartTime: base.Add(2 * time.Hour)},
+		{ID: "s3", StartTime: base.Add(3 * time.Hour), IncompleteReason: "checkpoint"},
+		{ID: "s4", StartTime: base.Add(4 * time.Hour)},
+	}
+
+	cases := []struct {
+		t       time.Time
+		want    string
+		wantErr string
+	}{
+		{t: base.Add(-time.Hour), want: "s1"},
+		{t: base.Add(50 * time.Minute), want: "s1"},
+		{t: base.Add(70 * time.Minute), want: "s2"},
+		{t: base.Add(3 * time.Hour), wantErr: "equally close"},
+		{t: base.Add(10 * time.Hour), want: "s4"},
+	}
+
+	for _, tc := range cases {
+		m, err := closestSnapshot(snapshots, tc.t)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("unexpected error for %v: %v, want %q", tc.t, err, tc.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %v", tc.t, err)
+		}
+
+		if got := string(m.ID); got != tc.want {
+			t.Errorf("unexpected snapshot for %v: %v, want %v", tc.t, got, tc.want)
+		}
+	}
+
+	if _, err := closestSnapshot(snapshots[2:3], base); err == nil {
+		t.Errorf("expected error when there are no complete snapshots")
+	}
+}
+
+func TestParseSnapshotTime(t *testing.T) {
+	for _, s := range []string{"2020-01-02T10:00", "2020-01-02T10:00:00", "2020-01-02 10:00", "2020-01-02"} {
+		if _, err := parseSnapshotTime(s); err != nil {
+			t.Errorf("unable to parse %q: %v", s, err)
+		}
+	}
+
+	got, err := parseSnapshotTime("2020-01-02T10:00:00Z")
+	if err != nil || !got.Equal(time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time with time zone: %v %v", got, err)
+	}
+
+	if _, err := parseSnapshotTime("yesterday"); err == nil {
+		t.Errorf("expected error parsing invalid time")
+	}
+}
+
+func TestIsWithinPath(t *testing.T) {
+	cases := []struct {
+		p, dir string
+		want   bool
+	}{
+		{"/home/user", "/home/user", true},
+		{"/home/user/docs", "/home/user", true},
+		{"/home/user2", "/home/user", false},
+		{"/home", "/home/user", false},
+		{"/home/user", "/", true},
+		{`C:\Users\user\docs`, `C:\Users\user`, true},
+		{`C:\Users\user`, `C:\`, true},
+	}
+
+	for _, tc := range cases {
+		if got := isWithinPath(tc.p, tc.dir); got != tc.want {
+			t.Errorf("isWithinPath(%q, %q) = %v, want %v", tc.p, tc.dir, got, tc.want)
+		}
+	}
+}
+
+func TestSplitSnapshotTimeReference(t *testing.T) {
+	cases := []struct {
+		id       string
+		wantPath string
+		wantOK   bool
+	}{
+		{id: "user@host:/home/user#2020-01-02T10:00", wantPath: "user@host:/home/user", wantOK: true},
+		{id: "/home/user/a#b#2020-01-02", wantPath: "/home/user/a#b", wantOK: true},
+		{id: "/home/user/a#b", wantOK: false},
+		{id: "k0123456789abcdef0123456789abcdef/dir/a#b", wantOK: false},
+		{id: "k0123456789abcdef0123456789abcdef/dir/a#2020-01-02", wantOK: false},
+		{id: "k0123456789abcdef0123456789abcdef", wantOK: false},
+	}
+
+	for _, tc := range cases {
+		path, _, ok := splitSnapshotTimeReference(tc.id)
+		if ok != tc.wantOK || path != tc.wantPath {
+			t.Errorf("splitSnapshotTimeReference(%q) = %q, %v, want %q, %v", tc.id, path, ok, tc.wantPath, tc.wantOK)
+		}
+	}
+}
@@ -16,8 +16,12 @@ var (
 )
 
 func askForNewRepositoryPassword() (string, error) {
+	return askForNewPassword("Enter password to create new repository: ")
+}
+
+func askForNewPassword(prompt string) (string, error) {
 	for {
-		p1, err := askPass("Enter password to create new repository: ")
+		p1, err := askPass(prompt)
 		if err != nil {
 			return "", errors.Wrap(err, "password entry")
 		}
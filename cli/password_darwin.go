@@ -3,5 +3,5 @@ package cli
 import "github.com/kopia/kopia/repo"
 
 func init() {
-	app.Flag("use-keychain", "Use macOS Keychain for storing repository password.").Default("true").BoolVar(&repo.KeyRingEnabled)
+	app.Flag("use-keychain", "Use macOS Keychain for storing repository password.").Envar("KOPIA_USE_KEYCHAIN").Default("true").BoolVar(&repo.KeyRingEnabled)
 }
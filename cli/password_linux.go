@@ -3,5 +3,5 @@ package cli
 import "github.com/kopia/kopia/repo"
 
 func init() {
-	app.Flag("use-keyring", "Use Gnome Keyring for storing repository password.").Default("false").BoolVar(&repo.KeyRingEnabled)
+	app.Flag("use-keyring", "Use Gnome Keyring for storing repository password.").Envar("KOPIA_USE_KEYRING").Default("false").BoolVar(&repo.KeyRingEnabled)
 }
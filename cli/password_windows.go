@@ -3,5 +3,5 @@ package cli
 import "github.com/kopia/kopia/repo"
 
 func init() {
-	app.Flag("use-credential-manager", "Use Windows Credential Manager for storing repository password.").Default("true").BoolVar(&repo.KeyRingEnabled)
+	app.Flag("use-credential-manager", "Use Windows Credential Manager for storing repository password.").Envar("KOPIA_USE_CREDENTIAL_MANAGER").Default("true").BoolVar(&repo.KeyRingEnabled)
 }
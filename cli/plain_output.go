@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"github.com/fatih/color"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var plainOutput = app.Flag("plain", "Plain output without progress bars, colors or rewriting of lines, suitable for screen readers and logs.").Envar("KOPIA_PLAIN_OUTPUT").Bool()
+
+func initializePlainOutput(ctx *kingpin.ParseContext) error {
+	if *plainOutput {
+		color.NoColor = true
+	}
+
+	return nil
+}
+
+func init() {
+	app.PreAction(initializePlainOutput)
+}
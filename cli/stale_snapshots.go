@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+var warnStaleSnapshots = app.Flag("warn-stale-snapshots", "Warn about sources whose last successful snapshot is older than the 'stale-after' age in their policy").Envar("KOPIA_WARN_STALE_SNAPSHOTS").Bool()
+
+// staleSource describes a snapshot source whose last successful snapshot is older than its policy allows.
+type staleSource struct {
+	Source     snapshot.SourceInfo
+	StaleAfter time.Duration
+
+	// LastSnapshotTime is the start time of the last successful snapshot, zero if there are none.
+	LastSnapshotTime time.Time
+}
+
+// findStaleSources returns the list of sources whose last successful snapshot is older than
+// the stale-after age defined in their effective policy.
+func findStaleSources(ctx context.Context, rep repo.Repository) ([]staleSource, error) {
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sources")
+	}
+
+	now := rep.Time()
+
+	var result []staleSource
+
+	for _, src := range sources {
+		pol, _, err := policy.GetEffectivePolicy(ctx, rep, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get effective policy for %v", src)
+		}
+
+		staleAfter := pol.SchedulingPolicy.StaleAfter()
+		if staleAfter == 0 {
+			continue
+		}
+
+		manifests, err := snapshot.ListSnapshots(ctx, rep, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list snapshots of %v", src)
+		}
+
+		var last time.Time
+
+		for _, m := range manifests {
+			if m.IncompleteReason == "" && m.StartTime.After(last) {
+				last = m.StartTime
+			}
+		}
+
+		if now.Sub(last) > staleAfter {
+			result = append(result, staleSource{src, staleAfter, last})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source.String() < result[j].Source.String()
+	})
+
+	return result, nil
+}
+
+// maybePrintStaleSnapshotWarnings prints a warning for each source whose last successful snapshot is stale, if enabled.
+func maybePrintStaleSnapshotWarnings(ctx context.Context, rep repo.Repository) {
+	if !*warnStaleSnapshots || rep == nil {
+		return
+	}
+
+	stale, err := findStaleSources(ctx, rep)
+	if err != nil {
+		log(ctx).Warningf("unable to check for stale snapshots: %v", err)
+		return
+	}
+
+	for _, s := range stale {
+		if s.LastSnapshotTime.IsZero() {
+			warningColor.Fprintf(os.Stderr, "WARNING: %v has no successful snapshots.\n", s.Source) //nolint:errcheck
+			continue
+		}
+
+		warningColor.Fprintf(os.Stderr, "WARNING: last successful snapshot of %v was %v ago, older than %v.\n", //nolint:errcheck
+			s.Source, formatAge(rep.Time().Sub(s.LastSnapshotTime)), s.StaleAfter)
+	}
+}
+
+// formatAge returns the human-readable approximate age.
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+
+	if d >= 2*day {
+		return fmt.Sprintf("%v days", int(d/day))
+	}
+
+	return d.Round(time.Minute).String()
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/kopia/kopia/repo/blob"
@@ -18,12 +19,23 @@ func init() {
 		func(cmd *kingpin.CmdClause) {
 			cmd.Flag("container", "Name of the Azure blob container").Required().StringVar(&azOptions.Container)
 			cmd.Flag("storage-account", "Azure storage account name(overrides AZURE_STORAGE_ACCOUNT environment variable)").Required().Envar("AZURE_STORAGE_ACCOUNT").StringVar(&azOptions.StorageAccount)
-			cmd.Flag("storage-key", "Azure storage account key(overrides AZURE_STORAGE_KEY environment variable)").Required().Envar("AZURE_STORAGE_KEY").StringVar(&azOptions.StorageKey)
+			cmd.Flag("storage-key", "Azure storage account key(overrides AZURE_STORAGE_KEY environment variable)").Envar("AZURE_STORAGE_KEY").StringVar(&azOptions.StorageKey)
+			cmd.Flag("federated-token-file", "Obtain access tokens using federated token from the provided file (overrides AZURE_FEDERATED_TOKEN_FILE environment variable)").Envar("AZURE_FEDERATED_TOKEN_FILE").StringVar(&azOptions.FederatedTokenFile)
+			cmd.Flag("tenant-id", "Azure AD tenant ID (overrides AZURE_TENANT_ID environment variable)").Envar("AZURE_TENANT_ID").StringVar(&azOptions.TenantID)
+			cmd.Flag("client-id", "Azure AD application client ID (overrides AZURE_CLIENT_ID environment variable)").Envar("AZURE_CLIENT_ID").StringVar(&azOptions.ClientID)
+			cmd.Flag("authority-host", "Azure AD authority host (overrides AZURE_AUTHORITY_HOST environment variable)").Envar("AZURE_AUTHORITY_HOST").StringVar(&azOptions.AuthorityHost)
 			cmd.Flag("prefix", "Prefix to use for objects in the bucket").StringVar(&azOptions.Prefix)
 			cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&azOptions.MaxDownloadSpeedBytesPerSecond)
 			cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&azOptions.MaxUploadSpeedBytesPerSecond)
+			cmd.Flag("encryption-scope", "Encrypt written blobs using the provided encryption scope").StringVar(&azOptions.EncryptionScope)
+			cmd.Flag("cold-access-tier", "Access tier of data packs, which are only read when restoring, metadata remains in the default access tier").EnumVar(&azOptions.ColdAccessTier, "Cool", "Archive")
+			cmd.Flag("rehydrate-access-tier", "Access tier archived data packs are moved to when they are rehydrated before restore, where they remain afterwards").EnumVar(&azOptions.RehydrateAccessTier, "Hot", "Cool")
 		},
 		func(ctx context.Context, isNew bool) (blob.Storage, error) {
+			if azOptions.StorageKey == "" && azOptions.FederatedTokenFile == "" {
+				return nil, errors.New("either --storage-key or --federated-token-file must be provided")
+			}
+
 			return azure.New(ctx, &azOptions)
 		},
 	)
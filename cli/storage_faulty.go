@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/faulty"
+)
+
+func init() {
+	var (
+		storageConfigFile string
+		opt               faulty.Options
+	)
+
+	RegisterStorageConnectFlags(
+		"faulty",
+		"a storage with randomly injected faults, for testing",
+		func(cmd *kingpin.CmdClause) {
+			cmd.Flag("storage-config", "JSON file with storage connection info of the underlying storage").Required().ExistingFileVar(&storageConfigFile)
+			cmd.Flag("latency-probability", "Probability of delaying an operation").Float64Var(&opt.LatencyProbability)
+			cmd.Flag("max-latency", "Maximum injected delay").Default("1s").DurationVar(&opt.MaxLatency)
+			cmd.Flag("dropped-write-probability", "Probability of silently not writing a blob").Float64Var(&opt.DroppedWriteProbability)
+			cmd.Flag("truncated-read-probability", "Probability of returning partial contents of a blob").Float64Var(&opt.TruncatedReadProbability)
+			cmd.Flag("not-found-probability", "Probability of reporting an existing blob as not found").Float64Var(&opt.NotFoundProbability)
+			cmd.Flag("seed", "Random seed, which makes the faults reproducible").Int64Var(&opt.Seed)
+		},
+		func(ctx context.Context, isNew bool) (blob.Storage, error) {
+			d, err := ioutil.ReadFile(storageConfigFile) //nolint:gosec
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to read storage config file")
+			}
+
+			if err := json.Unmarshal(d, &opt.Storage); err != nil {
+				return nil, errors.Wrapf(err, "invalid storage config in %v", storageConfigFile)
+			}
+
+			return faulty.New(ctx, &opt)
+		})
+}
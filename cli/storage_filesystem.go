@@ -65,6 +65,7 @@ func init() {
 			cmd.Flag("file-mode", "File mode for newly created files (0600)").PlaceHolder("MODE").StringVar(&connectFileMode)
 			cmd.Flag("dir-mode", "Mode of newly directory files (0700)").PlaceHolder("MODE").StringVar(&connectDirMode)
 			cmd.Flag("flat", "Use flat directory structure").BoolVar(&connectFlat)
+			cmd.Flag("sync-writes", "Flush written files to stable storage before completing writes").BoolVar(&options.SyncWrites)
 		},
 		connect)
 }
@@ -27,6 +27,7 @@ func init() {
 			cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&options.MaxDownloadSpeedBytesPerSecond)
 			cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&options.MaxUploadSpeedBytesPerSecond)
 			cmd.Flag("embed-credentials", "Embed GCS credentials JSON in Kopia configuration").BoolVar(&embedCredentials)
+			cmd.Flag("kms-key-name", "Encrypt written objects using the provided Cloud KMS key (CMEK)").StringVar(&options.KMSKeyName)
 		},
 		func(ctx context.Context, isNew bool) (blob.Storage, error) {
 			if embedCredentials {
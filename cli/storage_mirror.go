@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/mirror"
+)
+
+func init() {
+	var storageConfigFiles []string
+
+	RegisterStorageConnectFlags(
+		"mirror",
+		"a set of mirrored storages",
+		func(cmd *kingpin.CmdClause) {
+			cmd.Flag("storage-config", "JSON file with storage connection info of a mirror (repeat for each mirror, reads are attempted in order)").Required().ExistingFilesVar(&storageConfigFiles)
+		},
+		func(ctx context.Context, isNew bool) (blob.Storage, error) {
+			var opt mirror.Options
+
+			for _, fname := range storageConfigFiles {
+				d, err := ioutil.ReadFile(fname) //nolint:gosec
+				if err != nil {
+					return nil, errors.Wrap(err, "unable to read storage config file")
+				}
+
+				var ci blob.ConnectionInfo
+				if err := json.Unmarshal(d, &ci); err != nil {
+					return nil, errors.Wrapf(err, "invalid storage config in %v", fname)
+				}
+
+				opt.Storages = append(opt.Storages, ci)
+			}
+
+			return mirror.New(ctx, &opt)
+		})
+}
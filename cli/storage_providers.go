@@ -56,4 +56,31 @@ func RegisterStorageConnectFlags(
 
 		return runRepairCommandWithStorage(ctx, st)
 	})
+
+	// Set up 'validate-connection' subcommand
+	cc = validateConnectionCommand.Command(name, "Validate connection to "+description)
+	flags(cc)
+	cc.Action(func(_ *kingpin.ParseContext) error {
+		ctx := rootContext()
+		// validation typically precedes repository creation, so connect the same way 'create' does.
+		st, err := connect(ctx, true)
+		if err != nil {
+			return errors.Wrap(err, "can't connect to storage")
+		}
+
+		return runValidateConnectionCommandWithStorage(ctx, st)
+	})
+
+	// Set up 'blob audit verify' subcommand
+	cc = blobAuditVerifyCommand.Command(name, "Verify blob manifest against "+description)
+	flags(cc)
+	cc.Action(func(_ *kingpin.ParseContext) error {
+		ctx := rootContext()
+		st, err := connect(ctx, false)
+		if err != nil {
+			return errors.Wrap(err, "can't connect to storage")
+		}
+
+		return runBlobAuditVerifyCommandWithStorage(ctx, st)
+	})
 }
@@ -0,0 +1,31 @@
+// +build ceph
+
+package cli
+
+import (
+	"context"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/rados"
+)
+
+func init() {
+	var options rados.Options
+
+	RegisterStorageConnectFlags(
+		"rados",
+		"a Ceph RADOS pool",
+		func(cmd *kingpin.CmdClause) {
+			cmd.Flag("pool", "Name of the RADOS pool").Required().StringVar(&options.Pool)
+			cmd.Flag("namespace", "RADOS namespace within the pool").StringVar(&options.Namespace)
+			cmd.Flag("cluster-name", "Ceph cluster name").Envar("CEPH_CLUSTER").StringVar(&options.ClusterName)
+			cmd.Flag("ceph-user", "Ceph user name (client.<id>)").Envar("CEPH_USER").StringVar(&options.User)
+			cmd.Flag("ceph-config", "Path to Ceph configuration file").Envar("CEPH_CONF").StringVar(&options.ConfigFile)
+			cmd.Flag("stripe-size", "Split blobs larger than the provided number of bytes into multiple RADOS objects").Int64Var(&options.StripeSize)
+		},
+		func(ctx context.Context, isNew bool) (blob.Storage, error) {
+			return rados.New(ctx, &options)
+		})
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/kopia/kopia/repo/blob"
@@ -19,16 +20,29 @@ func init() {
 			cmd.Flag("bucket", "Name of the S3 bucket").Required().StringVar(&s3options.BucketName)
 			cmd.Flag("endpoint", "Endpoint to use").Default("s3.amazonaws.com").StringVar(&s3options.Endpoint)
 			cmd.Flag("region", "S3 Region").Default("").StringVar(&s3options.Region)
-			cmd.Flag("access-key", "Access key ID (overrides AWS_ACCESS_KEY_ID environment variable)").Required().Envar("AWS_ACCESS_KEY_ID").StringVar(&s3options.AccessKeyID)
-			cmd.Flag("secret-access-key", "Secret access key (overrides AWS_SECRET_ACCESS_KEY environment variable)").Required().Envar("AWS_SECRET_ACCESS_KEY").StringVar(&s3options.SecretAccessKey)
+			cmd.Flag("access-key", "Access key ID (overrides AWS_ACCESS_KEY_ID environment variable)").Envar("AWS_ACCESS_KEY_ID").StringVar(&s3options.AccessKeyID)
+			cmd.Flag("secret-access-key", "Secret access key (overrides AWS_SECRET_ACCESS_KEY environment variable)").Envar("AWS_SECRET_ACCESS_KEY").StringVar(&s3options.SecretAccessKey)
 			cmd.Flag("session-token", "Session token (overrides AWS_SESSION_TOKEN environment variable)").Envar("AWS_SESSION_TOKEN").StringVar(&s3options.SessionToken)
+			cmd.Flag("web-identity-token-file", "Obtain temporary credentials using web identity token from the provided file (overrides AWS_WEB_IDENTITY_TOKEN_FILE environment variable)").Envar("AWS_WEB_IDENTITY_TOKEN_FILE").StringVar(&s3options.WebIdentityTokenFile)
+			cmd.Flag("role-arn", "ARN of the role to assume using web identity token (overrides AWS_ROLE_ARN environment variable)").Envar("AWS_ROLE_ARN").StringVar(&s3options.RoleARN)
+			cmd.Flag("sts-endpoint", "STS endpoint used to obtain temporary credentials").StringVar(&s3options.STSEndpoint)
 			cmd.Flag("prefix", "Prefix to use for objects in the bucket").StringVar(&s3options.Prefix)
 			cmd.Flag("disable-tls", "Disable TLS security (HTTPS)").BoolVar(&s3options.DoNotUseTLS)
 			cmd.Flag("disable-tls-verification", "Disable TLS (HTTPS) certificate verification").BoolVar(&s3options.DoNotVerifyTLS)
 			cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&s3options.MaxDownloadSpeedBytesPerSecond)
 			cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&s3options.MaxUploadSpeedBytesPerSecond)
+			cmd.Flag("retention-mode", "S3 Object Lock retention mode applied to written objects (bucket must have Object Lock enabled)").EnumVar(&s3options.RetentionMode, blob.RetentionModeGovernance, blob.RetentionModeCompliance)
+			cmd.Flag("retention-period", "S3 Object Lock retention period of written objects").DurationVar(&s3options.RetentionPeriod)
+			cmd.Flag("kms-key-id", "Encrypt written objects with SSE-KMS using the provided KMS key ID or ARN").StringVar(&s3options.KMSKeyID)
+			cmd.Flag("cold-storage-class", "Storage class of data packs, which are only read when restoring, metadata remains in the default storage class (maintenance does not compact packs in GLACIER or DEEP_ARCHIVE)").EnumVar(&s3options.ColdStorageClass, "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER", "DEEP_ARCHIVE")
+			cmd.Flag("restore-days", "Number of days restored copies of archived objects remain readable").IntVar(&s3options.RestoreDays)
+			cmd.Flag("restore-tier", "Retrieval tier used when restoring archived objects").EnumVar(&s3options.RestoreTier, "Expedited", "Standard", "Bulk")
 		},
 		func(ctx context.Context, isNew bool) (blob.Storage, error) {
+			if s3options.WebIdentityTokenFile == "" && (s3options.AccessKeyID == "" || s3options.SecretAccessKey == "") {
+				return nil, errors.New("either --access-key and --secret-access-key or --web-identity-token-file must be provided")
+			}
+
 			return s3.New(ctx, &s3options)
 		},
 	)
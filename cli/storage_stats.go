@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/blob/stats"
+)
+
+// storageCounters accumulates operations performed on the storage by the command when --storage-stats is set.
+var storageCounters stats.Counters
+
+// maybePrintStorageStats prints the number of storage operations performed by the command, which helps
+// estimate its cost when using object stores charging per API call.
+func maybePrintStorageStats() {
+	if !*storageStats {
+		return
+	}
+
+	c := storageCounters.Snapshot()
+
+	printStderr("Storage operations: LIST %v (%v blobs), GET %v (%v), GET-METADATA %v, PUT %v (%v), DELETE %v\n",
+		c.ListCount, c.ListedBlobs,
+		c.GetCount, units.BytesStringBase10(c.GetBytes),
+		c.GetMetadataCount,
+		c.PutCount, units.BytesStringBase10(c.PutBytes),
+		c.DeleteCount)
+}
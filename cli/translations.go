@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"path/filepath"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/kopia/kopia/internal/i18n"
+	"github.com/kopia/kopia/internal/ospath"
+)
+
+var (
+	language        = app.Flag("language", "Language of displayed messages, defaults to the language of the system.").Envar("KOPIA_LANGUAGE").String()
+	translationsDir = app.Flag("translations-dir", "Directory containing community translations of messages as <language>.json files.").Default(filepath.Join(ospath.ConfigDir(), "translations")).Envar("KOPIA_TRANSLATIONS_DIR").Hidden().String()
+)
+
+func initializeTranslations(ctx *kingpin.ParseContext) error {
+	if err := i18n.LoadDirectory(*translationsDir); err != nil {
+		log(rootContext()).Warningf("unable to load translations: %v", err)
+	}
+
+	lang := *language
+	if lang == "" {
+		lang = i18n.DetectLanguage()
+	}
+
+	i18n.SetLanguage(lang)
+
+	return nil
+}
+
+func init() {
+	app.PreAction(initializeTranslations)
+}
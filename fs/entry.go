@@ -9,7 +9,7 @@ import (
 	"time"
 )
 
-// Entry represents a filesystem entry, which can be Directory, File, or Symlink
+// Entry represents a filesystem entry, which can be Directory, File, Symlink or SpecialFile.
 type Entry interface {
 	os.FileInfo
 	Owner() OwnerInfo
@@ -32,6 +32,21 @@ type Reader interface {
 	Entry() (Entry, error)
 }
 
+// Extent describes a range of file contents.
+type Extent struct {
+	Offset int64 `json:"off"`
+	Length int64 `json:"len"`
+}
+
+// SparseReader is implemented by readers of files, which can have holes that don't need to be read.
+type SparseReader interface {
+	Reader
+
+	// DataExtents returns ranges of the file holding data and the file size including holes.
+	// Returns nil extents if the file has no holes.
+	DataExtents() ([]Extent, int64, error)
+}
+
 // File represents an entry that is a file.
 type File interface {
 	Entry
@@ -49,6 +64,12 @@ type Directory interface {
 // ErrEntryNotFound is returned when an entry is not found.
 var ErrEntryNotFound = errors.New("entry not found")
 
+// ErrUnsupportedEntryType is returned when an entry is of a type that cannot be represented.
+var ErrUnsupportedEntryType = errors.New("unsupported entry type")
+
+// ErrSpecialFile is returned when attempting to read contents of a special file (named pipe, socket or device).
+var ErrSpecialFile = errors.New("special file has no contents")
+
 // ReadDirAndFindChild reads all entries from a directory and returns one by name.
 // This is a convenience function that may be helpful in implementations of Directory.Child()
 func ReadDirAndFindChild(ctx context.Context, d Directory, name string) (Entry, error) {
@@ -95,6 +116,90 @@ type Symlink interface {
 	Readlink(ctx context.Context) (string, error)
 }
 
+// ResolvableSymlink is a symbolic link whose target can be returned as an entry.
+type ResolvableSymlink interface {
+	Symlink
+	Resolve(ctx context.Context) (Entry, error)
+}
+
+// DeviceInfo describes major and minor numbers of a device node.
+type DeviceInfo struct {
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+}
+
+// SpecialFile represents an entry that is a named pipe, socket or device node.
+// Only metadata of such entries can be captured, they have no contents.
+type SpecialFile interface {
+	Entry
+	Device() DeviceInfo
+}
+
+// HardLinkID identifies contents of a file with multiple hard links within the file system.
+type HardLinkID struct {
+	Device uint64 `json:"dev"`
+	Inode  uint64 `json:"ino"`
+}
+
+// FileSystemEntry is implemented by entries that can report the identifier of the file system they reside on.
+type FileSystemEntry interface {
+	FileSystemID() (uint64, bool)
+}
+
+// HardLinkedFile is implemented by files which can be hard links to contents shared with other files.
+type HardLinkedFile interface {
+	File
+
+	// HardLinkID returns the identity of the file contents if there's more than one hard link to them.
+	HardLinkID() (HardLinkID, bool)
+}
+
+// ChangeInfo identifies a version of a file beyond its modification time. The status change time is updated
+// by the operating system on every modification, even when the modification time is later restored.
+type ChangeInfo struct {
+	ChangeTime time.Time `json:"ctime"`
+	Inode      uint64    `json:"ino"`
+}
+
+// ChangeInfoEntry is implemented by entries which can report their status change time and inode number.
+type ChangeInfoEntry interface {
+	ChangeInfo() (ChangeInfo, bool)
+}
+
+// ExtendedAttributes maps names of extended attributes of an entry to their values.
+// On Windows named alternate data streams are represented as extended attributes.
+type ExtendedAttributes map[string][]byte
+
+// ExtendedAttributesEntry is implemented by entries which can have extended attributes.
+type ExtendedAttributesEntry interface {
+	Entry
+
+	// ExtendedAttributes returns extended attributes of the entry or nil if it has none.
+	ExtendedAttributes() (ExtendedAttributes, error)
+}
+
+// ACL holds access control lists of an entry. POSIX ACLs are kept in the binary format used by Linux extended
+// attributes, NTFS security descriptors in the SDDL format.
+type ACL struct {
+	Access  []byte `json:"access,omitempty"`
+	Default []byte `json:"default,omitempty"`
+
+	SecurityDescriptor string `json:"sd,omitempty"`
+}
+
+// ACLEntry is implemented by entries which can have access control lists.
+type ACLEntry interface {
+	Entry
+
+	// ACL returns access control lists of the entry or nil if it only has permissions described by its mode.
+	ACL() (*ACL, error)
+}
+
+// IsSpecialFileMode returns true if the provided mode describes a named pipe, socket or device node.
+func IsSpecialFileMode(m os.FileMode) bool {
+	return m&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0
+}
+
 // FindByName returns an entry with a given name, or nil if not found.
 func (e Entries) FindByName(n string) Entry {
 	i := sort.Search(
@@ -0,0 +1,51 @@
+package ignorefs
+
+import (
+	"context"
+	"io"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	// cacheDirTagFile is the name of the file marking cache directories as defined by
+	// the Cache Directory Tagging Specification (https://bford.info/cachedir/).
+	cacheDirTagFile = "CACHEDIR.TAG"
+
+	// cacheDirTagSignature is the header which CACHEDIR.TAG file must begin with.
+	cacheDirTagSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+
+	// noBackupMarkerFile is the name of the file marking directories which should not be backed up.
+	noBackupMarkerFile = ".nobackup"
+)
+
+// isCacheDirectory determines whether the provided directory is marked as not worth backing up.
+func isCacheDirectory(ctx context.Context, dir fs.Directory) bool {
+	if _, ok := child(ctx, dir, noBackupMarkerFile).(fs.File); ok {
+		return true
+	}
+
+	return hasCacheDirTag(ctx, dir)
+}
+
+// hasCacheDirTag checks the header of CACHEDIR.TAG, since the specification requires it to distinguish
+// the tag from unrelated files with the same name.
+func hasCacheDirTag(ctx context.Context, dir fs.Directory) bool {
+	f, ok := child(ctx, dir, cacheDirTagFile).(fs.File)
+	if !ok {
+		return false
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		return false
+	}
+	defer r.Close() //nolint:errcheck
+
+	buf := make([]byte, len(cacheDirTagSignature))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return false
+	}
+
+	return string(buf) == cacheDirTagSignature
+}
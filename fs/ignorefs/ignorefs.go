@@ -4,6 +4,7 @@ package ignorefs
 import (
 	"bufio"
 	"context"
+	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -24,6 +25,14 @@ type ignoreContext struct {
 	dotIgnoreFiles []string         // which files to look for more ignore rules
 	matchers       []ignore.Matcher // current set of rules to ignore files
 	maxFileSize    int64            // maximum size of file allowed
+
+	ignoreSpecialFiles bool // whether to skip named pipes, sockets and devices
+	ignoreRepositories bool // whether to skip excluded local directories and backup repositories
+	ignoreCacheDirs    bool // whether to skip directories tagged with CACHEDIR.TAG or .nobackup
+	oneFileSystem      bool // whether to skip directories on other file systems than their parent
+
+	rootPath     string          // local path of the root directory
+	excludedDirs map[string]bool // local paths of directories skipped when ignoring repositories
 }
 
 func (c *ignoreContext) shouldIncludeByName(path string, e fs.Entry) bool {
@@ -44,6 +53,75 @@ func (c *ignoreContext) shouldIncludeByName(path string, e fs.Entry) bool {
 	return c.parent.shouldIncludeByName(path, e)
 }
 
+func (c *ignoreContext) shouldIncludeByType(path string, e fs.Entry) bool {
+	if _, ok := e.(fs.SpecialFile); !ok || !c.ignoreSpecialFiles {
+		return true
+	}
+
+	for _, oi := range c.onIgnore {
+		oi(path, e)
+	}
+
+	return false
+}
+
+func (c *ignoreContext) shouldIncludeByFileSystem(path string, parent, dir fs.Directory) bool {
+	if !c.oneFileSystem {
+		return true
+	}
+
+	pe, ok := parent.(fs.FileSystemEntry)
+	if !ok {
+		return true
+	}
+
+	de, ok := dir.(fs.FileSystemEntry)
+	if !ok {
+		return true
+	}
+
+	parentID, ok1 := pe.FileSystemID()
+	dirID, ok2 := de.FileSystemID()
+
+	if !ok1 || !ok2 || parentID == dirID {
+		return true
+	}
+
+	for _, oi := range c.onIgnore {
+		oi(path, dir)
+	}
+
+	return false
+}
+
+func (c *ignoreContext) shouldIncludeDirectory(ctx context.Context, path string, dir fs.Directory) bool {
+	if !c.ignoreRepositories {
+		return true
+	}
+
+	if !c.excludedDirs[filepath.Join(c.rootPath, filepath.FromSlash(path))] && !looksLikeRepository(ctx, dir) {
+		return true
+	}
+
+	for _, oi := range c.onIgnore {
+		oi(path, dir)
+	}
+
+	return false
+}
+
+func (c *ignoreContext) shouldIncludeByMarker(ctx context.Context, path string, dir fs.Directory) bool {
+	if !c.ignoreCacheDirs || !isCacheDirectory(ctx, dir) {
+		return true
+	}
+
+	for _, oi := range c.onIgnore {
+		oi(path, dir)
+	}
+
+	return false
+}
+
 type ignoreDirectory struct {
 	relativePath  string
 	parentContext *ignoreContext
@@ -70,11 +148,27 @@ func (d *ignoreDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
 			continue
 		}
 
+		if !thisContext.shouldIncludeByType(d.relativePath+"/"+e.Name(), e) {
+			continue
+		}
+
 		if maxSize := thisContext.maxFileSize; maxSize > 0 && e.Size() > maxSize {
 			continue
 		}
 
 		if dir, ok := e.(fs.Directory); ok {
+			if !thisContext.shouldIncludeByFileSystem(d.relativePath+"/"+e.Name(), d.Directory, dir) {
+				continue
+			}
+
+			if !thisContext.shouldIncludeDirectory(ctx, d.relativePath+"/"+e.Name(), dir) {
+				continue
+			}
+
+			if !thisContext.shouldIncludeByMarker(ctx, d.relativePath+"/"+e.Name(), dir) {
+				continue
+			}
+
 			e = &ignoreDirectory{d.relativePath + "/" + e.Name(), thisContext, d.policyTree.Child(e.Name()), dir}
 		}
 
@@ -110,6 +204,14 @@ func (d *ignoreDirectory) buildContext(ctx context.Context, entries fs.Entries)
 		onIgnore:       d.parentContext.onIgnore,
 		dotIgnoreFiles: effectiveDotIgnoreFiles,
 		maxFileSize:    d.parentContext.maxFileSize,
+
+		ignoreSpecialFiles: d.parentContext.ignoreSpecialFiles,
+		ignoreRepositories: d.parentContext.ignoreRepositories,
+		ignoreCacheDirs:    d.parentContext.ignoreCacheDirs,
+		oneFileSystem:      d.parentContext.oneFileSystem,
+
+		rootPath:     d.parentContext.rootPath,
+		excludedDirs: d.parentContext.excludedDirs,
 	}
 
 	if pol != nil {
@@ -139,6 +241,22 @@ func (c *ignoreContext) overrideFromPolicy(fp policy.FilesPolicy, dirPath string
 		c.maxFileSize = fp.MaxFileSize
 	}
 
+	if fp.IgnoreSpecialFiles != nil {
+		c.ignoreSpecialFiles = *fp.IgnoreSpecialFiles
+	}
+
+	if fp.IgnoreRepositories != nil {
+		c.ignoreRepositories = *fp.IgnoreRepositories
+	}
+
+	if fp.IgnoreCacheDirs != nil {
+		c.ignoreCacheDirs = *fp.IgnoreCacheDirs
+	}
+
+	if fp.OneFileSystem != nil {
+		c.oneFileSystem = *fp.OneFileSystem
+	}
+
 	// append policy-level rules
 	for _, rule := range fp.IgnoreRules {
 		m, err := ignore.ParseGitIgnore(dirPath, rule)
@@ -245,7 +363,27 @@ func New(dir fs.Directory, policyTree *policy.Tree, options ...Option) fs.Direct
 	return &ignoreDirectory{".", rootContext, policyTree, dir}
 }
 
+// ExtendedAttributes returns extended attributes of the underlying directory, if it supports them.
+func (d *ignoreDirectory) ExtendedAttributes() (fs.ExtendedAttributes, error) {
+	if xe, ok := d.Directory.(fs.ExtendedAttributesEntry); ok {
+		return xe.ExtendedAttributes()
+	}
+
+	return nil, nil
+}
+
+// ACL returns access control lists of the underlying directory, if it supports them.
+func (d *ignoreDirectory) ACL() (*fs.ACL, error) {
+	if ae, ok := d.Directory.(fs.ACLEntry); ok {
+		return ae.ACL()
+	}
+
+	return nil, nil
+}
+
 var _ fs.Directory = &ignoreDirectory{}
+var _ fs.ACLEntry = &ignoreDirectory{}
+var _ fs.ExtendedAttributesEntry = &ignoreDirectory{}
 
 // ReportIgnoredFiles returns an Option causing ignorefs to call the provided function whenever a file or directory is ignored.
 func ReportIgnoredFiles(f IgnoreCallback) Option {
@@ -255,3 +393,19 @@ func ReportIgnoredFiles(f IgnoreCallback) Option {
 		}
 	}
 }
+
+// ExcludeLocalDirectories returns an Option causing ignorefs to skip the provided local directories, when the
+// policy ignores repositories. The root directory being wrapped must be located at the provided local path.
+func ExcludeLocalDirectories(rootPath string, dirs ...string) Option {
+	return func(ic *ignoreContext) {
+		ic.rootPath = rootPath
+
+		if ic.excludedDirs == nil {
+			ic.excludedDirs = map[string]bool{}
+		}
+
+		for _, d := range dirs {
+			ic.excludedDirs[filepath.Clean(d)] = true
+		}
+	}
+}
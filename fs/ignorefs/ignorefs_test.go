@@ -79,6 +79,49 @@ var rootAndSrcPolicy = policy.BuildTree(map[string]*policy.Policy{
 	},
 }, policy.DefaultPolicy)
 
+var (
+	trueValue  = true
+	falseValue = false
+)
+
+var ignoreRepositoriesPolicy = policy.BuildTree(map[string]*policy.Policy{
+	".": {
+		FilesPolicy: policy.FilesPolicy{
+			IgnoreRepositories: &trueValue,
+		},
+	},
+}, policy.DefaultPolicy)
+
+func setupRepositories(root *mockfs.Directory) {
+	root.AddDir("kopia-repo", 0).AddFile("kopia.repository.f", dummyFileContents, 0)
+
+	restic := root.AddDir("restic-repo", 0)
+	restic.AddFile("config", dummyFileContents, 0)
+
+	for _, d := range []string{"data", "index", "keys", "snapshots"} {
+		restic.AddDir(d, 0)
+	}
+
+	borg := root.AddDir("borg-repo", 0)
+	borg.AddFileLines("README", []string{"This is a Borg Backup repository.", "See https://borgbackup.readthedocs.io/"}, 0)
+	borg.AddFile("config", dummyFileContents, 0)
+	borg.AddDir("data", 0)
+
+	notBorg := root.AddDir("not-borg-repo", 0)
+	notBorg.AddFileLines("README", []string{"This is a regular project."}, 0)
+	notBorg.AddFile("config", dummyFileContents, 0)
+	notBorg.AddDir("data", 0)
+}
+
+func setupCacheDirs(root *mockfs.Directory) {
+	root.AddDir("browser-cache", 0).AddFileLines("CACHEDIR.TAG", []string{
+		"Signature: 8a477f597d28d172789f06886806bc55",
+		"# This file is a cache directory tag.",
+	}, 0)
+	root.AddDir("build", 0).AddFile(".nobackup", nil, 0)
+	root.AddDir("not-cache", 0).AddFileLines("CACHEDIR.TAG", []string{"Signature: unrelated"}, 0)
+}
+
 var cases = []struct {
 	desc         string
 	policyTree   *policy.Tree
@@ -216,6 +259,96 @@ var cases = []struct {
 			"./src/some-src/f1",
 		},
 	},
+	{
+		desc:       "repositories are ignored",
+		policyTree: ignoreRepositoriesPolicy,
+		setup:      setupRepositories,
+		addedFiles: []string{
+			"./not-borg-repo/",
+			"./not-borg-repo/README",
+			"./not-borg-repo/config",
+			"./not-borg-repo/data/",
+		},
+	},
+	{
+		desc: "repositories are not ignored when disabled by policy",
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					IgnoreRepositories: &falseValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		setup: func(root *mockfs.Directory) {
+			root.AddDir("kopia-repo", 0).AddFile("kopia.repository.f", dummyFileContents, 0)
+		},
+		addedFiles: []string{
+			"./kopia-repo/",
+			"./kopia-repo/kopia.repository.f",
+		},
+	},
+	{
+		desc: "cache directories are ignored",
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					IgnoreCacheDirs: &trueValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		setup: setupCacheDirs,
+		addedFiles: []string{
+			"./not-cache/",
+			"./not-cache/CACHEDIR.TAG",
+		},
+	},
+	{
+		desc: "cache directories are not ignored when disabled by policy",
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					IgnoreCacheDirs: &falseValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		setup: setupCacheDirs,
+		addedFiles: []string{
+			"./browser-cache/",
+			"./browser-cache/CACHEDIR.TAG",
+			"./build/",
+			"./build/.nobackup",
+			"./not-cache/",
+			"./not-cache/CACHEDIR.TAG",
+		},
+	},
+	{
+		desc: "mount points are ignored with one file system",
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					OneFileSystem: &trueValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		setup: func(root *mockfs.Directory) {
+			root.Subdir("pkg").SetFileSystemID(2)
+		},
+		ignoredFiles: []string{
+			"./pkg/",
+			"./pkg/some-pkg",
+		},
+	},
+	{
+		desc: "mount points are included by default",
+		setup: func(root *mockfs.Directory) {
+			root.Subdir("pkg").SetFileSystemID(2)
+		},
+		policyTree: defaultPolicy,
+		ignoredFiles: []string{
+			"./ignored-by-rule",
+			"./largefile1",
+		},
+	},
 }
 
 func TestIgnoreFS(t *testing.T) {
@@ -236,6 +369,26 @@ func TestIgnoreFS(t *testing.T) {
 	}
 }
 
+func TestIgnoreFSExcludeLocalDirectories(t *testing.T) {
+	root := setupFilesystem()
+	originalFiles := walkTree(t, root)
+
+	ifs := ignorefs.New(root, ignoreRepositoriesPolicy, ignorefs.ExcludeLocalDirectories("/home/user", "/home/user/src/some-src", "/home/user/bin/"))
+
+	verifyDirectoryTree(t, ifs, addAndSubtractFiles(originalFiles, nil, []string{
+		"./bin/",
+		"./bin/some-bin",
+		"./src/some-src/",
+		"./src/some-src/f1",
+	}))
+
+	// excluded directories are included when the policy does not ignore repositories.
+	verifyDirectoryTree(t, ignorefs.New(root, defaultPolicy, ignorefs.ExcludeLocalDirectories("/home/user", "/home/user/bin")), addAndSubtractFiles(originalFiles, nil, []string{
+		"./ignored-by-rule",
+		"./largefile1",
+	}))
+}
+
 func addAndSubtractFiles(original, added, removed []string) []string {
 	m := map[string]bool{}
 	for _, ri := range removed {
@@ -0,0 +1,86 @@
+package ignorefs
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// borgReadmePrefix is the beginning of README file that Borg writes to the root of each repository.
+const borgReadmePrefix = "This is a Borg Backup repository."
+
+// repositorySignatures lists names of entries which are all present at the root of a repository.
+var repositorySignatures = []struct {
+	files []string
+	dirs  []string
+}{
+	// Kopia repository in a filesystem storage.
+	{files: []string{"kopia.repository.f"}},
+
+	// restic repository
+	{files: []string{"config"}, dirs: []string{"data", "index", "keys", "snapshots"}},
+}
+
+// looksLikeRepository determines whether the provided directory contains a backup repository.
+func looksLikeRepository(ctx context.Context, dir fs.Directory) bool {
+	for _, sig := range repositorySignatures {
+		if hasEntries(ctx, dir, sig.files, sig.dirs) {
+			return true
+		}
+	}
+
+	return isBorgRepository(ctx, dir)
+}
+
+func hasEntries(ctx context.Context, dir fs.Directory, files, dirs []string) bool {
+	for _, name := range files {
+		if _, ok := child(ctx, dir, name).(fs.File); !ok {
+			return false
+		}
+	}
+
+	for _, name := range dirs {
+		if _, ok := child(ctx, dir, name).(fs.Directory); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isBorgRepository checks the contents of README, since its name and the names of other files in Borg
+// repository are too common to be relied on.
+func isBorgRepository(ctx context.Context, dir fs.Directory) bool {
+	if !hasEntries(ctx, dir, []string{"README", "config"}, []string{"data"}) {
+		return false
+	}
+
+	f, ok := child(ctx, dir, "README").(fs.File)
+	if !ok {
+		return false
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		return false
+	}
+	defer r.Close() //nolint:errcheck
+
+	buf := make([]byte, len(borgReadmePrefix))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(string(buf), borgReadmePrefix)
+}
+
+func child(ctx context.Context, dir fs.Directory, name string) fs.Entry {
+	e, err := dir.Child(ctx, name)
+	if err != nil {
+		return nil
+	}
+
+	return e
+}
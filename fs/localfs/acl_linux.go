@@ -0,0 +1,62 @@
+package localfs
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// names of extended attributes holding POSIX ACLs.
+const (
+	aclAccessAttribute  = "system.posix_acl_access"
+	aclDefaultAttribute = "system.posix_acl_default"
+)
+
+func readACL(path string) (*fs.ACL, error) {
+	access, err := readACLAttribute(path, aclAccessAttribute)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := readACLAttribute(path, aclDefaultAttribute)
+	if err != nil {
+		return nil, err
+	}
+
+	if access == nil && def == nil {
+		return nil, nil
+	}
+
+	return &fs.ACL{Access: access, Default: def}, nil
+}
+
+func readACLAttribute(path, name string) ([]byte, error) {
+	v, err := getExtendedAttribute(path, name)
+
+	switch err {
+	case nil:
+		return v, nil
+	case unix.ENODATA, unix.ENOTSUP:
+		// no ACL or the file system doesn't support them.
+		return nil, nil
+	default:
+		return nil, errors.Wrapf(err, "unable to read %v of %v", name, path)
+	}
+}
+
+func writeACL(path string, acl *fs.ACL) error {
+	if acl.Access != nil {
+		if err := unix.Setxattr(path, aclAccessAttribute, acl.Access, 0); err != nil {
+			return errors.Wrap(err, "unable to set access ACL")
+		}
+	}
+
+	if acl.Default != nil {
+		if err := unix.Setxattr(path, aclDefaultAttribute, acl.Default, 0); err != nil {
+			return errors.Wrap(err, "unable to set default ACL")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+// +build !linux,!windows
+
+package localfs
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func readACL(path string) (*fs.ACL, error) {
+	return nil, nil
+}
+
+func writeACL(path string, acl *fs.ACL) error {
+	return errors.New("POSIX ACLs are not supported on this platform")
+}
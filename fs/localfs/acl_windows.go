@@ -0,0 +1,97 @@
+package localfs
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// parts of NTFS security descriptors which are captured, the SACL is not included since reading it requires privileges.
+const capturedSecurityInformation = windows.OWNER_SECURITY_INFORMATION | windows.GROUP_SECURITY_INFORMATION | windows.DACL_SECURITY_INFORMATION
+
+func readACL(path string) (*fs.ACL, error) {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, capturedSecurityInformation)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get security descriptor of "+path)
+	}
+
+	return &fs.ACL{SecurityDescriptor: sd.String()}, nil
+}
+
+func writeACL(path string, acl *fs.ACL) error {
+	if acl.SecurityDescriptor == "" {
+		return nil
+	}
+
+	sd, err := windows.SecurityDescriptorFromString(acl.SecurityDescriptor)
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor")
+	}
+
+	if err := writeDACL(path, sd); err != nil {
+		return err
+	}
+
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor owner")
+	}
+
+	group, _, err := sd.Group()
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor group")
+	}
+
+	if owner == nil && group == nil {
+		return nil
+	}
+
+	var info windows.SECURITY_INFORMATION
+
+	if owner != nil {
+		info |= windows.OWNER_SECURITY_INFORMATION
+	}
+
+	if group != nil {
+		info |= windows.GROUP_SECURITY_INFORMATION
+	}
+
+	switch err := windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, info, owner, group, nil, nil); err {
+	case nil:
+		return nil
+	case windows.ERROR_ACCESS_DENIED, windows.ERROR_INVALID_OWNER, windows.ERROR_PRIVILEGE_NOT_HELD:
+		// assigning other owners requires privileges, which are usually only held by administrators,
+		// same as os.Chown() failures are ignored on other platforms.
+		return nil
+	default:
+		return errors.Wrap(err, "unable to set owner")
+	}
+}
+
+func writeDACL(path string, sd *windows.SECURITY_DESCRIPTOR) error {
+	dacl, _, err := sd.DACL()
+	if err == windows.ERROR_OBJECT_NOT_FOUND {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor DACL")
+	}
+
+	control, _, err := sd.Control()
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor control")
+	}
+
+	info := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION)
+
+	// preserve whether the DACL inherits entries from the parent directory.
+	if control&windows.SE_DACL_PROTECTED != 0 {
+		info |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+	} else {
+		info |= windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+	}
+
+	return errors.Wrap(windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, info, nil, nil, dacl, nil), "unable to set DACL")
+}
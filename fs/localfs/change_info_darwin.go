@@ -0,0 +1,20 @@
+package localfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func platformSpecificChangeInfo(fi os.FileInfo) *fs.ChangeInfo {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return &fs.ChangeInfo{
+			ChangeTime: time.Unix(stat.Ctimespec.Unix()),
+			Inode:      stat.Ino,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+// +build !linux,!darwin
+
+package localfs
+
+import (
+	"os"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// platformSpecificChangeInfo is not supported, so files can't be compared by their change time and inode number.
+func platformSpecificChangeInfo(fi os.FileInfo) *fs.ChangeInfo {
+	return nil
+}
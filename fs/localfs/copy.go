@@ -3,6 +3,7 @@ package localfs
 import (
 	"context"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -10,8 +11,17 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/iocopy"
 )
 
+// errFileRejected is returned when the file scanner rejects a file, which is then skipped.
+var errFileRejected = errors.New("file rejected")
+
+// FileScanner is invoked for each copied file after its contents have been written to a temporary file at contentPath
+// and before it is moved to targetPath. It returns false to prevent the file from being written, which allows
+// integration with virus scanners or data loss prevention tools. Returning an error aborts the copy.
+type FileScanner func(ctx context.Context, targetPath, contentPath string) (bool, error)
+
 // CopyOptions contains the options for copying a file system tree
 type CopyOptions struct {
 	// If a directory already exists, overwrite the directory.
@@ -20,6 +30,15 @@ type CopyOptions struct {
 	// the copier does not modify already existing files and returns an error
 	// instead.
 	OverwriteFiles bool
+	// ScanFile, if set, is invoked for each file before it is written and can reject it.
+	ScanFile FileScanner
+	// SkipUnchangedFiles skips writing contents of existing files, which have the same size
+	// and modification time as the copied file, only their attributes are updated.
+	SkipUnchangedFiles bool
+	// DeleteExtraneous removes files and directories in the target directories, which don't exist in the copied tree.
+	DeleteExtraneous bool
+	// SkipExtendedAttributes prevents extended attributes from being restored.
+	SkipExtendedAttributes bool
 }
 
 // Copy copies e into targetPath in the local file system. If e is an
@@ -36,13 +55,16 @@ func Copy(ctx context.Context, targetPath string, e fs.Entry, opt CopyOptions) e
 		return err
 	}
 
-	c := copier{CopyOptions: opt}
+	c := copier{CopyOptions: opt, hardLinks: map[fs.HardLinkID]string{}}
 
 	return c.copyEntry(ctx, e, targetPath)
 }
 
 type copier struct {
 	CopyOptions
+
+	// paths of files restored so far by their hard link identity, so that other links to them can be recreated.
+	hardLinks map[fs.HardLinkID]string
 }
 
 func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string) error {
@@ -52,11 +74,24 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string) e
 	case fs.Directory:
 		err = c.copyDirectory(ctx, e, targetPath)
 	case fs.File:
-		err = c.copyFileContent(ctx, targetPath, e)
+		err = c.copyFile(ctx, targetPath, e)
+		if err == errFileRejected {
+			return nil
+		}
 	case fs.Symlink:
-		// Not yet implemented
-		log(ctx).Warningf("Not creating symlink %q from %v", targetPath, e)
-		return nil
+		if err := c.createSymlink(ctx, targetPath, e); err != nil {
+			return err
+		}
+
+		return c.setSymlinkAttributes(ctx, targetPath, e)
+	case fs.SpecialFile:
+		switch err := c.createSpecialFile(ctx, targetPath, e); {
+		case errors.Is(err, os.ErrPermission), errors.Is(err, errSpecialFilesNotSupported):
+			log(ctx).Warningf("Unable to create special file %q (%v): %v", targetPath, e.Mode(), err)
+			return nil
+		case err != nil:
+			return err
+		}
 	default:
 		return errors.Errorf("invalid FS entry type for %q: %#v", targetPath, e)
 	}
@@ -65,11 +100,11 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string) e
 		return err
 	}
 
-	return c.setAttributes(targetPath, e)
+	return c.setAttributes(ctx, targetPath, e)
 }
 
-// set permission, modification time and user/group ids on targetPath
-func (c *copier) setAttributes(targetPath string, e fs.Entry) error {
+// set permission, modification time, user/group ids, extended attributes and ACLs on targetPath
+func (c *copier) setAttributes(ctx context.Context, targetPath string, e fs.Entry) error {
 	const modBits = os.ModePerm | os.ModeSetgid | os.ModeSetuid | os.ModeSticky
 
 	le, err := NewEntry(targetPath)
@@ -84,6 +119,10 @@ func (c *copier) setAttributes(targetPath string, e fs.Entry) error {
 		}
 	}
 
+	// Set extended attributes before permissions and modification time, since writing them may require
+	// write access and on Windows it updates the modification time.
+	xattrsWritten := c.setExtendedAttributes(ctx, targetPath, e)
+
 	// Set file permissions from e
 	if (le.Mode() & modBits) != (e.Mode() & modBits) {
 		if err = os.Chmod(targetPath, e.Mode()&modBits); err != nil && !os.IsPermission(err) {
@@ -91,17 +130,91 @@ func (c *copier) setAttributes(targetPath string, e fs.Entry) error {
 		}
 	}
 
-	// Set mod time from e
-	if !le.ModTime().Equal(e.ModTime()) {
+	// Set mod time from e, unless it's unknown (such as for directories restored by object ID)
+	if !e.ModTime().IsZero() && (xattrsWritten || !le.ModTime().Equal(e.ModTime())) {
 		// Note: Set atime to ModTime as well
 		if err = os.Chtimes(targetPath, e.ModTime(), e.ModTime()); err != nil && !os.IsPermission(err) {
 			return errors.Wrap(err, "could not change mod time on "+targetPath)
 		}
 	}
 
+	c.setACL(ctx, targetPath, e)
+
 	return nil
 }
 
+// set user/group ids and extended attributes on the symlink at targetPath, permissions and modification time
+// of symlinks can't be set portably.
+func (c *copier) setSymlinkAttributes(ctx context.Context, targetPath string, e fs.Entry) error {
+	le, err := NewEntry(targetPath)
+	if err != nil {
+		return errors.Wrap(err, "could not create local FS entry for "+targetPath)
+	}
+
+	if le.Owner() != e.Owner() {
+		if err = os.Lchown(targetPath, int(e.Owner().UserID), int(e.Owner().GroupID)); err != nil && !os.IsPermission(err) {
+			return errors.Wrap(err, "could not change owner/group for "+targetPath)
+		}
+	}
+
+	c.setExtendedAttributes(ctx, targetPath, e)
+
+	return nil
+}
+
+// setExtendedAttributes applies extended attributes of e on targetPath and returns true if any were written.
+// Failures are not fatal, since setting some attributes requires privileges or the target file system
+// may not support them.
+func (c *copier) setExtendedAttributes(ctx context.Context, targetPath string, e fs.Entry) bool {
+	if c.SkipExtendedAttributes {
+		return false
+	}
+
+	xe, ok := e.(fs.ExtendedAttributesEntry)
+	if !ok {
+		return false
+	}
+
+	attrs, err := xe.ExtendedAttributes()
+	if err != nil {
+		log(ctx).Warningf("Unable to read extended attributes for %q: %v", targetPath, err)
+		return false
+	}
+
+	if len(attrs) == 0 {
+		return false
+	}
+
+	if err := writeExtendedAttributes(targetPath, attrs); err != nil {
+		log(ctx).Warningf("Unable to restore extended attributes of %q: %v", targetPath, err)
+	}
+
+	return true
+}
+
+// setACL applies POSIX ACLs of e on targetPath. Like extended attributes, ACLs may not be supported
+// by the target file system, so failures are not fatal.
+func (c *copier) setACL(ctx context.Context, targetPath string, e fs.Entry) {
+	ae, ok := e.(fs.ACLEntry)
+	if !ok {
+		return
+	}
+
+	acl, err := ae.ACL()
+	if err != nil {
+		log(ctx).Warningf("Unable to read ACL for %q: %v", targetPath, err)
+		return
+	}
+
+	if acl == nil {
+		return
+	}
+
+	if err := writeACL(targetPath, acl); err != nil {
+		log(ctx).Warningf("Unable to restore ACL of %q: %v", targetPath, err)
+	}
+}
+
 func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string) error {
 	if err := c.createDirectory(ctx, targetPath); err != nil {
 		return err
@@ -122,6 +235,32 @@ func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targe
 		}
 	}
 
+	if c.DeleteExtraneous {
+		return c.deleteExtraneous(ctx, entries, targetPath)
+	}
+
+	return nil
+}
+
+// deleteExtraneous removes entries of the target directory which are not among the copied entries.
+func (c *copier) deleteExtraneous(ctx context.Context, entries fs.Entries, targetPath string) error {
+	names, err := readDirNames(targetPath)
+	if err != nil {
+		return errors.Wrap(err, "unable to list "+targetPath)
+	}
+
+	for _, n := range names {
+		if entries.FindByName(n) != nil {
+			continue
+		}
+
+		log(ctx).Debugf("deleting extraneous entry: %v", filepath.Join(targetPath, n))
+
+		if err := os.RemoveAll(filepath.Join(targetPath, n)); err != nil {
+			return errors.Wrap(err, "unable to delete extraneous entry")
+		}
+	}
+
 	return nil
 }
 
@@ -146,10 +285,155 @@ func (c *copier) createDirectory(ctx context.Context, path string) error {
 	}
 }
 
+// copyFile copies file contents or recreates a hard link to an already copied file.
+func (c *copier) copyFile(ctx context.Context, targetPath string, f fs.File) error {
+	hl, ok := f.(fs.HardLinkedFile)
+	if !ok {
+		return c.copyFileContent(ctx, targetPath, f)
+	}
+
+	id, ok := hl.HardLinkID()
+	if !ok {
+		return c.copyFileContent(ctx, targetPath, f)
+	}
+
+	if linkedPath, ok := c.hardLinks[id]; ok {
+		switch err := c.createHardLink(ctx, targetPath, linkedPath); {
+		case err == nil:
+			return nil
+		case errors.Is(err, errHardLinkNotCreated):
+			log(ctx).Warningf("Unable to create hard link %q to %q, copying contents instead: %v", targetPath, linkedPath, err)
+		default:
+			return err
+		}
+	}
+
+	if err := c.copyFileContent(ctx, targetPath, f); err != nil {
+		return err
+	}
+
+	c.hardLinks[id] = targetPath
+
+	return nil
+}
+
+// errHardLinkNotCreated is returned when the file system fails to create a hard link.
+var errHardLinkNotCreated = errors.New("hard link not created")
+
+func (c *copier) createHardLink(ctx context.Context, targetPath, linkedPath string) error {
+	switch st, err := os.Lstat(targetPath); {
+	case os.IsNotExist(err): // create hard link below
+	case err != nil:
+		return errors.Wrap(err, "failed to stat "+targetPath)
+	default:
+		if linked, err := os.Stat(linkedPath); err == nil && os.SameFile(st, linked) {
+			log(ctx).Debugf("Not creating already existing hard link: %v", targetPath)
+			return nil
+		}
+
+		if !c.OverwriteFiles || st.IsDir() {
+			return errors.Errorf("unable to create hard link %q, it already exists", targetPath)
+		}
+
+		log(ctx).Debugf("Overwriting existing entry with hard link: %v", targetPath)
+
+		if err := os.Remove(targetPath); err != nil {
+			return errors.Wrap(err, "unable to remove "+targetPath)
+		}
+	}
+
+	log(ctx).Debugf("creating hard link %v -> %v", targetPath, linkedPath)
+
+	if err := os.Link(linkedPath, targetPath); err != nil {
+		return errors.Wrap(errHardLinkNotCreated, err.Error())
+	}
+
+	return nil
+}
+
+func (c *copier) createSymlink(ctx context.Context, targetPath string, sl fs.Symlink) error {
+	target, err := sl.Readlink(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to read symlink target for "+targetPath)
+	}
+
+	switch st, err := os.Lstat(targetPath); {
+	case os.IsNotExist(err): // create symlink below
+	case err != nil:
+		return errors.Wrap(err, "failed to stat "+targetPath)
+	case st.Mode()&os.ModeSymlink != 0:
+		if existing, _ := os.Readlink(targetPath); existing == target {
+			log(ctx).Debugf("Not creating already existing symlink: %v", targetPath)
+			return nil
+		}
+
+		fallthrough
+	default:
+		if !c.OverwriteFiles || st.IsDir() {
+			return errors.Errorf("unable to create symlink %q, it already exists", targetPath)
+		}
+
+		log(ctx).Debugf("Overwriting existing entry with symlink: %v", targetPath)
+
+		if err := os.Remove(targetPath); err != nil {
+			return errors.Wrap(err, "unable to remove "+targetPath)
+		}
+	}
+
+	log(ctx).Debugf("creating symlink %v -> %v", targetPath, target)
+
+	return errors.Wrap(os.Symlink(target, targetPath), "unable to create symlink")
+}
+
+// errSpecialFilesNotSupported is returned when special files can't be created on the current platform.
+var errSpecialFilesNotSupported = errors.New("special files are not supported on this platform")
+
+func (c *copier) createSpecialFile(ctx context.Context, targetPath string, sf fs.SpecialFile) error {
+	switch st, err := os.Lstat(targetPath); {
+	case os.IsNotExist(err): // create special file below
+	case err != nil:
+		return errors.Wrap(err, "failed to stat "+targetPath)
+	default:
+		if le, err := NewEntry(targetPath); err == nil && sameSpecialFile(le, sf) {
+			log(ctx).Debugf("Not creating already existing special file: %v", targetPath)
+			return nil
+		}
+
+		if !c.OverwriteFiles || st.IsDir() {
+			return errors.Errorf("unable to create special file %q, it already exists", targetPath)
+		}
+
+		log(ctx).Debugf("Overwriting existing entry with special file: %v", targetPath)
+
+		if err := os.Remove(targetPath); err != nil {
+			return errors.Wrap(err, "unable to remove "+targetPath)
+		}
+	}
+
+	log(ctx).Debugf("creating special file %v (%v)", targetPath, sf.Mode())
+
+	return errors.Wrap(createSpecialFile(targetPath, sf.Mode(), sf.Device()), "unable to create special file")
+}
+
+// sameSpecialFile returns true if the existing entry is a special file of the same type and device numbers.
+func sameSpecialFile(existing fs.Entry, sf fs.SpecialFile) bool {
+	esf, ok := existing.(fs.SpecialFile)
+	if !ok {
+		return false
+	}
+
+	return esf.Mode()&os.ModeType == sf.Mode()&os.ModeType && esf.Device() == sf.Device()
+}
+
 func (c *copier) copyFileContent(ctx context.Context, targetPath string, f fs.File) error {
-	switch _, err := os.Stat(targetPath); {
+	switch st, err := os.Stat(targetPath); {
 	case os.IsNotExist(err): // copy file below
 	case err == nil:
+		if c.SkipUnchangedFiles && st.Mode().IsRegular() && st.Size() == f.Size() && st.ModTime().Equal(f.ModTime()) {
+			log(ctx).Debugf("Not copying unchanged file: %v", targetPath)
+			return nil
+		}
+
 		if !c.OverwriteFiles {
 			return errors.Errorf("unable to create %q, it already exists", targetPath)
 		}
@@ -167,7 +451,98 @@ func (c *copier) copyFileContent(ctx context.Context, targetPath string, f fs.Fi
 
 	log(ctx).Debugf("copying file contents to: %v", targetPath)
 
-	return atomic.WriteFile(targetPath, r)
+	if sr, ok := r.(fs.SparseReader); ok {
+		extents, size, err := sr.DataExtents()
+		if err != nil {
+			return errors.Wrap(err, "unable to get data extents for "+targetPath)
+		}
+
+		if extents != nil {
+			return c.writeFileContent(ctx, targetPath, func(w *os.File) error {
+				return writeSparseFileContent(w, sr, extents, size)
+			})
+		}
+	}
+
+	if c.ScanFile == nil {
+		return atomic.WriteFile(targetPath, r)
+	}
+
+	return c.writeFileContent(ctx, targetPath, func(w *os.File) error {
+		_, err := iocopy.Copy(w, r)
+		return err
+	})
+}
+
+// writeFileContent writes file contents to a temporary file in the target directory and moves it
+// to the target path, unless rejected by the file scanner.
+func (c *copier) writeFileContent(ctx context.Context, targetPath string, write func(w *os.File) error) error {
+	dir, name := filepath.Split(targetPath)
+
+	tf, err := ioutil.TempFile(dir, "."+name+".kopia-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+
+	tempPath := tf.Name()
+	defer os.Remove(tempPath) //nolint:errcheck
+
+	err = write(tf)
+	if cerr := tf.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to write "+tempPath)
+	}
+
+	if c.ScanFile != nil {
+		ok, err := c.ScanFile(ctx, targetPath, tempPath)
+		if err != nil {
+			return errors.Wrap(err, "unable to scan "+targetPath)
+		}
+
+		if !ok {
+			log(ctx).Warningf("Not restoring %q, it was rejected by the file scanner", targetPath)
+			return errFileRejected
+		}
+	}
+
+	return atomic.ReplaceFile(tempPath, targetPath)
+}
+
+// writeSparseFileContent writes only data extents of a sparse file, leaving holes in the file system.
+func writeSparseFileContent(w *os.File, r fs.SparseReader, extents []fs.Extent, size int64) error {
+	if err := w.Truncate(size); err != nil {
+		return errors.Wrap(err, "unable to set file size")
+	}
+
+	for _, e := range extents {
+		if _, err := r.Seek(e.Offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek in snapshot file")
+		}
+
+		if _, err := w.Seek(e.Offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek in restored file")
+		}
+
+		if _, err := io.CopyN(w, r, e.Length); err != nil {
+			return errors.Wrap(err, "unable to copy data extent")
+		}
+	}
+
+	return nil
+}
+
+func readDirNames(name string) ([]string, error) {
+	f, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	return f.Readdirnames(-1)
 }
 
 func isEmptyDirectory(name string) (bool, error) {
@@ -0,0 +1,97 @@
+package localfs_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestCopyScanFile(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("good", []byte("good content"), 0600)
+	root.AddFile("bad", []byte("EICAR"), 0600)
+	root.AddDir("subdir", 0700).AddFile("bad2", []byte("EICAR again"), 0600)
+
+	dir, err := ioutil.TempDir("", "copy-scan")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var scanned []string
+
+	err = localfs.Copy(ctx, dir, root, localfs.CopyOptions{
+		ScanFile: func(ctx context.Context, targetPath, contentPath string) (bool, error) {
+			scanned = append(scanned, targetPath)
+
+			b, err := ioutil.ReadFile(contentPath)
+			if err != nil {
+				return false, err
+			}
+
+			return !bytes.Contains(b, []byte("EICAR")), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+
+	if got, want := len(scanned), 3; got != want {
+		t.Errorf("unexpected number of scanned files: %v, want %v", got, want)
+	}
+
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "good")); err != nil || string(b) != "good content" {
+		t.Errorf("unexpected contents of accepted file: %q %v", b, err)
+	}
+
+	for _, rejected := range []string{"bad", filepath.Join("subdir", "bad2")} {
+		if _, err := os.Stat(filepath.Join(dir, rejected)); !os.IsNotExist(err) {
+			t.Errorf("rejected file %v was restored: %v", rejected, err)
+		}
+	}
+
+	// no temporary files are left behind.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read directory: %v", err)
+	}
+
+	if got, want := len(entries), 2; got != want {
+		t.Errorf("unexpected number of entries in target directory: %v, want %v", got, want)
+	}
+}
+
+func TestCopyScanFileError(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("file", []byte("content"), 0600)
+
+	dir, err := ioutil.TempDir("", "copy-scan")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = localfs.Copy(ctx, dir, root, localfs.CopyOptions{
+		ScanFile: func(ctx context.Context, targetPath, contentPath string) (bool, error) {
+			return false, errors.New("scanner failed")
+		},
+	})
+	if err == nil {
+		t.Fatalf("expected copy to fail")
+	}
+}
@@ -72,6 +72,19 @@ func (e *filesystemEntry) Owner() fs.OwnerInfo {
 	return e.owner
 }
 
+func (e *filesystemEntry) ExtendedAttributes() (fs.ExtendedAttributes, error) {
+	return readExtendedAttributes(e.fullPath())
+}
+
+func (e *filesystemEntry) ACL() (*fs.ACL, error) {
+	if e.mode&os.ModeSymlink != 0 {
+		// symbolic links don't have ACLs.
+		return nil, nil
+	}
+
+	return readACL(e.fullPath())
+}
+
 var _ os.FileInfo = (*filesystemEntry)(nil)
 
 func newEntry(fi os.FileInfo, parentDir string) filesystemEntry {
@@ -87,6 +100,8 @@ func newEntry(fi os.FileInfo, parentDir string) filesystemEntry {
 
 type filesystemDirectory struct {
 	filesystemEntry
+
+	fileSystemID *uint64
 }
 
 type filesystemSymlink struct {
@@ -95,6 +110,26 @@ type filesystemSymlink struct {
 
 type filesystemFile struct {
 	filesystemEntry
+
+	// set only for files with more than one hard link.
+	hardLink *fs.HardLinkID
+
+	// nil when not supported by the platform.
+	changeInfo *fs.ChangeInfo
+}
+
+type filesystemSpecialFile struct {
+	filesystemEntry
+
+	device fs.DeviceInfo
+}
+
+func (fsd *filesystemDirectory) FileSystemID() (uint64, bool) {
+	if fsd.fileSystemID == nil {
+		return 0, false
+	}
+
+	return *fsd.fileSystemID, true
 }
 
 func (fsd *filesystemDirectory) Size() int64 {
@@ -236,7 +271,11 @@ func (f *fileWithMetadata) Entry() (fs.Entry, error) {
 		return nil, err
 	}
 
-	return &filesystemFile{newEntry(fi, filepath.Dir(f.Name()))}, nil
+	return newFileEntry(fi, filepath.Dir(f.Name())), nil
+}
+
+func (f *fileWithMetadata) DataExtents() ([]fs.Extent, int64, error) {
+	return dataExtents(f.File)
 }
 
 func (fsf *filesystemFile) Open(ctx context.Context) (fs.Reader, error) {
@@ -248,30 +287,57 @@ func (fsf *filesystemFile) Open(ctx context.Context) (fs.Reader, error) {
 	return &fileWithMetadata{f}, nil
 }
 
+func newFileEntry(fi os.FileInfo, parentDir string) *filesystemFile {
+	return &filesystemFile{newEntry(fi, parentDir), platformSpecificHardLinkID(fi), platformSpecificChangeInfo(fi)}
+}
+
+func (fsf *filesystemFile) HardLinkID() (fs.HardLinkID, bool) {
+	if fsf.hardLink == nil {
+		return fs.HardLinkID{}, false
+	}
+
+	return *fsf.hardLink, true
+}
+
+func (fsf *filesystemFile) ChangeInfo() (fs.ChangeInfo, bool) {
+	if fsf.changeInfo == nil {
+		return fs.ChangeInfo{}, false
+	}
+
+	return *fsf.changeInfo, true
+}
+
 func (fsl *filesystemSymlink) Readlink(ctx context.Context) (string, error) {
 	return os.Readlink(fsl.fullPath())
 }
 
-// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink.
-func NewEntry(path string) (fs.Entry, error) {
-	fi, err := os.Lstat(path)
+// Resolve returns the entry the symlink points to, following all symlinks along the way.
+func (fsl *filesystemSymlink) Resolve(ctx context.Context) (fs.Entry, error) {
+	target, err := filepath.EvalSymlinks(fsl.fullPath())
 	if err != nil {
 		return nil, err
 	}
 
-	switch fi.Mode() & os.ModeType {
-	case os.ModeDir:
-		return &filesystemDirectory{newEntry(fi, filepath.Dir(path))}, nil
+	return NewEntry(target)
+}
 
-	case os.ModeSymlink:
-		return &filesystemSymlink{newEntry(fi, filepath.Dir(path))}, nil
+func (fss *filesystemSpecialFile) Size() int64 {
+	// special files have no contents
+	return 0
+}
 
-	case 0:
-		return &filesystemFile{newEntry(fi, filepath.Dir(path))}, nil
+func (fss *filesystemSpecialFile) Device() fs.DeviceInfo {
+	return fss.device
+}
 
-	default:
-		return nil, errors.Errorf("unsupported filesystem entry: %v", fi)
+// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink, fs.SpecialFile.
+func NewEntry(path string) (fs.Entry, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
 	}
+
+	return entryFromChildFileInfo(fi, filepath.Dir(path))
 }
 
 // Directory returns fs.Directory for the specified path.
@@ -291,19 +357,29 @@ func Directory(path string) (fs.Directory, error) {
 func entryFromChildFileInfo(fi os.FileInfo, parentDir string) (fs.Entry, error) {
 	switch fi.Mode() & os.ModeType {
 	case os.ModeDir:
-		return &filesystemDirectory{newEntry(fi, parentDir)}, nil
+		return &filesystemDirectory{newEntry(fi, parentDir), platformSpecificFileSystemID(fi)}, nil
 
 	case os.ModeSymlink:
 		return &filesystemSymlink{newEntry(fi, parentDir)}, nil
 
 	case 0:
-		return &filesystemFile{newEntry(fi, parentDir)}, nil
+		return newFileEntry(fi, parentDir), nil
 
 	default:
-		return nil, errors.Errorf("unsupported filesystem entry: %v", fi)
+		if fs.IsSpecialFileMode(fi.Mode()) {
+			return &filesystemSpecialFile{newEntry(fi, parentDir), platformSpecificDeviceInfo(fi)}, nil
+		}
+
+		return nil, errors.Wrapf(fs.ErrUnsupportedEntryType, "%v (%v)", fi.Name(), fi.Mode())
 	}
 }
 
 var _ fs.Directory = &filesystemDirectory{}
-var _ fs.File = &filesystemFile{}
-var _ fs.Symlink = &filesystemSymlink{}
+var _ fs.SparseReader = &fileWithMetadata{}
+var _ fs.ExtendedAttributesEntry = &filesystemDirectory{}
+var _ fs.ACLEntry = &filesystemDirectory{}
+var _ fs.FileSystemEntry = &filesystemDirectory{}
+var _ fs.HardLinkedFile = &filesystemFile{}
+var _ fs.ChangeInfoEntry = &filesystemFile{}
+var _ fs.ResolvableSymlink = &filesystemSymlink{}
+var _ fs.SpecialFile = &filesystemSpecialFile{}
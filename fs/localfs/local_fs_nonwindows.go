@@ -6,6 +6,8 @@ import (
 	"os"
 	"syscall"
 
+	"golang.org/x/sys/unix"
+
 	"github.com/kopia/kopia/fs"
 )
 
@@ -18,3 +20,35 @@ func platformSpecificOwnerInfo(fi os.FileInfo) fs.OwnerInfo {
 
 	return oi
 }
+
+func platformSpecificHardLinkID(fi os.FileInfo) *fs.HardLinkID {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok && stat.Nlink > 1 {
+		return &fs.HardLinkID{
+			Device: uint64(stat.Dev), //nolint:unconvert
+			Inode:  stat.Ino,
+		}
+	}
+
+	return nil
+}
+
+func platformSpecificFileSystemID(fi os.FileInfo) *uint64 {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		dev := uint64(stat.Dev) //nolint:unconvert
+		return &dev
+	}
+
+	return nil
+}
+
+func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
+	var di fs.DeviceInfo
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		// not making a separate type for 32-bit platforms here..
+		rdev := uint64(stat.Rdev) //nolint:unconvert
+		di.Major = unix.Major(rdev)
+		di.Minor = unix.Minor(rdev)
+	}
+
+	return di
+}
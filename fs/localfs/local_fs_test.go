@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/kopia/kopia/fs"
@@ -140,6 +141,55 @@ func verifyChild(t *testing.T, dir fs.Directory) {
 	}
 }
 
+func TestChangeInfo(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("change info not supported")
+	}
+
+	tmp, err := ioutil.TempDir("", "kopia")
+	assertNoError(t, err)
+
+	defer os.RemoveAll(tmp)
+
+	fname := filepath.Join(tmp, "f")
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assertNoError(t, ioutil.WriteFile(fname, []byte{1, 2, 3}, 0600))
+	assertNoError(t, os.Chtimes(fname, mtime, mtime))
+
+	e1, err := NewEntry(fname)
+	assertNoError(t, err)
+
+	ci1, ok := e1.(fs.ChangeInfoEntry).ChangeInfo()
+	if !ok {
+		t.Fatalf("change info not available")
+	}
+
+	// ensure change time is different even on file systems with coarse timestamps.
+	time.Sleep(1100 * time.Millisecond)
+
+	// modify contents preserving size and modification time.
+	assertNoError(t, ioutil.WriteFile(fname, []byte{4, 5, 6}, 0600))
+	assertNoError(t, os.Chtimes(fname, mtime, mtime))
+
+	e2, err := NewEntry(fname)
+	assertNoError(t, err)
+
+	ci2, _ := e2.(fs.ChangeInfoEntry).ChangeInfo()
+
+	if !e1.ModTime().Equal(e2.ModTime()) || e1.Size() != e2.Size() {
+		t.Fatalf("unexpected metadata change")
+	}
+
+	if ci1.Inode != ci2.Inode {
+		t.Errorf("unexpected inode change: %v, %v", ci1.Inode, ci2.Inode)
+	}
+
+	if !ci2.ChangeTime.After(ci1.ChangeTime) {
+		t.Errorf("change time not updated: %v, %v", ci1.ChangeTime, ci2.ChangeTime)
+	}
+}
+
 func assertNoError(t *testing.T, err error) {
 	t.Helper()
 
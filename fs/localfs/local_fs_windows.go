@@ -9,3 +9,15 @@ import (
 func platformSpecificOwnerInfo(fi os.FileInfo) fs.OwnerInfo {
 	return fs.OwnerInfo{}
 }
+
+func platformSpecificHardLinkID(fi os.FileInfo) *fs.HardLinkID {
+	return nil
+}
+
+func platformSpecificFileSystemID(fi os.FileInfo) *uint64 {
+	return nil
+}
+
+func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
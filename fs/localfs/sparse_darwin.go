@@ -0,0 +1,7 @@
+package localfs
+
+// lseek() whence values for finding data and holes in sparse files.
+const (
+	seekHole = 3
+	seekData = 4
+)
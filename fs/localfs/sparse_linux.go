@@ -0,0 +1,7 @@
+package localfs
+
+// lseek() whence values for finding data and holes in sparse files.
+const (
+	seekData = 3
+	seekHole = 4
+)
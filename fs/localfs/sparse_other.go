@@ -0,0 +1,22 @@
+// +build !linux,!darwin
+
+package localfs
+
+import (
+	"io"
+	"os"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// dataExtents reports all files as having no holes, since there's no portable way of finding them.
+func dataExtents(f *os.File) ([]fs.Extent, int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+
+	return nil, size, err
+}
@@ -0,0 +1,79 @@
+// +build linux darwin
+
+package localfs
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// dataExtents returns ranges of the file holding data, as reported by lseek() with SEEK_DATA and SEEK_HOLE.
+// The file is positioned at the beginning afterwards.
+func dataExtents(f *os.File) ([]fs.Extent, int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to determine file size")
+	}
+
+	defer f.Seek(0, io.SeekStart) //nolint:errcheck
+
+	var (
+		extents []fs.Extent
+		offset  int64
+	)
+
+	for offset < size {
+		start, err := f.Seek(offset, seekData)
+		if isErrno(err, unix.ENXIO) {
+			// no more data until the end of file.
+			break
+		}
+
+		if err != nil {
+			if isErrno(err, unix.EINVAL) {
+				// holes are not supported by the file system.
+				return nil, size, nil
+			}
+
+			return nil, 0, errors.Wrap(err, "unable to find data")
+		}
+
+		end, err := f.Seek(start, seekHole)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "unable to find hole")
+		}
+
+		if end > size {
+			end = size
+		}
+
+		extents = append(extents, fs.Extent{Offset: start, Length: end - start})
+		offset = end
+	}
+
+	if len(extents) == 1 && extents[0].Offset == 0 && extents[0].Length == size {
+		// no holes
+		return nil, size, nil
+	}
+
+	if extents == nil && size > 0 {
+		// the file is one big hole.
+		extents = []fs.Extent{}
+	}
+
+	return extents, size, nil
+}
+
+func isErrno(err error, errno unix.Errno) bool {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+
+	return err == errno
+}
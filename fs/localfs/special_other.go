@@ -0,0 +1,13 @@
+// +build !linux,!darwin
+
+package localfs
+
+import (
+	"os"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func createSpecialFile(path string, mode os.FileMode, device fs.DeviceInfo) error {
+	return errSpecialFilesNotSupported
+}
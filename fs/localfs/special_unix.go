@@ -0,0 +1,27 @@
+// +build linux darwin
+
+package localfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// createSpecialFile creates a named pipe, socket or device node, creating device nodes requires privileges.
+func createSpecialFile(path string, mode os.FileMode, device fs.DeviceInfo) error {
+	perm := uint32(mode.Perm())
+
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return unix.Mkfifo(path, perm)
+	case mode&os.ModeSocket != 0:
+		return unix.Mknod(path, perm|unix.S_IFSOCK, 0)
+	case mode&os.ModeCharDevice != 0:
+		return unix.Mknod(path, perm|unix.S_IFCHR, int(unix.Mkdev(device.Major, device.Minor)))
+	default:
+		return unix.Mknod(path, perm|unix.S_IFBLK, int(unix.Mkdev(device.Major, device.Minor)))
+	}
+}
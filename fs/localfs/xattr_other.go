@@ -0,0 +1,17 @@
+// +build !linux,!darwin,!windows
+
+package localfs
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func readExtendedAttributes(path string) (fs.ExtendedAttributes, error) {
+	return nil, nil
+}
+
+func writeExtendedAttributes(path string, attrs fs.ExtendedAttributes) error {
+	return errors.New("extended attributes are not supported on this platform")
+}
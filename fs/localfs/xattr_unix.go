@@ -0,0 +1,137 @@
+// +build linux darwin
+
+package localfs
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// capturedExtendedAttributePrefixes are namespaces of Linux extended attributes, which are captured.
+// Attributes in 'trusted' and 'system' namespaces are managed by the kernel or require elevated privileges.
+var capturedExtendedAttributePrefixes = []string{"user.", "security."}
+
+func isCapturedExtendedAttribute(name string) bool {
+	if runtime.GOOS != "linux" {
+		// macOS has no namespaces, capture everything including resource forks.
+		return true
+	}
+
+	for _, p := range capturedExtendedAttributePrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readExtendedAttributes(path string) (fs.ExtendedAttributes, error) {
+	names, err := listExtendedAttributes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result fs.ExtendedAttributes
+
+	for _, n := range names {
+		if !isCapturedExtendedAttribute(n) {
+			continue
+		}
+
+		v, err := getExtendedAttribute(path, n)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read extended attribute %q of %v", n, path)
+		}
+
+		if result == nil {
+			result = fs.ExtendedAttributes{}
+		}
+
+		result[n] = v
+	}
+
+	return result, nil
+}
+
+func listExtendedAttributes(path string) ([]string, error) {
+	for {
+		sz, err := unix.Llistxattr(path, nil)
+		if err != nil {
+			if err == unix.ENOTSUP {
+				// file system does not support extended attributes.
+				return nil, nil
+			}
+
+			return nil, errors.Wrap(err, "unable to list extended attributes of "+path)
+		}
+
+		if sz == 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, sz)
+
+		sz, err = unix.Llistxattr(path, buf)
+		if err == unix.ERANGE {
+			// attributes were added in the meantime, try again.
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list extended attributes of "+path)
+		}
+
+		var names []string
+
+		for _, n := range strings.Split(string(buf[:sz]), "\x00") {
+			if n != "" {
+				names = append(names, n)
+			}
+		}
+
+		return names, nil
+	}
+}
+
+func getExtendedAttribute(path, name string) ([]byte, error) {
+	for {
+		sz, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, sz)
+
+		if sz == 0 {
+			return buf, nil
+		}
+
+		sz, err = unix.Lgetxattr(path, name, buf)
+		if err == unix.ERANGE {
+			// value has grown in the meantime, try again.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[:sz], nil
+	}
+}
+
+func writeExtendedAttributes(path string, attrs fs.ExtendedAttributes) error {
+	for n, v := range attrs {
+		if err := unix.Lsetxattr(path, n, v, 0); err != nil {
+			return errors.Wrapf(err, "unable to set extended attribute %q", n)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package localfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// Named alternate data streams of NTFS files and directories are captured as extended attributes,
+// the unnamed stream holds regular file contents. Extended attributes are stored inline in directory
+// manifests, so streams larger than maxAlternateDataStreamSize, which may hold arbitrary amounts of data,
+// are skipped with a warning.
+
+var (
+	modkernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procFindFirstStreamW = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = modkernel32.NewProc("FindNextStreamW")
+)
+
+const (
+	findStreamInfoStandard = 0
+	maxStreamNameLength    = windows.MAX_PATH + 36 //nolint:gomnd
+	dataStreamSuffix       = ":$DATA"
+
+	maxAlternateDataStreamSize = 65536
+)
+
+// win32FindStreamData corresponds to WIN32_FIND_STREAM_DATA.
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [maxStreamNameLength]uint16
+}
+
+func readExtendedAttributes(path string) (fs.ExtendedAttributes, error) {
+	if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSymlink != 0 {
+		// streams of symbolic links can't be accessed without following them.
+		return nil, nil
+	}
+
+	streams, err := listAlternateDataStreams(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result fs.ExtendedAttributes
+
+	for _, n := range streams {
+		if n.size > maxAlternateDataStreamSize {
+			log(context.Background()).Warningf("Skipping alternate data stream %q of %v with %v bytes, exceeding the limit of %v bytes", n.name, path, n.size, maxAlternateDataStreamSize)
+			continue
+		}
+
+		v, err := readAlternateDataStream(path + ":" + n.name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read alternate data stream %q of %v", n.name, path)
+		}
+
+		if int64(len(v)) > maxAlternateDataStreamSize {
+			log(context.Background()).Warningf("Skipping alternate data stream %q of %v, which has grown beyond the limit of %v bytes", n.name, path, maxAlternateDataStreamSize)
+			continue
+		}
+
+		if result == nil {
+			result = fs.ExtendedAttributes{}
+		}
+
+		result[n.name] = v
+	}
+
+	return result, nil
+}
+
+// readAlternateDataStream reads the stream, stopping right after the size limit has been exceeded.
+func readAlternateDataStream(streamPath string) ([]byte, error) {
+	f, err := os.Open(streamPath) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	return ioutil.ReadAll(io.LimitReader(f, maxAlternateDataStreamSize+1))
+}
+
+type alternateDataStream struct {
+	name string
+	size int64
+}
+
+func listAlternateDataStreams(path string) ([]alternateDataStream, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data win32FindStreamData
+
+	h, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(p)), findStreamInfoStandard, uintptr(unsafe.Pointer(&data)), 0)
+	if windows.Handle(h) == windows.InvalidHandle {
+		if err == windows.ERROR_HANDLE_EOF {
+			// no streams, which is typical for directories.
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "unable to list alternate data streams of "+path)
+	}
+
+	defer windows.FindClose(windows.Handle(h)) //nolint:errcheck
+
+	var streams []alternateDataStream
+
+	for {
+		// stream names have the form ':name:$DATA', the unnamed stream is '::$DATA'.
+		if n := strings.TrimSuffix(strings.TrimPrefix(windows.UTF16ToString(data.StreamName[:]), ":"), dataStreamSuffix); n != "" {
+			streams = append(streams, alternateDataStream{n, data.StreamSize})
+		}
+
+		if r, _, err := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data))); r == 0 {
+			if err == windows.ERROR_HANDLE_EOF {
+				return streams, nil
+			}
+
+			return nil, errors.Wrap(err, "unable to list alternate data streams of "+path)
+		}
+	}
+}
+
+func writeExtendedAttributes(path string, attrs fs.ExtendedAttributes) error {
+	if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	for n, v := range attrs {
+		if err := ioutil.WriteFile(path+":"+n, v, 0600); err != nil { //nolint:gomnd
+			return errors.Wrapf(err, "unable to write alternate data stream %q", n)
+		}
+	}
+
+	return nil
+}
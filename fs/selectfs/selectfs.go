@@ -0,0 +1,112 @@
+// Package selectfs implements a wrapper that exposes only an explicitly selected set of paths within a directory.
+package selectfs
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// selection is a node in a tree of selected paths.
+type selection struct {
+	all      bool // entire subtree is selected
+	children map[string]*selection
+}
+
+func (s *selection) add(components []string) {
+	if s.all {
+		return
+	}
+
+	if len(components) == 0 {
+		s.all = true
+		s.children = nil
+
+		return
+	}
+
+	if s.children == nil {
+		s.children = map[string]*selection{}
+	}
+
+	c := s.children[components[0]]
+	if c == nil {
+		c = &selection{}
+		s.children[components[0]] = c
+	}
+
+	c.add(components[1:])
+}
+
+type selectDirectory struct {
+	sel *selection
+
+	fs.Directory
+}
+
+func (d *selectDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	return fs.ReadDirAndFindChild(ctx, d, name)
+}
+
+func (d *selectDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
+	entries, err := d.Directory.Readdir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(fs.Entries, 0, len(d.sel.children))
+
+	for _, e := range entries {
+		childSel := d.sel.children[e.Name()]
+		if childSel == nil {
+			continue
+		}
+
+		if dir, ok := e.(fs.Directory); ok && !childSel.all {
+			e = &selectDirectory{childSel, dir}
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// cleanRelativePath normalizes the provided slash-separated path and ensures it does not escape the root.
+func cleanRelativePath(p string) (string, error) {
+	if strings.HasPrefix(p, "/") {
+		return "", errors.Errorf("path must be relative: %q", p)
+	}
+
+	c := path.Clean(p)
+	if c == ".." || strings.HasPrefix(c, "../") {
+		return "", errors.Errorf("path is outside of the root directory: %q", p)
+	}
+
+	return c, nil
+}
+
+// New returns a Directory that only exposes the provided slash-separated paths relative to the root,
+// along with their parent directories. Selected directories are included with all their contents.
+func New(root fs.Directory, paths []string) (fs.Directory, error) {
+	sel := &selection{}
+
+	for _, p := range paths {
+		c, err := cleanRelativePath(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if c == "." {
+			return root, nil
+		}
+
+		sel.add(strings.Split(c, "/"))
+	}
+
+	return &selectDirectory{sel, root}, nil
+}
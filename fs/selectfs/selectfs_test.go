@@ -0,0 +1,135 @@
+package selectfs_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/selectfs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func setupFilesystem() *mockfs.Directory {
+	root := mockfs.NewDirectory()
+	root.AddFile("file1", []byte("dummy"), 0)
+	root.AddFile("file2", []byte("dummy"), 0)
+
+	d1 := root.AddDir("a", 0)
+	d1.AddFile("f1", []byte("dummy"), 0)
+	d1.AddFile("f2", []byte("dummy"), 0)
+
+	d2 := d1.AddDir("b", 0)
+	d2.AddFile("f3", []byte("dummy"), 0)
+
+	d3 := root.AddDir("c", 0)
+	d3.AddFile("f4", []byte("dummy"), 0)
+	d3.AddDir("d", 0).AddFile("f5", []byte("dummy"), 0)
+
+	return root
+}
+
+func TestSelectFS(t *testing.T) {
+	cases := []struct {
+		desc  string
+		paths []string
+		want  []string
+	}{
+		{
+			desc:  "single file",
+			paths: []string{"file1"},
+			want:  []string{"./file1"},
+		},
+		{
+			desc:  "nested file",
+			paths: []string{"a/b/f3"},
+			want:  []string{"./a/", "./a/b/", "./a/b/f3"},
+		},
+		{
+			desc:  "whole directory",
+			paths: []string{"c", "a/f1"},
+			want:  []string{"./a/", "./a/f1", "./c/", "./c/d/", "./c/d/f5", "./c/f4"},
+		},
+		{
+			desc:  "directory and nested file inside it",
+			paths: []string{"c/d/f5", "c", "./file2"},
+			want:  []string{"./c/", "./c/d/", "./c/d/f5", "./c/f4", "./file2"},
+		},
+		{
+			desc:  "non-existent paths are skipped",
+			paths: []string{"no-such-file", "a/no-such-dir/f1"},
+			want:  []string{"./a/"},
+		},
+		{
+			desc:  "root",
+			paths: []string{"."},
+			want:  []string{"./a/", "./a/b/", "./a/b/f3", "./a/f1", "./a/f2", "./c/", "./c/d/", "./c/d/f5", "./c/f4", "./file1", "./file2"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := testlogging.Context(t)
+
+			d, err := selectfs.New(setupFilesystem(), tc.paths)
+			if err != nil {
+				t.Fatalf("unable to create selectfs: %v", err)
+			}
+
+			got := walkTree(ctx, t, d)
+			if diff := pretty.Compare(got, tc.want); diff != "" {
+				t.Errorf("unexpected files: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSelectFSInvalidPaths(t *testing.T) {
+	for _, p := range []string{"/etc/passwd", "..", "../x", "a/../../x"} {
+		if _, err := selectfs.New(setupFilesystem(), []string{p}); err == nil {
+			t.Errorf("expected error for %q", p)
+		}
+	}
+}
+
+func walkTree(ctx context.Context, t *testing.T, dir fs.Directory) []string {
+	var output []string
+
+	var walk func(path string, d fs.Directory) error
+
+	walk = func(path string, d fs.Directory) error {
+		entries, err := d.Readdir(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			relPath := path + "/" + e.Name()
+
+			if subdir, ok := e.(fs.Directory); ok {
+				output = append(output, relPath+"/")
+
+				if err := walk(relPath, subdir); err != nil {
+					return err
+				}
+			} else {
+				output = append(output, relPath)
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(".", dir); err != nil {
+		t.Fatalf("walk error: %v", err)
+	}
+
+	sort.Strings(output)
+
+	return output
+}
@@ -0,0 +1,180 @@
+// Package virtualfs implements file system entries, which don't exist in any file system.
+package virtualfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// ErrReaderAlreadyUsed is returned when a streaming file is opened more than once.
+var ErrReaderAlreadyUsed = errors.New("stream has already been read")
+
+// defaultStreamPermissions are permissions of streaming files.
+const defaultStreamPermissions = 0644
+
+// streamingFile is a file whose contents are read from a stream, which can only be read once.
+type streamingFile struct {
+	name    string
+	modTime time.Time
+
+	mu     sync.Mutex
+	r      io.Reader
+	size   int64
+	opened bool
+}
+
+func (f *streamingFile) Name() string {
+	return f.name
+}
+
+func (f *streamingFile) IsDir() bool {
+	return false
+}
+
+func (f *streamingFile) Mode() os.FileMode {
+	return defaultStreamPermissions
+}
+
+// Size returns the number of bytes read from the stream so far.
+func (f *streamingFile) Size() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.size
+}
+
+func (f *streamingFile) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *streamingFile) Sys() interface{} {
+	return nil
+}
+
+func (f *streamingFile) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (f *streamingFile) Open(ctx context.Context) (fs.Reader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.opened {
+		return nil, ErrReaderAlreadyUsed
+	}
+
+	f.opened = true
+
+	return &streamingFileReader{f}, nil
+}
+
+type streamingFileReader struct {
+	f *streamingFile
+}
+
+func (r *streamingFileReader) Read(b []byte) (int, error) {
+	n, err := r.f.r.Read(b)
+
+	r.f.mu.Lock()
+	r.f.size += int64(n)
+	r.f.mu.Unlock()
+
+	return n, err
+}
+
+func (r *streamingFileReader) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("streaming file is not seekable")
+}
+
+func (r *streamingFileReader) Close() error {
+	return nil
+}
+
+func (r *streamingFileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+// StreamingFileFromReader returns a file with the provided name, whose contents are read from the reader.
+// The file can only be opened once and its size is only known after its contents have been read.
+func StreamingFileFromReader(name string, r io.Reader, modTime time.Time) fs.File {
+	return &streamingFile{name: name, r: r, modTime: modTime}
+}
+
+// defaultDirectoryPermissions are permissions of static directories.
+const defaultDirectoryPermissions = 0755
+
+// staticDirectory is a directory with a fixed list of entries.
+type staticDirectory struct {
+	name    string
+	entries fs.Entries
+}
+
+func (d *staticDirectory) Name() string {
+	return d.name
+}
+
+func (d *staticDirectory) IsDir() bool {
+	return true
+}
+
+func (d *staticDirectory) Mode() os.FileMode {
+	return os.ModeDir | defaultDirectoryPermissions
+}
+
+func (d *staticDirectory) Size() int64 {
+	return 0
+}
+
+// ModTime returns the latest modification time of the directory entries.
+func (d *staticDirectory) ModTime() time.Time {
+	var t time.Time
+
+	for _, e := range d.entries {
+		if mt := e.ModTime(); mt.After(t) {
+			t = mt
+		}
+	}
+
+	return t
+}
+
+func (d *staticDirectory) Sys() interface{} {
+	return nil
+}
+
+func (d *staticDirectory) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (d *staticDirectory) Summary() *fs.DirectorySummary {
+	return nil
+}
+
+func (d *staticDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	return fs.ReadDirAndFindChild(ctx, d, name)
+}
+
+func (d *staticDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
+	return append(fs.Entries(nil), d.entries...), nil
+}
+
+// NewStaticDirectory returns a directory with the provided name and entries, which can be any fs.Entry
+// implementations including other virtual entries, so that sources not backed by a local file system
+// can be snapshotted.
+func NewStaticDirectory(name string, entries []fs.Entry) fs.Directory {
+	sorted := append(fs.Entries(nil), entries...)
+	sorted.Sort()
+
+	return &staticDirectory{name: name, entries: sorted}
+}
+
+var _ fs.File = (*streamingFile)(nil)
+var _ fs.Reader = (*streamingFileReader)(nil)
+var _ fs.Directory = (*staticDirectory)(nil)
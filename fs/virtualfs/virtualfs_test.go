@@ -0,0 +1,55 @@
+package virtualfs_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestStreamingFile(t *testing.T) {
+	ctx := testlogging.Context(t)
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := virtualfs.StreamingFileFromReader("db.sql", strings.NewReader("some contents"), modTime)
+
+	if got, want := f.Name(), "db.sql"; got != want {
+		t.Errorf("unexpected name: %v, want %v", got, want)
+	}
+
+	if got, want := f.ModTime(), modTime; !got.Equal(want) {
+		t.Errorf("unexpected modification time: %v, want %v", got, want)
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(b), "some contents"; got != want {
+		t.Errorf("unexpected contents: %q, want %q", got, want)
+	}
+
+	e, err := r.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := e.Size(), int64(len(b)); got != want {
+		t.Errorf("unexpected size: %v, want %v", got, want)
+	}
+
+	if _, err := f.Open(ctx); !errors.Is(err, virtualfs.ErrReaderAlreadyUsed) {
+		t.Errorf("unexpected error when opening again: %v", err)
+	}
+}
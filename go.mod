@@ -6,11 +6,12 @@ require (
 	bazil.org/fuse v0.0.0-20200117225306-7b5117fecadc
 	cloud.google.com/go/storage v1.8.0
 	contrib.go.opencensus.io/exporter/prometheus v0.1.0
-	github.com/Azure/azure-pipeline-go v0.2.2 // indirect
+	github.com/Azure/azure-pipeline-go v0.2.2
 	github.com/Azure/azure-storage-blob-go v0.8.0
 	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d // indirect
 	github.com/aws/aws-sdk-go v1.31.3
 	github.com/bgentry/speakeasy v0.1.0
+	github.com/ceph/go-ceph v0.4.0
 	github.com/chmduquesne/rollinghash v4.0.0+incompatible
 	github.com/efarrer/iothrottler v0.0.1
 	github.com/fatih/color v1.9.0
@@ -20,6 +21,7 @@ require (
 	github.com/google/readahead v0.0.0-20161222183148-eaceba169032 // indirect
 	github.com/google/wire v0.4.0 // indirect
 	github.com/gorilla/mux v1.7.4
+	github.com/grpc-ecosystem/grpc-gateway v1.14.6 // indirect
 	github.com/klauspost/compress v1.10.6
 	github.com/klauspost/pgzip v1.2.4
 	github.com/kylelemons/godebug v1.1.0
@@ -47,6 +49,7 @@ require (
 	golang.org/x/net v0.0.0-20200520182314-0ba52f642ac2
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
+	golang.org/x/sys v0.0.0-20200511232937-7e40ca221e25
 	golang.org/x/tools v0.0.0-20200521155704-91d71f6c2f04 // indirect
 	google.golang.org/api v0.25.0
 	google.golang.org/protobuf v1.23.0
@@ -7,7 +7,7 @@ import Row from 'react-bootstrap/Row';
 import Spinner from 'react-bootstrap/Spinner';
 import { Link } from "react-router-dom";
 import MyTable from './Table';
-import { compare, objectLink, parseQuery, rfc3339TimestampForDisplay, sizeWithFailures } from './uiutil';
+import { compare, objectLink, parseQuery, rfc3339TimestampForDisplay, sizeDisplayName, sizeWithFailures } from './uiutil';
 
 function pillVariant(tag) {
     if (tag.startsWith("latest-")) {
@@ -28,6 +28,16 @@ function pillVariant(tag) {
     return "primary";
 }
 
+function classificationSummary(c) {
+    if (!c || !c.categories) {
+        return "";
+    }
+
+    return Object.keys(c.categories).
+        sort((a, b) => -compare(c.categories[a].size, c.categories[b].size)).
+        map(k => <span title={c.categories[k].files + " files"}>{k}: {sizeDisplayName(c.categories[k].size)}{' '}</span>);
+}
+
 export class SnapshotsTable extends Component {
     constructor() {
         super();
@@ -141,6 +151,11 @@ export class SnapshotsTable extends Component {
             Header: 'Dirs',
             accessor: 'summary.dirs',
             width: 100,
+        }, {
+            id: 'classification',
+            Header: 'Breakdown',
+            width: "",
+            accessor: x => classificationSummary(x.classification),
         }]
 
         return <div class="padded">
@@ -30,28 +30,34 @@ type KopiaAPIClient struct {
 // Get is a helper that performs HTTP GET on a URL with the specified suffix and decodes the response
 // onto respPayload which must be a pointer to byte slice or JSON-serializable structure.
 func (c *KopiaAPIClient) Get(ctx context.Context, urlSuffix string, onNotFound error, respPayload interface{}) error {
-	return c.runRequest(ctx, http.MethodGet, c.BaseURL+urlSuffix, onNotFound, nil, respPayload)
+	return c.runRequest(ctx, http.MethodGet, c.BaseURL+urlSuffix, onNotFound, nil, nil, respPayload)
 }
 
 // Post is a helper that performs HTTP POST on a URL with the specified body from reqPayload and decodes the response
 // onto respPayload which must be a pointer to byte slice or JSON-serializable structure.
 func (c *KopiaAPIClient) Post(ctx context.Context, urlSuffix string, reqPayload, respPayload interface{}) error {
-	return c.runRequest(ctx, http.MethodPost, c.BaseURL+urlSuffix, nil, reqPayload, respPayload)
+	return c.runRequest(ctx, http.MethodPost, c.BaseURL+urlSuffix, nil, nil, reqPayload, respPayload)
+}
+
+// PostConditional is a helper that performs HTTP POST like Post, but returns onConflict when the server
+// responds with HTTP 409 Conflict.
+func (c *KopiaAPIClient) PostConditional(ctx context.Context, urlSuffix string, onConflict error, reqPayload, respPayload interface{}) error {
+	return c.runRequest(ctx, http.MethodPost, c.BaseURL+urlSuffix, nil, onConflict, reqPayload, respPayload)
 }
 
 // Put is a helper that performs HTTP PUT on a URL with the specified body from reqPayload and decodes the response
 // onto respPayload which must be a pointer to byte slice or JSON-serializable structure.
 func (c *KopiaAPIClient) Put(ctx context.Context, urlSuffix string, reqPayload, respPayload interface{}) error {
-	return c.runRequest(ctx, http.MethodPut, c.BaseURL+urlSuffix, nil, reqPayload, respPayload)
+	return c.runRequest(ctx, http.MethodPut, c.BaseURL+urlSuffix, nil, nil, reqPayload, respPayload)
 }
 
 // Delete is a helper that performs HTTP DELETE on a URL with the specified body from reqPayload and decodes the response
 // onto respPayload which must be a pointer to byte slice or JSON-serializable structure.
 func (c *KopiaAPIClient) Delete(ctx context.Context, urlSuffix string, reqPayload, respPayload interface{}) error {
-	return c.runRequest(ctx, http.MethodDelete, c.BaseURL+urlSuffix, nil, reqPayload, respPayload)
+	return c.runRequest(ctx, http.MethodDelete, c.BaseURL+urlSuffix, nil, nil, reqPayload, respPayload)
 }
 
-func (c *KopiaAPIClient) runRequest(ctx context.Context, method, url string, notFoundError error, reqPayload, respPayload interface{}) error {
+func (c *KopiaAPIClient) runRequest(ctx context.Context, method, url string, notFoundError, conflictError error, reqPayload, respPayload interface{}) error {
 	payload, contentType, err := requestReader(reqPayload)
 	if err != nil {
 		return err
@@ -77,6 +83,10 @@ func (c *KopiaAPIClient) runRequest(ctx context.Context, method, url string, not
 		return notFoundError
 	}
 
+	if resp.StatusCode == http.StatusConflict && conflictError != nil {
+		return conflictError
+	}
+
 	return decodeResponse(resp, respPayload)
 }
 
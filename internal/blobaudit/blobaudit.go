@@ -0,0 +1,254 @@
+// Package blobaudit implements signed manifests of all blobs in a repository, which allow third parties
+// with access to the storage, but without repository credentials, to verify that no blob was removed or altered.
+//
+// Blobs deleted by kopia itself, such as by maintenance, are recorded in signed deletion records once
+// the first manifest has been created and are not reported as missing. Blobs which kopia rewrites in place,
+// such as the format blob, are not included in manifests.
+package blobaudit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/parallelwork"
+	"github.com/kopia/kopia/repo/audit"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var signingKeyPurpose = []byte("BLOB-AUDIT")
+
+// ErrInvalidSignature is returned when the manifest signature does not match its contents or the public key.
+var ErrInvalidSignature = errors.New("invalid blob manifest signature")
+
+// rewrittenBlobIDs are blobs which kopia legitimately rewrites in place, which are not included in manifests.
+var rewrittenBlobIDs = map[blob.ID]bool{
+	"kopia.repository":  true,
+	"kopia.maintenance": true,
+	audit.HeadBlobID:    true,
+}
+
+// isAudited returns true if the blob is included in manifests.
+func isAudited(id blob.ID) bool {
+	return !rewrittenBlobIDs[id] && !strings.HasPrefix(string(id), string(BlobIDPrefix))
+}
+
+// Entry describes a single blob in the manifest.
+type Entry struct {
+	BlobID blob.ID `json:"id"`
+	Length int64   `json:"length"`
+	SHA256 string  `json:"sha256"`
+}
+
+// Manifest is the signed list of blobs in the storage at the time it was created.
+type Manifest struct {
+	Created   time.Time `json:"created"`
+	Blobs     []Entry   `json:"blobs"`
+	PublicKey []byte    `json:"publicKey"`
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+// Report is the result of verifying the storage against the manifest.
+type Report struct {
+	Verified int       `json:"verified"`
+	Missing  []blob.ID `json:"missing,omitempty"`
+	Altered  []blob.ID `json:"altered,omitempty"`
+	Added    []blob.ID `json:"added,omitempty"`
+
+	// Blobs from the manifest whose deletion was recorded by kopia.
+	Deleted []blob.ID `json:"deleted,omitempty"`
+}
+
+// SigningKey returns the key signing blob manifests of the repository, which is derived from its master key,
+// so that the public key remains the same and can be given to the auditor once.
+func SigningKey(deriveKey func(purpose []byte, keyLength int) []byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(deriveKey(signingKeyPurpose, ed25519.SeedSize))
+}
+
+// Create lists and hashes all blobs in the storage using the provided number of parallel workers
+// and returns the manifest signed with the provided key. Recording of deletions is enabled before
+// the blobs are listed.
+func Create(ctx context.Context, st blob.Storage, key ed25519.PrivateKey, now time.Time, parallel int) (*Manifest, error) {
+	m := &Manifest{
+		Created:   now.UTC(),
+		PublicKey: key.Public().(ed25519.PublicKey),
+	}
+
+	if err := st.PutBlob(ctx, EnabledBlobID, gather.FromSlice(m.PublicKey)); err != nil {
+		return nil, errors.Wrap(err, "unable to enable recording of deletions")
+	}
+
+	var mu sync.Mutex
+
+	q := parallelwork.NewQueue()
+
+	if err := st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if !isAudited(bm.BlobID) {
+			return nil
+		}
+
+		q.EnqueueBack(func() error {
+			h, err := hashBlob(ctx, st, bm.BlobID)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			m.Blobs = append(m.Blobs, Entry{bm.BlobID, bm.Length, h})
+			mu.Unlock()
+
+			return nil
+		})
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to list blobs")
+	}
+
+	if err := q.Process(parallel); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(m.Blobs, func(i, j int) bool {
+		return m.Blobs[i].BlobID < m.Blobs[j].BlobID
+	})
+
+	b, err := m.signedData()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Signature = ed25519.Sign(key, b)
+
+	return m, nil
+}
+
+// VerifySignature verifies that the manifest was signed with the private key of the provided public key.
+func (m *Manifest) VerifySignature(pub ed25519.PublicKey) error {
+	if !pub.Equal(ed25519.PublicKey(m.PublicKey)) {
+		return errors.Wrap(ErrInvalidSignature, "manifest was signed by a different key")
+	}
+
+	b, err := m.signedData()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pub, b, m.Signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signedData returns the manifest contents excluding the signature.
+func (m *Manifest) signedData() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+
+	b, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize manifest")
+	}
+
+	return b, nil
+}
+
+// Verify checks that all blobs in the manifest exist in the storage with the same contents
+// using the provided number of parallel workers. Blobs added since the manifest was created are reported, but expected.
+// Blobs whose deletion was recorded by kopia are reported as deleted instead of missing. The signature of the manifest
+// must be verified before.
+func Verify(ctx context.Context, st blob.Storage, m *Manifest, parallel int) (*Report, error) {
+	existing := map[blob.ID]bool{}
+
+	if err := st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if isAudited(bm.BlobID) {
+			existing[bm.BlobID] = true
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to list blobs")
+	}
+
+	deleted, err := loadDeletedBlobs(ctx, st, ed25519.PublicKey(m.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu sync.Mutex
+		r  = &Report{}
+	)
+
+	q := parallelwork.NewQueue()
+
+	for _, e := range m.Blobs {
+		e := e
+
+		if !existing[e.BlobID] {
+			if deleted[e.BlobID] {
+				r.Deleted = append(r.Deleted, e.BlobID)
+			} else {
+				r.Missing = append(r.Missing, e.BlobID)
+			}
+
+			continue
+		}
+
+		delete(existing, e.BlobID)
+
+		q.EnqueueBack(func() error {
+			h, err := hashBlob(ctx, st, e.BlobID)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if h == e.SHA256 {
+				r.Verified++
+			} else {
+				r.Altered = append(r.Altered, e.BlobID)
+			}
+
+			return nil
+		})
+	}
+
+	if err := q.Process(parallel); err != nil {
+		return nil, err
+	}
+
+	for id := range existing {
+		r.Added = append(r.Added, id)
+	}
+
+	for _, ids := range [][]blob.ID{r.Missing, r.Altered, r.Added, r.Deleted} {
+		ids := ids
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+
+	return r, nil
+}
+
+func hashBlob(ctx context.Context, st blob.Storage, id blob.ID) (string, error) {
+	b, err := st.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read blob %v", id)
+	}
+
+	h := sha256.Sum256(b)
+
+	return hex.EncodeToString(h[:]), nil
+}
@@ -0,0 +1,152 @@
+package blobaudit_test
+
+import (
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobaudit"
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type fakeKeyDeriver struct{}
+
+func (fakeKeyDeriver) DeriveKey(purpose []byte, keyLength int) []byte {
+	return make([]byte, keyLength)
+}
+
+func TestManifest(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	for _, id := range []blob.ID{"a1", "a2", "b1", "c1"} {
+		if err := st.PutBlob(ctx, id, gather.FromSlice([]byte("contents of "+string(id)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := blobaudit.SigningKey(fakeKeyDeriver{}.DeriveKey)
+	pub := key.Public().(ed25519.PublicKey)
+
+	m, err := blobaudit.Create(ctx, st, key, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), 2)
+	if err != nil {
+		t.Fatalf("unable to create manifest: %v", err)
+	}
+
+	if got, want := len(m.Blobs), 4; got != want {
+		t.Fatalf("unexpected number of blobs: %v, want %v", got, want)
+	}
+
+	if err = m.VerifySignature(pub); err != nil {
+		t.Fatalf("invalid signature: %v", err)
+	}
+
+	r, err := blobaudit.Verify(ctx, st, m, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (&blobaudit.Report{Verified: 4}); !reflect.DeepEqual(r, want) {
+		t.Errorf("unexpected report: %v, want %v", r, want)
+	}
+
+	// tamper with the storage
+	data["a2"] = []byte("altered")
+	delete(data, "b1")
+	data["d1"] = []byte("added")
+
+	r, err = blobaudit.Verify(ctx, st, m, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &blobaudit.Report{
+		Verified: 2,
+		Missing:  []blob.ID{"b1"},
+		Altered:  []blob.ID{"a2"},
+		Added:    []blob.ID{"d1"},
+	}
+
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("unexpected report: %v, want %v", r, want)
+	}
+
+	// tamper with the manifest
+	m.Blobs = m.Blobs[1:]
+
+	if err = m.VerifySignature(pub); !errors.Is(err, blobaudit.ErrInvalidSignature) {
+		t.Errorf("unexpected error for altered manifest: %v", err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	if err = m.VerifySignature(otherPub); !errors.Is(err, blobaudit.ErrInvalidSignature) {
+		t.Errorf("unexpected error for different key: %v", err)
+	}
+}
+
+func TestDeletionRecords(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	for _, id := range []blob.ID{"a1", "a2", "a3", "kopia.repository"} {
+		if err := st.PutBlob(ctx, id, gather.FromSlice([]byte("contents of "+string(id)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := blobaudit.SigningKey(fakeKeyDeriver{}.DeriveKey)
+	now := func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	// deletions made before blob audit is enabled are not recorded.
+	if err := blobaudit.NewDeletionRecorder(st, key, now).DeleteBlob(ctx, "a3"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := blobaudit.Create(ctx, st, key, now(), 2)
+	if err != nil {
+		t.Fatalf("unable to create manifest: %v", err)
+	}
+
+	// blobs rewritten in place are not included.
+	if got, want := len(m.Blobs), 2; got != want {
+		t.Fatalf("unexpected number of blobs: %v, want %v", got, want)
+	}
+
+	data["kopia.repository"] = []byte("rewritten")
+
+	if err := blobaudit.NewDeletionRecorder(st, key, now).DeleteBlob(ctx, "a1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// deletion recorded with a different key is not trusted.
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	if err := blobaudit.NewDeletionRecorder(st, otherKey, now).DeleteBlob(ctx, "a2"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := blobaudit.Verify(ctx, st, m, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &blobaudit.Report{
+		Missing: []blob.ID{"a2"},
+		Deleted: []blob.ID{"a1"},
+	}
+
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("unexpected report: %v, want %v", r, want)
+	}
+}
@@ -0,0 +1,202 @@
+package blobaudit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// BlobIDPrefix is the prefix of blobs written by the blob audit, which are not included in manifests.
+const BlobIDPrefix blob.ID = "kopia.blobaudit"
+
+// EnabledBlobID is the blob written when the first manifest is created, which enables recording of deletions.
+const EnabledBlobID = BlobIDPrefix + "-enabled"
+
+// DeletionRecordBlobIDPrefix is the prefix of blobs recording deletions of blobs made by kopia.
+const DeletionRecordBlobIDPrefix = BlobIDPrefix + ".deleted."
+
+// DeletionRecord describes blobs deleted by kopia, such as by maintenance. It's signed with the same key
+// as manifests, so that the auditor can distinguish them from blobs removed by someone else.
+type DeletionRecord struct {
+	Time      time.Time `json:"time"`
+	Blobs     []blob.ID `json:"blobs"`
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+func (r *DeletionRecord) signedData() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+
+	b, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize deletion record")
+	}
+
+	return b, nil
+}
+
+// deletionRecorder writes a signed deletion record before each blob is deleted, once the blob audit
+// has been enabled for the storage.
+type deletionRecorder struct {
+	base    blob.Storage
+	key     ed25519.PrivateKey
+	timeNow func() time.Time
+
+	mu      sync.Mutex
+	checked bool // whether the blob audit was checked to be enabled
+	enabled bool
+}
+
+func (s *deletionRecorder) isEnabled(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checked {
+		return s.enabled, nil
+	}
+
+	_, err := s.base.GetMetadata(ctx, EnabledBlobID)
+
+	switch err {
+	case nil:
+		s.enabled = true
+	case blob.ErrBlobNotFound:
+		s.enabled = false
+	default:
+		return false, errors.Wrap(err, "unable to determine whether blob audit is enabled")
+	}
+
+	s.checked = true
+
+	return s.enabled, nil
+}
+
+func (s *deletionRecorder) DeleteBlob(ctx context.Context, id blob.ID) error {
+	enabled, err := s.isEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		if err := s.recordDeletion(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return s.base.DeleteBlob(ctx, id)
+}
+
+// recordDeletion writes the signed record before the blob is deleted, so that the deletion is never unrecorded.
+func (s *deletionRecorder) recordDeletion(ctx context.Context, id blob.ID) error {
+	r := &DeletionRecord{
+		Time:  s.timeNow().UTC(),
+		Blobs: []blob.ID{id},
+	}
+
+	b, err := r.signedData()
+	if err != nil {
+		return err
+	}
+
+	r.Signature = ed25519.Sign(s.key, b)
+
+	v, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize deletion record")
+	}
+
+	suffix := make([]byte, 16) //nolint:gomnd
+	if _, err := rand.Read(suffix); err != nil {
+		return errors.Wrap(err, "unable to generate deletion record ID")
+	}
+
+	recordID := DeletionRecordBlobIDPrefix + blob.ID(hex.EncodeToString(suffix))
+
+	return errors.Wrap(s.base.PutBlob(ctx, recordID, gather.FromSlice(v)), "unable to write deletion record")
+}
+
+func (s *deletionRecorder) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *deletionRecorder) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	return s.base.GetBlob(ctx, id, offset, length)
+}
+
+func (s *deletionRecorder) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *deletionRecorder) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *deletionRecorder) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *deletionRecorder) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *deletionRecorder) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+// NewDeletionRecorder returns a Storage wrapper that records deletions of blobs in records signed with
+// the provided key, once the blob audit has been enabled by creating a manifest. Whether it's enabled
+// is checked once, before the first deletion.
+func NewDeletionRecorder(st blob.Storage, key ed25519.PrivateKey, timeNow func() time.Time) blob.Storage {
+	return &deletionRecorder{base: st, key: key, timeNow: timeNow}
+}
+
+// loadDeletedBlobs returns blobs deleted according to deletion records signed with the provided key.
+// Records with invalid signatures are ignored.
+func loadDeletedBlobs(ctx context.Context, st blob.Storage, pub ed25519.PublicKey) (map[blob.ID]bool, error) {
+	deleted := map[blob.ID]bool{}
+
+	var recordIDs []blob.ID
+
+	if err := st.ListBlobs(ctx, DeletionRecordBlobIDPrefix, func(bm blob.Metadata) error {
+		recordIDs = append(recordIDs, bm.BlobID)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to list deletion records")
+	}
+
+	for _, id := range recordIDs {
+		v, err := st.GetBlob(ctx, id, 0, -1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read deletion record %v", id)
+		}
+
+		r := &DeletionRecord{}
+		if err := json.Unmarshal(v, r); err != nil {
+			continue
+		}
+
+		b, err := r.signedData()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ed25519.Verify(pub, b, r.Signature) {
+			continue
+		}
+
+		for _, d := range r.Blobs {
+			deleted[d] = true
+		}
+	}
+
+	return deleted, nil
+}
@@ -90,6 +90,15 @@ func AssertGetBlobNotFound(ctx context.Context, t testingT, s blob.Storage, blob
 	}
 }
 
+// AssertGetMetadataNotFound asserts that GetMetadata() for specified blobID returns ErrNotFound.
+func AssertGetMetadataNotFound(ctx context.Context, t testingT, s blob.Storage, blobID blob.ID) {
+	t.Helper()
+
+	if _, err := s.GetMetadata(ctx, blobID); err != blob.ErrBlobNotFound {
+		t.Errorf("GetMetadata(%v) returned %v but expected ErrNotFound", blobID, err)
+	}
+}
+
 // AssertListResults asserts that the list results with given prefix return the specified list of names in order.
 func AssertListResults(ctx context.Context, t testingT, s blob.Storage, prefix blob.ID, want ...blob.ID) {
 	t.Helper()
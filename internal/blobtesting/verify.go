@@ -27,6 +27,7 @@ func VerifyStorage(ctx context.Context, t testingT, r blob.Storage) {
 	// First verify that blocks don't exist.
 	for _, b := range blocks {
 		AssertGetBlobNotFound(ctx, t, r, b.blk)
+		AssertGetMetadataNotFound(ctx, t, r, b.blk)
 	}
 
 	// Now add blocks.
@@ -0,0 +1,407 @@
+package diff
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/repo/object"
+)
+
+const (
+	// whiteoutPrefix is prepended to the name of an entry that must be removed when applying the bundle.
+	whiteoutPrefix = ".wh."
+
+	// paxAttributesOnly is the PAX record set on files whose contents did not change, only their attributes.
+	paxAttributesOnly = "KOPIA.attributes-only"
+)
+
+// BundleStats describes the contents of a diff bundle.
+type BundleStats struct {
+	Directories int   `json:"directories"`
+	Files       int   `json:"files"`
+	Attributes  int   `json:"attributes"`
+	Symlinks    int   `json:"symlinks"`
+	Removed     int   `json:"removed"`
+	Bytes       int64 `json:"bytes"`
+}
+
+// WriteBundle writes a gzip-compressed tar bundle of changes that transform the tree rooted at
+// e1 into the tree rooted at e2. Removed entries are represented by empty entries whose names
+// are prefixed with ".wh.", files whose contents did not change are stored without contents.
+func WriteBundle(ctx context.Context, out io.Writer, e1, e2 fs.Directory) (*BundleStats, error) {
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	stats := &BundleStats{}
+
+	if err := writeBundleDirectory(ctx, tw, e1, e2, ".", stats); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to close tar writer")
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to close gzip writer")
+	}
+
+	return stats, nil
+}
+
+func writeBundleDirectory(ctx context.Context, tw *tar.Writer, dir1, dir2 fs.Directory, relPath string, stats *BundleStats) error {
+	if dir1 == nil || !sameAttributes(dir1, dir2) {
+		if err := writeBundleHeader(ctx, tw, dir2, relPath+"/", nil); err != nil {
+			return err
+		}
+
+		stats.Directories++
+	}
+
+	if dir1 != nil && sameObjectID(dir1, dir2) {
+		return nil
+	}
+
+	var entries1 fs.Entries
+
+	if dir1 != nil {
+		e, err := dir1.Readdir(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read first directory %v", relPath)
+		}
+
+		entries1 = e
+	}
+
+	entries2, err := dir2.Readdir(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read second directory %v", relPath)
+	}
+
+	for _, e1 := range entries1 {
+		if entries2.FindByName(e1.Name()) == nil {
+			if err := writeWhiteout(tw, relPath, e1.Name(), stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, e2 := range entries2 {
+		if err := writeBundleEntry(ctx, tw, entries1.FindByName(e2.Name()), e2, path.Join(relPath, e2.Name()), stats); err != nil {
+			return errors.Wrapf(err, "error writing %v", e2.Name())
+		}
+	}
+
+	return nil
+}
+
+func writeBundleEntry(ctx context.Context, tw *tar.Writer, e1, e2 fs.Entry, relPath string, stats *BundleStats) error {
+	if e1 != nil && e1.IsDir() != e2.IsDir() {
+		// type changed between directory and non-directory, remove the old entry first.
+		if err := writeWhiteout(tw, path.Dir(relPath), e1.Name(), stats); err != nil {
+			return err
+		}
+
+		e1 = nil
+	}
+
+	switch e2 := e2.(type) {
+	case fs.Directory:
+		d1, _ := e1.(fs.Directory)
+		return writeBundleDirectory(ctx, tw, d1, e2, relPath, stats)
+
+	case fs.File:
+		if e1 != nil && sameObjectID(e1, e2) {
+			if sameAttributes(e1, e2) {
+				return nil
+			}
+
+			stats.Attributes++
+
+			return writeBundleHeader(ctx, tw, e2, relPath, map[string]string{paxAttributesOnly: "1"})
+		}
+
+		stats.Files++
+		stats.Bytes += e2.Size()
+
+		return writeBundleFile(ctx, tw, e2, relPath)
+
+	case fs.Symlink:
+		stats.Symlinks++
+		return writeBundleHeader(ctx, tw, e2, relPath, nil)
+
+	default:
+		log(ctx).Debugf("skipping unsupported entry %v", relPath)
+		return nil
+	}
+}
+
+func writeBundleFile(ctx context.Context, tw *tar.Writer, f fs.File, relPath string) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	if err := writeBundleHeader(ctx, tw, f, relPath, nil); err != nil {
+		return err
+	}
+
+	n, err := iocopy.Copy(tw, r)
+	if err != nil {
+		return errors.Wrap(err, "unable to copy file contents")
+	}
+
+	if n != f.Size() {
+		return errors.Errorf("unexpected file size %v, expected %v", n, f.Size())
+	}
+
+	return nil
+}
+
+func writeBundleHeader(ctx context.Context, tw *tar.Writer, e fs.Entry, name string, paxRecords map[string]string) error {
+	var link string
+
+	if sl, ok := e.(fs.Symlink); ok {
+		l, err := sl.Readlink(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to read symlink")
+		}
+
+		link = l
+	}
+
+	h, err := tar.FileInfoHeader(e, link)
+	if err != nil {
+		return errors.Wrap(err, "unable to create tar header")
+	}
+
+	h.Name = name
+	h.Uid = int(e.Owner().UserID)
+	h.Gid = int(e.Owner().GroupID)
+	h.Format = tar.FormatPAX
+	h.PAXRecords = paxRecords
+
+	if paxRecords[paxAttributesOnly] != "" {
+		h.Size = 0
+	}
+
+	return errors.Wrap(tw.WriteHeader(h), "unable to write tar header")
+}
+
+func writeWhiteout(tw *tar.Writer, dirPath, name string, stats *BundleStats) error {
+	stats.Removed++
+
+	return errors.Wrap(tw.WriteHeader(&tar.Header{
+		Name:     path.Join(dirPath, whiteoutPrefix+name),
+		Typeflag: tar.TypeReg,
+		Mode:     0600, //nolint:gomnd
+	}), "unable to write tar header")
+}
+
+func sameObjectID(e1, e2 fs.Entry) bool {
+	h1, ok1 := e1.(object.HasObjectID)
+	h2, ok2 := e2.(object.HasObjectID)
+
+	return ok1 && ok2 && h1.ObjectID() == h2.ObjectID()
+}
+
+func sameAttributes(e1, e2 fs.Entry) bool {
+	return e1.Mode() == e2.Mode() && e1.ModTime().Equal(e2.ModTime()) && e1.Owner() == e2.Owner()
+}
+
+// ApplyBundle applies the bundle created by WriteBundle to the tree rooted at targetPath.
+func ApplyBundle(ctx context.Context, r io.Reader, targetPath string) (*BundleStats, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid bundle")
+	}
+	defer gr.Close() //nolint:errcheck
+
+	tr := tar.NewReader(gr)
+	stats := &BundleStats{}
+
+	// attributes of directories are applied at the end, since applying changes modifies them.
+	var directories []*tar.Header
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read bundle")
+		}
+
+		fullPath, err := bundleTargetPath(targetPath, h.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkNoSymlinkParents(targetPath, fullPath); err != nil {
+			return nil, err
+		}
+
+		if err := applyBundleEntry(ctx, tr, h, fullPath, stats); err != nil {
+			return nil, errors.Wrapf(err, "error applying %v", h.Name)
+		}
+
+		if h.Typeflag == tar.TypeDir {
+			directories = append(directories, h)
+		}
+	}
+
+	for i := len(directories) - 1; i >= 0; i-- {
+		h := directories[i]
+		fullPath, _ := bundleTargetPath(targetPath, h.Name)
+
+		if err := setBundleAttributes(fullPath, h); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// bundleTargetPath returns the local path of the bundle entry, refusing entries outside of targetPath.
+func bundleTargetPath(targetPath, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.Errorf("invalid path in bundle: %q", name)
+	}
+
+	return filepath.Join(targetPath, filepath.FromSlash(clean)), nil
+}
+
+// checkNoSymlinkParents refuses entries whose parent directories below targetPath are symlinks,
+// which would allow entries created earlier in the bundle to redirect writes outside of targetPath.
+func checkNoSymlinkParents(targetPath, fullPath string) error {
+	rel, err := filepath.Rel(targetPath, filepath.Dir(fullPath))
+	if err != nil || rel == "." {
+		return err
+	}
+
+	p := targetPath
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+
+		st, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if st.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("refusing to apply bundle entry %q through symbolic link %q", fullPath, p)
+		}
+	}
+
+	return nil
+}
+
+func applyBundleEntry(ctx context.Context, tr *tar.Reader, h *tar.Header, fullPath string, stats *BundleStats) error {
+	if base := filepath.Base(fullPath); strings.HasPrefix(base, whiteoutPrefix) {
+		stats.Removed++
+		return os.RemoveAll(filepath.Join(filepath.Dir(fullPath), strings.TrimPrefix(base, whiteoutPrefix)))
+	}
+
+	switch h.Typeflag {
+	case tar.TypeDir:
+		stats.Directories++
+		return os.MkdirAll(fullPath, 0700) //nolint:gomnd
+
+	case tar.TypeSymlink:
+		stats.Symlinks++
+
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return os.Symlink(h.Linkname, fullPath)
+
+	case tar.TypeReg:
+		if h.PAXRecords[paxAttributesOnly] != "" {
+			stats.Attributes++
+			return setBundleAttributes(fullPath, h)
+		}
+
+		stats.Files++
+		stats.Bytes += h.Size
+
+		if err := writeLocalFile(tr, fullPath); err != nil {
+			return err
+		}
+
+		return setBundleAttributes(fullPath, h)
+
+	default:
+		log(ctx).Debugf("skipping unsupported entry %v", h.Name)
+		return nil
+	}
+}
+
+func writeLocalFile(r io.Reader, fullPath string) error {
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0700); err != nil { //nolint:gomnd
+		return err
+	}
+
+	// do not write through symlinks or into special files that are being replaced.
+	if st, err := os.Lstat(fullPath); err == nil && !st.Mode().IsRegular() {
+		if err := os.Remove(fullPath); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) //nolint:gomnd
+	if err != nil {
+		return err
+	}
+
+	if _, err := iocopy.Copy(f, r); err != nil {
+		f.Close() //nolint:errcheck
+		return errors.Wrap(err, "unable to write file contents")
+	}
+
+	return f.Close()
+}
+
+// setBundleAttributes sets permissions, modification time and owner of the local entry.
+// Only the owner of symbolic links is changed, without following them.
+func setBundleAttributes(fullPath string, h *tar.Header) error {
+	st, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Lchown(fullPath, h.Uid, h.Gid); err != nil && !os.IsPermission(err) {
+		return errors.Wrap(err, "could not change owner/group")
+	}
+
+	if st.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	if err := os.Chmod(fullPath, h.FileInfo().Mode()); err != nil && !os.IsPermission(err) {
+		return errors.Wrap(err, "could not change permissions")
+	}
+
+	if err := os.Chtimes(fullPath, h.ModTime, h.ModTime); err != nil && !os.IsPermission(err) {
+		return errors.Wrap(err, "could not change mod time")
+	}
+
+	return nil
+}
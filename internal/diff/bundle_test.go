@@ -0,0 +1,142 @@
+// +build linux darwin
+
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func makeBundle(t *testing.T, entries ...*tar.Header) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for _, h := range entries {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+
+		if h.Typeflag == tar.TypeReg && h.Size > 0 {
+			if _, err := tw.Write(bytes.Repeat([]byte{'x'}, int(h.Size))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestApplyBundleRefusesSymlinkTraversal(t *testing.T) {
+	outside, err := ioutil.TempDir("", "bundle-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	target, err := ioutil.TempDir("", "bundle-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(target)
+
+	cases := []struct {
+		desc string
+		file *tar.Header
+	}{
+		{"file", &tar.Header{Name: "evil/pwned", Typeflag: tar.TypeReg, Mode: 0600, Size: 3}},
+		{"directory", &tar.Header{Name: "evil/sub", Typeflag: tar.TypeDir, Mode: 0700}},
+		{"whiteout", &tar.Header{Name: "evil/" + whiteoutPrefix + "victim", Typeflag: tar.TypeReg, Mode: 0600}},
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(outside, "victim"), []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range cases {
+		bundle := makeBundle(t,
+			&tar.Header{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+			tc.file,
+		)
+
+		if _, err := ApplyBundle(context.Background(), bundle, target); err == nil {
+			t.Errorf("%v: expected error applying bundle writing through a symlink", tc.desc)
+		}
+
+		entries, err := ioutil.ReadDir(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 1 || entries[0].Name() != "victim" {
+			t.Fatalf("%v: bundle modified directory outside of target: %v", tc.desc, entries)
+		}
+	}
+}
+
+func TestApplyBundleDoesNotFollowSymlinkAttributes(t *testing.T) {
+	outside, err := ioutil.TempDir("", "bundle-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	target, err := ioutil.TempDir("", "bundle-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(target)
+
+	victim := filepath.Join(outside, "victim")
+	if err := ioutil.WriteFile(victim, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.Stat(victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := makeBundle(t,
+		&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: victim, Mode: 0777},
+		&tar.Header{
+			Name:       "link",
+			Typeflag:   tar.TypeReg,
+			Mode:       0777,
+			ModTime:    time.Unix(1000000000, 0),
+			Format:     tar.FormatPAX,
+			PAXRecords: map[string]string{paxAttributesOnly: "1"},
+		},
+	)
+
+	if _, err := ApplyBundle(context.Background(), bundle, target); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.Mode() != before.Mode() || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("attributes of symlink target changed: %v %v, was %v %v", after.Mode(), after.ModTime(), before.Mode(), before.ModTime())
+	}
+}
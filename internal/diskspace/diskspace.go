@@ -0,0 +1,60 @@
+// Package diskspace provides information about free space of local filesystems.
+package diskspace
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned when free space can't be determined on the current platform.
+var ErrNotSupported = errors.New("free space information is not supported on this platform")
+
+// Available returns the number of bytes available to the current user on the filesystem containing
+// the provided path. When the path does not exist yet, its closest existing parent is used.
+func Available(path string) (int64, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, errors.Errorf("no existing parent directory of %v", path)
+		}
+
+		path = parent
+	}
+
+	return available(path)
+}
+
+// Used returns the total size of files in the provided directory and its subdirectories.
+func Used(dir string) (int64, error) {
+	var total int64
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// files may be removed concurrently, for example by cache sweep.
+				return nil
+			}
+
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+
+		return nil
+	})
+
+	return total, err
+}
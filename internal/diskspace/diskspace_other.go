@@ -0,0 +1,7 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package diskspace
+
+func available(path string) (int64, error) {
+	return 0, ErrNotSupported
+}
@@ -0,0 +1,50 @@
+package diskspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskspace")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	avail, err := Available(dir)
+	if err != nil {
+		t.Fatalf("unable to get available space: %v", err)
+	}
+
+	if avail <= 0 {
+		t.Errorf("unexpected available space: %v", avail)
+	}
+
+	// non-existent paths use their closest existing parent.
+	if _, err = Available(filepath.Join(dir, "no-such-dir", "subdir")); err != nil {
+		t.Errorf("unable to get available space of non-existent path: %v", err)
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "a", "b"), 0700); err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+
+	for _, f := range []string{"f1", "a/f2", "a/b/f3"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(f)), make([]byte, 1000), 0600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	used, err := Used(dir)
+	if err != nil {
+		t.Fatalf("unable to get used space: %v", err)
+	}
+
+	if got, want := used, int64(3000); got != want {
+		t.Errorf("unexpected used space: %v, want %v", got, want)
+	}
+}
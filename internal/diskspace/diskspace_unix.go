@@ -0,0 +1,17 @@
+// +build linux darwin freebsd
+
+package diskspace
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func available(path string) (int64, error) {
+	var st unix.Statfs_t
+
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+
+	return int64(st.Bavail) * int64(st.Bsize), nil //nolint:unconvert
+}
@@ -0,0 +1,20 @@
+package diskspace
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func available(path string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytesAvailable uint64
+
+	if err := windows.GetDiskFreeSpaceEx(p, &freeBytesAvailable, nil, nil); err != nil {
+		return 0, err
+	}
+
+	return int64(freeBytesAvailable), nil
+}
@@ -35,8 +35,8 @@ func AutoAdvance(t time.Time, dt time.Duration) func() time.Time {
 // TimeAdvance allows controlling the passage of time. Intended to be used in
 // tests.
 type TimeAdvance struct {
+	delta int64 // must be first for 64-bit alignment of atomic access on x86-32
 	base  time.Time
-	delta int64
 }
 
 // NewTimeAdvance creates a TimeAdvance with the given start time
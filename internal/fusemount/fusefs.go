@@ -118,6 +118,8 @@ func newFuseNode(e fs.Entry) (fusefs.Node, error) {
 		return &fuseFileNode{fuseNode{e}}, nil
 	case fs.Symlink:
 		return &fuseSymlinkNode{fuseNode{e}}, nil
+	case fs.SpecialFile:
+		return &fuseNode{e}, nil
 	default:
 		return nil, errors.Errorf("entry type not supported: %v", e.Mode())
 	}
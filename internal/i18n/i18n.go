@@ -0,0 +1,231 @@
+// Package i18n provides translations of user-facing messages.
+//
+// Messages are identified by their English text, which is also displayed when no translation is available.
+// Translations are contributed as JSON files named after the language, such as "pl.json" or "pt_BR.json",
+// each containing an object mapping English messages (including any formatting verbs) to translated ones:
+//
+//	{
+//	  "Restored %v files.\n": "Przywrócono %v plików.\n"
+//	}
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLanguage is the language of untranslated messages.
+const DefaultLanguage = "en"
+
+// Catalog maps English messages to their translations.
+type Catalog map[string]string
+
+var (
+	mu              sync.RWMutex
+	catalogs        = map[string]Catalog{}
+	currentLanguage = DefaultLanguage
+)
+
+// Register adds translations for the provided language, replacing existing translations of the same messages.
+func Register(lang string, c Catalog) {
+	lang = normalizeLanguage(lang)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	cat := catalogs[lang]
+	if cat == nil {
+		cat = Catalog{}
+		catalogs[lang] = cat
+	}
+
+	for k, v := range c {
+		cat[k] = v
+	}
+}
+
+// LoadDirectory registers translations from all JSON files in the provided directory.
+// Missing directory is not an error.
+func LoadDirectory(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to read translations directory")
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		if err := LoadFile(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFile registers translations from the provided JSON file, whose name determines the language.
+func LoadFile(fname string) error {
+	b, err := ioutil.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to read translations")
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(b, &c); err != nil {
+		return errors.Wrapf(err, "invalid translations in %v", fname)
+	}
+
+	Register(strings.TrimSuffix(filepath.Base(fname), filepath.Ext(fname)), c)
+
+	return nil
+}
+
+// Languages returns the sorted list of languages with registered translations.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var result []string
+	for lang := range catalogs {
+		result = append(result, lang)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// SetLanguage selects the language of translated messages and returns the language actually used,
+// which falls back to the language without region (such as "pt" for "pt_BR") and then to DefaultLanguage.
+func SetLanguage(lang string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	currentLanguage = resolveLocked(lang)
+
+	return currentLanguage
+}
+
+// Language returns the currently selected language.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return currentLanguage
+}
+
+// Messages returns the language matching the provided one and a copy of its translations.
+func Messages(lang string) (string, Catalog) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	lang = resolveLocked(lang)
+
+	result := Catalog{}
+	for k, v := range catalogs[lang] {
+		result[k] = v
+	}
+
+	return lang, result
+}
+
+// T returns the translation of the provided message in the current language.
+func T(msg string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if v, ok := catalogs[currentLanguage][msg]; ok && v != "" {
+		return v
+	}
+
+	return msg
+}
+
+// Sprintf formats the translation of the provided format string in the current language.
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// DetectLanguage returns the language of the user based on POSIX locale environment variables.
+func DetectLanguage() string {
+	for _, e := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		// LANGUAGE is a colon-separated list of languages in order of preference.
+		for _, l := range strings.Split(os.Getenv(e), ":") {
+			if l = normalizeLanguage(l); l != "" {
+				return l
+			}
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// MatchAcceptLanguage returns the most preferred language from the HTTP Accept-Language header
+// which has registered translations or DefaultLanguage if there is none.
+func MatchAcceptLanguage(header string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	// browsers send languages in order of preference, so quality values are not needed.
+	for _, part := range strings.Split(header, ",") {
+		lang := strings.TrimSpace(strings.Split(part, ";")[0])
+		if l := resolveLocked(lang); l != DefaultLanguage {
+			return l
+		}
+	}
+
+	return DefaultLanguage
+}
+
+func resolveLocked(lang string) string {
+	lang = normalizeLanguage(lang)
+
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+
+	if p := strings.Index(lang, "_"); p >= 0 {
+		if _, ok := catalogs[lang[0:p]]; ok {
+			return lang[0:p]
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// normalizeLanguage converts locale names such as "pt-br" or "pt_BR.UTF-8@euro" to "pt_BR".
+func normalizeLanguage(lang string) string {
+	if p := strings.IndexAny(lang, ".@"); p >= 0 {
+		lang = lang[0:p]
+	}
+
+	lang = strings.ReplaceAll(strings.TrimSpace(lang), "-", "_")
+
+	switch lang {
+	case "", "C", "POSIX", "*":
+		return ""
+	}
+
+	parts := strings.SplitN(lang, "_", 2) //nolint:gomnd
+	parts[0] = strings.ToLower(parts[0])
+
+	if len(parts) > 1 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,138 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func resetCatalogs() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	catalogs = map[string]Catalog{}
+	currentLanguage = DefaultLanguage
+}
+
+func TestTranslation(t *testing.T) {
+	defer resetCatalogs()
+
+	Register("pl", Catalog{"Hello %v\n": "Cześć %v\n"})
+	Register("pt-br", Catalog{"Hello %v\n": "Olá %v\n"})
+
+	if got, want := Languages(), []string{"pl", "pt_BR"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected languages: %v, want %v", got, want)
+	}
+
+	cases := []struct {
+		lang     string
+		resolved string
+		want     string
+	}{
+		{"pl", "pl", "Cześć kopia\n"},
+		{"pl_PL.UTF-8", "pl", "Cześć kopia\n"},
+		{"pt_BR", "pt_BR", "Olá kopia\n"},
+		{"pt", "en", "Hello kopia\n"},
+		{"de_DE", "en", "Hello kopia\n"},
+		{"C", "en", "Hello kopia\n"},
+	}
+
+	for _, tc := range cases {
+		if got := SetLanguage(tc.lang); got != tc.resolved {
+			t.Errorf("unexpected language for %q: %v, want %v", tc.lang, got, tc.resolved)
+		}
+
+		if got := Sprintf("Hello %v\n", "kopia"); got != tc.want {
+			t.Errorf("unexpected message in %q: %q, want %q", tc.lang, got, tc.want)
+		}
+
+		if got, want := T("untranslated"), "untranslated"; got != want {
+			t.Errorf("unexpected untranslated message in %q: %q", tc.lang, got)
+		}
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	defer resetCatalogs()
+
+	td, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(td)
+
+	if err := LoadDirectory(filepath.Join(td, "missing")); err != nil {
+		t.Fatalf("unexpected error loading missing directory: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(td, "fr.json"), []byte(`{"Yes":"Oui"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(td, "README.md"), []byte("not a translation"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadDirectory(td); err != nil {
+		t.Fatalf("unable to load translations: %v", err)
+	}
+
+	if lang, msgs := Messages("fr_CA"); lang != "fr" || !reflect.DeepEqual(msgs, Catalog{"Yes": "Oui"}) {
+		t.Errorf("unexpected messages: %v %v", lang, msgs)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(td, "de.json"), []byte(`not json`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadDirectory(td); err == nil {
+		t.Errorf("expected error loading invalid translations")
+	}
+}
+
+func TestMatchAcceptLanguage(t *testing.T) {
+	defer resetCatalogs()
+
+	Register("pl", Catalog{"Yes": "Tak"})
+	Register("fr", Catalog{"Yes": "Oui"})
+
+	cases := map[string]string{
+		"":                        "en",
+		"pl-PL,pl;q=0.9,en;q=0.8": "pl",
+		"de-DE,fr;q=0.9,pl;q=0.8": "fr",
+		"en-US,en;q=0.9,pl;q=0.8": "pl",
+		"de":                      "en",
+	}
+
+	for header, want := range cases {
+		if got := MatchAcceptLanguage(header); got != want {
+			t.Errorf("unexpected language for %q: %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for _, e := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		defer os.Setenv(e, os.Getenv(e)) //nolint:errcheck
+		os.Unsetenv(e)                   //nolint:errcheck
+	}
+
+	if got, want := DetectLanguage(), DefaultLanguage; got != want {
+		t.Errorf("unexpected default language: %v, want %v", got, want)
+	}
+
+	os.Setenv("LANG", "pt_BR.UTF-8") //nolint:errcheck
+
+	if got, want := DetectLanguage(), "pt_BR"; got != want {
+		t.Errorf("unexpected language: %v, want %v", got, want)
+	}
+
+	os.Setenv("LANGUAGE", ":pl:en") //nolint:errcheck
+
+	if got, want := DetectLanguage(), "pl"; got != want {
+		t.Errorf("unexpected language: %v, want %v", got, want)
+	}
+}
@@ -71,6 +71,7 @@ type Directory struct {
 	children     fs.Entries
 	readdirError error
 	onReaddir    func()
+	fileSystemID uint64
 }
 
 // Summary returns summary of a directory.
@@ -102,6 +103,22 @@ func (imd *Directory) AddFile(name string, content []byte, permissions os.FileMo
 	return file
 }
 
+// AddSpecialFile adds a mock named pipe, socket or device node with the specified name, mode and device numbers.
+func (imd *Directory) AddSpecialFile(name string, mode os.FileMode, device fs.DeviceInfo) *SpecialFile {
+	imd, name = imd.resolveSubdir(name)
+	sf := &SpecialFile{
+		entry: entry{
+			name: name,
+			mode: mode,
+		},
+		device: device,
+	}
+
+	imd.addChild(sf)
+
+	return sf
+}
+
 // AddDir adds a fake directory with a given name and permissions.
 func (imd *Directory) AddDir(name string, permissions os.FileMode) *Directory {
 	imd, name = imd.resolveSubdir(name)
@@ -174,6 +191,16 @@ func (imd *Directory) FailReaddir(err error) {
 	imd.readdirError = err
 }
 
+// SetFileSystemID sets the identifier of the file system the directory resides on, which is zero by default.
+func (imd *Directory) SetFileSystemID(id uint64) {
+	imd.fileSystemID = id
+}
+
+// FileSystemID returns the identifier of the file system the directory resides on.
+func (imd *Directory) FileSystemID() (uint64, bool) {
+	return imd.fileSystemID, true
+}
+
 // OnReaddir invokes the provided function on read.
 func (imd *Directory) OnReaddir(cb func()) {
 	imd.onReaddir = cb
@@ -201,7 +228,9 @@ func (imd *Directory) Readdir(ctx context.Context) (fs.Entries, error) {
 type File struct {
 	entry
 
-	source func() (ReaderSeekerCloser, error)
+	source        func() (ReaderSeekerCloser, error)
+	changingOpens int
+	changeInfo    *fs.ChangeInfo
 }
 
 // SetContents changes the contents of a given file.
@@ -211,6 +240,38 @@ func (imf *File) SetContents(b []byte) {
 	}
 }
 
+// FailOpen causes the next n calls to Open() to fail with the specified error.
+func (imf *File) FailOpen(n int, err error) {
+	source := imf.source
+	imf.source = func() (ReaderSeekerCloser, error) {
+		if n > 0 {
+			n--
+			return nil, err
+		}
+
+		return source()
+	}
+}
+
+// ChangeWhileReading causes the file to appear modified while it is being read for the next n calls to Open().
+func (imf *File) ChangeWhileReading(n int) {
+	imf.changingOpens = n
+}
+
+// SetChangeInfo sets the status change time and inode number of the file, which are not reported by default.
+func (imf *File) SetChangeInfo(ci fs.ChangeInfo) {
+	imf.changeInfo = &ci
+}
+
+// ChangeInfo returns the status change time and inode number of the file.
+func (imf *File) ChangeInfo() (fs.ChangeInfo, bool) {
+	if imf.changeInfo == nil {
+		return fs.ChangeInfo{}, false
+	}
+
+	return *imf.changeInfo, true
+}
+
 type fileReader struct {
 	ReaderSeekerCloser
 	entry fs.Entry
@@ -220,6 +281,22 @@ func (ifr *fileReader) Entry() (fs.Entry, error) {
 	return ifr.entry, nil
 }
 
+// changingFileReader returns file entry with a later modification time on each call to Entry().
+type changingFileReader struct {
+	ReaderSeekerCloser
+	file  *File
+	calls int
+}
+
+func (cfr *changingFileReader) Entry() (fs.Entry, error) {
+	cfr.calls++
+
+	e := cfr.file.entry
+	e.modTime = e.modTime.Add(time.Duration(cfr.calls) * time.Second)
+
+	return &File{entry: e, source: cfr.file.source}, nil
+}
+
 // Open opens the file for reading, optionally simulating error.
 func (imf *File) Open(ctx context.Context) (fs.Reader, error) {
 	r, err := imf.source()
@@ -227,12 +304,30 @@ func (imf *File) Open(ctx context.Context) (fs.Reader, error) {
 		return nil, err
 	}
 
+	if imf.changingOpens > 0 {
+		imf.changingOpens--
+
+		return &changingFileReader{ReaderSeekerCloser: r, file: imf}, nil
+	}
+
 	return &fileReader{
 		ReaderSeekerCloser: r,
 		entry:              imf,
 	}, nil
 }
 
+// SpecialFile is an in-memory fs.SpecialFile.
+type SpecialFile struct {
+	entry
+
+	device fs.DeviceInfo
+}
+
+// Device returns device numbers of a special file.
+func (imsf *SpecialFile) Device() fs.DeviceInfo {
+	return imsf.device
+}
+
 type inmemorySymlink struct {
 	entry
 }
@@ -252,7 +347,8 @@ func NewDirectory() *Directory {
 }
 
 var (
-	_ fs.Directory = &Directory{}
-	_ fs.File      = &File{}
-	_ fs.Symlink   = &inmemorySymlink{}
+	_ fs.Directory   = &Directory{}
+	_ fs.File        = &File{}
+	_ fs.Symlink     = &inmemorySymlink{}
+	_ fs.SpecialFile = &SpecialFile{}
 )
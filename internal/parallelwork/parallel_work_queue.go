@@ -70,12 +70,15 @@ func (v *Queue) Process(workers int) error {
 					break
 				}
 
-				if err := callback(); err != nil {
+				err := callback()
+
+				// mark failed work as completed too, otherwise remaining workers wait for it forever.
+				v.completed()
+
+				if err != nil {
 					errors <- err
 					break
 				}
-
-				v.completed()
 			}
 		}(i)
 	}
@@ -27,4 +27,8 @@ func (p *Parameters) GetHMACSecret() []byte { return p.HMACSecret }
 type ManifestWithMetadata struct {
 	Payload  json.RawMessage         `json:"payload"`
 	Metadata *manifest.EntryMetadata `json:"metadata"`
+
+	// When Conditional is set, the manifest replaces existing ones only if they are exactly IfMatch.
+	Conditional bool          `json:"conditional,omitempty"`
+	IfMatch     []manifest.ID `json:"ifMatch,omitempty"`
 }
@@ -112,14 +112,20 @@ func (e *Environment) configFile() string {
 	return filepath.Join(e.configDir, "kopia.config")
 }
 
-// MustReopen closes and reopens the repository.
-func (e *Environment) MustReopen(t *testing.T) {
+// MustReopen closes and reopens the repository, optionally modifying open options.
+func (e *Environment) MustReopen(t *testing.T, openOpts ...func(*repo.Options)) {
 	err := e.Repository.Close(testlogging.Context(t))
 	if err != nil {
 		t.Fatalf("close error: %v", err)
 	}
 
-	rep, err := repo.Open(testlogging.Context(t), e.configFile(), masterPassword, &repo.Options{})
+	opt := &repo.Options{}
+
+	for _, mod := range openOpts {
+		mod(opt)
+	}
+
+	rep, err := repo.Open(testlogging.Context(t), e.configFile(), masterPassword, opt)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
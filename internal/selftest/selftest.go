@@ -0,0 +1,427 @@
+// Package selftest implements round-trip tests of blob storage, objects and manifests, which can be run against
+// a live repository to validate that its storage backend behaves correctly.
+package selftest
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/repo/splitter"
+)
+
+var log = logging.GetContextLoggerFunc("kopia/selftest")
+
+// BlobIDPrefix is the prefix of scratch blobs written by the self-test, followed by a random ID of the run.
+const BlobIDPrefix blob.ID = "kopia.selftest."
+
+// ManifestType is the type of manifests written by the self-test.
+const ManifestType = "selftest"
+
+// saltLength is the length of the random prefix of object payloads.
+const saltLength = 16
+
+// sampleNames are file and directory names that are commonly mishandled when encoding or normalizing strings.
+var sampleNames = []string{
+	"plain.txt",
+	"with space",
+	"zażółć gęślą jaźń",
+	"日本語のファイル名",
+	"Ελληνικά",
+	"עברית",
+	"😀🎉",
+	"e\u0301", // decomposed é
+	"\u00e9",  // precomposed é
+	"a/b\\c:d*e?f\"g<h>i|j",
+	"..",
+	"%2F%00",
+}
+
+// runeRanges are ranges of code points from which random names are generated.
+var runeRanges = [][2]rune{
+	{0x20, 0x7e},       // ASCII
+	{0xa0, 0x24f},      // Latin-1 and Latin Extended
+	{0x370, 0x3ff},     // Greek
+	{0x400, 0x4ff},     // Cyrillic
+	{0x590, 0x5ff},     // Hebrew
+	{0x300, 0x36f},     // combining diacritical marks
+	{0x4e00, 0x9fff},   // CJK
+	{0x1f600, 0x1f64f}, // emoticons, outside of basic multilingual plane
+}
+
+// Options provides options for Run.
+type Options struct {
+	Iterations int   // number of randomly sized items tested in each layer
+	MaxSize    int   // maximum size of blobs and objects
+	Seed       int64 // seed of random sizes, contents and names
+}
+
+// Result summarizes the items that were tested.
+type Result struct {
+	Blobs     int
+	Objects   int
+	Manifests int
+}
+
+type tester struct {
+	rep  *repo.DirectRepository
+	rnd  *rand.Rand
+	opt  Options
+	salt []byte // unique to each run, even with the same seed
+}
+
+// Run writes, reads back and deletes blobs with random and boundary sizes under a scratch prefix, objects whose
+// sizes are around the boundaries of the repository splitter and manifests with unicode labels, returning an
+// error describing the first difference that was found. Contents of test objects are left for garbage collection.
+func Run(ctx context.Context, rep *repo.DirectRepository, opt Options) (*Result, error) {
+	t := &tester{
+		rep:  rep,
+		rnd:  rand.New(rand.NewSource(opt.Seed)), //nolint:gosec
+		opt:  opt,
+		salt: make([]byte, saltLength),
+	}
+
+	if _, err := cryptorand.Read(t.salt); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
+	}
+
+	r := &Result{}
+
+	var err error
+
+	if r.Blobs, err = t.testBlobs(ctx); err != nil {
+		return r, errors.Wrap(err, "blob storage")
+	}
+
+	if r.Objects, err = t.testObjects(ctx); err != nil {
+		return r, errors.Wrap(err, "objects")
+	}
+
+	if r.Manifests, err = t.testManifests(ctx); err != nil {
+		return r, errors.Wrap(err, "manifests")
+	}
+
+	return r, nil
+}
+
+// sizes returns boundary sizes followed by random sizes, all within the maximum size.
+func (t *tester) sizes(boundaries ...int) []int {
+	var result []int
+
+	for _, b := range append([]int{0, 1}, boundaries...) {
+		if b >= 0 && b <= t.opt.MaxSize {
+			result = append(result, b)
+		}
+	}
+
+	for i := 0; i < t.opt.Iterations; i++ {
+		// prefer small sizes, which are much faster to test.
+		limit := t.opt.MaxSize
+		if i%2 == 0 && limit > 65536 {
+			limit = 65536
+		}
+
+		result = append(result, t.rnd.Intn(limit+1))
+	}
+
+	return result
+}
+
+func (t *tester) randomBytes(n int) []byte {
+	b := make([]byte, n)
+	t.rnd.Read(b) //nolint:errcheck
+
+	return b
+}
+
+// saltedBytes returns random bytes starting with the salt of the run, so that objects of the self-test
+// are not deduplicated with existing data, unless they are shorter than the salt.
+func (t *tester) saltedBytes(n int) []byte {
+	b := t.randomBytes(n)
+	copy(b, t.salt)
+
+	return b
+}
+
+func (t *tester) randomName() string {
+	if t.rnd.Intn(2) == 0 { //nolint:gomnd
+		return sampleNames[t.rnd.Intn(len(sampleNames))]
+	}
+
+	var sb strings.Builder
+
+	for n := 1 + t.rnd.Intn(30); n > 0; n-- { //nolint:gomnd
+		r := runeRanges[t.rnd.Intn(len(runeRanges))]
+		sb.WriteRune(r[0] + rune(t.rnd.Int63n(int64(r[1]-r[0]+1))))
+	}
+
+	return sb.String()
+}
+
+func (t *tester) testBlobs(ctx context.Context) (int, error) {
+	st := t.rep.Blobs
+	prefix := blob.ID(fmt.Sprintf("%v%016x.", BlobIDPrefix, t.rnd.Uint64()))
+	written := map[blob.ID][]byte{}
+
+	// scratch blobs are removed even if the test fails.
+	defer func() {
+		for id := range written {
+			if err := st.DeleteBlob(ctx, id); err != nil {
+				log(ctx).Warningf("unable to delete scratch blob %v: %v", id, err)
+			}
+		}
+	}()
+
+	for i, size := range t.sizes(4095, 4096, 4097, 65535, 65536, 65537) { //nolint:gomnd
+		id := blob.ID(fmt.Sprintf("%v%04d", prefix, i))
+		data := t.randomBytes(size)
+
+		if err := st.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+			return 0, errors.Wrapf(err, "unable to write blob %v of %v bytes", id, size)
+		}
+
+		written[id] = data
+
+		if err := t.verifyBlob(ctx, st, id, data); err != nil {
+			return 0, err
+		}
+	}
+
+	listed := map[blob.ID]int64{}
+
+	if err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		listed[bm.BlobID] = bm.Length
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "unable to list blobs")
+	}
+
+	for id, data := range written {
+		if l, ok := listed[id]; !ok || l != int64(len(data)) {
+			return 0, errors.Errorf("blob %v of %v bytes was listed as %v bytes (found: %v)", id, len(data), l, ok)
+		}
+	}
+
+	if len(listed) != len(written) {
+		return 0, errors.Errorf("listed %v blobs, but %v were written", len(listed), len(written))
+	}
+
+	count := len(written)
+
+	for id := range written {
+		if err := st.DeleteBlob(ctx, id); err != nil {
+			return 0, errors.Wrapf(err, "unable to delete blob %v", id)
+		}
+
+		delete(written, id)
+
+		if _, err := st.GetBlob(ctx, id, 0, -1); err != blob.ErrBlobNotFound {
+			return 0, errors.Errorf("unexpected result of reading deleted blob %v: %v", id, err)
+		}
+	}
+
+	return count, nil
+}
+
+func (t *tester) verifyBlob(ctx context.Context, st blob.Storage, id blob.ID, data []byte) error {
+	got, err := st.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read blob %v", id)
+	}
+
+	if !bytes.Equal(got, data) {
+		return errors.Errorf("blob %v of %v bytes was read back as different %v bytes", id, len(data), len(got))
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	offset := t.rnd.Intn(len(data))
+	length := t.rnd.Intn(len(data) - offset + 1)
+
+	got, err = st.GetBlob(ctx, id, int64(offset), int64(length))
+	if err != nil {
+		return errors.Wrapf(err, "unable to read range [%v,%v) of blob %v", offset, offset+length, id)
+	}
+
+	if !bytes.Equal(got, data[offset:offset+length]) {
+		return errors.Errorf("range [%v,%v) of blob %v was read back as different %v bytes", offset, offset+length, id, len(got))
+	}
+
+	return nil
+}
+
+func (t *tester) testObjects(ctx context.Context) (int, error) {
+	max := splitter.GetFactory(t.rep.Objects.Format.Splitter)().MaxSegmentSize()
+
+	// contents of test objects are not deleted, since they may be shared with existing objects,
+	// they are removed by garbage collection during maintenance instead.
+	defer func() {
+		if err := t.rep.Flush(ctx); err != nil {
+			log(ctx).Warningf("unable to flush repository: %v", err)
+		}
+	}()
+
+	written := map[object.ID][]byte{}
+
+	for _, size := range t.sizes(max-1, max, max+1, 2*max+1) { //nolint:gomnd
+		data := t.saltedBytes(size)
+
+		w := t.rep.NewObjectWriter(ctx, object.WriterOptions{Description: "selftest"})
+		if _, err := w.Write(data); err != nil {
+			w.Close() //nolint:errcheck
+			return 0, errors.Wrapf(err, "unable to write object of %v bytes", size)
+		}
+
+		oid, err := w.Result()
+		w.Close() //nolint:errcheck
+
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to write object of %v bytes", size)
+		}
+
+		if _, err := t.rep.VerifyObject(ctx, oid); err != nil {
+			return 0, errors.Wrapf(err, "unable to verify object %v", oid)
+		}
+
+		written[oid] = data
+	}
+
+	// objects are read again after their contents were written to the storage.
+	if err := t.rep.Flush(ctx); err != nil {
+		return 0, errors.Wrap(err, "unable to flush repository")
+	}
+
+	for oid, data := range written {
+		if err := t.verifyObject(ctx, oid, data); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(written), nil
+}
+
+func (t *tester) verifyObject(ctx context.Context, oid object.ID, data []byte) error {
+	r, err := t.rep.OpenObject(ctx, oid)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open object %v", oid)
+	}
+	defer r.Close() //nolint:errcheck
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read object %v", oid)
+	}
+
+	if !bytes.Equal(got, data) {
+		return errors.Errorf("object %v of %v bytes was read back as different %v bytes", oid, len(data), len(got))
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	offset := t.rnd.Intn(len(data))
+
+	if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+		return errors.Wrapf(err, "unable to seek object %v to %v", oid, offset)
+	}
+
+	got, err = ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read object %v from %v", oid, offset)
+	}
+
+	if !bytes.Equal(got, data[offset:]) {
+		return errors.Errorf("object %v was read from offset %v as different %v bytes", oid, offset, len(got))
+	}
+
+	return nil
+}
+
+func (t *tester) testManifests(ctx context.Context) (int, error) {
+	type item struct {
+		labels  map[string]string
+		payload map[string]string
+	}
+
+	written := map[manifest.ID]item{}
+
+	// test manifests are deleted even if the test fails.
+	defer func() {
+		for id := range written {
+			if err := t.rep.DeleteManifest(ctx, id); err != nil {
+				log(ctx).Warningf("unable to delete manifest %v: %v", id, err)
+			}
+		}
+
+		if err := t.rep.Flush(ctx); err != nil {
+			log(ctx).Warningf("unable to flush repository: %v", err)
+		}
+	}()
+
+	for i := 0; i < t.opt.Iterations; i++ {
+		it := item{
+			labels: map[string]string{
+				manifest.TypeLabelKey: ManifestType,
+				"name":                t.randomName(),
+			},
+			payload: map[string]string{},
+		}
+
+		for n := t.rnd.Intn(5); n >= 0; n-- { //nolint:gomnd
+			it.payload[t.randomName()] = t.randomName()
+		}
+
+		id, err := t.rep.PutManifest(ctx, it.labels, it.payload)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to put manifest %q", it.labels["name"])
+		}
+
+		written[id] = it
+	}
+
+	if err := t.rep.Flush(ctx); err != nil {
+		return 0, errors.Wrap(err, "unable to flush repository")
+	}
+
+	// manifests are loaded from the storage by a separate manager.
+	mm, err := manifest.NewManager(ctx, t.rep.Content, manifest.ManagerOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to open manifests")
+	}
+
+	for id, want := range written {
+		got := map[string]string{}
+
+		em, err := mm.Get(ctx, id, &got)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to read manifest %v", id)
+		}
+
+		if !reflect.DeepEqual(em.Labels, want.labels) {
+			return 0, errors.Errorf("labels of manifest %v were read back as %q, want %q", id, em.Labels, want.labels)
+		}
+
+		if !reflect.DeepEqual(got, want.payload) {
+			return 0, errors.Errorf("manifest %v was read back as %q, want %q", id, got, want.payload)
+		}
+	}
+
+	return len(written), nil
+}
@@ -0,0 +1,101 @@
+package selftest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/selftest"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(o *repo.NewRepositoryOptions) {
+		o.ObjectFormat.Splitter = "FIXED-1M"
+	}).Close(ctx, t)
+
+	// existing empty object shares its content with the empty test object.
+	w := env.Repository.NewObjectWriter(ctx, object.WriterOptions{})
+
+	oid, err := w.Result()
+	if err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	w.Close() //nolint:errcheck
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	r, err := selftest.Run(ctx, env.Repository, selftest.Options{
+		Iterations: 5,
+		MaxSize:    3 << 20,
+		Seed:       1,
+	})
+	if err != nil {
+		t.Fatalf("self-test failed: %v", err)
+	}
+
+	// 0, 1 and 6 boundary sizes of blobs and 0, 1 and 4 splitter boundary sizes of objects, each followed by 5 random sizes.
+	if got, want := *r, (selftest.Result{Blobs: 13, Objects: 11, Manifests: 5}); got != want {
+		t.Errorf("unexpected result: %+v, want %+v", got, want)
+	}
+
+	if err := env.Repository.Blobs.ListBlobs(ctx, selftest.BlobIDPrefix, func(bm blob.Metadata) error {
+		t.Errorf("scratch blob was not deleted: %v", bm.BlobID)
+		return nil
+	}); err != nil {
+		t.Fatalf("unable to list blobs: %v", err)
+	}
+
+	md, err := env.Repository.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: selftest.ManifestType})
+	if err != nil || len(md) != 0 {
+		t.Errorf("test manifests were not deleted: %v %v", md, err)
+	}
+
+	or, err := env.Repository.OpenObject(ctx, oid)
+	if err != nil {
+		t.Fatalf("existing object was deleted: %v", err)
+	}
+	defer or.Close() //nolint:errcheck
+
+	if _, err := ioutil.ReadAll(or); err != nil {
+		t.Errorf("unable to read existing object: %v", err)
+	}
+
+	// payloads are salted, so runs with the same seed write new contents.
+	before := countContents(ctx, t, env.Repository)
+
+	if _, err := selftest.Run(ctx, env.Repository, selftest.Options{Iterations: 1, MaxSize: 3 << 20, Seed: 1}); err != nil {
+		t.Fatalf("second self-test failed: %v", err)
+	}
+
+	if after := countContents(ctx, t, env.Repository); after <= before {
+		t.Errorf("second self-test with the same seed did not write new contents: %v, before %v", after, before)
+	}
+}
+
+func countContents(ctx context.Context, t *testing.T, rep *repo.DirectRepository) int {
+	t.Helper()
+
+	count := 0
+
+	if err := rep.Content.IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatalf("unable to list contents: %v", err)
+	}
+
+	return count
+}
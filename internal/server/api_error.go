@@ -20,6 +20,14 @@ func notFoundError(message string) *apiError {
 	return &apiError{404, serverapi.ErrorNotFound, message}
 }
 
+func conflictError(message string) *apiError {
+	return &apiError{409, serverapi.ErrorConflict, message}
+}
+
+func timeoutError() *apiError {
+	return &apiError{504, serverapi.ErrorTimeout, "request deadline exceeded"}
+}
+
 func internalServerError(err error) *apiError {
 	return &apiError{500, serverapi.ErrorInternal, fmt.Sprintf("internal server error: %v", err)}
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/remoterepoapi"
 	"github.com/kopia/kopia/internal/serverapi"
@@ -100,7 +101,21 @@ func (s *Server) handleManifestCreate(ctx context.Context, r *http.Request) (int
 		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request")
 	}
 
-	id, err := s.rep.PutManifest(ctx, req.Metadata.Labels, req.Payload)
+	var (
+		id  manifest.ID
+		err error
+	)
+
+	if req.Conditional {
+		id, err = s.rep.PutManifestIfMatch(ctx, req.Metadata.Labels, req.Payload, req.IfMatch)
+	} else {
+		id, err = s.rep.PutManifest(ctx, req.Metadata.Labels, req.Payload)
+	}
+
+	if errors.Cause(err) == manifest.ErrConflict {
+		return nil, conflictError(err.Error())
+	}
+
 	if err != nil {
 		return nil, internalServerError(err)
 	}
@@ -51,7 +51,7 @@ func (s *Server) handleRepoStatus(ctx context.Context, r *http.Request) (interfa
 			ConfigFile:  dr.ConfigFile,
 			CacheDir:    dr.Content.CachingOptions.CacheDirectory,
 			Hash:        dr.Content.Format.Hash,
-			Encryption:  dr.Content.Format.Encryption,
+			Encryption:  dr.Content.Format.CurrentEncryption(),
 			MaxPackSize: dr.Content.Format.MaxPackSize,
 			Splitter:    dr.Objects.Format.Splitter,
 			Storage:     dr.Blobs.ConnectionInfo().Type,
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/object"
+)
+
+var auditLog = logging.GetContextLoggerFunc("kopia/server/audit")
+
+const (
+	defaultShareLinkExpiration = 24 * time.Hour
+	shareLinkSecretSize        = 32
+	shareLinkPathPrefix        = "/share/"
+	shareLinkSecretFileSuffix  = ".kopia-share-secret"
+)
+
+// loadShareLinkSecret returns the secret used to sign share links, which is persisted next to the
+// config file so that links remain valid across server restarts.
+func loadShareLinkSecret(ctx context.Context, configFile string) ([]byte, error) {
+	var fn string
+
+	if configFile != "" {
+		fn = configFile + shareLinkSecretFileSuffix
+
+		b, err := ioutil.ReadFile(fn) //nolint:gosec
+		if err == nil && len(b) == shareLinkSecretSize {
+			return b, nil
+		}
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "unable to read share link secret")
+		}
+	}
+
+	secret := make([]byte, shareLinkSecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, errors.Wrap(err, "unable to generate share link secret")
+	}
+
+	if fn == "" {
+		log(ctx).Warningf("no config file, share links will stop working when the server is restarted")
+		return secret, nil
+	}
+
+	if err := atomic.WriteFile(fn, bytes.NewReader(secret)); err != nil {
+		return nil, errors.Wrap(err, "unable to write share link secret")
+	}
+
+	if err := os.Chmod(fn, 0600); err != nil { //nolint:gomnd
+		return nil, errors.Wrap(err, "unable to set permissions on share link secret")
+	}
+
+	return secret, nil
+}
+
+// shareLinkClaims is the signed payload of a share link.
+type shareLinkClaims struct {
+	ObjectID object.ID `json:"oid"`
+	FileName string    `json:"fname,omitempty"`
+	Expires  int64     `json:"exp"`
+}
+
+func (s *Server) signShareLink(c *shareLinkClaims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal share link")
+	}
+
+	h := hmac.New(sha256.New, s.shareLinkSecret)
+	h.Write(payload) //nolint:errcheck
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *Server) verifyShareLink(token string) (*shareLinkClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 { //nolint:gomnd
+		return nil, errors.New("malformed share link")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed share link")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed share link")
+	}
+
+	h := hmac.New(sha256.New, s.shareLinkSecret)
+	h.Write(payload) //nolint:errcheck
+
+	if !hmac.Equal(h.Sum(nil), sig) {
+		return nil, errors.New("invalid share link signature")
+	}
+
+	c := &shareLinkClaims{}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, errors.New("malformed share link")
+	}
+
+	if time.Now().Unix() > c.Expires { // allow:no-inject-time
+		return nil, errors.New("share link expired")
+	}
+
+	return c, nil
+}
+
+func (s *Server) handleShareLinkCreate(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	var req serverapi.CreateShareLinkRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	oid, err := object.ParseID(req.ObjectID)
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "invalid object id")
+	}
+
+	if _, err := s.rep.VerifyObject(ctx, oid); err != nil {
+		return nil, notFoundError("object not found")
+	}
+
+	expiration := defaultShareLinkExpiration
+	if req.ExpirationSeconds > 0 {
+		expiration = time.Duration(req.ExpirationSeconds) * time.Second
+	}
+
+	expires := time.Now().Add(expiration) // allow:no-inject-time
+
+	token, err := s.signShareLink(&shareLinkClaims{
+		ObjectID: oid,
+		FileName: req.FileName,
+		Expires:  expires.Unix(),
+	})
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	user, _, _ := r.BasicAuth()
+	auditLog(ctx).Infof("share link for %v (%q) expiring at %v created by %q from %v", oid, req.FileName, expires.Format(time.RFC3339), user, r.RemoteAddr)
+
+	return &serverapi.CreateShareLinkResponse{
+		Path:    shareLinkPathPrefix + token,
+		Expires: expires,
+	}, nil
+}
+
+func (s *Server) handleShareLinkGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	c, err := s.verifyShareLink(mux.Vars(r)["token"])
+	if err != nil {
+		auditLog(ctx).Warningf("rejected share link request from %v: %v", r.RemoteAddr, err)
+		http.Error(w, "invalid or expired link", http.StatusForbidden)
+
+		return
+	}
+
+	obj, err := s.openSharedObject(ctx, c.ObjectID)
+	if err == errNotConnected {
+		http.Error(w, "not connected", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+
+	defer obj.Close() //nolint:errcheck
+
+	auditLog(ctx).Infof("share link for %v (%q) used from %v", c.ObjectID, c.FileName, r.RemoteAddr)
+
+	fname := c.ObjectID.String()
+	if c.FileName != "" {
+		fname = c.FileName
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+strings.ReplaceAll(c.FileName, "\"", "")+"\"")
+	}
+
+	// the server lock is not held while streaming, so that slow downloads don't block other requests.
+	http.ServeContent(w, r, fname, time.Time{}, obj)
+}
+
+var errNotConnected = errors.New("not connected")
+
+func (s *Server) openSharedObject(ctx context.Context, oid object.ID) (object.Reader, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.rep == nil {
+		return nil, errNotConnected
+	}
+
+	return s.rep.OpenObject(ctx, oid)
+}
+
+// ShareLinkHandlers handles public share link requests, which don't require credentials.
+func (s *Server) ShareLinkHandlers() http.Handler {
+	m := mux.NewRouter()
+
+	m.HandleFunc(shareLinkPathPrefix+"{token}", s.handleShareLinkGet).Methods(http.MethodGet, http.MethodHead)
+
+	return m
+}
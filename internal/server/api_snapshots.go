@@ -71,6 +71,7 @@ func convertSnapshotManifest(m *snapshot.Manifest) *serverapi.Snapshot {
 		IncompleteReason: m.IncompleteReason,
 		RootEntry:        m.RootObjectID().String(),
 		RetentionReasons: m.RetentionReasons,
+		Classification:   m.Stats.Classification,
 	}
 
 	if re := m.RootEntry; re != nil {
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kopia/kopia/internal/i18n"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+// handleTranslations returns translated messages in the language from the 'lang' parameter
+// or preferred by the browser, or the language of the server if neither has translations.
+func (s *Server) handleTranslations(ctx context.Context, r *http.Request) (interface{}, *apiError) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = i18n.MatchAcceptLanguage(r.Header.Get("Accept-Language"))
+	}
+
+	if lang == i18n.DefaultLanguage {
+		lang = i18n.Language()
+	}
+
+	resolved, messages := i18n.Messages(lang)
+
+	languages := []string{i18n.DefaultLanguage}
+
+	for _, l := range i18n.Languages() {
+		if l != i18n.DefaultLanguage {
+			languages = append(languages, l)
+		}
+	}
+
+	return &serverapi.TranslationsResponse{
+		Language:  resolved,
+		Languages: languages,
+		Messages:  messages,
+	}, nil
+}
@@ -38,6 +38,9 @@ type Server struct {
 	mu              sync.RWMutex
 	sourceManagers  map[snapshot.SourceInfo]*sourceManager
 	uploadSemaphore chan struct{}
+
+	// secret used to sign share links, links become invalid when the server is restarted.
+	shareLinkSecret []byte
 }
 
 // APIHandlers handles API requests.
@@ -63,8 +66,12 @@ func (s *Server) APIHandlers() http.Handler {
 	m.HandleFunc("/api/v1/flush", s.handleAPI(s.handleFlush)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/shutdown", s.handleAPIPossiblyNotConnected(s.handleShutdown)).Methods(http.MethodPost)
 
+	m.HandleFunc("/api/v1/translations", s.handleAPIPossiblyNotConnected(s.handleTranslations)).Methods(http.MethodGet)
+
 	m.HandleFunc("/api/v1/objects/{objectID}", s.handleObjectGet).Methods(http.MethodGet)
 
+	m.HandleFunc("/api/v1/share", s.handleAPI(s.handleShareLinkCreate)).Methods(http.MethodPost)
+
 	m.HandleFunc("/api/v1/repo/status", s.handleAPIPossiblyNotConnected(s.handleRepoStatus)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/repo/connect", s.handleAPIPossiblyNotConnected(s.handleRepoConnect)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/repo/create", s.handleAPIPossiblyNotConnected(s.handleRepoCreate)).Methods(http.MethodPost)
@@ -102,6 +109,13 @@ func (s *Server) handleAPIPossiblyNotConnected(f func(ctx context.Context, r *ht
 
 		ctx := r.Context()
 
+		if s.options.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, s.options.RequestTimeout)
+			defer cancel()
+		}
+
 		log(ctx).Debugf("request %v", r.URL)
 
 		w.Header().Set("Content-Type", "application/json")
@@ -109,6 +123,9 @@ func (s *Server) handleAPIPossiblyNotConnected(f func(ctx context.Context, r *ht
 		e.SetIndent("", "  ")
 
 		v, err := f(ctx, r)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = timeoutError()
+		}
 
 		if err == nil {
 			if b, ok := v.([]byte); ok {
@@ -365,6 +382,10 @@ type Options struct {
 	ConfigFile      string
 	ConnectOptions  *repo.ConnectOptions
 	RefreshInterval time.Duration
+
+	// RequestTimeout is the deadline for handling API requests, which is propagated through the context
+	// to repository and storage operations, zero means no deadline.
+	RequestTimeout time.Duration
 }
 
 // New creates a Server.
@@ -376,5 +397,12 @@ func New(ctx context.Context, options Options) (*Server, error) {
 		uploadSemaphore: make(chan struct{}, 1),
 	}
 
+	secret, err := loadShareLinkSecret(ctx, options.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s.shareLinkSecret = secret
+
 	return s, nil
 }
@@ -211,19 +211,31 @@ func (s *sourceManager) snapshot(ctx context.Context) {
 	default:
 	}
 
-	localEntry, err := localfs.NewEntry(s.src.Path)
+	policyTree, err := policy.TreeForSource(ctx, s.server.rep, s.src)
 	if err != nil {
-		log(ctx).Errorf("unable to create local filesystem: %v", err)
+		log(ctx).Errorf("unable to create policy getter: %v", err)
+	}
+
+	actions, err := snapshotfs.BeginSnapshotRoot(ctx, policyTree.EffectivePolicy().ActionsPolicy, s.src, s.server.rep.ActionsEnabled())
+	if err != nil {
+		log(ctx).Errorf("unable to prepare snapshot: %v", err)
 		return
 	}
 
-	u := snapshotfs.NewUploader(s.server.rep)
+	defer func() {
+		if err := actions.End(ctx); err != nil {
+			log(ctx).Errorf("unable to clean up after snapshot: %v", err)
+		}
+	}()
 
-	policyTree, err := policy.TreeForSource(ctx, s.server.rep, s.src)
+	localEntry, err := localfs.NewEntry(actions.SnapshotPath)
 	if err != nil {
-		log(ctx).Errorf("unable to create policy getter: %v", err)
+		log(ctx).Errorf("unable to create local filesystem: %v", err)
+		return
 	}
 
+	u := snapshotfs.NewUploader(s.server.rep)
+
 	u.Progress = s.progress
 
 	log(ctx).Infof("starting upload of %v", s.src)
@@ -236,6 +248,13 @@ func (s *sourceManager) snapshot(ctx context.Context) {
 		return
 	}
 
+	if err := actions.End(ctx); err != nil {
+		log(ctx).Errorf("unable to clean up after snapshot: %v", err)
+		return
+	}
+
+	manifest.ActionOutput = actions.Output()
+
 	snapshotID, err := snapshot.SaveSnapshot(ctx, s.server.rep, manifest)
 	if err != nil {
 		log(ctx).Errorf("unable to save snapshot: %v", err)
@@ -2,6 +2,7 @@ package serverapi
 
 import (
 	"context"
+	"net/url"
 	"strings"
 
 	"github.com/kopia/kopia/internal/apiclient"
@@ -39,6 +40,16 @@ func CancelUpload(ctx context.Context, c *apiclient.KopiaAPIClient, match *snaps
 	return resp, nil
 }
 
+// CreateShareLink creates a public link for downloading a single file.
+func CreateShareLink(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateShareLinkRequest) (*CreateShareLinkResponse, error) {
+	resp := &CreateShareLinkResponse{}
+	if err := c.Post(ctx, "share", req, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 // CreateRepository invokes the 'repo/create' API.
 func CreateRepository(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateRepositoryRequest) error {
 	return c.Post(ctx, "repo/create", req, &StatusResponse{})
@@ -134,3 +145,13 @@ func matchSourceParameters(match *snapshot.SourceInfo) string {
 
 	return "?" + strings.Join(clauses, "&")
 }
+
+// Translations returns translated UI messages in the provided language.
+func Translations(ctx context.Context, c *apiclient.KopiaAPIClient, lang string) (*TranslationsResponse, error) {
+	resp := &TranslationsResponse{}
+	if err := c.Get(ctx, "translations?lang="+url.QueryEscape(lang), nil, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
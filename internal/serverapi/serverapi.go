@@ -67,6 +67,7 @@ const (
 	ErrorInternal           APIErrorCode = "INTERNAL"
 	ErrorAlreadyConnected   APIErrorCode = "ALREADY_CONNECTED"
 	ErrorAlreadyInitialized APIErrorCode = "ALREADY_INITIALIZED"
+	ErrorConflict           APIErrorCode = "CONFLICT"
 	ErrorInvalidPassword    APIErrorCode = "INVALID_PASSWORD"
 	ErrorInvalidToken       APIErrorCode = "INVALID_TOKEN"
 	ErrorMalformedRequest   APIErrorCode = "MALFORMED_REQUEST"
@@ -75,6 +76,7 @@ const (
 	ErrorNotInitialized     APIErrorCode = "NOT_INITIALIZED"
 	ErrorPathNotFound       APIErrorCode = "PATH_NOT_FOUND"
 	ErrorStorageConnection  APIErrorCode = "STORAGE_CONNECTION"
+	ErrorTimeout            APIErrorCode = "TIMEOUT"
 )
 
 // ErrorResponse represents error response.
@@ -132,18 +134,39 @@ type CreateSnapshotSourceResponse struct {
 
 // Snapshot describes single snapshot entry.
 type Snapshot struct {
-	ID               manifest.ID          `json:"id"`
-	Source           snapshot.SourceInfo  `json:"source"`
-	Description      string               `json:"description"`
-	StartTime        time.Time            `json:"startTime"`
-	EndTime          time.Time            `json:"endTime"`
-	IncompleteReason string               `json:"incomplete,omitempty"`
-	Summary          *fs.DirectorySummary `json:"summary"`
-	RootEntry        string               `json:"rootID"`
-	RetentionReasons []string             `json:"retention"`
+	ID               manifest.ID              `json:"id"`
+	Source           snapshot.SourceInfo      `json:"source"`
+	Description      string                   `json:"description"`
+	StartTime        time.Time                `json:"startTime"`
+	EndTime          time.Time                `json:"endTime"`
+	IncompleteReason string                   `json:"incomplete,omitempty"`
+	Summary          *fs.DirectorySummary     `json:"summary"`
+	RootEntry        string                   `json:"rootID"`
+	RetentionReasons []string                 `json:"retention"`
+	Classification   *snapshot.Classification `json:"classification,omitempty"`
+}
+
+// CreateShareLinkRequest contains request to create a public link for downloading a single file from a snapshot.
+type CreateShareLinkRequest struct {
+	ObjectID          string `json:"objectID"`
+	FileName          string `json:"fileName"`
+	ExpirationSeconds int    `json:"expirationSeconds"` // 0 means default
+}
+
+// CreateShareLinkResponse contains the path of the created share link, relative to the server URL.
+type CreateShareLinkResponse struct {
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
 }
 
 // SnapshotsResponse contains a list of snapshots.
 type SnapshotsResponse struct {
 	Snapshots []*Snapshot `json:"snapshots"`
 }
+
+// TranslationsResponse contains translations of UI messages in the language preferred by the user.
+type TranslationsResponse struct {
+	Language  string            `json:"language"`
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
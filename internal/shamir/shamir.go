@@ -0,0 +1,143 @@
+// Package shamir implements Shamir's secret sharing over GF(256).
+package shamir
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// MaxShares is the maximum number of shares that a secret can be split into.
+const MaxShares = 255
+
+// exp and log tables of GF(256) with the AES reduction polynomial x^8 + x^4 + x^3 + x + 1 and generator 3.
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+
+		// multiply by 3 = x * 2 + x
+		x ^= xtime(x)
+	}
+}
+
+func xtime(b byte) byte {
+	if b&0x80 != 0 { //nolint:gomnd
+		return b<<1 ^ 0x1b //nolint:gomnd
+	}
+
+	return b << 1
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+// Split splits the secret into n shares, any threshold of which can be combined to reconstruct it,
+// while fewer shares reveal nothing about the secret. The first byte of each share is its X coordinate.
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 1 || threshold > n || n > MaxShares {
+		return nil, errors.Errorf("invalid number of shares %v with threshold %v", n, threshold)
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+
+	for pos, b := range secret {
+		// random polynomial of degree threshold-1 whose value at zero is the secret byte.
+		coefficients[0] = b
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, errors.Wrap(err, "unable to generate coefficients")
+		}
+
+		for _, s := range shares {
+			s[pos+1] = evaluate(coefficients, s[0])
+		}
+	}
+
+	return shares, nil
+}
+
+// evaluate returns the value of the polynomial at x using Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+
+	return result
+}
+
+// Combine reconstructs the secret from shares produced by Split. The result is only correct
+// when at least threshold distinct shares are provided.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares provided")
+	}
+
+	secretLen := len(shares[0]) - 1
+	seen := map[byte]bool{}
+
+	for _, s := range shares {
+		if len(s) != secretLen+1 || secretLen == 0 {
+			return nil, errors.New("inconsistent share lengths")
+		}
+
+		if s[0] == 0 || seen[s[0]] {
+			return nil, errors.Errorf("invalid or duplicate share %v", s[0])
+		}
+
+		seen[s[0]] = true
+	}
+
+	secret := make([]byte, secretLen)
+
+	// Lagrange interpolation at zero.
+	for i, si := range shares {
+		basis := byte(1)
+
+		for j, sj := range shares {
+			if i != j {
+				// in GF(256) subtraction is XOR, so (0 - xj) / (xi - xj) == xj / (xi ^ xj)
+				basis = mul(basis, div(sj[0], si[0]^sj[0]))
+			}
+		}
+
+		for pos := range secret {
+			secret[pos] ^= mul(basis, si[pos+1])
+		}
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,88 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("this is a 32-byte long secret!!!")
+
+	cases := []struct{ n, threshold int }{
+		{1, 1},
+		{3, 2},
+		{5, 3},
+		{5, 5},
+		{MaxShares, 2},
+	}
+
+	for _, tc := range cases {
+		shares, err := Split(secret, tc.n, tc.threshold)
+		if err != nil {
+			t.Fatalf("unable to split %v/%v: %v", tc.n, tc.threshold, err)
+		}
+
+		if got, want := len(shares), tc.n; got != want {
+			t.Fatalf("unexpected number of shares: %v, want %v", got, want)
+		}
+
+		// any consecutive threshold shares reconstruct the secret.
+		for start := 0; start+tc.threshold <= tc.n; start++ {
+			got, err := Combine(shares[start : start+tc.threshold])
+			if err != nil {
+				t.Fatalf("unable to combine: %v", err)
+			}
+
+			if !bytes.Equal(got, secret) {
+				t.Errorf("invalid secret reconstructed from %v/%v shares starting at %v", tc.threshold, tc.n, start)
+			}
+		}
+
+		// all shares reconstruct the secret too.
+		if got, err := Combine(shares); err != nil || !bytes.Equal(got, secret) {
+			t.Errorf("invalid secret reconstructed from all shares: %v", err)
+		}
+
+		if tc.threshold > 1 {
+			if got, err := Combine(shares[0 : tc.threshold-1]); err != nil || bytes.Equal(got, secret) {
+				t.Errorf("unexpected secret reconstructed from too few shares: %v", err)
+			}
+		}
+	}
+}
+
+func TestInvalidSplit(t *testing.T) {
+	for _, tc := range []struct{ n, threshold int }{
+		{3, 0},
+		{3, 4},
+		{MaxShares + 1, 2},
+	} {
+		if _, err := Split([]byte("secret"), tc.n, tc.threshold); err == nil {
+			t.Errorf("unexpected success splitting into %v/%v", tc.n, tc.threshold)
+		}
+	}
+
+	if _, err := Split(nil, 3, 2); err == nil {
+		t.Errorf("unexpected success splitting empty secret")
+	}
+}
+
+func TestInvalidCombine(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("unable to split: %v", err)
+	}
+
+	cases := [][][]byte{
+		nil,
+		{shares[0], shares[0]},
+		{shares[0], shares[1][0:3]},
+		{shares[0], append([]byte{0}, shares[1][1:]...)},
+	}
+
+	for i, tc := range cases {
+		if _, err := Combine(tc); err == nil {
+			t.Errorf("unexpected success combining case %v", i)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package vss manages Windows Volume Shadow Copies, which provide a consistent point-in-time
+// view of a volume including files that are open or locked by other processes.
+package vss
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned when shadow copies are not available on the current platform.
+var ErrNotSupported = errors.New("volume shadow copies are only supported on Windows")
+
+// ShadowCopy describes a shadow copy of a single volume.
+type ShadowCopy struct {
+	ID           string // shadow copy ID, such as {1D4CC0D8-...}
+	Volume       string // volume name, such as C:
+	DeviceObject string // shadow copy device, such as \\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1
+}
+
+// Path returns the location of the provided path of the original volume inside the shadow copy.
+func (s *ShadowCopy) Path(p string) (string, error) {
+	if len(p) < len(s.Volume) || !strings.EqualFold(p[:len(s.Volume)], s.Volume) {
+		return "", errors.Errorf("%v is not on volume %v", p, s.Volume)
+	}
+
+	rest := p[len(s.Volume):]
+	if !strings.HasPrefix(rest, `\`) {
+		rest = `\` + rest
+	}
+
+	return s.DeviceObject + rest, nil
+}
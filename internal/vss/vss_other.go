@@ -0,0 +1,15 @@
+// +build !windows
+
+package vss
+
+import "context"
+
+// Create creates a shadow copy of the provided volume.
+func Create(ctx context.Context, volume string) (*ShadowCopy, error) {
+	return nil, ErrNotSupported
+}
+
+// Delete deletes the shadow copy.
+func (s *ShadowCopy) Delete(ctx context.Context) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,31 @@
+package vss
+
+import "testing"
+
+func TestShadowCopyPath(t *testing.T) {
+	s := &ShadowCopy{
+		Volume:       "C:",
+		DeviceObject: `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3`,
+	}
+
+	cases := map[string]string{
+		`C:\`:               `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`,
+		`C:`:                `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\`,
+		`c:\Users\Mail.pst`: `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy3\Users\Mail.pst`,
+	}
+
+	for input, want := range cases {
+		got, err := s.Path(input)
+		if err != nil {
+			t.Fatalf("unable to translate %v: %v", input, err)
+		}
+
+		if got != want {
+			t.Errorf("invalid path for %v: %v, want %v", input, got, want)
+		}
+	}
+
+	if _, err := s.Path(`D:\Data`); err == nil {
+		t.Errorf("expected error for path on another volume")
+	}
+}
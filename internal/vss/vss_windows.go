@@ -0,0 +1,71 @@
+package vss
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	volumeNameRegexp = regexp.MustCompile(`^[A-Za-z]:$`)
+	shadowIDRegexp   = regexp.MustCompile(`^\{[0-9A-Fa-f-]+\}$`)
+)
+
+// Create creates a shadow copy of the provided volume.
+func Create(ctx context.Context, volume string) (*ShadowCopy, error) {
+	if !volumeNameRegexp.MatchString(volume) {
+		return nil, errors.Errorf("invalid volume name: %q", volume)
+	}
+
+	out, err := runPowerShell(ctx, `
+$r = (Get-WmiObject -List Win32_ShadowCopy).Create('`+volume+`\', 'ClientAccessible')
+if ($r.ReturnValue -ne 0) { Write-Error "Win32_ShadowCopy.Create returned $($r.ReturnValue)"; exit 1 }
+$s = Get-WmiObject Win32_ShadowCopy -Filter "ID='$($r.ShadowID)'"
+Write-Output $s.ID
+Write-Output $s.DeviceObject
+`)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create shadow copy of %v, administrator privileges are required", volume)
+	}
+
+	lines := strings.Fields(out)
+	if len(lines) != 2 || !shadowIDRegexp.MatchString(lines[0]) { //nolint:gomnd
+		return nil, errors.Errorf("unexpected shadow copy information: %q", out)
+	}
+
+	return &ShadowCopy{
+		ID:           lines[0],
+		Volume:       volume,
+		DeviceObject: lines[1],
+	}, nil
+}
+
+// Delete deletes the shadow copy.
+func (s *ShadowCopy) Delete(ctx context.Context) error {
+	if !shadowIDRegexp.MatchString(s.ID) {
+		return errors.Errorf("invalid shadow copy ID: %q", s.ID)
+	}
+
+	_, err := runPowerShell(ctx, `Get-WmiObject Win32_ShadowCopy -Filter "ID='`+s.ID+`'" | ForEach-Object { $_.Delete() }`)
+
+	return errors.Wrapf(err, "unable to delete shadow copy %v", s.ID)
+}
+
+func runPowerShell(ctx context.Context, script string) (string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script) // nolint:gosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
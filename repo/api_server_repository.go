@@ -32,9 +32,10 @@ type apiServerRepository struct {
 	cli *apiclient.KopiaAPIClient
 	h   hashing.HashFunc
 
-	omgr     *object.Manager
-	username string
-	hostname string
+	omgr           *object.Manager
+	username       string
+	hostname       string
+	actionsEnabled bool
 }
 
 func (r *apiServerRepository) OpenObject(ctx context.Context, id object.ID) (object.Reader, error) {
@@ -81,6 +82,30 @@ func (r *apiServerRepository) PutManifest(ctx context.Context, labels map[string
 	return resp.ID, nil
 }
 
+func (r *apiServerRepository) PutManifestIfMatch(ctx context.Context, labels map[string]string, payload interface{}, expected []manifest.ID) (manifest.ID, error) {
+	v, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal JSON")
+	}
+
+	req := &remoterepoapi.ManifestWithMetadata{
+		Payload: json.RawMessage(v),
+		Metadata: &manifest.EntryMetadata{
+			Labels: labels,
+		},
+		Conditional: true,
+		IfMatch:     expected,
+	}
+
+	resp := &manifest.EntryMetadata{}
+
+	if err := r.cli.PostConditional(ctx, "manifests", manifest.ErrConflict, req, resp); err != nil {
+		return "", err
+	}
+
+	return resp.ID, nil
+}
+
 func (r *apiServerRepository) FindManifests(ctx context.Context, labels map[string]string) ([]*manifest.EntryMetadata, error) {
 	uv := make(url.Values)
 
@@ -109,6 +134,10 @@ func (r *apiServerRepository) Username() string {
 	return r.username
 }
 
+func (r *apiServerRepository) ActionsEnabled() bool {
+	return r.actionsEnabled
+}
+
 func (r *apiServerRepository) Time() time.Time {
 	return time.Now() // allow:no-inject-time
 }
@@ -168,7 +197,7 @@ func (r *apiServerRepository) WriteContent(ctx context.Context, data []byte, pre
 var _ Repository = (*apiServerRepository)(nil)
 
 // openAPIServer connects remote repository over Kopia API.
-func openAPIServer(ctx context.Context, si *APIServerInfo, username, hostname, password string) (Repository, error) {
+func openAPIServer(ctx context.Context, si *APIServerInfo, username, hostname, password string, actionsEnabled bool) (Repository, error) {
 	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
 		BaseURL:                             si.BaseURL,
 		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
@@ -181,9 +210,10 @@ func openAPIServer(ctx context.Context, si *APIServerInfo, username, hostname, p
 	}
 
 	rr := &apiServerRepository{
-		cli:      cli,
-		username: username,
-		hostname: hostname,
+		cli:            cli,
+		username:       username,
+		hostname:       hostname,
+		actionsEnabled: actionsEnabled,
 	}
 
 	var p remoterepoapi.Parameters
@@ -213,9 +243,10 @@ func openAPIServer(ctx context.Context, si *APIServerInfo, username, hostname, p
 // ConnectAPIServer sets up repository connection to a particular API server.
 func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo, password string, opt *ConnectOptions) error {
 	lc := LocalConfig{
-		APIServer: si,
-		Hostname:  opt.HostnameOverride,
-		Username:  opt.UsernameOverride,
+		APIServer:     si,
+		Hostname:      opt.HostnameOverride,
+		Username:      opt.UsernameOverride,
+		EnableActions: opt.EnableActions,
 	}
 
 	if lc.Hostname == "" {
@@ -239,5 +270,5 @@ func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo,
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password, opt.PersistCredentials)
+	return verifyConnect(ctx, configFile, password, "", opt.PersistCredentials)
 }
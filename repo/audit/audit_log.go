@@ -0,0 +1,451 @@
+// Package audit implements append-only encrypted log of changes to manifests stored in the repository.
+//
+// Recording is opt-in and is enabled for the whole repository with Enable. Each record is stored in
+// a separate blob, has a sequence number one higher than the record before it and includes the ID
+// and hash of that record. The sequence number, ID and hash of the latest record are kept in the
+// head blob, so that removed, modified or inserted records can be detected, including removal of
+// the newest records, as long as the head blob itself is not rolled back to an earlier copy.
+//
+// Records older than the configured retention are pruned, oldest first, which is recorded in the head.
+package audit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// BlobIDPrefix is the prefix of blobs containing audit log records.
+const BlobIDPrefix blob.ID = "kopia.audit."
+
+// HeadBlobID is the ID of the blob containing the settings and the latest record of the audit log.
+const HeadBlobID blob.ID = "kopia.audit-head"
+
+// KeySize is the size of the key used to encrypt audit log records.
+const KeySize = 32
+
+// KeyDerivationPurpose is the purpose used to derive the audit log key from the master key.
+var KeyDerivationPurpose = []byte("AUDIT-LOG")
+
+var auditAEADExtraData = []byte("audit")
+
+// Record describes changes to manifests flushed together by a single client.
+type Record struct {
+	ID blob.ID `json:"-"`
+
+	Sequence uint64            `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Username string            `json:"username"`
+	Hostname string            `json:"hostname"`
+	Changes  []manifest.Change `json:"changes"`
+
+	// ID and hash of the record with the preceding sequence number, empty for the first record.
+	PreviousID   blob.ID `json:"previousID,omitempty"`
+	PreviousHash string  `json:"previousHash,omitempty"`
+}
+
+// Head describes the settings and the latest record of the audit log.
+type Head struct {
+	Enabled   bool          `json:"enabled"`
+	Retention time.Duration `json:"retention,omitempty"`
+
+	// Sequence number, ID and hash of the latest record.
+	Sequence   uint64  `json:"seq"`
+	LatestID   blob.ID `json:"latestID,omitempty"`
+	LatestHash string  `json:"latestHash,omitempty"`
+
+	// Records with lower sequence numbers were pruned.
+	FirstSequence uint64 `json:"firstSeq"`
+}
+
+// Log provides access to audit log records stored in the repository.
+type Log struct {
+	st      blob.Storage
+	aead    cipher.AEAD
+	timeNow func() time.Time
+
+	mu sync.Mutex
+}
+
+// Head returns the head of the audit log or nil if the audit log was never enabled.
+func (l *Log) Head(ctx context.Context) (*Head, error) {
+	v, err := l.st.GetBlob(ctx, HeadBlobID, 0, -1)
+	if err == blob.ErrBlobNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read audit log head")
+	}
+
+	j, err := l.open(HeadBlobID, v)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Head{}
+	if err := json.Unmarshal(j, h); err != nil {
+		return nil, errors.Errorf("malformed audit log head")
+	}
+
+	return h, nil
+}
+
+func (l *Log) setHead(ctx context.Context, h *Head) error {
+	j, err := json.Marshal(h)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize audit log head")
+	}
+
+	v, err := l.seal(HeadBlobID, j)
+	if err != nil {
+		return err
+	}
+
+	return l.st.PutBlob(ctx, HeadBlobID, gather.FromSlice(v))
+}
+
+// Enable starts recording changes to manifests, keeping records for the provided amount of time (0 == forever).
+func (l *Log) Enable(ctx context.Context, retention time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, err := l.Head(ctx)
+	if err != nil {
+		return err
+	}
+
+	if h == nil {
+		h = &Head{FirstSequence: 1}
+	}
+
+	h.Enabled = true
+	h.Retention = retention
+
+	return l.setHead(ctx, h)
+}
+
+// Disable stops recording changes to manifests, existing records are kept and can still be verified.
+func (l *Log) Disable(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, err := l.Head(ctx)
+	if err != nil || h == nil {
+		return err
+	}
+
+	h.Enabled = false
+
+	return l.setHead(ctx, h)
+}
+
+// Append writes a new record describing the provided changes, chained to the latest record.
+// It does nothing unless the audit log is enabled.
+func (l *Log) Append(ctx context.Context, username, hostname string, changes []manifest.Change) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, err := l.Head(ctx)
+	if err != nil {
+		return err
+	}
+
+	if h == nil || !h.Enabled {
+		return nil
+	}
+
+	r := &Record{
+		Sequence:     h.Sequence + 1,
+		Time:         l.timeNow().UTC(),
+		Username:     username,
+		Hostname:     hostname,
+		Changes:      changes,
+		PreviousID:   h.LatestID,
+		PreviousHash: h.LatestHash,
+	}
+
+	suffix := make([]byte, 4) //nolint:gomnd
+	if _, err := rand.Read(suffix); err != nil {
+		return errors.Wrap(err, "unable to generate random suffix")
+	}
+
+	// record IDs sort by sequence number, the suffix keeps records written concurrently by different clients apart.
+	r.ID = blob.ID(fmt.Sprintf("%v%016x%x", BlobIDPrefix, r.Sequence, suffix))
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize audit record")
+	}
+
+	// blob ID is authenticated, so that records can't be renamed.
+	v, err := l.seal(r.ID, j)
+	if err != nil {
+		return err
+	}
+
+	if err := l.st.PutBlob(ctx, r.ID, gather.FromSlice(v)); err != nil {
+		return errors.Wrapf(err, "unable to write audit record %v", r.ID)
+	}
+
+	h.Sequence = r.Sequence
+	h.LatestID = r.ID
+	h.LatestHash = hashOf(v)
+
+	return l.setHead(ctx, h)
+}
+
+// Records returns all records sorted by sequence number.
+func (l *Log) Records(ctx context.Context) ([]*Record, error) {
+	var result []*Record
+
+	err := l.iterate(ctx, func(r *Record, hash string, err error) error {
+		if err != nil {
+			return err
+		}
+
+		result = append(result, r)
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Prune removes records older than the retention, oldest first, and returns the number of removed records.
+// The latest record is always kept, so that the chain can still be verified.
+func (l *Log) Prune(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, err := l.Head(ctx)
+	if err != nil || h == nil || h.Retention == 0 {
+		return 0, err
+	}
+
+	cutoff := l.timeNow().Add(-h.Retention)
+
+	var expired []*Record
+
+	if err := l.iterate(ctx, func(r *Record, hash string, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if r.Sequence >= h.Sequence || !r.Time.Before(cutoff) {
+			return errStopIteration
+		}
+
+		expired = append(expired, r)
+
+		return nil
+	}); err != nil && err != errStopIteration {
+		return 0, err
+	}
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	// the head is updated first, so that records missing after an interrupted prune are still accounted for.
+	h.FirstSequence = expired[len(expired)-1].Sequence + 1
+	if err := l.setHead(ctx, h); err != nil {
+		return 0, err
+	}
+
+	for _, r := range expired {
+		if err := l.st.DeleteBlob(ctx, r.ID); err != nil && err != blob.ErrBlobNotFound {
+			return 0, errors.Wrapf(err, "unable to delete audit record %v", r.ID)
+		}
+	}
+
+	return len(expired), nil
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+// Verify reads the head and all records and returns descriptions of detected problems, such as records that
+// were modified, removed or inserted.
+func (l *Log) Verify(ctx context.Context) ([]string, error) {
+	h, err := l.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		problems []string
+		records  = map[blob.ID]*Record{}
+		hashes   = map[blob.ID]string{}
+		seen     = map[uint64]bool{}
+	)
+
+	if err := l.iterate(ctx, func(r *Record, hash string, err error) error {
+		if err != nil {
+			problems = append(problems, err.Error())
+			return nil
+		}
+
+		records[r.ID] = r
+		hashes[r.ID] = hash
+		seen[r.Sequence] = true
+
+		if h == nil {
+			return nil
+		}
+
+		prev := records[r.PreviousID]
+
+		switch {
+		case r.Sequence > h.Sequence:
+			problems = append(problems, fmt.Sprintf("record %v is newer than the latest record %v", r.ID, h.LatestID))
+
+		case r.Sequence <= h.FirstSequence:
+			// the preceding record was pruned.
+
+		case prev == nil:
+			problems = append(problems, fmt.Sprintf("record %v preceding %v is missing", r.PreviousID, r.ID))
+
+		case prev.Sequence+1 != r.Sequence:
+			problems = append(problems, fmt.Sprintf("record %v is chained to %v, which is out of sequence", r.ID, r.PreviousID))
+
+		case hashes[r.PreviousID] != r.PreviousHash:
+			problems = append(problems, fmt.Sprintf("record %v preceding %v was modified", r.PreviousID, r.ID))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if h == nil {
+		if len(records) > 0 || len(problems) > 0 {
+			problems = append(problems, "audit log head is missing")
+		}
+
+		return problems, nil
+	}
+
+	for s := h.FirstSequence; s <= h.Sequence; s++ {
+		if s > 0 && !seen[s] {
+			problems = append(problems, fmt.Sprintf("record with sequence number %v is missing", s))
+		}
+	}
+
+	if h.LatestID != "" && records[h.LatestID] != nil && hashes[h.LatestID] != h.LatestHash {
+		problems = append(problems, fmt.Sprintf("latest record %v was modified", h.LatestID))
+	}
+
+	return problems, nil
+}
+
+// iterate invokes the callback for all records sorted by sequence number, providing decryption error for records
+// that could not be read.
+func (l *Log) iterate(ctx context.Context, cb func(r *Record, hash string, err error) error) error {
+	var ids []blob.ID
+
+	if err := l.st.ListBlobs(ctx, BlobIDPrefix, func(bm blob.Metadata) error {
+		ids = append(ids, bm.BlobID)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to list audit records")
+	}
+
+	// sequence numbers are fixed-width hexadecimal, so IDs sort by sequence number.
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		v, err := l.st.GetBlob(ctx, id, 0, -1)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read audit record %v", id)
+		}
+
+		r, err := l.decrypt(id, v)
+		if err := cb(r, hashOf(v), err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Log) seal(id blob.ID, j []byte) ([]byte, error) {
+	nonce := make([]byte, l.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	return l.aead.Seal(append([]byte(nil), nonce...), nonce, j, extraData(id)), nil
+}
+
+func (l *Log) open(id blob.ID, v []byte) ([]byte, error) {
+	if len(v) < l.aead.NonceSize() {
+		return nil, errors.Errorf("invalid audit blob %v", id)
+	}
+
+	j, err := l.aead.Open(nil, v[0:l.aead.NonceSize()], v[l.aead.NonceSize():], extraData(id))
+	if err != nil {
+		return nil, errors.Errorf("unable to decrypt audit blob %v", id)
+	}
+
+	return j, nil
+}
+
+func (l *Log) decrypt(id blob.ID, v []byte) (*Record, error) {
+	j, err := l.open(id, v)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{}
+	if err := json.Unmarshal(j, r); err != nil {
+		return nil, errors.Errorf("malformed audit record %v", id)
+	}
+
+	r.ID = id
+
+	return r, nil
+}
+
+func extraData(id blob.ID) []byte {
+	return append(append([]byte(nil), auditAEADExtraData...), id...)
+}
+
+func hashOf(v []byte) string {
+	h := sha256.Sum256(v)
+	return hex.EncodeToString(h[:])
+}
+
+// NewLog returns audit log stored in the provided storage and encrypted using the provided key.
+func NewLog(st blob.Storage, key []byte, timeNow func() time.Time) (*Log, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256-GCM")
+	}
+
+	return &Log{
+		st:      st,
+		aead:    aead,
+		timeNow: timeNow,
+	}, nil
+}
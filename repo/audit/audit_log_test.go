@@ -0,0 +1,308 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+func newLogForTesting(t *testing.T, data blobtesting.DataMap, key []byte, timeNow func() time.Time) *Log {
+	t.Helper()
+
+	l, err := NewLog(overwritingStorage{blobtesting.NewMapStorage(data, nil, nil)}, key, timeNow)
+	if err != nil {
+		t.Fatalf("unable to create audit log: %v", err)
+	}
+
+	return l
+}
+
+// overwritingStorage replaces existing blobs on PutBlob, like real storage does.
+type overwritingStorage struct {
+	blob.Storage
+}
+
+func (s overwritingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if err := s.Storage.DeleteBlob(ctx, id); err != nil {
+		return err
+	}
+
+	return s.Storage.PutBlob(ctx, id, data)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	key := bytes.Repeat([]byte{1}, KeySize)
+	l := newLogForTesting(t, data, key, faketime.AutoAdvance(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Second))
+
+	// nothing is recorded until the audit log is enabled.
+	appendChanges(ctx, t, l, manifest.OperationPut, "x")
+
+	if len(data) != 0 {
+		t.Fatalf("unexpected blobs written by disabled audit log: %v", sortedBlobIDs(data))
+	}
+
+	verifyProblems(ctx, t, l, 0)
+
+	if err := l.Enable(ctx, 0); err != nil {
+		t.Fatalf("unable to enable: %v", err)
+	}
+
+	appendChanges(ctx, t, l, manifest.OperationPut, "a")
+	appendChanges(ctx, t, l, manifest.OperationDelete, "aa")
+	appendChanges(ctx, t, l, manifest.OperationPut, "aaa")
+
+	records := verifyRecords(ctx, t, l, []string{"put a", "delete aa", "put aaa"})
+	verifyProblems(ctx, t, l, 0)
+
+	for i, r := range records {
+		if got, want := r.Sequence, uint64(i+1); got != want {
+			t.Errorf("unexpected sequence number of %v: %v, want %v", r.ID, got, want)
+		}
+	}
+
+	if records[0].PreviousID != "" || records[1].PreviousID != records[0].ID || records[2].PreviousID != records[1].ID {
+		t.Errorf("records are not chained")
+	}
+
+	if got, want := records[1].Username+"@"+records[1].Hostname, "user@host"; got != want {
+		t.Errorf("unexpected user: %v, want %v", got, want)
+	}
+
+	ids := recordBlobIDs(data)
+
+	// record can't be read with a different key.
+	if _, err := newLogForTesting(t, data, bytes.Repeat([]byte{2}, KeySize), time.Now).Records(ctx); err == nil {
+		t.Errorf("unexpected success reading records with a different key")
+	}
+
+	// renamed record is detected, because it can't be decrypted and its sequence number is missing.
+	data[ids[2]+"0"] = data[ids[2]]
+	delete(data, ids[2])
+	verifyProblems(ctx, t, l, 2)
+	data[ids[2]] = data[ids[2]+"0"]
+	delete(data, ids[2]+"0")
+
+	// removed record is detected, including the latest one.
+	for i, id := range ids {
+		removed := data[id]
+		delete(data, id)
+
+		if i == len(ids)-1 {
+			verifyProblems(ctx, t, l, 1)
+		} else {
+			// the next record is also chained to a missing record.
+			verifyProblems(ctx, t, l, 2)
+		}
+
+		data[id] = removed
+		verifyProblems(ctx, t, l, 0)
+	}
+
+	// removed head is detected.
+	head := data[HeadBlobID]
+	delete(data, HeadBlobID)
+	verifyProblems(ctx, t, l, 1)
+
+	data[HeadBlobID] = head
+
+	// record replaced by someone knowing the key is detected using the hash stored in the next record or the head.
+	for i, id := range ids {
+		forged := *records[i]
+		forged.Username = "someone-else"
+
+		original := data[id]
+		data[id] = encryptRecordForTesting(t, l, &forged)
+		verifyProblems(ctx, t, l, 1)
+
+		data[id] = original
+	}
+
+	// after disabling, records are kept but no new records are written.
+	if err := l.Disable(ctx); err != nil {
+		t.Fatalf("unable to disable: %v", err)
+	}
+
+	appendChanges(ctx, t, l, manifest.OperationPut, "y")
+	verifyRecords(ctx, t, l, []string{"put a", "delete aa", "put aaa"})
+	verifyProblems(ctx, t, l, 0)
+}
+
+func TestAuditLogConcurrentWriters(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	key := bytes.Repeat([]byte{1}, KeySize)
+	l1 := newLogForTesting(t, data, key, time.Now)
+	l2 := newLogForTesting(t, data, key, time.Now)
+
+	if err := l1.Enable(ctx, 0); err != nil {
+		t.Fatalf("unable to enable: %v", err)
+	}
+
+	appendChanges(ctx, t, l1, manifest.OperationPut, "a")
+
+	// simulate two clients reading the same head and writing records with the same sequence number.
+	head := data[HeadBlobID]
+	appendChanges(ctx, t, l1, manifest.OperationPut, "b")
+	data[HeadBlobID] = head
+	appendChanges(ctx, t, l2, manifest.OperationPut, "c")
+	appendChanges(ctx, t, l2, manifest.OperationPut, "d")
+
+	if got, want := len(recordBlobIDs(data)), 4; got != want {
+		t.Fatalf("unexpected number of records: %v, want %v", got, want)
+	}
+
+	verifyProblems(ctx, t, l1, 0)
+}
+
+func TestAuditLogRetention(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	key := bytes.Repeat([]byte{1}, KeySize)
+	ft := faketime.NewTimeAdvance(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := newLogForTesting(t, data, key, ft.NowFunc())
+
+	if err := l.Enable(ctx, 24*time.Hour); err != nil {
+		t.Fatalf("unable to enable: %v", err)
+	}
+
+	appendChanges(ctx, t, l, manifest.OperationPut, "a")
+	appendChanges(ctx, t, l, manifest.OperationPut, "b")
+	ft.Advance(20 * time.Hour)
+	appendChanges(ctx, t, l, manifest.OperationPut, "c")
+
+	verifyPrune(ctx, t, l, 0)
+	ft.Advance(5 * time.Hour)
+	verifyPrune(ctx, t, l, 2)
+
+	verifyRecords(ctx, t, l, []string{"put c"})
+	verifyProblems(ctx, t, l, 0)
+
+	appendChanges(ctx, t, l, manifest.OperationPut, "d")
+	verifyProblems(ctx, t, l, 0)
+
+	// the latest record is always kept.
+	ft.Advance(48 * time.Hour)
+	verifyPrune(ctx, t, l, 1)
+	verifyRecords(ctx, t, l, []string{"put d"})
+	verifyProblems(ctx, t, l, 0)
+
+	// removal of the remaining record is still detected.
+	for _, id := range recordBlobIDs(data) {
+		delete(data, id)
+	}
+
+	verifyProblems(ctx, t, l, 1)
+}
+
+func appendChanges(ctx context.Context, t *testing.T, l *Log, op, id string) {
+	t.Helper()
+
+	if err := l.Append(ctx, "user", "host", []manifest.Change{
+		{Operation: op, ID: manifest.ID(id), Labels: map[string]string{"type": "policy"}},
+	}); err != nil {
+		t.Fatalf("unable to append: %v", err)
+	}
+}
+
+func encryptRecordForTesting(t *testing.T, l *Log, r *Record) []byte {
+	t.Helper()
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("unable to serialize: %v", err)
+	}
+
+	v, err := l.seal(r.ID, j)
+	if err != nil {
+		t.Fatalf("unable to encrypt: %v", err)
+	}
+
+	return v
+}
+
+func verifyPrune(ctx context.Context, t *testing.T, l *Log, want int) {
+	t.Helper()
+
+	n, err := l.Prune(ctx)
+	if err != nil {
+		t.Fatalf("unable to prune: %v", err)
+	}
+
+	if n != want {
+		t.Errorf("unexpected number of pruned records: %v, want %v", n, want)
+	}
+}
+
+func verifyRecords(ctx context.Context, t *testing.T, l *Log, want []string) []*Record {
+	t.Helper()
+
+	records, err := l.Records(ctx)
+	if err != nil {
+		t.Fatalf("unable to read records: %v", err)
+	}
+
+	var got []string
+
+	for _, r := range records {
+		for _, c := range r.Changes {
+			got = append(got, c.Operation+" "+string(c.ID))
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected changes: %v, want %v", got, want)
+	}
+
+	return records
+}
+
+func verifyProblems(ctx context.Context, t *testing.T, l *Log, want int) {
+	t.Helper()
+
+	problems, err := l.Verify(ctx)
+	if err != nil {
+		t.Fatalf("unable to verify: %v", err)
+	}
+
+	if len(problems) != want {
+		t.Errorf("unexpected problems: %v, want %v", problems, want)
+	}
+}
+
+func sortedBlobIDs(data blobtesting.DataMap) []blob.ID {
+	var ids []blob.ID
+	for id := range data {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func recordBlobIDs(data blobtesting.DataMap) []blob.ID {
+	var ids []blob.ID
+
+	for id := range data {
+		if strings.HasPrefix(string(id), string(BlobIDPrefix)) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
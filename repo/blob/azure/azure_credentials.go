@@ -0,0 +1,171 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+	"gocloud.dev/blob/azureblob"
+
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("azure")
+
+const (
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+	storageScope         = "https://storage.azure.com/.default"
+
+	// tokenRefreshMargin is how long before expiration access tokens are refreshed.
+	tokenRefreshMargin = 5 * time.Minute
+
+	// tokenRetryInterval is the delay before retrying failed token refresh.
+	tokenRetryInterval = 30 * time.Second
+)
+
+// federatedTokenCredentials obtains Azure AD access tokens by exchanging a federated token (OIDC, Kubernetes workload identity)
+// read from a file. The token file is re-read each time since it's typically rotated by the environment.
+type federatedTokenCredentials struct {
+	authorityHost string
+	tenantID      string
+	clientID      string
+	tokenFile     string
+	httpClient    *http.Client
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (f *federatedTokenCredentials) Credentials(ctx context.Context) (*blob.Credentials, error) {
+	assertion, err := ioutil.ReadFile(f.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read federated token")
+	}
+
+	form := url.Values{
+		"client_id":             {f.clientID},
+		"scope":                 {storageScope},
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+
+	tokenURL := strings.TrimSuffix(f.authorityHost, "/") + "/" + url.PathEscape(f.tenantID) + "/oauth2/v2.0/token"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create token request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	requestTime := time.Now() // allow:no-inject-time
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get access token")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to get access token: %v", resp.Status)
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, errors.Wrap(err, "invalid token response")
+	}
+
+	return &blob.Credentials{
+		Token:      tr.AccessToken,
+		Expiration: requestTime.Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// newTokenCredential returns Azure token credential which is periodically refreshed using the provided provider
+// until the returned stop function is called. Refreshes use a context detached from the provided one, which is
+// only used while the storage is being opened.
+func newTokenCredential(ctx context.Context, p blob.CredentialsProvider) (azblob.TokenCredential, func(), error) {
+	// fail early if credentials can't be obtained.
+	c, err := p.Credentials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshCtx := ctxutil.Detach(ctx)
+	stopped := make(chan struct{})
+
+	var stopOnce sync.Once
+
+	tc := azblob.NewTokenCredential(c.Token, func(tc azblob.TokenCredential) time.Duration {
+		select {
+		case <-stopped:
+			// zero duration stops the refresher.
+			return 0
+		default:
+		}
+
+		c, err := p.Credentials(refreshCtx)
+		if err != nil {
+			log(refreshCtx).Warningf("unable to refresh access token: %v", err)
+			return tokenRetryInterval
+		}
+
+		tc.SetToken(c.Token)
+
+		if d := time.Until(c.Expiration.Add(-tokenRefreshMargin)); d > tokenRetryInterval {
+			return d
+		}
+
+		return tokenRetryInterval
+	})
+
+	return tc, func() { stopOnce.Do(func() { close(stopped) }) }, nil
+}
+
+// newCredentials returns pipeline credential for the provided options, using either storage key or federated token.
+// Shared key credential is also returned, if available, along with the function stopping refreshes of the token.
+func newCredentials(ctx context.Context, opt *Options) (azblob.Credential, *azblob.SharedKeyCredential, func(), error) {
+	if opt.FederatedTokenFile == "" {
+		credential, err := azureblob.NewCredential(azureblob.AccountName(opt.StorageAccount), azureblob.AccountKey(opt.StorageKey))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return credential, credential, func() {}, nil
+	}
+
+	if opt.TenantID == "" || opt.ClientID == "" {
+		return nil, nil, nil, errors.New("tenant ID and client ID must be specified with federated token file")
+	}
+
+	authorityHost := opt.AuthorityHost
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+
+	tc, stop, err := newTokenCredential(ctx, blob.NewRefreshingCredentialsProvider(&federatedTokenCredentials{
+		authorityHost: authorityHost,
+		tenantID:      opt.TenantID,
+		clientID:      opt.ClientID,
+		tokenFile:     opt.FederatedTokenFile,
+		httpClient:    http.DefaultClient,
+	}, tokenRefreshMargin, nil))
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to obtain access token")
+	}
+
+	return tc, nil, stop, nil
+}
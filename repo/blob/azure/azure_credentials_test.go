@@ -0,0 +1,101 @@
+package azure
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestFederatedTokenCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-tenant/oauth2/v2.0/token" || r.FormValue("client_id") != "my-client" || r.FormValue("client_assertion") != "my-token" {
+			http.Error(w, "invalid request", http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"access_token":"access-token","expires_in":3600}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "az-token")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("my-token\n"), 0600); err != nil {
+		t.Fatalf("unable to write token: %v", err)
+	}
+
+	f := &federatedTokenCredentials{
+		authorityHost: srv.URL + "/",
+		tenantID:      "my-tenant",
+		clientID:      "my-client",
+		tokenFile:     tokenFile,
+		httpClient:    http.DefaultClient,
+	}
+
+	c, err := f.Credentials(ctx)
+	if err != nil {
+		t.Fatalf("unable to get credentials: %v", err)
+	}
+
+	if c.Token != "access-token" || c.Expiration.IsZero() {
+		t.Errorf("unexpected credentials: %v", c)
+	}
+
+	f.clientID = "other-client"
+	if _, err := f.Credentials(ctx); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+type recordingCredentialsProvider struct {
+	contexts []context.Context
+}
+
+func (p *recordingCredentialsProvider) Credentials(ctx context.Context) (*blob.Credentials, error) {
+	p.contexts = append(p.contexts, ctx)
+
+	return &blob.Credentials{Token: "token", Expiration: time.Now().Add(time.Hour)}, nil
+}
+
+func TestTokenCredentialRefreshContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(testlogging.Context(t))
+
+	p := &recordingCredentialsProvider{}
+
+	tc, stop, err := newTokenCredential(ctx, p)
+	if err != nil {
+		t.Fatalf("unable to create token credential: %v", err)
+	}
+
+	defer stop()
+
+	cancel()
+
+	if tc.Token() != "token" {
+		t.Errorf("unexpected token: %v", tc.Token())
+	}
+
+	// the first call opens the storage, the second one is the immediate refresh.
+	if len(p.contexts) != 2 {
+		t.Fatalf("unexpected number of credential requests: %v", len(p.contexts))
+	}
+
+	if err := p.contexts[1].Err(); err != nil {
+		t.Errorf("refresh context is canceled with the context used to open the storage: %v", err)
+	}
+}
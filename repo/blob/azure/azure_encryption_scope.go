@@ -0,0 +1,31 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+const (
+	encryptionScopeHeader = "x-ms-encryption-scope"
+
+	// encryptionScopeServiceVersion is the first service version which supports encryption scopes,
+	// newer than the version used by the SDK.
+	encryptionScopeServiceVersion = "2019-07-07"
+)
+
+// encryptionScopePipeline is a pipeline that adds encryption scope to all write requests.
+type encryptionScopePipeline struct {
+	pipeline.Pipeline
+	scope string
+}
+
+func (p *encryptionScopePipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	if request.Method == http.MethodPut {
+		request.Header.Set(encryptionScopeHeader, p.scope)
+		request.Header.Set("x-ms-version", encryptionScopeServiceVersion)
+	}
+
+	return p.Pipeline.Do(ctx, methodFactory, request)
+}
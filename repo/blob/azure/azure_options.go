@@ -12,6 +12,28 @@ type Options struct {
 	StorageAccount string `json:"storageAccount"`
 	StorageKey     string `json:"storageKey" kopia:"sensitive"`
 
+	// FederatedTokenFile, TenantID and ClientID cause Azure AD access tokens to be obtained by exchanging
+	// a federated token instead of using storage key, the tokens are refreshed automatically before they expire.
+	FederatedTokenFile string `json:"federatedTokenFile,omitempty"`
+	TenantID           string `json:"tenantID,omitempty"`
+	ClientID           string `json:"clientID,omitempty"`
+
+	// AuthorityHost is the URL of Azure AD authority used to obtain access tokens.
+	AuthorityHost string `json:"authorityHost,omitempty"`
+
 	MaxUploadSpeedBytesPerSecond   int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// EncryptionScope is the name of the encryption scope (which can be backed by a customer-managed key
+	// in Azure Key Vault) used to encrypt written blobs.
+	EncryptionScope string `json:"encryptionScope,omitempty"`
+
+	// ColdAccessTier is the access tier (Cool or Archive) of blobs written to cold storage tier, other blobs
+	// use the default access tier of the storage account.
+	ColdAccessTier string `json:"coldAccessTier,omitempty"`
+
+	// RehydrateAccessTier is the access tier (Hot or Cool) archived blobs are moved to when they are rehydrated
+	// before restore. Rehydrated blobs remain in that tier and are billed accordingly, they are not moved back
+	// to the archive tier. Archived blobs are not rehydrated unless it's set.
+	RehydrateAccessTier string `json:"rehydrateAccessTier,omitempty"`
 }
@@ -33,6 +33,8 @@ type azStorage struct {
 
 	downloadThrottler *iothrottler.IOThrottlerPool
 	uploadThrottler   *iothrottler.IOThrottlerPool
+
+	stopCredentialRefresh func()
 }
 
 func (az *azStorage) GetBlob(ctx context.Context, b blob.ID, offset, length int64) ([]byte, error) {
@@ -58,6 +60,10 @@ func (az *azStorage) GetBlob(ctx context.Context, b blob.ID, offset, length int6
 
 	v, err := exponentialBackoff(ctx, fmt.Sprintf("GetBlob(%q,%v,%v)", b, offset, length), attempt)
 	if err != nil {
+		if az.isServiceError(err, azblob.ServiceCodeBlobArchived) {
+			return nil, blob.ErrBlobArchived
+		}
+
 		return nil, translateError(err)
 	}
 
@@ -152,7 +158,24 @@ func (az *azStorage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) er
 	}
 
 	// calling close before cancel() causes it to commit the upload.
-	return translateError(writer.Close())
+	if err := writer.Close(); err != nil {
+		return translateError(err)
+	}
+
+	if tier := az.accessTier(ctx); tier != "" {
+		return az.setTier(ctx, b, tier)
+	}
+
+	return nil
+}
+
+// accessTier returns access tier of a blob written now, empty for the default access tier of the storage account.
+func (az *azStorage) accessTier(ctx context.Context) azblob.AccessTierType {
+	if blob.PutOptionsFromContext(ctx).StorageTier == blob.StorageTierCold {
+		return azblob.AccessTierType(az.ColdAccessTier)
+	}
+
+	return azblob.AccessTierNone
 }
 
 // DeleteBlob deletes azure blob from container with given ID
@@ -213,6 +236,8 @@ func (az *azStorage) ConnectionInfo() blob.ConnectionInfo {
 }
 
 func (az *azStorage) Close(ctx context.Context) error {
+	az.stopCredentialRefresh()
+
 	return az.bucket.Close()
 }
 
@@ -226,24 +251,47 @@ func toBandwidth(bytesPerSecond int) iothrottler.Bandwidth {
 
 // New creates new Azure Blob Storage-backed storage with specified options:
 //
-// - the 'Container', 'StorageAccount' and 'StorageKey' fields are required and all other parameters are optional.
+// - the 'Container' and 'StorageAccount' fields are required along with either 'StorageKey' or 'FederatedTokenFile',
+// all other parameters are optional.
 func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 	if opt.Container == "" {
 		return nil, errors.New("container name must be specified")
 	}
 
+	switch azblob.AccessTierType(opt.ColdAccessTier) {
+	case azblob.AccessTierNone, azblob.AccessTierCool, azblob.AccessTierArchive:
+	default:
+		return nil, errors.Errorf("invalid cold access tier %q", opt.ColdAccessTier)
+	}
+
+	switch azblob.AccessTierType(opt.RehydrateAccessTier) {
+	case azblob.AccessTierNone, azblob.AccessTierHot, azblob.AccessTierCool:
+	default:
+		return nil, errors.Errorf("invalid rehydrate access tier %q", opt.RehydrateAccessTier)
+	}
+
 	// create a credentials object.
-	credential, err := azureblob.NewCredential(azureblob.AccountName(opt.StorageAccount), azureblob.AccountKey(opt.StorageKey))
+	credential, sharedKeyCredential, stopCredentialRefresh, err := newCredentials(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
 
+	// shared key credential is only used for signing URLs, which kopia does not need.
+	var bucketOptions azureblob.Options
+	if sharedKeyCredential != nil {
+		bucketOptions.Credential = sharedKeyCredential
+	}
+
 	// create a Pipeline with credentials.
 	pipeline := azureblob.NewPipeline(credential, azblob.PipelineOptions{})
+	if opt.EncryptionScope != "" {
+		pipeline = &encryptionScopePipeline{pipeline, opt.EncryptionScope}
+	}
 
 	// create a *blob.Bucket.
-	bucket, err := azureblob.OpenBucket(ctx, pipeline, azureblob.AccountName(opt.StorageAccount), opt.Container, &azureblob.Options{Credential: credential})
+	bucket, err := azureblob.OpenBucket(ctx, pipeline, azureblob.AccountName(opt.StorageAccount), opt.Container, &bucketOptions)
 	if err != nil {
+		stopCredentialRefresh()
 		return nil, err
 	}
 
@@ -256,6 +304,8 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		bucket:            bucket,
 		downloadThrottler: downloadThrottler,
 		uploadThrottler:   uploadThrottler,
+
+		stopCredentialRefresh: stopCredentialRefresh,
 	}
 
 	// verify Azure connection is functional by listing blobs in a bucket, which will fail if the container
@@ -266,6 +316,7 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 	})
 
 	if err != nil {
+		az.Close(ctx) //nolint:errcheck
 		return nil, errors.Wrap(err, "unable to list from the bucket")
 	}
 
@@ -0,0 +1,85 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// archiveStatusRehydratePendingPrefix is the prefix of archive status of blobs being rehydrated, see
+// https://docs.microsoft.com/rest/api/storageservices/get-blob-properties
+const archiveStatusRehydratePendingPrefix = "rehydrate-pending-"
+
+func (az *azStorage) blobURL(b blob.ID) (azblob.BlobURL, error) {
+	var cu *azblob.ContainerURL
+	if !az.bucket.As(&cu) {
+		return azblob.BlobURL{}, errors.New("unable to access container URL")
+	}
+
+	return cu.NewBlobURL(az.getObjectNameString(b)), nil
+}
+
+func (az *azStorage) setTier(ctx context.Context, b blob.ID, tier azblob.AccessTierType) error {
+	bu, err := az.blobURL(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = exponentialBackoff(ctx, fmt.Sprintf("SetTier(%q,%v)", b, tier), func() (interface{}, error) {
+		return bu.SetTier(ctx, tier, azblob.LeaseAccessConditions{})
+	})
+
+	return errors.Wrapf(err, "unable to set access tier of %v", b)
+}
+
+// RehydrateBlob implements blob.Rehydrator by moving archived blobs to the access tier specified in options,
+// where they remain after the restore. Archived blobs can't be rehydrated unless the access tier is specified.
+func (az *azStorage) RehydrateBlob(ctx context.Context, b blob.ID) (bool, error) {
+	bu, err := az.blobURL(b)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := exponentialBackoff(ctx, fmt.Sprintf("GetProperties(%q)", b), func() (interface{}, error) {
+		return bu.GetProperties(ctx, azblob.BlobAccessConditions{})
+	})
+	if err != nil {
+		if se, ok := err.(azblob.StorageError); ok && se.Response() != nil && se.Response().StatusCode == http.StatusNotFound {
+			return false, blob.ErrBlobNotFound
+		}
+
+		return false, err
+	}
+
+	props := v.(*azblob.BlobGetPropertiesResponse)
+
+	if !strings.EqualFold(props.AccessTier(), string(azblob.AccessTierArchive)) {
+		return true, nil
+	}
+
+	if strings.HasPrefix(props.ArchiveStatus(), archiveStatusRehydratePendingPrefix) {
+		return false, nil
+	}
+
+	if az.RehydrateAccessTier == "" {
+		return false, errors.Errorf("blob %v is archived and rehydrate access tier is not configured", b)
+	}
+
+	return false, az.setTier(ctx, b, azblob.AccessTierType(az.RehydrateAccessTier))
+}
+
+// isServiceError determines whether the provided error was returned by Azure with the provided service code.
+func (az *azStorage) isServiceError(err error, code azblob.ServiceCodeType) bool {
+	var se azblob.StorageError
+	if !az.bucket.ErrorAs(err, &se) {
+		return false
+	}
+
+	return se.ServiceCode() == code
+}
@@ -0,0 +1,294 @@
+// Package caching implements a wrapper around Storage that keeps recently used blobs in a local cache storage.
+package caching
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("repo/caching")
+
+// touchThreshold is the minimum age of a cached blob before its timestamp is refreshed on access,
+// timestamps are only used to restore LRU order when the cache is reopened.
+const touchThreshold = 10 * time.Minute
+
+type blobToucher interface {
+	TouchBlob(ctx context.Context, blobID blob.ID, threshold time.Duration) error
+}
+
+type cachedBlob struct {
+	id     blob.ID
+	length int64
+}
+
+// cachingStorage is a read-through and write-through cache of immutable blobs.
+type cachingStorage struct {
+	base              blob.Storage
+	cache             blob.Storage
+	maxSizeBytes      int64
+	cacheablePrefixes []blob.ID
+
+	mu        sync.Mutex
+	lru       *list.List // of *cachedBlob, most recently used first
+	entries   map[blob.ID]*list.Element
+	totalSize int64
+}
+
+func (s *cachingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if !s.isCacheable(id) {
+		return s.base.GetBlob(ctx, id, offset, length)
+	}
+
+	if s.isCached(id) {
+		b, err := s.cache.GetBlob(ctx, id, 0, -1)
+		if err == nil {
+			s.touch(ctx, id)
+			return sliceBlob(b, offset, length)
+		}
+
+		log(ctx).Warningf("unable to read cached blob %v: %v", id, err)
+		s.remove(ctx, id)
+	}
+
+	// always fetch the entire blob, so that subsequent reads of other ranges are served from the cache.
+	b, err := s.base.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	s.add(ctx, id, b)
+
+	return sliceBlob(b, offset, length)
+}
+
+func sliceBlob(b []byte, offset, length int64) ([]byte, error) {
+	if length < 0 {
+		return b, nil
+	}
+
+	if offset < 0 || offset > int64(len(b)) {
+		return nil, errors.Errorf("invalid offset %v", offset)
+	}
+
+	if offset+length > int64(len(b)) {
+		return nil, errors.Errorf("invalid length %v", length)
+	}
+
+	return b[offset : offset+length], nil
+}
+
+func (s *cachingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *cachingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if !s.isCacheable(id) {
+		return s.base.PutBlob(ctx, id, data)
+	}
+
+	var buf bytes.Buffer
+
+	data.WriteTo(&buf) //nolint:errcheck
+
+	if err := s.base.PutBlob(ctx, id, gather.FromSlice(buf.Bytes())); err != nil {
+		return err
+	}
+
+	s.add(ctx, id, buf.Bytes())
+
+	return nil
+}
+
+func (s *cachingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	s.remove(ctx, id)
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *cachingStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	if s.isCached(id) {
+		return true, nil
+	}
+
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *cachingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *cachingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *cachingStorage) Close(ctx context.Context) error {
+	if err := s.cache.Close(ctx); err != nil {
+		log(ctx).Warningf("unable to close cache storage: %v", err)
+	}
+
+	return s.base.Close(ctx)
+}
+
+// isCacheable returns true if the provided blob is immutable and can be served from the cache.
+// Other blobs (such as "kopia.repository") may be rewritten in place by other clients and are always
+// accessed directly.
+func (s *cachingStorage) isCacheable(id blob.ID) bool {
+	for _, p := range s.cacheablePrefixes {
+		if strings.HasPrefix(string(id), string(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *cachingStorage) isCached(id blob.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if ok {
+		s.lru.MoveToFront(e)
+	}
+
+	return ok
+}
+
+func (s *cachingStorage) touch(ctx context.Context, id blob.ID) {
+	if t, ok := s.cache.(blobToucher); ok {
+		t.TouchBlob(ctx, id, touchThreshold) //nolint:errcheck
+	}
+}
+
+// add stores the provided blob in the cache and evicts least recently used blobs to stay within the size limit.
+func (s *cachingStorage) add(ctx context.Context, id blob.ID, data []byte) {
+	length := int64(len(data))
+	if length > s.maxSizeBytes {
+		return
+	}
+
+	// do not report cache writes as uploads.
+	if err := s.cache.PutBlob(blob.WithUploadProgressCallback(ctx, nil), id, gather.FromSlice(data)); err != nil {
+		log(ctx).Warningf("unable to write cache item %v: %v", id, err)
+		return
+	}
+
+	s.mu.Lock()
+	evicted := s.addLocked(id, length)
+	s.mu.Unlock()
+
+	s.deleteFromCache(ctx, evicted)
+}
+
+func (s *cachingStorage) addLocked(id blob.ID, length int64) []blob.ID {
+	if e, ok := s.entries[id]; ok {
+		s.lru.MoveToFront(e)
+		return nil
+	}
+
+	s.entries[id] = s.lru.PushFront(&cachedBlob{id, length})
+	s.totalSize += length
+
+	var evicted []blob.ID
+
+	for s.totalSize > s.maxSizeBytes {
+		oldest := s.lru.Back()
+		cb := oldest.Value.(*cachedBlob)
+
+		s.lru.Remove(oldest)
+		delete(s.entries, cb.id)
+		s.totalSize -= cb.length
+
+		evicted = append(evicted, cb.id)
+	}
+
+	return evicted
+}
+
+func (s *cachingStorage) remove(ctx context.Context, id blob.ID) {
+	s.mu.Lock()
+
+	e, ok := s.entries[id]
+	if ok {
+		s.lru.Remove(e)
+		delete(s.entries, id)
+		s.totalSize -= e.Value.(*cachedBlob).length
+	}
+
+	s.mu.Unlock()
+
+	if ok {
+		s.deleteFromCache(ctx, []blob.ID{id})
+	}
+}
+
+func (s *cachingStorage) deleteFromCache(ctx context.Context, ids []blob.ID) {
+	for _, id := range ids {
+		if err := s.cache.DeleteBlob(ctx, id); err != nil {
+			log(ctx).Warningf("unable to remove cache item %v: %v", id, err)
+		}
+	}
+}
+
+// loadExistingEntries populates LRU list with blobs already present in the cache storage,
+// using their timestamps to determine the order.
+func (s *cachingStorage) loadExistingEntries(ctx context.Context) error {
+	var existing []blob.Metadata
+
+	if err := s.cache.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		existing = append(existing, bm)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to list cache")
+	}
+
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].Timestamp.Before(existing[j].Timestamp)
+	})
+
+	var evicted []blob.ID
+
+	for _, bm := range existing {
+		if !s.isCacheable(bm.BlobID) {
+			// left behind by an older version which cached mutable blobs.
+			evicted = append(evicted, bm.BlobID)
+			continue
+		}
+
+		evicted = append(evicted, s.addLocked(bm.BlobID, bm.Length)...)
+	}
+
+	s.deleteFromCache(ctx, evicted)
+
+	return nil
+}
+
+// NewWrapper returns a Storage wrapper that serves blobs from the provided cache storage when possible,
+// keeping up to maxSizeBytes of most recently used blobs there. Only blobs with one of the provided
+// prefixes are cached, those must never be modified once written.
+func NewWrapper(ctx context.Context, base, cache blob.Storage, maxSizeBytes int64, cacheablePrefixes []blob.ID) (blob.Storage, error) {
+	s := &cachingStorage{
+		base:              base,
+		cache:             cache,
+		maxSizeBytes:      maxSizeBytes,
+		cacheablePrefixes: cacheablePrefixes,
+		lru:               list.New(),
+		entries:           map[blob.ID]*list.Element{},
+	}
+
+	if err := s.loadExistingEntries(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,208 @@
+package caching
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// cacheAll causes all blobs to be cached.
+var cacheAll = []blob.ID{""}
+
+// countingStorage counts GetBlob() calls made to the underlying storage.
+type countingStorage struct {
+	blob.Storage
+	getBlobCount int
+}
+
+func (s *countingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	s.getBlobCount++
+	return s.Storage.GetBlob(ctx, id, offset, length)
+}
+
+func TestCachingStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st, err := NewWrapper(ctx,
+		blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		1000, cacheAll)
+	if err != nil {
+		t.Fatalf("unable to create caching storage: %v", err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestCachingStorageReadThrough(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	baseData := blobtesting.DataMap{
+		"blob1": []byte{1, 2, 3, 4},
+		"blob2": []byte{5, 6, 7, 8},
+	}
+	cacheData := blobtesting.DataMap{}
+
+	base := &countingStorage{Storage: blobtesting.NewMapStorage(baseData, nil, nil)}
+
+	st, err := NewWrapper(ctx, base, blobtesting.NewMapStorage(cacheData, nil, nil), 1000, cacheAll)
+	if err != nil {
+		t.Fatalf("unable to create caching storage: %v", err)
+	}
+
+	blobtesting.AssertGetBlob(ctx, t, st, "blob1", []byte{1, 2, 3, 4})
+
+	if got, want := base.getBlobCount, 1; got != want {
+		t.Errorf("unexpected number of reads from base storage: %v, want %v", got, want)
+	}
+
+	if _, ok := cacheData["blob1"]; !ok {
+		t.Errorf("blob1 was not cached")
+	}
+
+	if err := st.PutBlob(ctx, "blob3", gather.FromSlice([]byte{9})); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	if _, ok := cacheData["blob3"]; !ok {
+		t.Errorf("blob3 was not cached on write")
+	}
+
+	blobtesting.AssertGetBlob(ctx, t, st, "blob3", []byte{9})
+
+	if got, want := base.getBlobCount, 1; got != want {
+		t.Errorf("unexpected number of reads from base storage: %v, want %v", got, want)
+	}
+
+	if err := st.DeleteBlob(ctx, "blob1"); err != nil {
+		t.Fatalf("unable to delete blob: %v", err)
+	}
+
+	if _, ok := cacheData["blob1"]; ok {
+		t.Errorf("blob1 was not removed from cache")
+	}
+
+	if _, err := st.GetBlob(ctx, "blob1", 0, -1); err != blob.ErrBlobNotFound {
+		t.Errorf("unexpected error when reading deleted blob: %v", err)
+	}
+}
+
+func TestCachingStorageEviction(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	baseData := blobtesting.DataMap{
+		"blob1": make([]byte, 40),
+		"blob2": make([]byte, 40),
+		"blob3": make([]byte, 40),
+		"large": make([]byte, 200),
+	}
+	cacheData := blobtesting.DataMap{}
+
+	st, err := NewWrapper(ctx,
+		blobtesting.NewMapStorage(baseData, nil, nil),
+		blobtesting.NewMapStorage(cacheData, nil, nil),
+		100, cacheAll)
+	if err != nil {
+		t.Fatalf("unable to create caching storage: %v", err)
+	}
+
+	mustGetBlob(ctx, t, st, "blob1")
+	mustGetBlob(ctx, t, st, "blob2")
+	mustGetBlob(ctx, t, st, "blob1") // blob2 is now least recently used
+	mustGetBlob(ctx, t, st, "blob3")
+	mustGetBlob(ctx, t, st, "large") // larger than the entire cache, not cached
+
+	assertCachedBlobs(t, cacheData, "blob1", "blob3")
+}
+
+func TestCachingStorageReopen(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cacheData := blobtesting.DataMap{
+		"blob1": make([]byte, 40),
+		"blob2": make([]byte, 40),
+		"blob3": make([]byte, 40),
+	}
+	cacheTimes := map[blob.ID]time.Time{
+		"blob1": t0.Add(2 * time.Second),
+		"blob2": t0,
+		"blob3": t0.Add(1 * time.Second),
+	}
+
+	// oldest blob is evicted when the cache is opened with a smaller size limit.
+	if _, err := NewWrapper(ctx,
+		blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		blobtesting.NewMapStorage(cacheData, cacheTimes, nil),
+		100, cacheAll); err != nil {
+		t.Fatalf("unable to create caching storage: %v", err)
+	}
+
+	assertCachedBlobs(t, cacheData, "blob1", "blob3")
+}
+
+func TestCachingStorageMutableBlobs(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	baseData := blobtesting.DataMap{
+		"kopia.repository": []byte{1, 2, 3, 4},
+		"p123":             []byte{5, 6, 7, 8},
+	}
+	cacheData := blobtesting.DataMap{
+		// stale copy of mutable blob left behind in the cache.
+		"kopia.maintenance": []byte{7},
+	}
+
+	st, err := NewWrapper(ctx,
+		blobtesting.NewMapStorage(baseData, nil, nil),
+		blobtesting.NewMapStorage(cacheData, nil, nil),
+		1000, []blob.ID{"p", "q", "n"})
+	if err != nil {
+		t.Fatalf("unable to create caching storage: %v", err)
+	}
+
+	assertCachedBlobs(t, cacheData)
+
+	blobtesting.AssertGetBlob(ctx, t, st, "kopia.repository", []byte{1, 2, 3, 4})
+	blobtesting.AssertGetBlob(ctx, t, st, "p123", []byte{5, 6, 7, 8})
+
+	// another client rewrites the format blob.
+	baseData["kopia.repository"] = []byte{9, 9}
+
+	blobtesting.AssertGetBlob(ctx, t, st, "kopia.repository", []byte{9, 9})
+
+	if err := st.PutBlob(ctx, "kopia.maintenance", gather.FromSlice([]byte{8, 8})); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	blobtesting.AssertGetBlob(ctx, t, st, "kopia.maintenance", []byte{8, 8})
+
+	assertCachedBlobs(t, cacheData, "p123")
+}
+
+func mustGetBlob(ctx context.Context, t *testing.T, st blob.Storage, id blob.ID) {
+	t.Helper()
+
+	if _, err := st.GetBlob(ctx, id, 0, -1); err != nil {
+		t.Fatalf("unable to get %v: %v", id, err)
+	}
+}
+
+func assertCachedBlobs(t *testing.T, cacheData blobtesting.DataMap, want ...blob.ID) {
+	t.Helper()
+
+	if got := len(cacheData); got != len(want) {
+		t.Errorf("unexpected number of cached blobs: %v, want %v", got, len(want))
+	}
+
+	for _, id := range want {
+		if _, ok := cacheData[id]; !ok {
+			t.Errorf("%v was not found in cache", id)
+		}
+	}
+}
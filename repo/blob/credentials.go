@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("kopia/blob")
+
+// Credentials represents credentials used to access storage, which may be temporary.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Token is a bearer token used by token-based storage providers.
+	Token string
+
+	// Expiration is the time when credentials expire, zero if they never do.
+	Expiration time.Time
+}
+
+// CredentialsProvider provides credentials to storage providers, which ask for them before each request
+// so that long-running operations can continue after the original credentials expire.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (*Credentials, error)
+}
+
+// CredentialsProviderFunc is a function that implements CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context) (*Credentials, error)
+
+// Credentials implements CredentialsProvider.
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+type refreshingCredentialsProvider struct {
+	base          CredentialsProvider
+	refreshMargin time.Duration
+	timeNow       func() time.Time
+
+	mu      sync.Mutex
+	current *Credentials
+}
+
+func (p *refreshingCredentialsProvider) Credentials(ctx context.Context) (*Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.timeNow()
+
+	if c := p.current; c != nil && (c.Expiration.IsZero() || now.Before(c.Expiration.Add(-p.refreshMargin))) {
+		return c, nil
+	}
+
+	c, err := p.base.Credentials(ctx)
+	if err != nil {
+		// keep using current credentials while they are still valid, refresh will be retried on next call.
+		if p.current != nil && now.Before(p.current.Expiration) {
+			log(ctx).Warningf("unable to refresh credentials, will retry: %v", err)
+			return p.current, nil
+		}
+
+		return nil, err
+	}
+
+	p.current = c
+
+	return c, nil
+}
+
+// NewRefreshingCredentialsProvider returns a CredentialsProvider that caches credentials returned by the provided
+// provider and refreshes them when they are within refreshMargin of their expiration.
+func NewRefreshingCredentialsProvider(base CredentialsProvider, refreshMargin time.Duration, timeNow func() time.Time) CredentialsProvider {
+	if timeNow == nil {
+		timeNow = time.Now // allow:no-inject-time
+	}
+
+	return &refreshingCredentialsProvider{
+		base:          base,
+		refreshMargin: refreshMargin,
+		timeNow:       timeNow,
+	}
+}
@@ -0,0 +1,73 @@
+package blob_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestRefreshingCredentialsProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+	ta := faketime.NewTimeAdvance(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var (
+		calls   int
+		failure error
+	)
+
+	p := blob.NewRefreshingCredentialsProvider(blob.CredentialsProviderFunc(func(ctx context.Context) (*blob.Credentials, error) {
+		if failure != nil {
+			return nil, failure
+		}
+
+		calls++
+
+		return &blob.Credentials{
+			Token:      "token",
+			Expiration: ta.NowFunc()().Add(time.Hour),
+		}, nil
+	}), 5*time.Minute, ta.NowFunc())
+
+	mustGetCredentials := func(wantCalls int) {
+		t.Helper()
+
+		if _, err := p.Credentials(ctx); err != nil {
+			t.Fatalf("unable to get credentials: %v", err)
+		}
+
+		if calls != wantCalls {
+			t.Fatalf("unexpected number of refreshes: %v, want %v", calls, wantCalls)
+		}
+	}
+
+	mustGetCredentials(1)
+	ta.Advance(50 * time.Minute)
+	mustGetCredentials(1)
+
+	// within refresh margin.
+	ta.Advance(6 * time.Minute)
+	mustGetCredentials(2)
+
+	// refresh failure while current credentials are still valid.
+	failure = errors.New("some error")
+
+	ta.Advance(56 * time.Minute)
+	mustGetCredentials(2)
+
+	// credentials expired.
+	ta.Advance(5 * time.Minute)
+
+	if _, err := p.Credentials(ctx); err == nil {
+		t.Fatalf("expected error after credentials expired")
+	}
+
+	failure = nil
+
+	mustGetCredentials(3)
+}
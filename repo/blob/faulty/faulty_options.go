@@ -0,0 +1,30 @@
+package faulty
+
+import (
+	"time"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Options defines options for storage with injected faults.
+// Probabilities are numbers between 0 (never) and 1 (always).
+type Options struct {
+	// Storage contains connection information of the underlying storage.
+	Storage blob.ConnectionInfo `json:"storage"`
+
+	// LatencyProbability is the probability of delaying each operation by a random duration up to MaxLatency.
+	LatencyProbability float64       `json:"latencyProbability,omitempty"`
+	MaxLatency         time.Duration `json:"maxLatency,omitempty"`
+
+	// DroppedWriteProbability is the probability of PutBlob() reporting success without writing the blob.
+	DroppedWriteProbability float64 `json:"droppedWriteProbability,omitempty"`
+
+	// TruncatedReadProbability is the probability of GetBlob() returning only a part of the requested data.
+	TruncatedReadProbability float64 `json:"truncatedReadProbability,omitempty"`
+
+	// NotFoundProbability is the probability of GetBlob() and GetMetadata() failing with blob.ErrBlobNotFound.
+	NotFoundProbability float64 `json:"notFoundProbability,omitempty"`
+
+	// Seed initializes the random number generator, which makes the sequence of faults reproducible.
+	Seed int64 `json:"seed,omitempty"`
+}
@@ -0,0 +1,189 @@
+// Package faulty implements a wrapper around Storage that injects random faults, which is useful for
+// validating that Kopia behaves correctly against unreliable storage providers.
+package faulty
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("repo/faulty")
+
+const faultyStorageType = "faulty"
+
+type faultyStorage struct {
+	base blob.Storage
+	opt  Options
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (s *faultyStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	s.maybeDelay(ctx)
+
+	if s.happens(s.opt.NotFoundProbability) {
+		log(ctx).Debugf("injecting not found error when reading %v", id)
+		return nil, blob.ErrBlobNotFound
+	}
+
+	data, err := s.base.GetBlob(ctx, id, offset, length)
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+
+	if s.happens(s.opt.TruncatedReadProbability) {
+		n := s.intn(len(data))
+		log(ctx).Debugf("injecting truncated read of %v, returning %v out of %v bytes", id, n, len(data))
+
+		return data[0:n], nil
+	}
+
+	return data, nil
+}
+
+func (s *faultyStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	s.maybeDelay(ctx)
+
+	if s.happens(s.opt.NotFoundProbability) {
+		log(ctx).Debugf("injecting not found error when getting metadata of %v", id)
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *faultyStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	s.maybeDelay(ctx)
+
+	if s.happens(s.opt.DroppedWriteProbability) {
+		log(ctx).Debugf("injecting dropped write of %v", id)
+		return nil
+	}
+
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *faultyStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	s.maybeDelay(ctx)
+
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *faultyStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	s.maybeDelay(ctx)
+
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *faultyStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *faultyStorage) ConnectionInfo() blob.ConnectionInfo {
+	opt := s.opt
+	opt.Storage = s.base.ConnectionInfo()
+
+	return blob.ConnectionInfo{
+		Type:   faultyStorageType,
+		Config: &opt,
+	}
+}
+
+func (s *faultyStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+// happens returns true with the provided probability.
+func (s *faultyStorage) happens(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.Float64() < probability
+}
+
+func (s *faultyStorage) intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.Intn(n)
+}
+
+func (s *faultyStorage) maybeDelay(ctx context.Context) {
+	if s.opt.MaxLatency <= 0 || !s.happens(s.opt.LatencyProbability) {
+		return
+	}
+
+	s.mu.Lock()
+	delay := time.Duration(s.rnd.Int63n(int64(s.opt.MaxLatency)))
+	s.mu.Unlock()
+
+	log(ctx).Debugf("injecting latency of %v", delay)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
+
+// NewWrapper returns a Storage wrapper that injects faults into operations on the provided storage
+// according to the provided options.
+func NewWrapper(st blob.Storage, opt Options) (blob.Storage, error) {
+	for _, p := range []float64{
+		opt.LatencyProbability,
+		opt.DroppedWriteProbability,
+		opt.TruncatedReadProbability,
+		opt.NotFoundProbability,
+	} {
+		if p < 0 || p > 1 {
+			return nil, errors.Errorf("invalid probability %v, must be between 0 and 1", p)
+		}
+	}
+
+	seed := opt.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano() // allow:no-inject-time
+	}
+
+	return &faultyStorage{
+		base: st,
+		opt:  opt,
+		rnd:  rand.New(rand.NewSource(seed)), //nolint:gosec
+	}, nil
+}
+
+// New creates new storage with injected faults by connecting to the underlying storage in the provided options.
+func New(ctx context.Context, opt *Options) (blob.Storage, error) {
+	st, err := blob.NewStorage(ctx, opt.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to underlying storage")
+	}
+
+	fs, err := NewWrapper(st, *opt)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func init() {
+	blob.AddSupportedStorage(
+		faultyStorageType,
+		func() interface{} { return &Options{} },
+		func(ctx context.Context, o interface{}) (blob.Storage, error) {
+			return New(ctx, o.(*Options))
+		})
+}
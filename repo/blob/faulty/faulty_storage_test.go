@@ -0,0 +1,83 @@
+package faulty
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestFaultyStorageNoFaults(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st, err := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{})
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestFaultyStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{
+		"existing": bytes.Repeat([]byte{1}, 100),
+	}
+	base := blobtesting.NewMapStorage(data, nil, nil)
+
+	newWrapper := func(opt Options) blob.Storage {
+		t.Helper()
+
+		opt.Seed = 1
+
+		st, err := NewWrapper(base, opt)
+		if err != nil {
+			t.Fatalf("unable to create wrapper: %v", err)
+		}
+
+		return st
+	}
+
+	st := newWrapper(Options{DroppedWriteProbability: 1})
+	if err := st.PutBlob(ctx, "dropped", gather.FromSlice([]byte{1, 2, 3})); err != nil {
+		t.Fatalf("unexpected error from dropped write: %v", err)
+	}
+
+	if _, ok := data["dropped"]; ok {
+		t.Fatalf("dropped write was persisted")
+	}
+
+	st = newWrapper(Options{NotFoundProbability: 1})
+	if _, err := st.GetBlob(ctx, "existing", 0, -1); err != blob.ErrBlobNotFound {
+		t.Fatalf("unexpected error reading blob: %v", err)
+	}
+
+	if _, err := st.GetMetadata(ctx, "existing"); err != blob.ErrBlobNotFound {
+		t.Fatalf("unexpected error getting metadata: %v", err)
+	}
+
+	st = newWrapper(Options{TruncatedReadProbability: 1})
+
+	v, err := st.GetBlob(ctx, "existing", 0, -1)
+	if err != nil {
+		t.Fatalf("unexpected error reading blob: %v", err)
+	}
+
+	if len(v) >= len(data["existing"]) {
+		t.Fatalf("read was not truncated, got %v bytes", len(v))
+	}
+}
+
+func TestFaultyStorageInvalidOptions(t *testing.T) {
+	for _, opt := range []Options{
+		{LatencyProbability: -1},
+		{DroppedWriteProbability: 1.5},
+	} {
+		if _, err := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), opt); err == nil {
+			t.Errorf("expected error for %+v", opt)
+		}
+	}
+}
@@ -13,6 +13,10 @@ type Options struct {
 
 	FileUID *int `json:"uid,omitempty"`
 	FileGID *int `json:"gid,omitempty"`
+
+	// SyncWrites causes written blobs and their directories to be flushed to stable storage before PutBlob() returns,
+	// so that power loss can't leave partially-written blobs behind.
+	SyncWrites bool `json:"syncWrites,omitempty"`
 }
 
 func (fso *Options) fileMode() os.FileMode {
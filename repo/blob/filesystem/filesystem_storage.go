@@ -122,10 +122,6 @@ func (fs *fsImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, off
 
 func (fs *fsImpl) GetMetadataFromPath(ctx context.Context, dirPath, path string) (blob.Metadata, error) {
 	fi, err := os.Stat(path) //nolint:gosec
-	if err != nil {
-		return blob.Metadata{}, err
-	}
-
 	if err != nil {
 		if os.IsNotExist(err) {
 			return blob.Metadata{}, blob.ErrBlobNotFound
@@ -167,6 +163,13 @@ func (fs *fsImpl) PutBlobInPath(ctx context.Context, dirPath, path string, data
 			return errors.Wrap(err, "can't write temporary file")
 		}
 
+		if fs.SyncWrites {
+			if err = f.Sync(); err != nil {
+				f.Close() //nolint:errcheck
+				return errors.Wrap(err, "can't sync temporary file")
+			}
+		}
+
 		if err = f.Close(); err != nil {
 			return errors.Wrap(err, "can't close temporary file")
 		}
@@ -180,6 +183,12 @@ func (fs *fsImpl) PutBlobInPath(ctx context.Context, dirPath, path string, data
 			return err
 		}
 
+		if fs.SyncWrites {
+			if err = syncDirectory(filepath.Dir(path)); err != nil {
+				return errors.Wrap(err, "can't sync directory")
+			}
+		}
+
 		if fs.FileUID != nil && fs.FileGID != nil && os.Geteuid() == 0 {
 			if chownErr := os.Chown(path, *fs.FileUID, *fs.FileGID); chownErr != nil {
 				log(ctx).Warningf("can't change file permissions: %v", chownErr)
@@ -195,7 +204,7 @@ func (fs *fsImpl) createTempFileAndDir(tempFile string) (*os.File, error) {
 
 	f, err := os.OpenFile(tempFile, flags, fs.fileMode())
 	if os.IsNotExist(err) {
-		if err = os.MkdirAll(filepath.Dir(tempFile), fs.dirMode()); err != nil {
+		if err = fs.mkdirAll(filepath.Dir(tempFile)); err != nil {
 			return nil, errors.Wrap(err, "cannot create directory")
 		}
 
@@ -205,6 +214,48 @@ func (fs *fsImpl) createTempFileAndDir(tempFile string) (*os.File, error) {
 	return f, err
 }
 
+// mkdirAll creates the directory along with missing parents. When writes are synced, parents of created
+// directories are synced as well, since otherwise entries of new directories may be lost on power failure
+// even though blobs in them have been synced.
+func (fs *fsImpl) mkdirAll(dirPath string) error {
+	var created []string
+
+	if fs.SyncWrites {
+		created = missingDirectories(dirPath)
+	}
+
+	if err := os.MkdirAll(dirPath, fs.dirMode()); err != nil {
+		return err
+	}
+
+	for _, d := range created {
+		if err := syncDirectory(filepath.Dir(d)); err != nil {
+			return errors.Wrap(err, "can't sync directory")
+		}
+	}
+
+	return nil
+}
+
+// missingDirectories returns the provided directory and its parents which don't exist, starting with the deepest.
+func missingDirectories(dirPath string) []string {
+	var result []string
+
+	for d := dirPath; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			break
+		}
+
+		result = append(result, d)
+
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	return result
+}
+
 func (fs *fsImpl) DeleteBlobInPath(ctx context.Context, dirPath, path string) error {
 	return retry.WithExponentialBackoffNoValue(ctx, "DeleteBlobInPath:"+path, func() error {
 		err := os.Remove(path)
@@ -287,3 +338,20 @@ func init() {
 			return New(ctx, o.(*Options))
 		})
 }
+
+// syncDirectory flushes directory entries to stable storage, which makes preceding renames durable.
+func syncDirectory(dirPath string) error {
+	// Windows does not support syncing directories.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dirPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	defer d.Close() //nolint:errcheck
+
+	return d.Sync()
+}
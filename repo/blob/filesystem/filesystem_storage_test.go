@@ -3,6 +3,7 @@ package filesystem
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
@@ -51,6 +52,54 @@ func TestFileStorage(t *testing.T) {
 	}
 }
 
+func TestFileStorageSyncWrites(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	path, _ := ioutil.TempDir("", "r-fs")
+	defer os.RemoveAll(path)
+
+	r, err := New(ctx, &Options{
+		Path:       path,
+		SyncWrites: true,
+	})
+
+	if r == nil || err != nil {
+		t.Errorf("unexpected result: %v %v", r, err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, r)
+	blobtesting.AssertConnectionInfoRoundTrips(ctx, t, r)
+
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestMissingDirectories(t *testing.T) {
+	path, _ := ioutil.TempDir("", "r-fs")
+	defer os.RemoveAll(path)
+
+	a := filepath.Join(path, "a")
+	ab := filepath.Join(a, "b")
+	abc := filepath.Join(ab, "c")
+
+	if got, want := missingDirectories(abc), []string{abc, ab, a}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected missing directories: %v, want %v", got, want)
+	}
+
+	if err := os.Mkdir(a, 0700); err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+
+	if got, want := missingDirectories(abc), []string{abc, ab}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected missing directories: %v, want %v", got, want)
+	}
+
+	if got := missingDirectories(a); len(got) != 0 {
+		t.Errorf("unexpected missing directories: %v", got)
+	}
+}
+
 const (
 	t1 = "392ee1bc299db9f235e046a62625afb84902"
 	t2 = "2a7ff4f29eddbcd4c18fa9e73fec20bbb71f"
@@ -22,4 +22,8 @@ type Options struct {
 	MaxUploadSpeedBytesPerSecond int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// KMSKeyName is the resource name of the Cloud KMS key used to encrypt written blobs (CMEK),
+	// in the form projects/P/locations/L/keyRings/R/cryptoKeys/K.
+	KMSKeyName string `json:"kmsKeyName,omitempty"`
 }
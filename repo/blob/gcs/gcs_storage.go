@@ -127,6 +127,7 @@ func (gcs *gcsStorage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes)
 	writer := obj.NewWriter(ctx)
 	writer.ChunkSize = writerChunkSize
 	writer.ContentType = "application/x-kopia"
+	writer.KMSKeyName = gcs.KMSKeyName
 
 	combinedLength := data.Length()
 	progressCallback := blob.ProgressCallback(ctx)
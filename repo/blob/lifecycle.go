@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// LifecyclePolicy describes storage-side lifecycle rules for blobs of a repository. Blobs are never expired
+// by the rules, since only kopia maintenance can determine when it's safe to delete them.
+type LifecyclePolicy struct {
+	// Prefix is the prefix of names of all objects of the repository in the bucket.
+	Prefix string
+
+	// AbortIncompleteUploadsAfterDays is the number of days after which incomplete multipart uploads are aborted,
+	// zero disables the rule.
+	AbortIncompleteUploadsAfterDays int
+
+	// TransitionBlobPrefix is the prefix of IDs of blobs which are moved to TransitionStorageClass
+	// TransitionAfterDays after they have been written, zero days disables the rule.
+	TransitionBlobPrefix   ID
+	TransitionAfterDays    int
+	TransitionStorageClass string
+}
+
+// LifecyclePolicyGenerator returns a provider-specific document describing lifecycle rules of the provided policy,
+// which can be applied to the bucket using provider tools, or an error if the policy isn't safe to use.
+type LifecyclePolicyGenerator func(p *LifecyclePolicy) ([]byte, error)
+
+// LifecyclePolicyApplier is implemented by storage providers which can apply lifecycle policy to their bucket,
+// replacing lifecycle rules previously applied for the same prefix and preserving all other rules.
+type LifecyclePolicyApplier interface {
+	ApplyLifecyclePolicy(ctx context.Context, p *LifecyclePolicy) error
+}
+
+var lifecyclePolicyGenerators = map[string]LifecyclePolicyGenerator{}
+
+// AddLifecyclePolicyGenerator registers lifecycle policy generator for a given provider name.
+func AddLifecyclePolicyGenerator(provider string, g LifecyclePolicyGenerator) {
+	lifecyclePolicyGenerators[provider] = g
+}
+
+// LifecyclePolicyProviders returns the sorted list of providers with registered lifecycle policy generators.
+func LifecyclePolicyProviders() []string {
+	var result []string
+
+	for k := range lifecyclePolicyGenerators {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// GenerateLifecyclePolicy returns the document describing lifecycle rules of the provided policy for a given provider.
+func GenerateLifecyclePolicy(provider string, p *LifecyclePolicy) ([]byte, error) {
+	g := lifecyclePolicyGenerators[provider]
+	if g == nil {
+		return nil, errors.Errorf("lifecycle policy is not supported for %q, supported providers: %v", provider, LifecyclePolicyProviders())
+	}
+
+	return g(p)
+}
+
+// Validate returns an error if the policy is invalid regardless of the provider.
+func (p *LifecyclePolicy) Validate() error {
+	if p.AbortIncompleteUploadsAfterDays < 0 || p.TransitionAfterDays < 0 {
+		return errors.Errorf("number of days can't be negative")
+	}
+
+	if p.TransitionAfterDays > 0 && p.TransitionStorageClass == "" {
+		return errors.Errorf("transition storage class must be specified")
+	}
+
+	if p.AbortIncompleteUploadsAfterDays == 0 && p.TransitionAfterDays == 0 {
+		return errors.Errorf("lifecycle policy has no rules")
+	}
+
+	return nil
+}
+
+// ApplyLifecyclePolicy applies lifecycle policy to the bucket of storage that implements LifecyclePolicyApplier.
+func ApplyLifecyclePolicy(ctx context.Context, st Storage, p *LifecyclePolicy) error {
+	a, ok := st.(LifecyclePolicyApplier)
+	if !ok {
+		return errors.Errorf("storage %q does not support applying lifecycle policy", st.ConnectionInfo().Type)
+	}
+
+	return a.ApplyLifecyclePolicy(ctx, p)
+}
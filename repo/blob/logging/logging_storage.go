@@ -57,6 +57,15 @@ func (s *loggingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err
 }
 
+func (s *loggingStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	t0 := time.Now()
+	ready, err := blob.RehydrateBlob(ctx, s.base, id)
+	dt := time.Since(t0)
+	s.printf(s.prefix+"RehydrateBlob(%q)=(%v, %#v) took %v", id, ready, err, dt)
+
+	return ready, err
+}
+
 func (s *loggingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
 	t0 := time.Now()
 	cnt := 0
@@ -0,0 +1,9 @@
+package mirror
+
+import "github.com/kopia/kopia/repo/blob"
+
+// Options defines options for mirror storage.
+type Options struct {
+	// Storages contains connection information for all mirrors, reads are attempted in order.
+	Storages []blob.ConnectionInfo `json:"storages"`
+}
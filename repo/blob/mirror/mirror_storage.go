@@ -0,0 +1,188 @@
+// Package mirror implements Storage which replicates all writes to multiple underlying storages.
+package mirror
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("repo/mirror")
+
+const mirrorStorageType = "mirror"
+
+// mirrorStorage writes each blob to all underlying storages and reads from the first one that succeeds.
+type mirrorStorage struct {
+	storages []blob.Storage
+}
+
+func (s *mirrorStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	var firstErr error
+
+	for i, st := range s.storages {
+		b, err := st.GetBlob(ctx, id, offset, length)
+		if err == nil {
+			return b, nil
+		}
+
+		if err != blob.ErrBlobNotFound {
+			log(ctx).Warningf("unable to read %v from mirror #%v, trying next one: %v", id, i, err)
+		}
+
+		firstErr = preferredError(firstErr, err)
+	}
+
+	return nil, firstErr
+}
+
+func (s *mirrorStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	var firstErr error
+
+	for i, st := range s.storages {
+		m, err := st.GetMetadata(ctx, id)
+		if err == nil {
+			return m, nil
+		}
+
+		if err != blob.ErrBlobNotFound {
+			log(ctx).Warningf("unable to get metadata of %v from mirror #%v, trying next one: %v", id, i, err)
+		}
+
+		firstErr = preferredError(firstErr, err)
+	}
+
+	return blob.Metadata{}, firstErr
+}
+
+// preferredError returns the error that should be reported to the caller when all mirrors failed,
+// ErrBlobNotFound is only returned when no mirror failed with a different error.
+func preferredError(prev, err error) error {
+	if prev == nil || prev == blob.ErrBlobNotFound {
+		return err
+	}
+
+	return prev
+}
+
+func (s *mirrorStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	return s.forEachStorage(ctx, func(ctx context.Context, i int, st blob.Storage) error {
+		return errors.Wrapf(st.PutBlob(ctx, id, data), "error writing %v to mirror #%v", id, i)
+	})
+}
+
+func (s *mirrorStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	return s.forEachStorage(ctx, func(ctx context.Context, i int, st blob.Storage) error {
+		return errors.Wrapf(st.DeleteBlob(ctx, id), "error deleting %v from mirror #%v", id, i)
+	})
+}
+
+// forEachStorage invokes the provided callback for all underlying storages in parallel and returns the first error.
+func (s *mirrorStorage) forEachStorage(ctx context.Context, cb func(ctx context.Context, i int, st blob.Storage) error) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for i, st := range s.storages {
+		i, st := i, st
+
+		eg.Go(func() error {
+			return cb(ctx, i, st)
+		})
+	}
+
+	return eg.Wait()
+}
+
+func (s *mirrorStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	var lastErr error
+
+	for i, st := range s.storages {
+		delivered := false
+
+		err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			delivered = true
+			return callback(bm)
+		})
+		if err == nil {
+			return nil
+		}
+
+		if delivered {
+			// some results have already been passed to the callback, can't fall back to another mirror
+			// without reporting duplicates.
+			return err
+		}
+
+		log(ctx).Warningf("unable to list blobs with prefix %q in mirror #%v, trying next one: %v", prefix, i, err)
+
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+func (s *mirrorStorage) ConnectionInfo() blob.ConnectionInfo {
+	opt := &Options{}
+
+	for _, st := range s.storages {
+		opt.Storages = append(opt.Storages, st.ConnectionInfo())
+	}
+
+	return blob.ConnectionInfo{
+		Type:   mirrorStorageType,
+		Config: opt,
+	}
+}
+
+func (s *mirrorStorage) Close(ctx context.Context) error {
+	var firstErr error
+
+	for _, st := range s.storages {
+		if err := st.Close(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// NewWrapper returns a Storage that writes all blobs to all provided storages and reads them
+// from the first storage that is able to return them.
+func NewWrapper(storages ...blob.Storage) blob.Storage {
+	return &mirrorStorage{storages}
+}
+
+// New creates new mirror storage by connecting to all storages in the provided options.
+func New(ctx context.Context, opt *Options) (blob.Storage, error) {
+	if len(opt.Storages) == 0 {
+		return nil, errors.New("at least one storage must be provided")
+	}
+
+	var storages []blob.Storage
+
+	for i, ci := range opt.Storages {
+		st, err := blob.NewStorage(ctx, ci)
+		if err != nil {
+			for _, prev := range storages {
+				prev.Close(ctx) //nolint:errcheck
+			}
+
+			return nil, errors.Wrapf(err, "unable to connect to mirror #%v", i)
+		}
+
+		storages = append(storages, st)
+	}
+
+	return NewWrapper(storages...), nil
+}
+
+func init() {
+	blob.AddSupportedStorage(
+		mirrorStorageType,
+		func() interface{} { return &Options{} },
+		func(ctx context.Context, o interface{}) (blob.Storage, error) {
+			return New(ctx, o.(*Options))
+		})
+}
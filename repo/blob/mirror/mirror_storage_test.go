@@ -0,0 +1,92 @@
+package mirror
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestMirrorStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data1 := blobtesting.DataMap{}
+	data2 := blobtesting.DataMap{}
+
+	st := NewWrapper(
+		blobtesting.NewMapStorage(data1, nil, nil),
+		blobtesting.NewMapStorage(data2, nil, nil),
+	)
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	if len(data1) == 0 {
+		t.Fatalf("nothing was written")
+	}
+
+	if len(data1) != len(data2) {
+		t.Errorf("mirrors have different number of blobs: %v and %v", len(data1), len(data2))
+	}
+
+	for k, v := range data1 {
+		if !bytes.Equal(data2[k], v) {
+			t.Errorf("blob %v was not mirrored", k)
+		}
+	}
+
+	if got, want := len(st.ConnectionInfo().Config.(*Options).Storages), 2; got != want {
+		t.Errorf("unexpected number of storages in connection info: %v, want %v", got, want)
+	}
+}
+
+func TestMirrorStorageFailover(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	someError := errors.New("some error")
+
+	data1 := blobtesting.DataMap{}
+	data2 := blobtesting.DataMap{}
+
+	primary := &blobtesting.FaultyStorage{
+		Base: blobtesting.NewMapStorage(data1, nil, nil),
+	}
+
+	st := NewWrapper(primary, blobtesting.NewMapStorage(data2, nil, nil))
+
+	if err := st.PutBlob(ctx, "blob1", gather.FromSlice([]byte{1, 2, 3, 4})); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	primary.Faults = map[string][]*blobtesting.Fault{
+		"GetBlob":     {{Err: someError}},
+		"GetMetadata": {{Err: someError}},
+		"ListBlobs":   {{Err: someError}},
+		"PutBlob":     {{Err: someError}},
+	}
+
+	blobtesting.AssertGetBlob(ctx, t, st, "blob1", []byte{1, 2, 3, 4})
+	blobtesting.AssertListResults(ctx, t, st, "", "blob1")
+
+	if _, err := st.GetMetadata(ctx, "blob1"); err != nil {
+		t.Errorf("unexpected error getting metadata: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, "blob2", gather.FromSlice([]byte{1})); errors.Cause(err) != someError {
+		t.Errorf("unexpected error writing to failed mirror: %v", err)
+	}
+
+	// blob missing in the primary only.
+	delete(data1, "blob1")
+	blobtesting.AssertGetBlob(ctx, t, st, "blob1", []byte{1, 2, 3, 4})
+
+	delete(data2, "blob1")
+
+	if _, err := st.GetBlob(ctx, "blob1", 0, -1); err != blob.ErrBlobNotFound {
+		t.Errorf("unexpected error when blob is not found in any mirror: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultListParallelism is the default number of concurrent ListBlobs() calls used to list large storage.
+const DefaultListParallelism = 16
+
+// HexDigits are characters of lowercase hexadecimal strings, which follow the prefix in IDs of blobs
+// named after random or hashed bytes.
+const HexDigits = "0123456789abcdef"
+
+// SplitPrefix returns the prefix followed by each character of the alphabet, which together cover
+// all blobs with IDs longer than the prefix, as long as the alphabet includes all characters that can follow it.
+func SplitPrefix(prefix ID, alphabet string) []ID {
+	var result []ID
+
+	for _, ch := range alphabet {
+		result = append(result, prefix+ID(ch))
+	}
+
+	return result
+}
+
+// IterateAllBlobsInParallel invokes the callback for all blobs with the provided prefixes, listed using up to
+// 'parallelism' concurrent ListBlobs() calls. Each prefix is split into sub-prefixes using the alphabet returned
+// by shardAlphabet, which must include all characters that can follow that prefix in blob IDs. Prefixes for
+// which the alphabet is not known (empty) are listed with a single ListBlobs() call.
+// Callback invocations are serialized, but the order of blobs is not defined.
+func IterateAllBlobsInParallel(ctx context.Context, parallelism int, st Storage, prefixes []ID, shardAlphabet func(prefix ID) string, callback func(Metadata) error) error {
+	if parallelism <= 1 {
+		for _, prefix := range prefixes {
+			if err := st.ListBlobs(ctx, prefix, callback); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var mu sync.Mutex
+
+	serializedCallback := func(bm Metadata) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return callback(bm)
+	}
+
+	var shards []ID
+
+	for _, prefix := range prefixes {
+		alphabet := shardAlphabet(prefix)
+		if alphabet == "" {
+			shards = append(shards, prefix)
+			continue
+		}
+
+		// sub-prefixes don't cover the blob whose ID is equal to the prefix.
+		if prefix != "" {
+			bm, err := st.GetMetadata(ctx, prefix)
+
+			switch {
+			case err == nil:
+				if err := serializedCallback(bm); err != nil {
+					return err
+				}
+
+			case err != ErrBlobNotFound:
+				return err
+			}
+		}
+
+		shards = append(shards, SplitPrefix(prefix, alphabet)...)
+	}
+
+	return IterateAllPrefixesInParallel(ctx, parallelism, st, shards, serializedCallback)
+}
+
+// ListAllBlobsInParallel returns Metadata for all blobs with the provided prefix, listed using up to 'parallelism'
+// concurrent ListBlobs() calls when the alphabet of characters following the prefix is known.
+func ListAllBlobsInParallel(ctx context.Context, parallelism int, st Storage, prefix ID, shardAlphabet func(prefix ID) string) ([]Metadata, error) {
+	var result []Metadata
+
+	err := IterateAllBlobsInParallel(ctx, parallelism, st, []ID{prefix}, shardAlphabet, func(bm Metadata) error {
+		result = append(result, bm)
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,124 @@
+package blob_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestListAllBlobsInParallel(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, time.Now)
+
+	for _, id := range []blob.ID{
+		"kopia.repository",
+		"kopia.maintenance",
+		"n0123",
+		"p",
+		"p0123",
+		"pabcd",
+		"pf000",
+		"q1234",
+		"xn0_abcd",
+		"s0123-c1",
+		"Xyz",
+		"kopia.UPPER",
+		"~tilde",
+	} {
+		if err := st.PutBlob(ctx, id, gather.FromSlice([]byte{1, 2, 3})); err != nil {
+			t.Fatalf("unable to put %v: %v", id, err)
+		}
+	}
+
+	for _, prefix := range []blob.ID{"", "p", "n", "pf", "kopia.", "kopia.repository", "no-such-prefix"} {
+		want, err := blob.ListAllBlobs(ctx, st, prefix)
+		if err != nil {
+			t.Fatalf("error listing %q: %v", prefix, err)
+		}
+
+		for _, parallel := range []int{0, 1, 4, 16} {
+			got, err := blob.ListAllBlobsInParallel(ctx, parallel, st, prefix, hexAlphabetForPacks)
+			if err != nil {
+				t.Fatalf("error listing %q in parallel: %v", prefix, err)
+			}
+
+			if !reflect.DeepEqual(sortedIDs(got), sortedIDs(want)) {
+				t.Errorf("unexpected blobs with prefix %q and parallelism %v: %v, want %v", prefix, parallel, sortedIDs(got), sortedIDs(want))
+			}
+		}
+	}
+}
+
+func TestListAllBlobsInParallelShardsOnlyKnownPrefixes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := &listCountingStorage{Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, time.Now)}
+
+	if _, err := blob.ListAllBlobsInParallel(ctx, 16, st, "", hexAlphabetForPacks); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := st.listCount(), 1; got != want {
+		t.Errorf("unexpected number of list calls for unknown prefix: %v, want %v", got, want)
+	}
+
+	if _, err := blob.ListAllBlobsInParallel(ctx, 16, st, "p", hexAlphabetForPacks); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := st.listCount(), 1+len(blob.HexDigits); got != want {
+		t.Errorf("unexpected number of list calls for pack prefix: %v, want %v", got, want)
+	}
+}
+
+func hexAlphabetForPacks(prefix blob.ID) string {
+	switch prefix {
+	case "n", "p", "q":
+		return blob.HexDigits
+	default:
+		return ""
+	}
+}
+
+type listCountingStorage struct {
+	blob.Storage
+
+	mu    sync.Mutex
+	count int
+}
+
+func (s *listCountingStorage) ListBlobs(ctx context.Context, prefix blob.ID, cb func(blob.Metadata) error) error {
+	s.mu.Lock()
+	s.count++
+	s.mu.Unlock()
+
+	return s.Storage.ListBlobs(ctx, prefix, cb)
+}
+
+func (s *listCountingStorage) listCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count
+}
+
+func sortedIDs(bms []blob.Metadata) []blob.ID {
+	var result []blob.ID
+
+	for _, bm := range bms {
+		result = append(result, bm.BlobID)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return result
+}
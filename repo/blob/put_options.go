@@ -0,0 +1,49 @@
+package blob
+
+import (
+	"context"
+	"time"
+)
+
+const putOptionsContextKey contextKey = "put-options"
+
+// Supported retention modes.
+const (
+	// RetentionModeGovernance prevents blobs from being overwritten or deleted by users without special permissions.
+	RetentionModeGovernance = "GOVERNANCE"
+
+	// RetentionModeCompliance prevents blobs from being overwritten or deleted by any user until the retention period expires.
+	RetentionModeCompliance = "COMPLIANCE"
+)
+
+// StorageTierCold indicates that the blob is rarely read and can be placed in cold storage tier, such as S3 Glacier
+// or Azure Archive, by providers configured with one.
+const StorageTierCold = "cold"
+
+// PutOptions specifies additional options for PutBlob(), which are honored by storage providers that support them.
+type PutOptions struct {
+	// RetentionMode is the object lock mode applied to written blobs, empty if retention is not requested.
+	RetentionMode string
+
+	// RetentionPeriod is the duration (from the time of writing) during which the blob is immutable.
+	RetentionPeriod time.Duration
+
+	// StorageTier is the requested storage tier of written blobs, empty for the default (hot) tier.
+	StorageTier string
+}
+
+// WithPutOptions returns a context that passes the provided options to PutBlob().
+func WithPutOptions(ctx context.Context, opt PutOptions) context.Context {
+	return context.WithValue(ctx, putOptionsContextKey, opt)
+}
+
+// PutOptionsFromContext gets PutBlob() options from the context.
+func PutOptionsFromContext(ctx context.Context) PutOptions {
+	opt, _ := ctx.Value(putOptionsContextKey).(PutOptions)
+	return opt
+}
+
+// IsValidRetentionMode determines whether the provided retention mode is supported.
+func IsValidRetentionMode(mode string) bool {
+	return mode == RetentionModeGovernance || mode == RetentionModeCompliance
+}
@@ -0,0 +1,250 @@
+// Package quota implements a wrapper around Storage that refuses writes which would grow the storage above a limit.
+//
+// The quota is approximate: the total size is tracked in a local ledger, which doesn't reflect blobs written
+// or deleted by other clients until it's recomputed and which is persisted periodically and when the storage
+// is closed, so that writes made by sessions which did not close the storage are not accounted for.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("repo/quota")
+
+// ledgerRefreshInterval is the maximum age of the ledger before total size is recomputed by listing all blobs,
+// which accounts for blobs written and deleted by other clients.
+const ledgerRefreshInterval = 1 * time.Hour
+
+// ledgerSaveInterval is the minimum time between writes of the ledger file, it's written on Close() as well.
+const ledgerSaveInterval = 1 * time.Minute
+
+// Options specifies quota enforced by the wrapper.
+type Options struct {
+	// MaxBytes is the maximum total size of all blobs in the storage.
+	MaxBytes int64
+
+	// LedgerFile is the local file where the total size is persisted between sessions, optional.
+	LedgerFile string
+
+	// EnforcedPrefixes are prefixes of blobs which are refused when over quota, all blobs if empty.
+	// Writes of other blobs are always allowed, which permits recording metadata and cleaning up
+	// the repository even when the quota has been reached.
+	EnforcedPrefixes []blob.ID
+}
+
+// ledger is the persisted state of the quota wrapper.
+type ledger struct {
+	TotalBytes int64     `json:"totalBytes"`
+	Computed   time.Time `json:"computed"`
+}
+
+type quotaStorage struct {
+	base    blob.Storage
+	opt     Options
+	timeNow func() time.Time
+
+	mu        sync.Mutex
+	ledger    ledger
+	lastSaved time.Time // time when the ledger was last saved
+	dirty     bool      // whether the ledger has changed since it was last saved
+}
+
+func (s *quotaStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	length := int64(data.Length())
+
+	s.mu.Lock()
+	if s.isEnforced(id) && s.ledger.TotalBytes+length > s.opt.MaxBytes {
+		total := s.ledger.TotalBytes
+		s.mu.Unlock()
+
+		return errors.Wrapf(blob.ErrQuotaExceeded, "unable to write %v (%v bytes), storage has %v out of %v bytes", id, length, total, s.opt.MaxBytes)
+	}
+	s.mu.Unlock()
+
+	if err := s.base.PutBlob(ctx, id, data); err != nil {
+		return err
+	}
+
+	// overwritten blobs are counted again, which errs on the side of caution until the ledger is refreshed.
+	s.update(ctx, length)
+
+	return nil
+}
+
+func (s *quotaStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	bm, err := s.base.GetMetadata(ctx, id)
+	if err == blob.ErrBlobNotFound {
+		return s.base.DeleteBlob(ctx, id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.base.DeleteBlob(ctx, id); err != nil {
+		return err
+	}
+
+	s.update(ctx, -bm.Length)
+
+	return nil
+}
+
+func (s *quotaStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	return s.base.GetBlob(ctx, id, offset, length)
+}
+
+func (s *quotaStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *quotaStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *quotaStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *quotaStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *quotaStorage) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.dirty {
+		if err := s.saveLedgerLocked(); err != nil {
+			log(ctx).Warningf("unable to save quota ledger: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.base.Close(ctx)
+}
+
+func (s *quotaStorage) isEnforced(id blob.ID) bool {
+	if len(s.opt.EnforcedPrefixes) == 0 {
+		return true
+	}
+
+	for _, p := range s.opt.EnforcedPrefixes {
+		if strings.HasPrefix(string(id), string(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *quotaStorage) update(ctx context.Context, delta int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ledger.TotalBytes += delta
+	if s.ledger.TotalBytes < 0 {
+		s.ledger.TotalBytes = 0
+	}
+
+	s.dirty = true
+
+	if s.timeNow().Sub(s.lastSaved) < ledgerSaveInterval {
+		return
+	}
+
+	if err := s.saveLedgerLocked(); err != nil {
+		log(ctx).Warningf("unable to save quota ledger: %v", err)
+	}
+}
+
+func (s *quotaStorage) saveLedgerLocked() error {
+	s.dirty = false
+	s.lastSaved = s.timeNow()
+
+	if s.opt.LedgerFile == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(s.ledger)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal ledger")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.opt.LedgerFile), filepath.Base(s.opt.LedgerFile)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary ledger file")
+	}
+
+	_, err = f.Write(b)
+	f.Close() //nolint:errcheck
+
+	if err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return errors.Wrap(err, "unable to write ledger")
+	}
+
+	return os.Rename(f.Name(), s.opt.LedgerFile)
+}
+
+// loadLedger loads the persisted ledger, if it's recent enough, otherwise computes the total size of storage.
+func (s *quotaStorage) loadLedger(ctx context.Context, now time.Time) error {
+	if s.opt.LedgerFile != "" {
+		if b, err := ioutil.ReadFile(s.opt.LedgerFile); err == nil {
+			var l ledger
+			if err := json.Unmarshal(b, &l); err == nil && now.Sub(l.Computed) < ledgerRefreshInterval && !l.Computed.After(now) {
+				s.ledger = l
+				return nil
+			}
+		}
+	}
+
+	var total int64
+
+	if err := s.base.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		total += bm.Length
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to determine storage size")
+	}
+
+	s.ledger = ledger{TotalBytes: total, Computed: now}
+
+	log(ctx).Debugf("storage size is %v bytes, quota %v bytes", total, s.opt.MaxBytes)
+
+	if err := s.saveLedgerLocked(); err != nil {
+		log(ctx).Warningf("unable to save quota ledger: %v", err)
+	}
+
+	return nil
+}
+
+// NewWrapper returns a Storage wrapper that refuses writes above the quota specified in the options,
+// failing them with blob.ErrQuotaExceeded.
+func NewWrapper(ctx context.Context, st blob.Storage, opt Options, timeNow func() time.Time) (blob.Storage, error) {
+	if timeNow == nil {
+		timeNow = time.Now // allow:no-inject-time
+	}
+
+	s := &quotaStorage{
+		base:    st,
+		opt:     opt,
+		timeNow: timeNow,
+	}
+
+	if err := s.loadLedger(ctx, timeNow()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
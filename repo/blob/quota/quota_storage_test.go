@@ -0,0 +1,159 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestQuotaStorageVerify(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st, err := NewWrapper(ctx, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{MaxBytes: 1 << 20}, nil)
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestQuotaStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{
+		"existing": make([]byte, 40),
+	}
+
+	st, err := NewWrapper(ctx, blobtesting.NewMapStorage(data, nil, nil), Options{
+		MaxBytes:         100,
+		EnforcedPrefixes: []blob.ID{"p"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	mustPut(ctx, t, st, "p1", 50)
+
+	// 40+50+20 is above the quota
+	if err := st.PutBlob(ctx, "p2", gather.FromSlice(make([]byte, 20))); !errors.Is(err, blob.ErrQuotaExceeded) {
+		t.Fatalf("unexpected error when over quota: %v", err)
+	}
+
+	if _, ok := data["p2"]; ok {
+		t.Fatalf("blob was written despite quota")
+	}
+
+	// blobs not matching enforced prefixes are always written.
+	mustPut(ctx, t, st, "q1", 20)
+
+	if err := st.DeleteBlob(ctx, "q1"); err != nil {
+		t.Fatalf("unable to delete: %v", err)
+	}
+
+	if err := st.DeleteBlob(ctx, "existing"); err != nil {
+		t.Fatalf("unable to delete: %v", err)
+	}
+
+	// deleting blobs frees up space.
+	mustPut(ctx, t, st, "p2", 20)
+}
+
+func TestQuotaStorageLedger(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	ledgerFile := filepath.Join(dir, "ledger.json")
+	ta := faketime.NewTimeAdvance(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	opt := Options{MaxBytes: 100, LedgerFile: ledgerFile}
+
+	st, err := NewWrapper(ctx, blobtesting.NewMapStorage(data, nil, nil), opt, ta.NowFunc())
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	// ledger is not rewritten on every write.
+	mustPut(ctx, t, st, "a", 30)
+	verifyLedgerTotal(t, ledgerFile, 0)
+
+	ta.Advance(ledgerSaveInterval)
+
+	mustPut(ctx, t, st, "a2", 30)
+	verifyLedgerTotal(t, ledgerFile, 60)
+
+	// pending changes are saved when closing the storage.
+	mustPut(ctx, t, st, "a3", 10)
+	verifyLedgerTotal(t, ledgerFile, 60)
+
+	if err := st.Close(ctx); err != nil {
+		t.Fatalf("unable to close: %v", err)
+	}
+
+	verifyLedgerTotal(t, ledgerFile, 70)
+
+	// simulate another client removing the blobs, which is not reflected in the ledger.
+	delete(data, "a")
+	delete(data, "a2")
+	delete(data, "a3")
+
+	st, err = NewWrapper(ctx, blobtesting.NewMapStorage(data, nil, nil), opt, ta.NowFunc())
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, "b", gather.FromSlice(make([]byte, 60))); !errors.Is(err, blob.ErrQuotaExceeded) {
+		t.Fatalf("expected persisted ledger to be used, got %v", err)
+	}
+
+	// once the ledger is old, the size is recomputed.
+	ta.Advance(2 * ledgerRefreshInterval)
+
+	st, err = NewWrapper(ctx, blobtesting.NewMapStorage(data, nil, nil), opt, ta.NowFunc())
+	if err != nil {
+		t.Fatalf("unable to create wrapper: %v", err)
+	}
+
+	mustPut(ctx, t, st, "b", 60)
+}
+
+func mustPut(ctx context.Context, t *testing.T, st blob.Storage, id blob.ID, length int) {
+	t.Helper()
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(make([]byte, length))); err != nil {
+		t.Fatalf("unable to put %v: %v", id, err)
+	}
+}
+
+func verifyLedgerTotal(t *testing.T, ledgerFile string, want int64) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(ledgerFile)
+	if err != nil {
+		t.Fatalf("unable to read ledger: %v", err)
+	}
+
+	var l ledger
+	if err := json.Unmarshal(b, &l); err != nil {
+		t.Fatalf("invalid ledger: %v", err)
+	}
+
+	if l.TotalBytes != want {
+		t.Errorf("unexpected total in ledger: %v, want %v", l.TotalBytes, want)
+	}
+}
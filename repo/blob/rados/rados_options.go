@@ -0,0 +1,23 @@
+// Package rados implements Storage based on Ceph RADOS pool, which requires building with 'ceph' tag
+// and librados development files.
+package rados
+
+// Options defines options for Ceph RADOS-based storage.
+type Options struct {
+	// Pool is the name of RADOS pool where data is stored.
+	Pool string `json:"pool"`
+
+	// Namespace is the name of RADOS namespace within the pool, which allows multiple repositories to share a pool.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ClusterName and User are used to locate configuration and keyring, default to 'ceph' and 'client.admin'.
+	ClusterName string `json:"clusterName,omitempty"`
+	User        string `json:"user,omitempty"`
+
+	// ConfigFile is the path to Ceph configuration file, if not provided default locations are searched.
+	ConfigFile string `json:"configFile,omitempty"`
+
+	// StripeSize, when positive, causes blobs larger than it to be split into multiple RADOS objects of at most
+	// StripeSize bytes, which is needed when blobs exceed osd_max_object_size and spreads large blobs across OSDs.
+	StripeSize int64 `json:"stripeSize,omitempty"`
+}
@@ -0,0 +1,426 @@
+// +build ceph
+
+package rados
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/ceph/go-ceph/rados"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	radosStorageType = "rados"
+
+	defaultClusterName = "ceph"
+	defaultUser        = "client.admin"
+
+	// stripeSeparator separates blob ID from the generation and stripe number in names of RADOS objects
+	// holding stripes of a blob, it never appears in blob IDs.
+	stripeSeparator = "#"
+
+	// stripeXattrName is the name of extended attribute of the object named after a striped blob, which holds
+	// the stripe size, total length and generation of the stripes of the blob.
+	stripeXattrName      = "kopia.stripe"
+	maxStripeXattrLength = 64
+
+	stripeGenerationLength = 8
+)
+
+// errNoXattr is returned by RADOS when reading extended attribute that does not exist.
+var errNoXattr = rados.RadosError(-int(syscall.ENODATA))
+
+type radosStorage struct {
+	Options
+
+	conn  *rados.Conn
+	ioctx *rados.IOContext
+}
+
+// stripeInfo describes the layout of a blob that's split into multiple RADOS objects.
+type stripeInfo struct {
+	stripeSize int64
+	length     int64
+	generation string
+}
+
+func (si stripeInfo) stripeCount() int {
+	return int((si.length + si.stripeSize - 1) / si.stripeSize)
+}
+
+func (si stripeInfo) String() string {
+	return fmt.Sprintf("%d/%d/%v", si.stripeSize, si.length, si.generation)
+}
+
+// stripeObjectID returns the RADOS object ID holding the provided stripe of a blob. Each write of a striped blob
+// uses new generation, so that stripes of the blob being overwritten remain intact until the write completes.
+func stripeObjectID(id blob.ID, si *stripeInfo, n int) string {
+	return fmt.Sprintf("%v%v%v%v%v", id, stripeSeparator, si.generation, stripeSeparator, n)
+}
+
+// getStripeInfo returns the stripe layout of the provided blob or nil if the blob is stored in a single object.
+func (s *radosStorage) getStripeInfo(id blob.ID) (*stripeInfo, error) {
+	buf := make([]byte, maxStripeXattrLength)
+
+	n, err := s.ioctx.GetXattr(string(id), stripeXattrName, buf)
+	if err == errNoXattr {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	parts := strings.Split(string(buf[0:n]), "/")
+	if len(parts) != 3 { //nolint:gomnd
+		return nil, errors.Errorf("invalid stripe information of %v: %q", id, buf[0:n])
+	}
+
+	si := stripeInfo{generation: parts[2]}
+
+	if _, err := fmt.Sscanf(parts[0]+" "+parts[1], "%d %d", &si.stripeSize, &si.length); err != nil || si.stripeSize <= 0 || si.generation == "" {
+		return nil, errors.Errorf("invalid stripe information of %v: %q", id, buf[0:n])
+	}
+
+	return &si, nil
+}
+
+func (s *radosStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	si, err := s.getStripeInfo(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		offset = 0
+
+		if si != nil {
+			length = si.length
+		} else {
+			st, err := s.ioctx.Stat(string(id))
+			if err != nil {
+				return nil, translateError(err)
+			}
+
+			length = int64(st.Size)
+		}
+	}
+
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	// RADOS reads are ranged natively, only the requested bytes are transferred.
+	data := make([]byte, length)
+
+	if si == nil {
+		if err := s.readFull(string(id), data, offset); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+	}
+
+	if offset+length > si.length {
+		return nil, errors.Errorf("invalid length, requested %v bytes at %v, but blob has %v", length, offset, si.length)
+	}
+
+	// read the requested range from each stripe that overlaps it.
+	for pos := int64(0); pos < length; {
+		stripe := (offset + pos) / si.stripeSize
+		stripeOffset := (offset + pos) % si.stripeSize
+
+		n := si.stripeSize - stripeOffset
+		if n > length-pos {
+			n = length - pos
+		}
+
+		if err := s.readFull(stripeObjectID(id, si, int(stripe)), data[pos:pos+n], stripeOffset); err != nil {
+			return nil, err
+		}
+
+		pos += n
+	}
+
+	return data, nil
+}
+
+func (s *radosStorage) readFull(oid string, data []byte, offset int64) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	n, err := s.ioctx.Read(oid, data, uint64(offset))
+	if err != nil {
+		return translateError(err)
+	}
+
+	if n != len(data) {
+		return errors.Errorf("invalid length, got %v bytes, but expected %v", n, len(data))
+	}
+
+	return nil
+}
+
+func (s *radosStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	st, err := s.ioctx.Stat(string(id))
+	if err != nil {
+		return blob.Metadata{}, translateError(err)
+	}
+
+	bm := blob.Metadata{
+		BlobID:    id,
+		Length:    int64(st.Size),
+		Timestamp: st.ModTime,
+	}
+
+	si, err := s.getStripeInfo(id)
+	if err != nil {
+		return blob.Metadata{}, err
+	}
+
+	if si != nil {
+		bm.Length = si.length
+	}
+
+	return bm, nil
+}
+
+func translateError(err error) error {
+	if err == rados.ErrNotFound {
+		return blob.ErrBlobNotFound
+	}
+
+	return err
+}
+
+func (s *radosStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	progressCallback := blob.ProgressCallback(ctx)
+	if progressCallback != nil {
+		progressCallback(string(id), 0, int64(data.Length()))
+		defer progressCallback(string(id), int64(data.Length()), int64(data.Length()))
+	}
+
+	var b bytes.Buffer
+
+	if _, err := data.WriteTo(&b); err != nil {
+		return errors.Wrap(err, "unable to gather blob data")
+	}
+
+	old, err := s.getStripeInfo(id)
+	if err != nil && err != blob.ErrBlobNotFound {
+		return err
+	}
+
+	if s.StripeSize <= 0 || int64(b.Len()) <= s.StripeSize {
+		// full object writes are atomic, readers observe either old or new contents.
+		if err := s.ioctx.WriteFull(string(id), b.Bytes()); err != nil {
+			return err
+		}
+
+		if old == nil {
+			return nil
+		}
+
+		// readers keep reading stripes of the old blob until stripe information is removed.
+		if err := s.ioctx.RmXattr(string(id), stripeXattrName); err != nil {
+			return errors.Wrapf(err, "unable to remove stripe information of %v", id)
+		}
+
+		return s.deleteStripes(id, old)
+	}
+
+	si := &stripeInfo{stripeSize: s.StripeSize, length: int64(b.Len())}
+
+	gen := make([]byte, stripeGenerationLength)
+	if _, err := rand.Read(gen); err != nil {
+		return errors.Wrap(err, "unable to generate stripe generation")
+	}
+
+	si.generation = hex.EncodeToString(gen)
+
+	payload := b.Bytes()
+
+	for n := 0; n < si.stripeCount(); n++ {
+		start := int64(n) * si.stripeSize
+
+		end := start + si.stripeSize
+		if end > si.length {
+			end = si.length
+		}
+
+		if err := s.ioctx.WriteFull(stripeObjectID(id, si, n), payload[start:end]); err != nil {
+			return errors.Wrapf(err, "unable to write stripe %v of %v", n, id)
+		}
+	}
+
+	// setting stripe information creates the object named after the blob or atomically switches
+	// an existing blob to the new stripes, so the blob is never visible partially written.
+	if err := s.ioctx.SetXattr(string(id), stripeXattrName, []byte(si.String())); err != nil {
+		return errors.Wrapf(err, "unable to set stripe information of %v", id)
+	}
+
+	if old == nil {
+		// drop contents of the previous unstriped blob, if any.
+		return s.ioctx.Truncate(string(id), 0)
+	}
+
+	return s.deleteStripes(id, old)
+}
+
+func (s *radosStorage) deleteStripes(id blob.ID, si *stripeInfo) error {
+	for n := 0; n < si.stripeCount(); n++ {
+		if err := translateError(s.ioctx.Delete(stripeObjectID(id, si, n))); err != nil && err != blob.ErrBlobNotFound {
+			return errors.Wrapf(err, "unable to delete stripe %v of %v", n, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *radosStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	si, err := s.getStripeInfo(id)
+	if err == blob.ErrBlobNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := translateError(s.ioctx.Delete(string(id))); err != nil && err != blob.ErrBlobNotFound {
+		return err
+	}
+
+	if si == nil {
+		return nil
+	}
+
+	// stripes are deleted after the object named after the blob, so that the blob disappears atomically.
+	return s.deleteStripes(id, si)
+}
+
+func (s *radosStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	iter, err := s.ioctx.Iter()
+	if err != nil {
+		return errors.Wrap(err, "unable to list objects")
+	}
+	defer iter.Close()
+
+	// RADOS does not support listing by prefix, so objects are filtered here.
+	for iter.Next() {
+		oid := iter.Value()
+		if !strings.HasPrefix(oid, string(prefix)) || strings.Contains(oid, stripeSeparator) {
+			continue
+		}
+
+		bm, err := s.GetMetadata(ctx, blob.ID(oid))
+		if err == blob.ErrBlobNotFound {
+			// deleted while listing
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+func (s *radosStorage) ConnectionInfo() blob.ConnectionInfo {
+	return blob.ConnectionInfo{
+		Type:   radosStorageType,
+		Config: &s.Options,
+	}
+}
+
+func (s *radosStorage) Close(ctx context.Context) error {
+	s.ioctx.Destroy()
+	s.conn.Shutdown()
+
+	return nil
+}
+
+func (s *radosStorage) String() string {
+	return fmt.Sprintf("rados://%s/%s", s.Pool, s.Namespace)
+}
+
+// New creates new RADOS-backed storage with specified options.
+func New(ctx context.Context, opt *Options) (blob.Storage, error) {
+	if opt.Pool == "" {
+		return nil, errors.New("pool name must be specified")
+	}
+
+	if opt.StripeSize < 0 {
+		return nil, errors.New("stripe size must not be negative")
+	}
+
+	clusterName := opt.ClusterName
+	if clusterName == "" {
+		clusterName = defaultClusterName
+	}
+
+	user := opt.User
+	if user == "" {
+		user = defaultUser
+	}
+
+	conn, err := rados.NewConnWithClusterAndUser(clusterName, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create RADOS connection")
+	}
+
+	if opt.ConfigFile != "" {
+		err = conn.ReadConfigFile(opt.ConfigFile)
+	} else {
+		err = conn.ReadDefaultConfigFile()
+	}
+
+	if err != nil {
+		conn.Shutdown()
+		return nil, errors.Wrap(err, "unable to read Ceph configuration")
+	}
+
+	if err = conn.Connect(); err != nil {
+		conn.Shutdown()
+		return nil, errors.Wrap(err, "unable to connect to Ceph cluster")
+	}
+
+	ioctx, err := conn.OpenIOContext(opt.Pool)
+	if err != nil {
+		conn.Shutdown()
+		return nil, errors.Wrapf(err, "unable to open pool %q", opt.Pool)
+	}
+
+	ioctx.SetNamespace(opt.Namespace)
+
+	return &radosStorage{
+		Options: *opt,
+		conn:    conn,
+		ioctx:   ioctx,
+	}, nil
+}
+
+func init() {
+	blob.AddSupportedStorage(
+		radosStorageType,
+		func() interface{} {
+			return &Options{}
+		},
+		func(ctx context.Context, o interface{}) (blob.Storage, error) {
+			return New(ctx, o.(*Options))
+		})
+}
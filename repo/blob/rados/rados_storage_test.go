@@ -0,0 +1,119 @@
+// +build ceph
+
+package rados_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/rados"
+)
+
+const testPoolEnv = "KOPIA_RADOS_TEST_POOL"
+
+func TestRADOSStorage(t *testing.T) {
+	verifyRADOSStorage(t, 0)
+}
+
+func TestRADOSStorageWithStriping(t *testing.T) {
+	// use tiny stripes, so that blobs written by VerifyStorage are split into many objects.
+	verifyRADOSStorage(t, 3)
+}
+
+func verifyRADOSStorage(t *testing.T, stripeSize int64) {
+	pool, ok := os.LookupEnv(testPoolEnv)
+	if !ok {
+		t.Skip(fmt.Sprintf("%s not provided", testPoolEnv))
+	}
+
+	ctx := testlogging.Context(t)
+
+	data := make([]byte, 8)
+	rand.Read(data) //nolint:errcheck
+
+	// use random namespace to isolate from other tests sharing the pool.
+	st, err := rados.New(ctx, &rados.Options{
+		Pool:       pool,
+		Namespace:  fmt.Sprintf("test-%x", data),
+		ConfigFile: os.Getenv("CEPH_CONF"),
+		StripeSize: stripeSize,
+	})
+	if err != nil {
+		t.Fatalf("unable to connect to RADOS: %v", err)
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	blobtesting.VerifyStorage(ctx, t, st)
+	blobtesting.AssertConnectionInfoRoundTrips(ctx, t, st)
+
+	if err := st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		return st.DeleteBlob(ctx, bm.BlobID)
+	}); err != nil {
+		t.Fatalf("unable to clean up namespace: %v", err)
+	}
+}
+
+func TestRADOSStorageOverwriteStriping(t *testing.T) {
+	pool, ok := os.LookupEnv(testPoolEnv)
+	if !ok {
+		t.Skip(fmt.Sprintf("%s not provided", testPoolEnv))
+	}
+
+	ctx := testlogging.Context(t)
+
+	data := make([]byte, 8)
+	rand.Read(data) //nolint:errcheck
+
+	namespace := fmt.Sprintf("test-%x", data)
+
+	open := func(stripeSize int64) blob.Storage {
+		st, err := rados.New(ctx, &rados.Options{
+			Pool:       pool,
+			Namespace:  namespace,
+			ConfigFile: os.Getenv("CEPH_CONF"),
+			StripeSize: stripeSize,
+		})
+		if err != nil {
+			t.Fatalf("unable to connect to RADOS: %v", err)
+		}
+
+		return st
+	}
+
+	striped := open(3)
+	defer striped.Close(ctx) //nolint:errcheck
+
+	unstriped := open(0)
+	defer unstriped.Close(ctx) //nolint:errcheck
+
+	for _, tc := range []struct {
+		st   blob.Storage
+		data []byte
+	}{
+		{unstriped, []byte("unstriped-01")},
+		{striped, []byte("striped-0001")},
+		{striped, []byte("striped-2-longer")},
+		{unstriped, []byte("unstriped-02")},
+	} {
+		if err := tc.st.PutBlob(ctx, "blob", gather.FromSlice(tc.data)); err != nil {
+			t.Fatalf("unable to write blob: %v", err)
+		}
+
+		blobtesting.AssertGetBlob(ctx, t, striped, "blob", tc.data)
+		blobtesting.AssertGetBlob(ctx, t, unstriped, "blob", tc.data)
+	}
+
+	if err := unstriped.DeleteBlob(ctx, "blob"); err != nil {
+		t.Fatalf("unable to delete blob: %v", err)
+	}
+
+	blobtesting.AssertGetBlobNotFound(ctx, t, striped, "blob")
+	blobtesting.AssertListResults(ctx, t, unstriped, "")
+}
@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlobArchived is returned when reading a blob stored in cold storage tier, which must be rehydrated first.
+var ErrBlobArchived = errors.New("blob is archived and must be rehydrated before it can be read")
+
+// Rehydrator is implemented by storage providers supporting cold storage tiers, where blobs are not
+// immediately readable.
+type Rehydrator interface {
+	// RehydrateBlob requests the blob to be made readable, unless it's readable already or its rehydration is in progress,
+	// and returns true if the blob can be read now.
+	RehydrateBlob(ctx context.Context, id ID) (bool, error)
+}
+
+// RehydrateBlob invokes RehydrateBlob() on storage that implements Rehydrator, blobs in other storage are always readable.
+func RehydrateBlob(ctx context.Context, st Storage, id ID) (bool, error) {
+	if r, ok := st.(Rehydrator); ok {
+		return r.RehydrateBlob(ctx, id)
+	}
+
+	return true, nil
+}
+
+// RehydrateBlobs requests rehydration of provided blobs and polls the storage with the specified interval
+// until all of them are readable. The optional progress function is called after each poll.
+func RehydrateBlobs(ctx context.Context, st Storage, ids []ID, pollInterval time.Duration, progress func(ready, total int)) error {
+	pending := append([]ID(nil), ids...)
+
+	for {
+		var stillPending []ID
+
+		for _, id := range pending {
+			ready, err := RehydrateBlob(ctx, st, id)
+			if err != nil {
+				return errors.Wrapf(err, "unable to rehydrate %v", id)
+			}
+
+			if !ready {
+				stillPending = append(stillPending, id)
+			}
+		}
+
+		pending = stillPending
+
+		if progress != nil {
+			progress(len(ids)-len(pending), len(ids))
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package blob_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// slowRehydratingStorage makes blobs readable after they have been polled a number of times.
+type slowRehydratingStorage struct {
+	blob.Storage
+
+	mu        sync.Mutex
+	remaining map[blob.ID]int
+}
+
+func (s *slowRehydratingStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remaining[id] == 0 {
+		return true, nil
+	}
+
+	s.remaining[id]--
+
+	return false, nil
+}
+
+func TestRehydrateBlobs(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := &slowRehydratingStorage{
+		Storage:   blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		remaining: map[blob.ID]int{"a": 1, "b": 3, "c": 0},
+	}
+
+	var polls []int
+
+	if err := blob.RehydrateBlobs(ctx, st, []blob.ID{"a", "b", "c"}, time.Millisecond, func(ready, total int) {
+		if total != 3 {
+			t.Errorf("unexpected total: %v", total)
+		}
+
+		polls = append(polls, ready)
+	}); err != nil {
+		t.Fatalf("error rehydrating: %v", err)
+	}
+
+	if got, want := polls, []int{1, 2, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected progress: %v, want %v", got, want)
+	}
+
+	// storage without cold tier is always readable.
+	ready, err := blob.RehydrateBlob(ctx, st.Storage, "a")
+	if err != nil || !ready {
+		t.Errorf("unexpected result for storage without cold tier: %v %v", ready, err)
+	}
+
+	// waiting is canceled with the context.
+	st.remaining["a"] = 1000
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := blob.RehydrateBlobs(ctx, st, []blob.ID{"a"}, time.Hour, nil); err != context.Canceled {
+		t.Errorf("unexpected error when canceled: %v", err)
+	}
+}
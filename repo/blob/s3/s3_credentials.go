@@ -0,0 +1,151 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6/pkg/credentials"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	defaultSTSEndpoint = "https://sts.amazonaws.com"
+
+	// credentialsRefreshMargin is how long before expiration temporary credentials are refreshed.
+	credentialsRefreshMargin = 5 * time.Minute
+
+	webIdentitySessionName = "kopia"
+)
+
+// webIdentityCredentials obtains temporary credentials using AssumeRoleWithWebIdentity (OIDC, Kubernetes service account
+// tokens). The token file is re-read each time since it's typically rotated by the environment.
+type webIdentityCredentials struct {
+	stsEndpoint string
+	roleARN     string
+	tokenFile   string
+	httpClient  *http.Client
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+func (w *webIdentityCredentials) Credentials(ctx context.Context) (*blob.Credentials, error) {
+	token, err := ioutil.ReadFile(w.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read web identity token")
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {w.roleARN},
+		"RoleSessionName":  {webIdentitySessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.stsEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create STS request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to assume role with web identity")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to assume role with web identity: %v", resp.Status)
+	}
+
+	var r assumeRoleWithWebIdentityResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, errors.Wrap(err, "invalid STS response")
+	}
+
+	c := r.Result.Credentials
+
+	return &blob.Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expiration:      c.Expiration,
+	}, nil
+}
+
+// minioCredentials adapts blob.CredentialsProvider to minio credentials provider.
+type minioCredentials struct {
+	ctx      context.Context // detached from the context used to open the storage, used for refreshes
+	provider blob.CredentialsProvider
+}
+
+func (m *minioCredentials) Retrieve() (credentials.Value, error) {
+	c, err := m.provider.Credentials(m.ctx)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	return credentials.Value{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// IsExpired always returns true, so that credentials are retrieved before each request,
+// caching and refreshing them is up to the provider.
+func (m *minioCredentials) IsExpired() bool {
+	return true
+}
+
+// newCredentials returns credentials for the provided options, which are either static
+// or obtained using web identity and refreshed before they expire.
+func newCredentials(ctx context.Context, opt *Options) (*credentials.Credentials, error) {
+	if opt.WebIdentityTokenFile == "" {
+		return credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken), nil
+	}
+
+	if opt.RoleARN == "" {
+		return nil, errors.New("role ARN must be specified with web identity token file")
+	}
+
+	stsEndpoint := opt.STSEndpoint
+	if stsEndpoint == "" {
+		stsEndpoint = defaultSTSEndpoint
+	}
+
+	p := blob.NewRefreshingCredentialsProvider(&webIdentityCredentials{
+		stsEndpoint: stsEndpoint,
+		roleARN:     opt.RoleARN,
+		tokenFile:   opt.WebIdentityTokenFile,
+		httpClient:  http.DefaultClient,
+	}, credentialsRefreshMargin, nil)
+
+	// fail early if credentials can't be obtained.
+	if _, err := p.Credentials(ctx); err != nil {
+		return nil, err
+	}
+
+	// credentials are refreshed long after the context used to open the storage is canceled.
+	return credentials.New(&minioCredentials{ctxutil.Detach(ctx), p}), nil
+}
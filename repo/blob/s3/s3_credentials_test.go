@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestWebIdentityCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("Action") != "AssumeRoleWithWebIdentity" || r.FormValue("WebIdentityToken") != "my-token" || r.FormValue("RoleArn") != "my-role" {
+			http.Error(w, "invalid request", http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse>
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>access-key</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>2020-01-01T01:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "s3-token")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("my-token\n"), 0600); err != nil {
+		t.Fatalf("unable to write token: %v", err)
+	}
+
+	w := &webIdentityCredentials{
+		stsEndpoint: srv.URL,
+		roleARN:     "my-role",
+		tokenFile:   tokenFile,
+		httpClient:  http.DefaultClient,
+	}
+
+	c, err := w.Credentials(ctx)
+	if err != nil {
+		t.Fatalf("unable to get credentials: %v", err)
+	}
+
+	if c.AccessKeyID != "access-key" || c.SecretAccessKey != "secret" || c.SessionToken != "session" {
+		t.Errorf("unexpected credentials: %v", c)
+	}
+
+	if want := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC); !c.Expiration.Equal(want) {
+		t.Errorf("unexpected expiration: %v, want %v", c.Expiration, want)
+	}
+
+	w.roleARN = "other-role"
+	if _, err := w.Credentials(ctx); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,196 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// lifecycleRuleIDPrefix is the prefix of IDs of lifecycle rules generated by kopia, followed by the prefix of
+// the repository, which allows rules of multiple repositories in the same bucket to be updated independently.
+const lifecycleRuleIDPrefix = "kopia:"
+
+// transitionStorageClassMinDays maps storage classes which blobs can be transitioned to, to the minimum
+// number of days after which they can be transitioned. Objects in GLACIER and DEEP_ARCHIVE must be restored
+// before they can be read, which maintenance doesn't do, use cold storage class of the storage instead.
+var transitionStorageClassMinDays = map[string]int{
+	"STANDARD_IA":         30, // nolint:gomnd
+	"ONEZONE_IA":          30, // nolint:gomnd
+	"INTELLIGENT_TIERING": 1,
+	"GLACIER_IR":          30, // nolint:gomnd
+}
+
+// lifecycleConfiguration is the S3 bucket lifecycle configuration, which is marshaled to XML when applied using the API
+// and to JSON, as expected by 'aws s3api put-bucket-lifecycle-configuration', when generated.
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration" json:"-"`
+	Rules   []lifecycleRule `xml:"Rule" json:"Rules"`
+}
+
+type lifecycleRule struct {
+	ID                             string                          `xml:"ID" json:"ID"`
+	Filter                         lifecycleFilter                 `xml:"Filter" json:"Filter"`
+	Status                         string                          `xml:"Status" json:"Status"`
+	AbortIncompleteMultipartUpload *abortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty" json:"AbortIncompleteMultipartUpload,omitempty"`
+	Transitions                    []lifecycleTransition           `xml:"Transition,omitempty" json:"Transitions,omitempty"`
+}
+
+type lifecycleFilter struct {
+	Prefix string `xml:"Prefix" json:"Prefix"`
+}
+
+type abortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation" json:"DaysAfterInitiation"`
+}
+
+type lifecycleTransition struct {
+	Days         int    `xml:"Days" json:"Days"`
+	StorageClass string `xml:"StorageClass" json:"StorageClass"`
+}
+
+// existingLifecycleConfiguration preserves rules of lifecycle configuration read from the bucket verbatim.
+type existingLifecycleConfiguration struct {
+	Rules []struct {
+		ID    string `xml:"ID"`
+		Inner string `xml:",innerxml"`
+	} `xml:"Rule"`
+}
+
+type rawLifecycleRule struct {
+	Inner string `xml:",innerxml"`
+}
+
+func init() {
+	blob.AddLifecyclePolicyGenerator(s3storageType, generateLifecyclePolicy)
+}
+
+func generateLifecyclePolicy(p *blob.LifecyclePolicy) ([]byte, error) {
+	rules, err := lifecycleRules(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(&lifecycleConfiguration{Rules: rules}, "", "  ")
+}
+
+// lifecycleRules returns rules of the provided policy or an error if it isn't safe to use.
+func lifecycleRules(p *blob.LifecyclePolicy) ([]lifecycleRule, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	var rules []lifecycleRule
+
+	if p.AbortIncompleteUploadsAfterDays > 0 {
+		rules = append(rules, lifecycleRule{
+			ID:     lifecycleRuleIDPrefix + p.Prefix + ":abort-incomplete-uploads",
+			Filter: lifecycleFilter{Prefix: p.Prefix},
+			Status: "Enabled",
+			AbortIncompleteMultipartUpload: &abortIncompleteMultipartUpload{
+				DaysAfterInitiation: p.AbortIncompleteUploadsAfterDays,
+			},
+		})
+	}
+
+	if p.TransitionAfterDays > 0 {
+		minDays, ok := transitionStorageClassMinDays[p.TransitionStorageClass]
+		if !ok {
+			return nil, errors.Errorf("blobs can't be transitioned to storage class %q, supported classes: %v", p.TransitionStorageClass, transitionStorageClasses())
+		}
+
+		if p.TransitionAfterDays < minDays {
+			return nil, errors.Errorf("blobs can't be transitioned to storage class %v earlier than after %v days", p.TransitionStorageClass, minDays)
+		}
+
+		if p.TransitionBlobPrefix == "" {
+			return nil, errors.Errorf("only data pack blobs can be transitioned, blob prefix must be specified")
+		}
+
+		rules = append(rules, lifecycleRule{
+			ID:     lifecycleRuleIDPrefix + p.Prefix + ":transition-" + string(p.TransitionBlobPrefix),
+			Filter: lifecycleFilter{Prefix: p.Prefix + string(p.TransitionBlobPrefix)},
+			Status: "Enabled",
+			Transitions: []lifecycleTransition{{
+				Days:         p.TransitionAfterDays,
+				StorageClass: p.TransitionStorageClass,
+			}},
+		})
+	}
+
+	return rules, nil
+}
+
+// isLifecycleRuleForPrefix determines whether the rule with the provided ID has been generated for the prefix,
+// rule names following the prefix never contain a colon, so rules of repositories with longer prefixes don't match.
+func isLifecycleRuleForPrefix(id, prefix string) bool {
+	base := lifecycleRuleIDPrefix + prefix + ":"
+
+	return strings.HasPrefix(id, base) && !strings.Contains(id[len(base):], ":")
+}
+
+func transitionStorageClasses() []string {
+	var result []string
+
+	for k := range transitionStorageClassMinDays {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// ApplyLifecyclePolicy implements blob.LifecyclePolicyApplier by replacing lifecycle rules previously generated for the
+// prefix of the storage, other rules of the bucket are preserved. The prefix of the policy is ignored.
+func (s *s3Storage) ApplyLifecyclePolicy(ctx context.Context, p *blob.LifecyclePolicy) error {
+	pol := *p
+	pol.Prefix = s.Prefix
+
+	rules, err := lifecycleRules(&pol)
+	if err != nil {
+		return err
+	}
+
+	// returns empty configuration when the bucket has none.
+	existing, err := s.cli.GetBucketLifecycle(s.BucketName)
+	if err != nil {
+		return errors.Wrap(err, "unable to get bucket lifecycle configuration")
+	}
+
+	var (
+		ec     existingLifecycleConfiguration
+		merged []interface{}
+	)
+
+	if existing != "" {
+		if err := xml.Unmarshal([]byte(existing), &ec); err != nil {
+			return errors.Wrap(err, "invalid bucket lifecycle configuration")
+		}
+	}
+
+	for _, r := range ec.Rules {
+		if !isLifecycleRuleForPrefix(r.ID, s.Prefix) {
+			merged = append(merged, rawLifecycleRule{r.Inner})
+		}
+	}
+
+	for _, r := range rules {
+		merged = append(merged, r)
+	}
+
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration"`
+		Rules   []interface{} `xml:"Rule"`
+	}{Rules: merged})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal lifecycle configuration")
+	}
+
+	return errors.Wrap(s.cli.SetBucketLifecycleWithContext(ctx, s.BucketName, string(b)), "unable to set bucket lifecycle configuration")
+}
@@ -0,0 +1,158 @@
+package s3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	minio "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// fakeLifecycle emulates GET and PUT ?lifecycle requests of a bucket.
+type fakeLifecycle struct {
+	mu     sync.Mutex
+	config string
+}
+
+func (f *fakeLifecycle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path != "/bucket/" || r.URL.Query()["lifecycle"] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if f.config == "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchLifecycleConfiguration</Code></Error>")) //nolint:errcheck
+
+			return
+		}
+
+		w.Write([]byte(f.config)) //nolint:errcheck
+
+	case http.MethodPut:
+		b, _ := ioutil.ReadAll(r.Body)
+		f.config = string(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3GenerateLifecyclePolicy(t *testing.T) {
+	b, err := blob.GenerateLifecyclePolicy(s3storageType, &blob.LifecyclePolicy{
+		Prefix:                          "prefix-",
+		AbortIncompleteUploadsAfterDays: 7,
+		TransitionBlobPrefix:            "p",
+		TransitionAfterDays:             30,
+		TransitionStorageClass:          "STANDARD_IA",
+	})
+	if err != nil {
+		t.Fatalf("unable to generate lifecycle policy: %v", err)
+	}
+
+	var lc lifecycleConfiguration
+	if err := json.Unmarshal(b, &lc); err != nil {
+		t.Fatalf("invalid lifecycle policy: %v", err)
+	}
+
+	want := []lifecycleRule{
+		{
+			ID:                             "kopia:prefix-:abort-incomplete-uploads",
+			Filter:                         lifecycleFilter{Prefix: "prefix-"},
+			Status:                         "Enabled",
+			AbortIncompleteMultipartUpload: &abortIncompleteMultipartUpload{DaysAfterInitiation: 7},
+		},
+		{
+			ID:          "kopia:prefix-:transition-p",
+			Filter:      lifecycleFilter{Prefix: "prefix-p"},
+			Status:      "Enabled",
+			Transitions: []lifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}},
+		},
+	}
+
+	if !reflect.DeepEqual(lc.Rules, want) {
+		t.Errorf("unexpected rules: %v, want %v", string(b), want)
+	}
+
+	for _, p := range []blob.LifecyclePolicy{
+		{},
+		{AbortIncompleteUploadsAfterDays: -1},
+		{TransitionBlobPrefix: "p", TransitionAfterDays: 29, TransitionStorageClass: "STANDARD_IA"},
+		{TransitionBlobPrefix: "p", TransitionAfterDays: 100, TransitionStorageClass: "GLACIER"},
+		{TransitionAfterDays: 100, TransitionStorageClass: "STANDARD_IA"},
+	} {
+		p := p
+		if _, err := blob.GenerateLifecyclePolicy(s3storageType, &p); err == nil {
+			t.Errorf("unexpected success generating unsafe lifecycle policy %+v", p)
+		}
+	}
+}
+
+func TestS3ApplyLifecyclePolicy(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	// rule of another repository in the same bucket with a prefix extending ours and an unrelated rule.
+	fl := &fakeLifecycle{config: `<LifecycleConfiguration><Rule><ID>kopia:prefix-:x:abort-incomplete-uploads</ID><Filter><Prefix>prefix-:x</Prefix></Filter><Status>Enabled</Status><AbortIncompleteMultipartUpload><DaysAfterInitiation>3</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule><Rule><ID>other</ID><Filter><And><Prefix>logs/</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><Status>Enabled</Status><Expiration><Days>10</Days></Expiration></Rule></LifecycleConfiguration>`}
+
+	server := httptest.NewServer(fl)
+	defer server.Close()
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	creds := credentials.NewStaticV4("key", "secret", "")
+
+	cli, err := minio.NewWithCredentials(endpoint, creds, false, "us-west-2")
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	s := &s3Storage{
+		Options: Options{
+			BucketName:  "bucket",
+			Prefix:      "prefix-",
+			Endpoint:    endpoint,
+			DoNotUseTLS: true,
+		},
+		cli:   cli,
+		creds: creds,
+	}
+
+	for _, days := range []int{7, 5} {
+		if err := blob.ApplyLifecyclePolicy(ctx, s, &blob.LifecyclePolicy{AbortIncompleteUploadsAfterDays: days}); err != nil {
+			t.Fatalf("unable to apply lifecycle policy: %v", err)
+		}
+	}
+
+	var got struct {
+		Rules []struct {
+			ID   string `xml:"ID"`
+			Days int    `xml:"AbortIncompleteMultipartUpload>DaysAfterInitiation"`
+		} `xml:"Rule"`
+	}
+
+	if err := xml.Unmarshal([]byte(fl.config), &got); err != nil {
+		t.Fatalf("invalid lifecycle configuration: %v", err)
+	}
+
+	if len(got.Rules) != 3 || got.Rules[0].ID != "kopia:prefix-:x:abort-incomplete-uploads" || got.Rules[1].ID != "other" || got.Rules[2].ID != "kopia:prefix-:abort-incomplete-uploads" || got.Rules[2].Days != 5 {
+		t.Errorf("unexpected lifecycle configuration: %v", fl.config)
+	}
+
+	if !strings.Contains(fl.config, "<Tag><Key>k</Key><Value>v</Value></Tag>") {
+		t.Errorf("unrelated rule was not preserved: %v", fl.config)
+	}
+}
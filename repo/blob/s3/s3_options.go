@@ -1,5 +1,7 @@
 package s3
 
+import "time"
+
 // Options defines options for S3-based storage.
 type Options struct {
 	// BucketName is the name of the bucket where data is stored.
@@ -16,10 +18,38 @@ type Options struct {
 	SecretAccessKey string `json:"secretAccessKey" kopia:"sensitive"`
 	SessionToken    string `json:"sessionToken" kopia:"sensitive"`
 
+	// WebIdentityTokenFile and RoleARN cause temporary credentials to be obtained using AssumeRoleWithWebIdentity
+	// instead of using static keys, the credentials are refreshed automatically before they expire.
+	WebIdentityTokenFile string `json:"webIdentityTokenFile,omitempty"`
+	RoleARN              string `json:"roleARN,omitempty"`
+
+	// STSEndpoint is the URL of the STS service used to obtain temporary credentials.
+	STSEndpoint string `json:"stsEndpoint,omitempty"`
+
 	// Region is an optional region to pass in authorization header.
 	Region string `json:"region,omitempty"`
 
 	MaxUploadSpeedBytesPerSecond int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// RetentionMode is the default S3 Object Lock mode (GOVERNANCE or COMPLIANCE) applied to all written blobs,
+	// the bucket must have Object Lock enabled.
+	RetentionMode string `json:"retentionMode,omitempty"`
+
+	// RetentionPeriod is the default duration for which written blobs are locked.
+	RetentionPeriod time.Duration `json:"retentionPeriod,omitempty"`
+
+	// KMSKeyID enables SSE-KMS server-side encryption of written blobs using the provided KMS key ID or ARN.
+	KMSKeyID string `json:"kmsKeyID,omitempty"`
+
+	// ColdStorageClass is the storage class (such as GLACIER or DEEP_ARCHIVE) of blobs written to cold storage tier,
+	// other blobs use the default storage class of the bucket.
+	ColdStorageClass string `json:"coldStorageClass,omitempty"`
+
+	// RestoreDays is the number of days for which restored copies of archived blobs remain readable.
+	RestoreDays int `json:"restoreDays,omitempty"`
+
+	// RestoreTier is the retrieval tier (Expedited, Standard or Bulk) used when restoring archived blobs.
+	RestoreTier string `json:"restoreTier,omitempty"`
 }
@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	minio "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/signer"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	defaultRestoreDays = 7
+	defaultRestoreTier = "Standard"
+
+	defaultRegion = "us-east-1"
+)
+
+// archivedStorageClasses are storage classes of objects which must be restored before they can be read.
+var archivedStorageClasses = map[string]bool{
+	"GLACIER":      true,
+	"DEEP_ARCHIVE": true,
+}
+
+type restoreRequest struct {
+	XMLName xml.Name `xml:"RestoreRequest"`
+	Days    int      `xml:"Days"`
+	Tier    string   `xml:"GlacierJobParameters>Tier"`
+}
+
+// RehydrateBlob implements blob.Rehydrator by restoring objects in archived storage classes.
+func (s *s3Storage) RehydrateBlob(ctx context.Context, b blob.ID) (bool, error) {
+	v, err := retry.WithExponentialBackoff(ctx, fmt.Sprintf("RehydrateBlob(%v)", b), func() (interface{}, error) {
+		return s.rehydrateBlobAttempt(ctx, b)
+	}, isRetriableError)
+	if err != nil {
+		return false, translateError(err)
+	}
+
+	return v.(bool), nil
+}
+
+func (s *s3Storage) rehydrateBlobAttempt(ctx context.Context, b blob.ID) (bool, error) {
+	resp, err := s.doRequest(ctx, http.MethodHead, b, "", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, blob.ErrBlobNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errorResponse(resp)
+	}
+
+	if !archivedStorageClasses[resp.Header.Get("x-amz-storage-class")] {
+		return true, nil
+	}
+
+	// x-amz-restore is present once restore has been requested, see
+	// https://docs.aws.amazon.com/AmazonS3/latest/API/API_HeadObject.html
+	switch restore := resp.Header.Get("x-amz-restore"); {
+	case strings.Contains(restore, `ongoing-request="false"`):
+		return true, nil
+	case strings.Contains(restore, `ongoing-request="true"`):
+		return false, nil
+	}
+
+	return false, s.requestRestore(ctx, b)
+}
+
+func (s *s3Storage) requestRestore(ctx context.Context, b blob.ID) error {
+	req := restoreRequest{
+		Days: s.RestoreDays,
+		Tier: s.RestoreTier,
+	}
+
+	if req.Days <= 0 {
+		req.Days = defaultRestoreDays
+	}
+
+	if req.Tier == "" {
+		req.Tier = defaultRestoreTier
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal restore request")
+	}
+
+	resp, err := s.doRequest(ctx, http.MethodPost, b, "restore", body)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		return nil
+
+	case http.StatusConflict:
+		// RestoreAlreadyInProgress
+		return nil
+
+	default:
+		return errorResponse(resp)
+	}
+}
+
+// doRequest sends a signed request for the provided blob, which is used for APIs not supported by the client library.
+func (s *s3Storage) doRequest(ctx context.Context, method string, b blob.ID, query string, body []byte) (*http.Response, error) {
+	scheme := "https"
+	if s.DoNotUseTLS {
+		scheme = "http"
+	}
+
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     s.Endpoint,
+		Path:     "/" + s.BucketName + "/" + s.getObjectNameString(b),
+		RawQuery: query,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create request")
+	}
+
+	creds, err := s.creds.Get()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get credentials")
+	}
+
+	region := s.Region
+	if region == "" {
+		if region, err = s.cli.GetBucketLocation(s.BucketName); err != nil || region == "" {
+			region = defaultRegion
+		}
+	}
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	return s.httpClient.Do(signer.SignV4(*req, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, region))
+}
+
+// errorResponse returns an error describing the unsuccessful response, server errors are retriable.
+func errorResponse(resp *http.Response) error {
+	er := minio.ErrorResponse{
+		StatusCode: resp.StatusCode,
+	}
+
+	xml.NewDecoder(resp.Body).Decode(&er) //nolint:errcheck
+
+	if er.Code == "" {
+		er.Code = resp.Status
+	}
+
+	return er
+}
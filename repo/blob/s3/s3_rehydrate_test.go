@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	minio "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// fakeArchive emulates HEAD and POST ?restore requests for objects in an archived storage class.
+type fakeArchive struct {
+	mu           sync.Mutex
+	storageClass string
+	restore      string
+	requests     []restoreRequest
+}
+
+func (f *fakeArchive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path != "/bucket/prefix-blob1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		if f.storageClass != "" {
+			w.Header().Set("x-amz-storage-class", f.storageClass)
+		}
+
+		if f.restore != "" {
+			w.Header().Set("x-amz-restore", f.restore)
+		}
+
+	case r.Method == http.MethodPost && r.URL.Query()["restore"] != nil:
+		var req restoreRequest
+
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := xml.Unmarshal(b, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.requests = append(f.requests, req)
+		f.restore = `ongoing-request="true"`
+
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3RehydrateBlob(t *testing.T) {
+	ctx := testlogging.Context(t)
+	fa := &fakeArchive{storageClass: "GLACIER"}
+
+	server := httptest.NewServer(fa)
+	defer server.Close()
+
+	s := &s3Storage{
+		Options: Options{
+			BucketName:  "bucket",
+			Prefix:      "prefix-",
+			Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+			DoNotUseTLS: true,
+			Region:      "us-west-2",
+			RestoreTier: "Bulk",
+		},
+		creds:      credentials.NewStaticV4("key", "secret", ""),
+		httpClient: http.DefaultClient,
+	}
+
+	verifyRehydrate := func(id blob.ID, want bool) {
+		t.Helper()
+
+		ready, err := s.RehydrateBlob(ctx, id)
+		if err != nil {
+			t.Fatalf("error rehydrating %v: %v", id, err)
+		}
+
+		if ready != want {
+			t.Fatalf("unexpected rehydration result of %v: %v, want %v", id, ready, want)
+		}
+	}
+
+	// first call requests restore.
+	verifyRehydrate("blob1", false)
+
+	if got, want := fa.requests, []restoreRequest{{XMLName: xml.Name{Local: "RestoreRequest"}, Days: defaultRestoreDays, Tier: "Bulk"}}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("unexpected restore requests: %v, want %v", got, want)
+	}
+
+	// restore is in progress, not requested again.
+	verifyRehydrate("blob1", false)
+
+	if len(fa.requests) != 1 {
+		t.Fatalf("restore requested again while in progress")
+	}
+
+	fa.restore = `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`
+	verifyRehydrate("blob1", true)
+
+	// objects in other storage classes are always readable.
+	fa.storageClass = ""
+	fa.restore = ""
+	verifyRehydrate("blob1", true)
+
+	if _, err := s.RehydrateBlob(ctx, "no-such-blob"); err != blob.ErrBlobNotFound {
+		t.Fatalf("unexpected error rehydrating missing blob: %v", err)
+	}
+}
+
+func TestS3StorageClass(t *testing.T) {
+	ctx := testlogging.Context(t)
+	s := &s3Storage{Options: Options{ColdStorageClass: "DEEP_ARCHIVE"}}
+
+	if got := s.storageClass(ctx); got != "" {
+		t.Errorf("unexpected default storage class: %q", got)
+	}
+
+	if got, want := s.storageClass(blob.WithPutOptions(ctx, blob.PutOptions{StorageTier: blob.StorageTierCold})), "DEEP_ARCHIVE"; got != want {
+		t.Errorf("unexpected cold storage class: %q, want %q", got, want)
+	}
+
+	if err := translateError(minio.ErrorResponse{StatusCode: http.StatusForbidden, Code: "InvalidObjectState"}); err != blob.ErrBlobArchived {
+		t.Errorf("unexpected error reading archived object: %v", err)
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/efarrer/iothrottler"
 	minio "github.com/minio/minio-go/v6"
 	"github.com/minio/minio-go/v6/pkg/credentials"
+	"github.com/minio/minio-go/v6/pkg/encrypt"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/retry"
@@ -33,6 +35,11 @@ type s3Storage struct {
 
 	downloadThrottler *iothrottler.IOThrottlerPool
 	uploadThrottler   *iothrottler.IOThrottlerPool
+
+	sse encrypt.ServerSide
+
+	creds      *credentials.Credentials
+	httpClient *http.Client
 }
 
 func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64) ([]byte, error) {
@@ -108,6 +115,10 @@ func translateError(err error) error {
 		if me.StatusCode == http.StatusNotFound {
 			return blob.ErrBlobNotFound
 		}
+
+		if me.Code == "InvalidObjectState" {
+			return blob.ErrBlobArchived
+		}
 	}
 
 	return err
@@ -145,22 +156,63 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) err
 			defer progressCallback(string(b), int64(combinedLength), int64(combinedLength))
 		}
 
-		n, err := s.cli.PutObject(s.BucketName, s.getObjectNameString(b), throttled, int64(combinedLength), minio.PutObjectOptions{
-			ContentType: "application/x-kopia",
-			Progress:    newProgressReader(progressCallback, string(b), int64(combinedLength)),
-		})
+		opt := s.putObjectOptions(ctx)
+		opt.Progress = newProgressReader(progressCallback, string(b), int64(combinedLength))
+
+		n, err := s.cli.PutObject(s.BucketName, s.getObjectNameString(b), throttled, int64(combinedLength), opt)
 
 		if err == io.EOF && n == 0 {
 			// special case empty stream
-			_, err = s.cli.PutObject(s.BucketName, s.getObjectNameString(b), bytes.NewBuffer(nil), 0, minio.PutObjectOptions{
-				ContentType: "application/x-kopia",
-			})
+			_, err = s.cli.PutObject(s.BucketName, s.getObjectNameString(b), bytes.NewBuffer(nil), 0, s.putObjectOptions(ctx))
 		}
 
 		return err
 	}, isRetriableError))
 }
 
+// putObjectOptions returns options of objects written now.
+func (s *s3Storage) putObjectOptions(ctx context.Context) minio.PutObjectOptions {
+	retentionMode, retainUntil := s.retentionOptions(ctx)
+
+	return minio.PutObjectOptions{
+		ContentType:          "application/x-kopia",
+		Mode:                 retentionMode,
+		RetainUntilDate:      retainUntil,
+		ServerSideEncryption: s.sse,
+		StorageClass:         s.storageClass(ctx),
+		// S3 requires Content-MD5 header of objects written to buckets with object lock.
+		SendContentMd5: retentionMode != nil,
+	}
+}
+
+// retentionOptions returns object lock mode and retention date for a blob written now, options passed
+// in the context take precedence over storage defaults.
+func (s *s3Storage) retentionOptions(ctx context.Context) (*minio.RetentionMode, *time.Time) {
+	mode, period := s.RetentionMode, s.RetentionPeriod
+
+	if po := blob.PutOptionsFromContext(ctx); po.RetentionMode != "" {
+		mode, period = po.RetentionMode, po.RetentionPeriod
+	}
+
+	if mode == "" || period <= 0 {
+		return nil, nil
+	}
+
+	rm := minio.RetentionMode(mode)
+	retainUntil := time.Now().Add(period).UTC() // allow:no-inject-time
+
+	return &rm, &retainUntil
+}
+
+// storageClass returns storage class of a blob written now, empty for the default storage class of the bucket.
+func (s *s3Storage) storageClass(ctx context.Context) string {
+	if blob.PutOptionsFromContext(ctx).StorageTier == blob.StorageTierCold {
+		return s.ColdStorageClass
+	}
+
+	return ""
+}
+
 func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
 	attempt := func() (interface{}, error) {
 		return nil, s.cli.RemoveObject(s.BucketName, s.getObjectNameString(b))
@@ -251,6 +303,20 @@ func getCustomTransport(insecureSkipVerify bool) (transport *http.Transport) {
 	return customTransport
 }
 
+// serverSideEncryption returns server-side encryption settings for written objects or nil if not configured.
+func serverSideEncryption(opt *Options) (encrypt.ServerSide, error) {
+	if opt.KMSKeyID == "" {
+		return nil, nil
+	}
+
+	sse, err := encrypt.NewSSEKMS(opt.KMSKeyID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up server-side encryption")
+	}
+
+	return sse, nil
+}
+
 // New creates new S3-backed storage with specified options:
 //
 // - the 'BucketName' field is required and all other parameters are optional.
@@ -259,13 +325,36 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		return nil, errors.New("bucket name must be specified")
 	}
 
-	cli, err := minio.NewWithCredentials(opt.Endpoint, credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken), !opt.DoNotUseTLS, opt.Region)
+	if opt.RetentionMode != "" {
+		if !blob.IsValidRetentionMode(opt.RetentionMode) {
+			return nil, errors.Errorf("invalid retention mode %q", opt.RetentionMode)
+		}
+
+		if opt.RetentionPeriod <= 0 {
+			return nil, errors.New("retention period must be specified with retention mode")
+		}
+	}
+
+	sse, err := serverSideEncryption(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := newCredentials(ctx, opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to obtain credentials")
+	}
+
+	cli, err := minio.NewWithCredentials(opt.Endpoint, creds, !opt.DoNotUseTLS, opt.Region)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create client")
 	}
 
+	httpClient := http.DefaultClient
+
 	if opt.DoNotVerifyTLS {
 		cli.SetCustomTransport(getCustomTransport(true))
+		httpClient = &http.Client{Transport: getCustomTransport(true)}
 	}
 
 	downloadThrottler := iothrottler.NewIOThrottlerPool(toBandwidth(opt.MaxDownloadSpeedBytesPerSecond))
@@ -286,6 +375,9 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		cli:               cli,
 		downloadThrottler: downloadThrottler,
 		uploadThrottler:   uploadThrottler,
+		sse:               sse,
+		creds:             creds,
+		httpClient:        httpClient,
 	}, nil
 }
 
@@ -374,3 +374,69 @@ func cleanupOldData(ctx context.Context, t *testutil.RetriableT, options *Option
 		return nil
 	})
 }
+
+func TestS3RetentionOptions(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	s := &s3Storage{Options: Options{RetentionMode: blob.RetentionModeGovernance, RetentionPeriod: time.Hour}}
+
+	mode, until := s.retentionOptions(ctx)
+	if mode == nil || *mode != minio.Governance {
+		t.Errorf("unexpected retention mode: %v", mode)
+	}
+
+	if until == nil || until.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("unexpected retention date: %v", until)
+	}
+
+	mode, _ = s.retentionOptions(blob.WithPutOptions(ctx, blob.PutOptions{
+		RetentionMode:   blob.RetentionModeCompliance,
+		RetentionPeriod: 24 * time.Hour,
+	}))
+	if mode == nil || *mode != minio.Compliance {
+		t.Errorf("unexpected retention mode: %v", mode)
+	}
+
+	if mode, until = (&s3Storage{}).retentionOptions(ctx); mode != nil || until != nil {
+		t.Errorf("unexpected retention options without retention: %v %v", mode, until)
+	}
+
+	if opt := s.putObjectOptions(ctx); opt.Mode == nil || !opt.SendContentMd5 {
+		t.Errorf("Content-MD5 is not sent with retention: %+v", opt)
+	}
+
+	if opt := (&s3Storage{}).putObjectOptions(ctx); opt.SendContentMd5 {
+		t.Errorf("Content-MD5 is sent without retention")
+	}
+
+	if _, err := New(ctx, &Options{BucketName: "some-bucket", RetentionMode: "INVALID", RetentionPeriod: time.Hour}); err == nil {
+		t.Errorf("expected error for invalid retention mode")
+	}
+
+	if _, err := New(ctx, &Options{BucketName: "some-bucket", RetentionMode: blob.RetentionModeCompliance}); err == nil {
+		t.Errorf("expected error for missing retention period")
+	}
+}
+
+func TestS3ServerSideEncryption(t *testing.T) {
+	sse, err := serverSideEncryption(&Options{})
+	if err != nil || sse != nil {
+		t.Fatalf("unexpected server-side encryption without key: %v %v", sse, err)
+	}
+
+	sse, err = serverSideEncryption(&Options{KMSKeyID: "some-key"})
+	if err != nil || sse == nil {
+		t.Fatalf("unexpected server-side encryption: %v %v", sse, err)
+	}
+
+	h := http.Header{}
+	sse.Marshal(h)
+
+	if got, want := h.Get("X-Amz-Server-Side-Encryption"), "aws:kms"; got != want {
+		t.Errorf("unexpected encryption header: %v, want %v", got, want)
+	}
+
+	if got, want := h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), "some-key"; got != want {
+		t.Errorf("unexpected key ID header: %v, want %v", got, want)
+	}
+}
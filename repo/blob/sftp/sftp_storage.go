@@ -55,29 +55,35 @@ func (s *sftpImpl) GetBlobFromPath(ctx context.Context, dirPath, fullPath string
 	}
 	defer r.Close() //nolint:errcheck
 
-	// pkg/sftp doesn't have a `ioutil.Readall`, so we WriteTo to a buffer
-	// and either return it all or return the offset/length bytes
-	buf := new(bytes.Buffer)
-	n, err := r.WriteTo(buf)
-
-	if err != nil {
-		return nil, err
-	}
-
 	if length < 0 {
+		// pkg/sftp doesn't have a `ioutil.Readall`, so we WriteTo to a buffer
+		buf := new(bytes.Buffer)
+		if _, err := r.WriteTo(buf); err != nil {
+			return nil, err
+		}
+
 		return buf.Bytes(), nil
 	}
 
-	if offset > n || offset < 0 {
+	if offset < 0 {
 		return nil, errors.New("invalid offset")
 	}
 
-	data := buf.Bytes()[offset:]
-	if int(length) > len(data) {
-		return nil, errors.New("invalid length")
+	// only fetch the requested range instead of the entire blob.
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "seek error")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("invalid length")
+		}
+
+		return nil, err
 	}
 
-	return data[0:length], nil
+	return data, nil
 }
 
 func (s *sftpImpl) GetMetadataFromPath(ctx context.Context, dirPath, fullPath string) (blob.Metadata, error) {
@@ -0,0 +1,95 @@
+// Package stats implements wrapper around Storage that counts operations performed and bytes transferred.
+package stats
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Counters holds the number of storage operations and bytes transferred, which are
+// updated atomically and can be read at any time using Snapshot().
+type Counters struct {
+	ListCount        int64 `json:"list"`
+	ListedBlobs      int64 `json:"listedBlobs"`
+	GetCount         int64 `json:"get"`
+	GetBytes         int64 `json:"getBytes"`
+	GetMetadataCount int64 `json:"getMetadata"`
+	PutCount         int64 `json:"put"`
+	PutBytes         int64 `json:"putBytes"`
+	DeleteCount      int64 `json:"delete"`
+}
+
+// Snapshot returns a copy of the counters.
+func (c *Counters) Snapshot() Counters {
+	return Counters{
+		ListCount:        atomic.LoadInt64(&c.ListCount),
+		ListedBlobs:      atomic.LoadInt64(&c.ListedBlobs),
+		GetCount:         atomic.LoadInt64(&c.GetCount),
+		GetBytes:         atomic.LoadInt64(&c.GetBytes),
+		GetMetadataCount: atomic.LoadInt64(&c.GetMetadataCount),
+		PutCount:         atomic.LoadInt64(&c.PutCount),
+		PutBytes:         atomic.LoadInt64(&c.PutBytes),
+		DeleteCount:      atomic.LoadInt64(&c.DeleteCount),
+	}
+}
+
+type statsStorage struct {
+	base     blob.Storage
+	counters *Counters
+}
+
+func (s *statsStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	result, err := s.base.GetBlob(ctx, id, offset, length)
+
+	atomic.AddInt64(&s.counters.GetCount, 1)
+	atomic.AddInt64(&s.counters.GetBytes, int64(len(result)))
+
+	return result, err
+}
+
+func (s *statsStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	atomic.AddInt64(&s.counters.GetMetadataCount, 1)
+
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *statsStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	atomic.AddInt64(&s.counters.PutCount, 1)
+	atomic.AddInt64(&s.counters.PutBytes, int64(data.Length()))
+
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *statsStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	atomic.AddInt64(&s.counters.DeleteCount, 1)
+
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *statsStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *statsStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	atomic.AddInt64(&s.counters.ListCount, 1)
+
+	return s.base.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		atomic.AddInt64(&s.counters.ListedBlobs, 1)
+		return callback(bm)
+	})
+}
+
+func (s *statsStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+func (s *statsStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+// NewWrapper returns a Storage wrapper that counts all storage operations in the provided counters.
+func NewWrapper(wrapped blob.Storage, counters *Counters) blob.Storage {
+	return &statsStorage{base: wrapped, counters: counters}
+}
@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestStatsStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var c Counters
+
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), &c)
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	if got := c.Snapshot(); got.ListCount == 0 || got.GetCount == 0 || got.PutCount == 0 || got.DeleteCount == 0 {
+		t.Errorf("operations not counted: %+v", got)
+	}
+
+	c = Counters{}
+	st = NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), &c)
+
+	if err := st.PutBlob(ctx, "a", gather.FromSlice([]byte{1, 2, 3})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.GetBlob(ctx, "a", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.GetMetadata(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.ListBlobs(ctx, "", func(blob.Metadata) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.DeleteBlob(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Counters{
+		ListCount:        1,
+		ListedBlobs:      1,
+		GetCount:         1,
+		GetBytes:         2,
+		GetMetadataCount: 1,
+		PutCount:         1,
+		PutBytes:         3,
+		DeleteCount:      1,
+	}
+
+	if got := c.Snapshot(); got != want {
+		t.Errorf("unexpected counters: %+v, want %+v", got, want)
+	}
+}
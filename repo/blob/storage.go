@@ -40,6 +40,8 @@ type Storage interface {
 	// GetBlob returns full or partial contents of a blob with given ID.
 	// If length>0, the the function retrieves a range of bytes [offset,offset+length)
 	// If length<0, the entire blob must be fetched.
+	// Implementations should transfer only the requested range from the underlying storage,
+	// so that callers can cheaply read small parts of large blobs.
 	GetBlob(ctx context.Context, blobID ID, offset, length int64) ([]byte, error)
 
 	// GetMetadata returns Metadata about single blob.
@@ -70,6 +72,9 @@ type Metadata struct {
 // ErrBlobNotFound is returned when a BLOB cannot be found in storage.
 var ErrBlobNotFound = errors.New("BLOB not found")
 
+// ErrQuotaExceeded is returned when a BLOB cannot be written because the storage quota has been reached.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
 // ListAllBlobs returns Metadata for all blobs in a given storage that have the provided name prefix.
 func ListAllBlobs(ctx context.Context, st Storage, prefix ID) ([]Metadata, error) {
 	var result []Metadata
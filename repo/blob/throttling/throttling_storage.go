@@ -0,0 +1,128 @@
+// Package throttling implements a wrapper around Storage that limits the rate at which blobs are written.
+package throttling
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/efarrer/iothrottler"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Limiter holds the maximum rate at which blobs are written, which can be changed at any time.
+// The zero value is unlimited.
+type Limiter struct {
+	mu   sync.Mutex
+	pool *iothrottler.IOThrottlerPool // nil until the rate is limited for the first time
+}
+
+// SetMaxBytesPerSecond changes the maximum rate at which blobs are written, 0 is unlimited.
+func (l *Limiter) SetMaxBytesPerSecond(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bw := iothrottler.Bandwidth(iothrottler.Unlimited)
+	if rate > 0 {
+		bw = iothrottler.Bandwidth(rate) * iothrottler.BytesPerSecond
+	}
+
+	if l.pool == nil {
+		if rate <= 0 {
+			return
+		}
+
+		l.pool = iothrottler.NewIOThrottlerPool(bw)
+
+		return
+	}
+
+	l.pool.SetBandwidth(bw)
+}
+
+// throttledReader returns the reader throttled to the current rate or nil if the rate has never been limited.
+func (l *Limiter) throttledReader(r io.Reader) (io.ReadCloser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pool == nil {
+		return nil, nil
+	}
+
+	return l.pool.AddReader(ioutil.NopCloser(r))
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pool != nil {
+		l.pool.ReleasePool()
+		l.pool = nil
+	}
+}
+
+type throttlingStorage struct {
+	base    blob.Storage
+	limiter *Limiter
+}
+
+func (s *throttlingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	throttled, err := s.limiter.throttledReader(data.Reader())
+	if err != nil {
+		return errors.Wrap(err, "unable to throttle blob")
+	}
+
+	if throttled == nil {
+		return s.base.PutBlob(ctx, id, data)
+	}
+
+	defer throttled.Close() //nolint:errcheck
+
+	// the blob is passed to the storage only after it has been read at the limited rate.
+	b, err := ioutil.ReadAll(throttled)
+	if err != nil {
+		return errors.Wrap(err, "unable to read blob")
+	}
+
+	return s.base.PutBlob(ctx, id, gather.FromSlice(b))
+}
+
+func (s *throttlingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *throttlingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	return s.base.GetBlob(ctx, id, offset, length)
+}
+
+func (s *throttlingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *throttlingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *throttlingStorage) RehydrateBlob(ctx context.Context, id blob.ID) (bool, error) {
+	return blob.RehydrateBlob(ctx, s.base, id)
+}
+
+func (s *throttlingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *throttlingStorage) Close(ctx context.Context) error {
+	s.limiter.release()
+
+	return s.base.Close(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that writes blobs at the rate specified by the provided limiter.
+func NewWrapper(wrapped blob.Storage, limiter *Limiter) blob.Storage {
+	return &throttlingStorage{base: wrapped, limiter: limiter}
+}
@@ -0,0 +1,58 @@
+package throttling
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestThrottlingStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var lim Limiter
+
+	data := blobtesting.DataMap{}
+	st := NewWrapper(blobtesting.NewMapStorage(data, nil, nil), &lim)
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	const (
+		blobSize = 300000
+		rate     = 200000
+	)
+
+	lim.SetMaxBytesPerSecond(rate)
+
+	payload := bytes.Repeat([]byte{1}, blobSize)
+	t0 := time.Now()
+
+	if err := st.PutBlob(ctx, "throttled", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	if dt, min := time.Since(t0), blobSize/rate*time.Second; dt < min {
+		t.Errorf("blob write was not throttled, took %v, want at least %v", dt, min)
+	}
+
+	if !bytes.Equal(data["throttled"], payload) {
+		t.Errorf("unexpected contents of throttled blob")
+	}
+
+	lim.SetMaxBytesPerSecond(0)
+
+	t0 = time.Now()
+
+	if err := st.PutBlob(ctx, "unthrottled", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	if dt := time.Since(t0); dt >= time.Second {
+		t.Errorf("blob write was throttled after removing the limit, took %v", dt)
+	}
+}
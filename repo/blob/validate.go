@@ -0,0 +1,157 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+)
+
+const (
+	validationBlobPrefix = "kopia.validate."
+	validationBlobSize   = 1024
+
+	// MaxClockSkew is the maximum allowed difference between storage timestamps and local time.
+	MaxClockSkew = 5 * time.Minute
+)
+
+// ValidationResult contains results of ValidateStorage.
+type ValidationResult struct {
+	PutLatency         time.Duration `json:"putLatency"`
+	GetMetadataLatency time.Duration `json:"getMetadataLatency"`
+	ListLatency        time.Duration `json:"listLatency"`
+	GetLatency         time.Duration `json:"getLatency"`
+	DeleteLatency      time.Duration `json:"deleteLatency"`
+
+	// ClockSkew is the difference between blob timestamp reported by the storage and local time
+	// when the blob was written, positive if storage clock is ahead.
+	ClockSkew time.Duration `json:"clockSkew"`
+}
+
+// ValidateStorage exercises the provided storage end-to-end by writing, listing, reading and deleting a probe blob
+// and measuring latency of each operation. It returns an error describing the first operation that did not behave
+// as expected.
+func ValidateStorage(ctx context.Context, st Storage, timeNow func() time.Time) (*ValidationResult, error) {
+	if timeNow == nil {
+		timeNow = time.Now // allow:no-inject-time
+	}
+
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, errors.Wrap(err, "unable to generate probe blob ID")
+	}
+
+	data := make([]byte, validationBlobSize)
+	if _, err := rand.Read(data); err != nil {
+		return nil, errors.Wrap(err, "unable to generate probe blob")
+	}
+
+	id := ID(validationBlobPrefix + hex.EncodeToString(suffix[:]))
+	result := &ValidationResult{}
+
+	t0 := timeNow()
+	if err := st.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+		return nil, errors.Wrap(err, "unable to write probe blob, verify that credentials allow writing to the storage location")
+	}
+
+	t1 := timeNow()
+	result.PutLatency = t1.Sub(t0)
+
+	deleted := false
+
+	defer func() {
+		if !deleted {
+			st.DeleteBlob(ctx, id) //nolint:errcheck
+		}
+	}()
+
+	md, err := st.GetMetadata(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get metadata of probe blob")
+	}
+
+	result.GetMetadataLatency = timeNow().Sub(t1)
+	result.ClockSkew = clockSkew(md.Timestamp, t0, t1)
+
+	if err := validateList(ctx, st, id, result, timeNow); err != nil {
+		return nil, err
+	}
+
+	t0 = timeNow()
+
+	v, err := st.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read probe blob, verify that credentials allow reading from the storage location")
+	}
+
+	result.GetLatency = timeNow().Sub(t0)
+
+	if !bytes.Equal(v, data) {
+		return nil, errors.New("probe blob read back with different contents")
+	}
+
+	const partialOffset, partialLength = 100, 200
+
+	v, err = st.GetBlob(ctx, id, partialOffset, partialLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read part of probe blob")
+	}
+
+	if !bytes.Equal(v, data[partialOffset:partialOffset+partialLength]) {
+		return nil, errors.New("part of probe blob read back with different contents")
+	}
+
+	t0 = timeNow()
+
+	if err := st.DeleteBlob(ctx, id); err != nil {
+		return nil, errors.Wrap(err, "unable to delete probe blob, verify that credentials allow deleting from the storage location")
+	}
+
+	result.DeleteLatency = timeNow().Sub(t0)
+	deleted = true
+
+	if _, err := st.GetBlob(ctx, id, 0, -1); err != ErrBlobNotFound {
+		return nil, errors.Errorf("probe blob can still be read after deletion: %v", err)
+	}
+
+	return result, nil
+}
+
+func validateList(ctx context.Context, st Storage, id ID, result *ValidationResult, timeNow func() time.Time) error {
+	t0 := timeNow()
+
+	found, err := ListAllBlobs(ctx, st, id)
+	if err != nil {
+		return errors.Wrap(err, "unable to list blobs, verify that credentials allow listing the storage location")
+	}
+
+	result.ListLatency = timeNow().Sub(t0)
+
+	if len(found) != 1 || found[0].BlobID != id {
+		return errors.Errorf("probe blob was not found when listing blobs, got %v", found)
+	}
+
+	if found[0].Length != validationBlobSize {
+		return errors.Errorf("unexpected length of probe blob when listing: %v, want %v", found[0].Length, validationBlobSize)
+	}
+
+	return nil
+}
+
+// clockSkew returns the difference between the provided timestamp and the time range [t0,t1].
+func clockSkew(ts, t0, t1 time.Time) time.Duration {
+	// storage providers may truncate timestamps to whole seconds.
+	switch {
+	case ts.After(t1):
+		return ts.Sub(t1)
+	case ts.Before(t0.Truncate(time.Second)):
+		return ts.Sub(t0.Truncate(time.Second))
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,61 @@
+package blob_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type brokenListStorage struct {
+	blob.Storage
+}
+
+func (s brokenListStorage) ListBlobs(ctx context.Context, prefix blob.ID, cb func(bm blob.Metadata) error) error {
+	return nil
+}
+
+func TestValidateStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	res, err := blob.ValidateStorage(ctx, blobtesting.NewMapStorage(data, nil, nil), nil)
+	if err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	if res.ClockSkew != 0 {
+		t.Errorf("unexpected clock skew: %v", res.ClockSkew)
+	}
+
+	if len(data) != 0 {
+		t.Errorf("probe blob was not deleted: %v", data)
+	}
+
+	// storage with clock an hour ahead.
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, func() time.Time {
+		return time.Now().Add(time.Hour)
+	})
+
+	res, err = blob.ValidateStorage(ctx, st, nil)
+	if err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	if res.ClockSkew < 59*time.Minute || res.ClockSkew > time.Hour {
+		t.Errorf("unexpected clock skew: %v", res.ClockSkew)
+	}
+
+	data = blobtesting.DataMap{}
+
+	if _, err := blob.ValidateStorage(ctx, brokenListStorage{blobtesting.NewMapStorage(data, nil, nil)}, nil); err == nil {
+		t.Errorf("expected validation failure")
+	}
+
+	if len(data) != 0 {
+		t.Errorf("probe blob was not deleted after failure: %v", data)
+	}
+}
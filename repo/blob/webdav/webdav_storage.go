@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
@@ -46,29 +48,94 @@ type davStorageImpl struct {
 }
 
 func (d *davStorageImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, offset, length int64) ([]byte, error) {
+	if length < 0 {
+		v, err := retry.WithExponentialBackoff(ctx, "GetBlobFromPath", func() (interface{}, error) {
+			return d.cli.Read(path)
+		}, isRetriable)
+		if err != nil {
+			return nil, d.translateError(err)
+		}
+
+		return v.([]byte), nil
+	}
+
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
 	v, err := retry.WithExponentialBackoff(ctx, "GetBlobFromPath", func() (interface{}, error) {
-		return d.cli.Read(path)
+		return d.readRange(ctx, path, offset, length)
 	}, isRetriable)
 	if err != nil {
 		return nil, d.translateError(err)
 	}
 
 	data := v.([]byte)
+	if int64(len(data)) != length {
+		return nil, errors.New("invalid length")
+	}
 
-	if length < 0 {
-		return data, nil
+	return data, nil
+}
+
+// readRange reads the provided range of a file using HTTP Range request. Servers which ignore the range and
+// respond with the entire file are handled by discarding bytes before the range and stopping as soon as
+// the range has been read. Servers requiring authentication other than basic are read using streaming client.
+func (d *davStorageImpl) readRange(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gowebdav.PathEscape(gowebdav.Join(d.URL, path)), nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if int(offset) > len(data) || offset < 0 {
-		return nil, errors.New("invalid offset")
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+length-1))
 	}
 
-	data = data[offset:]
-	if int(length) > len(data) {
-		return nil, errors.New("invalid length")
+	if d.Username != "" {
+		req.SetBasicAuth(d.Username, d.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return ioutil.ReadAll(io.LimitReader(resp.Body, length))
+
+	case http.StatusOK:
+		return readRangeFromStream(resp.Body, offset, length)
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// range is past the end of file, which is reported by the caller.
+		return nil, nil
+
+	case http.StatusUnauthorized:
+		r, err := d.cli.ReadStream(path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer r.Close() //nolint:errcheck
+
+		return readRangeFromStream(r, offset, length)
+
+	default:
+		return nil, &os.PathError{Op: "ReadRange", Path: path, Err: errors.Errorf("%d", resp.StatusCode)}
+	}
+}
+
+// readRangeFromStream reads the provided range from the stream of the entire file.
+func readRangeFromStream(r io.Reader, offset, length int64) ([]byte, error) {
+	if _, err := io.CopyN(ioutil.Discard, r, offset); err != nil && err != io.EOF {
+		return nil, err
 	}
 
-	return data[0:length], nil
+	// returns fewer bytes if the range is past the end of file, which is verified by the caller.
+	return ioutil.ReadAll(io.LimitReader(r, length))
 }
 
 func (d *davStorageImpl) GetMetadataFromPath(ctx context.Context, dirPath, path string) (blob.Metadata, error) {
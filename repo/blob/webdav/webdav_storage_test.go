@@ -1,16 +1,19 @@
 package webdav
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 
 	"golang.org/x/net/webdav"
 
 	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/repo/blob"
 )
@@ -114,3 +117,81 @@ func verifyWebDAVStorage(t *testing.T, url, username, password string, shardSpec
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestWebDAVStorageRangeReads(t *testing.T) {
+	for _, ignoreRange := range []bool{false, true} {
+		ignoreRange := ignoreRange
+
+		t.Run(fmt.Sprintf("ignoreRange-%v", ignoreRange), func(t *testing.T) {
+			verifyWebDAVStorageRangeReads(t, ignoreRange)
+		})
+	}
+}
+
+func verifyWebDAVStorageRangeReads(t *testing.T, ignoreRange bool) {
+	ctx := testlogging.Context(t)
+
+	tmpDir, _ := ioutil.TempDir("", "webdav")
+	defer os.RemoveAll(tmpDir)
+
+	var rangeRequests int32
+
+	handler := basicAuth(&webdav.Handler{
+		FileSystem: webdav.Dir(tmpDir),
+		LockSystem: webdav.NewMemLS(),
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&rangeRequests, 1)
+
+			if ignoreRange {
+				r.Header.Del("Range")
+			}
+		}
+
+		handler(w, r)
+	}))
+	defer server.Close()
+
+	st, err := New(ctx, &Options{URL: server.URL, Username: "user", Password: "password"})
+	if err != nil {
+		t.Fatalf("unable to create storage: %v", err)
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	data := make([]byte, 1<<20)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	if err := st.PutBlob(ctx, "someblob", gather.FromSlice(data)); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+
+	v, err := st.GetBlob(ctx, "someblob", 500000, 1000)
+	if err != nil {
+		t.Fatalf("unable to get blob range: %v", err)
+	}
+
+	if !bytes.Equal(v, data[500000:501000]) {
+		t.Errorf("unexpected range contents")
+	}
+
+	if atomic.LoadInt32(&rangeRequests) == 0 {
+		t.Errorf("range was not requested from the server")
+	}
+
+	if _, err := st.GetBlob(ctx, "someblob", int64(len(data))-10, 20); err == nil {
+		t.Errorf("expected error when reading past the end of blob")
+	}
+
+	if _, err := st.GetBlob(ctx, "someblob", int64(len(data))+10, 20); err == nil {
+		t.Errorf("expected error when reading range starting past the end of blob")
+	}
+
+	if _, err := st.GetBlob(ctx, "no-such-blob", 0, 10); err != blob.ErrBlobNotFound {
+		t.Errorf("unexpected error when reading range of missing blob: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPasswordDerivedMasterKey is returned when changing password of a repository created by older versions of kopia,
+// where the master key is derived from the original password.
+var ErrPasswordDerivedMasterKey = errors.New("the master key of this repository is derived from its original password, " +
+	"which would keep decrypting all data after the password is changed; to rotate the password, " +
+	"create a new repository and copy snapshots into it")
+
+// ChangePassword changes the password of the credential used to open the repository by re-wrapping its master key
+// with a key derived from the new password. Repository data is not re-encrypted, since the master key remains the same.
+func (r *DirectRepository) ChangePassword(ctx context.Context, newPassword string) error {
+	if r.CredentialKMSKeyURL(r.credential) != "" {
+		return errors.Errorf("credential %q is protected by KMS and has no password", r.credential)
+	}
+
+	if r.CredentialUsesKeyFile(r.credential) {
+		return errors.Errorf("credential %q is protected by a key file and has no password", r.credential)
+	}
+
+	if r.hasPasswordDerivedMasterKey() {
+		return ErrPasswordDerivedMasterKey
+	}
+
+	var (
+		w   wrappedMasterKey
+		err error
+	)
+
+	if r.CredentialUsesHardwareKey(r.credential) {
+		w, err = r.newHardwareKeyWrappedMasterKey(ctx, r.credential, newPassword)
+	} else {
+		w, err = r.newWrappedMasterKey(r.credential, newPassword)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := r.updateWrappedMasterKeys(ctx, func(keys []wrappedMasterKey) ([]wrappedMasterKey, error) {
+		found := false
+
+		for i := range keys {
+			if keys[i].Name == r.credential {
+				keys[i] = w
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, ErrCredentialNotFound
+		}
+
+		return keys, nil
+	}); err != nil {
+		return err
+	}
+
+	if r.ConfigFile == "" {
+		return nil
+	}
+
+	if err := r.resignLocalConfigWithPassword(newPassword); err != nil {
+		return errors.Wrap(err, "unable to sign local config")
+	}
+
+	if _, ok := GetPersistedPassword(ctx, r.ConfigFile); ok {
+		if err := persistPassword(ctx, r.ConfigFile, newPassword); err != nil {
+			return errors.Wrap(err, "unable to persist password")
+		}
+	}
+
+	return nil
+}
+
+// resignLocalConfigWithPassword updates the HMAC of the local config after the password has changed,
+// if the config is protected with a key derived from the password.
+func (r *DirectRepository) resignLocalConfigWithPassword(newPassword string) error {
+	lc, err := loadConfigFromFile(r.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if !lc.hmacKeyedByPassword() {
+		return nil
+	}
+
+	key, err := r.formatBlob.deriveKeyFromPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	data, err := lc.integrityData()
+	if err != nil {
+		return err
+	}
+
+	r.localConfigKey = localConfigHMACKey(key, r.UniqueID)
+
+	return writeSignedLocalConfig(r.ConfigFile, data, r.localConfigKey)
+}
+
+// hasPasswordDerivedMasterKey returns true if the master key is the key derived from the original password,
+// which is the case in repositories created before master keys were wrapped.
+func (r *DirectRepository) hasPasswordDerivedMasterKey() bool {
+	if len(r.formatBlob.WrappedMasterKeys) == 0 {
+		return true
+	}
+
+	for _, w := range r.formatBlob.WrappedMasterKeys {
+		if _, err := unwrapMasterKey(r.masterKey, w.Key, r.formatBlob.UniqueID); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// invalidateCachedFormatBlob removes the local copy of the format blob so that it's read again from the storage.
+func (r *DirectRepository) invalidateCachedFormatBlob() error {
+	lc, err := loadConfigFromFile(r.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if lc.Caching == nil || lc.Caching.CacheDirectory == "" {
+		return nil
+	}
+
+	cacheDir := lc.Caching.CacheDirectory
+	if !filepath.IsAbs(cacheDir) {
+		cacheDir = filepath.Join(filepath.Dir(r.ConfigFile), cacheDir)
+	}
+
+	if err := os.Remove(filepath.Join(cacheDir, FormatBlobID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -20,6 +20,14 @@ type ConnectOptions struct {
 	PersistCredentials bool   `json:"persistCredentials"`
 	HostnameOverride   string `json:"hostnameOverride"`
 	UsernameOverride   string `json:"usernameOverride"`
+	StorageQuotaBytes  int64  `json:"storageQuotaBytes"`
+	UseKMS             bool   `json:"useKMS"`
+	HardwareKeyCommand string `json:"hardwareKeyCommand"`
+	KeyFile            string `json:"keyFile"`
+	EnableActions      bool   `json:"enableActions"`
+
+	// RecoveryCode is used instead of the password to verify the connection and is not persisted.
+	RecoveryCode string `json:"-"`
 
 	content.CachingOptions
 }
@@ -48,6 +56,10 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return err
 	}
 
+	if err := validateFIPSFormatBlob(f); err != nil {
+		return errors.Wrap(err, "repository can't be used in FIPS mode")
+	}
+
 	var lc LocalConfig
 
 	ci := st.ConnectionInfo()
@@ -63,6 +75,12 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		lc.Username = getDefaultUserName(ctx)
 	}
 
+	lc.StorageQuotaBytes = opt.StorageQuotaBytes
+	lc.UseKMS = opt.UseKMS
+	lc.HardwareKeyCommand = opt.HardwareKeyCommand
+	lc.KeyFile = opt.KeyFile
+	lc.EnableActions = opt.EnableActions
+
 	if err = setupCaching(ctx, configFile, &lc, opt.CachingOptions, f.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to set up caching")
 	}
@@ -80,12 +98,12 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password, opt.PersistCredentials)
+	return verifyConnect(ctx, configFile, password, opt.RecoveryCode, opt.PersistCredentials && !opt.UseKMS && opt.RecoveryCode == "")
 }
 
-func verifyConnect(ctx context.Context, configFile, password string, persist bool) error {
+func verifyConnect(ctx context.Context, configFile, password, recoveryCode string, persist bool) error {
 	// now verify that the repository can be opened with the provided config file.
-	r, err := Open(ctx, configFile, password, nil)
+	r, err := Open(ctx, configFile, password, &Options{RecoveryCode: recoveryCode, signLocalConfig: true})
 	if err != nil {
 		// we failed to open the repository after writing the config file,
 		// remove the config file we just wrote and any caches.
@@ -146,6 +164,8 @@ func setupCaching(ctx context.Context, configPath string, lc *LocalConfig, opt c
 	lc.Caching.MaxCacheSizeBytes = opt.MaxCacheSizeBytes
 	lc.Caching.MaxMetadataCacheSizeBytes = opt.MaxMetadataCacheSizeBytes
 	lc.Caching.MaxListCacheDurationSec = opt.MaxListCacheDurationSec
+	lc.Caching.MaxBlobCacheSizeBytes = opt.MaxBlobCacheSizeBytes
+	lc.Caching.IndexStore = opt.IndexStore
 
 	log(ctx).Debugf("Creating cache directory '%v' with max size %v", lc.Caching.CacheDirectory, lc.Caching.MaxCacheSizeBytes)
 
@@ -1,11 +1,17 @@
 package content
 
+// IndexStoreConsolidated causes committed index blobs to be combined into a single local index,
+// which speeds up lookups in repositories with many index blobs.
+const IndexStoreConsolidated = "consolidated"
+
 // CachingOptions specifies configuration of local cache.
 type CachingOptions struct {
 	CacheDirectory            string `json:"cacheDirectory,omitempty"`
 	MaxCacheSizeBytes         int64  `json:"maxCacheSize,omitempty"`
 	MaxMetadataCacheSizeBytes int64  `json:"maxMetadataCacheSize,omitempty"`
 	MaxListCacheDurationSec   int    `json:"maxListCacheDuration,omitempty"`
+	MaxBlobCacheSizeBytes     int64  `json:"maxBlobCacheSize,omitempty"`
+	IndexStore                string `json:"indexStore,omitempty"`
 	IgnoreListCache           bool   `json:"-"`
 	HMACSecret                []byte `json:"-"`
 }
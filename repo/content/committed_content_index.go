@@ -11,11 +11,17 @@ import (
 )
 
 type committedContentIndex struct {
-	cache committedContentIndexCache
+	cache        committedContentIndexCache
+	consolidated *consolidatedIndexStore
 
 	mu     sync.Mutex
 	inUse  map[blob.ID]packIndex
 	merged mergedIndex
+
+	// readers is the number of iterations in progress outside of the lock, replaced indexes are
+	// retired and only closed (which unmaps index files) when there are none.
+	readers int
+	retired mergedIndex
 }
 
 type committedContentIndexCache interface {
@@ -71,11 +77,54 @@ func (b *committedContentIndex) addContent(ctx context.Context, indexBlobID blob
 func (b *committedContentIndex) listContents(r IDRange, cb func(i Info) error) error {
 	b.mu.Lock()
 	m := append(mergedIndex(nil), b.merged...)
+	b.readers++
 	b.mu.Unlock()
 
+	defer func() {
+		b.mu.Lock()
+		b.readers--
+		b.closeRetiredLocked()
+		b.mu.Unlock()
+	}()
+
 	return m.Iterate(r, cb)
 }
 
+// retireLocked schedules indexes that are no longer used by the merged index, the set of indexes in use
+// or the consolidated index store to be closed once no iterations are in progress.
+func (b *committedContentIndex) retireLocked(old mergedIndex) {
+	used := map[packIndex]bool{}
+	for _, ndx := range b.merged {
+		used[ndx] = true
+	}
+
+	for _, ndx := range b.inUse {
+		used[ndx] = true
+	}
+
+	if b.consolidated != nil && b.consolidated.ndx != nil {
+		used[b.consolidated.ndx] = true
+	}
+
+	for _, ndx := range old {
+		if ndx != nil && !used[ndx] {
+			used[ndx] = true // don't retire the same index twice
+			b.retired = append(b.retired, ndx)
+		}
+	}
+
+	b.closeRetiredLocked()
+}
+
+func (b *committedContentIndex) closeRetiredLocked() {
+	if b.readers > 0 {
+		return
+	}
+
+	b.retired.Close() //nolint:errcheck
+	b.retired = nil
+}
+
 func (b *committedContentIndex) packFilesChanged(packFiles []blob.ID) bool {
 	if len(packFiles) != len(b.inUse) {
 		return true
@@ -121,9 +170,27 @@ func (b *committedContentIndex) use(ctx context.Context, packFiles []blob.ID) (b
 		newInUse[e] = ndx
 	}
 
+	old := append(mergedIndex(nil), b.merged...)
+	for _, ndx := range b.inUse {
+		old = append(old, ndx)
+	}
+
 	b.merged = newMerged
 	b.inUse = newInUse
 
+	if b.consolidated != nil {
+		old = append(old, b.consolidated.ndx)
+
+		m, err := b.consolidated.merge(ctx, newInUse)
+		if err != nil {
+			log(ctx).Warningf("unable to use consolidated index: %v", err)
+		} else {
+			b.merged = m
+		}
+	}
+
+	b.retireLocked(old)
+
 	if err := b.cache.expireUnused(ctx, packFiles); err != nil {
 		log(ctx).Warningf("unable to expire unused content index files: %v", err)
 	}
@@ -134,11 +201,18 @@ func (b *committedContentIndex) use(ctx context.Context, packFiles []blob.ID) (b
 }
 
 func newCommittedContentIndex(caching CachingOptions) *committedContentIndex {
-	var cache committedContentIndexCache
+	var (
+		cache        committedContentIndexCache
+		consolidated *consolidatedIndexStore
+	)
 
 	if caching.CacheDirectory != "" {
 		dirname := filepath.Join(caching.CacheDirectory, "indexes")
 		cache = &diskCommittedContentIndexCache{dirname}
+
+		if caching.IndexStore == IndexStoreConsolidated {
+			consolidated = &consolidatedIndexStore{dirname: dirname}
+		}
 	} else {
 		cache = &memoryCommittedContentIndexCache{
 			contents: map[blob.ID]packIndex{},
@@ -146,7 +220,8 @@ func newCommittedContentIndex(caching CachingOptions) *committedContentIndex {
 	}
 
 	return &committedContentIndex{
-		cache: cache,
-		inUse: map[blob.ID]packIndex{},
+		cache:        cache,
+		consolidated: consolidated,
+		inUse:        map[blob.ID]packIndex{},
 	}
 }
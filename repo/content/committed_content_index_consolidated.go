@@ -0,0 +1,245 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	consolidatedIndexSuffix       = ".cndx"
+	consolidatedIndexManifestName = "consolidated.json"
+
+	// maxUnconsolidatedIndexBlobs is the maximum number of index blobs not included in the consolidated index
+	// before it's rebuilt, this avoids rebuilding the entire index each time a new index blob is written.
+	maxUnconsolidatedIndexBlobs = 4
+)
+
+// consolidatedIndexStore maintains a single local index that combines committed index blobs, so that
+// lookups are served by a single binary search instead of searching each index blob. The index is derived
+// from index blobs and is rebuilt when index blobs it was built from are removed (for example by compaction)
+// or when too many new index blobs have been added since.
+type consolidatedIndexStore struct {
+	dirname string
+
+	current *consolidatedIndexManifest
+	ndx     packIndex
+}
+
+// consolidatedIndexManifest describes the most recently built consolidated index.
+type consolidatedIndexManifest struct {
+	FileName   string    `json:"fileName"`
+	IndexBlobs []blob.ID `json:"indexBlobs"`
+}
+
+// coveredBy returns true if all index blobs included in the consolidated index are present in the provided set.
+func (m *consolidatedIndexManifest) coveredBy(indexes map[blob.ID]packIndex) bool {
+	for _, b := range m.IndexBlobs {
+		if indexes[b] == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// merge returns mergedIndex equivalent to merging all provided indexes, which uses consolidated index for most of them.
+func (s *consolidatedIndexStore) merge(ctx context.Context, indexes map[blob.ID]packIndex) (mergedIndex, error) {
+	if s.current == nil {
+		s.openExisting(ctx)
+	}
+
+	if s.current != nil && s.current.coveredBy(indexes) {
+		if m := s.mergeWithCurrent(indexes); len(m)-1 <= maxUnconsolidatedIndexBlobs {
+			return m, nil
+		}
+	}
+
+	if len(indexes) <= maxUnconsolidatedIndexBlobs {
+		var m mergedIndex
+
+		for _, ndx := range indexes {
+			m = append(m, ndx)
+		}
+
+		return m, nil
+	}
+
+	if err := s.build(ctx, indexes); err != nil {
+		return nil, err
+	}
+
+	return s.mergeWithCurrent(indexes), nil
+}
+
+// mergeWithCurrent returns current consolidated index merged with the provided indexes not already included in it.
+func (s *consolidatedIndexStore) mergeWithCurrent(indexes map[blob.ID]packIndex) mergedIndex {
+	m := mergedIndex{s.ndx}
+
+	included := map[blob.ID]bool{}
+	for _, b := range s.current.IndexBlobs {
+		included[b] = true
+	}
+
+	for b, ndx := range indexes {
+		if !included[b] {
+			m = append(m, ndx)
+		}
+	}
+
+	return m
+}
+
+// openExisting opens the consolidated index built previously, possibly by another process.
+func (s *consolidatedIndexStore) openExisting(ctx context.Context) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dirname, consolidatedIndexManifestName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log(ctx).Warningf("unable to read consolidated index manifest: %v", err)
+		}
+
+		return
+	}
+
+	man := &consolidatedIndexManifest{}
+	if err := json.Unmarshal(b, man); err != nil {
+		log(ctx).Warningf("invalid consolidated index manifest: %v", err)
+		return
+	}
+
+	f, err := mmapOpenWithRetry(ctx, filepath.Join(s.dirname, man.FileName))
+	if err != nil {
+		log(ctx).Warningf("unable to open consolidated index: %v", err)
+		return
+	}
+
+	ndx, err := openPackIndex(f)
+	if err != nil {
+		log(ctx).Warningf("unable to open consolidated index: %v", err)
+		return
+	}
+
+	s.current = man
+	s.ndx = ndx
+}
+
+func consolidatedIndexFileName(indexBlobs []blob.ID) string {
+	h := sha256.New()
+	for _, b := range indexBlobs {
+		h.Write([]byte(b)) //nolint:errcheck
+		h.Write([]byte{0}) //nolint:errcheck
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) + consolidatedIndexSuffix
+}
+
+// build writes consolidated index including all provided indexes and makes it current.
+func (s *consolidatedIndexStore) build(ctx context.Context, indexes map[blob.ID]packIndex) error {
+	log(ctx).Debugf("building consolidated index of %v index blobs", len(indexes))
+
+	man := &consolidatedIndexManifest{}
+	b := packIndexBuilder{}
+
+	for indexBlobID, ndx := range indexes {
+		man.IndexBlobs = append(man.IndexBlobs, indexBlobID)
+
+		if err := ndx.Iterate(AllIDs, func(i Info) error {
+			// resolve conflicts the same way as mergedIndex.GetInfo()
+			if best := b[i.ID]; best == nil || i.TimestampSeconds > best.TimestampSeconds || (i.TimestampSeconds == best.TimestampSeconds && !i.Deleted) {
+				b[i.ID] = &i
+			}
+
+			return nil
+		}); err != nil {
+			return errors.Wrapf(err, "unable to read index %v", indexBlobID)
+		}
+	}
+
+	sort.Slice(man.IndexBlobs, func(i, j int) bool {
+		return man.IndexBlobs[i] < man.IndexBlobs[j]
+	})
+
+	man.FileName = consolidatedIndexFileName(man.IndexBlobs)
+
+	var buf bytes.Buffer
+
+	if err := b.Build(&buf); err != nil {
+		return errors.Wrap(err, "unable to build consolidated index")
+	}
+
+	if err := s.writeFileAtomic(man.FileName, buf.Bytes()); err != nil {
+		return err
+	}
+
+	f, err := mmapOpenWithRetry(ctx, filepath.Join(s.dirname, man.FileName))
+	if err != nil {
+		return errors.Wrap(err, "unable to open consolidated index")
+	}
+
+	ndx, err := openPackIndex(f)
+	if err != nil {
+		return errors.Wrap(err, "unable to open consolidated index")
+	}
+
+	manBytes, err := json.Marshal(man)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal consolidated index manifest")
+	}
+
+	if err := s.writeFileAtomic(consolidatedIndexManifestName, manBytes); err != nil {
+		return err
+	}
+
+	s.current = man
+	s.ndx = ndx
+
+	s.expireUnused(ctx)
+
+	return nil
+}
+
+func (s *consolidatedIndexStore) writeFileAtomic(name string, data []byte) error {
+	tmpFile, err := writeTempFileAtomic(s.dirname, data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFile, filepath.Join(s.dirname, name)); err != nil {
+		return errors.Wrapf(err, "unable to write %v", name)
+	}
+
+	return nil
+}
+
+// expireUnused removes old consolidated indexes other than the current one.
+func (s *consolidatedIndexStore) expireUnused(ctx context.Context) {
+	entries, err := ioutil.ReadDir(s.dirname)
+	if err != nil {
+		log(ctx).Warningf("unable to list consolidated indexes: %v", err)
+		return
+	}
+
+	for _, ent := range entries {
+		if !strings.HasSuffix(ent.Name(), consolidatedIndexSuffix) || ent.Name() == s.current.FileName {
+			continue
+		}
+
+		if time.Since(ent.ModTime()) > unusedCommittedContentIndexCleanupTime { // allow:no-inject-time
+			if err := os.Remove(filepath.Join(s.dirname, ent.Name())); err != nil {
+				log(ctx).Warningf("unable to remove unused consolidated index: %v", err)
+			}
+		}
+	}
+}
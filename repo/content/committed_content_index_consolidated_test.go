@@ -0,0 +1,157 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestConsolidatedIndex(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cacheDir, err := ioutil.TempDir("", "consolidated-index")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(cacheDir)
+
+	newIndex := func() *committedContentIndex {
+		return newCommittedContentIndex(CachingOptions{
+			CacheDirectory: cacheDir,
+			IndexStore:     IndexStoreConsolidated,
+		})
+	}
+
+	ci := newIndex()
+
+	var indexBlobs []blob.ID
+
+	addIndexBlob := func(infos ...Info) {
+		t.Helper()
+
+		b := packIndexBuilder{}
+		for _, i := range infos {
+			b.Add(i)
+		}
+
+		var buf bytes.Buffer
+		if err := b.Build(&buf); err != nil {
+			t.Fatalf("unable to build index: %v", err)
+		}
+
+		indexBlobID := blob.ID(fmt.Sprintf("n%v", len(indexBlobs)))
+		if err := ci.addContent(ctx, indexBlobID, buf.Bytes(), false); err != nil {
+			t.Fatalf("unable to add index: %v", err)
+		}
+
+		indexBlobs = append(indexBlobs, indexBlobID)
+	}
+
+	for i := 0; i < 10; i++ {
+		addIndexBlob(
+			Info{ID: ID(fmt.Sprintf("%04x", i)), TimestampSeconds: 1, PackBlobID: "p1", PackOffset: uint32(i)},
+			Info{ID: "ffff", TimestampSeconds: int64(i), PackBlobID: "p1", PackOffset: uint32(i)},
+		)
+	}
+
+	addIndexBlob(Info{ID: "0000", TimestampSeconds: 2, PackBlobID: "p1", Deleted: true})
+
+	if _, err := ci.use(ctx, indexBlobs); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+
+	if got, want := len(ci.merged), 1; got != want {
+		t.Fatalf("unexpected number of merged indexes: %v, want %v", got, want)
+	}
+
+	verifyContents := func(ci *committedContentIndex) {
+		t.Helper()
+
+		if i, err := ci.getContent("0000"); err != nil || !i.Deleted {
+			t.Errorf("unexpected info for deleted content: %v %v", i, err)
+		}
+
+		if i, err := ci.getContent("0005"); err != nil || i.PackOffset != 5 {
+			t.Errorf("unexpected info: %v %v", i, err)
+		}
+
+		if i, err := ci.getContent("ffff"); err != nil || i.TimestampSeconds != 9 {
+			t.Errorf("unexpected info for shared content: %v %v", i, err)
+		}
+
+		if _, err := ci.getContent("dddd"); err != ErrContentNotFound {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	verifyContents(ci)
+
+	// new index blob is merged with existing consolidated index.
+	addIndexBlob(Info{ID: "eeee", TimestampSeconds: 1, PackBlobID: "p2"})
+
+	if _, err := ci.use(ctx, indexBlobs); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+
+	if got, want := len(ci.merged), 2; got != want {
+		t.Fatalf("unexpected number of merged indexes: %v, want %v", got, want)
+	}
+
+	verifyContents(ci)
+
+	// consolidated index is reused after reopening.
+	ci2 := newIndex()
+	if _, err := ci2.use(ctx, indexBlobs); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+
+	if got, want := len(ci2.merged), 2; got != want {
+		t.Fatalf("unexpected number of merged indexes: %v, want %v", got, want)
+	}
+
+	verifyContents(ci2)
+
+	if i, err := ci2.getContent("eeee"); err != nil || i.PackBlobID != "p2" {
+		t.Errorf("unexpected info: %v %v", i, err)
+	}
+
+	// removing index blob included in consolidated index causes it to be rebuilt.
+	indexBlobs = indexBlobs[1:]
+
+	if _, err := ci2.use(ctx, indexBlobs); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+
+	if got, want := len(ci2.merged), 1; got != want {
+		t.Fatalf("unexpected number of merged indexes: %v, want %v", got, want)
+	}
+
+	if _, err := ci2.getContent("0000"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(cacheDir, "indexes"))
+	if err != nil {
+		t.Fatalf("unable to list cache: %v", err)
+	}
+
+	var consolidated int
+
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), consolidatedIndexSuffix) {
+			consolidated++
+		}
+	}
+
+	if got, want := consolidated, 2; got != want {
+		t.Errorf("unexpected number of consolidated indexes: %v, want %v", got, want)
+	}
+}
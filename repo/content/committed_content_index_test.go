@@ -0,0 +1,61 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type closeTrackingIndex struct {
+	packIndex
+	closed bool
+}
+
+func (c *closeTrackingIndex) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *closeTrackingIndex) Iterate(r IDRange, cb func(Info) error) error {
+	return cb(Info{ID: "abcd"})
+}
+
+func TestCommittedContentIndexClosesReplacedIndexesAfterReaders(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	n1 := &closeTrackingIndex{}
+	n2 := &closeTrackingIndex{}
+
+	ci := newCommittedContentIndex(CachingOptions{})
+	ci.cache = &memoryCommittedContentIndexCache{
+		contents: map[blob.ID]packIndex{"n1": n1, "n2": n2},
+	}
+
+	if _, err := ci.use(ctx, []blob.ID{"n1"}); err != nil {
+		t.Fatalf("use failed: %v", err)
+	}
+
+	// replace the index while it's being iterated.
+	if err := ci.listContents(AllIDs, func(i Info) error {
+		if _, err := ci.use(ctx, []blob.ID{"n2"}); err != nil {
+			t.Fatalf("use failed: %v", err)
+		}
+
+		if n1.closed {
+			t.Errorf("replaced index was closed while being read")
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if !n1.closed {
+		t.Errorf("replaced index was not closed after reading finished")
+	}
+
+	if n2.closed {
+		t.Errorf("index in use was closed")
+	}
+}
@@ -2,12 +2,44 @@ package content
 
 // FormattingOptions describes the rules for formatting contents in repository.
 type FormattingOptions struct {
-	Version     int    `json:"version,omitempty"`     // version number, must be "1"
-	Hash        string `json:"hash,omitempty"`        // identifier of the hash algorithm used
-	Encryption  string `json:"encryption,omitempty"`  // identifier of the encryption algorithm used
-	HMACSecret  []byte `json:"secret,omitempty"`      // HMAC secret used to generate encryption keys
-	MasterKey   []byte `json:"masterKey,omitempty"`   // master encryption key (SIV-mode encryption only)
-	MaxPackSize int    `json:"maxPackSize,omitempty"` // maximum size of a pack object
+	Version     int        `json:"version,omitempty"`     // version number, "1" or "2" when key epochs are present
+	Hash        string     `json:"hash,omitempty"`        // identifier of the hash algorithm used
+	Encryption  string     `json:"encryption,omitempty"`  // identifier of the encryption algorithm used
+	HMACSecret  []byte     `json:"secret,omitempty"`      // HMAC secret used to generate encryption keys
+	MasterKey   []byte     `json:"masterKey,omitempty"`   // master encryption key (SIV-mode encryption only)
+	MaxPackSize int        `json:"maxPackSize,omitempty"` // maximum size of a pack object
+	KeyEpochs   []KeyEpoch `json:"keyEpochs,omitempty"`   // encryption keys added by key rotations, key epoch N is KeyEpochs[N-1]
+}
+
+// KeyEpoch describes the encryption algorithm and key used for contents written after a key rotation.
+// Key epoch 0 is described by Encryption and MasterKey of FormattingOptions.
+type KeyEpoch struct {
+	Encryption string `json:"encryption"`
+	MasterKey  []byte `json:"masterKey"`
+}
+
+// GetEncryptionAlgorithm implements encryption.Parameters
+func (k *KeyEpoch) GetEncryptionAlgorithm() string {
+	return k.Encryption
+}
+
+// GetMasterKey implements encryption.Parameters
+func (k *KeyEpoch) GetMasterKey() []byte {
+	return k.MasterKey
+}
+
+// CurrentKeyEpoch returns the key epoch used to encrypt newly written contents.
+func (f *FormattingOptions) CurrentKeyEpoch() int {
+	return len(f.KeyEpochs)
+}
+
+// CurrentEncryption returns the encryption algorithm used to encrypt newly written contents.
+func (f *FormattingOptions) CurrentEncryption() string {
+	if n := len(f.KeyEpochs); n > 0 {
+		return f.KeyEpochs[n-1].Encryption
+	}
+
+	return f.Encryption
 }
 
 // GetEncryptionAlgorithm implements encryption.Parameters
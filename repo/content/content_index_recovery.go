@@ -225,7 +225,7 @@ func (bm *lockFreeManager) readPackFileLocalIndex(ctx context.Context, packFile
 		return nil, errors.Errorf("unable to find valid local index in file %v", packFile)
 	}
 
-	localIndexBytes, err := bm.decryptAndVerify(encryptedLocalIndexBytes, postamble.localIndexIV)
+	localIndexBytes, err := bm.decryptAndVerifyAnyKeyEpoch(encryptedLocalIndexBytes, postamble.localIndexIV)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to decrypt local index")
 	}
@@ -41,6 +41,26 @@ var PackBlobIDPrefixes = []blob.ID{
 	PackBlobIDPrefixSpecial,
 }
 
+// ImmutableBlobIDPrefixes contains prefixes of blobs which are never modified once written.
+var ImmutableBlobIDPrefixes = []blob.ID{
+	PackBlobIDPrefixRegular,
+	PackBlobIDPrefixSpecial,
+	newIndexBlobPrefix,
+}
+
+// BlobIDAlphabet returns characters that can follow the provided prefix in IDs of blobs written by the content
+// manager, which are named after random or hashed bytes, or an empty string if the prefix is not one of
+// ImmutableBlobIDPrefixes and blobs with other IDs may be listed.
+func BlobIDAlphabet(prefix blob.ID) string {
+	for _, p := range ImmutableBlobIDPrefixes {
+		if prefix == p {
+			return blob.HexDigits
+		}
+	}
+
+	return ""
+}
+
 const (
 	parallelFetches          = 5                // number of parallel reads goroutines
 	flushPackIndexTimeout    = 10 * time.Minute // time after which all pending indexes are flushes
@@ -52,10 +72,10 @@ const (
 	currentWriteVersion = 1
 
 	minSupportedWriteVersion = 1
-	maxSupportedWriteVersion = currentWriteVersion
+	maxSupportedWriteVersion = KeyEpochsVersion
 
 	minSupportedReadVersion = 1
-	maxSupportedReadVersion = currentWriteVersion
+	maxSupportedReadVersion = KeyEpochsVersion
 
 	indexLoadAttempts = 10
 )
@@ -72,6 +92,9 @@ type IndexBlobInfo struct {
 
 // Manager builds content-addressable storage with encryption, deduplication and packaging on top of BLOB store.
 type Manager struct {
+	// lockFreeManager starts with Stats and must be first to keep its int64 fields aligned to 64-bit boundaries.
+	lockFreeManager
+
 	mu       *sync.RWMutex
 	cond     *sync.Cond
 	flushing bool
@@ -83,8 +106,6 @@ type Manager struct {
 
 	disableIndexFlushCount int
 	flushPackIndexesAfter  time.Time // time when those indexes should be flushed
-
-	lockFreeManager
 }
 
 type pendingPackInfo struct {
@@ -524,27 +545,32 @@ func (bm *Manager) getOrCreatePendingPackInfoLocked(prefix blob.ID) (*pendingPac
 // WriteContent saves a given content of data to a pack group with a provided name and returns a contentID
 // that's based on the contents of data written.
 func (bm *Manager) WriteContent(ctx context.Context, data []byte, prefix ID) (ID, error) {
+	contentID, _, err := bm.WriteContentIfNotExists(ctx, data, prefix)
+	return contentID, err
+}
+
+// WriteContentIfNotExists is like WriteContent but also reports whether the content already existed
+// in the repository, in which case its data was not written again.
+func (bm *Manager) WriteContentIfNotExists(ctx context.Context, data []byte, prefix ID) (contentID ID, existed bool, err error) {
 	stats.Record(ctx, metricContentWriteContentCount.M(1))
 	stats.Record(ctx, metricContentWriteContentBytes.M(int64(len(data))))
 
 	if err := ValidatePrefix(prefix); err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	var hashOutput [maxHashSize]byte
 
-	contentID := prefix + ID(hex.EncodeToString(bm.hashData(hashOutput[:0], data)))
+	contentID = prefix + ID(hex.EncodeToString(bm.hashData(hashOutput[:0], data)))
 
 	// content already tracked
 	if _, bi, err := bm.getContentInfo(contentID); err == nil {
 		if !bi.Deleted {
-			return contentID, nil
+			return contentID, true, nil
 		}
 	}
 
-	err := bm.addToPackUnlocked(ctx, contentID, data, false)
-
-	return contentID, err
+	return contentID, false, bm.addToPackUnlocked(ctx, contentID, data, false)
 }
 
 // GetContent gets the contents of a given content. If the content is not found returns ErrContentNotFound.
@@ -676,11 +702,11 @@ func NewManager(ctx context.Context, st blob.Storage, f *FormattingOptions, cach
 }
 
 func newManagerWithOptions(ctx context.Context, st blob.Storage, f *FormattingOptions, caching CachingOptions, timeNow func() time.Time, repositoryFormatBytes []byte) (*Manager, error) {
-	if f.Version < minSupportedReadVersion || f.Version > currentWriteVersion {
+	if f.Version < minSupportedReadVersion || f.Version > maxSupportedReadVersion {
 		return nil, errors.Errorf("can't handle repositories created using version %v (min supported %v, max supported %v)", f.Version, minSupportedReadVersion, maxSupportedReadVersion)
 	}
 
-	if f.Version < minSupportedWriteVersion || f.Version > currentWriteVersion {
+	if f.Version < minSupportedWriteVersion || f.Version > maxSupportedWriteVersion {
 		return nil, errors.Errorf("can't handle repositories created using version %v (min supported %v, max supported %v)", f.Version, minSupportedWriteVersion, maxSupportedWriteVersion)
 	}
 
@@ -689,6 +715,14 @@ func newManagerWithOptions(ctx context.Context, st blob.Storage, f *FormattingOp
 		return nil, err
 	}
 
+	encryptors, err := createKeyEpochEncryptors(f, encryptor)
+	if err != nil {
+		return nil, err
+	}
+
+	// new contents are encrypted using the latest key epoch.
+	encryptor = encryptors[len(encryptors)-1]
+
 	dataCacheStorage, err := newCacheStorageOrNil(ctx, caching.CacheDirectory, caching.MaxCacheSizeBytes, "contents")
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to initialize data cache storage")
@@ -729,6 +763,7 @@ func newManagerWithOptions(ctx context.Context, st blob.Storage, f *FormattingOp
 			timeNow:                 timeNow,
 			maxPackSize:             f.MaxPackSize,
 			encryptor:               encryptor,
+			encryptors:              encryptors,
 			hasher:                  hasher,
 			minPreambleLength:       defaultMinPreambleLength,
 			maxPreambleLength:       defaultMaxPreambleLength,
@@ -739,7 +774,7 @@ func newManagerWithOptions(ctx context.Context, st blob.Storage, f *FormattingOp
 			st:                      st,
 			repositoryFormatBytes:   repositoryFormatBytes,
 			checkInvariantsOnUnlock: os.Getenv("KOPIA_VERIFY_INVARIANTS") != "",
-			writeFormatVersion:      int32(f.Version),
+			writeFormatVersion:      int32(formatVersionForKeyEpoch(f.CurrentKeyEpoch())),
 			committedContents:       contentIndex,
 			encryptionBufferPool:    buf.NewPool(ctx, defaultEncryptionBufferPoolSegmentSize+encryptor.MaxOverhead(), "content-manager-encryption"),
 		},
@@ -2,7 +2,6 @@ package content
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"sync"
 
@@ -247,22 +246,9 @@ func (bm *Manager) IterateUnreferencedBlobs(ctx context.Context, blobPrefixes []
 		blobPrefixes = PackBlobIDPrefixes
 	}
 
-	var prefixes []blob.ID
+	log(ctx).Debugf("scanning prefixes %v", blobPrefixes)
 
-	if parallellism <= len(blobPrefixes) {
-		prefixes = append(prefixes, blobPrefixes...)
-	} else {
-		// iterate {p,q}[0-9,a-f]
-		for _, prefix := range blobPrefixes {
-			for hexDigit := 0; hexDigit < 16; hexDigit++ {
-				prefixes = append(prefixes, blob.ID(fmt.Sprintf("%v%x", prefix, hexDigit)))
-			}
-		}
-	}
-
-	log(ctx).Debugf("scanning prefixes %v", prefixes)
-
-	if err := blob.IterateAllPrefixesInParallel(ctx, parallellism, bm.st, prefixes,
+	if err := blob.IterateAllBlobsInParallel(ctx, parallellism, bm.st, blobPrefixes, BlobIDAlphabet,
 		func(bm blob.Metadata) error {
 			if usedPacks[bm.BlobID] {
 				return nil
@@ -40,7 +40,8 @@ type lockFreeManager struct {
 
 	maxPackSize       int
 	hasher            hashing.HashFunc
-	encryptor         encryption.Encryptor
+	encryptor         encryption.Encryptor   // encryptor of the current key epoch
+	encryptors        []encryption.Encryptor // encryptors of all key epochs, indexed by key epoch
 	minPreambleLength int
 	maxPreambleLength int
 	paddingUnit       int
@@ -232,10 +233,14 @@ func (bm *lockFreeManager) getContentDataUnlocked(ctx context.Context, pp *pendi
 	} else {
 		var err error
 
+		t0 := time.Now() // allow:no-inject-time
+
 		payload, err = bm.getCacheForContentID(bi.ID).getContent(ctx, cacheKey(bi.ID), bi.PackBlobID, int64(bi.PackOffset), int64(bi.Length))
 		if err != nil {
 			return nil, err
 		}
+
+		bm.Stats.fetchedIn(time.Since(t0)) // allow:no-inject-time
 	}
 
 	bm.Stats.readContent(len(payload))
@@ -247,23 +252,32 @@ func (bm *lockFreeManager) getContentDataUnlocked(ctx context.Context, pp *pendi
 		return nil, err
 	}
 
-	decrypted, err := bm.decryptAndVerify(payload, iv)
+	e, err := bm.encryptorForFormatVersion(bi.FormatVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	t0 := time.Now() // allow:no-inject-time
+
+	decrypted, err := bm.decryptAndVerify(e, payload, iv)
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid checksum at %v offset %v length %v", bi.PackBlobID, bi.PackOffset, len(payload))
 	}
 
+	bm.Stats.decryptedIn(time.Since(t0)) // allow:no-inject-time
+
 	return decrypted, nil
 }
 
-func (bm *lockFreeManager) decryptAndVerify(encrypted, iv []byte) ([]byte, error) {
-	decrypted, err := bm.encryptor.Decrypt(nil, encrypted, iv)
+func (bm *lockFreeManager) decryptAndVerify(e encryption.Encryptor, encrypted, iv []byte) ([]byte, error) {
+	decrypted, err := e.Decrypt(nil, encrypted, iv)
 	if err != nil {
 		return nil, errors.Wrap(err, "decrypt")
 	}
 
 	bm.Stats.decrypted(len(decrypted))
 
-	if bm.encryptor.IsAuthenticated() {
+	if e.IsAuthenticated() {
 		// already verified
 		return decrypted, nil
 	}
@@ -273,6 +287,23 @@ func (bm *lockFreeManager) decryptAndVerify(encrypted, iv []byte) ([]byte, error
 	return decrypted, bm.verifyChecksum(decrypted, iv)
 }
 
+// decryptAndVerifyAnyKeyEpoch decrypts data whose key epoch is not known, such as index blobs and local indexes
+// of packs, by trying encryptors of all key epochs starting with the latest one.
+func (bm *lockFreeManager) decryptAndVerifyAnyKeyEpoch(encrypted, iv []byte) ([]byte, error) {
+	var lastErr error
+
+	for epoch := len(bm.encryptors) - 1; epoch >= 0; epoch-- {
+		decrypted, err := bm.decryptAndVerify(bm.encryptors[epoch], encrypted, iv)
+		if err == nil {
+			return decrypted, nil
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
 func (bm *lockFreeManager) preparePackDataContent(ctx context.Context, pp *pendingPackInfo) (packIndexBuilder, error) {
 	formatLog(ctx).Debugf("preparing content data with %v items (contents %v)", len(pp.currentPackItems), pp.currentPackData.Length())
 
@@ -334,19 +365,11 @@ func (bm *lockFreeManager) getIndexBlobInternal(ctx context.Context, blobID blob
 
 	bm.Stats.readContent(len(payload))
 
-	payload, err = bm.encryptor.Decrypt(nil, payload, iv)
-	bm.Stats.decrypted(len(payload))
-
+	payload, err = bm.decryptAndVerifyAnyKeyEpoch(payload, iv)
 	if err != nil {
 		return nil, errors.Wrap(err, "decrypt error")
 	}
 
-	// Since the encryption key is a function of data, we must be able to generate exactly the same key
-	// after decrypting the content. This serves as a checksum.
-	if err := bm.verifyChecksum(payload, iv); err != nil {
-		return nil, err
-	}
-
 	return payload, nil
 }
 
@@ -371,6 +394,14 @@ func (bm *lockFreeManager) writePackFileNotLocked(ctx context.Context, packFile
 	bm.Stats.wroteContent(data.Length())
 	bm.listCache.deleteListCache()
 
+	if strings.HasPrefix(string(packFile), string(PackBlobIDPrefixRegular)) {
+		// data packs are only read when restoring, so they can be placed in cold storage tier,
+		// while metadata packs and indexes remain in the default tier.
+		po := blob.PutOptionsFromContext(ctx)
+		po.StorageTier = blob.StorageTierCold
+		ctx = blob.WithPutOptions(ctx, po)
+	}
+
 	return bm.st.PutBlob(ctx, packFile, data)
 }
 
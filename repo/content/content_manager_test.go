@@ -1694,6 +1694,88 @@ func verifyVersionCompat(t *testing.T, writeVersion int) {
 	verifyContentManagerDataSet(ctx, t, mgr, dataSet)
 }
 
+func TestKeyEpochs(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	f := &FormattingOptions{
+		Hash:        "HMAC-SHA256",
+		Encryption:  "AES-256-CTR",
+		HMACSecret:  hmacSecret,
+		MasterKey:   bytes.Repeat([]byte{1}, 32),
+		MaxPackSize: maxPackSize,
+		Version:     1,
+	}
+
+	newManager := func(f *FormattingOptions) (*Manager, error) {
+		return newManagerWithOptions(ctx, st, f, CachingOptions{}, faketime.AutoAdvance(fakeTime, 1*time.Second), nil)
+	}
+
+	mgr0, err := newManager(f)
+	if err != nil {
+		t.Fatalf("can't create content manager: %v", err)
+	}
+
+	id0 := writeContentAndVerify(ctx, t, mgr0, seededRandomData(10, 100))
+	assertNoError(t, mgr0.Flush(ctx))
+	mgr0.Close(ctx)
+
+	rotated := *f
+	rotated.KeyEpochs = []KeyEpoch{{Encryption: "CHACHA20-POLY1305-HMAC-SHA256", MasterKey: bytes.Repeat([]byte{2}, 32)}}
+
+	if _, err := newManager(&rotated); err == nil {
+		t.Fatalf("unexpected success opening key epochs with version 1")
+	}
+
+	rotated.Version = KeyEpochsVersion
+
+	mgr1, err := newManager(&rotated)
+	if err != nil {
+		t.Fatalf("can't create content manager: %v", err)
+	}
+	defer mgr1.Close(ctx)
+
+	verifyContent(ctx, t, mgr1, id0, seededRandomData(10, 100))
+	id1 := writeContentAndVerify(ctx, t, mgr1, seededRandomData(11, 100))
+	assertNoError(t, mgr1.Flush(ctx))
+
+	verifyKeyEpoch(ctx, t, mgr1, id0, 0)
+	verifyKeyEpoch(ctx, t, mgr1, id1, 1)
+
+	// index written with the new key can't be read by a manager unaware of key epochs.
+	if _, err := newManager(f); err == nil {
+		t.Fatalf("unexpected success opening repository with stale key epochs")
+	}
+
+	// rewriting re-encrypts the content with the current key.
+	assertNoError(t, mgr1.RewriteContent(ctx, id0))
+	assertNoError(t, mgr1.Flush(ctx))
+	verifyKeyEpoch(ctx, t, mgr1, id0, 1)
+
+	mgr2, err := newManager(&rotated)
+	if err != nil {
+		t.Fatalf("can't create content manager: %v", err)
+	}
+	defer mgr2.Close(ctx)
+
+	verifyContent(ctx, t, mgr2, id0, seededRandomData(10, 100))
+	verifyContent(ctx, t, mgr2, id1, seededRandomData(11, 100))
+}
+
+func verifyKeyEpoch(ctx context.Context, t *testing.T, mgr *Manager, contentID ID, want int) {
+	t.Helper()
+
+	bi, err := mgr.ContentInfo(ctx, contentID)
+	if err != nil {
+		t.Fatalf("unable to get content info: %v", err)
+	}
+
+	if got := bi.KeyEpoch(); got != want {
+		t.Errorf("unexpected key epoch of %v: %v, want %v", contentID, got, want)
+	}
+}
+
 func verifyContentManagerDataSet(ctx context.Context, t *testing.T, mgr *Manager, dataSet map[ID][]byte) {
 	for contentID, originalPayload := range dataSet {
 		v, err := mgr.GetContent(ctx, contentID)
@@ -1893,3 +1975,57 @@ func getContentInfo(t *testing.T, bm *Manager, c ID) Info {
 
 	return i
 }
+
+// storageTierRecorder records storage tier requested when writing each blob.
+type storageTierRecorder struct {
+	blob.Storage
+
+	mu    sync.Mutex
+	tiers map[blob.ID]string
+}
+
+func (s *storageTierRecorder) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	s.mu.Lock()
+	s.tiers[id] = blob.PutOptionsFromContext(ctx).StorageTier
+	s.mu.Unlock()
+
+	return s.Storage.PutBlob(ctx, id, data)
+}
+
+func TestContentManagerColdStorageTier(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := &storageTierRecorder{
+		Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		tiers:   map[blob.ID]string{},
+	}
+
+	bm := newTestContentManagerWithStorage(t, st, nil)
+	defer bm.Close(ctx)
+
+	writeContentAndVerify(ctx, t, bm, seededRandomData(1, 100))
+
+	if _, err := bm.WriteContent(ctx, seededRandomData(2, 100), "k"); err != nil {
+		t.Fatalf("unable to write metadata content: %v", err)
+	}
+
+	if err := bm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	var dataPacks int
+
+	for id, tier := range st.tiers {
+		isDataPack := id[0:1] == PackBlobIDPrefixRegular
+		if isDataPack {
+			dataPacks++
+		}
+
+		if got, want := tier == blob.StorageTierCold, isDataPack; got != want {
+			t.Errorf("unexpected storage tier of %v: %q", id, tier)
+		}
+	}
+
+	if dataPacks != 1 || len(st.tiers) != 3 {
+		t.Errorf("unexpected blobs written: %v", st.tiers)
+	}
+}
@@ -38,3 +38,8 @@ type Info struct {
 func (i *Info) Timestamp() time.Time {
 	return time.Unix(i.TimestampSeconds, 0)
 }
+
+// KeyEpoch returns the key epoch of the encryption key used to encrypt the content.
+func (i *Info) KeyEpoch() int {
+	return keyEpochForFormatVersion(i.FormatVersion)
+}
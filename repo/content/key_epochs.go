@@ -0,0 +1,62 @@
+package content
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+const (
+	// KeyEpochsVersion is the format version of repositories with rotated encryption keys, which must not be
+	// opened by older versions of kopia that are unaware of key epochs.
+	KeyEpochsVersion = 2
+
+	// MaxKeyEpoch is the maximum key epoch, limited by the format version byte of index entries
+	// which stores the key epoch of each content.
+	MaxKeyEpoch = 254
+)
+
+// keyEpochForFormatVersion returns the key epoch of a content written using the provided format version,
+// contents written before the first key rotation have format version 1 and key epoch 0.
+func keyEpochForFormatVersion(v byte) int {
+	return int(v) - 1
+}
+
+// formatVersionForKeyEpoch returns the format version of index entries of contents written using the provided key epoch.
+func formatVersionForKeyEpoch(epoch int) byte {
+	return byte(epoch + 1)
+}
+
+// createKeyEpochEncryptors returns encryptors for all key epochs, indexed by key epoch.
+func createKeyEpochEncryptors(f *FormattingOptions, epoch0 encryption.Encryptor) ([]encryption.Encryptor, error) {
+	if len(f.KeyEpochs) > 0 && f.Version < KeyEpochsVersion {
+		return nil, errors.Errorf("key epochs require format version %v, got %v", KeyEpochsVersion, f.Version)
+	}
+
+	if len(f.KeyEpochs) > MaxKeyEpoch {
+		return nil, errors.Errorf("too many key epochs: %v, max %v", len(f.KeyEpochs), MaxKeyEpoch)
+	}
+
+	result := []encryption.Encryptor{epoch0}
+
+	for i := range f.KeyEpochs {
+		e, err := encryption.CreateEncryptor(&f.KeyEpochs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create encryptor for key epoch %v", i+1)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// encryptorForFormatVersion returns the encryptor for contents written using the provided format version.
+func (bm *lockFreeManager) encryptorForFormatVersion(v byte) (encryption.Encryptor, error) {
+	epoch := keyEpochForFormatVersion(v)
+	if epoch < 0 || epoch >= len(bm.encryptors) {
+		return nil, errors.Errorf("content uses unknown key epoch %v, the repository must be reopened after key rotation", epoch)
+	}
+
+	return bm.encryptors[epoch], nil
+}
@@ -2,6 +2,7 @@ package content
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 // Stats exposes statistics about content operation.
@@ -13,6 +14,8 @@ type Stats struct {
 	decryptedBytes int64
 	encryptedBytes int64
 	hashedBytes    int64
+	fetchNanos     int64
+	decryptNanos   int64
 
 	readContents    uint32
 	writtenContents uint32
@@ -29,6 +32,8 @@ func (s *Stats) Reset() {
 	atomic.StoreInt64(&s.decryptedBytes, 0)
 	atomic.StoreInt64(&s.encryptedBytes, 0)
 	atomic.StoreInt64(&s.hashedBytes, 0)
+	atomic.StoreInt64(&s.fetchNanos, 0)
+	atomic.StoreInt64(&s.decryptNanos, 0)
 	atomic.StoreUint32(&s.readContents, 0)
 	atomic.StoreUint32(&s.writtenContents, 0)
 	atomic.StoreUint32(&s.hashedContents, 0)
@@ -61,6 +66,16 @@ func (s *Stats) EncryptedBytes() int64 {
 	return atomic.LoadInt64(&s.encryptedBytes)
 }
 
+// FetchDuration returns the approximate total time spent fetching contents from the cache or storage.
+func (s *Stats) FetchDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.fetchNanos))
+}
+
+// DecryptDuration returns the approximate total time spent decrypting and verifying contents.
+func (s *Stats) DecryptDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.decryptNanos))
+}
+
 // InvalidContents returns the approximate count of invalid contents found
 func (s *Stats) InvalidContents() uint32 {
 	return atomic.LoadUint32(&s.invalidContents)
@@ -79,6 +94,14 @@ func (s *Stats) encrypted(size int) int64 {
 	return atomic.AddInt64(&s.encryptedBytes, int64(size))
 }
 
+func (s *Stats) fetchedIn(d time.Duration) {
+	atomic.AddInt64(&s.fetchNanos, int64(d))
+}
+
+func (s *Stats) decryptedIn(d time.Duration) {
+	atomic.AddInt64(&s.decryptNanos, int64(d))
+}
+
 func (s *Stats) readContent(size int) (count uint32, sum int64) {
 	return updateCountSum(&s.readContents, &s.readBytes, size)
 }
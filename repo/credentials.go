@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCredentialName is the name of the credential created together with the repository.
+const DefaultCredentialName = "default"
+
+// ErrCredentialNotFound is returned when the credential with a given name does not exist.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Credentials returns the names of credentials that can open the repository.
+func (r *DirectRepository) Credentials() []string {
+	var result []string
+
+	for _, w := range r.currentWrappedMasterKeys() {
+		result = append(result, w.Name)
+	}
+
+	return result
+}
+
+// CurrentCredential returns the name of the credential used to open the repository.
+func (r *DirectRepository) CurrentCredential() string {
+	return r.credential
+}
+
+// AddCredential adds a named credential with the provided password, which can be used to open the repository
+// in addition to existing ones.
+func (r *DirectRepository) AddCredential(ctx context.Context, name, password string) error {
+	w, err := r.newWrappedMasterKey(name, password)
+	if err != nil {
+		return err
+	}
+
+	return r.addWrappedMasterKey(ctx, w)
+}
+
+// RemoveCredential revokes the credential with the provided name, so that its password can no longer be used
+// to open the repository. The credential used to open the repository cannot be removed.
+// The master key is not rotated, so holders of the credential who kept a copy of it can still decrypt all data.
+func (r *DirectRepository) RemoveCredential(ctx context.Context, name string) error {
+	if name == r.credential {
+		return errors.Errorf("credential %q is used by this connection and cannot be removed", name)
+	}
+
+	return r.updateWrappedMasterKeys(ctx, func(current []wrappedMasterKey) ([]wrappedMasterKey, error) {
+		var (
+			keys  []wrappedMasterKey
+			found bool
+		)
+
+		for _, w := range current {
+			if w.Name == name {
+				found = true
+				continue
+			}
+
+			keys = append(keys, w)
+		}
+
+		if !found {
+			return nil, ErrCredentialNotFound
+		}
+
+		return keys, nil
+	})
+}
+
+// currentWrappedMasterKeys returns a copy of the list of wrapped master keys of the repository.
+// In repositories that use password-derived key as the master key, the master key is wrapped with itself,
+// which preserves the ability to open the repository with its original password.
+func (r *DirectRepository) currentWrappedMasterKeys() []wrappedMasterKey {
+	return r.wrappedMasterKeysOf(r.formatBlob)
+}
+
+func (r *DirectRepository) wrappedMasterKeysOf(f *formatBlob) []wrappedMasterKey {
+	if len(f.WrappedMasterKeys) == 0 {
+		wrapped, err := wrapMasterKey(r.masterKey, r.masterKey, f.UniqueID)
+		if err != nil {
+			panic("unable to wrap master key, this should never happen: " + err.Error())
+		}
+
+		return []wrappedMasterKey{{Name: DefaultCredentialName, Key: wrapped}}
+	}
+
+	return append([]wrappedMasterKey(nil), f.WrappedMasterKeys...)
+}
+
+// addWrappedMasterKey adds the wrapped master key of a new credential.
+func (r *DirectRepository) addWrappedMasterKey(ctx context.Context, w wrappedMasterKey) error {
+	if w.Name == "" {
+		return errors.New("credential name must not be empty")
+	}
+
+	return r.updateWrappedMasterKeys(ctx, func(keys []wrappedMasterKey) ([]wrappedMasterKey, error) {
+		for _, k := range keys {
+			if k.Name == w.Name {
+				return nil, errors.Errorf("credential %q already exists", w.Name)
+			}
+		}
+
+		return append(keys, w), nil
+	})
+}
+
+func (r *DirectRepository) newWrappedMasterKey(name, password string) (wrappedMasterKey, error) {
+	passwordKey, err := r.formatBlob.deriveKeyFromPassword(password)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to derive key from password")
+	}
+
+	wrapped, err := wrapMasterKey(passwordKey, r.masterKey, r.formatBlob.UniqueID)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to wrap master key")
+	}
+
+	return wrappedMasterKey{Name: name, Key: wrapped}, nil
+}
+
+// updateWrappedMasterKeys writes the format blob with the wrapped master keys returned by the provided function,
+// which receives the current wrapped master keys read from the storage.
+func (r *DirectRepository) updateWrappedMasterKeys(ctx context.Context, modify func(keys []wrappedMasterKey) ([]wrappedMasterKey, error)) error {
+	return r.updateFormatBlob(ctx, func(f *formatBlob) error {
+		keys, err := modify(r.wrappedMasterKeysOf(f))
+		if err != nil {
+			return err
+		}
+
+		f.WrappedMasterKeys = keys
+
+		return nil
+	})
+}
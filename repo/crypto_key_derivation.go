@@ -1,26 +1,260 @@
 package repo
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
 
+// KeyDerivationScrypt is the scrypt key derivation algorithm with default parameters.
+const KeyDerivationScrypt = "scrypt-65536-8-1"
+
+const scryptPrefix = "scrypt-"
+
+// Default parameters of Argon2id key derivation.
+const (
+	DefaultArgon2idMemoryKiB   = 64 * 1024
+	DefaultArgon2idIterations  = 3
+	DefaultArgon2idParallelism = 4
+)
+
+const argon2idPrefix = "argon2id-"
+
+// DefaultPBKDF2Iterations is the default number of iterations of PBKDF2 key derivation.
+const DefaultPBKDF2Iterations = 600000
+
+const pbkdf2Prefix = "pbkdf2-sha256-"
+
 // defaultKeyDerivationAlgorithm is the key derivation algorithm for new configurations.
-const defaultKeyDerivationAlgorithm = "scrypt-65536-8-1"
+const defaultKeyDerivationAlgorithm = KeyDerivationScrypt
+
+// Argon2idKeyDerivationAlgorithm returns the name of Argon2id key derivation algorithm with the provided
+// amount of memory (in KiB), number of iterations and degree of parallelism.
+func Argon2idKeyDerivationAlgorithm(memoryKiB, iterations uint32, parallelism uint8) string {
+	return fmt.Sprintf("%v%v-%v-%v", argon2idPrefix, memoryKiB, iterations, parallelism)
+}
+
+// ScryptKeyDerivationAlgorithm returns the name of scrypt key derivation algorithm with the provided
+// CPU/memory cost (power of two), block size and parallelization.
+func ScryptKeyDerivationAlgorithm(n, r, p int) string {
+	return fmt.Sprintf("%v%v-%v-%v", scryptPrefix, n, r, p)
+}
+
+// PBKDF2KeyDerivationAlgorithm returns the name of PBKDF2 key derivation algorithm using HMAC-SHA256
+// with the provided number of iterations.
+func PBKDF2KeyDerivationAlgorithm(iterations int) string {
+	return fmt.Sprintf("%v%v", pbkdf2Prefix, iterations)
+}
+
+// ValidateKeyDerivationAlgorithm returns an error if the provided key derivation algorithm is not supported.
+func ValidateKeyDerivationAlgorithm(algorithm string) error {
+	if err := validateFIPSKeyDerivation(algorithm); err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(algorithm, scryptPrefix):
+		_, _, _, err := parseScryptParameters(algorithm)
+		return err
+
+	case strings.HasPrefix(algorithm, pbkdf2Prefix):
+		_, err := parsePBKDF2Iterations(algorithm)
+		return err
+
+	default:
+		_, _, _, err := parseArgon2idParameters(algorithm)
+		return err
+	}
+}
+
+// newKeyDerivationAlgorithm returns the key derivation algorithm for newly encrypted keys, which
+// is PBKDF2 in FIPS mode.
+func newKeyDerivationAlgorithm() string {
+	if FIPSMode() {
+		return PBKDF2KeyDerivationAlgorithm(DefaultPBKDF2Iterations)
+	}
+
+	return defaultKeyDerivationAlgorithm
+}
+
+func parsePBKDF2Iterations(algorithm string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(algorithm, pbkdf2Prefix))
+	if err != nil || n <= 0 {
+		return 0, errors.Errorf("invalid pbkdf2 iterations: %v", algorithm)
+	}
+
+	return n, nil
+}
+
+func parseScryptParameters(algorithm string) (n, r, p int, err error) {
+	parts := strings.Split(strings.TrimPrefix(algorithm, scryptPrefix), "-")
+	if len(parts) != 3 { //nolint:gomnd
+		return 0, 0, 0, errors.Errorf("invalid scrypt parameters: %v", algorithm)
+	}
+
+	var v [3]int
+
+	for i, s := range parts {
+		if v[i], err = strconv.Atoi(s); err != nil || v[i] <= 0 {
+			return 0, 0, 0, errors.Errorf("invalid scrypt parameters: %v", algorithm)
+		}
+	}
+
+	// scrypt requires cost to be a power of two greater than 1.
+	if v[0] < 2 || v[0]&(v[0]-1) != 0 {
+		return 0, 0, 0, errors.Errorf("invalid scrypt cost, must be a power of two: %v", v[0])
+	}
+
+	return v[0], v[1], v[2], nil
+}
+
+func parseArgon2idParameters(algorithm string) (memoryKiB, iterations uint32, parallelism uint8, err error) {
+	if !strings.HasPrefix(algorithm, argon2idPrefix) {
+		return 0, 0, 0, errors.Errorf("unsupported key algorithm: %v", algorithm)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(algorithm, argon2idPrefix), "-")
+	if len(parts) != 3 { //nolint:gomnd
+		return 0, 0, 0, errors.Errorf("invalid argon2id parameters: %v", algorithm)
+	}
 
-func (f *formatBlob) deriveMasterKeyFromPassword(password string) ([]byte, error) {
+	m, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || m == 0 {
+		return 0, 0, 0, errors.Errorf("invalid argon2id memory: %v", parts[0])
+	}
+
+	t, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || t == 0 {
+		return 0, 0, 0, errors.Errorf("invalid argon2id iterations: %v", parts[1])
+	}
+
+	p, err := strconv.ParseUint(parts[2], 10, 8)
+	if err != nil || p == 0 {
+		return 0, 0, 0, errors.Errorf("invalid argon2id parallelism: %v", parts[2])
+	}
+
+	return uint32(m), uint32(t), uint8(p), nil
+}
+
+// deriveMasterKeyFromPassword returns the master key of the repository and the name of the credential
+// it was unlocked with given its password and optional hardware key. In repositories with wrapped master keys
+// the password-derived key is used to unwrap the master key, otherwise the password-derived key is the master key itself.
+func (f *formatBlob) deriveMasterKeyFromPassword(ctx context.Context, password string, hardwareKey HardwareKeyFunc) (masterKey []byte, credential string, err error) {
+	key, err := f.deriveKeyFromPassword(password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(f.WrappedMasterKeys) == 0 {
+		return key, DefaultCredentialName, nil
+	}
+
+	for _, wrapped := range f.WrappedMasterKeys {
+		if wrapped.KMSKeyURL != "" || wrapped.KeyFile || wrapped.RecoveryCode {
+			continue
+		}
+
+		unwrapKey := key
+
+		if len(wrapped.HardwareKeyChallenge) > 0 {
+			if hardwareKey == nil {
+				continue
+			}
+
+			response, err := hardwareKey(ctx, wrapped.HardwareKeyChallenge)
+			if err != nil {
+				return nil, "", errors.Wrap(err, "unable to get hardware key response")
+			}
+
+			unwrapKey = hardwareKeyCredentialKey(key, response, f.UniqueID)
+		}
+
+		if masterKey, err := unwrapMasterKey(unwrapKey, wrapped.Key, f.UniqueID); err == nil {
+			return masterKey, wrapped.Name, nil
+		}
+	}
+
+	return nil, "", ErrInvalidPassword
+}
+
+// wrapMasterKey encrypts the master key with the provided password-derived key.
+func wrapMasterKey(key, masterKey, uniqueID []byte) ([]byte, error) {
+	aead, authData, err := initCrypto(FormatEncryptionAES256GCM, key, uniqueID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize crypto")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, masterKey, authData), nil
+}
+
+// unwrapMasterKey decrypts the master key wrapped by wrapMasterKey().
+func unwrapMasterKey(key, wrapped, uniqueID []byte) ([]byte, error) {
+	aead, authData, err := initCrypto(FormatEncryptionAES256GCM, key, uniqueID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize crypto")
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.Errorf("invalid wrapped master key, too short")
+	}
+
+	return aead.Open(nil, wrapped[0:aead.NonceSize()], wrapped[aead.NonceSize():], authData)
+}
+
+func (f *formatBlob) deriveKeyFromPassword(password string) ([]byte, error) {
+	return deriveKeyFromPasswordWithAlgorithm(f.KeyDerivationAlgorithm, password, f.UniqueID)
+}
+
+// deriveKeyFromPasswordWithAlgorithm derives 32-byte key from the password and salt using the provided algorithm.
+func deriveKeyFromPasswordWithAlgorithm(algorithm, password string, salt []byte) ([]byte, error) {
 	const masterKeySize = 32
 
-	switch f.KeyDerivationAlgorithm {
-	case "scrypt-65536-8-1":
-		return scrypt.Key([]byte(password), f.UniqueID, 65536, 8, 1, masterKeySize)
+	if err := validateFIPSKeyDerivation(algorithm); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(algorithm, scryptPrefix):
+		n, r, p, err := parseScryptParameters(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		return scrypt.Key([]byte(password), salt, n, r, p, masterKeySize)
+
+	case strings.HasPrefix(algorithm, argon2idPrefix):
+		m, t, p, err := parseArgon2idParameters(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		return argon2.IDKey([]byte(password), salt, t, m, p, masterKeySize), nil
+
+	case strings.HasPrefix(algorithm, pbkdf2Prefix):
+		n, err := parsePBKDF2Iterations(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		return pbkdf2.Key([]byte(password), salt, n, masterKeySize, sha256.New), nil
 
 	default:
-		return nil, errors.Errorf("unsupported key algorithm: %v", f.KeyDerivationAlgorithm)
+		return nil, errors.Errorf("unsupported key algorithm: %v", algorithm)
 	}
 }
 
@@ -77,6 +77,14 @@ func SupportedAlgorithms(includeDeprecated bool) []string {
 	return result
 }
 
+// IsDeprecated returns true if the provided encryption algorithm is deprecated and should not be used
+// by new repositories.
+func IsDeprecated(name string) bool {
+	e := encryptors[name]
+
+	return e != nil && e.deprecated
+}
+
 // Register registers new encryption algorithm.
 func Register(name, description string, deprecated bool, newEncryptor EncryptorFactory) {
 	encryptors[name] = &encryptorInfo{
@@ -209,3 +209,19 @@ func verifyCiphertextSamples(t *testing.T, masterKey, contentID, payload []byte,
 		}
 	}
 }
+
+func TestIsDeprecated(t *testing.T) {
+	cases := map[string]bool{
+		encryption.DefaultAlgorithm:        false,
+		"CHACHA20-POLY1305-HMAC-SHA256":    false,
+		"AES-256-CTR":                      true,
+		encryption.DeprecatedNoneAlgorithm: true,
+		"no-such-algorithm":                false,
+	}
+
+	for algo, want := range cases {
+		if got := encryption.IsDeprecated(algo); got != want {
+			t.Errorf("unexpected IsDeprecated(%q): %v, want %v", algo, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repo
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ErrNotFIPSApproved is returned in FIPS mode when the repository uses algorithms that are not approved by FIPS 140-2.
+var ErrNotFIPSApproved = errors.New("algorithm is not FIPS-approved")
+
+// fipsHashAlgorithms are content hash algorithms based on SHA-2 and SHA-3 families.
+var fipsHashAlgorithms = map[string]bool{
+	"HMAC-SHA256":     true,
+	"HMAC-SHA256-128": true,
+	"HMAC-SHA224":     true,
+	"HMAC-SHA3-224":   true,
+	"HMAC-SHA3-256":   true,
+}
+
+// fipsEncryptionAlgorithms are content encryption algorithms based on AES-GCM.
+var fipsEncryptionAlgorithms = map[string]bool{
+	"AES256-GCM-HMAC-SHA256": true,
+}
+
+// fipsModeEnabled is non-zero when FIPS mode was enabled at runtime.
+var fipsModeEnabled int32
+
+// SetFIPSMode enables or disables FIPS mode, which restricts cryptographic algorithms to FIPS-approved
+// AES-GCM, SHA-2, SHA-3, PBKDF2 and HKDF. FIPS mode can't be disabled in binaries built with 'fips' tag.
+func SetFIPSMode(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&fipsModeEnabled, v)
+}
+
+// FIPSMode returns true if FIPS mode is enabled.
+func FIPSMode() bool {
+	return fipsBuild || atomic.LoadInt32(&fipsModeEnabled) != 0
+}
+
+// FIPSHashAlgorithms returns the list of content hash algorithms allowed in FIPS mode.
+func FIPSHashAlgorithms() []string {
+	return sortedKeys(fipsHashAlgorithms)
+}
+
+// FIPSEncryptionAlgorithms returns the list of content encryption algorithms allowed in FIPS mode.
+func FIPSEncryptionAlgorithms() []string {
+	return sortedKeys(fipsEncryptionAlgorithms)
+}
+
+// ValidateFIPSOptions returns an error in FIPS mode if the provided options of a new repository, after applying
+// defaults, use algorithms that are not FIPS-approved.
+func ValidateFIPSOptions(opt *NewRepositoryOptions) error {
+	if opt == nil {
+		opt = &NewRepositoryOptions{}
+	}
+
+	if err := validateFIPSFormatBlob(formatBlobFromOptions(opt)); err != nil {
+		return err
+	}
+
+	return validateFIPSContentFormat(&repositoryObjectFormatFromOptions(opt).FormattingOptions)
+}
+
+// validateFIPSFormatBlob verifies algorithms used to protect the master key and the format blob.
+func validateFIPSFormatBlob(f *formatBlob) error {
+	if !FIPSMode() {
+		return nil
+	}
+
+	if f.EncryptionAlgorithm != FormatEncryptionAES256GCM {
+		return errors.Wrapf(ErrNotFIPSApproved, "format encryption %v", f.EncryptionAlgorithm)
+	}
+
+	return validateFIPSKeyDerivation(f.KeyDerivationAlgorithm)
+}
+
+// validateFIPSContentFormat verifies algorithms used to hash and encrypt contents.
+func validateFIPSContentFormat(fo *content.FormattingOptions) error {
+	if !FIPSMode() {
+		return nil
+	}
+
+	if !fipsHashAlgorithms[fo.Hash] {
+		return errors.Wrapf(ErrNotFIPSApproved, "content hash %v, use one of %v", fo.Hash, strings.Join(FIPSHashAlgorithms(), ", "))
+	}
+
+	if !fipsEncryptionAlgorithms[fo.Encryption] {
+		return errors.Wrapf(ErrNotFIPSApproved, "content encryption %v, use one of %v", fo.Encryption, strings.Join(FIPSEncryptionAlgorithms(), ", "))
+	}
+
+	for i, ke := range fo.KeyEpochs {
+		if !fipsEncryptionAlgorithms[ke.Encryption] {
+			return errors.Wrapf(ErrNotFIPSApproved, "content encryption %v of key epoch %v", ke.Encryption, i+1)
+		}
+	}
+
+	return nil
+}
+
+func validateFIPSKeyDerivation(algorithm string) error {
+	if !FIPSMode() || strings.HasPrefix(algorithm, pbkdf2Prefix) {
+		return nil
+	}
+
+	return errors.Wrapf(ErrNotFIPSApproved, "key derivation %v, use PBKDF2", algorithm)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var result []string
+	for k := range m {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,6 @@
+// +build !fips
+
+package repo
+
+// fipsBuild enables FIPS mode regardless of SetFIPSMode.
+const fipsBuild = false
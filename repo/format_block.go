@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"bytes"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -11,13 +12,23 @@ import (
 	"io"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
 
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/blob"
 )
 
+// Supported algorithms for encrypting the format blob.
 const (
-	defaultFormatEncryption         = "AES256_GCM"
+	FormatEncryptionAES256GCM        = "AES256_GCM"
+	FormatEncryptionChaCha20Poly1305 = "CHACHA20_POLY1305"
+)
+
+// SupportedFormatEncryptionAlgorithms lists algorithms that can be used to encrypt the format blob.
+var SupportedFormatEncryptionAlgorithms = []string{FormatEncryptionAES256GCM, FormatEncryptionChaCha20Poly1305}
+
+const (
+	defaultFormatEncryption         = FormatEncryptionAES256GCM
 	lengthOfRecoverBlockLength      = 2 // number of bytes used to store recover block length
 	maxChecksummedFormatBytesLength = 65000
 	maxRecoverChunkLength           = 65536
@@ -33,9 +44,28 @@ var formatBlobChecksumSecret = []byte("kopia-repository")
 // FormatBlobID is the identifier of a BLOB that describes repository format.
 const FormatBlobID = "kopia.repository"
 
+// Versions of the format blob, clients refuse to open repositories with format blob versions they don't support.
+const (
+	// formatBlobVersionLegacy is the version of format blobs where the key derived from password is the master key.
+	formatBlobVersionLegacy = "1"
+
+	// formatBlobVersionWrappedMasterKey is the version of format blobs where random master key is wrapped by keys of credentials.
+	formatBlobVersionWrappedMasterKey = "2"
+
+	// formatBlobVersionWithKeyEpochs is the version of format blobs of repositories with rotated encryption keys.
+	formatBlobVersionWithKeyEpochs = "3"
+)
+
+var supportedFormatBlobVersions = map[string]bool{
+	formatBlobVersionLegacy:           true,
+	formatBlobVersionWrappedMasterKey: true,
+	formatBlobVersionWithKeyEpochs:    true,
+}
+
 var (
-	purposeAESKey   = []byte("AES")
-	purposeAuthData = []byte("CHECKSUM")
+	purposeAESKey      = []byte("AES")
+	purposeChaCha20Key = []byte("CHACHA20")
+	purposeAuthData    = []byte("CHECKSUM")
 
 	errFormatBlobNotFound = errors.New("format blob not found")
 )
@@ -48,12 +78,28 @@ type formatBlob struct {
 	UniqueID               []byte `json:"uniqueID"`
 	KeyDerivationAlgorithm string `json:"keyAlgo"`
 
+	// WrappedMasterKeys contains the master key encrypted with keys derived from passwords of individual credentials,
+	// if not present the key derived from password is used as the master key.
+	WrappedMasterKeys []wrappedMasterKey `json:"wrappedMasterKeys,omitempty"`
+
 	Version              string                  `json:"version"`
 	EncryptionAlgorithm  string                  `json:"encryption"`
 	EncryptedFormatBytes []byte                  `json:"encryptedBlockFormat,omitempty"`
 	UnencryptedFormat    *repositoryObjectFormat `json:"blockFormat,omitempty"`
 }
 
+// wrappedMasterKey is the master key encrypted with a key derived from password of a named credential
+// (optionally combined with the response of a hardware key), with a key stored in key management service,
+// with a key stored in a key file or with a key derived from a recovery code.
+type wrappedMasterKey struct {
+	Name                 string `json:"name"`
+	KMSKeyURL            string `json:"kms,omitempty"`
+	HardwareKeyChallenge []byte `json:"hardwareKeyChallenge,omitempty"`
+	KeyFile              bool   `json:"keyFile,omitempty"`
+	RecoveryCode         bool   `json:"recoveryCode,omitempty"`
+	Key                  []byte `json:"key"`
+}
+
 // encryptedRepositoryConfig contains the configuration of repository that's persisted in encrypted format.
 type encryptedRepositoryConfig struct {
 	Format repositoryObjectFormat `json:"format"`
@@ -66,6 +112,10 @@ func parseFormatBlob(b []byte) (*formatBlob, error) {
 		return nil, errors.Wrap(err, "invalid format blob")
 	}
 
+	if !supportedFormatBlobVersions[f.Version] {
+		return nil, errors.Errorf("unsupported repository format version %q, upgrade kopia to open this repository", f.Version)
+	}
+
 	return f, nil
 }
 
@@ -154,29 +204,92 @@ func verifyFormatBlobChecksum(b []byte) ([]byte, bool) {
 	return data, true
 }
 
-func writeFormatBlob(ctx context.Context, st blob.Storage, f *formatBlob) error {
-	buf := gather.NewWriteBuffer()
-	e := json.NewEncoder(buf)
+func serializeFormatBlob(f *formatBlob) ([]byte, error) {
+	var buf bytes.Buffer
+
+	e := json.NewEncoder(&buf)
 	e.SetIndent("", "  ")
 
 	if err := e.Encode(f); err != nil {
-		return errors.Wrap(err, "unable to marshal format blob")
+		return nil, errors.Wrap(err, "unable to marshal format blob")
 	}
 
-	if err := st.PutBlob(ctx, FormatBlobID, buf.Bytes); err != nil {
+	return buf.Bytes(), nil
+}
+
+func writeFormatBlob(ctx context.Context, st blob.Storage, f *formatBlob) error {
+	b, err := serializeFormatBlob(f)
+	if err != nil {
+		return err
+	}
+
+	if err := st.PutBlob(ctx, FormatBlobID, gather.FromSlice(b)); err != nil {
 		return errors.Wrap(err, "unable to write format blob")
 	}
 
 	return nil
 }
 
+// ErrFormatBlobModified is returned when the format blob was replaced by another client while it was being updated.
+var ErrFormatBlobModified = errors.New("repository format blob was modified by another client, reopen the repository and try again")
+
+// updateFormatBlob applies the provided modification to the format blob read from the storage, bypassing the local
+// cache, so that changes made by other clients since the repository was opened are preserved. The blob storage
+// can't write conditionally, so the format blob is read back after it's written to verify that no other client
+// replaced it in the meantime.
+func (r *DirectRepository) updateFormatBlob(ctx context.Context, modify func(f *formatBlob) error) error {
+	current, err := r.Blobs.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "unable to read format blob")
+	}
+
+	f, err := parseFormatBlob(current)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(f.UniqueID, r.formatBlob.UniqueID) {
+		return errors.Errorf("format blob belongs to a different repository")
+	}
+
+	if err = modify(f); err != nil {
+		return err
+	}
+
+	written, err := serializeFormatBlob(f)
+	if err != nil {
+		return err
+	}
+
+	if err = r.Blobs.PutBlob(ctx, FormatBlobID, gather.FromSlice(written)); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	readBack, err := r.Blobs.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify format blob")
+	}
+
+	if !bytes.Equal(readBack, written) {
+		return ErrFormatBlobModified
+	}
+
+	r.formatBlob = f
+
+	if r.ConfigFile == "" {
+		return nil
+	}
+
+	return errors.Wrap(r.invalidateCachedFormatBlob(), "unable to invalidate cached format blob")
+}
+
 func (f *formatBlob) decryptFormatBytes(masterKey []byte) (*repositoryObjectFormat, error) {
 	switch f.EncryptionAlgorithm {
 	case "NONE": // do nothing
 		return f.UnencryptedFormat, nil
 
-	case "AES256_GCM":
-		aead, authData, err := initCrypto(masterKey, f.UniqueID)
+	case FormatEncryptionAES256GCM, FormatEncryptionChaCha20Poly1305:
+		aead, authData, err := initCrypto(f.EncryptionAlgorithm, masterKey, f.UniqueID)
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot initialize cipher")
 		}
@@ -206,10 +319,20 @@ func (f *formatBlob) decryptFormatBytes(masterKey []byte) (*repositoryObjectForm
 	}
 }
 
-func initCrypto(masterKey, repositoryID []byte) (cipher.AEAD, []byte, error) {
-	aesKey := deriveKeyFromMasterKey(masterKey, repositoryID, purposeAESKey, 32)
+func initCrypto(algorithm string, masterKey, repositoryID []byte) (cipher.AEAD, []byte, error) {
 	authData := deriveKeyFromMasterKey(masterKey, repositoryID, purposeAuthData, 32)
 
+	if algorithm == FormatEncryptionChaCha20Poly1305 {
+		aead, err := chacha20poly1305.New(deriveKeyFromMasterKey(masterKey, repositoryID, purposeChaCha20Key, chacha20poly1305.KeySize))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot create cipher")
+		}
+
+		return aead, authData, nil
+	}
+
+	aesKey := deriveKeyFromMasterKey(masterKey, repositoryID, purposeAESKey, 32)
+
 	blk, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "cannot create cipher")
@@ -229,13 +352,13 @@ func encryptFormatBytes(f *formatBlob, format *repositoryObjectFormat, masterKey
 		f.UnencryptedFormat = format
 		return nil
 
-	case "AES256_GCM":
+	case FormatEncryptionAES256GCM, FormatEncryptionChaCha20Poly1305:
 		content, err := json.Marshal(&encryptedRepositoryConfig{Format: *format})
 		if err != nil {
 			return errors.Wrap(err, "can't marshal format to JSON")
 		}
 
-		aead, authData, err := initCrypto(masterKey, repositoryID)
+		aead, authData, err := initCrypto(f.EncryptionAlgorithm, masterKey, repositoryID)
 		if err != nil {
 			return errors.Wrap(err, "unable to initialize crypto")
 		}
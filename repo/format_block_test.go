@@ -3,6 +3,7 @@ package repo
 import (
 	"crypto/sha256"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kopia/kopia/internal/blobtesting"
@@ -82,3 +83,119 @@ func assertNoError(t *testing.T, err error) {
 		t.Errorf("err: %v", err)
 	}
 }
+
+func TestFormatBlobEncryption(t *testing.T) {
+	masterKey := []byte("0123456789abcdef0123456789abcdef")
+	repositoryID := []byte("some-repository-id")
+
+	for _, alg := range SupportedFormatEncryptionAlgorithms {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			f := &formatBlob{
+				UniqueID:            repositoryID,
+				EncryptionAlgorithm: alg,
+			}
+
+			format := &repositoryObjectFormat{}
+			format.Hash = "HMAC-SHA256"
+			format.Splitter = "FIXED-1M"
+
+			assertNoError(t, encryptFormatBytes(f, format, masterKey, repositoryID))
+
+			if f.EncryptedFormatBytes == nil {
+				t.Fatalf("format was not encrypted")
+			}
+
+			got, err := f.decryptFormatBytes(masterKey)
+			if err != nil {
+				t.Fatalf("unable to decrypt: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, format) {
+				t.Errorf("unexpected decrypted format: %v, want %v", got, format)
+			}
+
+			if _, err := f.decryptFormatBytes([]byte("some-other-master-key-0123456789")); err == nil {
+				t.Errorf("unexpected success decrypting with wrong key")
+			}
+		})
+	}
+}
+
+func TestLegacyMasterKeyWrapping(t *testing.T) {
+	f := &formatBlob{
+		UniqueID:               []byte("some-repository-id"),
+		KeyDerivationAlgorithm: Argon2idKeyDerivationAlgorithm(1024, 1, 1),
+	}
+
+	// legacy repositories use password-derived key as the master key.
+	legacyMasterKey, _, err := f.deriveMasterKeyFromPassword(testlogging.Context(t), "old-password", nil)
+	if err != nil {
+		t.Fatalf("unable to derive master key: %v", err)
+	}
+
+	passwordKey, err := f.deriveKeyFromPassword("new-password")
+	if err != nil {
+		t.Fatalf("unable to derive key: %v", err)
+	}
+
+	wrapped, err := wrapMasterKey(passwordKey, legacyMasterKey, f.UniqueID)
+	if err != nil {
+		t.Fatalf("unable to wrap master key: %v", err)
+	}
+
+	f.WrappedMasterKeys = []wrappedMasterKey{{Name: "some-credential", Key: wrapped}}
+
+	got, credential, err := f.deriveMasterKeyFromPassword(testlogging.Context(t), "new-password", nil)
+	if err != nil || !reflect.DeepEqual(got, legacyMasterKey) || credential != "some-credential" {
+		t.Errorf("unexpected master key after wrapping: %x %v %v, want %x", got, credential, err, legacyMasterKey)
+	}
+
+	if _, _, err := f.deriveMasterKeyFromPassword(testlogging.Context(t), "old-password", nil); err != ErrInvalidPassword {
+		t.Errorf("unexpected error for old password: %v", err)
+	}
+}
+
+func TestChangePasswordRefusedWithPasswordDerivedMasterKey(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	f := &formatBlob{
+		UniqueID:               []byte("some-repository-id"),
+		KeyDerivationAlgorithm: Argon2idKeyDerivationAlgorithm(1024, 1, 1),
+		Version:                formatBlobVersionLegacy,
+	}
+
+	legacyMasterKey, _, err := f.deriveMasterKeyFromPassword(ctx, "old-password", nil)
+	if err != nil {
+		t.Fatalf("unable to derive master key: %v", err)
+	}
+
+	r := &DirectRepository{formatBlob: f, masterKey: legacyMasterKey, credential: DefaultCredentialName}
+
+	if err := r.ChangePassword(ctx, "new-password"); err != ErrPasswordDerivedMasterKey {
+		t.Errorf("unexpected error changing password of legacy repository: %v", err)
+	}
+
+	// adding credentials to legacy repository keeps the password-derived master key.
+	w, err := r.newWrappedMasterKey("other", "other-password")
+	if err != nil {
+		t.Fatalf("unable to wrap master key: %v", err)
+	}
+
+	f.WrappedMasterKeys = append(r.currentWrappedMasterKeys(), w)
+	r.credential = "other"
+
+	if err := r.ChangePassword(ctx, "new-password"); err != ErrPasswordDerivedMasterKey {
+		t.Errorf("unexpected error changing password of legacy repository with credentials: %v", err)
+	}
+}
+
+func TestUnsupportedFormatBlobVersion(t *testing.T) {
+	if _, err := parseFormatBlob([]byte(`{"version":"1"}`)); err != nil {
+		t.Errorf("unable to parse legacy format blob: %v", err)
+	}
+
+	if _, err := parseFormatBlob([]byte(`{"version":"99"}`)); err == nil || !strings.Contains(err.Error(), "unsupported repository format version") {
+		t.Errorf("unexpected error parsing format blob with unsupported version: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/diskspace"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// minFreeCacheSpace is the amount of free space required in the cache directory, which is also used for
+// temporary data when creating snapshots and running maintenance.
+const minFreeCacheSpace = 100 << 20
+
+// ErrInsufficientScratchSpace is returned when the cache directory does not have enough free space.
+var ErrInsufficientScratchSpace = errors.New("insufficient free space in cache directory")
+
+// availableDiskSpace returns free space of the filesystem containing the path, overridden in tests.
+var availableDiskSpace = diskspace.Available
+
+// CheckFreeCacheSpace returns ErrInsufficientScratchSpace when the cache directory is almost full,
+// so that operations writing to it can fail early instead of running out of space midway.
+func (r *DirectRepository) CheckFreeCacheSpace() error {
+	dir := r.Content.CachingOptions.CacheDirectory
+	if dir == "" {
+		return nil
+	}
+
+	avail, err := availableDiskSpace(dir)
+	if err != nil {
+		// free space is unknown, do not prevent the operation.
+		return nil
+	}
+
+	if avail < minFreeCacheSpace {
+		return errors.Wrapf(ErrInsufficientScratchSpace, "%v available in %v, at least %v is required, free up space or move the cache using 'kopia cache set --cache-directory'",
+			units.BytesStringBase10(avail), dir, units.BytesStringBase10(minFreeCacheSpace))
+	}
+
+	return nil
+}
+
+// limitCachesToAvailableSpace reduces maximum sizes of caches proportionally when the cache directory
+// does not have enough free space for them to grow to their configured sizes.
+func limitCachesToAvailableSpace(ctx context.Context, opt *content.CachingOptions) {
+	if opt.CacheDirectory == "" {
+		return
+	}
+
+	metadataCacheSize := opt.MaxMetadataCacheSizeBytes
+	if metadataCacheSize == 0 {
+		metadataCacheSize = opt.MaxCacheSizeBytes
+	}
+
+	total := opt.MaxCacheSizeBytes + metadataCacheSize + opt.MaxBlobCacheSizeBytes
+	if total == 0 {
+		return
+	}
+
+	avail, err := availableDiskSpace(opt.CacheDirectory)
+	if err != nil {
+		log(ctx).Debugf("unable to determine free space in %v: %v", opt.CacheDirectory, err)
+		return
+	}
+
+	if avail >= total+minFreeCacheSpace {
+		return
+	}
+
+	// space already used by caches counts towards their sizes.
+	used, err := diskspace.Used(opt.CacheDirectory)
+	if err != nil {
+		log(ctx).Debugf("unable to determine size of %v: %v", opt.CacheDirectory, err)
+	}
+
+	budget := used + avail - minFreeCacheSpace
+	if budget >= total {
+		return
+	}
+
+	if budget < 0 {
+		budget = 0
+	}
+
+	scale := func(v int64) int64 {
+		return int64(float64(v) * float64(budget) / float64(total))
+	}
+
+	opt.MaxCacheSizeBytes = scale(opt.MaxCacheSizeBytes)
+	opt.MaxMetadataCacheSizeBytes = scale(metadataCacheSize)
+	opt.MaxBlobCacheSizeBytes = scale(opt.MaxBlobCacheSizeBytes)
+
+	log(ctx).Warningf("only %v available in cache directory %v, limiting caches to %v instead of %v",
+		units.BytesStringBase10(avail), opt.CacheDirectory, units.BytesStringBase10(budget), units.BytesStringBase10(total))
+}
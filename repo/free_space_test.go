@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestLimitCachesToAvailableSpace(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(cacheDir) //nolint:errcheck
+
+	// caches already use 1 MB.
+	if err = ioutil.WriteFile(filepath.Join(cacheDir, "cached"), make([]byte, 1<<20), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	defer func(f func(string) (int64, error)) { availableDiskSpace = f }(availableDiskSpace)
+
+	cases := []struct {
+		available int64
+		input     content.CachingOptions
+		want      content.CachingOptions
+	}{
+		// enough space
+		{
+			available: 1000 << 20,
+			input:     content.CachingOptions{MaxCacheSizeBytes: 300 << 20, MaxMetadataCacheSizeBytes: 200 << 20, MaxBlobCacheSizeBytes: 100 << 20},
+			want:      content.CachingOptions{MaxCacheSizeBytes: 300 << 20, MaxMetadataCacheSizeBytes: 200 << 20, MaxBlobCacheSizeBytes: 100 << 20},
+		},
+		// caches are limited to 1 MB used + 499 MB available - 100 MB reserved.
+		{
+			available: 499 << 20,
+			input:     content.CachingOptions{MaxCacheSizeBytes: 400 << 20, MaxMetadataCacheSizeBytes: 200 << 20, MaxBlobCacheSizeBytes: 200 << 20},
+			want:      content.CachingOptions{MaxCacheSizeBytes: 200 << 20, MaxMetadataCacheSizeBytes: 100 << 20, MaxBlobCacheSizeBytes: 100 << 20},
+		},
+		// metadata cache inherits the size of content cache when not set.
+		{
+			available: 299 << 20,
+			input:     content.CachingOptions{MaxCacheSizeBytes: 200 << 20},
+			want:      content.CachingOptions{MaxCacheSizeBytes: 100 << 20, MaxMetadataCacheSizeBytes: 100 << 20},
+		},
+		// no space at all.
+		{
+			available: 10 << 20,
+			input:     content.CachingOptions{MaxCacheSizeBytes: 200 << 20},
+			want:      content.CachingOptions{},
+		},
+	}
+
+	for _, tc := range cases {
+		available := tc.available
+		availableDiskSpace = func(string) (int64, error) { return available, nil }
+
+		opt := tc.input
+		opt.CacheDirectory = cacheDir
+		tc.want.CacheDirectory = cacheDir
+
+		limitCachesToAvailableSpace(testlogging.Context(t), &opt)
+
+		if !reflect.DeepEqual(opt, tc.want) {
+			t.Errorf("unexpected caching options with %v available: %+v, want %+v", available, opt, tc.want)
+		}
+	}
+}
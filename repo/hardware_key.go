@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const hardwareKeyChallengeSize = 32
+
+var purposeHardwareKey = []byte("HARDWARE-KEY")
+
+// HardwareKeyFunc computes the response of a hardware security key to the provided challenge.
+// The response must be deterministic for a given challenge and device.
+type HardwareKeyFunc func(ctx context.Context, challenge []byte) ([]byte, error)
+
+// ErrHardwareKeyRequired is returned when adding a hardware key credential without hardware key configured.
+var ErrHardwareKeyRequired = errors.New("hardware key not configured")
+
+// CommandHardwareKey returns HardwareKeyFunc that invokes the provided command with the hex-encoded challenge
+// appended to its arguments and reads the hex-encoded response from its standard output.
+// For example 'ykchalresp -2 -x' computes HMAC-SHA1 challenge-response using slot 2 of the YubiKey,
+// a wrapper script around 'fido2-assert' can use FIDO2 hmac-secret extension.
+func CommandHardwareKey(cmdLine string) HardwareKeyFunc {
+	parts := strings.Fields(cmdLine)
+
+	return func(ctx context.Context, challenge []byte) ([]byte, error) {
+		if len(parts) == 0 {
+			return nil, ErrHardwareKeyRequired
+		}
+
+		args := append(append([]string(nil), parts[1:]...), hex.EncodeToString(challenge))
+
+		var stdout bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, parts[0], args...) // nolint:gosec
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return nil, errors.Wrap(err, "hardware key command failed")
+		}
+
+		response, err := hex.DecodeString(strings.TrimSpace(stdout.String()))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid hardware key response")
+		}
+
+		if len(response) == 0 {
+			return nil, errors.New("empty hardware key response")
+		}
+
+		return response, nil
+	}
+}
+
+// AddHardwareKeyCredential adds a named credential that requires both the provided password and the
+// response of the hardware key the repository was opened with to open the repository.
+func (r *DirectRepository) AddHardwareKeyCredential(ctx context.Context, name, password string) error {
+	w, err := r.newHardwareKeyWrappedMasterKey(ctx, name, password)
+	if err != nil {
+		return err
+	}
+
+	return r.addWrappedMasterKey(ctx, w)
+}
+
+// CredentialUsesHardwareKey returns true if the credential with the provided name requires a hardware key.
+func (r *DirectRepository) CredentialUsesHardwareKey(name string) bool {
+	for _, w := range r.formatBlob.WrappedMasterKeys {
+		if w.Name == name {
+			return len(w.HardwareKeyChallenge) > 0
+		}
+	}
+
+	return false
+}
+
+// newHardwareKeyWrappedMasterKey wraps the master key with the provided password and the response of the hardware key
+// to a new random challenge.
+func (r *DirectRepository) newHardwareKeyWrappedMasterKey(ctx context.Context, name, password string) (wrappedMasterKey, error) {
+	if r.hardwareKey == nil {
+		return wrappedMasterKey{}, ErrHardwareKeyRequired
+	}
+
+	challenge := make([]byte, hardwareKeyChallengeSize)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to generate challenge")
+	}
+
+	passwordKey, err := r.formatBlob.deriveKeyFromPassword(password)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to derive key from password")
+	}
+
+	response, err := r.hardwareKey(ctx, challenge)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to get hardware key response")
+	}
+
+	wrapped, err := wrapMasterKey(hardwareKeyCredentialKey(passwordKey, response, r.formatBlob.UniqueID), r.masterKey, r.formatBlob.UniqueID)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to wrap master key")
+	}
+
+	return wrappedMasterKey{Name: name, HardwareKeyChallenge: challenge, Key: wrapped}, nil
+}
+
+// hardwareKeyCredentialKey combines the password-derived key with the response of the hardware key,
+// so that both are needed to unwrap the master key.
+func hardwareKeyCredentialKey(passwordKey, response, uniqueID []byte) []byte {
+	const keySize = 32
+
+	return deriveKeyFromMasterKey(append(append([]byte(nil), passwordKey...), response...), uniqueID, purposeHardwareKey, keySize)
+}
@@ -34,6 +34,12 @@ type NewRepositoryOptions struct {
 	BlockFormat  content.FormattingOptions `json:"blockFormat"`
 	DisableHMAC  bool                      `json:"disableHMAC"`
 	ObjectFormat object.Format             `json:"objectFormat"` // object format
+
+	FormatEncryption       string `json:"formatEncryption,omitempty"`       // algorithm used to encrypt the format blob
+	KeyDerivationAlgorithm string `json:"keyDerivationAlgorithm,omitempty"` // algorithm used to derive master key from password
+
+	// RecoveryCode created with GenerateRecoveryCode() opens the repository as RecoveryCodeCredentialName credential.
+	RecoveryCode string `json:"-"`
 }
 
 // ErrAlreadyInitialized indicates that repository has already been initialized.
@@ -45,6 +51,10 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 		opt = &NewRepositoryOptions{}
 	}
 
+	if err := ValidateFIPSOptions(opt); err != nil {
+		return err
+	}
+
 	// get the blob - expect ErrNotFound
 	_, err := st.GetBlob(ctx, FormatBlobID, 0, -1)
 	if err == nil {
@@ -57,12 +67,37 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 
 	format := formatBlobFromOptions(opt)
 
-	masterKey, err := format.deriveMasterKeyFromPassword(password)
+	objectFormat := repositoryObjectFormatFromOptions(opt)
+	if err := validateChunkSizes(objectFormat.Format); err != nil {
+		return err
+	}
+
+	passwordKey, err := format.deriveKeyFromPassword(password)
+	if err != nil {
+		return errors.Wrap(err, "unable to derive key from password")
+	}
+
+	// random master key is wrapped with the password-derived key, so that password can be changed later
+	// without affecting keys derived from the master key.
+	masterKey := randomBytes(masterKeyLength)
+
+	wrapped, err := wrapMasterKey(passwordKey, masterKey, format.UniqueID)
 	if err != nil {
-		return errors.Wrap(err, "unable to derive master key")
+		return errors.Wrap(err, "unable to wrap master key")
 	}
 
-	if err := encryptFormatBytes(format, repositoryObjectFormatFromOptions(opt), masterKey, format.UniqueID); err != nil {
+	format.WrappedMasterKeys = []wrappedMasterKey{{Name: DefaultCredentialName, Key: wrapped}}
+
+	if opt.RecoveryCode != "" {
+		w, err := newRecoveryCodeWrappedMasterKey(RecoveryCodeCredentialName, opt.RecoveryCode, masterKey, format.UniqueID)
+		if err != nil {
+			return err
+		}
+
+		format.WrappedMasterKeys = append(format.WrappedMasterKeys, w)
+	}
+
+	if err := encryptFormatBytes(format, objectFormat, masterKey, format.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to encrypt format bytes")
 	}
 
@@ -77,10 +112,10 @@ func formatBlobFromOptions(opt *NewRepositoryOptions) *formatBlob {
 	return &formatBlob{
 		Tool:                   "https://github.com/kopia/kopia",
 		BuildInfo:              BuildInfo,
-		KeyDerivationAlgorithm: defaultKeyDerivationAlgorithm,
+		KeyDerivationAlgorithm: applyDefaultString(opt.KeyDerivationAlgorithm, newKeyDerivationAlgorithm()),
 		UniqueID:               applyDefaultRandomBytes(opt.UniqueID, uniqueIDLength),
-		Version:                "1",
-		EncryptionAlgorithm:    defaultFormatEncryption,
+		Version:                formatBlobVersionWrappedMasterKey,
+		EncryptionAlgorithm:    applyDefaultString(opt.FormatEncryption, defaultFormatEncryption),
 	}
 }
 
@@ -99,6 +134,12 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObj
 		},
 	}
 
+	if avg := opt.ObjectFormat.AvgChunkSize; avg != 0 {
+		f.MinChunkSize = applyDefaultInt(opt.ObjectFormat.MinChunkSize, avg/2) //nolint:gomnd
+		f.AvgChunkSize = avg
+		f.MaxChunkSize = applyDefaultInt(opt.ObjectFormat.MaxChunkSize, avg*2) //nolint:gomnd
+	}
+
 	if opt.DisableHMAC {
 		f.HMACSecret = nil
 	}
@@ -106,6 +147,20 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObj
 	return f
 }
 
+func validateChunkSizes(f object.Format) error {
+	if f.AvgChunkSize == 0 {
+		if f.MinChunkSize != 0 || f.MaxChunkSize != 0 {
+			return errors.Errorf("minimum and maximum chunk sizes require average chunk size")
+		}
+
+		return nil
+	}
+
+	_, err := splitter.GetFactoryWithChunkSizes(f.Splitter, f.MinChunkSize, f.AvgChunkSize, f.MaxChunkSize)
+
+	return errors.Wrap(err, "invalid chunk sizes")
+}
+
 func randomBytes(n int) []byte {
 	b := make([]byte, n)
 	io.ReadFull(rand.Reader, b) //nolint:errcheck
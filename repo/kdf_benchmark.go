@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of key derivation algorithms that can be benchmarked.
+const (
+	KeyDerivationKindScrypt   = "scrypt"
+	KeyDerivationKindArgon2id = "argon2id"
+	KeyDerivationKindPBKDF2   = "pbkdf2"
+)
+
+// minBenchmarkDuration is the minimum duration of key derivation measured to reliably extrapolate its cost.
+const minBenchmarkDuration = 100 * time.Millisecond
+
+// KeyDerivationBenchmark is the key derivation algorithm selected by BenchmarkKeyDerivation and the time
+// it takes to derive a key on this machine.
+type KeyDerivationBenchmark struct {
+	Algorithm string
+	Duration  time.Duration
+}
+
+// kdfCostModel describes how the cost parameter of a kind of key derivation maps to the algorithm.
+type kdfCostModel struct {
+	algorithm  func(cost int) string
+	minCost    int  // default parameters, benchmarking never selects weaker ones
+	powerOfTwo bool // whether cost must be a power of two
+}
+
+var kdfCostModels = map[string]kdfCostModel{
+	KeyDerivationKindScrypt: {
+		algorithm:  func(cost int) string { return ScryptKeyDerivationAlgorithm(cost, 8, 1) }, //nolint:gomnd
+		minCost:    65536,                                                                     //nolint:gomnd
+		powerOfTwo: true,
+	},
+	KeyDerivationKindArgon2id: {
+		algorithm: func(cost int) string {
+			return Argon2idKeyDerivationAlgorithm(DefaultArgon2idMemoryKiB, uint32(cost), DefaultArgon2idParallelism)
+		},
+		minCost: DefaultArgon2idIterations,
+	},
+	KeyDerivationKindPBKDF2: {
+		algorithm: PBKDF2KeyDerivationAlgorithm,
+		minCost:   DefaultPBKDF2Iterations,
+	},
+}
+
+// BenchmarkKeyDerivation selects parameters of the provided kind of key derivation ("scrypt", "argon2id" or "pbkdf2"),
+// for which deriving a key takes approximately the target duration on this machine, but never weaker than the defaults.
+// Only the CPU cost is adjusted, so memory use of scrypt grows with the cost and memory use of argon2id is unchanged.
+func BenchmarkKeyDerivation(kind string, target time.Duration) (KeyDerivationBenchmark, error) {
+	m, ok := kdfCostModels[kind]
+	if !ok {
+		return KeyDerivationBenchmark{}, errors.Errorf("unsupported key derivation %q", kind)
+	}
+
+	if err := validateFIPSKeyDerivation(m.algorithm(m.minCost)); err != nil {
+		return KeyDerivationBenchmark{}, err
+	}
+
+	cost := m.minCost
+
+	dt, err := timeKeyDerivation(m.algorithm(cost))
+	if err != nil {
+		return KeyDerivationBenchmark{}, err
+	}
+
+	// derivation with default parameters may be too fast to be measured reliably.
+	for dt < minBenchmarkDuration && dt < target {
+		cost *= 2
+
+		if dt, err = timeKeyDerivation(m.algorithm(cost)); err != nil {
+			return KeyDerivationBenchmark{}, err
+		}
+	}
+
+	cost = m.scaledCost(cost, float64(target)/float64(dt))
+
+	algorithm := m.algorithm(cost)
+
+	if dt, err = timeKeyDerivation(algorithm); err != nil {
+		return KeyDerivationBenchmark{}, err
+	}
+
+	return KeyDerivationBenchmark{algorithm, dt}, nil
+}
+
+// scaledCost returns the cost multiplied by the factor, rounded to the nearest power of two if needed.
+func (m kdfCostModel) scaledCost(cost int, factor float64) int {
+	scaled := float64(cost) * factor
+
+	if m.powerOfTwo {
+		scaled = math.Pow(2, math.Round(math.Log2(scaled))) //nolint:gomnd
+	}
+
+	if scaled < float64(m.minCost) {
+		return m.minCost
+	}
+
+	return int(math.Round(scaled))
+}
+
+func timeKeyDerivation(algorithm string) (time.Duration, error) {
+	salt := make([]byte, uniqueIDLength)
+
+	t0 := time.Now() // allow:no-inject-time
+
+	if _, err := deriveKeyFromPasswordWithAlgorithm(algorithm, "benchmark-password", salt); err != nil {
+		return 0, err
+	}
+
+	return time.Since(t0), nil // allow:no-inject-time
+}
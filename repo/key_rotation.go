@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+// RotateEncryptionKey adds a new key epoch with a random master key, which is used to encrypt contents written
+// after the repository is reopened, optionally switching to a different encryption algorithm (empty keeps the current one).
+// Existing contents remain readable using keys of previous epochs and are re-encrypted with the new key
+// when they are rewritten, which full maintenance does for all contents encrypted with keys of previous epochs.
+//
+// The key epoch is added to the format blob read from the storage and the format blob is verified after it's written,
+// so that keys added concurrently by other clients are never lost. Other clients that keep the repository open,
+// such as servers, keep encrypting with the previous key and can't read contents encrypted with the new key
+// until they reopen the repository.
+//
+// Returns the new key epoch.
+func (r *DirectRepository) RotateEncryptionKey(ctx context.Context, encryptionAlgorithm string) (int, error) {
+	var rotated []content.KeyEpoch
+
+	if err := r.updateFormatBlob(ctx, func(f *formatBlob) error {
+		repoConfig, err := f.decryptFormatBytes(r.masterKey)
+		if err != nil {
+			return errors.Wrap(err, "unable to decrypt repository config")
+		}
+
+		fo := &repoConfig.FormattingOptions
+
+		// key epochs known to this client must never be dropped.
+		if !keyEpochsPrefix(r.Content.Format.KeyEpochs, fo.KeyEpochs) {
+			return errors.Errorf("key epochs in the storage don't match key epochs of this client, reopen the repository")
+		}
+
+		if fo.CurrentKeyEpoch() >= content.MaxKeyEpoch {
+			return errors.Errorf("the encryption key can't be rotated more than %v times", content.MaxKeyEpoch)
+		}
+
+		ke := content.KeyEpoch{
+			Encryption: applyDefaultString(encryptionAlgorithm, fo.CurrentEncryption()),
+			MasterKey:  randomBytes(masterKeyLength),
+		}
+
+		if _, err := encryption.CreateEncryptor(&ke); err != nil {
+			return errors.Wrap(err, "invalid encryption algorithm")
+		}
+
+		fo.Version = content.KeyEpochsVersion
+		fo.KeyEpochs = append(fo.KeyEpochs, ke)
+		rotated = fo.KeyEpochs
+
+		if err := validateFIPSContentFormat(fo); err != nil {
+			return err
+		}
+
+		f.Version = formatBlobVersionWithKeyEpochs
+
+		return errors.Wrap(encryptFormatBytes(f, repoConfig, r.masterKey, f.UniqueID), "unable to encrypt format bytes")
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := r.verifyKeyEpochs(ctx, rotated); err != nil {
+		// nothing is encrypted with the new key until the repository is reopened, so it's safe to try again.
+		return 0, errors.Wrap(err, "unable to verify rotated key")
+	}
+
+	return len(rotated), nil
+}
+
+// verifyKeyEpochs reads the format blob from the storage and verifies that it contains the provided key epochs.
+func (r *DirectRepository) verifyKeyEpochs(ctx context.Context, want []content.KeyEpoch) error {
+	b, err := r.Blobs.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "unable to read format blob")
+	}
+
+	f, err := parseFormatBlob(b)
+	if err != nil {
+		return err
+	}
+
+	repoConfig, err := f.decryptFormatBytes(r.masterKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	if got := repoConfig.FormattingOptions.KeyEpochs; len(got) != len(want) || !keyEpochsPrefix(want, got) {
+		return ErrFormatBlobModified
+	}
+
+	return nil
+}
+
+// keyEpochsPrefix returns true if the key epochs in prefix are the first key epochs in all.
+func keyEpochsPrefix(prefix, all []content.KeyEpoch) bool {
+	if len(prefix) > len(all) {
+		return false
+	}
+
+	for i, ke := range prefix {
+		if ke.Encryption != all[i].Encryption || !bytes.Equal(ke.MasterKey, all[i].MasterKey) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,225 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyFileVersion  = 1
+	keyFileKeySize  = 32
+	keyFileSaltSize = 32
+)
+
+var purposeKeyFile = []byte("KEYFILE")
+
+// ErrInvalidKeyFile is returned when the key file can't be read or does not unwrap any key file credential.
+var ErrInvalidKeyFile = errors.New("invalid key file")
+
+// keyFile is the JSON-encoded contents of the key file.
+type keyFile struct {
+	Version int `json:"version"`
+
+	// Key is the random key stored in an unprotected key file.
+	Key []byte `json:"key,omitempty"`
+
+	// EncryptedKey is the random key encrypted with a key derived from the passphrase of a protected key file.
+	EncryptedKey           []byte `json:"encryptedKey,omitempty"`
+	KeyDerivationAlgorithm string `json:"keyAlgo,omitempty"`
+	Salt                   []byte `json:"salt,omitempty"`
+
+	// TPM is the random key sealed to the local TPM.
+	TPM *tpmSealedKey `json:"tpm,omitempty"`
+}
+
+// GenerateKeyFile writes a new key file with a random key at the provided path, which can be added as a credential
+// to open repositories without a password. When the passphrase is not empty, the key is encrypted with it.
+func GenerateKeyFile(path, passphrase string) error {
+	kf := &keyFile{Version: keyFileVersion}
+
+	key := make([]byte, keyFileKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return errors.Wrap(err, "unable to generate key")
+	}
+
+	if passphrase == "" {
+		kf.Key = key
+	} else {
+		kf.KeyDerivationAlgorithm = newKeyDerivationAlgorithm()
+		kf.Salt = make([]byte, keyFileSaltSize)
+
+		if _, err := io.ReadFull(rand.Reader, kf.Salt); err != nil {
+			return errors.Wrap(err, "unable to generate salt")
+		}
+
+		passphraseKey, err := deriveKeyFromPasswordWithAlgorithm(kf.KeyDerivationAlgorithm, passphrase, kf.Salt)
+		if err != nil {
+			return errors.Wrap(err, "unable to derive key from passphrase")
+		}
+
+		if kf.EncryptedKey, err = wrapMasterKey(passphraseKey, key, kf.Salt); err != nil {
+			return errors.Wrap(err, "unable to encrypt key")
+		}
+	}
+
+	return writeKeyFile(path, kf)
+}
+
+// GenerateTPMKeyFile writes a new key file at the provided path with a random key sealed to the local TPM,
+// which can only be unsealed on this machine while the provided PCRs (if any) have their current values.
+func GenerateTPMKeyFile(ctx context.Context, path, pcrs string) error {
+	if err := ValidateTPMPCRs(pcrs); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("key file %v already exists", path)
+	}
+
+	sealed, err := tpm.Seal(ctx, randomBytes(keyFileKeySize), pcrs)
+	if err != nil {
+		return errors.Wrap(err, "unable to seal key to TPM")
+	}
+
+	return writeKeyFile(path, &keyFile{Version: keyFileVersion, TPM: sealed})
+}
+
+func writeKeyFile(path string, kf *keyFile) error {
+	b, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal key file")
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("key file %v already exists", path)
+	}
+
+	if err := atomic.WriteFile(path, bytes.NewReader(b)); err != nil {
+		return errors.Wrap(err, "unable to write key file")
+	}
+
+	return os.Chmod(path, 0600) //nolint:gomnd
+}
+
+// KeyFileRequiresPassphrase returns true if the key file at the provided path is protected with a passphrase.
+func KeyFileRequiresPassphrase(path string) (bool, error) {
+	kf, err := loadKeyFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	return kf.Key == nil && kf.TPM == nil, nil
+}
+
+// AddKeyFileCredential adds a named credential that opens the repository using the key stored in the provided key file
+// and its passphrase if it's protected.
+func (r *DirectRepository) AddKeyFileCredential(ctx context.Context, name, path, passphrase string) error {
+	key, err := readKeyFile(ctx, path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := wrapMasterKey(keyFileCredentialKey(key, r.formatBlob.UniqueID), r.masterKey, r.formatBlob.UniqueID)
+	if err != nil {
+		return errors.Wrap(err, "unable to wrap master key")
+	}
+
+	return r.addWrappedMasterKey(ctx, wrappedMasterKey{Name: name, KeyFile: true, Key: wrapped})
+}
+
+// CredentialUsesKeyFile returns true if the credential with the provided name is protected by a key file.
+func (r *DirectRepository) CredentialUsesKeyFile(name string) bool {
+	for _, w := range r.formatBlob.WrappedMasterKeys {
+		if w.Name == name {
+			return w.KeyFile
+		}
+	}
+
+	return false
+}
+
+// unwrapMasterKeyWithKeyFile returns the master key of the repository and the name of the credential
+// it was unlocked with using the key stored in the key file.
+func (f *formatBlob) unwrapMasterKeyWithKeyFile(ctx context.Context, path, passphrase string) (masterKey []byte, credential string, err error) {
+	key, err := readKeyFile(ctx, path, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+
+	unwrapKey := keyFileCredentialKey(key, f.UniqueID)
+
+	for _, w := range f.WrappedMasterKeys {
+		if !w.KeyFile {
+			continue
+		}
+
+		if masterKey, err := unwrapMasterKey(unwrapKey, w.Key, f.UniqueID); err == nil {
+			return masterKey, w.Name, nil
+		}
+	}
+
+	return nil, "", ErrInvalidKeyFile
+}
+
+func loadKeyFile(path string) (*keyFile, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open key file")
+	}
+	defer f.Close() //nolint:errcheck
+
+	kf := &keyFile{}
+	if err := json.NewDecoder(f).Decode(kf); err != nil {
+		return nil, errors.Wrap(ErrInvalidKeyFile, err.Error())
+	}
+
+	if kf.Version != keyFileVersion {
+		return nil, errors.Errorf("unsupported key file version %v", kf.Version)
+	}
+
+	return kf, nil
+}
+
+// readKeyFile returns the key stored in the key file, decrypting it with the passphrase or unsealing it using the TPM if needed.
+func readKeyFile(ctx context.Context, path, passphrase string) ([]byte, error) {
+	kf, err := loadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if kf.Key != nil {
+		return kf.Key, nil
+	}
+
+	if kf.TPM != nil {
+		key, err := tpm.Unseal(ctx, kf.TPM)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to unseal key file using TPM")
+		}
+
+		return key, nil
+	}
+
+	passphraseKey, err := deriveKeyFromPasswordWithAlgorithm(kf.KeyDerivationAlgorithm, passphrase, kf.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive key from passphrase")
+	}
+
+	key, err := unwrapMasterKey(passphraseKey, kf.EncryptedKey, kf.Salt)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidKeyFile, "invalid key file passphrase")
+	}
+
+	return key, nil
+}
+
+func keyFileCredentialKey(key, uniqueID []byte) []byte {
+	return deriveKeyFromMasterKey(key, uniqueID, purposeKeyFile, keyFileKeySize)
+}
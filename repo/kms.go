@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/secrets"
+
+	// Register supported key management services.
+	_ "gocloud.dev/secrets/awskms"
+	_ "gocloud.dev/secrets/azurekeyvault"
+	_ "gocloud.dev/secrets/gcpkms"
+)
+
+// ErrNoKMSCredentials is returned when opening the repository using KMS and it has no KMS credentials.
+var ErrNoKMSCredentials = errors.New("repository has no KMS credentials")
+
+// AddKMSCredential adds a named credential that wraps the master key using the key stored in a cloud key management
+// service instead of a password, such as 'awskms://alias/name?region=us-east-1',
+// 'gcpkms://projects/P/locations/L/keyRings/R/cryptoKeys/K' or 'azurekeyvault://V.vault.azure.net/keys/K'.
+// Access to the key and its rotation are governed by the key management service, which also audits its usage.
+func (r *DirectRepository) AddKMSCredential(ctx context.Context, name, keyURL string) error {
+	if keyURL == "" {
+		return errors.New("KMS key URL must not be empty")
+	}
+
+	wrapped, err := kmsEncrypt(ctx, keyURL, r.masterKey)
+	if err != nil {
+		return err
+	}
+
+	return r.addWrappedMasterKey(ctx, wrappedMasterKey{Name: name, KMSKeyURL: keyURL, Key: wrapped})
+}
+
+// CredentialKMSKeyURL returns the URL of the KMS key used by the credential with the provided name
+// or an empty string if it's protected by a password.
+func (r *DirectRepository) CredentialKMSKeyURL(name string) string {
+	for _, w := range r.formatBlob.WrappedMasterKeys {
+		if w.Name == name {
+			return w.KMSKeyURL
+		}
+	}
+
+	return ""
+}
+
+// unwrapMasterKeyWithKMS returns the master key of the repository and the name of the credential it was unlocked with
+// by decrypting it using the first KMS credential the caller is allowed to use.
+func (f *formatBlob) unwrapMasterKeyWithKMS(ctx context.Context) (masterKey []byte, credential string, err error) {
+	lastErr := ErrNoKMSCredentials
+
+	for _, w := range f.WrappedMasterKeys {
+		if w.KMSKeyURL == "" {
+			continue
+		}
+
+		masterKey, err := kmsDecrypt(ctx, w.KMSKeyURL, w.Key)
+		if err != nil {
+			log(ctx).Debugf("unable to unwrap master key with credential %v: %v", w.Name, err)
+			lastErr = err
+
+			continue
+		}
+
+		return masterKey, w.Name, nil
+	}
+
+	return nil, "", lastErr
+}
+
+func kmsEncrypt(ctx context.Context, keyURL string, plaintext []byte) ([]byte, error) {
+	keeper, err := secrets.OpenKeeper(ctx, keyURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open KMS key %v", keyURL)
+	}
+	defer keeper.Close() //nolint:errcheck
+
+	ciphertext, err := keeper.Encrypt(ctx, plaintext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to encrypt with KMS key %v", keyURL)
+	}
+
+	return ciphertext, nil
+}
+
+func kmsDecrypt(ctx context.Context, keyURL string, ciphertext []byte) ([]byte, error) {
+	keeper, err := secrets.OpenKeeper(ctx, keyURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open KMS key %v", keyURL)
+	}
+	defer keeper.Close() //nolint:errcheck
+
+	plaintext, err := keeper.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt with KMS key %v", keyURL)
+	}
+
+	return plaintext, nil
+}
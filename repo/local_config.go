@@ -1,10 +1,15 @@
 package repo
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"os"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
@@ -22,8 +27,43 @@ type LocalConfig struct {
 
 	Hostname string `json:"hostname"`
 	Username string `json:"username"`
+
+	// StorageQuotaBytes is the approximate maximum size of the storage, new data is refused above it when non-zero.
+	StorageQuotaBytes int64 `json:"storageQuotaBytes,omitempty"`
+
+	// UseKMS indicates that the master key is unwrapped using KMS credentials instead of a password.
+	UseKMS bool `json:"useKMS,omitempty"`
+
+	// HardwareKeyCommand is the command computing responses of the hardware key to challenges of hardware key credentials.
+	HardwareKeyCommand string `json:"hardwareKeyCommand,omitempty"`
+
+	// KeyFile is the path of the key file used to unwrap the master key, the password is used as its passphrase.
+	KeyFile string `json:"keyFile,omitempty"`
+
+	// EnableActions allows running commands defined in snapshot actions of policies on this machine.
+	EnableActions bool `json:"enableActions,omitempty"`
+
+	// HMAC protects the configuration from tampering, it's computed over the remaining fields when connecting
+	// with a key derived from the master key, or from the password when the configuration specifies a key file
+	// or hardware key command, so that it's verified before they are used.
+	HMAC []byte `json:"hmac,omitempty"`
 }
 
+const localConfigHMACKeySize = 32
+
+var purposeLocalConfig = []byte("LOCAL-CONFIG")
+
+// ErrLocalConfigTampered is returned when the local configuration has been modified outside of kopia.
+var ErrLocalConfigTampered = errors.New("local configuration has been tampered with, reconnect to the repository")
+
+// ErrInvalidPasswordOrLocalConfigTampered is returned when the HMAC of the local configuration keyed by the password
+// doesn't match, which happens both when the password is invalid and when the configuration has been tampered with.
+var ErrInvalidPasswordOrLocalConfigTampered = errors.New("invalid repository password or local configuration has been tampered with")
+
+// ErrLocalConfigNotSigned is returned when the local configuration has no HMAC, such as configurations
+// written by previous versions.
+var ErrLocalConfigNotSigned = errors.New("local configuration is not protected from tampering, reconnect to the repository")
+
 // repositoryObjectFormat describes the format of objects in a repository.
 type repositoryObjectFormat struct {
 	content.FormattingOptions
@@ -48,6 +88,66 @@ func (lc *LocalConfig) Save(w io.Writer) error {
 	return err
 }
 
+// integrityData returns the contents of the configuration protected by its HMAC.
+func (lc *LocalConfig) integrityData() ([]byte, error) {
+	c := *lc
+	c.HMAC = nil
+
+	return json.Marshal(&c)
+}
+
+// hmacKeyedByPassword returns true if the HMAC of the configuration is keyed by the password, which is the case
+// when the key file or hardware key command from the configuration are used to unlock the master key.
+func (lc *LocalConfig) hmacKeyedByPassword() bool {
+	return lc.KeyFile != "" || lc.HardwareKeyCommand != ""
+}
+
+// localConfigHMACKey derives the key of the HMAC of the local configuration from the master key or password-derived key.
+func localConfigHMACKey(key, uniqueID []byte) []byte {
+	return deriveKeyFromMasterKey(key, uniqueID, purposeLocalConfig, localConfigHMACKeySize)
+}
+
+func localConfigHMAC(data, hmacKey []byte) []byte {
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write(data) // nolint:errcheck
+
+	return h.Sum(nil)
+}
+
+// verifyLocalConfig verifies the HMAC of the configuration with the provided integrity data.
+func verifyLocalConfig(data, mac, hmacKey []byte, keyedByPassword bool) error {
+	switch {
+	case mac == nil:
+		return ErrLocalConfigNotSigned
+
+	case hmac.Equal(mac, localConfigHMAC(data, hmacKey)):
+		return nil
+
+	case keyedByPassword:
+		return ErrInvalidPasswordOrLocalConfigTampered
+
+	default:
+		return ErrLocalConfigTampered
+	}
+}
+
+// writeSignedLocalConfig writes the configuration with the provided integrity data to the config file along with its HMAC.
+func writeSignedLocalConfig(configFile string, data, hmacKey []byte) error {
+	var lc LocalConfig
+	if err := json.Unmarshal(data, &lc); err != nil {
+		return errors.Wrap(err, "unable to parse local config")
+	}
+
+	lc.HMAC = localConfigHMAC(data, hmacKey)
+
+	d, err := json.MarshalIndent(&lc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(ioutil.WriteFile(configFile, d, 0600), "unable to write config file") //nolint:gomnd
+}
+
 // loadConfigFromFile reads the local configuration from the specified file.
 func loadConfigFromFile(fileName string) (*LocalConfig, error) {
 	f, err := os.Open(fileName) //nolint:gosec
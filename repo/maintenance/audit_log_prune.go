@@ -0,0 +1,28 @@
+package maintenance
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/audit"
+)
+
+// PruneAuditLog removes audit log records older than the retention configured for the repository.
+func PruneAuditLog(ctx context.Context, rep MaintainableRepository) error {
+	l, err := audit.NewLog(rep.BlobStorage(), rep.DeriveKey(audit.KeyDerivationPurpose, audit.KeySize), rep.Time)
+	if err != nil {
+		return errors.Wrap(err, "unable to open audit log")
+	}
+
+	n, err := l.Prune(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to prune audit log")
+	}
+
+	if n > 0 {
+		log(ctx).Infof("Pruned %v audit log records.", n)
+	}
+
+	return nil
+}
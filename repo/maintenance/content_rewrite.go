@@ -28,6 +28,20 @@ type RewriteContentsOptions struct {
 	ShortPacks     bool
 	FormatVersion  int
 	DryRun         bool
+
+	// OldKeyEpochs causes live contents encrypted with keys of previous key epochs to be rewritten,
+	// which re-encrypts them with the current key.
+	OldKeyEpochs bool
+
+	// LowUtilizationPacks causes live contents to be rewritten from packs where they occupy less than
+	// MaxUtilizationPercent of the pack blob, the remainder being garbage left behind by deletions.
+	LowUtilizationPacks   bool
+	MaxUtilizationPercent int
+
+	// MaxBytes and MaxBytesPerSecond limit the total number of bytes rewritten and the rate of rewrites,
+	// to limit the load on the storage backend.
+	MaxBytes          int64
+	MaxBytesPerSecond int64
 }
 
 const shortPackThresholdPercent = 60 // blocks below 60% of max block size are considered to be 'short
@@ -60,6 +74,10 @@ func RewriteContents(ctx context.Context, rep MaintainableRepository, opt *Rewri
 		mu          sync.Mutex
 		totalBytes  int64
 		failedCount int
+
+		// packs in archival cold storage tier (such as S3 Glacier) can't be read without rehydration,
+		// contents in them are left in place.
+		archivedPacks = map[blob.ID]bool{}
 	)
 
 	if opt.Parallel == 0 {
@@ -68,6 +86,8 @@ func RewriteContents(ctx context.Context, rep MaintainableRepository, opt *Rewri
 
 	var wg sync.WaitGroup
 
+	startTime := time.Now() // allow:no-inject-time
+
 	for i := 0; i < opt.Parallel; i++ {
 		wg.Add(1)
 
@@ -94,16 +114,52 @@ func RewriteContents(ctx context.Context, rep MaintainableRepository, opt *Rewri
 					continue
 				}
 
-				log(ctx).Debugf("Rewriting content %v (%v bytes) from pack %v%v %v", c.ID, c.Length, c.PackBlobID, optDeleted, age)
 				mu.Lock()
+				if archivedPacks[c.PackBlobID] {
+					mu.Unlock()
+					continue
+				}
+
+				if opt.MaxBytes > 0 && totalBytes+int64(c.Length) > opt.MaxBytes {
+					mu.Unlock()
+					log(ctx).Debugf("Not rewriting content %v (%v bytes) from pack %v%v, because the limit of rewritten bytes has been reached.", c.ID, c.Length, c.PackBlobID, optDeleted)
+
+					continue
+				}
+
 				totalBytes += int64(c.Length)
+				throttleDelay := rewriteThrottleDelay(startTime, totalBytes, opt.MaxBytesPerSecond)
 				mu.Unlock()
 
+				log(ctx).Debugf("Rewriting content %v (%v bytes) from pack %v%v %v", c.ID, c.Length, c.PackBlobID, optDeleted, age)
+
 				if opt.DryRun {
 					continue
 				}
 
-				if err := rep.ContentManager().RewriteContent(ctx, c.ID); err != nil {
+				if throttleDelay > 0 {
+					select {
+					case <-ctx.Done():
+						// keep draining the channel, so that the producer is not blocked.
+						continue
+					case <-time.After(throttleDelay):
+					}
+				}
+
+				err := rep.ContentManager().RewriteContent(ctx, c.ID)
+				if errors.Cause(err) == blob.ErrBlobArchived {
+					mu.Lock()
+					if !archivedPacks[c.PackBlobID] {
+						log(ctx).Infof("Not rewriting contents of pack %v, because it's archived.", c.PackBlobID)
+					}
+					archivedPacks[c.PackBlobID] = true
+					totalBytes -= int64(c.Length)
+					mu.Unlock()
+
+					continue
+				}
+
+				if err != nil {
 					log(ctx).Infof("unable to rewrite content %q: %v", c.ID, err)
 					mu.Lock()
 					failedCount++
@@ -117,6 +173,10 @@ func RewriteContents(ctx context.Context, rep MaintainableRepository, opt *Rewri
 
 	log(ctx).Debugf("Total bytes rewritten %v", units.BytesStringBase10(totalBytes))
 
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "rewrite canceled")
+	}
+
 	if failedCount == 0 {
 		return rep.ContentManager().Flush(ctx)
 	}
@@ -124,6 +184,36 @@ func RewriteContents(ctx context.Context, rep MaintainableRepository, opt *Rewri
 	return errors.Errorf("failed to rewrite %v contents", failedCount)
 }
 
+// DefragmentPacks rewrites live contents out of packs with low utilization according to the provided parameters.
+func DefragmentPacks(ctx context.Context, rep MaintainableRepository, dp DefragmentParams) error {
+	maxUtilization := dp.MaxUtilizationPercent
+	if maxUtilization <= 0 {
+		maxUtilization = defaultDefragmentMaxUtilizationPercent
+	}
+
+	return RewriteContents(ctx, rep, &RewriteContentsOptions{
+		LowUtilizationPacks:   true,
+		MaxUtilizationPercent: maxUtilization,
+		MaxBytes:              dp.MaxBytes,
+		MaxBytesPerSecond:     dp.MaxBytesPerSecond,
+	})
+}
+
+// rewriteThrottleDelay returns the delay needed to keep the rate of rewrites since startTime below maxBytesPerSecond.
+func rewriteThrottleDelay(startTime time.Time, totalBytes, maxBytesPerSecond int64) time.Duration {
+	if maxBytesPerSecond <= 0 {
+		return 0
+	}
+
+	expected := time.Duration(float64(totalBytes) / float64(maxBytesPerSecond) * float64(time.Second))
+
+	if d := expected - time.Since(startTime); d > 0 { // allow:no-inject-time
+		return d
+	}
+
+	return 0
+}
+
 func getContentToRewrite(ctx context.Context, rep MaintainableRepository, opt *RewriteContentsOptions) <-chan contentInfoOrError {
 	ch := make(chan contentInfoOrError)
 
@@ -139,10 +229,20 @@ func getContentToRewrite(ctx context.Context, rep MaintainableRepository, opt *R
 			findContentInShortPacks(ctx, rep, ch, threshold, opt)
 		}
 
+		// add all live contents from packs with low utilization
+		if opt.LowUtilizationPacks {
+			findContentInLowUtilizationPacks(ctx, rep, ch, opt)
+		}
+
 		// add all blocks with given format version
 		if opt.FormatVersion != 0 {
 			findContentWithFormatVersion(ctx, rep, ch, opt)
 		}
+
+		// add all live contents encrypted with keys of previous key epochs
+		if opt.OldKeyEpochs {
+			findContentWithOldKeyEpochs(ctx, rep, ch, opt)
+		}
 	}()
 
 	return ch
@@ -174,6 +274,24 @@ func findContentWithFormatVersion(ctx context.Context, rep MaintainableRepositor
 		})
 }
 
+func findContentWithOldKeyEpochs(ctx context.Context, rep MaintainableRepository, ch chan contentInfoOrError, opt *RewriteContentsOptions) {
+	currentEpoch := rep.ContentManager().Format.CurrentKeyEpoch()
+
+	if err := rep.ContentManager().IterateContents(
+		ctx,
+		content.IterateOptions{
+			Range: opt.ContentIDRange,
+		},
+		func(b content.Info) error {
+			if b.KeyEpoch() < currentEpoch && strings.HasPrefix(string(b.PackBlobID), string(opt.PackPrefix)) {
+				ch <- contentInfoOrError{Info: b}
+			}
+			return nil
+		}); err != nil {
+		ch <- contentInfoOrError{err: err}
+	}
+}
+
 func findContentInShortPacks(ctx context.Context, rep MaintainableRepository, ch chan contentInfoOrError, threshold int64, opt *RewriteContentsOptions) {
 	var prefixes []blob.ID
 
@@ -206,3 +324,54 @@ func findContentInShortPacks(ctx context.Context, rep MaintainableRepository, ch
 		return
 	}
 }
+
+func findContentInLowUtilizationPacks(ctx context.Context, rep MaintainableRepository, ch chan contentInfoOrError, opt *RewriteContentsOptions) {
+	prefixes := content.PackBlobIDPrefixes
+	if opt.PackPrefix != "" {
+		prefixes = []blob.ID{opt.PackPrefix}
+	}
+
+	packSizes := map[blob.ID]int64{}
+
+	if err := blob.IterateAllBlobsInParallel(ctx, opt.Parallel, rep.BlobStorage(), prefixes, content.BlobIDAlphabet, func(bm blob.Metadata) error {
+		packSizes[bm.BlobID] = bm.Length
+		return nil
+	}); err != nil {
+		ch <- contentInfoOrError{err: errors.Wrap(err, "unable to list pack blobs")}
+		return
+	}
+
+	var lowUtilizationPacks int
+
+	// only live contents are included, packs where all contents are deleted are removed by blob GC.
+	err := rep.ContentManager().IteratePacks(
+		ctx,
+		content.IteratePackOptions{
+			Prefixes:            prefixes,
+			IncludeContentInfos: true,
+		},
+		func(pi content.PackInfo) error {
+			packSize := packSizes[pi.PackID]
+			if packSize == 0 || pi.TotalSize*100 >= packSize*int64(opt.MaxUtilizationPercent) { //nolint:gomnd
+				return nil
+			}
+
+			log(ctx).Debugf("Pack %v has low utilization: %v live bytes out of %v", pi.PackID, pi.TotalSize, packSize)
+
+			lowUtilizationPacks++
+
+			for _, ci := range pi.ContentInfos {
+				ch <- contentInfoOrError{Info: ci}
+			}
+
+			return nil
+		},
+	)
+
+	if err != nil {
+		ch <- contentInfoOrError{err: err}
+		return
+	}
+
+	log(ctx).Infof("Found %v packs with utilization below %v%%.", lowUtilizationPacks, opt.MaxUtilizationPercent)
+}
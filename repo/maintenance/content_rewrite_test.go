@@ -0,0 +1,333 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestDefragmentPacks(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	ft := faketime.NewTimeAdvance(time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC))
+
+	env.MustReopen(t, func(o *repo.Options) {
+		o.TimeNowFunc = ft.NowFunc()
+	})
+
+	cm := env.Repository.Content
+
+	writeContents := func(n int) []content.ID {
+		var ids []content.ID
+
+		for i := 0; i < n; i++ {
+			data := make([]byte, 10000)
+			rand.Read(data) //nolint:errcheck
+
+			cid, err := cm.WriteContent(ctx, data, "")
+			if err != nil {
+				t.Fatalf("unable to write content: %v", err)
+			}
+
+			ids = append(ids, cid)
+		}
+
+		if err := cm.Flush(ctx); err != nil {
+			t.Fatalf("flush error: %v", err)
+		}
+
+		return ids
+	}
+
+	// one pack which will be mostly garbage and one which is fully utilized.
+	sparse := writeContents(10)
+	full := writeContents(10)
+
+	ft.Advance(time.Minute)
+
+	for _, cid := range sparse[2:] {
+		if err := cm.DeleteContent(ctx, cid); err != nil {
+			t.Fatalf("unable to delete content: %v", err)
+		}
+	}
+
+	if err := cm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	packsOf := func(ids []content.ID) map[content.ID]blob.ID {
+		result := map[content.ID]blob.ID{}
+
+		for _, cid := range ids {
+			ci, err := cm.ContentInfo(ctx, cid)
+			if err != nil {
+				t.Fatalf("unable to get content info: %v", err)
+			}
+
+			result[cid] = ci.PackBlobID
+		}
+
+		return result
+	}
+
+	// contents are only rewritten when old enough
+	ft.Advance(3 * time.Hour)
+
+	sparsePacks := packsOf(sparse[0:2])
+	fullPacks := packsOf(full)
+
+	// limit of rewritten bytes is below the size of single content, nothing gets rewritten.
+	if err := DefragmentPacks(ctx, env.Repository, DefragmentParams{MaxBytes: 5000}); err != nil {
+		t.Fatalf("defragment error: %v", err)
+	}
+
+	if err := cm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	for cid, packID := range packsOf(sparse[0:2]) {
+		if packID != sparsePacks[cid] {
+			t.Errorf("content %v was unexpectedly rewritten", cid)
+		}
+	}
+
+	// throttled defragmentation stops waiting when canceled.
+	cancelCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	if err := DefragmentPacks(cancelCtx, env.Repository, DefragmentParams{MaxBytesPerSecond: 1}); err == nil {
+		t.Fatalf("expected error from canceled defragmentation")
+	}
+
+	for cid, packID := range packsOf(sparse[0:2]) {
+		if packID != sparsePacks[cid] {
+			t.Errorf("content %v was unexpectedly rewritten", cid)
+		}
+	}
+
+	if err := DefragmentPacks(ctx, env.Repository, DefragmentParams{}); err != nil {
+		t.Fatalf("defragment error: %v", err)
+	}
+
+	if err := cm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	for cid, packID := range packsOf(sparse[0:2]) {
+		if packID == sparsePacks[cid] {
+			t.Errorf("content %v from low-utilization pack was not rewritten", cid)
+		}
+	}
+
+	for cid, packID := range packsOf(full) {
+		if packID != fullPacks[cid] {
+			t.Errorf("content %v from fully-utilized pack was unexpectedly rewritten", cid)
+		}
+	}
+}
+
+func TestRewriteOldKeyEpochs(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	ft := faketime.NewTimeAdvance(time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC))
+	openOpts := func(o *repo.Options) {
+		o.TimeNowFunc = ft.NowFunc()
+	}
+
+	env.MustReopen(t, openOpts)
+
+	oldData := make([]byte, 10000)
+	rand.Read(oldData) //nolint:errcheck
+
+	oldID, err := env.Repository.Content.WriteContent(ctx, oldData, "")
+	if err != nil {
+		t.Fatalf("unable to write content: %v", err)
+	}
+
+	if err = env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	epoch, err := env.Repository.RotateEncryptionKey(ctx, "CHACHA20-POLY1305-HMAC-SHA256")
+	if err != nil {
+		t.Fatalf("unable to rotate key: %v", err)
+	}
+
+	if epoch != 1 {
+		t.Errorf("unexpected key epoch: %v", epoch)
+	}
+
+	env.MustReopen(t, openOpts)
+
+	cm := env.Repository.Content
+
+	if got, want := cm.Format.CurrentEncryption(), "CHACHA20-POLY1305-HMAC-SHA256"; got != want {
+		t.Errorf("unexpected encryption: %v, want %v", got, want)
+	}
+
+	newID, err := cm.WriteContent(ctx, []byte("new content"), "")
+	if err != nil {
+		t.Fatalf("unable to write content: %v", err)
+	}
+
+	if err = cm.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	keyEpochOf := func(cid content.ID) int {
+		ci, err := cm.ContentInfo(ctx, cid)
+		if err != nil {
+			t.Fatalf("unable to get content info: %v", err)
+		}
+
+		return ci.KeyEpoch()
+	}
+
+	if keyEpochOf(oldID) != 0 || keyEpochOf(newID) != 1 {
+		t.Fatalf("unexpected key epochs: %v %v", keyEpochOf(oldID), keyEpochOf(newID))
+	}
+
+	// contents are only rewritten when old enough
+	ft.Advance(3 * time.Hour)
+
+	if err = RewriteContents(ctx, env.Repository, &RewriteContentsOptions{
+		ContentIDRange: content.AllIDs,
+		OldKeyEpochs:   true,
+	}); err != nil {
+		t.Fatalf("rewrite error: %v", err)
+	}
+
+	if got := keyEpochOf(oldID); got != 1 {
+		t.Errorf("content was not re-encrypted with the current key, key epoch %v", got)
+	}
+
+	env.MustReopen(t, openOpts)
+
+	if got, err := env.Repository.Content.GetContent(ctx, oldID); err != nil || !bytes.Equal(got, oldData) {
+		t.Errorf("unable to read re-encrypted content: %v", err)
+	}
+}
+
+func TestRewriteThrottleDelay(t *testing.T) {
+	if got := rewriteThrottleDelay(time.Now(), 1e6, 0); got != 0 {
+		t.Errorf("unexpected delay without limit: %v", got)
+	}
+
+	if got := rewriteThrottleDelay(time.Now().Add(-time.Hour), 1e6, 1000); got != 0 {
+		t.Errorf("unexpected delay when below the limit: %v", got)
+	}
+
+	if got := rewriteThrottleDelay(time.Now(), 10000, 1000); got < 9*time.Second || got > 10*time.Second {
+		t.Errorf("unexpected delay when above the limit: %v", got)
+	}
+}
+
+// archivedRepository is a repository whose content manager reads pack blobs from the provided storage.
+type archivedRepository struct {
+	*repo.DirectRepository
+	cm *content.Manager
+}
+
+func (r archivedRepository) ContentManager() *content.Manager {
+	return r.cm
+}
+
+func TestRewriteSkipsArchivedPacks(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	ft := faketime.NewTimeAdvance(time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC))
+
+	env.MustReopen(t, func(o *repo.Options) {
+		o.TimeNowFunc = ft.NowFunc()
+	})
+
+	var ids []content.ID
+
+	for i := 0; i < 10; i++ {
+		data := make([]byte, 10000)
+		rand.Read(data) //nolint:errcheck
+
+		cid, err := env.Repository.Content.WriteContent(ctx, data, "")
+		if err != nil {
+			t.Fatalf("unable to write content: %v", err)
+		}
+
+		ids = append(ids, cid)
+	}
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	ft.Advance(time.Minute)
+
+	for _, cid := range ids[2:] {
+		if err := env.Repository.Content.DeleteContent(ctx, cid); err != nil {
+			t.Fatalf("unable to delete content: %v", err)
+		}
+	}
+
+	if err := env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	// contents are only rewritten when old enough
+	ft.Advance(3 * time.Hour)
+
+	fs := &blobtesting.FaultyStorage{Base: env.Repository.Blobs}
+
+	cm, err := content.NewManager(ctx, fs, &env.Repository.Content.Format, content.CachingOptions{}, content.ManagerOptions{TimeNow: ft.NowFunc()})
+	if err != nil {
+		t.Fatalf("unable to open content manager: %v", err)
+	}
+
+	defer cm.Close(ctx)
+
+	ci, err := cm.ContentInfo(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("unable to get content info: %v", err)
+	}
+
+	// all reads of pack blobs fail as they are in archival storage tier.
+	fs.Faults = map[string][]*blobtesting.Fault{
+		"GetBlob": {{Repeat: 1000, Err: blob.ErrBlobArchived}},
+	}
+
+	rep := archivedRepository{env.Repository, cm}
+
+	if err := RewriteContents(ctx, rep, &RewriteContentsOptions{ContentIDRange: content.AllIDs, ShortPacks: true}); err != nil {
+		t.Fatalf("rewrite error: %v", err)
+	}
+
+	if err := DefragmentPacks(ctx, rep, DefragmentParams{}); err != nil {
+		t.Fatalf("defragment error: %v", err)
+	}
+
+	ci2, err := cm.ContentInfo(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("unable to get content info: %v", err)
+	}
+
+	if ci2.PackBlobID != ci.PackBlobID {
+		t.Errorf("content from archived pack was unexpectedly rewritten to %v", ci2.PackBlobID)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/kopia/kopia/repo/manifest"
 )
 
+// defaultDefragmentMaxUtilizationPercent is the default utilization below which packs are defragmented.
+const defaultDefragmentMaxUtilizationPercent = 50
+
 var manifestLabels = map[string]string{
 	"type": "maintenance",
 }
@@ -21,6 +24,8 @@ type Params struct {
 	FullCycle  CycleParams `json:"full"`
 
 	SnapshotGC SnapshotGCParams `json:"snapshotGC"`
+
+	Defragment DefragmentParams `json:"defragment"`
 }
 
 // SnapshotGCParams contains parameters for Snapshot Garbage Collection
@@ -30,6 +35,15 @@ type SnapshotGCParams struct {
 	MinContentAge time.Duration `json:"minAge"`
 }
 
+// DefragmentParams contains parameters for rewriting live contents out of packs with low utilization
+// during full maintenance.
+type DefragmentParams struct {
+	Enabled               bool  `json:"enabled"`
+	MaxUtilizationPercent int   `json:"maxUtilizationPercent,omitempty"`
+	MaxBytes              int64 `json:"maxBytes,omitempty"`
+	MaxBytesPerSecond     int64 `json:"maxBytesPerSecond,omitempty"`
+}
+
 // DefaultParams represents default values of maintenance parameters.
 func DefaultParams() Params {
 	return Params{
@@ -46,6 +60,11 @@ func DefaultParams() Params {
 		SnapshotGC: SnapshotGCParams{
 			MinContentAge: 24 * time.Hour, //nolint:gomnd
 		},
+		Defragment: DefragmentParams{
+			// disabled by default, since rewriting packs increases load on the storage and, for storage
+			// that charges for early deletion, the cost of full maintenance.
+			MaxUtilizationPercent: defaultDefragmentMaxUtilizationPercent,
+		},
 	}
 }
 
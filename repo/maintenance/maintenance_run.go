@@ -265,6 +265,35 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters) error {
 		return errors.Wrap(err, "error rewriting contents in short packs")
 	}
 
+	// rewrite live contents out of packs that are mostly garbage after deletions,
+	// orphaning old packs in the process.
+	if dp := runParams.Params.Defragment; dp.Enabled {
+		if err := ReportRun(ctx, runParams.rep, "full-defragment-packs", func() error {
+			return DefragmentPacks(ctx, runParams.rep, dp)
+		}); err != nil {
+			return errors.Wrap(err, "error defragmenting packs")
+		}
+	}
+
+	// re-encrypt live contents with the current key after key rotation, orphaning old packs in the process.
+	if runParams.rep.ContentManager().Format.CurrentKeyEpoch() > 0 {
+		if err := ReportRun(ctx, runParams.rep, "full-rewrap-contents", func() error {
+			return RewriteContents(ctx, runParams.rep, &RewriteContentsOptions{
+				ContentIDRange: content.AllIDs,
+				OldKeyEpochs:   true,
+			})
+		}); err != nil {
+			return errors.Wrap(err, "error re-encrypting contents with old keys")
+		}
+	}
+
+	// remove audit log records older than the retention.
+	if err := ReportRun(ctx, runParams.rep, "full-prune-audit-log", func() error {
+		return PruneAuditLog(ctx, runParams.rep)
+	}); err != nil {
+		return errors.Wrap(err, "error pruning audit log")
+	}
+
 	// delete orphaned packs after some time.
 	if err := ReportRun(ctx, runParams.rep, "full-delete-blobs", func() error {
 		_, err := DeleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{})
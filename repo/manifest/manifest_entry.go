@@ -9,4 +9,21 @@ type EntryMetadata struct {
 	Length  int               `json:"length"`
 	Labels  map[string]string `json:"labels"`
 	ModTime time.Time         `json:"mtime"`
+
+	// Created is the time the contents were put, which for previous versions precedes ModTime of their deletion.
+	Created time.Time `json:"created"`
+}
+
+// Operations reported in Change.
+const (
+	OperationPut    = "put"
+	OperationDelete = "delete"
+)
+
+// Change describes a single Put or Delete of a manifest item.
+type Change struct {
+	Operation string            `json:"op"`
+	ID        ID                `json:"id"`
+	Labels    map[string]string `json:"labels"`
+	Time      time.Time         `json:"time"`
 }
@@ -0,0 +1,99 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Item is a manifest item along with its JSON contents, used to move items between repositories.
+type Item struct {
+	EntryMetadata
+	Content json.RawMessage `json:"data"`
+}
+
+// Export returns all current manifest items with their contents sorted by modification time.
+func (m *Manager) Export(ctx context.Context) ([]*Item, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var items []*Item
+
+	m.forEachEntryLocked(func(e *manifestEntry) {
+		if !e.Deleted {
+			items = append(items, &Item{*cloneEntryMetadata(e), e.Content})
+		}
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ModTime.Before(items[j].ModTime)
+	})
+
+	return items, nil
+}
+
+// Import adds the provided items preserving their IDs and modification times and returns the number
+// of imported items. Items whose IDs already exist are not imported again, neither are items of unversioned
+// types deleted in this repository, since data they reference may have been garbage-collected since.
+func (m *Manager) Import(ctx context.Context, items []*Item) (int, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	imported := 0
+
+	for _, it := range items {
+		if it.ID == "" || it.Labels[TypeLabelKey] == "" {
+			return imported, errors.Errorf("invalid item %q, ID and 'type' label are required", it.ID)
+		}
+
+		existing := m.pendingEntries[it.ID]
+		if existing == nil {
+			existing = m.committedEntries[it.ID]
+		}
+
+		if existing != nil && !existing.Deleted {
+			continue
+		}
+
+		if existing != nil && m.unversionedTypes[it.Labels[TypeLabelKey]] {
+			log(ctx).Warningf("not importing %v %v, which has been deleted in this repository", it.Labels[TypeLabelKey], it.ID)
+			continue
+		}
+
+		created := it.Created
+		if created.IsZero() {
+			created = it.ModTime
+		}
+
+		e := &manifestEntry{
+			ID:      it.ID,
+			ModTime: it.ModTime.UTC(),
+			Created: created.UTC(),
+			Labels:  copyLabels(it.Labels),
+			Content: it.Content,
+		}
+
+		// items deleted in this repository are restored, which requires modification time
+		// newer than the deletion marker.
+		if existing != nil && !e.ModTime.After(existing.ModTime) {
+			e.ModTime = m.timeNow().UTC()
+		}
+
+		m.pendingEntries[e.ID] = e
+		m.addChangeLocked(OperationPut, e)
+
+		imported++
+	}
+
+	return imported, nil
+}
@@ -9,6 +9,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,10 +27,16 @@ var log = logging.GetContextLoggerFunc("kopia/manifest")
 // ErrNotFound is returned when the metadata item is not found.
 var ErrNotFound = errors.New("not found")
 
+// ErrConflict is returned by PutIfMatch when the current manifest items differ from the expected ones.
+var ErrConflict = errors.New("manifest was modified concurrently")
+
 // ContentPrefix is the prefix of the content id for manifests
 const ContentPrefix = "m"
 const autoCompactionContentCount = 16
 
+// maxPreviousVersions is the maximum number of previous versions retained for each set of labels.
+const maxPreviousVersions = 10
+
 // TypeLabelKey is the label key for manifest type
 const TypeLabelKey = "type"
 
@@ -40,6 +48,7 @@ type contentManager interface {
 	DisableIndexFlush(ctx context.Context)
 	EnableIndexFlush(ctx context.Context)
 	Flush(ctx context.Context) error
+	Refresh(ctx context.Context) (bool, error)
 }
 
 // ID is a unique identifier of a single manifest.
@@ -52,11 +61,18 @@ type Manager struct {
 
 	initialized    bool
 	pendingEntries map[ID]*manifestEntry
+	pendingChanges []Change
 
 	committedEntries    map[ID]*manifestEntry
 	committedContentIDs map[content.ID]bool
 
-	timeNow func() time.Time // Time provider
+	// loadedContents caches parsed manifest contents, since content IDs are derived from their data
+	// the same content never needs to be downloaded and decrypted again when refreshing.
+	loadedContents map[content.ID]manifest
+
+	timeNow          func() time.Time // Time provider
+	recordChanges    func(ctx context.Context, changes []Change) error
+	unversionedTypes map[string]bool
 }
 
 // Put serializes the provided payload to JSON and persists it. Returns unique identifier that represents the manifest.
@@ -72,6 +88,112 @@ func (m *Manager) Put(ctx context.Context, labels map[string]string, payload int
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.putLocked(labels, payload)
+}
+
+// PutIfMatch replaces manifest items matching the provided labels with a new one, but only if the items
+// currently found are exactly the expected ones, such as IDs returned by Find() before the payload was prepared.
+// Empty list of expected items means no item must be present, otherwise ErrConflict is returned.
+//
+// The new item is flushed immediately and manifests are reloaded again. If another client has concurrently
+// put an item matching the labels, the new item is deleted and ErrConflict is returned. Since the client which
+// flushes later always observes the item flushed earlier, at most one of the racing clients succeeds, while both
+// of them can fail and retry.
+func (m *Manager) PutIfMatch(ctx context.Context, labels map[string]string, payload interface{}, expected []ID) (ID, error) {
+	if labels[TypeLabelKey] == "" {
+		return "", errors.Errorf("'type' label is required")
+	}
+
+	if err := m.ensureInitialized(ctx); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refreshLocked(ctx); err != nil {
+		return "", err
+	}
+
+	current := m.matchingIDsLocked(labels)
+
+	for _, id := range expected {
+		if !current[id] {
+			return "", errors.Wrapf(ErrConflict, "expected manifest %v was deleted or replaced", id)
+		}
+
+		delete(current, id)
+	}
+
+	for id := range current {
+		return "", errors.Wrapf(ErrConflict, "unexpected manifest %v was added", id)
+	}
+
+	newID, err := m.putLocked(labels, payload)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range expected {
+		m.deleteLocked(id)
+	}
+
+	if err := m.flushLocked(ctx); err != nil {
+		return "", err
+	}
+
+	if err := m.refreshLocked(ctx); err != nil {
+		return "", err
+	}
+
+	competing := m.matchingIDsLocked(labels)
+	delete(competing, newID)
+
+	for id := range competing {
+		m.deleteLocked(newID)
+
+		if err := m.flushLocked(ctx); err != nil {
+			return "", errors.Wrapf(err, "unable to delete manifest %v conflicting with %v", newID, id)
+		}
+
+		return "", errors.Wrapf(ErrConflict, "manifest %v was concurrently added", id)
+	}
+
+	return newID, nil
+}
+
+// refreshLocked reloads manifests flushed by other clients.
+func (m *Manager) refreshLocked(ctx context.Context) error {
+	if _, err := m.b.Refresh(ctx); err != nil {
+		return errors.Wrap(err, "unable to refresh contents")
+	}
+
+	return errors.Wrap(m.loadCommittedContentsLocked(ctx), "unable to reload manifests")
+}
+
+// flushLocked writes pending manifests and the index of their contents to the storage.
+func (m *Manager) flushLocked(ctx context.Context) error {
+	if _, err := m.flushPendingEntriesLocked(ctx); err != nil {
+		return err
+	}
+
+	return errors.Wrap(m.b.Flush(ctx), "unable to flush contents")
+}
+
+// matchingIDsLocked returns IDs of current manifests matching the provided labels.
+func (m *Manager) matchingIDsLocked(labels map[string]string) map[ID]bool {
+	result := map[ID]bool{}
+
+	m.forEachEntryLocked(func(e *manifestEntry) {
+		if !e.Deleted && matchesLabels(e.Labels, labels) {
+			result[e.ID] = true
+		}
+	})
+
+	return result
+}
+
+func (m *Manager) putLocked(labels map[string]string, payload interface{}) (ID, error) {
 	random := make([]byte, 16)
 	if _, err := rand.Read(random); err != nil {
 		return "", errors.Wrap(err, "can't initialize randomness")
@@ -82,14 +204,18 @@ func (m *Manager) Put(ctx context.Context, labels map[string]string, payload int
 		return "", errors.Wrap(err, "marshal error")
 	}
 
+	now := m.timeNow().UTC()
+
 	e := &manifestEntry{
 		ID:      ID(hex.EncodeToString(random)),
-		ModTime: m.timeNow().UTC(),
+		ModTime: now,
+		Created: now,
 		Labels:  copyLabels(labels),
 		Content: b,
 	}
 
 	m.pendingEntries[e.ID] = e
+	m.addChangeLocked(OperationPut, e)
 
 	return e.ID, nil
 }
@@ -112,12 +238,7 @@ func (m *Manager) GetMetadata(ctx context.Context, id ID) (*EntryMetadata, error
 		return nil, ErrNotFound
 	}
 
-	return &EntryMetadata{
-		ID:      id,
-		ModTime: e.ModTime,
-		Length:  len(e.Content),
-		Labels:  copyLabels(e.Labels),
-	}, nil
+	return cloneEntryMetadata(e), nil
 }
 
 // Get retrieves the contents of the provided manifest item by deserializing it as JSON to provided object.
@@ -159,10 +280,73 @@ func (m *Manager) Find(ctx context.Context, labels map[string]string) ([]*EntryM
 
 	var matches []*EntryMetadata
 
-	for _, e := range m.pendingEntries {
-		if matchesLabels(e.Labels, labels) {
+	m.forEachEntryLocked(func(e *manifestEntry) {
+		if !e.Deleted && matchesLabels(e.Labels, labels) {
 			matches = append(matches, cloneEntryMetadata(e))
 		}
+	})
+
+	sortByModTime(matches)
+
+	return matches, nil
+}
+
+// ListVersions returns the list of EntryMetadata for previous versions of manifest entries matching all provided labels,
+// which were deleted or replaced. ModTime of a previous version is the time it was deleted.
+// Up to 10 most recent previous versions are retained for each set of labels.
+func (m *Manager) ListVersions(ctx context.Context, labels map[string]string) ([]*EntryMetadata, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []*EntryMetadata
+
+	m.forEachEntryLocked(func(e *manifestEntry) {
+		if m.isPreviousVersion(e) && matchesLabels(e.Labels, labels) {
+			matches = append(matches, cloneEntryMetadata(e))
+		}
+	})
+
+	sortByModTime(matches)
+
+	return matches, nil
+}
+
+// GetVersion retrieves the contents of the previous version of the deleted manifest item by deserializing it
+// as JSON to provided object. If the previous version is not found, returns ErrNotFound.
+func (m *Manager) GetVersion(ctx context.Context, id ID, data interface{}) (*EntryMetadata, error) {
+	if err := m.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.pendingEntries[id]
+	if e == nil {
+		e = m.committedEntries[id]
+	}
+
+	if e == nil || !m.isPreviousVersion(e) {
+		return nil, ErrNotFound
+	}
+
+	if data != nil {
+		if err := json.Unmarshal([]byte(e.Content), data); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmashal %q", id)
+		}
+	}
+
+	return cloneEntryMetadata(e), nil
+}
+
+// forEachEntryLocked invokes the callback for each pending entry and committed entry that's not pending.
+func (m *Manager) forEachEntryLocked(cb func(e *manifestEntry)) {
+	for _, e := range m.pendingEntries {
+		cb(e)
 	}
 
 	for _, e := range m.committedEntries {
@@ -171,16 +355,19 @@ func (m *Manager) Find(ctx context.Context, labels map[string]string) ([]*EntryM
 			continue
 		}
 
-		if matchesLabels(e.Labels, labels) {
-			matches = append(matches, cloneEntryMetadata(e))
-		}
+		cb(e)
 	}
+}
 
+func sortByModTime(matches []*EntryMetadata) {
 	sort.Slice(matches, func(i, j int) bool {
 		return matches[i].ModTime.Before(matches[j].ModTime)
 	})
+}
 
-	return matches, nil
+// isPreviousVersion returns true if the entry is a deletion marker that retains the deleted contents.
+func (m *Manager) isPreviousVersion(e *manifestEntry) bool {
+	return e.Deleted && e.Content != nil && !m.unversionedTypes[e.Labels[TypeLabelKey]]
 }
 
 func cloneEntryMetadata(e *manifestEntry) *EntryMetadata {
@@ -189,6 +376,7 @@ func cloneEntryMetadata(e *manifestEntry) *EntryMetadata {
 		Labels:  copyLabels(e.Labels),
 		Length:  len(e.Content),
 		ModTime: e.ModTime,
+		Created: e.createdTime(),
 	}
 }
 
@@ -218,6 +406,15 @@ func (m *Manager) flushPendingEntriesLocked(ctx context.Context) (content.ID, er
 		return "", nil
 	}
 
+	// changes are recorded before they are written, so that no change is ever missing from the record.
+	if len(m.pendingChanges) > 0 {
+		if err := m.recordChanges(ctx, m.pendingChanges); err != nil {
+			return "", errors.Wrap(err, "unable to record manifest changes")
+		}
+
+		m.pendingChanges = nil
+	}
+
 	man := manifest{}
 
 	for _, e := range m.pendingEntries {
@@ -241,6 +438,7 @@ func (m *Manager) flushPendingEntriesLocked(ctx context.Context) (content.ID, er
 	}
 
 	m.committedContentIDs[contentID] = true
+	m.loadedContents[contentID] = man
 
 	return contentID, nil
 }
@@ -260,17 +458,50 @@ func (m *Manager) Delete(ctx context.Context, id ID) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.pendingEntries[id] == nil && m.committedEntries[id] == nil {
-		return nil
+	m.deleteLocked(id)
+
+	return nil
+}
+
+func (m *Manager) deleteLocked(id ID) {
+	prev := m.pendingEntries[id]
+	if prev == nil {
+		prev = m.committedEntries[id]
+	}
+
+	if prev == nil || prev.Deleted {
+		return
 	}
 
-	m.pendingEntries[id] = &manifestEntry{
+	// deletion marker retains labels and contents as the previous version.
+	e := &manifestEntry{
 		ID:      id,
 		ModTime: m.timeNow().UTC(),
+		Created: prev.createdTime(),
 		Deleted: true,
+		Labels:  prev.Labels,
+		Content: prev.Content,
 	}
 
-	return nil
+	if m.unversionedTypes[prev.Labels[TypeLabelKey]] {
+		e.Content = nil
+	}
+
+	m.pendingEntries[id] = e
+	m.addChangeLocked(OperationDelete, e)
+}
+
+func (m *Manager) addChangeLocked(op string, e *manifestEntry) {
+	if m.recordChanges == nil {
+		return
+	}
+
+	m.pendingChanges = append(m.pendingChanges, Change{
+		Operation: op,
+		ID:        e.ID,
+		Labels:    copyLabels(e.Labels),
+		Time:      e.ModTime,
+	})
 }
 
 // Refresh updates the committed contents from the underlying storage.
@@ -296,10 +527,17 @@ func (m *Manager) loadCommittedContentsLocked(ctx context.Context) error {
 			Range:    content.PrefixRange(ContentPrefix),
 			Parallel: manifestLoadParallelism,
 		}, func(ci content.Info) error {
-			man, err := m.loadManifestContent(ctx, ci.ID)
-			if err != nil {
-				return err
+			// loadedContents is not modified while iterating.
+			man, ok := m.loadedContents[ci.ID]
+			if !ok {
+				loaded, err := m.loadManifestContent(ctx, ci.ID)
+				if err != nil {
+					return err
+				}
+
+				man = loaded
 			}
+
 			mu.Lock()
 			manifests[ci.ID] = man
 			mu.Unlock()
@@ -320,6 +558,9 @@ func (m *Manager) loadCommittedContentsLocked(ctx context.Context) error {
 
 	m.loadManifestContentsLocked(manifests)
 
+	// only retain contents that still exist.
+	m.loadedContents = manifests
+
 	if err := m.maybeCompactLocked(ctx); err != nil {
 		return errors.Errorf("error auto-compacting contents")
 	}
@@ -341,14 +582,52 @@ func (m *Manager) loadManifestContentsLocked(manifests map[content.ID]manifest)
 		}
 	}
 
-	// after merging, remove contents marked as deleted.
+	// after merging, remove contents marked as deleted, except for recent previous versions.
+	versions := map[string][]*manifestEntry{}
+
 	for k, e := range m.committedEntries {
-		if e.Deleted {
-			delete(m.committedEntries, k)
+		if !e.Deleted {
+			continue
+		}
+
+		if m.isPreviousVersion(e) {
+			key := labelsKey(e.Labels)
+			versions[key] = append(versions[key], e)
+
+			continue
+		}
+
+		delete(m.committedEntries, k)
+	}
+
+	for _, v := range versions {
+		if len(v) <= maxPreviousVersions {
+			continue
+		}
+
+		sort.Slice(v, func(i, j int) bool {
+			return v[i].ModTime.After(v[j].ModTime)
+		})
+
+		for _, e := range v[maxPreviousVersions:] {
+			delete(m.committedEntries, e.ID)
 		}
 	}
 }
 
+// labelsKey returns a string uniquely identifying the set of labels.
+func labelsKey(labels map[string]string) string {
+	var parts []string
+
+	for k, v := range labels {
+		parts = append(parts, strconv.Quote(k)+":"+strconv.Quote(v))
+	}
+
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}
+
 func (m *Manager) loadManifestContent(ctx context.Context, contentID content.ID) (manifest, error) {
 	man := manifest{}
 
@@ -476,6 +755,13 @@ func copyLabels(m map[string]string) map[string]string {
 // ManagerOptions are optional parameters for Manager creation
 type ManagerOptions struct {
 	TimeNow func() time.Time // Time provider
+
+	// RecordChanges, if set, is invoked with all Put and Delete operations before they are flushed.
+	RecordChanges func(ctx context.Context, changes []Change) error
+
+	// UnversionedTypes are types of manifests whose previous versions are not retained, because they
+	// reference data that's garbage-collected once they are deleted.
+	UnversionedTypes []string
 }
 
 // NewManager returns new manifest manager for the provided content manager.
@@ -490,7 +776,14 @@ func NewManager(ctx context.Context, b contentManager, options ManagerOptions) (
 		pendingEntries:      map[ID]*manifestEntry{},
 		committedEntries:    map[ID]*manifestEntry{},
 		committedContentIDs: map[content.ID]bool{},
+		loadedContents:      map[content.ID]manifest{},
 		timeNow:             timeNow,
+		recordChanges:       options.RecordChanges,
+		unversionedTypes:    map[string]bool{},
+	}
+
+	for _, t := range options.UnversionedTypes {
+		m.unversionedTypes[t] = true
 	}
 
 	return m, nil
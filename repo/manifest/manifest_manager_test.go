@@ -6,10 +6,14 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/encryption"
@@ -298,6 +302,15 @@ func sortIDs(s []ID) {
 }
 
 func newManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.DataMap) *Manager {
+	mm, err := NewManager(ctx, newContentManagerForTesting(ctx, t, data), ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	return mm
+}
+
+func newContentManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.DataMap) *content.Manager {
 	st := blobtesting.NewMapStorage(data, nil, nil)
 
 	bm, err := content.NewManager(ctx, st, &content.FormattingOptions{
@@ -310,12 +323,7 @@ func newManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.Da
 		t.Fatalf("can't create content manager: %v", err)
 	}
 
-	mm, err := NewManager(ctx, bm, ManagerOptions{})
-	if err != nil {
-		t.Fatalf("can't create manifest manager: %v", err)
-	}
-
-	return mm
+	return bm
 }
 
 func TestManifestInvalidPut(t *testing.T) {
@@ -353,3 +361,544 @@ func TestManifestAutoCompaction(t *testing.T) {
 		mgr.Flush(ctx)
 	}
 }
+
+// getCountingContentManager counts contents read from the underlying content manager.
+type getCountingContentManager struct {
+	contentManager
+	gets int32
+}
+
+func (c *getCountingContentManager) GetContent(ctx context.Context, contentID content.ID) ([]byte, error) {
+	atomic.AddInt32(&c.gets, 1)
+	return c.contentManager.GetContent(ctx, contentID)
+}
+
+func TestManifestRefreshReusesLoadedContents(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	writer := newManagerForTesting(ctx, t, data)
+
+	for i := 0; i < 3; i++ {
+		addAndVerify(ctx, t, writer, map[string]string{"type": "item", "color": "red"}, map[string]int{"foo": i})
+		mustFlush(ctx, t, writer)
+	}
+
+	bm := newContentManagerForTesting(ctx, t, data)
+	counting := &getCountingContentManager{contentManager: bm}
+
+	reader, err := NewManager(ctx, counting, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	verifyContentsLoaded := func(want int32) {
+		t.Helper()
+
+		if got := atomic.SwapInt32(&counting.gets, 0); got != want {
+			t.Errorf("unexpected number of loaded contents: %v, want %v", got, want)
+		}
+	}
+
+	ids1, err := reader.Find(ctx, map[string]string{"type": "item"})
+	if err != nil {
+		t.Fatalf("find error: %v", err)
+	}
+
+	verifyContentsLoaded(3)
+
+	if err = reader.Refresh(ctx); err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+
+	verifyContentsLoaded(0)
+
+	addAndVerify(ctx, t, writer, map[string]string{"type": "item", "color": "blue"}, map[string]int{"bar": 1})
+	mustFlush(ctx, t, writer)
+
+	if _, err = bm.Refresh(ctx); err != nil {
+		t.Fatalf("content refresh error: %v", err)
+	}
+
+	if err = reader.Refresh(ctx); err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+
+	// only the new content is loaded.
+	verifyContentsLoaded(1)
+
+	ids2, err := reader.Find(ctx, map[string]string{"type": "item"})
+	if err != nil {
+		t.Fatalf("find error: %v", err)
+	}
+
+	if got, want := len(ids2), len(ids1)+1; got != want {
+		t.Errorf("unexpected number of manifests after refresh: %v, want %v", got, want)
+	}
+}
+
+func mustFlush(ctx context.Context, t *testing.T, mgr *Manager) {
+	t.Helper()
+
+	if err := mgr.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	if err := mgr.b.Flush(ctx); err != nil {
+		t.Fatalf("content flush error: %v", err)
+	}
+}
+
+func TestManifestVersions(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	mgr := newManagerForTesting(ctx, t, data)
+
+	labels := map[string]string{"type": "item", "color": "red"}
+	otherLabels := map[string]string{"type": "item", "color": "blue"}
+
+	// replace the item the same way policies are replaced.
+	replace := func(i int) ID {
+		prev, err := mgr.Find(ctx, labels)
+		if err != nil {
+			t.Fatalf("find error: %v", err)
+		}
+
+		id := addAndVerify(ctx, t, mgr, labels, map[string]int{"version": i})
+
+		for _, p := range prev {
+			if err := mgr.Delete(ctx, p.ID); err != nil {
+				t.Fatalf("delete error: %v", err)
+			}
+		}
+
+		return id
+	}
+
+	id1 := replace(1)
+	addAndVerify(ctx, t, mgr, otherLabels, map[string]int{"version": 1})
+	id2 := replace(2)
+
+	verifyMatches(ctx, t, mgr, labels, []ID{id2})
+	verifyItemNotFound(ctx, t, mgr, id1)
+	verifyVersions(ctx, t, mgr, labels, []ID{id1})
+	verifyVersions(ctx, t, mgr, otherLabels, nil)
+	verifyVersion(ctx, t, mgr, id1, 1)
+
+	if _, err := mgr.GetVersion(ctx, id2, nil); err != ErrNotFound {
+		t.Errorf("unexpected error getting current item as previous version: %v", err)
+	}
+
+	// deleting again does not replace the previous version.
+	if err := mgr.Delete(ctx, id1); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	verifyVersion(ctx, t, mgr, id1, 1)
+
+	mustFlush(ctx, t, mgr)
+
+	// previous versions are persisted.
+	mgr = newManagerForTesting(ctx, t, data)
+	verifyVersions(ctx, t, mgr, labels, []ID{id1})
+	verifyVersion(ctx, t, mgr, id1, 1)
+
+	var ids []ID
+
+	for i := 3; i < 3+maxPreviousVersions+5; i++ {
+		ids = append(ids, replace(i))
+		mustFlush(ctx, t, mgr)
+	}
+
+	// only the most recent versions are retained after reloading, including after compaction.
+	wantVersions := append([]ID{id2}, ids[0:len(ids)-1]...)
+	wantVersions = wantVersions[len(wantVersions)-maxPreviousVersions:]
+
+	mgr = newManagerForTesting(ctx, t, data)
+	verifyMatches(ctx, t, mgr, labels, []ID{ids[len(ids)-1]})
+	verifyVersions(ctx, t, mgr, labels, wantVersions)
+
+	if err := mgr.Compact(ctx); err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	mgr = newManagerForTesting(ctx, t, data)
+	verifyMatches(ctx, t, mgr, labels, []ID{ids[len(ids)-1]})
+	verifyVersions(ctx, t, mgr, labels, wantVersions)
+	verifyVersion(ctx, t, mgr, wantVersions[0], len(ids)+2-maxPreviousVersions)
+}
+
+func TestManifestUnversionedTypes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	newManager := func() *Manager {
+		mm, err := NewManager(ctx, newContentManagerForTesting(ctx, t, data), ManagerOptions{
+			UnversionedTypes: []string{"snapshot"},
+		})
+		if err != nil {
+			t.Fatalf("can't create manifest manager: %v", err)
+		}
+
+		return mm
+	}
+
+	mgr := newManager()
+
+	snapshotLabels := map[string]string{"type": "snapshot", "host": "foo"}
+	itemLabels := map[string]string{"type": "item", "color": "red"}
+
+	snapshotID := addAndVerify(ctx, t, mgr, snapshotLabels, map[string]int{"version": 1})
+	itemID := addAndVerify(ctx, t, mgr, itemLabels, map[string]int{"version": 1})
+
+	for _, id := range []ID{snapshotID, itemID} {
+		if err := mgr.Delete(ctx, id); err != nil {
+			t.Fatalf("delete error: %v", err)
+		}
+	}
+
+	verifyVersions(ctx, t, mgr, snapshotLabels, nil)
+	verifyVersions(ctx, t, mgr, itemLabels, []ID{itemID})
+
+	if _, err := mgr.GetVersion(ctx, snapshotID, nil); err != ErrNotFound {
+		t.Errorf("unexpected error getting previous version of unversioned type: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	mgr = newManager()
+	verifyVersions(ctx, t, mgr, snapshotLabels, nil)
+	verifyVersions(ctx, t, mgr, itemLabels, []ID{itemID})
+	verifyVersion(ctx, t, mgr, itemID, 1)
+}
+
+func verifyVersions(ctx context.Context, t *testing.T, mgr *Manager, labels map[string]string, expected []ID) {
+	t.Helper()
+
+	versions, err := mgr.ListVersions(ctx, labels)
+	if err != nil {
+		t.Fatalf("error listing versions: %v", err)
+	}
+
+	var ids []ID
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+	}
+
+	// versions are sorted by time, which is the expected order.
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("unexpected versions of %v: %v, want %v", labels, ids, expected)
+	}
+}
+
+func verifyVersion(ctx context.Context, t *testing.T, mgr *Manager, id ID, wantVersion int) {
+	t.Helper()
+
+	var v map[string]int
+
+	if _, err := mgr.GetVersion(ctx, id, &v); err != nil {
+		t.Fatalf("unable to get version %v: %v", id, err)
+	}
+
+	if got := v["version"]; got != wantVersion {
+		t.Errorf("unexpected previous version of %v: %v, want %v", id, got, wantVersion)
+	}
+}
+
+func TestManifestRecordChanges(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	var recorded []Change
+
+	mgr, err := NewManager(ctx, newContentManagerForTesting(ctx, t, data), ManagerOptions{
+		RecordChanges: func(ctx context.Context, changes []Change) error {
+			recorded = append(recorded, changes...)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	labels := map[string]string{"type": "item", "color": "red"}
+
+	id1 := addAndVerify(ctx, t, mgr, labels, map[string]int{"version": 1})
+
+	if len(recorded) != 0 {
+		t.Fatalf("changes recorded before flush: %v", recorded)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	if err := mgr.Delete(ctx, id1); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	// deleting non-existent or already deleted items is not a change.
+	for _, id := range []ID{id1, "no-such-id"} {
+		if err := mgr.Delete(ctx, id); err != nil {
+			t.Fatalf("delete error: %v", err)
+		}
+	}
+
+	// compaction rewrites items without changing them.
+	if err := mgr.Compact(ctx); err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	var got []string
+	for _, c := range recorded {
+		got = append(got, c.Operation+" "+string(c.ID)+" "+c.Labels["color"])
+	}
+
+	want := []string{
+		"put " + string(id1) + " red",
+		"delete " + string(id1) + " red",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected recorded changes: %v, want %v", got, want)
+	}
+}
+
+func TestManifestExportImport(t *testing.T) {
+	ctx := testlogging.Context(t)
+	src := newManagerForTesting(ctx, t, blobtesting.DataMap{})
+
+	labels1 := map[string]string{"type": "item", "color": "red"}
+	labels2 := map[string]string{"type": "item", "color": "blue"}
+
+	id1 := addAndVerify(ctx, t, src, labels1, map[string]int{"version": 1})
+	id2 := addAndVerify(ctx, t, src, labels2, map[string]int{"version": 2})
+	id3 := addAndVerify(ctx, t, src, labels2, map[string]int{"version": 3})
+
+	if err := src.Delete(ctx, id3); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	items, err := src.Export(ctx)
+	if err != nil {
+		t.Fatalf("export error: %v", err)
+	}
+
+	if got, want := len(items), 2; got != want {
+		t.Fatalf("unexpected number of exported items: %v, want %v", got, want)
+	}
+
+	data := blobtesting.DataMap{}
+	dst := newManagerForTesting(ctx, t, data)
+
+	verifyImport(ctx, t, dst, items, 2)
+	mustFlush(ctx, t, dst)
+
+	dst = newManagerForTesting(ctx, t, data)
+	verifyItem(ctx, t, dst, id1, labels1, map[string]int{"version": 1})
+	verifyItem(ctx, t, dst, id2, labels2, map[string]int{"version": 2})
+	verifyItemNotFound(ctx, t, dst, id3)
+
+	// existing items are not imported again.
+	verifyImport(ctx, t, dst, items, 0)
+
+	// deleted items are restored.
+	if err := dst.Delete(ctx, id1); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	mustFlush(ctx, t, dst)
+	verifyImport(ctx, t, dst, items, 1)
+	mustFlush(ctx, t, dst)
+
+	dst = newManagerForTesting(ctx, t, data)
+	verifyItem(ctx, t, dst, id1, labels1, map[string]int{"version": 1})
+
+	if _, err := dst.Import(ctx, []*Item{{EntryMetadata: EntryMetadata{ID: "some-id"}}}); err == nil {
+		t.Errorf("unexpected success importing item without type")
+	}
+
+	// deleted items of unversioned types are not restored, since their data may have been garbage-collected.
+	dst, err = NewManager(ctx, newContentManagerForTesting(ctx, t, data), ManagerOptions{UnversionedTypes: []string{"item"}})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	if err := dst.Delete(ctx, id2); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	mustFlush(ctx, t, dst)
+	verifyImport(ctx, t, dst, items, 0)
+	verifyItemNotFound(ctx, t, dst, id2)
+}
+
+func verifyImport(ctx context.Context, t *testing.T, mgr *Manager, items []*Item, want int) {
+	t.Helper()
+
+	n, err := mgr.Import(ctx, items)
+	if err != nil {
+		t.Fatalf("import error: %v", err)
+	}
+
+	if n != want {
+		t.Errorf("unexpected number of imported items: %v, want %v", n, want)
+	}
+}
+
+func TestManifestCreatedTime(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	ta := faketime.NewTimeAdvance(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	mgr, err := NewManager(ctx, newContentManagerForTesting(ctx, t, data), ManagerOptions{TimeNow: ta.NowFunc()})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	labels := map[string]string{"type": "item"}
+	putTime := ta.Advance(0)
+
+	id := addAndVerify(ctx, t, mgr, labels, map[string]int{"version": 1})
+
+	md, err := mgr.GetMetadata(ctx, id)
+	if err != nil {
+		t.Fatalf("unable to get metadata: %v", err)
+	}
+
+	if !md.Created.Equal(putTime) || !md.ModTime.Equal(putTime) {
+		t.Errorf("unexpected times of new item: created %v modified %v, want %v", md.Created, md.ModTime, putTime)
+	}
+
+	deleteTime := ta.Advance(time.Hour)
+
+	if err := mgr.Delete(ctx, id); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	mustFlush(ctx, t, mgr)
+
+	// previous version retains the time it was created after reloading.
+	mgr = newManagerForTesting(ctx, t, data)
+
+	versions, err := mgr.ListVersions(ctx, labels)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("unexpected versions: %v %v", versions, err)
+	}
+
+	if !versions[0].Created.Equal(putTime) || !versions[0].ModTime.Equal(deleteTime) {
+		t.Errorf("unexpected times of previous version: created %v modified %v, want %v and %v", versions[0].Created, versions[0].ModTime, putTime, deleteTime)
+	}
+}
+
+func TestManifestPutIfMatch(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	mgr1 := newManagerForTesting(ctx, t, data)
+
+	labels := map[string]string{"type": "item", "color": "red"}
+
+	id1, err := mgr1.PutIfMatch(ctx, labels, map[string]int{"version": 1}, nil)
+	if err != nil {
+		t.Fatalf("unable to put first item: %v", err)
+	}
+
+	if _, err := mgr1.PutIfMatch(ctx, labels, map[string]int{"version": 2}, nil); errors.Cause(err) != ErrConflict {
+		t.Fatalf("unexpected error when item already exists: %v", err)
+	}
+
+	cm2 := newContentManagerForTesting(ctx, t, data)
+
+	mgr2, err := NewManager(ctx, cm2, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	verifyMatches(ctx, t, mgr2, labels, []ID{id1})
+
+	// both clients have read the same item, the first one replaces it.
+	id2, err := mgr1.PutIfMatch(ctx, labels, map[string]int{"version": 2}, []ID{id1})
+	if err != nil {
+		t.Fatalf("unable to replace item: %v", err)
+	}
+
+	verifyMatches(ctx, t, mgr1, labels, []ID{id2})
+
+	// replacement of the same item by the second client is rejected instead of overwriting the change.
+	if _, err := mgr2.PutIfMatch(ctx, labels, map[string]int{"version": 3}, []ID{id1}); errors.Cause(err) != ErrConflict {
+		t.Fatalf("unexpected error replacing modified item: %v", err)
+	}
+
+	verifyMatches(ctx, t, mgr2, labels, []ID{id2})
+
+	id3, err := mgr2.PutIfMatch(ctx, labels, map[string]int{"version": 3}, []ID{id2})
+	if err != nil {
+		t.Fatalf("unable to replace current item: %v", err)
+	}
+
+	verifyMatches(ctx, t, mgr2, labels, []ID{id3})
+	verifyMatches(ctx, t, mgr2, map[string]string{"type": "item", "color": "blue"}, nil)
+}
+
+// flushHookContentManager invokes the hook before flushing the underlying content manager.
+type flushHookContentManager struct {
+	contentManager
+	beforeFlush func()
+}
+
+func (c *flushHookContentManager) Flush(ctx context.Context) error {
+	if h := c.beforeFlush; h != nil {
+		c.beforeFlush = nil
+		h()
+	}
+
+	return c.contentManager.Flush(ctx)
+}
+
+func TestManifestPutIfMatchConcurrent(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	labels := map[string]string{"type": "item", "color": "red"}
+
+	mgr2 := newManagerForTesting(ctx, t, data)
+	hooked := &flushHookContentManager{contentManager: newContentManagerForTesting(ctx, t, data)}
+
+	mgr1, err := NewManager(ctx, hooked, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create manifest manager: %v", err)
+	}
+
+	var err2 error
+
+	// second client puts its item after the first one has verified that no item exists, but before it is flushed.
+	hooked.beforeFlush = func() {
+		_, err2 = mgr2.PutIfMatch(ctx, labels, map[string]int{"client": 2}, nil)
+	}
+
+	_, err1 := mgr1.PutIfMatch(ctx, labels, map[string]int{"client": 1}, nil)
+
+	if err1 == nil && err2 == nil {
+		t.Fatalf("both concurrent writes succeeded")
+	}
+
+	for _, err := range []error{err1, err2} {
+		if err != nil && errors.Cause(err) != ErrConflict {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	mgr3 := newManagerForTesting(ctx, t, data)
+
+	ids, err := mgr3.Find(ctx, labels)
+	if err != nil {
+		t.Fatalf("find error: %v", err)
+	}
+
+	if got, want := len(ids), 1; got > want {
+		t.Fatalf("unexpected number of items after concurrent writes: %v, want at most %v", got, want)
+	}
+}
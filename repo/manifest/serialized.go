@@ -13,6 +13,17 @@ type manifestEntry struct {
 	ID      ID                `json:"id"`
 	Labels  map[string]string `json:"labels"`
 	ModTime time.Time         `json:"modified"`
+	Created time.Time         `json:"created"`
 	Deleted bool              `json:"deleted,omitempty"`
 	Content json.RawMessage   `json:"data"`
 }
+
+// createdTime returns the time the entry contents were put, which is unknown for entries
+// written by older versions.
+func (e *manifestEntry) createdTime() time.Time {
+	if e.Created.IsZero() {
+		return e.ModTime
+	}
+
+	return e.Created
+}
@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+const (
+	manifestExportVersion  = 1
+	manifestExportSaltSize = 32
+)
+
+// ErrInvalidExportPassword is returned when the exported manifests can't be decrypted with the provided password.
+var ErrInvalidExportPassword = errors.New("invalid password of exported manifests")
+
+// manifestExport is the JSON-encoded archive of exported manifests.
+type manifestExport struct {
+	Version                int    `json:"version"`
+	KeyDerivationAlgorithm string `json:"keyAlgo"`
+	Salt                   []byte `json:"salt"`
+
+	// EncryptedData is gzip-compressed JSON of manifestExportData encrypted with the key derived from the password.
+	EncryptedData []byte `json:"encryptedData"`
+}
+
+// manifestExportData describes the exported manifests along with the format of the repository they were exported from.
+type manifestExportData struct {
+	RepositoryID []byte    `json:"repositoryID"`
+	ExportTime   time.Time `json:"exportTime"`
+	Hash         string    `json:"hash"`
+	Encryption   string    `json:"encryption"`
+	Splitter     string    `json:"splitter"`
+
+	Items []*manifest.Item `json:"items"`
+}
+
+// ExportManifests writes all manifests (policies, snapshot manifests and others) to a single archive
+// encrypted with the provided password, which can be imported into any repository using ImportManifests.
+func (r *DirectRepository) ExportManifests(ctx context.Context, w io.Writer, password string) error {
+	items, err := r.Manifests.Export(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to export manifests")
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(&manifestExportData{
+		RepositoryID: r.UniqueID,
+		ExportTime:   r.Time().UTC(),
+		Hash:         r.Content.Format.Hash,
+		Encryption:   r.Content.Format.Encryption,
+		Splitter:     r.Objects.Format.Splitter,
+		Items:        items,
+	}); err != nil {
+		return errors.Wrap(err, "unable to serialize manifests")
+	}
+
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "unable to compress manifests")
+	}
+
+	me := &manifestExport{
+		Version:                manifestExportVersion,
+		KeyDerivationAlgorithm: newKeyDerivationAlgorithm(),
+		Salt:                   make([]byte, manifestExportSaltSize),
+	}
+
+	if _, err := io.ReadFull(rand.Reader, me.Salt); err != nil {
+		return errors.Wrap(err, "unable to generate salt")
+	}
+
+	key, err := deriveKeyFromPasswordWithAlgorithm(me.KeyDerivationAlgorithm, password, me.Salt)
+	if err != nil {
+		return errors.Wrap(err, "unable to derive key from password")
+	}
+
+	if me.EncryptedData, err = wrapMasterKey(key, buf.Bytes(), me.Salt); err != nil {
+		return errors.Wrap(err, "unable to encrypt manifests")
+	}
+
+	return errors.Wrap(json.NewEncoder(w).Encode(me), "unable to write exported manifests")
+}
+
+// ImportManifests adds manifests from the archive written by ExportManifests and returns the number of imported items.
+// Manifests that already exist in the repository are not imported again.
+func (r *DirectRepository) ImportManifests(ctx context.Context, rd io.Reader, password string) (int, error) {
+	me := &manifestExport{}
+	if err := json.NewDecoder(rd).Decode(me); err != nil {
+		return 0, errors.Wrap(err, "invalid exported manifests")
+	}
+
+	if me.Version != manifestExportVersion {
+		return 0, errors.Errorf("unsupported version of exported manifests: %v", me.Version)
+	}
+
+	key, err := deriveKeyFromPasswordWithAlgorithm(me.KeyDerivationAlgorithm, password, me.Salt)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to derive key from password")
+	}
+
+	compressed, err := unwrapMasterKey(key, me.EncryptedData, me.Salt)
+	if err != nil {
+		return 0, ErrInvalidExportPassword
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid exported manifests")
+	}
+
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to decompress exported manifests")
+	}
+
+	data := &manifestExportData{}
+	if err := json.Unmarshal(b, data); err != nil {
+		return 0, errors.Wrap(err, "invalid exported manifests")
+	}
+
+	if !bytes.Equal(data.RepositoryID, r.UniqueID) && containsSnapshots(data.Items) {
+		log(ctx).Warningf("importing manifests exported from a different repository at %v, snapshots require their contents to be present in this repository",
+			data.ExportTime.Local())
+	}
+
+	n, err := r.Manifests.Import(ctx, data.Items)
+	if err != nil {
+		return n, errors.Wrap(err, "unable to import manifests")
+	}
+
+	return n, nil
+}
+
+func containsSnapshots(items []*manifest.Item) bool {
+	for _, it := range items {
+		if it.Labels[manifest.TypeLabelKey] == "snapshot" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -35,13 +37,26 @@ type contentManager interface {
 	WriteContent(ctx context.Context, data []byte, prefix content.ID) (content.ID, error)
 }
 
+// existingContentReporter is implemented by content managers which report whether written content already existed.
+type existingContentReporter interface {
+	WriteContentIfNotExists(ctx context.Context, data []byte, prefix content.ID) (content.ID, bool, error)
+}
+
 // Format describes the format of objects in a repository.
 type Format struct {
 	Splitter string `json:"splitter,omitempty"` // splitter used to break objects into pieces of content
+
+	// chunk sizes of content-defined splitter, when not set sizes implied by the splitter name are used.
+	MinChunkSize int `json:"minChunkSize,omitempty"`
+	AvgChunkSize int `json:"avgChunkSize,omitempty"`
+	MaxChunkSize int `json:"maxChunkSize,omitempty"`
 }
 
 // Manager implements a content-addressable storage on top of blob storage.
 type Manager struct {
+	// Keep Stats first to ensure its int64 fields get aligned to at least 64-bit
+	// boundaries, which is required for atomic access on ARM and x86-32.
+	Stats  Stats
 	Format Format
 
 	contentMgr contentManager
@@ -49,6 +64,9 @@ type Manager struct {
 
 	newSplitter splitter.Factory
 
+	namedSplittersMutex sync.Mutex
+	namedSplitters      map[string]splitter.Factory // pooled factories of splitters requested by writers
+
 	bufferPool *buf.Pool
 }
 
@@ -57,7 +75,7 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	w := &objectWriter{
 		ctx:         ctx,
 		om:          om,
-		splitter:    om.newSplitter(),
+		splitter:    om.splitterForWriter(opt.Splitter)(),
 		description: opt.Description,
 		prefix:      opt.Prefix,
 		compressor:  compression.ByName[opt.Compressor],
@@ -75,6 +93,48 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	return w
 }
 
+// writeContent writes the provided content and returns its ID and whether it was already in the repository,
+// which is only known when supported by the content manager.
+func (om *Manager) writeContent(ctx context.Context, data []byte, prefix content.ID) (content.ID, bool, error) {
+	if r, ok := om.contentMgr.(existingContentReporter); ok {
+		return r.WriteContentIfNotExists(ctx, data, prefix)
+	}
+
+	contentID, err := om.contentMgr.WriteContent(ctx, data, prefix)
+
+	return contentID, false, err
+}
+
+// splitterForWriter returns the factory of the splitter with the provided name or the default splitter
+// of the repository if the name is empty or not supported. Objects can be read regardless of the splitter
+// used to write them.
+func (om *Manager) splitterForWriter(name string) splitter.Factory {
+	if name == "" || name == om.Format.Splitter {
+		return om.newSplitter
+	}
+
+	om.namedSplittersMutex.Lock()
+	defer om.namedSplittersMutex.Unlock()
+
+	if f := om.namedSplitters[name]; f != nil {
+		return f
+	}
+
+	f := splitter.GetFactory(name)
+	if f == nil {
+		om.trace("unsupported splitter %q, using %q", name, om.Format.Splitter)
+		return om.newSplitter
+	}
+
+	if om.namedSplitters == nil {
+		om.namedSplitters = map[string]splitter.Factory{}
+	}
+
+	om.namedSplitters[name] = splitter.Pooled(f)
+
+	return om.namedSplitters[name]
+}
+
 // Open creates new ObjectReader for reading given object from a repository.
 func (om *Manager) Open(ctx context.Context, objectID ID) (Reader, error) {
 	return om.openAndAssertLength(ctx, objectID, -1)
@@ -162,6 +222,69 @@ func (om *Manager) verifyObjectInternal(ctx context.Context, oid ID, tracker *co
 	return errors.Errorf("unrecognized object type: %v", oid)
 }
 
+// Concatenate creates an object that is a logical concatenation of the provided objects.
+// The resulting object references the existing contents of the provided objects, so their
+// data is not rewritten and only the length of direct objects needs to be determined by reading them.
+func (om *Manager) Concatenate(ctx context.Context, objectIDs []ID) (ID, error) {
+	if len(objectIDs) == 0 {
+		return "", errors.Errorf("empty list of objects")
+	}
+
+	if len(objectIDs) == 1 {
+		return objectIDs[0], nil
+	}
+
+	var (
+		concatenatedEntries []indirectObjectEntry
+		totalLength         int64
+	)
+
+	for _, objectID := range objectIDs {
+		entries, err := om.flattenedEntries(ctx, objectID)
+		if err != nil {
+			return "", errors.Wrapf(err, "error reading %v", objectID)
+		}
+
+		for _, e := range entries {
+			if e.Length == 0 {
+				continue
+			}
+
+			e.Start = totalLength
+			totalLength += e.Length
+			concatenatedEntries = append(concatenatedEntries, e)
+		}
+	}
+
+	if len(concatenatedEntries) == 0 {
+		// all objects are empty, return the first of them.
+		return objectIDs[0], nil
+	}
+
+	return om.writeIndirectObject(ctx, "CONCATENATED", "", concatenatedEntries)
+}
+
+// flattenedEntries returns the list of direct objects that make up the provided object.
+func (om *Manager) flattenedEntries(ctx context.Context, objectID ID) ([]indirectObjectEntry, error) {
+	if indexObjectID, ok := objectID.IndexObjectID(); ok {
+		rd, err := om.Open(ctx, indexObjectID)
+		if err != nil {
+			return nil, err
+		}
+		defer rd.Close() //nolint:errcheck
+
+		return om.flattenListChunk(rd)
+	}
+
+	rd, err := om.newRawReader(ctx, objectID, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close() //nolint:errcheck
+
+	return []indirectObjectEntry{{Length: rd.Length(), Object: objectID}}, nil
+}
+
 func nullTrace(message string, args ...interface{}) {
 }
 
@@ -170,6 +293,20 @@ type ManagerOptions struct {
 	Trace func(message string, args ...interface{})
 }
 
+func splitterFactory(splitterID string, f Format) (splitter.Factory, error) {
+	if f.AvgChunkSize != 0 {
+		os, err := splitter.GetFactoryWithChunkSizes(splitterID, f.MinChunkSize, f.AvgChunkSize, f.MaxChunkSize)
+		return os, errors.Wrap(err, "invalid splitter chunk sizes")
+	}
+
+	os := splitter.GetFactory(splitterID)
+	if os == nil {
+		return nil, errors.Errorf("unsupported splitter %q", f.Splitter)
+	}
+
+	return os, nil
+}
+
 // NewObjectManager creates an ObjectManager with the specified content manager and format.
 func NewObjectManager(ctx context.Context, bm contentManager, f Format, opts ManagerOptions) (*Manager, error) {
 	om := &Manager{
@@ -183,9 +320,9 @@ func NewObjectManager(ctx context.Context, bm contentManager, f Format, opts Man
 		splitterID = "FIXED"
 	}
 
-	os := splitter.GetFactory(splitterID)
-	if os == nil {
-		return nil, errors.Errorf("unsupported splitter %q", f.Splitter)
+	os, err := splitterFactory(splitterID, f)
+	if err != nil {
+		return nil, err
 	}
 
 	om.newSplitter = splitter.Pooled(os)
@@ -248,10 +385,14 @@ func (om *Manager) newRawReader(ctx context.Context, objectID ID, assertLength i
 		if compressed {
 			var b bytes.Buffer
 
+			t0 := time.Now() // allow:no-inject-time
+
 			if err = om.decompress(&b, payload); err != nil {
 				return nil, errors.Wrap(err, "decompression error")
 			}
 
+			om.Stats.decompressed(b.Len(), time.Since(t0)) // allow:no-inject-time
+
 			payload = b.Bytes()
 		}
 
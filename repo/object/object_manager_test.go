@@ -39,6 +39,11 @@ func (f *fakeContentManager) GetContent(ctx context.Context, contentID content.I
 }
 
 func (f *fakeContentManager) WriteContent(ctx context.Context, data []byte, prefix content.ID) (content.ID, error) {
+	contentID, _, err := f.WriteContentIfNotExists(ctx, data, prefix)
+	return contentID, err
+}
+
+func (f *fakeContentManager) WriteContentIfNotExists(ctx context.Context, data []byte, prefix content.ID) (content.ID, bool, error) {
 	h := sha256.New()
 	h.Write(data) //nolint:errcheck
 	contentID := prefix + content.ID(hex.EncodeToString(h.Sum(nil)))
@@ -46,9 +51,10 @@ func (f *fakeContentManager) WriteContent(ctx context.Context, data []byte, pref
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	_, existed := f.data[contentID]
 	f.data[contentID] = append([]byte(nil), data...)
 
-	return contentID, nil
+	return contentID, existed, nil
 }
 
 func (f *fakeContentManager) ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error) {
@@ -502,3 +508,275 @@ func TestSeek(t *testing.T) {
 		}
 	}
 }
+
+func TestConcatenate(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, om := setupTest(t)
+
+	writeObject := func(data []byte, compressor compression.Name) ID {
+		t.Helper()
+
+		w := om.NewWriter(ctx, WriterOptions{Compressor: compressor})
+		defer w.Close()
+
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+
+		oid, err := w.Result()
+		if err != nil {
+			t.Fatalf("result error: %v", err)
+		}
+
+		return oid
+	}
+
+	small := []byte("the quick brown fox jumps over the lazy dog")
+	empty := []byte{}
+	large := make([]byte, 2500000)
+	cryptorand.Read(large) //nolint:errcheck
+
+	compressible := bytes.Repeat([]byte{1, 2, 3, 4}, 1000000)
+
+	smallID := writeObject(small, "")
+	emptyID := writeObject(empty, "")
+	largeID := writeObject(large, "")
+	compressibleID := writeObject(compressible, "pgzip")
+
+	concatenatedID, err := om.Concatenate(ctx, []ID{smallID, largeID, emptyID, compressibleID, smallID})
+	if err != nil {
+		t.Fatalf("unable to concatenate: %v", err)
+	}
+
+	if indirectionLevel(concatenatedID) != 1 {
+		t.Errorf("unexpected indirection level of %v", concatenatedID)
+	}
+
+	var expected []byte
+
+	for _, d := range [][]byte{small, large, empty, compressible, small} {
+		expected = append(expected, d...)
+	}
+
+	r, err := om.Open(ctx, concatenatedID)
+	if err != nil {
+		t.Fatalf("unable to open: %v", err)
+	}
+	defer r.Close()
+
+	if got, want := r.Length(), int64(len(expected)); got != want {
+		t.Errorf("unexpected length: %v, want %v", got, want)
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unable to read: %v", err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("unexpected data")
+	}
+
+	verify(ctx, t, om, concatenatedID, expected, "concatenated")
+
+	if _, err := om.VerifyObject(ctx, concatenatedID); err != nil {
+		t.Errorf("unable to verify concatenated object: %v", err)
+	}
+
+	// concatenating concatenated objects
+	doubleID, err := om.Concatenate(ctx, []ID{concatenatedID, concatenatedID})
+	if err != nil {
+		t.Fatalf("unable to concatenate: %v", err)
+	}
+
+	verify(ctx, t, om, doubleID, append(append([]byte(nil), expected...), expected...), "double")
+
+	if got, err := om.Concatenate(ctx, []ID{smallID}); err != nil || got != smallID {
+		t.Errorf("unexpected result of concatenating single object: %v %v", got, err)
+	}
+
+	if _, err := om.Concatenate(ctx, nil); err == nil {
+		t.Errorf("unexpected success concatenating empty list")
+	}
+
+	if _, err := om.Concatenate(ctx, []ID{smallID, "no-such-object"}); err == nil {
+		t.Errorf("unexpected success concatenating missing object")
+	}
+}
+
+func TestContentDefinedChunkSizes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := map[content.ID][]byte{}
+
+	om, err := NewObjectManager(ctx, &fakeContentManager{data: data}, Format{
+		Splitter:     "DYNAMIC-4M-BUZHASH",
+		MinChunkSize: 4096,
+		AvgChunkSize: 8192,
+		MaxChunkSize: 16384,
+	}, ManagerOptions{})
+	if err != nil {
+		t.Fatalf("can't create object manager: %v", err)
+	}
+
+	if got, want := om.newSplitter().MaxSegmentSize(), 16384; got != want {
+		t.Errorf("unexpected max segment size: %v, want %v", got, want)
+	}
+
+	original := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(original) //nolint:errcheck
+
+	write := func(b []byte) {
+		w := om.NewWriter(ctx, WriterOptions{})
+		defer w.Close()
+
+		if _, err := w.Write(b); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+
+		if _, err := w.Result(); err != nil {
+			t.Fatalf("result error: %v", err)
+		}
+	}
+
+	write(original)
+
+	chunkCount := len(data)
+
+	// inserting bytes at the beginning only affects the first chunk and the index of chunks.
+	write(append([]byte("some inserted bytes"), original...))
+
+	if got := len(data) - chunkCount; got > 5 {
+		t.Errorf("too many new contents after shifting data: %v out of %v", got, chunkCount)
+	}
+
+	if _, err := NewObjectManager(ctx, &fakeContentManager{data: data}, Format{
+		Splitter:     "FIXED-1M",
+		AvgChunkSize: 8192,
+	}, ManagerOptions{}); err == nil {
+		t.Errorf("expected error for fixed splitter with chunk sizes")
+	}
+}
+
+func TestWriterSplitter(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	payload := make([]byte, 3<<20)
+	rand.New(rand.NewSource(1)).Read(payload) //nolint:errcheck
+
+	cases := []struct {
+		splitter     string
+		wantContents int
+	}{
+		{"", 4},         // FIXED-1M used by the test repository, 3 chunks and index
+		{"FIXED-1M", 4}, // same as the repository default
+		{"FIXED-2M", 3}, // 2 chunks and index
+		{"FIXED-4M", 1}, // single chunk
+		{"NO-SUCH", 4},  // unsupported splitter falls back to the repository default
+	}
+
+	for _, tc := range cases {
+		data, om := setupTest(t)
+
+		// write twice to reuse pooled splitters, the second write is deduplicated.
+		for i := 0; i < 2; i++ {
+			w := om.NewWriter(ctx, WriterOptions{Splitter: tc.splitter})
+
+			if _, err := w.Write(payload); err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+
+			if _, err := w.Result(); err != nil {
+				t.Fatalf("result error: %v", err)
+			}
+
+			w.Close()
+		}
+
+		if got := len(data); got != tc.wantContents {
+			t.Errorf("unexpected number of contents written with splitter %q: %v, want %v", tc.splitter, got, tc.wantContents)
+		}
+	}
+}
+
+func TestWriterCompressionStats(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, om := setupTest(t)
+
+	compressible := bytes.Repeat([]byte("hello world "), 100000)
+
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random) //nolint:errcheck
+
+	cases := []struct {
+		compressor compression.Name
+		data       []byte
+		wantInput  int64
+		compressed bool
+	}{
+		{"", compressible, 0, false},
+		{"gzip", compressible, int64(len(compressible)), true},
+		{"gzip", random, int64(len(random)), false},
+	}
+
+	for _, tc := range cases {
+		w := om.NewWriter(ctx, WriterOptions{Compressor: tc.compressor})
+
+		if _, err := w.Write(tc.data); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+
+		if _, err := w.Result(); err != nil {
+			t.Fatalf("result error: %v", err)
+		}
+
+		cs := w.CompressionStats()
+		w.Close()
+
+		if got, want := cs.InputBytes, tc.wantInput; got != want {
+			t.Errorf("unexpected input bytes for %q: %v, want %v", tc.compressor, got, want)
+		}
+
+		if got := cs.OutputBytes < cs.InputBytes; got != tc.compressed {
+			t.Errorf("unexpected compression result for %q: %+v", tc.compressor, cs)
+		}
+	}
+}
+
+func TestWriterDeduplicationStats(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, om := setupTest(t)
+
+	data := make([]byte, 3<<20)
+	rand.New(rand.NewSource(1)).Read(data) //nolint:errcheck
+
+	write := func(b []byte) DeduplicationStats {
+		w := om.NewWriter(ctx, WriterOptions{})
+		defer w.Close()
+
+		if _, err := w.Write(b); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+
+		if _, err := w.Result(); err != nil {
+			t.Fatalf("result error: %v", err)
+		}
+
+		return w.DeduplicationStats()
+	}
+
+	if got, want := write(data), (DeduplicationStats{NewBytes: int64(len(data))}); got != want {
+		t.Errorf("unexpected stats of new data: %+v, want %+v", got, want)
+	}
+
+	if got, want := write(data), (DeduplicationStats{DeduplicatedBytes: int64(len(data))}); got != want {
+		t.Errorf("unexpected stats of existing data: %+v, want %+v", got, want)
+	}
+
+	// first 2 chunks of 1MB are shared with the previous data.
+	changed := append([]byte(nil), data...)
+	changed[len(changed)-1]++
+
+	if got, want := write(changed), (DeduplicationStats{NewBytes: 1 << 20, DeduplicatedBytes: 2 << 20}); got != want {
+		t.Errorf("unexpected stats of modified data: %+v, want %+v", got, want)
+	}
+}
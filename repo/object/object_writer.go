@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
@@ -26,6 +27,20 @@ type Writer interface {
 	io.WriteCloser
 
 	Result() (ID, error)
+
+	// CompressionStats returns the size of data written so far before and after compression.
+	CompressionStats() CompressionStats
+
+	// DeduplicationStats returns the size of contents written so far which were new to the repository
+	// or already present.
+	DeduplicationStats() DeduplicationStats
+}
+
+// CompressionStats describes the effect of compression on data written by a Writer.
+// Data written without a compressor is not included.
+type CompressionStats struct {
+	InputBytes  int64 `json:"inputBytes"`  // bytes passed to the compressor
+	OutputBytes int64 `json:"outputBytes"` // bytes stored, which are not compressed when compression would not save space
 }
 
 type contentIDTracker struct {
@@ -56,7 +71,22 @@ func (t *contentIDTracker) contentIDs() []content.ID {
 	return result
 }
 
+// DeduplicationStats describes how much of the data written by a Writer was already in the repository.
+// Sizes are of stored, possibly compressed, contents. Contents are counted as new when the repository
+// does not report whether they existed.
+type DeduplicationStats struct {
+	NewBytes          int64 `json:"newBytes"`
+	DeduplicatedBytes int64 `json:"deduplicatedBytes"`
+}
+
 type objectWriter struct {
+	// Keep int64 fields first to ensure they get aligned to at least 64-bit
+	// boundaries, which is required for atomic access on ARM and x86-32.
+	compressionInputBytes  int64
+	compressionOutputBytes int64
+	newBytes               int64
+	deduplicatedBytes      int64
+
 	ctx context.Context
 	om  *Manager
 
@@ -175,11 +205,22 @@ func (w *objectWriter) prepareAndWriteContentChunk(chunkID int, data []byte) err
 		return errors.Wrap(err, "unable to prepare content bytes")
 	}
 
-	contentID, err := w.om.contentMgr.WriteContent(w.ctx, contentBytes, w.prefix)
+	if w.compressor != nil {
+		atomic.AddInt64(&w.compressionInputBytes, int64(len(data)))
+		atomic.AddInt64(&w.compressionOutputBytes, int64(len(contentBytes)))
+	}
+
+	contentID, existed, err := w.om.writeContent(w.ctx, contentBytes, w.prefix)
 	if err != nil {
 		return errors.Wrapf(err, "unable to write content chunk %v of %v: %v", chunkID, w.description, err)
 	}
 
+	if existed {
+		atomic.AddInt64(&w.deduplicatedBytes, int64(len(contentBytes)))
+	} else {
+		atomic.AddInt64(&w.newBytes, int64(len(contentBytes)))
+	}
+
 	// update index under a lock
 	w.indirectIndexGrowMutex.Lock()
 	w.indirectIndex[chunkID].Object = maybeCompressedObjectID(contentID, isCompressed)
@@ -223,6 +264,20 @@ func maybeCompressedContentBytes(comp compression.Compressor, output *bytes.Buff
 	return input, false, nil
 }
 
+func (w *objectWriter) CompressionStats() CompressionStats {
+	return CompressionStats{
+		InputBytes:  atomic.LoadInt64(&w.compressionInputBytes),
+		OutputBytes: atomic.LoadInt64(&w.compressionOutputBytes),
+	}
+}
+
+func (w *objectWriter) DeduplicationStats() DeduplicationStats {
+	return DeduplicationStats{
+		NewBytes:          atomic.LoadInt64(&w.newBytes),
+		DeduplicatedBytes: atomic.LoadInt64(&w.deduplicatedBytes),
+	}
+}
+
 func (w *objectWriter) Result() (ID, error) {
 	// no need to hold a lock on w.indirectIndexGrowMutex, since growing index only happens synchronously
 	// and never in parallel with calling Result()
@@ -243,13 +298,18 @@ func (w *objectWriter) Result() (ID, error) {
 		return w.indirectIndex[0].Object, nil
 	}
 
+	return w.om.writeIndirectObject(w.ctx, "LIST("+w.description+")", w.prefix, w.indirectIndex)
+}
+
+// writeIndirectObject writes the index of the provided entries and returns the ID of an indirect object referencing them.
+func (om *Manager) writeIndirectObject(ctx context.Context, description string, prefix content.ID, entries []indirectObjectEntry) (ID, error) {
 	iw := &objectWriter{
-		ctx:         w.ctx,
-		om:          w.om,
+		ctx:         ctx,
+		om:          om,
 		compressor:  nil,
-		description: "LIST(" + w.description + ")",
-		splitter:    w.om.newSplitter(),
-		prefix:      w.prefix,
+		description: description,
+		splitter:    om.newSplitter(),
+		prefix:      prefix,
 	}
 
 	if iw.prefix == "" {
@@ -263,7 +323,7 @@ func (w *objectWriter) Result() (ID, error) {
 
 	ind := indirectObject{
 		StreamID: "kopia:indirect",
-		Entries:  w.indirectIndex,
+		Entries:  entries,
 	}
 
 	if err := json.NewEncoder(iw).Encode(ind); err != nil {
@@ -283,5 +343,6 @@ type WriterOptions struct {
 	Description string
 	Prefix      content.ID // empty string or a single-character ('g'..'z')
 	Compressor  compression.Name
-	AsyncWrites int // allow up to N content writes to be asynchronous
+	Splitter    string // splitter used instead of the repository default, see splitter.SupportedAlgorithms()
+	AsyncWrites int    // allow up to N content writes to be asynchronous
 }
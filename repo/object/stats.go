@@ -0,0 +1,35 @@
+package object
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats exposes statistics about object operations.
+type Stats struct {
+	// Keep int64 fields first to ensure they get aligned to at least 64-bit
+	// boundaries, which is required for atomic access on ARM and x86-32.
+	decompressedBytes int64
+	decompressNanos   int64
+}
+
+// Reset clears all object statistics.
+func (s *Stats) Reset() {
+	atomic.StoreInt64(&s.decompressedBytes, 0)
+	atomic.StoreInt64(&s.decompressNanos, 0)
+}
+
+// DecompressedBytes returns the approximate total number of decompressed bytes.
+func (s *Stats) DecompressedBytes() int64 {
+	return atomic.LoadInt64(&s.decompressedBytes)
+}
+
+// DecompressDuration returns the approximate total time spent decompressing objects.
+func (s *Stats) DecompressDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.decompressNanos))
+}
+
+func (s *Stats) decompressed(size int, d time.Duration) {
+	atomic.AddInt64(&s.decompressedBytes, int64(size))
+	atomic.AddInt64(&s.decompressNanos, int64(d))
+}
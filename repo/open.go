@@ -3,7 +3,6 @@ package repo
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,8 +11,16 @@ import (
 	"github.com/natefinch/atomic"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/blobaudit"
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/repo/audit"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/caching"
+	"github.com/kopia/kopia/repo/blob/filesystem"
 	loggingwrapper "github.com/kopia/kopia/repo/blob/logging"
+	"github.com/kopia/kopia/repo/blob/quota"
+	"github.com/kopia/kopia/repo/blob/stats"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/manifest"
@@ -27,6 +34,12 @@ type Options struct {
 	TraceStorage         func(f string, args ...interface{}) // Logs all storage access using provided Printf-style function
 	ObjectManagerOptions object.ManagerOptions
 	TimeNowFunc          func() time.Time // Time provider
+	HardwareKey          HardwareKeyFunc  // Computes responses of hardware key, overrides the command in the configuration
+	RecoveryShares       []string         // Recovery shares used to reconstruct the master key instead of the password
+	RecoveryCode         string           // Recovery code used to unwrap the master key instead of the password
+	StorageStats         *stats.Counters  // Counts operations performed on the underlying storage
+
+	signLocalConfig bool // computes HMAC of the local config instead of verifying it, set when connecting
 }
 
 // ErrInvalidPassword is returned when repository password is invalid.
@@ -55,7 +68,7 @@ func Open(ctx context.Context, configFile, password string, options *Options) (r
 	}
 
 	if lc.APIServer != nil {
-		return openAPIServer(ctx, lc.APIServer, lc.Username, lc.Hostname, password)
+		return openAPIServer(ctx, lc.APIServer, lc.Username, lc.Hostname, password, lc.EnableActions)
 	}
 
 	return openDirect(ctx, configFile, lc, password, options)
@@ -63,8 +76,10 @@ func Open(ctx context.Context, configFile, password string, options *Options) (r
 
 // openDirect opens the repository that directly manipulates blob storage..
 func openDirect(ctx context.Context, configFile string, lc *LocalConfig, password string, options *Options) (rep *DirectRepository, err error) {
-	if lc.Caching.CacheDirectory != "" && !filepath.IsAbs(lc.Caching.CacheDirectory) {
-		lc.Caching.CacheDirectory = filepath.Join(filepath.Dir(configFile), lc.Caching.CacheDirectory)
+	// capture the configuration before paths are resolved and caches are limited.
+	integrityData, err := lc.integrityData()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal local config")
 	}
 
 	if lc.Storage == nil {
@@ -80,7 +95,46 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 		st = loggingwrapper.NewWrapper(st, options.TraceStorage, "[STORAGE] ")
 	}
 
-	r, err := OpenWithConfig(ctx, st, lc, password, options, *lc.Caching)
+	if options.StorageStats != nil {
+		st = stats.NewWrapper(st, options.StorageStats)
+	}
+
+	// the configuration is verified before the cache directory, key file or hardware key command
+	// it specifies are used, so the format blob is read directly from the storage.
+	fb, err := st.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, errors.Wrap(err, "unable to read format blob")
+	}
+
+	u, err := unlockFormatBlob(ctx, fb, lc, password, options, func(hmacKey []byte) error {
+		if options.signLocalConfig {
+			return nil
+		}
+
+		return verifyLocalConfig(integrityData, lc.HMAC, hmacKey, lc.hmacKeyedByPassword())
+	})
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	if lc.Caching.CacheDirectory != "" && !filepath.IsAbs(lc.Caching.CacheDirectory) {
+		lc.Caching.CacheDirectory = filepath.Join(filepath.Dir(configFile), lc.Caching.CacheDirectory)
+	}
+
+	limitCachesToAvailableSpace(ctx, lc.Caching)
+	cacheFormatBlobBytes(ctx, lc.Caching.CacheDirectory, fb)
+
+	if st, err = wrapWithQuota(ctx, st, lc, options); err != nil {
+		return nil, err
+	}
+
+	if st, err = wrapWithBlobCache(ctx, st, lc.Caching); err != nil {
+		return nil, err
+	}
+
+	r, err := openWithUnlockedFormatBlob(ctx, st, u, options, *lc.Caching)
 	if err != nil {
 		st.Close(ctx) //nolint:errcheck
 		return nil, err
@@ -88,6 +142,7 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 
 	r.hostname = lc.Hostname
 	r.username = lc.Username
+	r.actionsEnabled = lc.EnableActions
 
 	if r.hostname == "" {
 		r.hostname = getDefaultHostName(ctx)
@@ -99,37 +154,210 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 
 	r.ConfigFile = configFile
 
+	if options.signLocalConfig {
+		if err := writeSignedLocalConfig(configFile, integrityData, r.localConfigKey); err != nil {
+			r.Close(ctx) //nolint:errcheck
+			return nil, err
+		}
+	}
+
 	return r, nil
 }
 
+// wrapWithQuota wraps the provided storage with quota enforcement, if configured.
+func wrapWithQuota(ctx context.Context, st blob.Storage, lc *LocalConfig, options *Options) (blob.Storage, error) {
+	if lc.StorageQuotaBytes <= 0 {
+		return st, nil
+	}
+
+	opt := quota.Options{
+		MaxBytes: lc.StorageQuotaBytes,
+		// only refuse new data, so that metadata can be written and the repository can be cleaned up when over quota.
+		EnforcedPrefixes: []blob.ID{content.PackBlobIDPrefixRegular},
+	}
+
+	if lc.Caching != nil && lc.Caching.CacheDirectory != "" {
+		if err := os.MkdirAll(lc.Caching.CacheDirectory, 0700); err != nil {
+			st.Close(ctx) //nolint:errcheck
+			return nil, errors.Wrap(err, "unable to create cache directory")
+		}
+
+		opt.LedgerFile = filepath.Join(lc.Caching.CacheDirectory, "quota-ledger.json")
+	}
+
+	qst, err := quota.NewWrapper(ctx, st, opt, options.TimeNowFunc)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, errors.Wrap(err, "unable to set up storage quota")
+	}
+
+	return qst, nil
+}
+
+// wrapWithBlobCache wraps the provided storage with local cache of recently used blobs, if enabled.
+func wrapWithBlobCache(ctx context.Context, st blob.Storage, opt *content.CachingOptions) (blob.Storage, error) {
+	if opt.MaxBlobCacheSizeBytes <= 0 || opt.CacheDirectory == "" {
+		return st, nil
+	}
+
+	blobCacheDir := filepath.Join(opt.CacheDirectory, "blobs")
+
+	if err := os.MkdirAll(blobCacheDir, 0700); err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, errors.Wrap(err, "unable to create blob cache directory")
+	}
+
+	cacheStorage, err := filesystem.New(ctxutil.Detach(ctx), &filesystem.Options{
+		Path:            blobCacheDir,
+		DirectoryShards: []int{2},
+	})
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, errors.Wrap(err, "unable to open blob cache")
+	}
+
+	cst, err := caching.NewWrapper(ctx, st, cacheStorage, opt.MaxBlobCacheSizeBytes, content.ImmutableBlobIDPrefixes)
+	if err != nil {
+		cacheStorage.Close(ctx) //nolint:errcheck
+		st.Close(ctx)           //nolint:errcheck
+
+		return nil, errors.Wrap(err, "unable to open blob cache")
+	}
+
+	return cst, nil
+}
+
 // OpenWithConfig opens the repository with a given configuration, avoiding the need for a config file.
 func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, password string, options *Options, caching content.CachingOptions) (*DirectRepository, error) {
+	return openWithConfig(ctx, st, lc, password, options, caching, true)
+}
+
+// openWithConfig opens the repository, if it can't be opened using a cached format blob, which is stale
+// after the encryption key has been rotated by another client, the format blob is read again from the storage.
+func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, password string, options *Options, caching content.CachingOptions, allowFormatBlobRefresh bool) (*DirectRepository, error) {
 	// Read format blob, potentially from cache.
 	fb, err := readAndCacheFormatBlobBytes(ctx, st, caching.CacheDirectory)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to read format blob")
 	}
 
+	u, err := unlockFormatBlob(ctx, fb, lc, password, options, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := openWithUnlockedFormatBlob(ctx, st, u, options, caching)
+	if err != nil {
+		if allowFormatBlobRefresh && caching.CacheDirectory != "" {
+			if rerr := os.Remove(filepath.Join(caching.CacheDirectory, FormatBlobID)); rerr == nil {
+				log(ctx).Debugf("unable to open repository, retrying with fresh format blob: %v", err)
+				return openWithConfig(ctx, st, lc, password, options, caching, false)
+			}
+		}
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// unlockedFormatBlob is the format blob of the repository with its master key unlocked.
+type unlockedFormatBlob struct {
+	formatBytes    []byte // format blob with checksum and length
+	f              *formatBlob
+	repoConfig     *repositoryObjectFormat
+	masterKey      []byte
+	credential     string
+	hardwareKey    HardwareKeyFunc
+	localConfigKey []byte // key of the HMAC of the local configuration
+}
+
+// unlockFormatBlob parses the format blob and unlocks its master key with the credentials specified by options
+// and the local configuration. When provided, verifyConfig is called with the HMAC key of the local configuration
+// before the key file or hardware key command from the configuration are used.
+func unlockFormatBlob(ctx context.Context, fb []byte, lc *LocalConfig, password string, options *Options, verifyConfig func(hmacKey []byte) error) (*unlockedFormatBlob, error) {
 	f, err := parseFormatBlob(fb)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't parse format blob")
 	}
 
+	if err := validateFIPSFormatBlob(f); err != nil {
+		return nil, errors.Wrap(err, "repository can't be used in FIPS mode")
+	}
+
 	fb, err = addFormatBlobChecksumAndLength(fb)
 	if err != nil {
 		return nil, errors.Errorf("unable to add checksum")
 	}
 
-	masterKey, err := f.deriveMasterKeyFromPassword(password)
+	u := &unlockedFormatBlob{formatBytes: fb, f: f}
+
+	if lc.hmacKeyedByPassword() {
+		key, err := f.deriveKeyFromPassword(password)
+		if err != nil {
+			return nil, err
+		}
+
+		u.localConfigKey = localConfigHMACKey(key, f.UniqueID)
+
+		if verifyConfig != nil {
+			if err := verifyConfig(u.localConfigKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	u.hardwareKey = options.HardwareKey
+	if u.hardwareKey == nil && lc.HardwareKeyCommand != "" {
+		u.hardwareKey = CommandHardwareKey(lc.HardwareKeyCommand)
+	}
+
+	switch {
+	case len(options.RecoveryShares) > 0:
+		u.masterKey, err = f.combineRecoveryShares(options.RecoveryShares)
+		u.credential = DefaultCredentialName
+	case options.RecoveryCode != "":
+		u.masterKey, u.credential, err = f.unwrapMasterKeyWithRecoveryCode(options.RecoveryCode)
+	case lc.UseKMS:
+		u.masterKey, u.credential, err = f.unwrapMasterKeyWithKMS(ctx)
+	case lc.KeyFile != "":
+		u.masterKey, u.credential, err = f.unwrapMasterKeyWithKeyFile(ctx, lc.KeyFile, password)
+	default:
+		u.masterKey, u.credential, err = f.deriveMasterKeyFromPassword(ctx, password, u.hardwareKey)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	repoConfig, err := f.decryptFormatBytes(masterKey)
+	repoConfig, err := f.decryptFormatBytes(u.masterKey)
 	if err != nil {
 		return nil, ErrInvalidPassword
 	}
 
+	u.repoConfig = repoConfig
+
+	if !lc.hmacKeyedByPassword() {
+		u.localConfigKey = localConfigHMACKey(u.masterKey, f.UniqueID)
+
+		if verifyConfig != nil {
+			if err := verifyConfig(u.localConfigKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return u, nil
+}
+
+// openWithUnlockedFormatBlob opens the repository using the unlocked format blob.
+func openWithUnlockedFormatBlob(ctx context.Context, st blob.Storage, u *unlockedFormatBlob, options *Options, caching content.CachingOptions) (*DirectRepository, error) {
+	f, masterKey, repoConfig := u.f, u.masterKey, u.repoConfig
+
+	if err := validateFIPSContentFormat(&repoConfig.FormattingOptions); err != nil {
+		return nil, errors.Wrap(err, "repository can't be used in FIPS mode")
+	}
+
 	caching.HMACSecret = deriveKeyFromMasterKey(masterKey, f.UniqueID, []byte("local-cache-integrity"), 16)
 
 	fo := &repoConfig.FormattingOptions
@@ -139,8 +367,17 @@ func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		fo.MaxPackSize = 20 << 20 // nolint:gomnd
 	}
 
+	// deletions are recorded once blob audit is enabled, so that they are not reported as missing blobs.
+	st = blobaudit.NewDeletionRecorder(st, blobaudit.SigningKey(func(purpose []byte, keyLength int) []byte {
+		return deriveKeyFromMasterKey(masterKey, f.UniqueID, purpose, keyLength)
+	}), defaultTime(options.TimeNowFunc))
+
+	// blob writes are throttled only when requested for the session, such as by the upload policy.
+	uploadLimiter := &throttling.Limiter{}
+	st = throttling.NewWrapper(st, uploadLimiter)
+
 	cmOpts := content.ManagerOptions{
-		RepositoryFormatBytes: fb,
+		RepositoryFormatBytes: u.formatBytes,
 		TimeNow:               defaultTime(options.TimeNowFunc),
 	}
 
@@ -154,22 +391,41 @@ func OpenWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, errors.Wrap(err, "unable to open object manager")
 	}
 
-	manifests, err := manifest.NewManager(ctx, cm, manifest.ManagerOptions{TimeNow: cmOpts.TimeNow})
+	al, err := audit.NewLog(st, deriveKeyFromMasterKey(masterKey, f.UniqueID, audit.KeyDerivationPurpose, audit.KeySize), cmOpts.TimeNow)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to open manifests")
+		return nil, errors.Wrap(err, "unable to open audit log")
 	}
 
-	return &DirectRepository{
-		Content:   cm,
-		Objects:   om,
-		Blobs:     st,
-		Manifests: manifests,
-		UniqueID:  f.UniqueID,
+	r := &DirectRepository{
+		Content:  cm,
+		Objects:  om,
+		Blobs:    st,
+		AuditLog: al,
+		UniqueID: f.UniqueID,
+
+		formatBlob:  f,
+		masterKey:   masterKey,
+		credential:  u.credential,
+		hardwareKey: u.hardwareKey,
+		timeNow:     cmOpts.TimeNow,
+
+		localConfigKey: u.localConfigKey,
+		uploadLimiter:  uploadLimiter,
+	}
+
+	r.Manifests, err = manifest.NewManager(ctx, cm, manifest.ManagerOptions{
+		TimeNow:       cmOpts.TimeNow,
+		RecordChanges: r.recordManifestChanges,
 
-		formatBlob: f,
-		masterKey:  masterKey,
-		timeNow:    cmOpts.TimeNow,
-	}, nil
+		// snapshot GC only keeps contents of existing snapshots, so restoring deleted snapshot
+		// would produce a snapshot with missing data. Manifests of the self-test are scratch data.
+		UnversionedTypes: []string{"snapshot", "selftest"},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open manifests")
+	}
+
+	return r, nil
 }
 
 // SetCachingConfig changes caching configuration for a given repository.
@@ -183,20 +439,16 @@ func (r *DirectRepository) SetCachingConfig(ctx context.Context, opt content.Cac
 		return errors.Wrap(err, "unable to set up caching")
 	}
 
-	d, err := json.MarshalIndent(&lc, "", "  ")
+	data, err := lc.integrityData()
 	if err != nil {
 		return err
 	}
 
-	if err := ioutil.WriteFile(r.ConfigFile, d, 0600); err != nil {
-		return nil
-	}
-
-	return nil
+	return writeSignedLocalConfig(r.ConfigFile, data, r.localConfigKey)
 }
 
 func readAndCacheFormatBlobBytes(ctx context.Context, st blob.Storage, cacheDirectory string) ([]byte, error) {
-	cachedFile := filepath.Join(cacheDirectory, "kopia.repository")
+	cachedFile := filepath.Join(cacheDirectory, FormatBlobID)
 
 	if cacheDirectory != "" {
 		if err := os.MkdirAll(cacheDirectory, 0700); err != nil && !os.IsExist(err) {
@@ -215,11 +467,22 @@ func readAndCacheFormatBlobBytes(ctx context.Context, st blob.Storage, cacheDire
 		return nil, err
 	}
 
-	if cacheDirectory != "" {
-		if err := atomic.WriteFile(cachedFile, bytes.NewReader(b)); err != nil {
-			log(ctx).Warningf("warning: unable to write cache: %v", err)
-		}
-	}
+	cacheFormatBlobBytes(ctx, cacheDirectory, b)
 
 	return b, nil
 }
+
+// cacheFormatBlobBytes stores the format blob in the cache directory, if caching is enabled.
+func cacheFormatBlobBytes(ctx context.Context, cacheDirectory string, b []byte) {
+	if cacheDirectory == "" {
+		return
+	}
+
+	if err := os.MkdirAll(cacheDirectory, 0700); err != nil && !os.IsExist(err) {
+		log(ctx).Warningf("unable to create cache directory: %v", err)
+	}
+
+	if err := atomic.WriteFile(filepath.Join(cacheDirectory, FormatBlobID), bytes.NewReader(b)); err != nil {
+		log(ctx).Warningf("warning: unable to write cache: %v", err)
+	}
+}
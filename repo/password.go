@@ -34,6 +34,11 @@ func GetPersistedPassword(ctx context.Context, configFile string) (string, bool)
 		s, err := base64.StdEncoding.DecodeString(string(b))
 		if err == nil {
 			log(ctx).Debugf("password for %v retrieved from password file", configFile)
+
+			if KeyRingEnabled {
+				movePasswordToKeyring(ctx, configFile, string(s))
+			}
+
 			return string(s), true
 		}
 	}
@@ -63,6 +68,22 @@ func persistPassword(ctx context.Context, configFile, password string) error {
 	return ioutil.WriteFile(fn, []byte(base64.StdEncoding.EncodeToString([]byte(password))), 0600)
 }
 
+// movePasswordToKeyring moves the password persisted in a file before the keyring was enabled to the keyring,
+// so that it's no longer stored in plaintext.
+func movePasswordToKeyring(ctx context.Context, configFile, password string) {
+	if err := keyring.Set(getKeyringItemID(configFile), keyringUsername(ctx), password); err != nil {
+		log(ctx).Warningf("unable to move password for %v to OS keyring: %v", configFile, err)
+		return
+	}
+
+	if err := os.Remove(passwordFileName(configFile)); err != nil {
+		log(ctx).Warningf("unable to remove password file: %v", err)
+		return
+	}
+
+	log(ctx).Infof("moved password for %v from password file to OS keyring.", configFile)
+}
+
 // deletePassword removes stored repository password.
 func deletePassword(ctx context.Context, configFile string) {
 	// delete from both keyring and a file
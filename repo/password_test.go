@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestMovePasswordToKeyring(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	keyring.MockInit()
+
+	dir, err := ioutil.TempDir("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	configFile := filepath.Join(dir, "repository.config")
+
+	if err = persistPassword(ctx, configFile, "some-password"); err != nil {
+		t.Fatalf("unable to persist password: %v", err)
+	}
+
+	KeyRingEnabled = true
+	defer func() { KeyRingEnabled = false }()
+
+	for i := 0; i < 2; i++ {
+		if got, ok := GetPersistedPassword(ctx, configFile); !ok || got != "some-password" {
+			t.Fatalf("unexpected persisted password: %v %v", got, ok)
+		}
+
+		if _, err := os.Stat(passwordFileName(configFile)); !os.IsNotExist(err) {
+			t.Errorf("password file was not removed: %v", err)
+		}
+	}
+
+	deletePassword(ctx, configFile)
+
+	if _, ok := GetPersistedPassword(ctx, configFile); ok {
+		t.Errorf("password was not deleted")
+	}
+}
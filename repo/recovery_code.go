@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RecoveryCodeCredentialName is the name of the recovery code credential created together with the repository.
+	RecoveryCodeCredentialName = "recovery-code"
+
+	recoveryCodeVersion    = 1
+	recoveryCodeSecretSize = 20
+	recoveryCodeKeySize    = 32
+	recoveryCodeGroupSize  = 5
+)
+
+var purposeRecoveryCode = []byte("RECOVERY-CODE")
+
+// ErrInvalidRecoveryCode is returned when the recovery code is malformed or does not unwrap any recovery code credential.
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+// GenerateRecoveryCode returns a new random recovery code, which consists of groups of letters and digits
+// that can be written down and typed back.
+func GenerateRecoveryCode() string {
+	var buf bytes.Buffer
+
+	buf.WriteByte(recoveryCodeVersion)
+	buf.Write(randomBytes(recoveryCodeSecretSize))
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes())) //nolint:errcheck
+
+	s := recoveryKeyEncoding.EncodeToString(buf.Bytes())
+
+	var groups []string
+
+	for len(s) > recoveryCodeGroupSize {
+		groups = append(groups, s[0:recoveryCodeGroupSize])
+		s = s[recoveryCodeGroupSize:]
+	}
+
+	return strings.Join(append(groups, s), "-")
+}
+
+// AddRecoveryCodeCredential adds a named credential that opens the repository using a new recovery code, which is returned.
+func (r *DirectRepository) AddRecoveryCodeCredential(ctx context.Context, name string) (string, error) {
+	code := GenerateRecoveryCode()
+
+	w, err := newRecoveryCodeWrappedMasterKey(name, code, r.masterKey, r.formatBlob.UniqueID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.addWrappedMasterKey(ctx, w); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// CredentialUsesRecoveryCode returns true if the credential with the provided name is protected by a recovery code.
+func (r *DirectRepository) CredentialUsesRecoveryCode(name string) bool {
+	for _, w := range r.formatBlob.WrappedMasterKeys {
+		if w.Name == name {
+			return w.RecoveryCode
+		}
+	}
+
+	return false
+}
+
+func newRecoveryCodeWrappedMasterKey(name, code string, masterKey, uniqueID []byte) (wrappedMasterKey, error) {
+	secret, err := decodeRecoveryCode(code)
+	if err != nil {
+		return wrappedMasterKey{}, err
+	}
+
+	wrapped, err := wrapMasterKey(recoveryCodeCredentialKey(secret, uniqueID), masterKey, uniqueID)
+	if err != nil {
+		return wrappedMasterKey{}, errors.Wrap(err, "unable to wrap master key")
+	}
+
+	return wrappedMasterKey{Name: name, RecoveryCode: true, Key: wrapped}, nil
+}
+
+// unwrapMasterKeyWithRecoveryCode returns the master key of the repository unwrapped with the recovery code.
+// Just like after combining recovery shares, the repository is considered opened with the default credential,
+// so that its forgotten password can be reset.
+func (f *formatBlob) unwrapMasterKeyWithRecoveryCode(code string) (masterKey []byte, credential string, err error) {
+	secret, err := decodeRecoveryCode(code)
+	if err != nil {
+		return nil, "", err
+	}
+
+	unwrapKey := recoveryCodeCredentialKey(secret, f.UniqueID)
+
+	for _, w := range f.WrappedMasterKeys {
+		if !w.RecoveryCode {
+			continue
+		}
+
+		if masterKey, err := unwrapMasterKey(unwrapKey, w.Key, f.UniqueID); err == nil {
+			return masterKey, DefaultCredentialName, nil
+		}
+	}
+
+	return nil, "", ErrInvalidRecoveryCode
+}
+
+// decodeRecoveryCode returns the secret of the recovery code, ignoring whitespace, dashes and case.
+func decodeRecoveryCode(code string) ([]byte, error) {
+	v, err := recoveryKeyEncoding.DecodeString(strings.ReplaceAll(normalizeRecoveryString(code), "-", ""))
+	if err != nil || len(v) != 1+recoveryCodeSecretSize+checksumSize {
+		return nil, errors.Wrap(ErrInvalidRecoveryCode, "malformed recovery code")
+	}
+
+	payload, checksum := v[:len(v)-checksumSize], v[len(v)-checksumSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(checksum) {
+		return nil, errors.Wrap(ErrInvalidRecoveryCode, "invalid recovery code checksum, mistyped?")
+	}
+
+	if payload[0] != recoveryCodeVersion {
+		return nil, errors.Errorf("unsupported recovery code version %v", payload[0])
+	}
+
+	return payload[1:], nil
+}
+
+func recoveryCodeCredentialKey(secret, uniqueID []byte) []byte {
+	return deriveKeyFromMasterKey(secret, uniqueID, purposeRecoveryCode, recoveryCodeKeySize)
+}
@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	recoveryKeyPrefix   = "KOPIA-RECOVERY-1-"
+	recoveryKeySaltSize = 16
+	recoveryKeySize     = 32
+)
+
+// recoveryKeyEncoding produces strings that can be easily written down and encoded as compact QR codes.
+var recoveryKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryKey contains information necessary to reconnect to a repository and restore its format blob
+// when local configuration is lost.
+type RecoveryKey struct {
+	Storage    blob.ConnectionInfo `json:"storage"`
+	FormatBlob []byte              `json:"format"`
+	MasterKey  []byte              `json:"masterKey"`
+}
+
+// ExportRecoveryKey returns a recovery key for the repository encrypted with the provided passphrase.
+func (r *DirectRepository) ExportRecoveryKey(passphrase string) (string, error) {
+	fb, err := json.Marshal(r.formatBlob)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal format blob")
+	}
+
+	return encryptRecoveryKey(&RecoveryKey{
+		Storage:    r.Blobs.ConnectionInfo(),
+		FormatBlob: fb,
+		MasterKey:  r.masterKey,
+	}, passphrase)
+}
+
+// DecryptRecoveryKey decrypts the recovery key produced by ExportRecoveryKey() using the provided passphrase.
+// Whitespace in the recovery key is ignored.
+func DecryptRecoveryKey(recoveryKey, passphrase string) (*RecoveryKey, error) {
+	recoveryKey = normalizeRecoveryString(recoveryKey)
+
+	if !strings.HasPrefix(recoveryKey, recoveryKeyPrefix) {
+		return nil, errors.New("not a recovery key")
+	}
+
+	v, err := recoveryKeyEncoding.DecodeString(strings.TrimPrefix(recoveryKey, recoveryKeyPrefix))
+	if err != nil {
+		return nil, errors.New("unable to decode recovery key")
+	}
+
+	if len(v) < recoveryKeySaltSize {
+		return nil, errors.New("recovery key too short")
+	}
+
+	aead, err := recoveryKeyCipher(passphrase, v[0:recoveryKeySaltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	v = v[recoveryKeySaltSize:]
+	if len(v) < aead.NonceSize() {
+		return nil, errors.New("recovery key too short")
+	}
+
+	compressed, err := aead.Open(nil, v[0:aead.NonceSize()], v[aead.NonceSize():], []byte(recoveryKeyPrefix))
+	if err != nil {
+		return nil, errors.New("unable to decrypt recovery key, invalid passphrase?")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid recovery key")
+	}
+
+	j, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid recovery key")
+	}
+
+	k := &RecoveryKey{}
+	if err := json.Unmarshal(j, k); err != nil {
+		return nil, errors.Wrap(err, "invalid recovery key")
+	}
+
+	return k, nil
+}
+
+// RestoreFormatBlob writes the format blob stored in the recovery key to the provided storage,
+// after verifying that it can be decrypted using the master key.
+func (k *RecoveryKey) RestoreFormatBlob(ctx context.Context, st blob.Storage) error {
+	f, err := parseFormatBlob(k.FormatBlob)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.decryptFormatBytes(k.MasterKey); err != nil {
+		return errors.Wrap(err, "recovery key is inconsistent")
+	}
+
+	if err := st.PutBlob(ctx, FormatBlobID, gather.FromSlice(k.FormatBlob)); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	return nil
+}
+
+// normalizeRecoveryString removes whitespace and converts to upper case the string that may have been written down.
+func normalizeRecoveryString(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+func encryptRecoveryKey(k *RecoveryKey, passphrase string) (string, error) {
+	var compressed bytes.Buffer
+
+	gz := gzip.NewWriter(&compressed)
+
+	if err := json.NewEncoder(gz).Encode(k); err != nil {
+		return "", errors.Wrap(err, "unable to marshal recovery key")
+	}
+
+	if err := gz.Close(); err != nil {
+		return "", errors.Wrap(err, "unable to compress recovery key")
+	}
+
+	salt := make([]byte, recoveryKeySaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", errors.Wrap(err, "unable to generate salt")
+	}
+
+	aead, err := recoveryKeyCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.Wrap(err, "unable to generate nonce")
+	}
+
+	result := append(append(salt, nonce...), aead.Seal(nil, nonce, compressed.Bytes(), []byte(recoveryKeyPrefix))...)
+
+	return recoveryKeyPrefix + recoveryKeyEncoding.EncodeToString(result), nil
+}
+
+func recoveryKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	// recovery keys are always protected using scrypt.
+	if err := validateFIPSKeyDerivation(KeyDerivationScrypt); err != nil {
+		return nil, errors.Wrap(err, "recovery keys can't be used in FIPS mode")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, 65536, 8, 1, recoveryKeySize) //nolint:gomnd
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive key")
+	}
+
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create cipher")
+	}
+
+	return cipher.NewGCM(blk)
+}
@@ -0,0 +1,191 @@
+package repo_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestRecoveryKey(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	k, err := env.Repository.ExportRecoveryKey("some-passphrase")
+	if err != nil {
+		t.Fatalf("unable to export recovery key: %v", err)
+	}
+
+	if _, err = repo.DecryptRecoveryKey(k, "wrong-passphrase"); err == nil {
+		t.Errorf("expected error when decrypting with wrong passphrase")
+	}
+
+	// whitespace is ignored, allowing the key to be split into multiple lines.
+	rk, err := repo.DecryptRecoveryKey(k[0:20]+"\n "+k[20:], "some-passphrase")
+	if err != nil {
+		t.Fatalf("unable to decrypt recovery key: %v", err)
+	}
+
+	if got, want := rk.Storage.Type, env.Repository.Blobs.ConnectionInfo().Type; got != want {
+		t.Errorf("unexpected storage type: %v, want %v", got, want)
+	}
+
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	if err := rk.RestoreFormatBlob(ctx, st); err != nil {
+		t.Fatalf("unable to restore format blob: %v", err)
+	}
+
+	if !bytes.Equal(data[repo.FormatBlobID], rk.FormatBlob) {
+		t.Errorf("format blob was not restored")
+	}
+
+	if _, err := repo.DecryptRecoveryKey(strings.Replace(k, "KOPIA", "KOPYA", 1), "some-passphrase"); err == nil {
+		t.Errorf("expected error when decrypting invalid recovery key")
+	}
+}
+
+func TestRecoveryShares(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	if _, err := env.Repository.ExportRecoveryShares(2, 3); err == nil {
+		t.Errorf("unexpected success exporting recovery shares with threshold above the number of shares")
+	}
+
+	shares, err := env.Repository.ExportRecoveryShares(5, 3)
+	if err != nil {
+		t.Fatalf("unable to export recovery shares: %v", err)
+	}
+
+	var otherEnv repotesting.Environment
+	defer otherEnv.Setup(t).Close(ctx, t)
+
+	otherShares, err := otherEnv.Repository.ExportRecoveryShares(5, 3)
+	if err != nil {
+		t.Fatalf("unable to export recovery shares: %v", err)
+	}
+
+	mistyped := shares[2][0:20] + "A" + shares[2][21:]
+	if mistyped == shares[2] {
+		mistyped = shares[2][0:20] + "B" + shares[2][21:]
+	}
+
+	configFile := env.Repository.ConfigFile
+
+	for _, invalid := range [][]string{
+		shares[0:2],
+		{shares[0], shares[1], mistyped},
+		{shares[0], shares[1], shares[1]},
+		{shares[0], shares[1], otherShares[2]},
+		{shares[0], shares[1], "KOPIA-RECOVERY-1-AAAA"},
+	} {
+		// failures to open are logged as errors, which would fail the test when using test logger.
+		if _, err = repo.Open(context.Background(), configFile, "wrong-password", &repo.Options{RecoveryShares: invalid}); err == nil {
+			t.Errorf("unexpected success opening repository with invalid recovery shares")
+		}
+	}
+
+	// whitespace and case are ignored and any 3 shares can be used.
+	env.MustReopen(t, func(o *repo.Options) {
+		o.RecoveryShares = []string{strings.ToLower(shares[4]), shares[1][0:10] + "\n" + shares[1][10:], shares[3]}
+	})
+
+	if got, want := env.Repository.CurrentCredential(), repo.DefaultCredentialName; got != want {
+		t.Errorf("unexpected current credential: %v, want %v", got, want)
+	}
+
+	// password can be reset after recovery.
+	if err := env.Repository.ChangePassword(ctx, "new-password"); err != nil {
+		t.Fatalf("unable to change password: %v", err)
+	}
+
+	r, err := repo.Open(context.Background(), configFile, "new-password", nil)
+	if err != nil {
+		t.Fatalf("unable to open repository with new password: %v", err)
+	}
+
+	r.Close(ctx) //nolint:errcheck
+}
+
+func TestRecoveryCode(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	code := repo.GenerateRecoveryCode()
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(o *repo.NewRepositoryOptions) { o.RecoveryCode = code }).Close(ctx, t)
+
+	if !env.Repository.CredentialUsesRecoveryCode(repo.RecoveryCodeCredentialName) {
+		t.Fatalf("recovery code credential was not created: %v", env.Repository.Credentials())
+	}
+
+	addedCode, err := env.Repository.AddRecoveryCodeCredential(ctx, "second-code")
+	if err != nil {
+		t.Fatalf("unable to add recovery code credential: %v", err)
+	}
+
+	mistyped := code[0:7] + "A" + code[8:]
+	if mistyped == code {
+		mistyped = code[0:7] + "B" + code[8:]
+	}
+
+	configFile := env.Repository.ConfigFile
+
+	for _, invalid := range []string{
+		mistyped,
+		code[0:20],
+		repo.GenerateRecoveryCode(),
+		// recovery code is not a password.
+		"",
+	} {
+		// failures to open are logged as errors, which would fail the test when using test logger.
+		if _, err = repo.Open(context.Background(), configFile, code, &repo.Options{RecoveryCode: invalid}); err == nil {
+			t.Errorf("unexpected success opening repository with invalid recovery code %q", invalid)
+		}
+	}
+
+	// dashes, whitespace and case are ignored.
+	env.MustReopen(t, func(o *repo.Options) {
+		o.RecoveryCode = strings.ToLower(strings.ReplaceAll(addedCode, "-", " "))
+	})
+
+	env.MustReopen(t, func(o *repo.Options) {
+		o.RecoveryCode = code
+	})
+
+	if got, want := env.Repository.CurrentCredential(), repo.DefaultCredentialName; got != want {
+		t.Errorf("unexpected current credential: %v, want %v", got, want)
+	}
+
+	// password can be reset after recovery.
+	if err := env.Repository.ChangePassword(ctx, "new-password"); err != nil {
+		t.Fatalf("unable to change password: %v", err)
+	}
+
+	r, err := repo.Open(context.Background(), configFile, "new-password", nil)
+	if err != nil {
+		t.Fatalf("unable to open repository with new password: %v", err)
+	}
+
+	r.Close(ctx) //nolint:errcheck
+
+	// revoked recovery code can't be used.
+	if err := env.Repository.RemoveCredential(ctx, repo.RecoveryCodeCredentialName); err != nil {
+		t.Fatalf("unable to remove recovery code credential: %v", err)
+	}
+
+	if _, err = repo.Open(context.Background(), configFile, "", &repo.Options{RecoveryCode: code}); err == nil {
+		t.Errorf("unexpected success opening repository with revoked recovery code")
+	}
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/shamir"
+)
+
+const (
+	recoverySharePrefix = "KOPIA-SHARE-1-"
+
+	// repositoryIDSize is the number of bytes of repository unique ID stored in each share
+	// to detect shares of different repositories.
+	repositoryIDSize = 4
+	checksumSize     = 4
+)
+
+// recoveryShareHeader precedes the share value in encoded recovery shares.
+type recoveryShareHeader struct {
+	Threshold    byte
+	RepositoryID [repositoryIDSize]byte
+}
+
+// ExportRecoveryShares splits the master key of the repository into n recovery shares, any threshold of which
+// can be used to open the repository when all passwords are lost.
+func (r *DirectRepository) ExportRecoveryShares(n, threshold int) ([]string, error) {
+	shares, err := shamir.Split(r.masterKey, n, threshold)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to split master key")
+	}
+
+	h := recoveryShareHeader{Threshold: byte(threshold)}
+	copy(h.RepositoryID[:], r.formatBlob.UniqueID)
+
+	var result []string
+
+	for _, s := range shares {
+		var buf bytes.Buffer
+
+		binary.Write(&buf, binary.BigEndian, h) //nolint:errcheck
+		buf.Write(s)
+		binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes())) //nolint:errcheck
+
+		result = append(result, recoverySharePrefix+recoveryKeyEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	return result, nil
+}
+
+// combineRecoveryShares reconstructs the master key of the repository from recovery shares
+// produced by ExportRecoveryShares.
+func (f *formatBlob) combineRecoveryShares(encoded []string) ([]byte, error) {
+	var (
+		shares    [][]byte
+		threshold int
+	)
+
+	for _, e := range encoded {
+		h, share, err := decodeRecoveryShare(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasPrefix(f.UniqueID, h.RepositoryID[:]) {
+			return nil, errors.New("recovery share belongs to a different repository")
+		}
+
+		threshold = int(h.Threshold)
+		shares = append(shares, share)
+	}
+
+	if len(shares) < threshold {
+		return nil, errors.Errorf("not enough recovery shares, %v are required", threshold)
+	}
+
+	masterKey, err := shamir.Combine(shares)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to combine recovery shares")
+	}
+
+	if _, err := f.decryptFormatBytes(masterKey); err != nil {
+		return nil, errors.New("invalid recovery shares")
+	}
+
+	return masterKey, nil
+}
+
+func decodeRecoveryShare(s string) (recoveryShareHeader, []byte, error) {
+	var h recoveryShareHeader
+
+	s = normalizeRecoveryString(s)
+
+	if !strings.HasPrefix(s, recoverySharePrefix) {
+		return h, nil, errors.New("not a recovery share")
+	}
+
+	v, err := recoveryKeyEncoding.DecodeString(strings.TrimPrefix(s, recoverySharePrefix))
+	if err != nil {
+		return h, nil, errors.New("unable to decode recovery share")
+	}
+
+	headerSize := binary.Size(h)
+
+	if len(v) <= headerSize+checksumSize {
+		return h, nil, errors.New("recovery share too short")
+	}
+
+	payload, checksum := v[:len(v)-checksumSize], v[len(v)-checksumSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(checksum) {
+		return h, nil, errors.New("invalid recovery share checksum, mistyped?")
+	}
+
+	if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, &h); err != nil {
+		return h, nil, errors.Wrap(err, "invalid recovery share")
+	}
+
+	return h, payload[headerSize:], nil
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/repo/audit"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -20,12 +22,16 @@ type Repository interface {
 
 	GetManifest(ctx context.Context, id manifest.ID, data interface{}) (*manifest.EntryMetadata, error)
 	PutManifest(ctx context.Context, labels map[string]string, payload interface{}) (manifest.ID, error)
+	PutManifestIfMatch(ctx context.Context, labels map[string]string, payload interface{}, expected []manifest.ID) (manifest.ID, error)
 	FindManifests(ctx context.Context, labels map[string]string) ([]*manifest.EntryMetadata, error)
 	DeleteManifest(ctx context.Context, id manifest.ID) error
 
 	Hostname() string
 	Username() string
 
+	// ActionsEnabled returns true if snapshot actions defined in policies may run for this connection.
+	ActionsEnabled() bool
+
 	Time() time.Time
 
 	Refresh(ctx context.Context) error
@@ -39,6 +45,7 @@ type DirectRepository struct {
 	Content   *content.Manager
 	Objects   *object.Manager
 	Manifests *manifest.Manager
+	AuditLog  *audit.Log
 	UniqueID  []byte
 
 	ConfigFile string
@@ -46,9 +53,18 @@ type DirectRepository struct {
 	hostname string // connected (localhost) hostname
 	username string // connected username
 
+	actionsEnabled bool // snapshot actions may run for this connection
+
 	timeNow    func() time.Time
 	formatBlob *formatBlob
 	masterKey  []byte
+	credential string // name of the credential used to unlock the master key
+
+	hardwareKey HardwareKeyFunc
+
+	localConfigKey []byte // key of the HMAC protecting the local config
+
+	uploadLimiter *throttling.Limiter
 }
 
 // DeriveKey derives encryption key of the provided length from the master key.
@@ -56,12 +72,29 @@ func (r *DirectRepository) DeriveKey(purpose []byte, keyLength int) []byte {
 	return deriveKeyFromMasterKey(r.masterKey, r.UniqueID, purpose, keyLength)
 }
 
+// recordManifestChanges appends changes to manifests to the audit log along with the identity of the client,
+// if the audit log was enabled for the repository.
+func (r *DirectRepository) recordManifestChanges(ctx context.Context, changes []manifest.Change) error {
+	return r.AuditLog.Append(ctx, r.username, r.hostname, changes)
+}
+
+// ActionsEnabled returns true if the connection was configured to run snapshot actions defined in policies.
+func (r *DirectRepository) ActionsEnabled() bool { return r.actionsEnabled }
+
 // Hostname returns the hostname that connected to the repository.
 func (r *DirectRepository) Hostname() string { return r.hostname }
 
 // Username returns the username that's connect to the repository.
 func (r *DirectRepository) Username() string { return r.username }
 
+// FormatEncryption returns the algorithm used to encrypt the repository format blob.
+func (r *DirectRepository) FormatEncryption() string { return r.formatBlob.EncryptionAlgorithm }
+
+// KeyDerivationAlgorithm returns the algorithm used to derive the master key from the password.
+func (r *DirectRepository) KeyDerivationAlgorithm() string {
+	return r.formatBlob.KeyDerivationAlgorithm
+}
+
 // BlobStorage returns the blob storage.
 func (r *DirectRepository) BlobStorage() blob.Storage {
 	return r.Blobs
@@ -102,6 +135,13 @@ func (r *DirectRepository) PutManifest(ctx context.Context, labels map[string]st
 	return r.Manifests.Put(ctx, labels, payload)
 }
 
+// PutManifestIfMatch saves the given manifest payload replacing manifests matching the set of labels,
+// but only if they are exactly the expected ones, otherwise returns manifest.ErrConflict.
+// The manifest is flushed to the storage before returning.
+func (r *DirectRepository) PutManifestIfMatch(ctx context.Context, labels map[string]string, payload interface{}, expected []manifest.ID) (manifest.ID, error) {
+	return r.Manifests.PutIfMatch(ctx, labels, payload, expected)
+}
+
 // FindManifests returns metadata for manifests matching given set of labels.
 func (r *DirectRepository) FindManifests(ctx context.Context, labels map[string]string) ([]*manifest.EntryMetadata, error) {
 	return r.Manifests.Find(ctx, labels)
@@ -112,6 +152,11 @@ func (r *DirectRepository) DeleteManifest(ctx context.Context, id manifest.ID) e
 	return r.Manifests.Delete(ctx, id)
 }
 
+// SetMaxUploadBytesPerSecond limits the rate at which blobs are written to the storage, 0 is unlimited.
+func (r *DirectRepository) SetMaxUploadBytesPerSecond(rate int64) {
+	r.uploadLimiter.SetMaxBytesPerSecond(rate)
+}
+
 // Close closes the repository and releases all resources.
 func (r *DirectRepository) Close(ctx context.Context) error {
 	if err := r.Flush(ctx); err != nil {
@@ -3,14 +3,29 @@ package repo_test
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"runtime/debug"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	_ "gocloud.dev/secrets/localsecrets"
+
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -319,3 +334,758 @@ func TestFormats(t *testing.T) {
 		}
 	}
 }
+
+func TestArgon2idKeyDerivation(t *testing.T) {
+	ctx := testlogging.Context(t)
+	algo := repo.Argon2idKeyDerivationAlgorithm(1024, 1, 2)
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(n *repo.NewRepositoryOptions) {
+		n.KeyDerivationAlgorithm = algo
+	}).Close(ctx, t)
+
+	if got := env.Repository.KeyDerivationAlgorithm(); got != algo {
+		t.Errorf("unexpected key derivation algorithm: %v, want %v", got, algo)
+	}
+
+	env.MustReopen(t)
+
+	if got := env.Repository.KeyDerivationAlgorithm(); got != algo {
+		t.Errorf("unexpected key derivation algorithm after reopen: %v, want %v", got, algo)
+	}
+}
+
+func TestPBKDF2KeyDerivation(t *testing.T) {
+	ctx := testlogging.Context(t)
+	algo := repo.PBKDF2KeyDerivationAlgorithm(1000)
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(n *repo.NewRepositoryOptions) {
+		n.KeyDerivationAlgorithm = algo
+	}).Close(ctx, t)
+
+	if got := env.Repository.KeyDerivationAlgorithm(); got != algo {
+		t.Errorf("unexpected key derivation algorithm: %v, want %v", got, algo)
+	}
+
+	env.MustReopen(t)
+
+	if got := env.Repository.KeyDerivationAlgorithm(); got != algo {
+		t.Errorf("unexpected key derivation algorithm after reopen: %v, want %v", got, algo)
+	}
+}
+
+func TestScryptKeyDerivation(t *testing.T) {
+	ctx := testlogging.Context(t)
+	algo := repo.ScryptKeyDerivationAlgorithm(1024, 8, 1)
+
+	var env repotesting.Environment
+	defer env.Setup(t, func(n *repo.NewRepositoryOptions) {
+		n.KeyDerivationAlgorithm = algo
+	}).Close(ctx, t)
+
+	env.MustReopen(t)
+
+	if got := env.Repository.KeyDerivationAlgorithm(); got != algo {
+		t.Errorf("unexpected key derivation algorithm after reopen: %v, want %v", got, algo)
+	}
+}
+
+func TestBenchmarkKeyDerivation(t *testing.T) {
+	if _, err := repo.BenchmarkKeyDerivation("md5", time.Second); err == nil {
+		t.Errorf("unexpected success benchmarking unsupported key derivation")
+	}
+
+	// parameters are never weaker than the defaults.
+	b, err := repo.BenchmarkKeyDerivation(repo.KeyDerivationKindScrypt, time.Millisecond)
+	if err != nil {
+		t.Fatalf("unable to benchmark key derivation: %v", err)
+	}
+
+	if got, want := b.Algorithm, repo.KeyDerivationScrypt; got != want {
+		t.Errorf("unexpected algorithm: %v, want %v", got, want)
+	}
+
+	// cost grows with the target duration.
+	b, err = repo.BenchmarkKeyDerivation(repo.KeyDerivationKindPBKDF2, 3*b.Duration+500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unable to benchmark key derivation: %v", err)
+	}
+
+	if err := repo.ValidateKeyDerivationAlgorithm(b.Algorithm); err != nil {
+		t.Errorf("invalid algorithm %v: %v", b.Algorithm, err)
+	}
+
+	if b.Algorithm == repo.PBKDF2KeyDerivationAlgorithm(repo.DefaultPBKDF2Iterations) {
+		t.Errorf("unexpected default parameters")
+	}
+}
+
+func TestValidateKeyDerivationAlgorithm(t *testing.T) {
+	cases := map[string]bool{
+		repo.KeyDerivationScrypt:                         true,
+		repo.Argon2idKeyDerivationAlgorithm(65536, 3, 4): true,
+		"argon2id-65536-3":                               false,
+		"argon2id-0-3-4":                                 false,
+		"argon2id-65536-3-300":                           false,
+		"argon2id-a-b-c":                                 false,
+		repo.PBKDF2KeyDerivationAlgorithm(600000):        true,
+		"pbkdf2-sha256-0":                                false,
+		"pbkdf2-sha256-x":                                false,
+		"scrypt-1-2-3":                                   false,
+		"scrypt-1000-8-1":                                false,
+		"scrypt-1024-8":                                  false,
+		repo.ScryptKeyDerivationAlgorithm(1024, 8, 1):    true,
+		"": false,
+	}
+
+	for algo, valid := range cases {
+		if err := repo.ValidateKeyDerivationAlgorithm(algo); (err == nil) != valid {
+			t.Errorf("unexpected validation result for %q: %v", algo, err)
+		}
+	}
+}
+
+func TestValidateFIPSOptions(t *testing.T) {
+	fipsOptions := &repo.NewRepositoryOptions{
+		BlockFormat:            content.FormattingOptions{Hash: "HMAC-SHA256-128"},
+		KeyDerivationAlgorithm: repo.PBKDF2KeyDerivationAlgorithm(1000),
+	}
+
+	if err := repo.ValidateFIPSOptions(nil); err != nil {
+		t.Errorf("unexpected error without FIPS mode: %v", err)
+	}
+
+	repo.SetFIPSMode(true)
+	defer repo.SetFIPSMode(false)
+
+	cases := map[*repo.NewRepositoryOptions]bool{
+		nil:         false,
+		fipsOptions: true,
+		{
+			BlockFormat:            content.FormattingOptions{Hash: "HMAC-SHA256-128", Encryption: "CHACHA20-POLY1305-HMAC-SHA256"},
+			KeyDerivationAlgorithm: repo.PBKDF2KeyDerivationAlgorithm(1000),
+		}: false,
+		{
+			BlockFormat:            content.FormattingOptions{Hash: "HMAC-SHA256-128"},
+			KeyDerivationAlgorithm: repo.KeyDerivationScrypt,
+		}: false,
+		{
+			BlockFormat:            content.FormattingOptions{Hash: "HMAC-SHA256-128"},
+			FormatEncryption:       repo.FormatEncryptionChaCha20Poly1305,
+			KeyDerivationAlgorithm: repo.PBKDF2KeyDerivationAlgorithm(1000),
+		}: false,
+	}
+
+	for opt, valid := range cases {
+		err := repo.ValidateFIPSOptions(opt)
+		if (err == nil) != valid {
+			t.Errorf("unexpected validation result for %+v: %v", opt, err)
+		}
+
+		if err != nil && errors.Cause(err) != repo.ErrNotFIPSApproved {
+			t.Errorf("unexpected error for %+v: %v", opt, err)
+		}
+	}
+
+	if err := repo.ValidateKeyDerivationAlgorithm(repo.KeyDerivationScrypt); errors.Cause(err) != repo.ErrNotFIPSApproved {
+		t.Errorf("unexpected error validating scrypt in FIPS mode: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	w := env.Repository.NewObjectWriter(ctx, object.WriterOptions{})
+	w.Write([]byte("hello world")) //nolint:errcheck
+
+	oid, err := w.Result()
+	if err != nil {
+		t.Fatalf("unable to write object: %v", err)
+	}
+
+	if err = env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("unable to flush: %v", err)
+	}
+
+	schedKey := env.Repository.DeriveKey([]byte("some-purpose"), 16)
+
+	if err = env.Repository.ChangePassword(ctx, "new-password"); err != nil {
+		t.Fatalf("unable to change password: %v", err)
+	}
+
+	// failures to open are logged as errors, which would fail the test when using test logger.
+	if _, err = repo.Open(context.Background(), env.Repository.ConfigFile, "foobarbazfoobarbaz", nil); err != repo.ErrInvalidPassword {
+		t.Fatalf("unexpected error opening repository with old password: %v", err)
+	}
+
+	r, err := repo.Open(ctx, env.Repository.ConfigFile, "new-password", nil)
+	if err != nil {
+		t.Fatalf("unable to open repository with new password: %v", err)
+	}
+	defer r.Close(ctx) //nolint:errcheck
+
+	verify(ctx, t, r, oid, []byte("hello world"), "after password change")
+
+	// keys derived from the master key must not change.
+	if got := r.(*repo.DirectRepository).DeriveKey([]byte("some-purpose"), 16); !bytes.Equal(got, schedKey) {
+		t.Errorf("derived key has changed after password change")
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	if got, want := env.Repository.Credentials(), []string{repo.DefaultCredentialName}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected credentials: %v, want %v", got, want)
+	}
+
+	if err := env.Repository.AddCredential(ctx, "alice", "alice-password"); err != nil {
+		t.Fatalf("unable to add credential: %v", err)
+	}
+
+	if err := env.Repository.AddCredential(ctx, "alice", "other-password"); err == nil {
+		t.Errorf("unexpected success adding duplicate credential")
+	}
+
+	r, err := repo.Open(ctx, env.Repository.ConfigFile, "alice-password", nil)
+	if err != nil {
+		t.Fatalf("unable to open repository with added credential: %v", err)
+	}
+
+	alice := r.(*repo.DirectRepository)
+
+	if got, want := alice.CurrentCredential(), "alice"; got != want {
+		t.Errorf("unexpected current credential: %v, want %v", got, want)
+	}
+
+	if got, want := alice.Credentials(), []string{repo.DefaultCredentialName, "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected credentials: %v, want %v", got, want)
+	}
+
+	if err = alice.RemoveCredential(ctx, "alice"); err == nil {
+		t.Errorf("unexpected success removing credential used by the connection")
+	}
+
+	alice.Close(ctx) //nolint:errcheck
+
+	if err = env.Repository.RemoveCredential(ctx, "no-such-credential"); err != repo.ErrCredentialNotFound {
+		t.Errorf("unexpected error removing non-existent credential: %v", err)
+	}
+
+	if err = env.Repository.RemoveCredential(ctx, "alice"); err != nil {
+		t.Fatalf("unable to remove credential: %v", err)
+	}
+
+	// failures to open are logged as errors, which would fail the test when using test logger.
+	if _, err = repo.Open(context.Background(), env.Repository.ConfigFile, "alice-password", nil); err != repo.ErrInvalidPassword {
+		t.Errorf("unexpected error opening repository with removed credential: %v", err)
+	}
+
+	env.MustReopen(t)
+
+	if got, want := env.Repository.Credentials(), []string{repo.DefaultCredentialName}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected credentials after removal: %v, want %v", got, want)
+	}
+}
+
+func TestConcurrentFormatBlobUpdates(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	stale := env.MustOpenAnother(t).(*repo.DirectRepository)
+	defer stale.Close(ctx) //nolint:errcheck
+
+	if err := env.Repository.AddCredential(ctx, "alice", "alice-password"); err != nil {
+		t.Fatalf("unable to add credential: %v", err)
+	}
+
+	// credential added by the other client is preserved.
+	if err := stale.AddCredential(ctx, "bob", "bob-password"); err != nil {
+		t.Fatalf("unable to add credential: %v", err)
+	}
+
+	env.MustReopen(t)
+
+	if got, want := env.Repository.Credentials(), []string{repo.DefaultCredentialName, "alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected credentials: %v, want %v", got, want)
+	}
+
+	// format blob replaced by another client right after it was written is detected.
+	env.Repository.Blobs = formatBlobRacingStorage{env.Repository.Blobs}
+
+	if err := env.Repository.AddCredential(ctx, "carol", "carol-password"); errors.Cause(err) != repo.ErrFormatBlobModified {
+		t.Errorf("unexpected error: %v, want %v", err, repo.ErrFormatBlobModified)
+	}
+}
+
+func TestConcurrentKeyRotation(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	stale := env.MustOpenAnother(t).(*repo.DirectRepository)
+	defer stale.Close(ctx) //nolint:errcheck
+
+	if epoch, err := env.Repository.RotateEncryptionKey(ctx, ""); err != nil || epoch != 1 {
+		t.Fatalf("unable to rotate key: %v %v", epoch, err)
+	}
+
+	// contents are written with the key of the first rotation.
+	env.MustReopen(t)
+
+	cid, err := env.Repository.Content.WriteContent(ctx, []byte("written with epoch 1"), "")
+	if err != nil {
+		t.Fatalf("unable to write content: %v", err)
+	}
+
+	if err = env.Repository.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	// rotation by a client that opened the repository earlier keeps the key of the first rotation.
+	if epoch, err := stale.RotateEncryptionKey(ctx, ""); err != nil || epoch != 2 {
+		t.Fatalf("unable to rotate key: %v %v", epoch, err)
+	}
+
+	env.MustReopen(t)
+
+	if got, want := env.Repository.Content.Format.CurrentKeyEpoch(), 2; got != want {
+		t.Errorf("unexpected key epoch: %v, want %v", got, want)
+	}
+
+	if _, err := env.Repository.Content.GetContent(ctx, cid); err != nil {
+		t.Errorf("unable to read content encrypted with the key of the first rotation: %v", err)
+	}
+}
+
+// formatBlobRacingStorage simulates another client writing the format blob right after each write.
+type formatBlobRacingStorage struct {
+	blob.Storage
+}
+
+func (s formatBlobRacingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if err := s.Storage.PutBlob(ctx, id, data); err != nil || id != repo.FormatBlobID {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	data.WriteTo(&buf) //nolint:errcheck
+
+	return s.Storage.PutBlob(ctx, id, gather.FromSlice(append(buf.Bytes(), ' ')))
+}
+
+func TestKMSCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	// local key is used in place of the key stored in KMS.
+	keyURL := "base64key://" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 32))
+
+	if err := env.Repository.AddKMSCredential(ctx, "kms", keyURL); err != nil {
+		t.Fatalf("unable to add KMS credential: %v", err)
+	}
+
+	if got, want := env.Repository.CredentialKMSKeyURL("kms"), keyURL; got != want {
+		t.Errorf("unexpected KMS key URL: %v, want %v", got, want)
+	}
+
+	if got := env.Repository.CredentialKMSKeyURL(repo.DefaultCredentialName); got != "" {
+		t.Errorf("unexpected KMS key URL of password credential: %v", got)
+	}
+
+	configDir, err := ioutil.TempDir("", "kms")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(configDir) //nolint:errcheck
+
+	configFile := filepath.Join(configDir, "kms.config")
+
+	if err = repo.Connect(ctx, configFile, env.Repository.Blobs, "", &repo.ConnectOptions{UseKMS: true}); err != nil {
+		t.Fatalf("unable to connect using KMS: %v", err)
+	}
+
+	defer repo.Disconnect(ctx, configFile) //nolint:errcheck
+
+	r, err := repo.Open(ctx, configFile, "", nil)
+	if err != nil {
+		t.Fatalf("unable to open using KMS: %v", err)
+	}
+
+	kr := r.(*repo.DirectRepository)
+
+	if got, want := kr.CurrentCredential(), "kms"; got != want {
+		t.Errorf("unexpected current credential: %v, want %v", got, want)
+	}
+
+	if err = kr.ChangePassword(ctx, "some-password"); err == nil {
+		t.Errorf("unexpected success changing password of KMS credential")
+	}
+
+	kr.Close(ctx) //nolint:errcheck
+
+	if err = env.Repository.RemoveCredential(ctx, "kms"); err != nil {
+		t.Fatalf("unable to remove credential: %v", err)
+	}
+
+	// failures to open are logged as errors, which would fail the test when using test logger.
+	if _, err = repo.Open(context.Background(), configFile, "", nil); err != repo.ErrNoKMSCredentials {
+		t.Errorf("unexpected error opening repository with removed KMS credential: %v", err)
+	}
+
+	// the password still works.
+	env.MustReopen(t)
+}
+
+func TestLocalConfigIntegrity(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	configFile := env.Repository.ConfigFile
+
+	if err := env.Repository.SetCachingConfig(ctx, content.CachingOptions{MaxCacheSizeBytes: 1 << 20}); err != nil {
+		t.Fatalf("unable to set caching config: %v", err)
+	}
+
+	// changes made by kopia are signed.
+	env.MustReopen(t)
+
+	original, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modifyConfig := func(modify func(m map[string]interface{})) {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(original, &m); err != nil {
+			t.Fatal(err)
+		}
+
+		modify(m)
+
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(configFile, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modifyConfig(func(m map[string]interface{}) {
+		m["caching"] = map[string]interface{}{"cacheDirectory": "/tmp/malicious"}
+	})
+
+	// failures to open are logged as errors, which would fail the test when using test logger.
+	if _, err := repo.Open(context.Background(), configFile, "foobarbazfoobarbaz", nil); err != repo.ErrLocalConfigTampered {
+		t.Errorf("unexpected error opening repository with tampered config: %v", err)
+	}
+
+	// hardware key command is not run before the configuration is verified.
+	marker := filepath.Join(filepath.Dir(configFile), "marker")
+
+	modifyConfig(func(m map[string]interface{}) {
+		m["hardwareKeyCommand"] = "touch " + marker
+	})
+
+	if _, err := repo.Open(context.Background(), configFile, "foobarbazfoobarbaz", nil); err != repo.ErrInvalidPasswordOrLocalConfigTampered {
+		t.Errorf("unexpected error opening repository with injected hardware key command: %v", err)
+	}
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("hardware key command from tampered config was run: %v", err)
+	}
+
+	// configuration without HMAC, such as written by previous versions, must be reconnected.
+	modifyConfig(func(m map[string]interface{}) {
+		delete(m, "hmac")
+	})
+
+	if _, err := repo.Open(context.Background(), configFile, "foobarbazfoobarbaz", nil); err != repo.ErrLocalConfigNotSigned {
+		t.Errorf("unexpected error opening repository with unsigned config: %v", err)
+	}
+
+	modifyConfig(func(m map[string]interface{}) {})
+	env.MustReopen(t)
+}
+
+func TestHardwareKeyCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	if err := env.Repository.AddHardwareKeyCredential(ctx, "yubikey", "yubikey-password"); err != repo.ErrHardwareKeyRequired {
+		t.Fatalf("unexpected error adding hardware key credential without hardware key: %v", err)
+	}
+
+	device := fakeHardwareKey("device-secret")
+	otherDevice := fakeHardwareKey("other-secret")
+
+	env.MustReopen(t, func(o *repo.Options) { o.HardwareKey = device })
+
+	if err := env.Repository.AddHardwareKeyCredential(ctx, "yubikey", "yubikey-password"); err != nil {
+		t.Fatalf("unable to add hardware key credential: %v", err)
+	}
+
+	if !env.Repository.CredentialUsesHardwareKey("yubikey") {
+		t.Errorf("credential does not use hardware key")
+	}
+
+	// failures to open are logged as errors, which would fail the test when using test logger.
+	for _, o := range []*repo.Options{nil, {HardwareKey: otherDevice}} {
+		if _, err := repo.Open(context.Background(), env.Repository.ConfigFile, "yubikey-password", o); err != repo.ErrInvalidPassword {
+			t.Errorf("unexpected error opening without the hardware key: %v", err)
+		}
+	}
+
+	if _, err := repo.Open(context.Background(), env.Repository.ConfigFile, "wrong-password", &repo.Options{HardwareKey: device}); err != repo.ErrInvalidPassword {
+		t.Errorf("unexpected error opening with wrong password: %v", err)
+	}
+
+	r, err := repo.Open(ctx, env.Repository.ConfigFile, "yubikey-password", &repo.Options{HardwareKey: device})
+	if err != nil {
+		t.Fatalf("unable to open with hardware key: %v", err)
+	}
+
+	hr := r.(*repo.DirectRepository)
+
+	if got, want := hr.CurrentCredential(), "yubikey"; got != want {
+		t.Errorf("unexpected current credential: %v, want %v", got, want)
+	}
+
+	// changing password keeps the requirement of the hardware key.
+	if err = hr.ChangePassword(ctx, "new-yubikey-password"); err != nil {
+		t.Fatalf("unable to change password: %v", err)
+	}
+
+	hr.Close(ctx) //nolint:errcheck
+
+	if _, err = repo.Open(context.Background(), env.Repository.ConfigFile, "new-yubikey-password", nil); err != repo.ErrInvalidPassword {
+		t.Errorf("unexpected error opening without the hardware key: %v", err)
+	}
+
+	r, err = repo.Open(ctx, env.Repository.ConfigFile, "new-yubikey-password", &repo.Options{HardwareKey: device})
+	if err != nil {
+		t.Fatalf("unable to open with hardware key after password change: %v", err)
+	}
+
+	r.Close(ctx) //nolint:errcheck
+}
+
+// fakeHardwareKey returns HardwareKeyFunc which computes HMAC of the challenge with the provided secret,
+// similar to challenge-response of a YubiKey.
+func fakeHardwareKey(secret string) repo.HardwareKeyFunc {
+	return func(ctx context.Context, challenge []byte) ([]byte, error) {
+		h := hmac.New(sha1.New, []byte(secret))
+		h.Write(challenge) //nolint:errcheck
+
+		return h.Sum(nil), nil
+	}
+}
+
+func TestCommandHardwareKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires 'echo' command")
+	}
+
+	ctx := testlogging.Context(t)
+
+	// echo responds with the challenge itself.
+	resp, err := repo.CommandHardwareKey("echo")(ctx, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := resp, []byte{1, 2, 3}; !bytes.Equal(got, want) {
+		t.Errorf("unexpected response: %x, want %x", got, want)
+	}
+
+	if _, err := repo.CommandHardwareKey("false")(ctx, []byte{1, 2, 3}); err == nil {
+		t.Errorf("unexpected success from failing command")
+	}
+
+	if _, err := repo.CommandHardwareKey("echo not-hex")(ctx, []byte{1, 2, 3}); err == nil {
+		t.Errorf("unexpected success from invalid response")
+	}
+}
+
+func TestKeyFileCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var env repotesting.Environment
+	defer env.Setup(t).Close(ctx, t)
+
+	keyDir, err := ioutil.TempDir("", "keyfile")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(keyDir) //nolint:errcheck
+
+	keyFile := filepath.Join(keyDir, "unprotected.key")
+	protectedKeyFile := filepath.Join(keyDir, "protected.key")
+	otherKeyFile := filepath.Join(keyDir, "other.key")
+
+	for _, kf := range []struct{ path, passphrase string }{
+		{keyFile, ""},
+		{protectedKeyFile, "key-file-passphrase"},
+		{otherKeyFile, ""},
+	} {
+		if err = repo.GenerateKeyFile(kf.path, kf.passphrase); err != nil {
+			t.Fatalf("unable to generate key file: %v", err)
+		}
+
+		protected, err := repo.KeyFileRequiresPassphrase(kf.path)
+		if err != nil {
+			t.Fatalf("unable to read key file: %v", err)
+		}
+
+		if got, want := protected, kf.passphrase != ""; got != want {
+			t.Errorf("unexpected protection of %v: %v, want %v", kf.path, got, want)
+		}
+	}
+
+	if err = repo.GenerateKeyFile(keyFile, ""); err == nil {
+		t.Errorf("unexpected success overwriting existing key file")
+	}
+
+	if err = env.Repository.AddKeyFileCredential(ctx, "keyfile", keyFile, ""); err != nil {
+		t.Fatalf("unable to add key file credential: %v", err)
+	}
+
+	if err = env.Repository.AddKeyFileCredential(ctx, "protected", protectedKeyFile, "wrong-passphrase"); errors.Cause(err) != repo.ErrInvalidKeyFile {
+		t.Fatalf("unexpected error adding key file credential with wrong passphrase: %v", err)
+	}
+
+	if err = env.Repository.AddKeyFileCredential(ctx, "protected", protectedKeyFile, "key-file-passphrase"); err != nil {
+		t.Fatalf("unable to add protected key file credential: %v", err)
+	}
+
+	if !env.Repository.CredentialUsesKeyFile("keyfile") || env.Repository.CredentialUsesKeyFile(repo.DefaultCredentialName) {
+		t.Errorf("unexpected key file credentials")
+	}
+
+	configDir, err := ioutil.TempDir("", "keyfile-config")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(configDir) //nolint:errcheck
+
+	cases := []struct {
+		keyFile    string
+		passphrase string
+		credential string
+		wantErr    error
+	}{
+		{keyFile, "", "keyfile", nil},
+		{protectedKeyFile, "key-file-passphrase", "protected", nil},
+		{protectedKeyFile, "wrong-passphrase", "", repo.ErrInvalidKeyFile},
+		{otherKeyFile, "", "", repo.ErrInvalidKeyFile},
+	}
+
+	for i, tc := range cases {
+		configFile := filepath.Join(configDir, fmt.Sprintf("keyfile-%v.config", i))
+
+		// failures to open are logged as errors, which would fail the test when using test logger.
+		err := repo.Connect(context.Background(), configFile, env.Repository.Blobs, tc.passphrase, &repo.ConnectOptions{KeyFile: tc.keyFile})
+		if errors.Cause(err) != tc.wantErr {
+			t.Errorf("unexpected error connecting using %v: %v, want %v", tc.keyFile, err, tc.wantErr)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		r, err := repo.Open(ctx, configFile, tc.passphrase, nil)
+		if err != nil {
+			t.Fatalf("unable to open using key file: %v", err)
+		}
+
+		kr := r.(*repo.DirectRepository)
+
+		if got, want := kr.CurrentCredential(), tc.credential; got != want {
+			t.Errorf("unexpected current credential: %v, want %v", got, want)
+		}
+
+		if err = kr.ChangePassword(ctx, "some-password"); err == nil {
+			t.Errorf("unexpected success changing password of key file credential")
+		}
+
+		kr.Close(ctx)                    //nolint:errcheck
+		repo.Disconnect(ctx, configFile) //nolint:errcheck
+	}
+
+	// the password still works.
+	env.MustReopen(t)
+}
+
+func TestManifestExportImport(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var src, dst repotesting.Environment
+	defer src.Setup(t).Close(ctx, t)
+	defer dst.Setup(t).Close(ctx, t)
+
+	labels := map[string]string{"type": "item", "name": "first"}
+
+	id, err := src.Repository.PutManifest(ctx, labels, map[string]string{"some": "value"})
+	if err != nil {
+		t.Fatalf("unable to put manifest: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err = src.Repository.ExportManifests(ctx, &buf, "export-password"); err != nil {
+		t.Fatalf("unable to export manifests: %v", err)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("first")) {
+		t.Errorf("exported manifests are not encrypted")
+	}
+
+	if _, err = dst.Repository.ImportManifests(ctx, bytes.NewReader(buf.Bytes()), "wrong-password"); err != repo.ErrInvalidExportPassword {
+		t.Fatalf("unexpected error importing with wrong password: %v", err)
+	}
+
+	for _, want := range []int{1, 0} {
+		n, err := dst.Repository.ImportManifests(ctx, bytes.NewReader(buf.Bytes()), "export-password")
+		if err != nil {
+			t.Fatalf("unable to import manifests: %v", err)
+		}
+
+		if n != want {
+			t.Errorf("unexpected number of imported manifests: %v, want %v", n, want)
+		}
+	}
+
+	var v map[string]string
+
+	md, err := dst.Repository.GetManifest(ctx, id, &v)
+	if err != nil {
+		t.Fatalf("unable to get imported manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(md.Labels, labels) || v["some"] != "value" {
+		t.Errorf("unexpected imported manifest: %v %v", md.Labels, v)
+	}
+}
@@ -3,6 +3,9 @@ package splitter
 
 import (
 	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -68,5 +71,27 @@ func GetFactory(name string) Factory {
 	return splitterFactories[name]
 }
 
+// GetFactoryWithChunkSizes gets a factory for the content-defined splitter with a specified name which
+// produces chunks of at least minSize and at most maxSize bytes, averaging avgSize, which must be a power of two.
+func GetFactoryWithChunkSizes(name string, minSize, avgSize, maxSize int) (Factory, error) {
+	if avgSize <= 0 || avgSize&(avgSize-1) != 0 {
+		return nil, errors.Errorf("average chunk size must be a power of two, got %v", avgSize)
+	}
+
+	if minSize <= 0 || minSize > avgSize || maxSize < avgSize {
+		return nil, errors.Errorf("invalid chunk sizes, must be 0 < min (%v) <= avg (%v) <= max (%v)", minSize, avgSize, maxSize)
+	}
+
+	if GetFactory(name) == nil || !strings.HasPrefix(name, "DYNAMIC") {
+		return nil, errors.Errorf("splitter %q does not support custom chunk sizes", name)
+	}
+
+	if strings.HasSuffix(name, "-RABINKARP") {
+		return newRabinKarp64SplitterFactoryWithSizes(minSize, avgSize, maxSize), nil
+	}
+
+	return newBuzHash32SplitterFactoryWithSizes(minSize, avgSize, maxSize), nil
+}
+
 // DefaultAlgorithm is the name of the splitter used by default for new repositories.
 const DefaultAlgorithm = "DYNAMIC-4M-BUZHASH"
@@ -44,8 +44,11 @@ func (rs *rabinKarp64Splitter) MaxSegmentSize() int {
 }
 
 func newRabinKarp64SplitterFactory(avgSize int) Factory {
+	return newRabinKarp64SplitterFactoryWithSizes(avgSize/2, avgSize, avgSize*2) //nolint:gomnd
+}
+
+func newRabinKarp64SplitterFactoryWithSizes(minSize, avgSize, maxSize int) Factory {
 	mask := uint64(avgSize - 1)
-	minSize, maxSize := avgSize/2, avgSize*2 //nolint:gomnd
 
 	return func() Splitter {
 		s := rabinkarp64.New()
@@ -114,3 +114,51 @@ func TestSplitterStability(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitterWithChunkSizes(t *testing.T) {
+	rnd := make([]byte, 1000000)
+	rand.New(rand.NewSource(5)).Read(rnd) //nolint:errcheck
+
+	for _, name := range []string{"DYNAMIC", "DYNAMIC-4M-BUZHASH", "DYNAMIC-4M-RABINKARP"} {
+		f, err := GetFactoryWithChunkSizes(name, 3000, 4096, 6000)
+		if err != nil {
+			t.Fatalf("unable to get factory for %v: %v", name, err)
+		}
+
+		s := f()
+
+		if got, want := s.MaxSegmentSize(), 6000; got != want {
+			t.Errorf("unexpected max segment size of %v: %v, want %v", name, got, want)
+		}
+
+		lastSplit := -1
+
+		for i, p := range rnd {
+			if !s.ShouldSplit(p) {
+				continue
+			}
+
+			if l := i - lastSplit; l < 3000 || l > 6000 {
+				t.Errorf("invalid chunk size of %v: %v", name, l)
+			}
+
+			lastSplit = i
+		}
+	}
+
+	for _, tc := range []struct {
+		name                      string
+		minSize, avgSize, maxSize int
+	}{
+		{"FIXED-4M", 1000, 4096, 8192},
+		{"NO-SUCH-SPLITTER", 1000, 4096, 8192},
+		{"DYNAMIC-4M-BUZHASH", 1000, 4000, 8192},
+		{"DYNAMIC-4M-BUZHASH", 5000, 4096, 8192},
+		{"DYNAMIC-4M-BUZHASH", 1000, 4096, 2048},
+		{"DYNAMIC-4M-BUZHASH", 0, 4096, 8192},
+	} {
+		if _, err := GetFactoryWithChunkSizes(tc.name, tc.minSize, tc.avgSize, tc.maxSize); err == nil {
+			t.Errorf("expected error for %+v", tc)
+		}
+	}
+}
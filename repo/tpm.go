@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTPMPCRs is the default PCR selection of TPM-sealed key files, PCR 7 reflects the Secure Boot policy.
+const DefaultTPMPCRs = "sha256:7"
+
+const maxTPMPCR = 23
+
+// tpmSealedKey is the key of the key file sealed to the local TPM.
+type tpmSealedKey struct {
+	// PCRs is the selection of PCRs, such as 'sha256:0,2,4,7', whose values at the time of sealing
+	// must match to unseal the key, empty when the key is not bound to PCRs.
+	PCRs    string `json:"pcrs,omitempty"`
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+}
+
+// tpmSealer seals data to the local TPM, so that it can only be unsealed on the same machine and,
+// when PCRs are selected, only while they have the same values as when sealed.
+type tpmSealer interface {
+	Seal(ctx context.Context, data []byte, pcrs string) (*tpmSealedKey, error)
+	Unseal(ctx context.Context, k *tpmSealedKey) ([]byte, error)
+}
+
+// tpm is replaced in tests.
+var tpm tpmSealer = tpm2Tools{}
+
+// ValidateTPMPCRs returns an error if the provided PCR selection, such as 'sha256:0,7', is invalid.
+func ValidateTPMPCRs(pcrs string) error {
+	if pcrs == "" {
+		return nil
+	}
+
+	parts := strings.Split(pcrs, ":")
+	if len(parts) != 2 { //nolint:gomnd
+		return errors.Errorf("invalid PCR selection %q, expected format such as %v", pcrs, DefaultTPMPCRs)
+	}
+
+	switch parts[0] {
+	case "sha1", "sha256", "sha384", "sha512":
+	default:
+		return errors.Errorf("unsupported PCR bank %q", parts[0])
+	}
+
+	for _, p := range strings.Split(parts[1], ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > maxTPMPCR {
+			return errors.Errorf("invalid PCR index %q", p)
+		}
+	}
+
+	return nil
+}
+
+// tpm2Tools implements tpmSealer using tpm2-tools, which must be installed and have access to the TPM.
+// The sealed object is stored in the key file and loaded under the primary key of the owner hierarchy,
+// which is re-created on demand from the same template.
+type tpm2Tools struct{}
+
+func (tpm2Tools) Seal(ctx context.Context, data []byte, pcrs string) (*tpmSealedKey, error) {
+	dir, err := ioutil.TempDir("", "kopia-tpm")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary directory")
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	if err := runTPM2Tool(ctx, dir, nil, "tpm2_createprimary", "-C", "o", "-c", "primary.ctx"); err != nil {
+		return nil, err
+	}
+
+	args := []string{"-C", "primary.ctx", "-i", "-", "-u", "seal.pub", "-r", "seal.priv"}
+
+	if pcrs != "" {
+		if err := runTPM2Tool(ctx, dir, nil, "tpm2_createpolicy", "--policy-pcr", "-l", pcrs, "-L", "pcr.policy"); err != nil {
+			return nil, err
+		}
+
+		// without userwithauth the object can only be unsealed by satisfying the PCR policy.
+		args = append(args, "-L", "pcr.policy", "-a", "fixedtpm|fixedparent|adminwithpolicy|noda")
+	}
+
+	// data is passed on standard input, so that it's never written to disk.
+	if err := runTPM2Tool(ctx, dir, bytes.NewReader(data), "tpm2_create", args...); err != nil {
+		return nil, err
+	}
+
+	k := &tpmSealedKey{PCRs: pcrs}
+
+	if k.Public, err = ioutil.ReadFile(filepath.Join(dir, "seal.pub")); err != nil {
+		return nil, errors.Wrap(err, "unable to read sealed public area")
+	}
+
+	if k.Private, err = ioutil.ReadFile(filepath.Join(dir, "seal.priv")); err != nil {
+		return nil, errors.Wrap(err, "unable to read sealed private area")
+	}
+
+	return k, nil
+}
+
+func (tpm2Tools) Unseal(ctx context.Context, k *tpmSealedKey) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "kopia-tpm")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary directory")
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "seal.pub"), k.Public, 0600); err != nil { //nolint:gomnd
+		return nil, errors.Wrap(err, "unable to write sealed public area")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "seal.priv"), k.Private, 0600); err != nil { //nolint:gomnd
+		return nil, errors.Wrap(err, "unable to write sealed private area")
+	}
+
+	if err := runTPM2Tool(ctx, dir, nil, "tpm2_createprimary", "-C", "o", "-c", "primary.ctx"); err != nil {
+		return nil, err
+	}
+
+	if err := runTPM2Tool(ctx, dir, nil, "tpm2_load", "-C", "primary.ctx", "-u", "seal.pub", "-r", "seal.priv", "-c", "seal.ctx"); err != nil {
+		return nil, err
+	}
+
+	args := []string{"-c", "seal.ctx"}
+	if k.PCRs != "" {
+		args = append(args, "-p", "pcr:"+k.PCRs)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "tpm2_unseal", args...) // nolint:gosec
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrap(err, "unable to unseal key, boot configuration may have changed")
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func runTPM2Tool(ctx context.Context, dir string, stdin *bytes.Reader, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...) // nolint:gosec
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	return errors.Wrapf(cmd.Run(), "%v failed", name)
+}
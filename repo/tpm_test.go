@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+// fakeTPM seals data to the value of a single emulated PCR.
+type fakeTPM struct {
+	pcrValue byte
+}
+
+func (f *fakeTPM) Seal(ctx context.Context, data []byte, pcrs string) (*tpmSealedKey, error) {
+	sealed := append([]byte(nil), data...)
+	for i := range sealed {
+		sealed[i] ^= f.pcrValue
+	}
+
+	return &tpmSealedKey{PCRs: pcrs, Public: []byte{f.pcrValue}, Private: sealed}, nil
+}
+
+func (f *fakeTPM) Unseal(ctx context.Context, k *tpmSealedKey) ([]byte, error) {
+	if k.Public[0] != f.pcrValue {
+		return nil, errors.New("PCR policy check failed")
+	}
+
+	data := append([]byte(nil), k.Private...)
+	for i := range data {
+		data[i] ^= f.pcrValue
+	}
+
+	return data, nil
+}
+
+func TestTPMKeyFile(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	ft := &fakeTPM{pcrValue: 0x55}
+
+	oldTPM := tpm
+	tpm = ft
+
+	defer func() { tpm = oldTPM }()
+
+	dir, err := ioutil.TempDir("", "tpm-keyfile")
+	assertNoError(t, err)
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	path := filepath.Join(dir, "key.json")
+
+	if err := GenerateTPMKeyFile(ctx, path, "sha256:0,7,24"); err == nil {
+		t.Errorf("unexpected success generating key file with invalid PCRs")
+	}
+
+	assertNoError(t, GenerateTPMKeyFile(ctx, path, DefaultTPMPCRs))
+
+	if err := GenerateTPMKeyFile(ctx, path, DefaultTPMPCRs); err == nil {
+		t.Errorf("unexpected success overwriting key file")
+	}
+
+	protected, err := KeyFileRequiresPassphrase(path)
+	assertNoError(t, err)
+
+	if protected {
+		t.Errorf("TPM-sealed key file must not require passphrase")
+	}
+
+	key, err := readKeyFile(ctx, path, "")
+	assertNoError(t, err)
+
+	if len(key) != keyFileKeySize {
+		t.Fatalf("unexpected key length: %v", len(key))
+	}
+
+	kf, err := loadKeyFile(path)
+	assertNoError(t, err)
+
+	if kf.Key != nil || kf.EncryptedKey != nil || bytes.Equal(kf.TPM.Private, key) || kf.TPM.PCRs != DefaultTPMPCRs {
+		t.Errorf("key is not sealed: %+v", kf)
+	}
+
+	// boot configuration change.
+	ft.pcrValue++
+
+	if _, err := readKeyFile(ctx, path, ""); err == nil {
+		t.Errorf("unexpected success unsealing key after PCR change")
+	}
+}
+
+func TestValidateTPMPCRs(t *testing.T) {
+	for _, valid := range []string{"", DefaultTPMPCRs, "sha1:0,2,4,7", "sha384:23"} {
+		if err := ValidateTPMPCRs(valid); err != nil {
+			t.Errorf("unexpected error validating %q: %v", valid, err)
+		}
+	}
+
+	for _, invalid := range []string{"sha256", "md5:1", "sha256:", "sha256:0,x", "sha256:-1", "sha256:7:8"} {
+		if err := ValidateTPMPCRs(invalid); err == nil {
+			t.Errorf("unexpected success validating %q", invalid)
+		}
+	}
+}
@@ -8,9 +8,7 @@ import (
 
 // Upgrade upgrades repository data structures to the latest version.
 func (r *DirectRepository) Upgrade(ctx context.Context) error {
-	f := r.formatBlob
-
-	repoConfig, err := f.decryptFormatBytes(r.masterKey)
+	repoConfig, err := r.formatBlob.decryptFormatBytes(r.masterKey)
 	if err != nil {
 		return errors.Wrap(err, "unable to decrypt repository config")
 	}
@@ -23,11 +21,9 @@ func (r *DirectRepository) Upgrade(ctx context.Context) error {
 		return nil
 	}
 
-	if err := encryptFormatBytes(f, repoConfig, r.masterKey, f.UniqueID); err != nil {
-		return errors.Errorf("unable to encrypt format bytes")
-	}
-
 	log(ctx).Infof("writing updated format content...")
 
-	return writeFormatBlob(ctx, r.Blobs, f)
+	return r.updateFormatBlob(ctx, func(f *formatBlob) error {
+		return errors.Wrap(encryptFormatBytes(f, repoConfig, r.masterKey, f.UniqueID), "unable to encrypt format bytes")
+	})
 }
@@ -0,0 +1,122 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File categories of Classification.
+const (
+	CategoryDocuments = "documents"
+	CategoryMedia     = "media"
+	CategoryCode      = "code"
+	CategoryOther     = "other"
+)
+
+// maxClassifiedExtensions is the maximum number of distinct extensions in Classification, files with
+// other extensions are counted under OtherExtensions.
+const maxClassifiedExtensions = 100
+
+// OtherExtensions is the key of Classification.Extensions counting files with extensions over the limit.
+const OtherExtensions = "*"
+
+var extensionCategories = map[string]string{}
+
+func init() {
+	for cat, exts := range map[string][]string{
+		CategoryDocuments: {
+			"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "odt", "ods", "odp", "rtf", "txt", "md", "csv",
+			"epub", "pages", "numbers", "key", "tex",
+		},
+		CategoryMedia: {
+			"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff", "heic", "heif", "webp", "raw", "cr2", "nef", "arw", "dng",
+			"psd", "mp3", "wav", "flac", "aac", "ogg", "m4a", "wma", "mp4", "mov", "avi", "mkv", "wmv", "webm", "m4v", "mpg", "mpeg",
+		},
+		CategoryCode: {
+			"go", "c", "h", "cc", "cpp", "hpp", "cs", "java", "kt", "scala", "js", "jsx", "ts", "tsx", "py", "rb", "php",
+			"rs", "swift", "m", "sh", "ps1", "pl", "lua", "sql", "html", "css", "scss", "json", "yaml", "yml", "xml", "toml",
+		},
+	} {
+		for _, ext := range exts {
+			extensionCategories[ext] = cat
+		}
+	}
+}
+
+// ClassificationStats contains the number and total size of files in a classification group.
+type ClassificationStats struct {
+	FileCount     int   `json:"files"`
+	TotalFileSize int64 `json:"size"`
+}
+
+// Classification summarizes files of a snapshot by category and lowercase file extension.
+type Classification struct {
+	Categories map[string]ClassificationStats `json:"categories"`
+	Extensions map[string]ClassificationStats `json:"extensions"`
+
+	mu sync.Mutex
+}
+
+// NewClassification returns empty Classification.
+func NewClassification() *Classification {
+	return &Classification{
+		Categories: map[string]ClassificationStats{},
+		Extensions: map[string]ClassificationStats{},
+	}
+}
+
+// ClassifyFile returns the category and lowercase extension (without a dot) of a file with the provided name.
+func ClassifyFile(name string) (category, extension string) {
+	extension = strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+
+	if cat, ok := extensionCategories[extension]; ok {
+		return cat, extension
+	}
+
+	return CategoryOther, extension
+}
+
+// AddFile adds a file with the provided name and size to the classification.
+func (c *Classification) AddFile(name string, size int64) {
+	cat, ext := ClassifyFile(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Categories[cat] = c.Categories[cat].add(size)
+
+	if _, ok := c.Extensions[ext]; !ok && len(c.Extensions) >= maxClassifiedExtensions {
+		ext = OtherExtensions
+	}
+
+	c.Extensions[ext] = c.Extensions[ext].add(size)
+}
+
+func (s ClassificationStats) add(size int64) ClassificationStats {
+	s.FileCount++
+	s.TotalFileSize += size
+
+	return s
+}
+
+// SortedCategories returns categories of the classification sorted by descending total size.
+func (c *Classification) SortedCategories() []string {
+	var result []string
+
+	for k := range c.Categories {
+		result = append(result, k)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		si, sj := c.Categories[result[i]].TotalFileSize, c.Categories[result[j]].TotalFileSize
+		if si != sj {
+			return si > sj
+		}
+
+		return result[i] < result[j]
+	})
+
+	return result
+}
@@ -0,0 +1,57 @@
+package snapshot_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestClassification(t *testing.T) {
+	c := snapshot.NewClassification()
+	c.AddFile("report.PDF", 100)
+	c.AddFile("photo.jpg", 1000)
+	c.AddFile("main.go", 10)
+	c.AddFile("README", 1)
+	c.AddFile("notes.txt", 5)
+
+	wantCategories := map[string]snapshot.ClassificationStats{
+		snapshot.CategoryDocuments: {FileCount: 2, TotalFileSize: 105},
+		snapshot.CategoryMedia:     {FileCount: 1, TotalFileSize: 1000},
+		snapshot.CategoryCode:      {FileCount: 1, TotalFileSize: 10},
+		snapshot.CategoryOther:     {FileCount: 1, TotalFileSize: 1},
+	}
+
+	if !reflect.DeepEqual(c.Categories, wantCategories) {
+		t.Errorf("unexpected categories: %v, want %v", c.Categories, wantCategories)
+	}
+
+	if got, want := c.Extensions["pdf"], (snapshot.ClassificationStats{FileCount: 1, TotalFileSize: 100}); got != want {
+		t.Errorf("unexpected stats of pdf files: %v, want %v", got, want)
+	}
+
+	if got, want := c.Extensions[""], (snapshot.ClassificationStats{FileCount: 1, TotalFileSize: 1}); got != want {
+		t.Errorf("unexpected stats of files without extension: %v, want %v", got, want)
+	}
+
+	if got, want := c.SortedCategories(), []string{"media", "documents", "code", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected sorted categories: %v, want %v", got, want)
+	}
+}
+
+func TestClassificationExtensionLimit(t *testing.T) {
+	c := snapshot.NewClassification()
+
+	for i := 0; i < 150; i++ {
+		c.AddFile(fmt.Sprintf("file.x%v", i), 1)
+	}
+
+	if got, want := len(c.Extensions), 101; got != want {
+		t.Fatalf("unexpected number of extensions: %v, want %v", got, want)
+	}
+
+	if got, want := c.Extensions[snapshot.OtherExtensions].FileCount, 50; got != want {
+		t.Errorf("unexpected number of files with other extensions: %v, want %v", got, want)
+	}
+}
@@ -25,9 +25,25 @@ type Manifest struct {
 
 	RootEntry *DirEntry `json:"rootEntry"`
 
+	// ReportID is the object containing the Report of the snapshot, if any.
+	ReportID object.ID `json:"report,omitempty"`
+
+	// ActionOutput is the standard output of actions run before and after the snapshot, if any.
+	ActionOutput *ActionOutput `json:"actionOutput,omitempty"`
+
+	// Splitter is the splitter selected by the policy of the source root to write files,
+	// empty when the repository default was used.
+	Splitter string `json:"splitter,omitempty"`
+
 	RetentionReasons []string `json:"-"`
 }
 
+// ActionOutput holds standard output of actions run before and after the snapshot of the source root.
+type ActionOutput struct {
+	BeforeSnapshotRoot string `json:"beforeSnapshotRoot,omitempty"`
+	AfterSnapshotRoot  string `json:"afterSnapshotRoot,omitempty"`
+}
+
 // EntryType is a type of a filesystem entry.
 type EntryType string
 
@@ -37,8 +53,23 @@ const (
 	EntryTypeFile      EntryType = "f" // file
 	EntryTypeDirectory EntryType = "d" // directory
 	EntryTypeSymlink   EntryType = "s" // symbolic link
+
+	EntryTypeNamedPipe   EntryType = "p" // named pipe (FIFO), metadata only
+	EntryTypeSocket      EntryType = "o" // UNIX domain socket, metadata only
+	EntryTypeCharDevice  EntryType = "c" // character device node, metadata only
+	EntryTypeBlockDevice EntryType = "b" // block device node, metadata only
 )
 
+// IsSpecial returns true if the entry type represents a named pipe, socket or device node.
+func (t EntryType) IsSpecial() bool {
+	switch t {
+	case EntryTypeNamedPipe, EntryTypeSocket, EntryTypeCharDevice, EntryTypeBlockDevice:
+		return true
+	default:
+		return false
+	}
+}
+
 // Permissions encapsulates UNIX permissions for a filesystem entry.
 type Permissions int
 
@@ -82,6 +113,20 @@ type DirEntry struct {
 	GroupID     uint32               `json:"gid,omitempty"`
 	ObjectID    object.ID            `json:"obj,omitempty"`
 	DirSummary  *fs.DirectorySummary `json:"summ,omitempty"`
+	Device      *fs.DeviceInfo       `json:"dev,omitempty"`
+	HardLink    *fs.HardLinkID       `json:"hlink,omitempty"`
+	Sparse      *SparseFile          `json:"sparse,omitempty"`
+	Unstable    bool                 `json:"unstable,omitempty"` // file changed while it was being read
+	ChangeInfo  *fs.ChangeInfo       `json:"chg,omitempty"`      // recorded when required by policy to detect changes
+
+	ExtendedAttributes fs.ExtendedAttributes `json:"xattrs,omitempty"`
+	ACL                *fs.ACL               `json:"acl,omitempty"`
+}
+
+// SparseFile describes ranges of a sparse file holding data, the remaining ranges up to the file size are holes.
+// The file object holds the full file contents with zeros in the holes, extents are only used to restore the file sparse.
+type SparseFile struct {
+	Extents []fs.Extent `json:"extents"`
 }
 
 // HasDirEntry is implemented by objects that have a DirEntry associated with them.
@@ -0,0 +1,61 @@
+package policy
+
+// ActionFailureMode determines what happens when an action fails.
+type ActionFailureMode string
+
+// Supported action failure modes.
+const (
+	ActionFailureModeFail     ActionFailureMode = "fail"     // snapshot fails when the action fails
+	ActionFailureModeContinue ActionFailureMode = "continue" // failure is logged and the snapshot continues
+)
+
+// ActionsPolicy describes commands invoked before and after a snapshot of a source root, which can be
+// used to create and remove a filesystem snapshot (LVM, btrfs, ZFS) to upload from.
+type ActionsPolicy struct {
+	// BeforeSnapshotRoot is the shell command run before the snapshot is taken. It may change the directory
+	// that is uploaded by printing KOPIA_SNAPSHOT_PATH=<path> to standard output.
+	BeforeSnapshotRoot string `json:"beforeSnapshotRoot,omitempty"`
+
+	// AfterSnapshotRoot is the shell command run after the snapshot finishes, whether it succeeded or not.
+	AfterSnapshotRoot string `json:"afterSnapshotRoot,omitempty"`
+
+	// TimeoutSeconds is the maximum time each command is allowed to run.
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+
+	// FailureMode determines whether the snapshot fails when an action fails.
+	FailureMode ActionFailureMode `json:"failureMode,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *ActionsPolicy) Merge(src ActionsPolicy) {
+	if p.BeforeSnapshotRoot == "" {
+		p.BeforeSnapshotRoot = src.BeforeSnapshotRoot
+	}
+
+	if p.AfterSnapshotRoot == "" {
+		p.AfterSnapshotRoot = src.AfterSnapshotRoot
+	}
+
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = src.TimeoutSeconds
+	}
+
+	if p.FailureMode == "" {
+		p.FailureMode = src.FailureMode
+	}
+}
+
+// FailureModeOrDefault returns the failure mode if it is set or ActionFailureModeFail otherwise.
+func (p *ActionsPolicy) FailureModeOrDefault() ActionFailureMode {
+	if p.FailureMode == "" {
+		return ActionFailureModeFail
+	}
+
+	return p.FailureMode
+}
+
+// defaultActionsPolicy is the default actions policy.
+var defaultActionsPolicy = ActionsPolicy{
+	TimeoutSeconds: 300, //nolint:gomnd
+	FailureMode:    ActionFailureModeFail,
+}
@@ -7,6 +7,9 @@ type ErrorHandlingPolicy struct {
 
 	// IgnoreDirectoryErrors controls whether or not snapshot operation should terminate when a directory throws an error on being read or opened
 	IgnoreDirectoryErrors *bool `json:"ignoreDirectoryErrors,omitempty"`
+
+	// RetryFileErrors is the number of times reading a file is retried before the error is ignored or terminates the snapshot.
+	RetryFileErrors *int `json:"retryFileErrors,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -18,6 +21,10 @@ func (p *ErrorHandlingPolicy) Merge(src ErrorHandlingPolicy) {
 	if p.IgnoreDirectoryErrors == nil && src.IgnoreDirectoryErrors != nil {
 		p.IgnoreDirectoryErrors = newBool(*src.IgnoreDirectoryErrors)
 	}
+
+	if p.RetryFileErrors == nil && src.RetryFileErrors != nil {
+		p.RetryFileErrors = intPtr(*src.RetryFileErrors)
+	}
 }
 
 // IgnoreFileErrorsOrDefault returns the ignore-file-error setting if it is set,
@@ -40,10 +47,21 @@ func (p *ErrorHandlingPolicy) IgnoreDirectoryErrorsOrDefault(def bool) bool {
 	return *p.IgnoreDirectoryErrors
 }
 
+// RetryFileErrorsOrDefault returns the retry-file-errors setting if it is set,
+// and returns the passed default if not
+func (p *ErrorHandlingPolicy) RetryFileErrorsOrDefault(def int) int {
+	if p.RetryFileErrors == nil {
+		return def
+	}
+
+	return *p.RetryFileErrors
+}
+
 // defaultErrorHandlingPolicy is the default error handling policy.
 var defaultErrorHandlingPolicy = ErrorHandlingPolicy{
 	IgnoreFileErrors:      newBool(false),
 	IgnoreDirectoryErrors: newBool(false),
+	RetryFileErrors:       intPtr(0),
 }
 
 func newBool(b bool) *bool {
@@ -9,6 +9,29 @@ type FilesPolicy struct {
 	NoParentDotIgnoreFiles bool     `json:"noParentDotFiles,omitempty"`
 
 	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+
+	// IgnoreSpecialFiles controls whether named pipes, sockets and device nodes are skipped instead of being recorded as metadata-only entries.
+	IgnoreSpecialFiles *bool `json:"ignoreSpecialFiles,omitempty"`
+
+	// IgnoreRepositories controls whether Kopia's own cache and configuration directories and directories
+	// containing Kopia, restic or Borg repositories are skipped.
+	IgnoreRepositories *bool `json:"ignoreRepositories,omitempty"`
+
+	// IgnoreCacheDirs controls whether directories tagged with a CACHEDIR.TAG file or containing a .nobackup
+	// marker file are skipped. It is disabled by default, since such markers are created by third-party tools
+	// without the user's knowledge.
+	IgnoreCacheDirs *bool `json:"ignoreCacheDirs,omitempty"`
+
+	// FollowSymlinks controls whether symbolic links to files are stored as the contents of their targets
+	// instead of the link itself. Symbolic links to directories are never followed to prevent cycles.
+	FollowSymlinks *bool `json:"followSymlinks,omitempty"`
+
+	// ExtendedAttributes controls whether user and security extended attributes of files and directories are captured.
+	// On Windows it controls capturing of named alternate data streams.
+	ExtendedAttributes *bool `json:"extendedAttributes,omitempty"`
+
+	// OneFileSystem controls whether directories on file systems other than their parent's (mount points) are skipped.
+	OneFileSystem *bool `json:"oneFileSystem,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -24,9 +47,99 @@ func (p *FilesPolicy) Merge(src FilesPolicy) {
 	if len(p.DotIgnoreFiles) == 0 {
 		p.DotIgnoreFiles = src.DotIgnoreFiles
 	}
+
+	if p.IgnoreSpecialFiles == nil && src.IgnoreSpecialFiles != nil {
+		p.IgnoreSpecialFiles = newBool(*src.IgnoreSpecialFiles)
+	}
+
+	if p.IgnoreRepositories == nil && src.IgnoreRepositories != nil {
+		p.IgnoreRepositories = newBool(*src.IgnoreRepositories)
+	}
+
+	if p.IgnoreCacheDirs == nil && src.IgnoreCacheDirs != nil {
+		p.IgnoreCacheDirs = newBool(*src.IgnoreCacheDirs)
+	}
+
+	if p.FollowSymlinks == nil && src.FollowSymlinks != nil {
+		p.FollowSymlinks = newBool(*src.FollowSymlinks)
+	}
+
+	if p.ExtendedAttributes == nil && src.ExtendedAttributes != nil {
+		p.ExtendedAttributes = newBool(*src.ExtendedAttributes)
+	}
+
+	if p.OneFileSystem == nil && src.OneFileSystem != nil {
+		p.OneFileSystem = newBool(*src.OneFileSystem)
+	}
+}
+
+// IgnoreSpecialFilesOrDefault returns the ignore-special-files setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) IgnoreSpecialFilesOrDefault(def bool) bool {
+	if p.IgnoreSpecialFiles == nil {
+		return def
+	}
+
+	return *p.IgnoreSpecialFiles
+}
+
+// IgnoreRepositoriesOrDefault returns the ignore-repositories setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) IgnoreRepositoriesOrDefault(def bool) bool {
+	if p.IgnoreRepositories == nil {
+		return def
+	}
+
+	return *p.IgnoreRepositories
+}
+
+// IgnoreCacheDirsOrDefault returns the ignore-cache-dirs setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) IgnoreCacheDirsOrDefault(def bool) bool {
+	if p.IgnoreCacheDirs == nil {
+		return def
+	}
+
+	return *p.IgnoreCacheDirs
+}
+
+// FollowSymlinksOrDefault returns the follow-symlinks setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) FollowSymlinksOrDefault(def bool) bool {
+	if p.FollowSymlinks == nil {
+		return def
+	}
+
+	return *p.FollowSymlinks
+}
+
+// ExtendedAttributesOrDefault returns the extended-attributes setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) ExtendedAttributesOrDefault(def bool) bool {
+	if p.ExtendedAttributes == nil {
+		return def
+	}
+
+	return *p.ExtendedAttributes
+}
+
+// OneFileSystemOrDefault returns the one-file-system setting if it is set,
+// and returns the passed default if not
+func (p *FilesPolicy) OneFileSystemOrDefault(def bool) bool {
+	if p.OneFileSystem == nil {
+		return def
+	}
+
+	return *p.OneFileSystem
 }
 
 // defaultFilesPolicy is the default file ignore policy.
 var defaultFilesPolicy = FilesPolicy{
-	DotIgnoreFiles: []string{".kopiaignore"},
+	DotIgnoreFiles:     []string{".kopiaignore"},
+	IgnoreSpecialFiles: newBool(false),
+	IgnoreRepositories: newBool(true),
+	IgnoreCacheDirs:    newBool(false),
+	FollowSymlinks:     newBool(false),
+	ExtendedAttributes: newBool(true),
+	OneFileSystem:      newBool(false),
 }
@@ -19,6 +19,9 @@ type Policy struct {
 	ErrorHandlingPolicy ErrorHandlingPolicy `json:"errorHandling,omitempty"`
 	SchedulingPolicy    SchedulingPolicy    `json:"scheduling,omitempty"`
 	CompressionPolicy   CompressionPolicy   `json:"compression,omitempty"`
+	UploadPolicy        UploadPolicy        `json:"upload,omitempty"`
+	ActionsPolicy       ActionsPolicy       `json:"actions,omitempty"`
+	SplitterPolicy      SplitterPolicy      `json:"splitter,omitempty"`
 	NoParent            bool                `json:"noParent,omitempty"`
 }
 
@@ -63,6 +66,9 @@ func MergePolicies(policies []*Policy) *Policy {
 		merged.ErrorHandlingPolicy.Merge(p.ErrorHandlingPolicy)
 		merged.SchedulingPolicy.Merge(p.SchedulingPolicy)
 		merged.CompressionPolicy.Merge(p.CompressionPolicy)
+		merged.UploadPolicy.Merge(p.UploadPolicy)
+		merged.ActionsPolicy.Merge(p.ActionsPolicy)
+		merged.SplitterPolicy.Merge(p.SplitterPolicy)
 	}
 
 	// Merge default expiration policy.
@@ -71,6 +77,9 @@ func MergePolicies(policies []*Policy) *Policy {
 	merged.ErrorHandlingPolicy.Merge(defaultErrorHandlingPolicy)
 	merged.SchedulingPolicy.Merge(defaultSchedulingPolicy)
 	merged.CompressionPolicy.Merge(defaultCompressionPolicy)
+	merged.UploadPolicy.Merge(defaultUploadPolicy)
+	merged.ActionsPolicy.Merge(defaultActionsPolicy)
+	merged.SplitterPolicy.Merge(defaultSplitterPolicy)
 
 	return &merged
 }
@@ -141,6 +141,30 @@ func SetPolicy(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo,
 	return nil
 }
 
+// DefinedPolicyManifestIDs returns IDs of manifests defining the policy on the provided snapshot.SourceInfo,
+// which can be passed to SetPolicyIfMatch after the policy is modified.
+func DefinedPolicyManifestIDs(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) ([]manifest.ID, error) {
+	md, err := rep.FindManifests(ctx, labelsForSource(si))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load manifests for %v", si)
+	}
+
+	var result []manifest.ID
+	for _, em := range md {
+		result = append(result, em.ID)
+	}
+
+	return result, nil
+}
+
+// SetPolicyIfMatch sets the policy on a given source only if it's still defined by the expected manifests
+// returned by DefinedPolicyManifestIDs, otherwise returns manifest.ErrConflict.
+func SetPolicyIfMatch(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo, pol *Policy, expected []manifest.ID) error {
+	_, err := rep.PutManifestIfMatch(ctx, labelsForSource(si), pol, expected)
+
+	return err
+}
+
 // RemovePolicy removes the policy for a given source.
 func RemovePolicy(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) error {
 	md, err := rep.FindManifests(ctx, labelsForSource(si))
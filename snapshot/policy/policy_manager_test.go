@@ -4,7 +4,10 @@ import (
 	"context"
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/manifest"
 )
 
 func TestPolicyManager(t *testing.T) {
@@ -43,6 +46,56 @@ func TestPolicyManager(t *testing.T) {
 	}
 }
 
+func TestPolicyManagerConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+
+	var env repotesting.Environment
+
+	defer env.Setup(t).Close(ctx, t)
+
+	r1 := env.Repository
+	sourceInfo := GlobalPolicySourceInfo
+
+	must(t, SetPolicy(ctx, r1, sourceInfo, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily: intPtr(11),
+		},
+	}))
+	must(t, r1.Flush(ctx))
+
+	// both clients read the policy before modifying it.
+	r2 := env.MustOpenAnother(t)
+
+	ids1, err := DefinedPolicyManifestIDs(ctx, r1, sourceInfo)
+	must(t, err)
+
+	ids2, err := DefinedPolicyManifestIDs(ctx, r2, sourceInfo)
+	must(t, err)
+
+	must(t, SetPolicyIfMatch(ctx, r1, sourceInfo, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily: intPtr(22),
+		},
+	}, ids1))
+	must(t, r1.Flush(ctx))
+
+	err = SetPolicyIfMatch(ctx, r2, sourceInfo, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily: intPtr(33),
+		},
+	}, ids2)
+	if errors.Cause(err) != manifest.ErrConflict {
+		t.Fatalf("unexpected error when saving concurrently modified policy: %v", err)
+	}
+
+	pi, err := GetDefinedPolicy(ctx, r2, sourceInfo)
+	must(t, err)
+
+	if got := *pi.RetentionPolicy.KeepDaily; got != 22 {
+		t.Errorf("unexpected policy after conflict: %v", got)
+	}
+}
+
 func must(t *testing.T, err error) {
 	t.Helper()
 
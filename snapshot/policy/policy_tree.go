@@ -9,6 +9,9 @@ var DefaultPolicy = &Policy{
 	CompressionPolicy:   defaultCompressionPolicy,
 	ErrorHandlingPolicy: defaultErrorHandlingPolicy,
 	SchedulingPolicy:    defaultSchedulingPolicy,
+	UploadPolicy:        defaultUploadPolicy,
+	ActionsPolicy:       defaultActionsPolicy,
+	SplitterPolicy:      defaultSplitterPolicy,
 }
 
 // Tree represents a node in the policy tree, where a policy can be
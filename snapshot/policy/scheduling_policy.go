@@ -52,6 +52,9 @@ func SortAndDedupeTimesOfDay(tod []TimeOfDay) []TimeOfDay {
 type SchedulingPolicy struct {
 	IntervalSeconds int64       `json:"intervalSeconds,omitempty"`
 	TimesOfDay      []TimeOfDay `json:"timeOfDay,omitempty"`
+
+	// StaleAfterSeconds is the age of the last successful snapshot after which it's considered stale.
+	StaleAfterSeconds int64 `json:"staleAfterSeconds,omitempty"`
 }
 
 // Interval returns the snapshot interval or zero if not specified.
@@ -64,12 +67,26 @@ func (p *SchedulingPolicy) SetInterval(d time.Duration) {
 	p.IntervalSeconds = int64(d.Seconds())
 }
 
+// StaleAfter returns the age after which the last snapshot is considered stale or zero if not specified.
+func (p *SchedulingPolicy) StaleAfter() time.Duration {
+	return time.Duration(p.StaleAfterSeconds) * time.Second
+}
+
+// SetStaleAfter sets the age after which the last snapshot is considered stale (zero disables).
+func (p *SchedulingPolicy) SetStaleAfter(d time.Duration) {
+	p.StaleAfterSeconds = int64(d.Seconds())
+}
+
 // Merge applies default values from the provided policy.
 func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	if p.IntervalSeconds == 0 {
 		p.IntervalSeconds = src.IntervalSeconds
 	}
 
+	if p.StaleAfterSeconds == 0 {
+		p.StaleAfterSeconds = src.StaleAfterSeconds
+	}
+
 	p.TimesOfDay = SortAndDedupeTimesOfDay(
 		append(append([]TimeOfDay(nil), src.TimesOfDay...), p.TimesOfDay...))
 }
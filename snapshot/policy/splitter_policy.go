@@ -0,0 +1,17 @@
+package policy
+
+// SplitterPolicy specifies how the contents of files are split into chunks.
+type SplitterPolicy struct {
+	// Algorithm is the name of the splitter (see splitter.SupportedAlgorithms()), the repository default when empty.
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *SplitterPolicy) Merge(src SplitterPolicy) {
+	if p.Algorithm == "" {
+		p.Algorithm = src.Algorithm
+	}
+}
+
+// defaultSplitterPolicy uses the splitter from the repository format.
+var defaultSplitterPolicy = SplitterPolicy{}
@@ -0,0 +1,56 @@
+package policy
+
+// UploadPolicy controls how snapshot data is uploaded to the repository.
+type UploadPolicy struct {
+	// MaxUploadBytesPerSecond limits the rate at which blobs are written to the storage, 0 is unlimited.
+	MaxUploadBytesPerSecond int64 `json:"maxUploadBytesPerSecond,omitempty"`
+
+	// CompareChangeInfo makes files from previous snapshots reused only when their status change time and inode
+	// number also match, which detects modifications that preserve the modification time.
+	CompareChangeInfo *bool `json:"compareChangeInfo,omitempty"`
+
+	// RehashUnchangedPercentage is the percentage of files unchanged since previous snapshots which are read
+	// and hashed anyway, different files are selected in each snapshot.
+	RehashUnchangedPercentage *int `json:"rehashUnchangedPercentage,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *UploadPolicy) Merge(src UploadPolicy) {
+	if p.MaxUploadBytesPerSecond == 0 {
+		p.MaxUploadBytesPerSecond = src.MaxUploadBytesPerSecond
+	}
+
+	if p.CompareChangeInfo == nil && src.CompareChangeInfo != nil {
+		p.CompareChangeInfo = newBool(*src.CompareChangeInfo)
+	}
+
+	if p.RehashUnchangedPercentage == nil && src.RehashUnchangedPercentage != nil {
+		p.RehashUnchangedPercentage = intPtr(*src.RehashUnchangedPercentage)
+	}
+}
+
+// CompareChangeInfoOrDefault returns the compare-change-info setting if it is set,
+// and returns the passed default if not
+func (p *UploadPolicy) CompareChangeInfoOrDefault(def bool) bool {
+	if p.CompareChangeInfo == nil {
+		return def
+	}
+
+	return *p.CompareChangeInfo
+}
+
+// RehashUnchangedPercentageOrDefault returns the rehash-unchanged percentage if it is set,
+// and returns the passed default if not
+func (p *UploadPolicy) RehashUnchangedPercentageOrDefault(def int) int {
+	if p.RehashUnchangedPercentage == nil {
+		return def
+	}
+
+	return *p.RehashUnchangedPercentage
+}
+
+// defaultUploadPolicy is the default upload policy.
+var defaultUploadPolicy = UploadPolicy{
+	CompareChangeInfo:         newBool(false),
+	RehashUnchangedPercentage: intPtr(0),
+}
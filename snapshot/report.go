@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// MaxReportedItems is the maximum number of errors and excluded paths recorded in a Report.
+const MaxReportedItems = 1000
+
+// Report is a machine-readable summary of creating a snapshot, which is stored in the repository
+// and referenced by the snapshot manifest, so that backup health can be audited later.
+type Report struct {
+	Source           SourceInfo    `json:"source"`
+	StartTime        time.Time     `json:"startTime"`
+	EndTime          time.Time     `json:"endTime"`
+	Duration         time.Duration `json:"duration"`
+	IncompleteReason string        `json:"incomplete,omitempty"`
+	Checkpoints      int           `json:"checkpoints,omitempty"`
+
+	Stats Stats `json:"stats"`
+
+	// Errors contains ignored errors, up to MaxReportedItems.
+	Errors []*fs.EntryWithError `json:"errors,omitempty"`
+
+	// Excluded contains paths of entries excluded by policy, up to MaxReportedItems.
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// WriteReport writes the provided report to the repository and returns its object ID.
+func WriteReport(ctx context.Context, rep repo.Repository, r *Report) (object.ID, error) {
+	w := rep.NewObjectWriter(ctx, object.WriterOptions{
+		Description: "REPORT:" + r.Source.String(),
+		Prefix:      "k",
+	})
+	defer w.Close() //nolint:errcheck
+
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		return "", errors.Wrap(err, "unable to encode report")
+	}
+
+	return w.Result()
+}
+
+// LoadReport loads the report of the provided snapshot.
+func LoadReport(ctx context.Context, rep repo.Repository, m *Manifest) (*Report, error) {
+	if m.ReportID == "" {
+		return nil, errors.Errorf("snapshot %v does not have a report", m.ID)
+	}
+
+	r, err := rep.OpenObject(ctx, m.ReportID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open report")
+	}
+	defer r.Close() //nolint:errcheck
+
+	result := &Report{}
+	if err := json.NewDecoder(r).Decode(result); err != nil {
+		return nil, errors.Wrap(err, "unable to decode report")
+	}
+
+	return result, nil
+}
@@ -1,12 +1,15 @@
 package snapshotfs
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 )
 
@@ -26,3 +29,17 @@ func readDirEntries(r io.Reader) ([]*snapshot.DirEntry, *fs.DirectorySummary, er
 
 	return dir.Entries, dir.Summary, nil
 }
+
+// ReadDirectorySummary returns the summary stored in the directory object with the specified ID, which is nil
+// for directories written by older versions of kopia.
+func ReadDirectorySummary(ctx context.Context, rep repo.Repository, oid object.ID) (*fs.DirectorySummary, error) {
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	_, summary, err := readDirEntries(r)
+
+	return summary, err
+}
@@ -0,0 +1,42 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestReadDirectorySummary(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	summary, err := ReadDirectorySummary(ctx, th.repo, man.RootObjectID())
+	if err != nil {
+		t.Fatalf("unable to read directory summary: %v", err)
+	}
+
+	if summary == nil {
+		t.Fatalf("directory summary not found")
+	}
+
+	if got, want := summary.TotalFileSize, int64(37); got != want {
+		t.Errorf("unexpected total file size: %v, want %v", got, want)
+	}
+
+	// restoring by object ID uses the size from the summary.
+	if got, want := DirectoryEntry(th.repo, man.RootObjectID(), summary).Size(), int64(37); got != want {
+		t.Errorf("unexpected directory size: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+package snapshotfs
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// UploadEstimate summarizes the work needed to snapshot a source.
+type UploadEstimate struct {
+	Directories int   `json:"directories"`
+	Files       int   `json:"files"`
+	TotalBytes  int64 `json:"totalBytes"`
+
+	// CachedFiles are unchanged since one of the previous snapshots and won't be read.
+	CachedFiles int   `json:"cachedFiles"`
+	CachedBytes int64 `json:"cachedBytes"`
+
+	// ReadFiles need to be read and hashed, the number of uploaded bytes is at most ReadBytes
+	// and is lower when their contents are already in the repository or are compressible.
+	ReadFiles int   `json:"readFiles"`
+	ReadBytes int64 `json:"readBytes"`
+
+	ExcludedFiles int   `json:"excludedFiles"`
+	ExcludedDirs  int   `json:"excludedDirs"`
+	ExcludedBytes int64 `json:"excludedBytes"`
+
+	// Errors is the number of directories that could not be read.
+	Errors int `json:"errors"`
+}
+
+// ReadDuration returns the estimated time to read all files that need to be hashed at the provided rate in bytes per second.
+func (e *UploadEstimate) ReadDuration(bytesPerSecond int64) time.Duration {
+	if bytesPerSecond <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(e.ReadBytes) / float64(bytesPerSecond) * float64(time.Second))
+}
+
+// EstimateOption customizes the behavior of Estimate.
+type EstimateOption func(w *estimateWalker)
+
+// EstimateReportDirectory invokes the provided function for each directory before it is scanned.
+func EstimateReportDirectory(f func(relativePath string)) EstimateOption {
+	return func(w *estimateWalker) {
+		w.onDirectory = f
+	}
+}
+
+// EstimateReportFile invokes the provided function for each included file, cached is true for files unchanged since previous snapshots.
+func EstimateReportFile(f func(relativePath string, e fs.File, cached bool)) EstimateOption {
+	return func(w *estimateWalker) {
+		w.onFile = f
+	}
+}
+
+// EstimateReportIgnored invokes the provided function for each file or directory excluded by the policy.
+func EstimateReportIgnored(f func(relativePath string, e fs.Entry)) EstimateOption {
+	return func(w *estimateWalker) {
+		w.onIgnored = f
+	}
+}
+
+// Estimate walks the provided source applying ignore rules from the policy tree and reports the number of files
+// and bytes that a snapshot would read, taking into account files unchanged since the previous snapshots.
+// Nothing is written to the repository.
+func Estimate(ctx context.Context, rep repo.Repository, source fs.Entry, policyTree *policy.Tree, sourceInfo snapshot.SourceInfo, previousManifests []*snapshot.Manifest, options ...EstimateOption) (*UploadEstimate, error) {
+	w := &estimateWalker{
+		est:         &UploadEstimate{},
+		hardLinks:   map[fs.HardLinkID]bool{},
+		onDirectory: func(string) {},
+		onFile:      func(string, fs.File, bool) {},
+		onIgnored:   func(string, fs.Entry) {},
+
+		compareChangeInfo: policyTree.EffectivePolicy().UploadPolicy.CompareChangeInfoOrDefault(false),
+	}
+
+	for _, o := range options {
+		o(w)
+	}
+
+	switch e := source.(type) {
+	case fs.Directory:
+		var previousDirs []fs.Directory
+
+		for _, m := range previousManifests {
+			if d := maybeOpenDirectoryFromManifest(ctx, rep, m); d != nil {
+				previousDirs = append(previousDirs, d)
+			}
+		}
+
+		dir := ignorefs.New(e, policyTree, ignorefs.ReportIgnoredFiles(w.ignored), ignorefs.ExcludeLocalDirectories(sourceInfo.Path, KopiaDirectories(rep)...))
+
+		if err := w.estimateDirectory(ctx, ".", dir, previousDirs); err != nil {
+			return nil, errors.Wrap(err, "unable to read source directory")
+		}
+
+	case fs.File:
+		w.estimateFile(ctx, e.Name(), e, nil)
+	}
+
+	return w.est, nil
+}
+
+type estimateWalker struct {
+	est       *UploadEstimate
+	hardLinks map[fs.HardLinkID]bool
+
+	// policy of the source root, per-directory overrides are not considered.
+	compareChangeInfo bool
+
+	onDirectory func(relativePath string)
+	onFile      func(relativePath string, e fs.File, cached bool)
+	onIgnored   func(relativePath string, e fs.Entry)
+}
+
+func (w *estimateWalker) ignored(entryPath string, md fs.Entry) {
+	if md.IsDir() {
+		w.est.ExcludedDirs++
+	} else {
+		w.est.ExcludedFiles++
+		w.est.ExcludedBytes += md.Size()
+	}
+
+	// ignored paths are relative to "." unlike other paths.
+	w.onIgnored(path.Clean(entryPath), md)
+}
+
+func (w *estimateWalker) estimateDirectory(ctx context.Context, dirRelativePath string, dir fs.Directory, previousDirs []fs.Directory) error {
+	w.onDirectory(dirRelativePath)
+
+	entries, err := dir.Readdir(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.est.Directories++
+
+	var prevEntries []fs.Entries
+
+	for _, d := range previousDirs {
+		if pe, err := d.Readdir(ctx); err == nil {
+			prevEntries = append(prevEntries, pe)
+		}
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entryRelativePath := path.Join(dirRelativePath, e.Name())
+
+		switch e := e.(type) {
+		case fs.Directory:
+			var childPrevious []fs.Directory
+
+			for _, pe := range prevEntries {
+				if d, _ := pe.FindByName(e.Name()).(fs.Directory); d != nil {
+					childPrevious = append(childPrevious, d)
+				}
+			}
+
+			if err := w.estimateDirectory(ctx, entryRelativePath, e, uniqueDirectories(childPrevious)); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				log(ctx).Warningf("unable to read directory %v: %v", entryRelativePath, err)
+				w.est.Errors++
+			}
+
+		case fs.File:
+			w.estimateFile(ctx, entryRelativePath, e, prevEntries)
+		}
+	}
+
+	return nil
+}
+
+func (w *estimateWalker) estimateFile(ctx context.Context, relativePath string, f fs.File, prevEntries []fs.Entries) {
+	w.est.Files++
+	w.est.TotalBytes += f.Size()
+
+	if findCachedEntry(ctx, f, prevEntries, w.compareChangeInfo) != nil {
+		w.est.CachedFiles++
+		w.est.CachedBytes += f.Size()
+		w.onFile(relativePath, f, true)
+
+		return
+	}
+
+	w.onFile(relativePath, f, false)
+
+	if hl, ok := f.(fs.HardLinkedFile); ok {
+		if id, ok := hl.HardLinkID(); ok {
+			if w.hardLinks[id] {
+				// contents of other links to the same file are read only once.
+				return
+			}
+
+			w.hardLinks[id] = true
+		}
+	}
+
+	w.est.ReadFiles++
+	w.est.ReadBytes += f.Size()
+}
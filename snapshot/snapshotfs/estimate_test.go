@@ -0,0 +1,69 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestEstimate(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	pol := &policy.Policy{
+		FilesPolicy: policy.FilesPolicy{
+			IgnoreRules: []string{"f3"},
+		},
+	}
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{".": pol}, pol)
+
+	est, err := Estimate(ctx, th.repo, th.sourceDir, policyTree, snapshot.SourceInfo{}, nil)
+	if err != nil {
+		t.Fatalf("estimate failed: %v", err)
+	}
+
+	s1, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := est.Files, s1.Stats.TotalFileCount; got != want {
+		t.Errorf("unexpected number of files: %v, want %v", got, want)
+	}
+
+	if got, want := est.TotalBytes, s1.Stats.TotalFileSize; got != want {
+		t.Errorf("unexpected total bytes: %v, want %v", got, want)
+	}
+
+	if got, want := est.ExcludedFiles, s1.Stats.ExcludedFileCount; got != want {
+		t.Errorf("unexpected number of excluded files: %v, want %v", got, want)
+	}
+
+	if est.CachedFiles != 0 || est.ReadFiles != est.Files || est.ReadBytes != est.TotalBytes {
+		t.Errorf("all files should be read without previous snapshot: %+v", est)
+	}
+
+	th.sourceDir.AddFile("d2/added", []byte{1, 2, 3, 4, 5, 6, 7}, defaultPermissions)
+
+	est, err = Estimate(ctx, th.repo, th.sourceDir, policyTree, snapshot.SourceInfo{}, []*snapshot.Manifest{s1})
+	if err != nil {
+		t.Fatalf("estimate failed: %v", err)
+	}
+
+	if got, want := est.ReadFiles, 1; got != want {
+		t.Errorf("unexpected number of files to read: %v, want %v", got, want)
+	}
+
+	if got, want := est.ReadBytes, int64(7); got != want {
+		t.Errorf("unexpected number of bytes to read: %v, want %v", got, want)
+	}
+
+	if got, want := est.CachedFiles, s1.Stats.TotalFileCount; got != want {
+		t.Errorf("unexpected number of cached files: %v, want %v", got, want)
+	}
+}
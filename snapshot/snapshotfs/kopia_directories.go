@@ -0,0 +1,24 @@
+package snapshotfs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/kopia/kopia/internal/ospath"
+	"github.com/kopia/kopia/repo"
+)
+
+// KopiaDirectories returns local directories used by Kopia itself, which should not be included in snapshots.
+func KopiaDirectories(rep repo.Repository) []string {
+	result := []string{ospath.ConfigDir(), ospath.LogsDir()}
+
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		result = append(result, filepath.Join(cacheDir, "kopia"))
+	}
+
+	if dr, ok := rep.(*repo.DirectRepository); ok && dr.Content.CachingOptions.CacheDirectory != "" {
+		result = append(result, dr.Content.CachingOptions.CacheDirectory)
+	}
+
+	return result
+}
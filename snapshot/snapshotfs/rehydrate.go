@@ -0,0 +1,75 @@
+package snapshotfs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// RehydrateRoot requests rehydration of data packs holding contents of all files under the directory with given
+// object ID, which is necessary before restoring from storage that keeps data packs in cold storage tier,
+// and waits until all of them are readable.
+func RehydrateRoot(ctx context.Context, rep *repo.DirectRepository, oid object.ID, pollInterval time.Duration, progress func(ready, total int)) error {
+	packs := map[blob.ID]bool{}
+
+	if err := findDataPacks(ctx, rep, DirectoryEntry(rep, oid, nil), packs); err != nil {
+		return err
+	}
+
+	var blobIDs []blob.ID
+	for b := range packs {
+		blobIDs = append(blobIDs, b)
+	}
+
+	return blob.RehydrateBlobs(ctx, rep.Blobs, blobIDs, pollInterval, progress)
+}
+
+func findDataPacks(ctx context.Context, rep *repo.DirectRepository, e fs.Entry, packs map[blob.ID]bool) error {
+	switch e := e.(type) {
+	case fs.Directory:
+		entries, err := e.Readdir(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read directory %v", e.Name())
+		}
+
+		for _, child := range entries {
+			if err := findDataPacks(ctx, rep, child, packs); err != nil {
+				return err
+			}
+		}
+
+	case fs.File:
+		de, ok := e.(snapshot.HasDirEntry)
+		if !ok {
+			return nil
+		}
+
+		contentIDs, err := rep.VerifyObject(ctx, de.DirEntry().ObjectID)
+		if err != nil {
+			return errors.Wrapf(err, "unable to determine contents of %v", e.Name())
+		}
+
+		for _, cid := range contentIDs {
+			ci, err := rep.Content.ContentInfo(ctx, cid)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get content info of %v", cid)
+			}
+
+			// only data packs are placed in cold storage tier.
+			if strings.HasPrefix(string(ci.PackBlobID), string(content.PackBlobIDPrefixRegular)) {
+				packs[ci.PackBlobID] = true
+			}
+		}
+	}
+
+	return nil
+}
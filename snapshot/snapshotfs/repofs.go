@@ -30,6 +30,14 @@ func (e *repositoryEntry) Mode() os.FileMode {
 		return os.ModeDir | os.FileMode(e.metadata.Permissions)
 	case snapshot.EntryTypeSymlink:
 		return os.ModeSymlink | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeNamedPipe:
+		return os.ModeNamedPipe | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeSocket:
+		return os.ModeSocket | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeCharDevice:
+		return os.ModeDevice | os.ModeCharDevice | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeBlockDevice:
+		return os.ModeDevice | os.FileMode(e.metadata.Permissions)
 	default:
 		return os.FileMode(e.metadata.Permissions)
 	}
@@ -79,6 +87,10 @@ type repositorySymlink struct {
 	repositoryEntry
 }
 
+type repositorySpecialFile struct {
+	repositoryEntry
+}
+
 func (rd *repositoryDirectory) Summary() *fs.DirectorySummary {
 	return rd.summary
 }
@@ -118,6 +130,10 @@ func (rf *repositoryFile) Open(ctx context.Context) (fs.Reader, error) {
 		return nil, err
 	}
 
+	if sp := rf.metadata.Sparse; sp != nil {
+		return &sparseFileReader{Reader: r, e: rf, extents: sp.Extents}, nil
+	}
+
 	return withFileInfo(r, rf), nil
 }
 
@@ -137,6 +153,14 @@ func (rsl *repositorySymlink) Readlink(ctx context.Context) (string, error) {
 	return string(b), nil
 }
 
+func (rsf *repositorySpecialFile) Device() fs.DeviceInfo {
+	if rsf.metadata.Device == nil {
+		return fs.DeviceInfo{}
+	}
+
+	return *rsf.metadata.Device
+}
+
 // EntryFromDirEntry returns a filesystem entry based on the directory entry.
 func EntryFromDirEntry(r repo.Repository, md *snapshot.DirEntry) (fs.Entry, error) {
 	re := repositoryEntry{
@@ -146,9 +170,10 @@ func EntryFromDirEntry(r repo.Repository, md *snapshot.DirEntry) (fs.Entry, erro
 
 	switch md.Type {
 	case snapshot.EntryTypeDirectory:
+		// directories keep their own modification time, the latest modification time of their contents
+		// is available in the summary.
 		if md.DirSummary != nil {
 			md.FileSize = md.DirSummary.TotalFileSize
-			md.ModTime = md.DirSummary.MaxModTime
 		}
 
 		return fs.Directory(&repositoryDirectory{re, md.DirSummary}), nil
@@ -159,6 +184,9 @@ func EntryFromDirEntry(r repo.Repository, md *snapshot.DirEntry) (fs.Entry, erro
 	case snapshot.EntryTypeFile:
 		return fs.File(&repositoryFile{re}), nil
 
+	case snapshot.EntryTypeNamedPipe, snapshot.EntryTypeSocket, snapshot.EntryTypeCharDevice, snapshot.EntryTypeBlockDevice:
+		return fs.SpecialFile(&repositorySpecialFile{re}), nil
+
 	default:
 		return nil, errors.Errorf("not supported entry metadata type: %q", md.Type)
 	}
@@ -204,7 +232,32 @@ func SnapshotRoot(rep repo.Repository, man *snapshot.Manifest) (fs.Entry, error)
 var _ fs.Directory = (*repositoryDirectory)(nil)
 var _ fs.File = (*repositoryFile)(nil)
 var _ fs.Symlink = (*repositorySymlink)(nil)
+var _ fs.SpecialFile = (*repositorySpecialFile)(nil)
+
+func (rf *repositoryFile) HardLinkID() (fs.HardLinkID, bool) {
+	if rf.metadata.HardLink == nil {
+		return fs.HardLinkID{}, false
+	}
+
+	return *rf.metadata.HardLink, true
+}
+
+var _ fs.HardLinkedFile = (*repositoryFile)(nil)
+
+func (e *repositoryEntry) ExtendedAttributes() (fs.ExtendedAttributes, error) {
+	return e.metadata.ExtendedAttributes, nil
+}
+
+func (e *repositoryEntry) ACL() (*fs.ACL, error) {
+	return e.metadata.ACL, nil
+}
 
+var _ fs.ACLEntry = (*repositoryDirectory)(nil)
+var _ fs.ACLEntry = (*repositoryFile)(nil)
+var _ fs.ExtendedAttributesEntry = (*repositoryDirectory)(nil)
+var _ fs.ExtendedAttributesEntry = (*repositoryFile)(nil)
+var _ fs.ExtendedAttributesEntry = (*repositorySymlink)(nil)
 var _ snapshot.HasDirEntry = (*repositoryDirectory)(nil)
 var _ snapshot.HasDirEntry = (*repositoryFile)(nil)
 var _ snapshot.HasDirEntry = (*repositorySymlink)(nil)
+var _ snapshot.HasDirEntry = (*repositorySpecialFile)(nil)
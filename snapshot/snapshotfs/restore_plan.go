@@ -0,0 +1,154 @@
+package snapshotfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	// restorePlanSampleBytes is the number of bytes downloaded to measure storage throughput.
+	restorePlanSampleBytes = 8 << 20
+
+	// restorePlanMaxSamples is the maximum number of contents downloaded to measure storage throughput.
+	restorePlanMaxSamples = 20
+)
+
+// RestorePlan summarizes the data that needs to be downloaded to restore a directory.
+type RestorePlan struct {
+	Directories   int   `json:"directories"`
+	Files         int   `json:"files"`
+	TotalBytes    int64 `json:"totalBytes"`
+	Contents      int   `json:"contents"`
+	PackBlobs     int   `json:"packBlobs"`
+	DownloadBytes int64 `json:"downloadBytes"`
+
+	// Throughput is the measured download throughput in bytes per second, zero if it could not be measured.
+	Throughput float64 `json:"throughput"`
+}
+
+// EstimatedDuration returns the estimated time to download all data based on measured throughput
+// or zero if it's unknown.
+func (p *RestorePlan) EstimatedDuration() time.Duration {
+	if p.Throughput <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(p.DownloadBytes) / p.Throughput * float64(time.Second))
+}
+
+// PlanRestore computes the RestorePlan for the provided snapshot entry and measures storage throughput
+// by downloading a sample of the required contents.
+func PlanRestore(ctx context.Context, rep *repo.DirectRepository, root fs.Entry) (*RestorePlan, error) {
+	plan := &RestorePlan{}
+	contents := map[content.ID]content.Info{}
+
+	if err := planRestoreEntry(ctx, rep, root, plan, contents); err != nil {
+		return nil, err
+	}
+
+	packs := map[blob.ID]bool{}
+
+	for _, ci := range contents {
+		packs[ci.PackBlobID] = true
+		plan.DownloadBytes += int64(ci.Length)
+	}
+
+	plan.Contents = len(contents)
+	plan.PackBlobs = len(packs)
+
+	throughput, err := measureThroughput(ctx, rep.Blobs, contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to measure throughput")
+	}
+
+	plan.Throughput = throughput
+
+	return plan, nil
+}
+
+func planRestoreEntry(ctx context.Context, rep *repo.DirectRepository, e fs.Entry, plan *RestorePlan, contents map[content.ID]content.Info) error {
+	switch e := e.(type) {
+	case fs.Directory:
+		plan.Directories++
+
+		entries, err := e.Readdir(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read directory %v", e.Name())
+		}
+
+		for _, child := range entries {
+			if err := planRestoreEntry(ctx, rep, child, plan, contents); err != nil {
+				return err
+			}
+		}
+
+	case fs.File:
+		plan.Files++
+		plan.TotalBytes += e.Size()
+
+		de, ok := e.(snapshot.HasDirEntry)
+		if !ok {
+			return nil
+		}
+
+		contentIDs, err := rep.VerifyObject(ctx, de.DirEntry().ObjectID)
+		if err != nil {
+			return errors.Wrapf(err, "unable to determine contents of %v", e.Name())
+		}
+
+		for _, cid := range contentIDs {
+			if _, ok := contents[cid]; ok {
+				continue
+			}
+
+			ci, err := rep.Content.ContentInfo(ctx, cid)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get content info of %v", cid)
+			}
+
+			contents[cid] = ci
+		}
+	}
+
+	return nil
+}
+
+// measureThroughput downloads a sample of provided contents directly from the storage and returns
+// the observed throughput in bytes per second.
+func measureThroughput(ctx context.Context, st blob.Storage, contents map[content.ID]content.Info) (float64, error) {
+	var (
+		sampledBytes int64
+		samples      int
+	)
+
+	t0 := time.Now() // allow:no-inject-time
+
+	for _, ci := range contents {
+		if sampledBytes >= restorePlanSampleBytes || samples >= restorePlanMaxSamples {
+			break
+		}
+
+		data, err := st.GetBlob(ctx, ci.PackBlobID, int64(ci.PackOffset), int64(ci.Length))
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to read %v", ci.PackBlobID)
+		}
+
+		sampledBytes += int64(len(data))
+		samples++
+	}
+
+	dt := time.Since(t0) // allow:no-inject-time
+	if sampledBytes == 0 || dt <= 0 {
+		return 0, nil
+	}
+
+	return float64(sampledBytes) / dt.Seconds(), nil
+}
@@ -0,0 +1,64 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestPlanRestore(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	if err = th.repo.Flush(ctx); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	root, err := SnapshotRoot(th.repo, man)
+	if err != nil {
+		t.Fatalf("unable to get snapshot root: %v", err)
+	}
+
+	plan, err := PlanRestore(ctx, th.repo.(*repo.DirectRepository), root)
+	if err != nil {
+		t.Fatalf("unable to plan restore: %v", err)
+	}
+
+	if got, want := plan.Files, 10; got != want {
+		t.Errorf("unexpected number of files: %v, want %v", got, want)
+	}
+
+	if got, want := plan.Directories, 6; got != want {
+		t.Errorf("unexpected number of directories: %v, want %v", got, want)
+	}
+
+	if got, want := plan.TotalBytes, int64(37); got != want {
+		t.Errorf("unexpected total bytes: %v, want %v", got, want)
+	}
+
+	// files with identical contents are deduplicated.
+	if got, want := plan.Contents, 3; got != want {
+		t.Errorf("unexpected number of contents: %v, want %v", got, want)
+	}
+
+	if plan.PackBlobs != 1 || plan.DownloadBytes <= 0 {
+		t.Errorf("unexpected download plan: %+v", plan)
+	}
+
+	if plan.Throughput <= 0 || plan.EstimatedDuration() <= 0 {
+		t.Errorf("throughput was not measured: %+v", plan)
+	}
+}
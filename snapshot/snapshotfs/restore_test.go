@@ -0,0 +1,122 @@
+package snapshotfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestRestoreDirectoryAttributes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	sourceDir, err := ioutil.TempDir("", "kopia-source")
+	if err != nil {
+		t.Fatalf("unable to create source directory: %v", err)
+	}
+
+	defer os.RemoveAll(sourceDir) //nolint:errcheck
+
+	dirs := map[string]os.FileMode{
+		"":                 0750,
+		"empty":            0700,
+		"nested":           0755,
+		"nested/empty":     0711,
+		"nested/non-empty": 0500,
+		"read-only-parent": 0555,
+	}
+
+	for _, d := range []string{"empty", "nested/empty", "nested/non-empty", "read-only-parent"} {
+		if err = os.MkdirAll(filepath.Join(sourceDir, d), 0700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+	}
+
+	for _, f := range []string{"nested/non-empty/file", "read-only-parent/file"} {
+		if err = ioutil.WriteFile(filepath.Join(sourceDir, f), []byte(f), 0600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	// set attributes of directories bottom-up, after their contents have been written.
+	mtime := time.Date(2019, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, d := range []string{"nested/non-empty", "nested/empty", "nested", "empty", "read-only-parent", ""} {
+		p := filepath.Join(sourceDir, d)
+
+		if err = os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatalf("unable to set mtime: %v", err)
+		}
+
+		if err = os.Chmod(p, dirs[d]); err != nil {
+			t.Fatalf("unable to set permissions: %v", err)
+		}
+
+		mtime = mtime.Add(time.Hour)
+	}
+
+	// allow cleanup of read-only directories.
+	defer os.Chmod(filepath.Join(sourceDir, "nested", "non-empty"), 0700) //nolint:errcheck
+	defer os.Chmod(filepath.Join(sourceDir, "read-only-parent"), 0700)    //nolint:errcheck
+
+	src, err := localfs.Directory(sourceDir)
+	if err != nil {
+		t.Fatalf("unable to open source directory: %v", err)
+	}
+
+	man, err := NewUploader(th.repo).Upload(ctx, src, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	root, err := SnapshotRoot(th.repo, man)
+	if err != nil {
+		t.Fatalf("unable to get snapshot root: %v", err)
+	}
+
+	targetBase, err := ioutil.TempDir("", "kopia-restore")
+	if err != nil {
+		t.Fatalf("unable to create target directory: %v", err)
+	}
+
+	defer os.RemoveAll(targetBase) //nolint:errcheck
+
+	targetDir := filepath.Join(targetBase, "restored")
+
+	if err = localfs.Copy(ctx, targetDir, root, localfs.CopyOptions{}); err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+
+	defer os.Chmod(filepath.Join(targetDir, "nested", "non-empty"), 0700) //nolint:errcheck
+	defer os.Chmod(filepath.Join(targetDir, "read-only-parent"), 0700)    //nolint:errcheck
+
+	for _, d := range []string{"", "empty", "nested", "nested/empty", "nested/non-empty", "read-only-parent"} {
+		want, err := os.Stat(filepath.Join(sourceDir, d))
+		if err != nil {
+			t.Fatalf("unable to stat source: %v", err)
+		}
+
+		got, err := os.Stat(filepath.Join(targetDir, d))
+		if err != nil {
+			t.Errorf("directory %q was not restored: %v", d, err)
+			continue
+		}
+
+		if !got.ModTime().Equal(want.ModTime()) {
+			t.Errorf("unexpected mtime of %q: %v, want %v", d, got.ModTime(), want.ModTime())
+		}
+
+		if got.Mode() != want.Mode() {
+			t.Errorf("unexpected mode of %q: %v, want %v", d, got.Mode(), want.Mode())
+		}
+	}
+}
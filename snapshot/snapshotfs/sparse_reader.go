@@ -0,0 +1,25 @@
+package snapshotfs
+
+import (
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// sparseFileReader reads the object of a sparse file and reports its data extents, so that holes
+// can be skipped when restoring it.
+type sparseFileReader struct {
+	object.Reader
+
+	e       fs.Entry
+	extents []fs.Extent
+}
+
+func (r *sparseFileReader) Entry() (fs.Entry, error) {
+	return r.e, nil
+}
+
+func (r *sparseFileReader) DataExtents() ([]fs.Extent, int64, error) {
+	return r.extents, r.Length(), nil
+}
+
+var _ fs.SparseReader = (*sparseFileReader)(nil)
@@ -21,6 +21,7 @@ import (
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -36,6 +37,13 @@ var log = logging.GetContextLoggerFunc("snapshotfs")
 
 var errCanceled = errors.New("canceled")
 
+// retryFileErrorDelay is the delay between attempts to read a file that failed with an error.
+var retryFileErrorDelay = 1 * time.Second
+
+// maxUnstableFileRetries is the number of times a file that changed while being read is read again
+// before it is stored with the unstable flag.
+const maxUnstableFileRetries = 3
+
 // reasons why a snapshot is incomplete
 const (
 	IncompleteReasonCheckpoint   = "checkpoint"
@@ -45,6 +53,20 @@ const (
 
 // Uploader supports efficient uploading files and directories to repository.
 type Uploader struct {
+	// Keep int64 fields first to ensure they get aligned to at least 64-bit
+	// boundaries, which is required for atomic access on ARM and x86-32.
+	totalWrittenBytes int64
+
+	// sizes of file contents before and after compression.
+	compressionInputBytes  int64
+	compressionOutputBytes int64
+
+	// sizes of cached and hashed files and of new and deduplicated contents.
+	cachedBytes       int64
+	hashedBytes       int64
+	newBytes          int64
+	deduplicatedBytes int64
+
 	Progress UploadProgress
 
 	// automatically cancel the Upload after certain number of bytes
@@ -58,21 +80,63 @@ type Uploader struct {
 	// 100=never use cached entries
 	ForceHashPercentage int
 
-	// Number of files to hash and upload in parallel.
+	// Number of files to hash and upload in parallel, 0 uses the number of CPUs.
+	// Resulting directory manifests don't depend on it, since their entries are sorted.
 	ParallelUploads int
 
 	// How frequently to create checkpoint snapshot entries.
 	CheckpointInterval time.Duration
 
+	// Files at least this large are uploaded only after all directories and smaller files
+	// have been saved in a checkpoint, so that interrupted uploads still produce a snapshot
+	// with as many files as possible. 0 uploads files in directory order.
+	DeferredFileSize int64
+
+	// Compute breakdown of files by category and extension and store it in snapshot statistics.
+	ClassifyFiles bool
+
+	// Maximum rate at which blobs are written to the storage, which overrides the upload policy
+	// of the snapshot root. 0 uses the policy.
+	MaxUploadBytesPerSecond int64
+
+	// Reuse subdirectories which were completely uploaded by checkpoints of interrupted uploads
+	// without reading them again, as long as their modification time is unchanged. This resumes
+	// interrupted uploads where they stopped, but changes made to the reused directories since
+	// the checkpoint which don't affect their modification time are not picked up.
+	ResumeCompletedDirectories bool
+
 	repo repo.Repository
 
+	// true while the files of at least DeferredFileSize are being skipped.
+	deferringLargeFiles bool
+	deferredFileCount   int32
+
+	// details of the upload recorded in the snapshot report, entries are keyed by path since
+	// the same directory can be processed by multiple passes.
+	reportMu       sync.Mutex
+	reportErrors   map[string]*fs.EntryWithError
+	reportExcluded map[string]bool
+	checkpoints    int
+
+	// contents of hard linked files uploaded so far, which are read only once.
+	hardLinksMu sync.Mutex
+	hardLinks   map[fs.HardLinkID]uploadedContents
+
+	// object IDs of directories in incomplete previous snapshots, which can be resumed and results of
+	// comparisons of local directories with them, which are reused when resuming subdirectories.
+	resumableMu   sync.Mutex
+	resumableDirs map[object.ID]bool
+	unchangedDirs map[unchangedDirKey]bool
+
 	stats              snapshot.Stats
 	canceled           int32
 	nextCheckpointTime time.Time
 
 	uploadBufPool sync.Pool
 
-	totalWrittenBytes int64
+
+	// salt selecting unchanged files re-hashed by policy, different for each snapshot.
+	rehashSalt string
 }
 
 // IsCanceled returns true if the upload is canceled.
@@ -99,6 +163,11 @@ func (u *Uploader) incompleteReason() string {
 }
 
 func (u *Uploader) uploadFileInternal(ctx context.Context, relativePath string, f fs.File, pol *policy.Policy, asyncWrites int) (*snapshot.DirEntry, error) {
+	if fs.IsSpecialFileMode(f.Mode()) {
+		// opening named pipes or devices for reading may block forever or produce endless stream.
+		return nil, fs.ErrSpecialFile
+	}
+
 	u.Progress.HashingFile(relativePath)
 	defer u.Progress.FinishedHashingFile(relativePath, f.Size())
 
@@ -108,14 +177,28 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, relativePath string,
 	}
 	defer file.Close() //nolint:errcheck
 
+	fi1, err := file.Entry()
+	if err != nil {
+		return nil, err
+	}
+
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "FILE:" + f.Name(),
 		Compressor:  pol.CompressionPolicy.CompressorForFile(f),
+		Splitter:    pol.SplitterPolicy.Algorithm,
 		AsyncWrites: asyncWrites,
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, file, 0, f.Size())
+	var written int64
+
+	extents, sparseSize := sparseExtents(ctx, file)
+	if extents != nil {
+		written, extents, err = u.copyExtentsWithProgress(writer, file, extents, sparseSize)
+	} else {
+		written, err = u.copyWithProgress(writer, file, 0, f.Size())
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -130,16 +213,107 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, relativePath string,
 		return nil, err
 	}
 
+	cs := writer.CompressionStats()
+	atomic.AddInt64(&u.compressionInputBytes, cs.InputBytes)
+	atomic.AddInt64(&u.compressionOutputBytes, cs.OutputBytes)
+
+	ds := writer.DeduplicationStats()
+	atomic.AddInt64(&u.hashedBytes, written)
+	atomic.AddInt64(&u.newBytes, ds.NewBytes)
+	atomic.AddInt64(&u.deduplicatedBytes, ds.DeduplicatedBytes)
+
 	de, err := newDirEntry(fi2, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
 
+	// files reached through followed symlinks are stored under the name of the symlink.
+	de.Name = f.Name()
 	de.FileSize = written
+	de.Unstable = fi1.Size() != fi2.Size() || !fi1.ModTime().Equal(fi2.ModTime())
+
+	if pol.UploadPolicy.CompareChangeInfoOrDefault(false) {
+		recordChangeInfo(de, fi2)
+	}
+
+	if extents != nil {
+		de.Sparse = &snapshot.SparseFile{Extents: extents}
+	}
 
 	return de, nil
 }
 
+// maxSparseFileExtents is the maximum number of data extents of a file uploaded as sparse, files with more
+// extents are read in full to keep directory listings small.
+const maxSparseFileExtents = 1000
+
+// sparseExtents returns data extents and size of a sparse file or nil extents if the file has no holes.
+func sparseExtents(ctx context.Context, file fs.Reader) ([]fs.Extent, int64) {
+	sr, ok := file.(fs.SparseReader)
+	if !ok {
+		return nil, 0
+	}
+
+	extents, size, err := sr.DataExtents()
+	if err != nil {
+		log(ctx).Debugf("unable to find data extents, reading the whole file: %v", err)
+		return nil, 0
+	}
+
+	if len(extents) > maxSparseFileExtents {
+		return nil, 0
+	}
+
+	return extents, size
+}
+
+// copyExtentsWithProgress copies a sparse file reading only its data extents and writing zeros for the holes,
+// so that the object is identical to the file. Returns the extents actually copied, which are shorter
+// if the file has been truncated in the meantime.
+func (u *Uploader) copyExtentsWithProgress(dst io.Writer, file io.ReadSeeker, extents []fs.Extent, size int64) (int64, []fs.Extent, error) {
+	var written int64
+
+	for i, e := range extents {
+		n, err := u.copyWithProgress(dst, io.LimitReader(zeroReader{}, e.Offset-written), written, size)
+		written += n
+
+		if err != nil {
+			return written, nil, err
+		}
+
+		if _, err := file.Seek(e.Offset, io.SeekStart); err != nil {
+			return written, nil, errors.Wrap(err, "unable to seek to data extent")
+		}
+
+		n, err = u.copyWithProgress(dst, io.LimitReader(file, e.Length), written, size)
+		written += n
+
+		if err != nil {
+			return written, nil, err
+		}
+
+		if n < e.Length {
+			return written, append(extents[:i:i], fs.Extent{Offset: e.Offset, Length: n}), nil
+		}
+	}
+
+	n, err := u.copyWithProgress(dst, io.LimitReader(zeroReader{}, size-written), written, size)
+	written += n
+
+	return written, extents, err
+}
+
+// zeroReader returns zeros for the holes of sparse files.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+
+	return len(b), nil
+}
+
 func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath string, f fs.Symlink) (*snapshot.DirEntry, error) {
 	u.Progress.HashingFile(relativePath)
 	defer u.Progress.FinishedHashingFile(relativePath, f.Size())
@@ -223,17 +397,35 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, len
 }
 
 func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
-	var entryType snapshot.EntryType
+	var (
+		entryType snapshot.EntryType
+		device    *fs.DeviceInfo
+		hardLink  *fs.HardLinkID
+	)
 
 	switch md := md.(type) {
 	case fs.Directory:
 		entryType = snapshot.EntryTypeDirectory
 	case fs.Symlink:
 		entryType = snapshot.EntryTypeSymlink
+	case fs.SpecialFile:
+		entryType = specialEntryType(md.Mode())
+
+		if md.Mode()&os.ModeDevice != 0 {
+			di := md.Device()
+			device = &di
+		}
 	case fs.File:
 		entryType = snapshot.EntryTypeFile
+
+		// record the identity of hard linked files, so that they can be restored as hard links.
+		if hl, ok := md.(fs.HardLinkedFile); ok {
+			if id, ok := hl.HardLinkID(); ok {
+				hardLink = &id
+			}
+		}
 	default:
-		return nil, errors.Errorf("invalid entry type %T", md)
+		return nil, errors.Wrapf(fs.ErrUnsupportedEntryType, "invalid entry type %T", md)
 	}
 
 	return &snapshot.DirEntry{
@@ -245,9 +437,78 @@ func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
 		UserID:      md.Owner().UserID,
 		GroupID:     md.Owner().GroupID,
 		ObjectID:    oid,
+		Device:      device,
+		HardLink:    hardLink,
 	}, nil
 }
 
+func specialEntryType(m os.FileMode) snapshot.EntryType {
+	switch {
+	case m&os.ModeNamedPipe != 0:
+		return snapshot.EntryTypeNamedPipe
+	case m&os.ModeSocket != 0:
+		return snapshot.EntryTypeSocket
+	case m&os.ModeCharDevice != 0:
+		return snapshot.EntryTypeCharDevice
+	default:
+		return snapshot.EntryTypeBlockDevice
+	}
+}
+
+// uploadSpecialFile records metadata of a named pipe, socket or device node without reading its contents.
+func (u *Uploader) uploadSpecialFile(ctx context.Context, relativePath string, f fs.SpecialFile) (*snapshot.DirEntry, error) {
+	log(ctx).Debugf("recording special file %v (%v)", relativePath, f.Mode())
+
+	de, err := newDirEntry(f, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.FileSize = 0
+
+	return de, nil
+}
+
+// uploadFileWithRetries uploads the specified file, retrying failed attempts as many times as allowed by the error handling policy.
+func (u *Uploader) uploadFileWithRetries(ctx context.Context, relativePath string, f fs.File, pol *policy.Policy, asyncWrites int) (*snapshot.DirEntry, error) {
+	retries := pol.ErrorHandlingPolicy.RetryFileErrorsOrDefault(0)
+	unstableRetries := 0
+
+	for attempt := 0; ; attempt++ {
+		de, err := u.uploadFileInternal(ctx, relativePath, f, pol, asyncWrites)
+		if err == nil && de.Unstable {
+			if unstableRetries < maxUnstableFileRetries {
+				unstableRetries++
+				attempt--
+
+				log(ctx).Debugf("%v changed while being read, reading it again", relativePath)
+
+				continue
+			}
+
+			log(ctx).Warningf("%v kept changing while being read, its contents may be inconsistent", relativePath)
+			atomic.AddInt32(&u.stats.UnstableFiles, 1)
+		}
+
+		if err == nil || attempt >= retries || !isRetriableFileError(err) {
+			return de, err
+		}
+
+		log(ctx).Warningf("error reading %v (attempt %v of %v), retrying: %v", relativePath, attempt+1, retries+1, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryFileErrorDelay):
+		}
+	}
+}
+
+// isRetriableFileError returns true if the error returned while uploading a file may go away on another attempt.
+func isRetriableFileError(err error) bool {
+	return err != errCanceled && !errors.Is(err, blob.ErrQuotaExceeded) && !errors.Is(err, fs.ErrSpecialFile)
+}
+
 // uploadFile uploads the specified File to the repository.
 func (u *Uploader) uploadFile(ctx context.Context, relativePath string, file fs.File, pol *policy.Policy) (*snapshot.DirEntry, error) {
 	par := u.effectiveParallelUploads()
@@ -255,7 +516,7 @@ func (u *Uploader) uploadFile(ctx context.Context, relativePath string, file fs.
 		par = 0
 	}
 
-	res, err := u.uploadFileInternal(ctx, relativePath, file, pol, par)
+	res, err := u.uploadFileWithRetries(ctx, relativePath, file, pol, par)
 	if err != nil {
 		return nil, err
 	}
@@ -265,6 +526,8 @@ func (u *Uploader) uploadFile(ctx context.Context, relativePath string, file fs.
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
 
+	u.addExtendedMetadata(ctx, de, file, pol)
+
 	de.DirSummary = &fs.DirectorySummary{
 		TotalFileCount: 1,
 		TotalFileSize:  res.FileSize,
@@ -276,6 +539,8 @@ func (u *Uploader) uploadFile(ctx context.Context, relativePath string, file fs.
 
 // uploadDirWithCheckpointing uploads the specified Directory to the repository.
 func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Directory, policyTree *policy.Tree, previousDirs []fs.Directory, sourceInfo snapshot.SourceInfo) (*snapshot.DirEntry, error) {
+	u.deferringLargeFiles = u.DeferredFileSize > 0
+
 	for {
 		if u.CheckpointInterval != 0 {
 			u.nextCheckpointTime = u.repo.Time().Add(u.CheckpointInterval)
@@ -285,16 +550,29 @@ func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Di
 
 		startTime := u.repo.Time()
 
+		if u.ClassifyFiles {
+			// each pass visits all files of the snapshot.
+			u.stats.Classification = snapshot.NewClassification()
+		}
+
 		oid, summ, err := uploadDirInternal(ctx, u, rootDir, policyTree, previousDirs, ".")
 		if err != nil && err != errCanceled {
 			return nil, err
 		}
 
+		if u.deferringLargeFiles && summ.IncompleteReason == "" && atomic.LoadInt32(&u.deferredFileCount) > 0 {
+			// everything but large files has been uploaded, save it as a checkpoint and upload them
+			// in the next pass, which reuses all other files.
+			u.deferringLargeFiles = false
+			summ.IncompleteReason = IncompleteReasonCheckpoint
+		}
+
 		de, err := newDirEntry(rootDir, oid)
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to create dir entry")
 		}
 
+		u.addExtendedMetadata(ctx, de, rootDir, policyTree.EffectivePolicy())
 		de.DirSummary = &summ
 
 		if summ.IncompleteReason == IncompleteReasonCheckpoint {
@@ -302,6 +580,7 @@ func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Di
 
 			// when retrying use the partial snapshot
 			previousDirs = append(previousDirs, DirectoryEntry(u.repo, oid, &summ))
+			u.markResumable(oid)
 
 			man := &snapshot.Manifest{
 				StartTime:        startTime,
@@ -315,6 +594,8 @@ func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Di
 				return nil, errors.Wrap(err, "error saving checkpoint")
 			}
 
+			u.checkpoints++
+
 			if err = u.repo.Flush(ctx); err != nil {
 				return nil, errors.Wrap(err, "error flushing saving checkpoint")
 			}
@@ -404,6 +685,11 @@ func (u *Uploader) populateChildEntries(parent *snapshot.DirManifest, children <
 		case snapshot.EntryTypeFile:
 			u.stats.TotalFileCount++
 			u.stats.TotalFileSize += de.FileSize
+
+			if c := u.stats.Classification; c != nil {
+				c.AddFile(de.Name, de.FileSize)
+			}
+
 			parentSummary.TotalFileCount++
 			parentSummary.TotalFileSize += de.FileSize
 
@@ -506,8 +792,14 @@ func (u *Uploader) processSubdirectories(ctx context.Context, output chan dirEnt
 
 		previousDirs = uniqueDirectories(previousDirs)
 
+		if de := u.maybeResumeDirectory(ctx, dir, previousDirs, entryRelativePath); de != nil {
+			u.Progress.FinishedEntry(entryRelativePath, EntryActionCached, de.DirSummary.TotalFileSize, nil)
+			output <- dirEntryOrError{de: de}
+			return nil
+		}
+
 		oid, subdirsumm, err := uploadDirInternal(ctx, u, dir, policyTree.Child(entry.Name()), previousDirs, entryRelativePath)
-		if err == errCanceled {
+		if err == errCanceled || errors.Is(err, blob.ErrQuotaExceeded) {
 			return err
 		}
 
@@ -520,11 +812,9 @@ func (u *Uploader) processSubdirectories(ctx context.Context, output chan dirEnt
 				rc := rootCauseError(dre.error)
 
 				u.Progress.IgnoredError(entryRelativePath, rc)
+				u.Progress.FinishedEntry(entryRelativePath, EntryActionError, 0, rc)
 				output <- dirEntryOrError{
-					failedEntry: &fs.EntryWithError{
-						EntryPath: entryRelativePath,
-						Error:     rc.Error(),
-					},
+					failedEntry: u.recordIgnoredError(entryRelativePath, rc),
 				}
 				return nil
 			}
@@ -537,6 +827,7 @@ func (u *Uploader) processSubdirectories(ctx context.Context, output chan dirEnt
 		}
 
 		de.DirSummary = &subdirsumm
+		u.Progress.FinishedEntry(entryRelativePath, EntryActionUploaded, subdirsumm.TotalFileSize, nil)
 		output <- dirEntryOrError{de: de}
 		return nil
 	})
@@ -562,9 +853,21 @@ func metadataEquals(e1, e2 fs.Entry) bool {
 	return true
 }
 
-func findCachedEntry(ctx context.Context, entry fs.Entry, prevEntries []fs.Entries) fs.Entry {
+// findCachedEntry returns the entry with the same name and metadata from previous snapshots, which can be reused
+// without reading the file. When compareChangeInfo is set, status change time and inode number must match as well.
+func findCachedEntry(ctx context.Context, entry fs.Entry, prevEntries []fs.Entries, compareChangeInfo bool) fs.Entry {
 	for _, e := range prevEntries {
 		if ent := e.FindByName(entry.Name()); ent != nil {
+			if hd, ok := ent.(snapshot.HasDirEntry); ok && hd.DirEntry().Unstable {
+				// contents of files that changed while being read are never reused.
+				continue
+			}
+
+			if compareChangeInfo && !changeInfoEquals(entry, ent) {
+				log(ctx).Debugf("found entry with different change info for %v", entry.Name())
+				continue
+			}
+
 			if metadataEquals(entry, ent) {
 				return ent
 			}
@@ -578,21 +881,60 @@ func findCachedEntry(ctx context.Context, entry fs.Entry, prevEntries []fs.Entri
 	return nil
 }
 
-// objectIDPercent arbitrarily maps given object ID onto a number 0.99
-func objectIDPercent(obj object.ID) int {
+// changeInfoEquals returns true if the status change time and inode number of the entry match the ones recorded
+// in the previous snapshot. Entries which can't report them are considered equal.
+func changeInfoEquals(entry, prev fs.Entry) bool {
+	ce, ok := entry.(fs.ChangeInfoEntry)
+	if !ok {
+		return true
+	}
+
+	ci, ok := ce.ChangeInfo()
+	if !ok {
+		return true
+	}
+
+	hd, ok := prev.(snapshot.HasDirEntry)
+	if !ok || hd.DirEntry().ChangeInfo == nil {
+		return false
+	}
+
+	prevCI := hd.DirEntry().ChangeInfo
+
+	return ci.ChangeTime.Equal(prevCI.ChangeTime) && ci.Inode == prevCI.Inode
+}
+
+// recordChangeInfo stores the status change time and inode number of the entry for comparison in future snapshots.
+func recordChangeInfo(de *snapshot.DirEntry, e fs.Entry) {
+	if ce, ok := e.(fs.ChangeInfoEntry); ok {
+		if ci, ok := ce.ChangeInfo(); ok {
+			de.ChangeInfo = &ci
+		}
+	}
+}
+
+// objectIDPercent arbitrarily maps given object ID and salt onto a number 0.99
+func objectIDPercent(obj object.ID, salt string) int {
 	h := fnv.New32a()
+	io.WriteString(h, salt)         //nolint:errcheck
 	io.WriteString(h, obj.String()) //nolint:errcheck
 
 	return int(h.Sum32() % 100) //nolint:gomnd
 }
 
-func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry) fs.Entry {
+func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry, pol *policy.Policy) fs.Entry {
 	if h, ok := ent.(object.HasObjectID); ok {
-		if objectIDPercent(h.ObjectID()) < u.ForceHashPercentage {
+		if objectIDPercent(h.ObjectID(), "") < u.ForceHashPercentage {
 			log(ctx).Debugf("ignoring valid cached object: %v", h.ObjectID())
 			return nil
 		}
 
+		// files re-hashed by policy are different in each snapshot.
+		if objectIDPercent(h.ObjectID(), u.rehashSalt) < pol.UploadPolicy.RehashUnchangedPercentageOrDefault(0) {
+			log(ctx).Debugf("re-hashing unchanged object: %v", h.ObjectID())
+			return nil
+		}
+
 		return ent
 	}
 
@@ -631,10 +973,31 @@ func (u *Uploader) processNonDirectories(ctx context.Context, output chan dirEnt
 			return nil
 		}
 
+		entryPolicy := policyTree.Child(entry.Name()).EffectivePolicy()
+
+		if sl, ok := entry.(fs.Symlink); ok && entryPolicy.FilesPolicy.FollowSymlinksOrDefault(false) {
+			entry = followSymlink(ctx, sl, entryRelativePath)
+		}
+
+		if sf, ok := entry.(fs.SpecialFile); ok {
+			de, err := u.uploadSpecialFile(ctx, entryRelativePath, sf)
+			if err != nil {
+				return u.maybeIgnoreFileReadError(err, output, entryRelativePath, policyTree)
+			}
+
+			u.Progress.FinishedEntry(entryRelativePath, EntryActionUploaded, de.FileSize, nil)
+			output <- dirEntryOrError{de: de}
+			return nil
+		}
+
 		// See if we had this name during either of previous passes.
-		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entry, prevEntries)); cachedEntry != nil {
+		compareChangeInfo := entryPolicy.UploadPolicy.CompareChangeInfoOrDefault(false)
+
+		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entry, prevEntries, compareChangeInfo), entryPolicy); cachedEntry != nil {
 			atomic.AddInt32(&u.stats.CachedFiles, 1)
+			atomic.AddInt64(&u.cachedBytes, entry.Size())
 			u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
+			u.Progress.FinishedEntry(entryRelativePath, EntryActionCached, entry.Size(), nil)
 
 			// compute entryResult now, cachedEntry is short-lived
 			cachedDirEntry, err := newDirEntry(entry, cachedEntry.(object.HasObjectID).ObjectID())
@@ -642,6 +1005,14 @@ func (u *Uploader) processNonDirectories(ctx context.Context, output chan dirEnt
 				return errors.Wrap(err, "unable to create dir entry")
 			}
 
+			if hd, ok := cachedEntry.(snapshot.HasDirEntry); ok {
+				cachedDirEntry.Sparse = hd.DirEntry().Sparse
+			}
+
+			if compareChangeInfo {
+				recordChangeInfo(cachedDirEntry, entry)
+			}
+
 			output <- dirEntryOrError{de: cachedDirEntry}
 			return nil
 		}
@@ -653,25 +1024,303 @@ func (u *Uploader) processNonDirectories(ctx context.Context, output chan dirEnt
 				return u.maybeIgnoreFileReadError(err, output, entryRelativePath, policyTree)
 			}
 
+			u.Progress.FinishedEntry(entryRelativePath, EntryActionUploaded, de.FileSize, nil)
 			output <- dirEntryOrError{de: de}
 			return nil
 
 		case fs.File:
+			if u.deferringLargeFiles && entry.Size() >= u.DeferredFileSize {
+				log(ctx).Debugf("deferring upload of %v (%v bytes)", entryRelativePath, entry.Size())
+				atomic.AddInt32(&u.deferredFileCount, 1)
+
+				return nil
+			}
+
+			if uc, ok := u.uploadedHardLink(entry); ok {
+				atomic.AddInt32(&u.stats.CachedFiles, 1)
+				atomic.AddInt64(&u.cachedBytes, entry.Size())
+				u.Progress.CachedFile(entryRelativePath, entry.Size())
+				u.Progress.FinishedEntry(entryRelativePath, EntryActionCached, entry.Size(), nil)
+
+				de, err := newDirEntry(entry, uc.objectID)
+				if err != nil {
+					return errors.Wrap(err, "unable to create dir entry")
+				}
+
+				de.Sparse = uc.sparse
+
+				if compareChangeInfo {
+					recordChangeInfo(de, entry)
+				}
+
+				output <- dirEntryOrError{de: de}
+				return nil
+			}
+
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
-			de, err := u.uploadFileInternal(ctx, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy(), asyncWritesPerFile)
+			de, err := u.uploadFileWithRetries(ctx, entryRelativePath, entry, entryPolicy, asyncWritesPerFile)
 			if err != nil {
 				return u.maybeIgnoreFileReadError(err, output, entryRelativePath, policyTree)
 			}
 
+			u.rememberHardLink(de)
+
+			u.Progress.FinishedEntry(entryRelativePath, EntryActionUploaded, de.FileSize, nil)
 			output <- dirEntryOrError{de: de}
 			return nil
 
 		default:
-			return errors.Errorf("file type not supported: %v", entry.Mode())
+			return errors.Wrapf(fs.ErrUnsupportedEntryType, "%v (%v)", entryRelativePath, entry.Mode())
 		}
 	})
 }
 
+// addExtendedMetadata records ACLs and extended attributes of the provided entry, the latter unless disabled
+// by the policy. They are not essential, so failures to read them are only logged.
+func (u *Uploader) addExtendedMetadata(ctx context.Context, de *snapshot.DirEntry, e fs.Entry, pol *policy.Policy) {
+	if ae, ok := e.(fs.ACLEntry); ok {
+		acl, err := ae.ACL()
+		if err != nil {
+			log(ctx).Warningf("unable to read ACL of %v: %v", de.Name, err)
+		}
+
+		de.ACL = acl
+	}
+
+	if !pol.FilesPolicy.ExtendedAttributesOrDefault(true) {
+		return
+	}
+
+	xe, ok := e.(fs.ExtendedAttributesEntry)
+	if !ok {
+		return
+	}
+
+	attrs, err := xe.ExtendedAttributes()
+	if err != nil {
+		log(ctx).Warningf("unable to read extended attributes of %v: %v", de.Name, err)
+		return
+	}
+
+	de.ExtendedAttributes = attrs
+}
+
+// uploadedContents describes contents of an uploaded file.
+type uploadedContents struct {
+	objectID object.ID
+	sparse   *snapshot.SparseFile
+}
+
+// uploadedHardLink returns contents of the provided file if it's a hard link to contents already uploaded.
+func (u *Uploader) uploadedHardLink(f fs.File) (uploadedContents, bool) {
+	hl, ok := f.(fs.HardLinkedFile)
+	if !ok {
+		return uploadedContents{}, false
+	}
+
+	id, ok := hl.HardLinkID()
+	if !ok {
+		return uploadedContents{}, false
+	}
+
+	u.hardLinksMu.Lock()
+	defer u.hardLinksMu.Unlock()
+
+	uc, ok := u.hardLinks[id]
+
+	return uc, ok
+}
+
+func (u *Uploader) rememberHardLink(de *snapshot.DirEntry) {
+	if de.HardLink == nil || de.Unstable {
+		return
+	}
+
+	u.hardLinksMu.Lock()
+	defer u.hardLinksMu.Unlock()
+
+	u.hardLinks[*de.HardLink] = uploadedContents{de.ObjectID, de.Sparse}
+}
+
+func (u *Uploader) markResumable(oid object.ID) {
+	u.resumableMu.Lock()
+	defer u.resumableMu.Unlock()
+
+	u.resumableDirs[oid] = true
+}
+
+func (u *Uploader) isResumable(d fs.Directory) bool {
+	u.resumableMu.Lock()
+	defer u.resumableMu.Unlock()
+
+	return u.resumableDirs[d.(object.HasObjectID).ObjectID()]
+}
+
+// maybeResumeDirectory returns the entry for the provided directory, which reuses a complete directory
+// from a checkpoint of an interrupted upload or nil if there's no such directory.
+// The directory is only reused if all its entries, including ones in subdirectories, are unchanged.
+func (u *Uploader) maybeResumeDirectory(ctx context.Context, dir fs.Directory, previousDirs []fs.Directory, dirRelativePath string) *snapshot.DirEntry {
+	if !u.ResumeCompletedDirectories {
+		return nil
+	}
+
+	for _, d := range previousDirs {
+		summ := d.Summary()
+		if summ == nil || summ.IncompleteReason != "" || summ.NumFailed > 0 || !d.ModTime().Equal(dir.ModTime()) || !u.isResumable(d) {
+			continue
+		}
+
+		// files modified in place don't change the modification time of the directory.
+		unchanged, err := u.entriesUnchanged(ctx, dir, d, dirRelativePath)
+		if err != nil {
+			log(ctx).Warningf("unable to compare %v with checkpoint: %v", dirRelativePath, err)
+			return nil
+		}
+
+		if !unchanged {
+			log(ctx).Debugf("not resuming changed directory %v", dirRelativePath)
+			continue
+		}
+
+		de, err := newDirEntry(dir, d.(object.HasObjectID).ObjectID())
+		if err != nil {
+			log(ctx).Warningf("unable to create dir entry for %v: %v", dirRelativePath, err)
+			return nil
+		}
+
+		log(ctx).Debugf("resuming completed directory %v", dirRelativePath)
+
+		s := *summ
+		de.DirSummary = &s
+
+		u.stats.TotalDirectoryCount += int(s.TotalDirCount)
+		u.stats.TotalFileCount += int(s.TotalFileCount)
+		u.stats.TotalFileSize += s.TotalFileSize
+		atomic.AddInt32(&u.stats.CachedFiles, int32(s.TotalFileCount))
+		atomic.AddInt64(&u.cachedBytes, s.TotalFileSize)
+
+		return de
+	}
+
+	return nil
+}
+
+// unchangedDirKey identifies comparison of a local directory with a directory from a checkpoint.
+type unchangedDirKey struct {
+	prev         object.ID
+	relativePath string
+}
+
+// entriesUnchanged returns true if all entries of the previous directory and its subdirectories still exist
+// in the local directory with the same metadata. Entries that were added locally change the modification
+// time of their parent directory, which is compared as well.
+// Results are memoized, so that subdirectories compared while checking their parent are not compared again
+// when the parent turns out to be changed and its subdirectories are considered for resuming.
+func (u *Uploader) entriesUnchanged(ctx context.Context, local, prev fs.Directory, relativePath string) (bool, error) {
+	key := unchangedDirKey{prev.(object.HasObjectID).ObjectID(), relativePath}
+
+	u.resumableMu.Lock()
+	unchanged, ok := u.unchangedDirs[key]
+	u.resumableMu.Unlock()
+
+	if ok {
+		return unchanged, nil
+	}
+
+	unchanged, err := u.compareEntries(ctx, local, prev, relativePath)
+	if err != nil {
+		return false, err
+	}
+
+	u.resumableMu.Lock()
+	u.unchangedDirs[key] = unchanged
+	u.resumableMu.Unlock()
+
+	return unchanged, nil
+}
+
+func (u *Uploader) compareEntries(ctx context.Context, local, prev fs.Directory, relativePath string) (bool, error) {
+	localEntries, err := local.Readdir(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read local directory")
+	}
+
+	prevEntries, err := prev.Readdir(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read previous directory")
+	}
+
+	for _, pe := range prevEntries {
+		if hd, ok := pe.(snapshot.HasDirEntry); ok && hd.DirEntry().Unstable {
+			return false, nil
+		}
+
+		le := localEntries.FindByName(pe.Name())
+		if le == nil || !resumedMetadataEquals(le, pe) {
+			return false, nil
+		}
+
+		pd, ok := pe.(fs.Directory)
+		if !ok {
+			continue
+		}
+
+		ld, ok := le.(fs.Directory)
+		if !ok {
+			return false, nil
+		}
+
+		if unchanged, err := u.entriesUnchanged(ctx, ld, pd, path.Join(relativePath, pe.Name())); err != nil || !unchanged {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// resumedMetadataEquals compares local entry with the entry from a checkpoint. Sizes of directories and special
+// files are not stored as reported by the file system, so only their remaining metadata is compared.
+func resumedMetadataEquals(local, prev fs.Entry) bool {
+	if prev.IsDir() || prev.Mode()&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice) != 0 {
+		return local.Mode() == prev.Mode() && local.ModTime().Equal(prev.ModTime()) && local.Owner() == prev.Owner()
+	}
+
+	return metadataEquals(local, prev)
+}
+
+// symlinkTarget is a file reached through a followed symlink, which is named after the symlink.
+type symlinkTarget struct {
+	fs.File
+	name string
+}
+
+func (t symlinkTarget) Name() string {
+	return t.name
+}
+
+// followSymlink returns the file the symlink points to or the symlink itself when it can't be resolved
+// or points to anything else than a regular file.
+func followSymlink(ctx context.Context, sl fs.Symlink, entryRelativePath string) fs.Entry {
+	rs, ok := sl.(fs.ResolvableSymlink)
+	if !ok {
+		return sl
+	}
+
+	target, err := rs.Resolve(ctx)
+	if err != nil {
+		log(ctx).Warningf("unable to follow symlink %v, storing the link instead: %v", entryRelativePath, err)
+		return sl
+	}
+
+	f, ok := target.(fs.File)
+	if !ok || !target.Mode().IsRegular() {
+		log(ctx).Debugf("not following symlink %v to %v", entryRelativePath, target.Mode())
+		return sl
+	}
+
+	return symlinkTarget{f, sl.Name()}
+}
+
 func maybeReadDirectoryEntries(ctx context.Context, dir fs.Directory) fs.Entries {
 	if dir == nil {
 		return nil
@@ -746,6 +1395,15 @@ func uploadDirInternal(
 	for _, d := range uniqueDirectories(previousDirs) {
 		if ent := maybeReadDirectoryEntries(ctx, d); ent != nil {
 			prevEntries = append(prevEntries, ent)
+
+			if u.ResumeCompletedDirectories && u.isResumable(d) {
+				// subdirectories of resumable directories are resumable too.
+				for _, e := range ent {
+					if sd, ok := e.(fs.Directory); ok {
+						u.markResumable(sd.(object.HasObjectID).ObjectID())
+					}
+				}
+			}
 		}
 	}
 
@@ -757,6 +1415,12 @@ func uploadDirInternal(
 		dirManifest.Summary.MaxModTime = directory.ModTime()
 	}
 
+	for _, de := range dirManifest.Entries {
+		if e := entries.FindByName(de.Name); e != nil {
+			u.addExtendedMetadata(ctx, de, e, policyTree.Child(de.Name).EffectivePolicy())
+		}
+	}
+
 	// at this point dirManifest is ready to go
 
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
@@ -780,13 +1444,16 @@ func uploadDirInternal(
 func (u *Uploader) maybeIgnoreFileReadError(err error, output chan dirEntryOrError, entryRelativePath string, policyTree *policy.Tree) error {
 	errHandlingPolicy := policyTree.EffectivePolicy().ErrorHandlingPolicy
 
+	if errors.Is(err, blob.ErrQuotaExceeded) {
+		// no further data can be written, fail the upload instead of each remaining file.
+		return err
+	}
+
 	if u.IgnoreReadErrors || errHandlingPolicy.IgnoreFileErrorsOrDefault(false) {
 		err = rootCauseError(err)
 		u.Progress.IgnoredError(entryRelativePath, err)
-		output <- dirEntryOrError{failedEntry: &fs.EntryWithError{
-			EntryPath: entryRelativePath,
-			Error:     err.Error(),
-		}}
+		u.Progress.FinishedEntry(entryRelativePath, EntryActionError, 0, err)
+		output <- dirEntryOrError{failedEntry: u.recordIgnoredError(entryRelativePath, err)}
 
 		return nil
 	}
@@ -794,6 +1461,64 @@ func (u *Uploader) maybeIgnoreFileReadError(err error, output chan dirEntryOrErr
 	return err
 }
 
+// recordIgnoredError remembers the ignored error for the snapshot report and returns the failed entry.
+func (u *Uploader) recordIgnoredError(entryRelativePath string, err error) *fs.EntryWithError {
+	e := &fs.EntryWithError{
+		EntryPath: entryRelativePath,
+		Error:     err.Error(),
+	}
+
+	u.reportMu.Lock()
+	defer u.reportMu.Unlock()
+
+	if _, ok := u.reportErrors[entryRelativePath]; ok || len(u.reportErrors) < snapshot.MaxReportedItems {
+		u.reportErrors[entryRelativePath] = e
+	}
+
+	return e
+}
+
+func (u *Uploader) recordExcluded(entryPath string) {
+	u.reportMu.Lock()
+	defer u.reportMu.Unlock()
+
+	if len(u.reportExcluded) < snapshot.MaxReportedItems {
+		u.reportExcluded[entryPath] = true
+	}
+}
+
+// report returns the report of the upload which produced the provided manifest.
+func (u *Uploader) report(man *snapshot.Manifest) *snapshot.Report {
+	u.reportMu.Lock()
+	defer u.reportMu.Unlock()
+
+	r := &snapshot.Report{
+		Source:           man.Source,
+		StartTime:        man.StartTime,
+		EndTime:          man.EndTime,
+		Duration:         man.EndTime.Sub(man.StartTime),
+		IncompleteReason: man.IncompleteReason,
+		Checkpoints:      u.checkpoints,
+		Stats:            man.Stats,
+	}
+
+	for _, e := range u.reportErrors {
+		r.Errors = append(r.Errors, e)
+	}
+
+	sort.Slice(r.Errors, func(i, j int) bool {
+		return r.Errors[i].EntryPath < r.Errors[j].EntryPath
+	})
+
+	for p := range u.reportExcluded {
+		r.Excluded = append(r.Excluded, p)
+	}
+
+	sort.Strings(r.Excluded)
+
+	return r
+}
+
 func (u *Uploader) shouldIgnoreDirectoryReadErrors(policyTree *policy.Tree) bool {
 	errHandlingPolicy := policyTree.EffectivePolicy().ErrorHandlingPolicy
 
@@ -810,7 +1535,6 @@ func NewUploader(r repo.Repository) *Uploader {
 		repo:               r,
 		Progress:           &NullUploadProgress{},
 		IgnoreReadErrors:   false,
-		ParallelUploads:    1,
 		CheckpointInterval: DefaultCheckpointInterval,
 		uploadBufPool: sync.Pool{
 			New: func() interface{} {
@@ -827,12 +1551,12 @@ func (u *Uploader) Cancel() {
 	atomic.StoreInt32(&u.canceled, 1)
 }
 
-func (u *Uploader) maybeOpenDirectoryFromManifest(ctx context.Context, man *snapshot.Manifest) fs.Directory {
+func maybeOpenDirectoryFromManifest(ctx context.Context, rep repo.Repository, man *snapshot.Manifest) fs.Directory {
 	if man == nil {
 		return nil
 	}
 
-	ent, err := EntryFromDirEntry(u.repo, man.RootEntry)
+	ent, err := EntryFromDirEntry(rep, man.RootEntry)
 	if err != nil {
 		log(ctx).Warningf("invalid previous manifest root entry %v: %v", man.RootEntry, err)
 		return nil
@@ -847,6 +1571,20 @@ func (u *Uploader) maybeOpenDirectoryFromManifest(ctx context.Context, man *snap
 	return dir
 }
 
+// uploadRateLimiter is implemented by repositories which can limit the rate at which blobs are written.
+type uploadRateLimiter interface {
+	SetMaxUploadBytesPerSecond(rate int64)
+}
+
+// uploadRate returns the maximum upload rate in bytes per second, 0 is unlimited.
+func (u *Uploader) uploadRate(policyTree *policy.Tree) int64 {
+	if u.MaxUploadBytesPerSecond > 0 {
+		return u.MaxUploadBytesPerSecond
+	}
+
+	return policyTree.EffectivePolicy().UploadPolicy.MaxUploadBytesPerSecond
+}
+
 // Upload uploads contents of the specified filesystem entry (file or directory) to the repository and returns snapshot.Manifest with statistics.
 // Old snapshot manifest, when provided can be used to speed up uploads by utilizing hash cache.
 func (u *Uploader) Upload(
@@ -879,29 +1617,64 @@ func (u *Uploader) Upload(
 	defer u.Progress.UploadFinished()
 
 	u.stats = snapshot.Stats{}
+	if u.ClassifyFiles {
+		u.stats.Classification = snapshot.NewClassification()
+	}
+
 	u.totalWrittenBytes = 0
+	u.compressionInputBytes = 0
+	u.compressionOutputBytes = 0
+	u.cachedBytes = 0
+	u.hashedBytes = 0
+	u.newBytes = 0
+	u.deduplicatedBytes = 0
+	u.reportErrors = map[string]*fs.EntryWithError{}
+	u.reportExcluded = map[string]bool{}
+	u.checkpoints = 0
+	u.resumableDirs = map[object.ID]bool{}
+	u.unchangedDirs = map[unchangedDirKey]bool{}
+	u.hardLinks = map[fs.HardLinkID]uploadedContents{}
+
+	if l, ok := u.repo.(uploadRateLimiter); ok {
+		// the limit stays in effect after the upload, so that blobs written by the subsequent flush are throttled too.
+		l.SetMaxUploadBytesPerSecond(u.uploadRate(policyTree))
+	} else if u.uploadRate(policyTree) > 0 {
+		log(ctx).Warningf("upload throttling is not supported by the repository")
+	}
 
 	var err error
 
 	s.StartTime = u.repo.Time()
+	u.rehashSalt = s.StartTime.String()
+	s.Splitter = policyTree.EffectivePolicy().SplitterPolicy.Algorithm
 
 	switch entry := source.(type) {
 	case fs.Directory:
 		var previousDirs []fs.Directory
 
 		for _, m := range previousManifests {
-			if d := u.maybeOpenDirectoryFromManifest(ctx, m); d != nil {
+			if d := maybeOpenDirectoryFromManifest(ctx, u.repo, m); d != nil {
 				previousDirs = append(previousDirs, d)
+
+				if m.IncompleteReason != "" {
+					u.markResumable(d.(object.HasObjectID).ObjectID())
+				}
 			}
 		}
 
-		entry = ignorefs.New(entry, policyTree, ignorefs.ReportIgnoredFiles(func(_ string, md fs.Entry) {
+		entry = ignorefs.New(entry, policyTree, ignorefs.ReportIgnoredFiles(func(entryPath string, md fs.Entry) {
 			u.stats.AddExcluded(md)
-		}))
+			u.recordExcluded(entryPath)
+			// ignored paths are relative to "." unlike other uploader paths.
+			u.Progress.FinishedEntry(path.Clean(entryPath), EntryActionIgnored, md.Size(), nil)
+		}), ignorefs.ExcludeLocalDirectories(sourceInfo.Path, KopiaDirectories(u.repo)...))
 		s.RootEntry, err = u.uploadDirWithCheckpointing(ctx, entry, policyTree, previousDirs, sourceInfo)
 
 	case fs.File:
 		s.RootEntry, err = u.uploadFile(ctx, entry.Name(), entry, policyTree.EffectivePolicy())
+		if err == nil && u.stats.Classification != nil {
+			u.stats.Classification.AddFile(s.RootEntry.Name, s.RootEntry.FileSize)
+		}
 
 	default:
 		return nil, errors.Errorf("unsupported source: %v", s.Source)
@@ -914,6 +1687,16 @@ func (u *Uploader) Upload(
 	s.IncompleteReason = u.incompleteReason()
 	s.EndTime = u.repo.Time()
 	s.Stats = u.stats
+	s.Stats.CompressionInputBytes = atomic.LoadInt64(&u.compressionInputBytes)
+	s.Stats.CompressionOutputBytes = atomic.LoadInt64(&u.compressionOutputBytes)
+	s.Stats.CachedBytes = atomic.LoadInt64(&u.cachedBytes)
+	s.Stats.HashedBytes = atomic.LoadInt64(&u.hashedBytes)
+	s.Stats.NewBytes = atomic.LoadInt64(&u.newBytes)
+	s.Stats.DeduplicatedBytes = atomic.LoadInt64(&u.deduplicatedBytes)
+
+	if s.ReportID, err = snapshot.WriteReport(ctx, u.repo, u.report(s)); err != nil {
+		return nil, errors.Wrap(err, "unable to write snapshot report")
+	}
 
 	return s, nil
 }
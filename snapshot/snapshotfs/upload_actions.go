@@ -0,0 +1,203 @@
+package snapshotfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// Environment variables passed to snapshot actions.
+const (
+	actionSourcePathEnv   = "KOPIA_SOURCE_PATH"   // path of the snapshot source
+	actionSnapshotIDEnv   = "KOPIA_SNAPSHOT_ID"   // random identifier shared by actions of a single snapshot
+	actionSnapshotPathEnv = "KOPIA_SNAPSHOT_PATH" // directory being uploaded, may be changed by the before action
+)
+
+const actionSnapshotIDLength = 8
+
+// maxActionOutputSize is the maximum size of action output stored in the snapshot manifest.
+const maxActionOutputSize = 64 << 10
+
+// SnapshotRootActions represents actions invoked around a single snapshot of a source root.
+type SnapshotRootActions struct {
+	policy     policy.ActionsPolicy
+	source     snapshot.SourceInfo
+	snapshotID string
+	ended      bool
+	output     snapshot.ActionOutput
+
+	// SnapshotPath is the local directory to upload, which is the source path unless
+	// changed by the before-snapshot-root action.
+	SnapshotPath string
+}
+
+// BeginSnapshotRoot runs the before-snapshot-root action configured in the policy and returns the
+// actions whose End() must be called after the snapshot of the source completes.
+// Unless actions are enabled for the repository connection, they are logged and skipped.
+func BeginSnapshotRoot(ctx context.Context, pol policy.ActionsPolicy, source snapshot.SourceInfo, enabled bool) (*SnapshotRootActions, error) {
+	var id [actionSnapshotIDLength]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, errors.Wrap(err, "unable to generate snapshot ID")
+	}
+
+	a := &SnapshotRootActions{
+		policy:       pol,
+		source:       source,
+		snapshotID:   hex.EncodeToString(id[:]),
+		SnapshotPath: source.Path,
+	}
+
+	if !enabled && (pol.BeforeSnapshotRoot != "" || pol.AfterSnapshotRoot != "") {
+		log(ctx).Warningf("not running snapshot actions of %v, because they are not enabled for this repository connection, reconnect with --enable-actions to run them", source)
+
+		a.policy = policy.ActionsPolicy{}
+
+		return a, nil
+	}
+
+	if pol.BeforeSnapshotRoot == "" {
+		return a, nil
+	}
+
+	out, err := a.run(ctx, pol.BeforeSnapshotRoot)
+	a.output.BeforeSnapshotRoot = truncateActionOutput(out)
+
+	if err == nil {
+		err = a.parseBeforeSnapshotRootOutput(ctx, out)
+	}
+
+	if err = a.handleFailure(ctx, errors.Wrap(err, "before-snapshot-root action failed")); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *SnapshotRootActions) parseBeforeSnapshotRootOutput(ctx context.Context, out []byte) error {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, actionSnapshotPathEnv+"=") {
+			continue
+		}
+
+		p := strings.TrimPrefix(line, actionSnapshotPathEnv+"=")
+
+		if !filepath.IsAbs(p) {
+			return errors.Errorf("relative snapshot path: %q", p)
+		}
+
+		log(ctx).Infof("snapshotting %v from %v", a.source.Path, p)
+
+		a.SnapshotPath = p
+	}
+
+	return nil
+}
+
+// End runs the after-snapshot-root action configured in the policy. Subsequent calls do nothing,
+// so End can be deferred to clean up after failures in addition to being called on success.
+func (a *SnapshotRootActions) End(ctx context.Context) error {
+	if a.ended || a.policy.AfterSnapshotRoot == "" {
+		return nil
+	}
+
+	a.ended = true
+
+	out, err := a.run(ctx, a.policy.AfterSnapshotRoot)
+	a.output.AfterSnapshotRoot = truncateActionOutput(out)
+
+	return a.handleFailure(ctx, errors.Wrap(err, "after-snapshot-root action failed"))
+}
+
+// Output returns the output of actions that have run so far to be stored in the snapshot manifest
+// or nil if there was none.
+func (a *SnapshotRootActions) Output() *snapshot.ActionOutput {
+	if a.output == (snapshot.ActionOutput{}) {
+		return nil
+	}
+
+	o := a.output
+
+	return &o
+}
+
+// handleFailure returns the error if the policy requires actions to succeed, otherwise logs it.
+func (a *SnapshotRootActions) handleFailure(ctx context.Context, err error) error {
+	if err == nil || a.policy.FailureModeOrDefault() == policy.ActionFailureModeFail {
+		return err
+	}
+
+	log(ctx).Warningf("%v, continuing", err)
+
+	return nil
+}
+
+func truncateActionOutput(out []byte) string {
+	if len(out) > maxActionOutputSize {
+		out = out[:maxActionOutputSize]
+	}
+
+	return string(out)
+}
+
+func (a *SnapshotRootActions) run(ctx context.Context, command string) ([]byte, error) {
+	if a.policy.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(a.policy.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	// standard output is captured in a file rather than a pipe, which would be kept open by processes
+	// started in the background by the action and delay its completion.
+	stdout, err := ioutil.TempFile("", "kopia-action")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create action output file")
+	}
+
+	defer os.Remove(stdout.Name()) //nolint:errcheck
+	defer stdout.Close()           //nolint:errcheck
+
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		actionSourcePathEnv+"="+a.source.Path,
+		actionSnapshotIDEnv+"="+a.snapshotID,
+		actionSnapshotPathEnv+"="+a.SnapshotPath,
+	)
+
+	log(ctx).Debugf("running action: %v", command)
+
+	runErr := cmd.Run()
+
+	out, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read action output")
+	}
+
+	return out, errors.Wrap(runErr, command)
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd.exe", "/c", command) // nolint:gosec
+	}
+
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command) // nolint:gosec
+}
@@ -0,0 +1,154 @@
+package snapshotfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestSnapshotRootActions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("actions are shell scripts")
+	}
+
+	ctx := testlogging.Context(t)
+
+	dir, err := ioutil.TempDir("", "kopia-actions")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	mountPoint := filepath.Join(dir, "mnt")
+	afterOutput := filepath.Join(dir, "after.txt")
+
+	src := snapshot.SourceInfo{Path: "/some/source"}
+
+	a, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{
+		BeforeSnapshotRoot: "echo preparing; echo $KOPIA_SNAPSHOT_ID > " + filepath.Join(dir, "before.txt") + "; echo KOPIA_SNAPSHOT_PATH=" + mountPoint,
+		AfterSnapshotRoot:  "echo $KOPIA_SNAPSHOT_ID $KOPIA_SOURCE_PATH $KOPIA_SNAPSHOT_PATH > " + afterOutput,
+	}, src, true)
+	if err != nil {
+		t.Fatalf("before action failed: %v", err)
+	}
+
+	if got, want := a.SnapshotPath, mountPoint; got != want {
+		t.Errorf("unexpected snapshot path: %v, want %v", got, want)
+	}
+
+	if err = a.End(ctx); err != nil {
+		t.Fatalf("after action failed: %v", err)
+	}
+
+	before, err := ioutil.ReadFile(filepath.Join(dir, "before.txt"))
+	if err != nil {
+		t.Fatalf("before action output not found: %v", err)
+	}
+
+	after, err := ioutil.ReadFile(afterOutput)
+	if err != nil {
+		t.Fatalf("after action output not found: %v", err)
+	}
+
+	if got, want := strings.TrimSpace(string(after)), strings.TrimSpace(string(before))+" /some/source "+mountPoint; got != want {
+		t.Errorf("unexpected after action environment: %q, want %q", got, want)
+	}
+
+	if got, want := a.Output(), (&snapshot.ActionOutput{BeforeSnapshotRoot: "preparing\nKOPIA_SNAPSHOT_PATH=" + mountPoint + "\n"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected action output: %v, want %v", got, want)
+	}
+
+	if _, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{BeforeSnapshotRoot: "exit 1"}, src, true); err == nil {
+		t.Errorf("expected error from failing before action")
+	}
+
+	if _, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{BeforeSnapshotRoot: "echo KOPIA_SNAPSHOT_PATH=relative"}, src, true); err == nil {
+		t.Errorf("expected error from relative snapshot path")
+	}
+
+	if _, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{BeforeSnapshotRoot: "sleep 3", TimeoutSeconds: 1}, src, true); err == nil {
+		t.Errorf("expected timeout error")
+	}
+}
+
+func TestSnapshotRootActionsContinueOnFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("actions are shell scripts")
+	}
+
+	ctx := testlogging.Context(t)
+	src := snapshot.SourceInfo{Path: "/some/source"}
+
+	a, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{
+		BeforeSnapshotRoot: "echo KOPIA_SNAPSHOT_PATH=/other; exit 1",
+		AfterSnapshotRoot:  "echo cleaning up; exit 1",
+		FailureMode:        policy.ActionFailureModeContinue,
+	}, src, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// path printed by failed action is ignored.
+	if got, want := a.SnapshotPath, src.Path; got != want {
+		t.Errorf("unexpected snapshot path: %v, want %v", got, want)
+	}
+
+	if err := a.End(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := a.Output().AfterSnapshotRoot, "cleaning up\n"; got != want {
+		t.Errorf("unexpected after action output: %q, want %q", got, want)
+	}
+}
+
+func TestSnapshotRootActionsDisabled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("actions are shell scripts")
+	}
+
+	ctx := testlogging.Context(t)
+
+	dir, err := ioutil.TempDir("", "kopia-actions")
+	if err != nil {
+		t.Fatalf("unable to create temp directory: %v", err)
+	}
+
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	marker := filepath.Join(dir, "marker.txt")
+	src := snapshot.SourceInfo{Path: "/some/source"}
+
+	a, err := BeginSnapshotRoot(ctx, policy.ActionsPolicy{
+		BeforeSnapshotRoot: "touch " + marker + "; echo KOPIA_SNAPSHOT_PATH=/other",
+		AfterSnapshotRoot:  "touch " + marker,
+	}, src, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := a.SnapshotPath, src.Path; got != want {
+		t.Errorf("unexpected snapshot path: %v, want %v", got, want)
+	}
+
+	if err := a.End(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("disabled action was run: %v", err)
+	}
+
+	if got := a.Output(); got != nil {
+		t.Errorf("unexpected action output: %v", got)
+	}
+}
@@ -5,6 +5,17 @@ import (
 	"sync/atomic"
 )
 
+// EntryAction describes the outcome of processing a file or directory during upload.
+type EntryAction string
+
+// Supported entry actions.
+const (
+	EntryActionCached   EntryAction = "cached"   // reused from a previous snapshot without reading
+	EntryActionUploaded EntryAction = "uploaded" // contents read, hashed and written to the repository
+	EntryActionIgnored  EntryAction = "ignored"  // excluded from the snapshot by policy
+	EntryActionError    EntryAction = "error"    // left out of the snapshot due to an ignored error
+)
+
 // UploadProgress is invoked by by uploader to report status of file and directory uploads.
 type UploadProgress interface {
 	// UploadStarted is emitted once at the start of an upload
@@ -39,6 +50,9 @@ type UploadProgress interface {
 
 	// Checkpoint is emitted whenever snapshot is checkpointed.
 	Checkpoint()
+
+	// FinishedEntry is emitted once processing of a file or directory has finished with the provided action.
+	FinishedEntry(path string, action EntryAction, numBytes int64, err error)
 }
 
 // NullUploadProgress is an implementation of UploadProgress that does not produce any output.
@@ -78,6 +92,9 @@ func (p *NullUploadProgress) IgnoredError(path string, err error) {}
 // Checkpoint implements UploadProgress
 func (p *NullUploadProgress) Checkpoint() {}
 
+// FinishedEntry implements UploadProgress
+func (p *NullUploadProgress) FinishedEntry(path string, action EntryAction, numBytes int64, err error) {}
+
 var _ UploadProgress = (*NullUploadProgress)(nil)
 
 // UploadCounters represents a snapshot of upload counters.
@@ -0,0 +1,54 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func TestCopyExtentsWithProgress(t *testing.T) {
+	file := []byte("\x00\x00abc\x00\x00\x00de\x00\x00")
+	extents := []fs.Extent{{Offset: 2, Length: 3}, {Offset: 8, Length: 2}}
+
+	u := NewUploader(nil)
+
+	var buf bytes.Buffer
+
+	src := bytes.NewReader(file)
+
+	written, copied, err := u.copyExtentsWithProgress(&buf, src, extents, int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if written != int64(len(file)) {
+		t.Errorf("unexpected number of bytes written: %v, want %v", written, len(file))
+	}
+
+	if !bytes.Equal(buf.Bytes(), file) {
+		t.Errorf("unexpected object contents: %q, want %q", buf.Bytes(), file)
+	}
+
+	if len(copied) != len(extents) {
+		t.Errorf("unexpected extents: %v, want %v", copied, extents)
+	}
+
+	// file truncated after its extents were found.
+	buf.Reset()
+
+	src = bytes.NewReader(file[:9])
+
+	written, copied, err = u.copyExtentsWithProgress(&buf, src, extents, int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if written != 9 || !bytes.Equal(buf.Bytes(), file[:9]) {
+		t.Errorf("unexpected contents of truncated file: %q", buf.Bytes())
+	}
+
+	if want := []fs.Extent{{Offset: 2, Length: 3}, {Offset: 8, Length: 1}}; len(copied) != 2 || copied[1] != want[1] {
+		t.Errorf("unexpected extents of truncated file: %v, want %v", copied, want)
+	}
+}
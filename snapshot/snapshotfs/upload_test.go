@@ -2,10 +2,12 @@ package snapshotfs
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
 	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/internal/testlogging"
@@ -199,6 +202,105 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+func TestUpload_DeduplicationStats(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 1 // identical files uploaded in parallel could both be reported as new
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	s1, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	// 10 files, of which 3 have distinct contents of 3, 4 and 5 bytes.
+	if got := s1.Stats; got.CachedBytes != 0 || got.HashedBytes != 37 || got.NewBytes != 12 || got.DeduplicatedBytes != 25 {
+		t.Errorf("unexpected s1 stats: %+v", got)
+	}
+
+	s2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, s1)
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	if got := s2.Stats; got.CachedBytes != 37 || got.HashedBytes != 0 || got.NewBytes != 0 || got.DeduplicatedBytes != 0 {
+		t.Errorf("unexpected s2 stats: %+v", got)
+	}
+
+	// without previous snapshots all files are hashed, but their contents are already in the repository.
+	s3, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	if got := s3.Stats; got.CachedBytes != 0 || got.HashedBytes != 37 || got.NewBytes != 0 || got.DeduplicatedBytes != 37 {
+		t.Errorf("unexpected s3 stats: %+v", got)
+	}
+}
+
+func TestUpload_CacheMatching(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	ctime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := th.sourceDir.AddFile("f4", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+	f.SetChangeInfo(fs.ChangeInfo{ChangeTime: ctime, Inode: 5})
+
+	upload := func(pol policy.UploadPolicy, previous ...*snapshot.Manifest) *snapshot.Manifest {
+		t.Helper()
+
+		policyTree := policy.BuildTree(map[string]*policy.Policy{".": {UploadPolicy: pol}}, policy.DefaultPolicy)
+
+		man, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, previous...)
+		if err != nil {
+			t.Fatalf("upload error: %v", err)
+		}
+
+		return man
+	}
+
+	compareChangeInfo := true
+	strict := policy.UploadPolicy{CompareChangeInfo: &compareChangeInfo}
+
+	s1 := upload(strict)
+	s2 := upload(strict, s1)
+
+	if got, want := s2.Stats.NonCachedFiles, int32(0); got != want {
+		t.Errorf("unexpected non-cached files: %v, want %v", got, want)
+	}
+
+	// modification which preserved modification time and size is only detected by comparing change info.
+	f.SetChangeInfo(fs.ChangeInfo{ChangeTime: ctime.Add(time.Second), Inode: 5})
+
+	if got, want := upload(policy.UploadPolicy{}, s2).Stats.NonCachedFiles, int32(0); got != want {
+		t.Errorf("unexpected non-cached files without comparing change info: %v, want %v", got, want)
+	}
+
+	s3 := upload(strict, s2)
+	if got, want := s3.Stats.NonCachedFiles, int32(1); got != want {
+		t.Errorf("unexpected non-cached files with comparing change info: %v, want %v", got, want)
+	}
+
+	// files without change info are compared using remaining metadata.
+	if got, want := s3.Stats.CachedFiles, int32(10); got != want {
+		t.Errorf("unexpected cached files: %v, want %v", got, want)
+	}
+
+	rehashAll := 100
+
+	if got, want := upload(policy.UploadPolicy{RehashUnchangedPercentage: &rehashAll}, s3).Stats.NonCachedFiles, int32(11); got != want {
+		t.Errorf("unexpected non-cached files when re-hashing all unchanged files: %v, want %v", got, want)
+	}
+}
+
 func TestUpload_TopLevelDirectoryReadFailure(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx)
@@ -239,6 +341,142 @@ func TestUpload_SubDirectoryReadFailure(t *testing.T) {
 	}
 }
 
+func TestUpload_RetryFileErrors(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	defer func(d time.Duration) { retryFileErrorDelay = d }(retryFileErrorDelay)
+
+	retryFileErrorDelay = 0
+
+	th.sourceDir.AddFile("retried", []byte{1, 2, 3}, defaultPermissions).FailOpen(2, errTest)
+	th.sourceDir.AddFile("failed", []byte{4, 5, 6}, defaultPermissions).FailOpen(3, errTest)
+
+	u := NewUploader(th.repo)
+
+	trueValue := true
+	twoRetries := 2
+
+	pol := &policy.Policy{
+		ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
+			IgnoreFileErrors: &trueValue,
+			RetryFileErrors:  &twoRetries,
+		},
+	}
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{".": pol}, pol)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantErrors := []*fs.EntryWithError{
+		{EntryPath: "failed", Error: errTest.Error()},
+	}
+
+	if diff := pretty.Compare(man.RootEntry.DirSummary.FailedEntries, wantErrors); diff != "" {
+		t.Errorf("unexpected failed entries, diff(-got,+want): %v\n", diff)
+	}
+}
+
+func TestUpload_FileChangedWhileReading(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("stabilized", []byte{1, 2, 3}, defaultPermissions).ChangeWhileReading(maxUnstableFileRetries)
+	th.sourceDir.AddFile("unstable", []byte{4, 5, 6}, defaultPermissions).ChangeWhileReading(maxUnstableFileRetries + 1)
+
+	u := NewUploader(th.repo)
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := man.Stats.UnstableFiles, int32(1); got != want {
+		t.Errorf("unexpected number of unstable files: %v, want %v", got, want)
+	}
+
+	entries, err := DirectoryEntry(th.repo, man.RootObjectID(), nil).Readdir(ctx)
+	if err != nil {
+		t.Fatalf("unable to read root directory: %v", err)
+	}
+
+	for name, want := range map[string]bool{"stabilized": false, "unstable": true} {
+		e := entries.FindByName(name)
+		if e == nil {
+			t.Fatalf("entry %v not found", name)
+		}
+
+		if got := e.(snapshot.HasDirEntry).DirEntry().Unstable; got != want {
+			t.Errorf("unexpected unstable flag of %v: %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestUpload_VirtualSource(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	root := virtualfs.NewStaticDirectory("virtual", []fs.Entry{
+		virtualfs.StreamingFileFromReader("dump.sql", strings.NewReader("table contents"), modTime),
+		virtualfs.NewStaticDirectory("logs", []fs.Entry{
+			virtualfs.StreamingFileFromReader("app.log", strings.NewReader("log line"), modTime),
+		}),
+	})
+
+	man, err := NewUploader(th.repo).Upload(ctx, root, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{Path: "/virtual"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if got, want := man.Stats.TotalFileCount, 2; got != want {
+		t.Errorf("unexpected number of files: %v, want %v", got, want)
+	}
+
+	dir := DirectoryEntry(th.repo, man.RootObjectID(), nil)
+
+	for p, want := range map[string]string{
+		"dump.sql":     "table contents",
+		"logs/app.log": "log line",
+	} {
+		var e fs.Entry = dir
+
+		for _, name := range strings.Split(p, "/") {
+			if e, err = e.(fs.Directory).Child(ctx, name); err != nil {
+				t.Fatalf("unable to find %v: %v", p, err)
+			}
+		}
+
+		r, err := e.(fs.File).Open(ctx)
+		if err != nil {
+			t.Fatalf("unable to open %v: %v", p, err)
+		}
+
+		b, err := ioutil.ReadAll(r)
+		r.Close() //nolint:errcheck
+
+		if err != nil {
+			t.Fatalf("unable to read %v: %v", p, err)
+		}
+
+		if got := string(b); got != want {
+			t.Errorf("unexpected contents of %v: %q, want %q", p, got, want)
+		}
+	}
+}
+
 func objectIDsEqual(o1, o2 object.ID) bool {
 	return reflect.DeepEqual(o1, o2)
 }
@@ -256,12 +494,17 @@ func TestUpload_SubDirectoryReadFailureIgnoreFailures(t *testing.T) {
 
 	trueValue := true
 
-	policyTree := policy.BuildTree(nil, &policy.Policy{
+	pol := &policy.Policy{
 		ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
 			IgnoreFileErrors:      &trueValue,
 			IgnoreDirectoryErrors: &trueValue,
 		},
-	})
+		FilesPolicy: policy.FilesPolicy{
+			IgnoreRules: []string{"f3"},
+		},
+	}
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{".": pol}, pol)
 
 	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
 	if err != nil {
@@ -281,6 +524,86 @@ func TestUpload_SubDirectoryReadFailureIgnoreFailures(t *testing.T) {
 	if diff := pretty.Compare(man.RootEntry.DirSummary.FailedEntries, wantErrors); diff != "" {
 		t.Errorf("unexpected directory tree, diff(-got,+want): %v\n", diff)
 	}
+
+	report, err := snapshot.LoadReport(ctx, th.repo, man)
+	if err != nil {
+		t.Fatalf("unable to load report: %v", err)
+	}
+
+	if diff := pretty.Compare(report.Errors, wantErrors); diff != "" {
+		t.Errorf("unexpected report errors, diff(-got,+want): %v\n", diff)
+	}
+
+	if got, want := report.Excluded, []string{"./f3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected excluded entries: %v, want %v", got, want)
+	}
+
+	if got, want := report.Stats, man.Stats; got != want {
+		t.Errorf("unexpected report stats: %v, want %v", got, want)
+	}
+}
+
+func TestUpload_SpecialFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddSpecialFile("d1/fifo", os.ModeNamedPipe|0600, fs.DeviceInfo{})
+	th.sourceDir.AddSpecialFile("d1/null", os.ModeDevice|os.ModeCharDevice|0666, fs.DeviceInfo{Major: 1, Minor: 3})
+
+	u := NewUploader(th.repo)
+
+	man, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	d1, err := DirectoryEntry(th.repo, man.RootObjectID(), nil).Child(ctx, "d1")
+	if err != nil {
+		t.Fatalf("unable to find d1: %v", err)
+	}
+
+	entries, err := d1.(fs.Directory).Readdir(ctx)
+	if err != nil {
+		t.Fatalf("unable to read d1: %v", err)
+	}
+
+	fifo, ok := entries.FindByName("fifo").(fs.SpecialFile)
+	if !ok {
+		t.Fatalf("fifo not recorded as special file: %v", entries.FindByName("fifo"))
+	}
+
+	if got, want := fifo.Mode(), os.ModeNamedPipe|0600; got != want {
+		t.Errorf("unexpected fifo mode: %v, want %v", got, want)
+	}
+
+	null, ok := entries.FindByName("null").(fs.SpecialFile)
+	if !ok {
+		t.Fatalf("device not recorded as special file: %v", entries.FindByName("null"))
+	}
+
+	if got, want := null.Device(), (fs.DeviceInfo{Major: 1, Minor: 3}); got != want {
+		t.Errorf("unexpected device: %v, want %v", got, want)
+	}
+
+	// now upload again with policy that ignores special files.
+	trueValue := true
+
+	man, err = u.Upload(ctx, th.sourceDir, policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				IgnoreSpecialFiles: &trueValue,
+			},
+		},
+	}, policy.DefaultPolicy), snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("upload error: %v", err)
+	}
+
+	if got, want := man.Stats.ExcludedFileCount, 2; got != want {
+		t.Errorf("unexpected number of excluded files: %v, want %v", got, want)
+	}
 }
 
 // nolint:gocyclo
@@ -332,3 +655,350 @@ func TestUploadWithCheckpointing(t *testing.T) {
 		}
 	}
 }
+
+func TestUploadResumeCompletedDirectories(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	full, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{Path: "full"})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	// interrupt the upload after d1 has been completed.
+	u := NewUploader(th.repo)
+	th.sourceDir.Subdir("d2").OnReaddir(u.Cancel)
+
+	interrupted, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	if got, want := interrupted.IncompleteReason, IncompleteReasonCanceled; got != want {
+		t.Fatalf("unexpected incompleteReason %q, want %q", got, want)
+	}
+
+	th.sourceDir.Subdir("d2").OnReaddir(func() {})
+
+	d1Reads := 0
+
+	th.sourceDir.Subdir("d1").OnReaddir(func() {
+		d1Reads++
+	})
+
+	u = NewUploader(th.repo)
+	u.ResumeCompletedDirectories = true
+
+	resumed, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, interrupted)
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	// completed directory is only listed to compare it with the checkpoint.
+	if d1Reads != 1 {
+		t.Errorf("completed directory was read again %v times", d1Reads)
+	}
+
+	if got, want := resumed.RootObjectID(), full.RootObjectID(); got != want {
+		t.Errorf("unexpected root of resumed snapshot: %v, want %v", got, want)
+	}
+
+	if got, want := resumed.Stats.TotalFileCount, full.Stats.TotalFileCount; got != want {
+		t.Errorf("unexpected file count of resumed snapshot: %v, want %v", got, want)
+	}
+
+	// complete snapshots are never resumed.
+	if _, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, resumed); err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	if d1Reads != 2 {
+		t.Errorf("directory of complete snapshot was read %v times", d1Reads)
+	}
+}
+
+func TestUploadResumeChangedDirectories(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	// interrupt the upload after d1 has been completed.
+	u := NewUploader(th.repo)
+	th.sourceDir.Subdir("d2").OnReaddir(u.Cancel)
+
+	interrupted, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	th.sourceDir.Subdir("d2").OnReaddir(func() {})
+
+	// file modified in place does not change the modification time of its directory.
+	th.sourceDir.Subdir("d1", "d1").Remove("f1")
+	th.sourceDir.AddFile("d1/d1/f1", []byte{9, 9, 9, 9, 9, 9}, defaultPermissions)
+
+	full, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{Path: "full"})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	u = NewUploader(th.repo)
+	u.ResumeCompletedDirectories = true
+
+	resumed, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, interrupted)
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	if got, want := resumed.RootObjectID(), full.RootObjectID(); got != want {
+		t.Errorf("resumed snapshot reused changed directory: %v, want %v", got, want)
+	}
+}
+
+func TestUploadResumeComparesDirectoriesOnce(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	// interrupt the upload after d1 has been completed.
+	u := NewUploader(th.repo)
+	th.sourceDir.Subdir("d2").OnReaddir(u.Cancel)
+
+	interrupted, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	th.sourceDir.Subdir("d2").OnReaddir(func() {})
+
+	// d1 is changed because of d1/d2, its unchanged subdirectory d1/d1 is compared before that.
+	th.sourceDir.Subdir("d1", "d2").Remove("f1")
+	th.sourceDir.AddFile("d1/d2/f1", []byte{9, 9, 9, 9, 9, 9}, defaultPermissions)
+
+	d1d1Reads := 0
+
+	th.sourceDir.Subdir("d1", "d1").OnReaddir(func() {
+		d1d1Reads++
+	})
+
+	u = NewUploader(th.repo)
+	u.ResumeCompletedDirectories = true
+
+	if _, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, interrupted); err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	if d1d1Reads != 1 {
+		t.Errorf("unchanged subdirectory of changed directory was read %v times, want 1", d1d1Reads)
+	}
+}
+
+func TestUploadThrottling(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	const (
+		fileSize    = 300000
+		uploadSpeed = 200000
+	)
+
+	th.sourceDir.AddFile("large", make([]byte, fileSize), defaultPermissions)
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{
+		".": {UploadPolicy: policy.UploadPolicy{MaxUploadBytesPerSecond: uploadSpeed}},
+	}, policy.DefaultPolicy)
+
+	t0 := time.Now()
+
+	if _, err := NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}); err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	// uploaded contents are written to the storage when flushed.
+	if err := th.repo.Flush(ctx); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	if dt, min := time.Since(t0), fileSize/uploadSpeed*time.Second; dt < min {
+		t.Errorf("upload was not throttled, took %v, want at least %v", dt, min)
+	}
+}
+
+func TestUploadWithDeferredLargeFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.DeferredFileSize = 5
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	si := snapshot.SourceInfo{
+		UserName: "user",
+		Host:     "host",
+		Path:     "path",
+	}
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, si)
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+
+	if got, want := len(snapshots), 1; got != want {
+		t.Fatalf("unexpected number of checkpoints: %v, want %v", got, want)
+	}
+
+	if got, want := snapshots[0].IncompleteReason, IncompleteReasonCheckpoint; got != want {
+		t.Errorf("unexpected incompleteReason %q, want %q", got, want)
+	}
+
+	// the checkpoint has everything except the large file.
+	verifyRootEntries(ctx, t, th.repo, snapshots[0], "d1", "d2", "f1", "f2")
+	verifyRootEntries(ctx, t, th.repo, man, "d1", "d2", "f1", "f2", "f3")
+
+	// no checkpoint is made when there are no large files.
+	u = NewUploader(th.repo)
+	u.DeferredFileSize = 1000
+
+	if _, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{Path: "other"}); err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	snapshots, err = snapshot.ListSnapshots(ctx, th.repo, snapshot.SourceInfo{Path: "other"})
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+
+	if len(snapshots) != 0 {
+		t.Fatalf("unexpected checkpoints: %v", snapshots)
+	}
+}
+
+func TestUploadWithClassification(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("d1/doc.pdf", []byte{1, 2}, defaultPermissions)
+	th.sourceDir.AddFile("d2/d1/pic.JPG", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+
+	u := NewUploader(th.repo)
+	u.ClassifyFiles = true
+
+	// files visited again after the checkpoint must not be counted twice.
+	u.DeferredFileSize = 6
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	c := man.Stats.Classification
+	if c == nil {
+		t.Fatalf("missing classification")
+	}
+
+	want := map[string]snapshot.ClassificationStats{
+		snapshot.CategoryDocuments: {FileCount: 1, TotalFileSize: 2},
+		snapshot.CategoryMedia:     {FileCount: 1, TotalFileSize: 6},
+		snapshot.CategoryOther:     {FileCount: 10, TotalFileSize: 37},
+	}
+
+	if !reflect.DeepEqual(c.Categories, want) {
+		t.Errorf("unexpected categories: %v, want %v", c.Categories, want)
+	}
+
+	// classification is not computed by default.
+	man, err = NewUploader(th.repo).Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"})
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+
+	if man.Stats.Classification != nil {
+		t.Errorf("unexpected classification: %v", man.Stats.Classification)
+	}
+}
+
+func TestParallelUploadIsDeterministic(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx)
+
+	defer th.cleanup()
+
+	many := th.sourceDir.AddDir("d2/many", defaultPermissions)
+
+	for i := 0; i < 100; i++ {
+		many.AddFile(fmt.Sprintf("f%v", i), []byte(fmt.Sprintf("file-%v", i)), defaultPermissions)
+	}
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	var rootIDs []object.ID
+
+	for _, parallel := range []int{1, 8, 0} {
+		u := NewUploader(th.repo)
+		u.ParallelUploads = parallel
+
+		man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"})
+		if err != nil {
+			t.Fatalf("Upload error: %v", err)
+		}
+
+		if got, want := man.Stats.TotalFileCount, 110; got != want {
+			t.Errorf("unexpected file count with %v parallel uploads: %v, want %v", parallel, got, want)
+		}
+
+		rootIDs = append(rootIDs, man.RootObjectID())
+	}
+
+	for _, oid := range rootIDs[1:] {
+		if oid != rootIDs[0] {
+			t.Errorf("root object depends on parallelism: %v", rootIDs)
+		}
+	}
+}
+
+func verifyRootEntries(ctx context.Context, t *testing.T, rep repo.Repository, man *snapshot.Manifest, want ...string) {
+	t.Helper()
+
+	root, err := SnapshotRoot(rep, man)
+	if err != nil {
+		t.Fatalf("unable to get root: %v", err)
+	}
+
+	entries, err := root.(fs.Directory).Readdir(ctx)
+	if err != nil {
+		t.Fatalf("unable to read root: %v", err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected root entries: %v, want %v", got, want)
+	}
+}
@@ -26,17 +26,22 @@ func oidOf(entry fs.Entry) object.ID {
 	return entry.(object.HasObjectID).ObjectID()
 }
 
-func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Map) error {
+func loadAllSnapshots(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
 	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil)
 	if err != nil {
-		return errors.Wrap(err, "unable to list snapshot manifest IDs")
+		return nil, errors.Wrap(err, "unable to list snapshot manifest IDs")
 	}
 
 	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
 	if err != nil {
-		return errors.Wrap(err, "unable to load manifest IDs")
+		return nil, errors.Wrap(err, "unable to load manifest IDs")
 	}
 
+	return manifests, nil
+}
+
+// findInUseContentIDs stores IDs of all contents referenced by the provided snapshots in the map.
+func findInUseContentIDs(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, used *sync.Map) error {
 	w := snapshotfs.NewTreeWalker()
 	w.EntryID = func(e fs.Entry) interface{} { return oidOf(e) }
 
@@ -47,6 +52,17 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 		}
 
 		w.RootEntries = append(w.RootEntries, root)
+
+		if m.ReportID != "" {
+			contentIDs, err := rep.VerifyObject(ctx, m.ReportID)
+			if err != nil {
+				return errors.Wrapf(err, "error verifying report %v", m.ReportID)
+			}
+
+			for _, cid := range contentIDs {
+				used.Store(cid, nil)
+			}
+		}
 	}
 
 	w.ObjectCallback = func(entry fs.Entry) error {
@@ -92,13 +108,18 @@ func runInternal(ctx context.Context, rep *repo.DirectRepository, params mainten
 		unused, inUse, system, tooRecent, undeleted stats.CountSum
 	)
 
-	if err := findInUseContentIDs(ctx, rep, &used); err != nil {
+	manifests, err := loadAllSnapshots(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if err := findInUseContentIDs(ctx, rep, manifests, &used); err != nil {
 		return errors.Wrap(err, "unable to find in-use content ID")
 	}
 
 	log(ctx).Infof("looking for unreferenced contents")
 
-	err := rep.Content.IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+	err = rep.Content.IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
 		if manifest.ContentPrefix == ci.ID.Prefix() {
 			system.Add(int64(ci.Length))
 			return nil
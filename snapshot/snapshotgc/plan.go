@@ -0,0 +1,245 @@
+package snapshotgc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const planSignatureKeySize = 32
+
+var planSignatureKeyPurpose = []byte("maintenance plan")
+
+// maxReportedConflicts is the maximum number of conflicting contents included in verification errors.
+const maxReportedConflicts = 10
+
+// ErrPlanNotVerified is returned when applying a plan which was not verified or was modified since.
+var ErrPlanNotVerified = errors.New("plan was not verified or was modified after verification")
+
+// Plan describes snapshots to be expired according to retention policies and contents which are no longer
+// referenced by the remaining snapshots, so that the deletion can be verified before it's applied.
+type Plan struct {
+	Created          time.Time     `json:"created"`
+	MinContentAge    time.Duration `json:"minContentAge"`
+	ExpiredSnapshots []manifest.ID `json:"expiredSnapshots"`
+	DeletedContents  []content.ID  `json:"deletedContents"`
+
+	// Set by VerifyPlan, snapshots that were checked not to reference any deleted content.
+	Verified          time.Time     `json:"verified,omitempty"`
+	RetainedSnapshots []manifest.ID `json:"retainedSnapshots,omitempty"`
+	Signature         []byte        `json:"signature,omitempty"`
+}
+
+// CreatePlan determines snapshots to be expired and contents to be deleted without modifying the repository.
+func CreatePlan(ctx context.Context, rep *repo.DirectRepository, params maintenance.SnapshotGCParams) (*Plan, error) {
+	p := &Plan{
+		Created:       rep.Time().UTC(),
+		MinContentAge: params.MinContentAge,
+	}
+
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sources")
+	}
+
+	expired := map[manifest.ID]bool{}
+
+	for _, src := range sources {
+		toDelete, err := policy.ApplyRetentionPolicy(ctx, rep, src, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to apply retention policy of %v", src)
+		}
+
+		for _, m := range toDelete {
+			expired[m.ID] = true
+			p.ExpiredSnapshots = append(p.ExpiredSnapshots, m.ID)
+		}
+	}
+
+	retained, err := loadRetainedSnapshots(ctx, rep, expired)
+	if err != nil {
+		return nil, err
+	}
+
+	var used sync.Map
+
+	if err := findInUseContentIDs(ctx, rep, retained, &used); err != nil {
+		return nil, errors.Wrap(err, "unable to find in-use content ID")
+	}
+
+	if err := rep.Content.IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		if manifest.ContentPrefix == ci.ID.Prefix() {
+			return nil
+		}
+
+		if _, ok := used.Load(ci.ID); ok {
+			return nil
+		}
+
+		if rep.Time().Sub(ci.Timestamp()) < params.MinContentAge {
+			return nil
+		}
+
+		p.DeletedContents = append(p.DeletedContents, ci.ID)
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	sortManifestIDs(p.ExpiredSnapshots)
+	sort.Slice(p.DeletedContents, func(i, j int) bool {
+		return p.DeletedContents[i] < p.DeletedContents[j]
+	})
+
+	return p, nil
+}
+
+// VerifyPlan walks all snapshots which are not expired by the plan, including snapshots created after the plan,
+// and returns an error if any of them references a content that would be deleted. Successfully verified plans
+// are signed with a key derived from the repository master key, which ApplyPlan requires.
+func VerifyPlan(ctx context.Context, rep *repo.DirectRepository, p *Plan) error {
+	expired := map[manifest.ID]bool{}
+	for _, id := range p.ExpiredSnapshots {
+		expired[id] = true
+	}
+
+	retained, err := loadRetainedSnapshots(ctx, rep, expired)
+	if err != nil {
+		return err
+	}
+
+	var used sync.Map
+
+	if err := findInUseContentIDs(ctx, rep, retained, &used); err != nil {
+		return errors.Wrap(err, "unable to find in-use content ID")
+	}
+
+	var conflicts []content.ID
+
+	for _, cid := range p.DeletedContents {
+		if _, ok := used.Load(cid); ok {
+			conflicts = append(conflicts, cid)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		if len(conflicts) > maxReportedConflicts {
+			conflicts = conflicts[0:maxReportedConflicts]
+		}
+
+		return errors.Errorf("plan would delete contents referenced by retained snapshots, such as %v", conflicts)
+	}
+
+	p.Verified = rep.Time().UTC()
+	p.RetainedSnapshots = nil
+
+	for _, m := range retained {
+		p.RetainedSnapshots = append(p.RetainedSnapshots, m.ID)
+	}
+
+	sortManifestIDs(p.RetainedSnapshots)
+
+	p.Signature, err = planSignature(rep, p)
+
+	return err
+}
+
+// ApplyPlan deletes snapshots and contents according to a plan signed by VerifyPlan. It fails without
+// deleting anything if snapshots were created since the plan was verified.
+func ApplyPlan(ctx context.Context, rep *repo.DirectRepository, p *Plan) error {
+	sig, err := planSignature(rep, p)
+	if err != nil {
+		return err
+	}
+
+	if p.Verified.IsZero() || !hmac.Equal(sig, p.Signature) {
+		return ErrPlanNotVerified
+	}
+
+	known := map[manifest.ID]bool{}
+	for _, id := range append(append([]manifest.ID(nil), p.RetainedSnapshots...), p.ExpiredSnapshots...) {
+		known[id] = true
+	}
+
+	current, err := snapshot.ListSnapshotManifests(ctx, rep, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to list snapshot manifest IDs")
+	}
+
+	for _, id := range current {
+		if !known[id] {
+			return errors.Errorf("snapshot %v was created after the plan was verified, verify the plan again", id)
+		}
+	}
+
+	return maintenance.ReportRun(ctx, rep, "snapshot-gc", func() error {
+		for _, id := range p.ExpiredSnapshots {
+			if err := rep.DeleteManifest(ctx, id); err != nil {
+				return errors.Wrapf(err, "unable to delete snapshot %v", id)
+			}
+		}
+
+		for _, cid := range p.DeletedContents {
+			if err := rep.Content.DeleteContent(ctx, cid); err != nil && errors.Cause(err) != content.ErrContentNotFound {
+				return errors.Wrapf(err, "unable to delete content %v", cid)
+			}
+		}
+
+		log(ctx).Infof("deleted %v snapshots and %v contents", len(p.ExpiredSnapshots), len(p.DeletedContents))
+
+		return rep.Flush(ctx)
+	})
+}
+
+func loadRetainedSnapshots(ctx context.Context, rep repo.Repository, expired map[manifest.ID]bool) ([]*snapshot.Manifest, error) {
+	all, err := loadAllSnapshots(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	var retained []*snapshot.Manifest
+
+	for _, m := range all {
+		if !expired[m.ID] {
+			retained = append(retained, m)
+		}
+	}
+
+	return retained, nil
+}
+
+// planSignature returns HMAC of the plan contents excluding the signature.
+func planSignature(rep *repo.DirectRepository, p *Plan) ([]byte, error) {
+	unsigned := *p
+	unsigned.Signature = nil
+
+	b, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize plan")
+	}
+
+	h := hmac.New(sha256.New, rep.DeriveKey(planSignatureKeyPurpose, planSignatureKeySize))
+	h.Write(b) //nolint:errcheck
+
+	return h.Sum(nil), nil
+}
+
+func sortManifestIDs(ids []manifest.ID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+}
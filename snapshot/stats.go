@@ -17,7 +17,41 @@ type Stats struct {
 	CachedFiles    int32 `json:"cachedFiles"`
 	NonCachedFiles int32 `json:"nonCachedFiles"`
 
+	// UnstableFiles is the number of files that kept changing while being read.
+	UnstableFiles int32 `json:"unstableFiles,omitempty"`
+
 	ReadErrors int `json:"readErrors"`
+
+	// CompressionInputBytes is the size of file contents uploaded with compression enabled and
+	// CompressionOutputBytes is their size after compression.
+	CompressionInputBytes  int64 `json:"compressionInputBytes,omitempty"`
+	CompressionOutputBytes int64 `json:"compressionOutputBytes,omitempty"`
+
+	// CachedBytes is the size of files unchanged since previous snapshots, which were not read.
+	CachedBytes int64 `json:"cachedBytes,omitempty"`
+
+	// HashedBytes is the size of files read and hashed.
+	HashedBytes int64 `json:"hashedBytes,omitempty"`
+
+	// NewBytes is the size of file contents written to the repository and DeduplicatedBytes of
+	// hashed contents which were already there, both after compression.
+	NewBytes          int64 `json:"newBytes,omitempty"`
+	DeduplicatedBytes int64 `json:"deduplicatedBytes,omitempty"`
+
+	// Classification is only computed when requested during upload.
+	Classification *Classification `json:"classification,omitempty"`
+}
+
+// CompressionSavings returns the number of bytes saved by compression and the ratio of saved bytes to
+// the size of compressed contents before compression.
+func (s *Stats) CompressionSavings() (int64, float64) {
+	if s.CompressionInputBytes == 0 {
+		return 0, 0
+	}
+
+	saved := s.CompressionInputBytes - s.CompressionOutputBytes
+
+	return saved, float64(saved) / float64(s.CompressionInputBytes)
 }
 
 // AddExcluded adds the information about excluded file to the statistics.
@@ -0,0 +1,85 @@
+// +build linux
+
+package endtoend_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+// tags of POSIX ACL entries as stored in extended attributes.
+const (
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclMask     = 0x10
+	aclOther    = 0x20
+
+	aclUndefinedID = 0xffffffff
+)
+
+// makeACL returns the binary representation of a POSIX ACL granting read access to the provided user.
+func makeACL(t *testing.T, uid uint32) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	for _, v := range []interface{}{
+		uint32(2), // version
+		[]uint16{aclUserObj, 6}, uint32(aclUndefinedID),
+		[]uint16{aclUser, 4}, uid,
+		[]uint16{aclGroupObj, 4}, uint32(aclUndefinedID),
+		[]uint16{aclMask, 4}, uint32(aclUndefinedID),
+		[]uint16{aclOther, 0}, uint32(aclUndefinedID),
+	} {
+		testenv.AssertNoError(t, binary.Write(&buf, binary.LittleEndian, v))
+	}
+
+	return buf.Bytes()
+}
+
+func TestPosixACL(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "file.txt"), "contents")
+
+	fileACL := makeACL(t, 12345)
+	dirACL := makeACL(t, 23456)
+
+	if err := unix.Setxattr(filepath.Join(source, "file.txt"), "system.posix_acl_access", fileACL, 0); err != nil {
+		t.Skipf("POSIX ACLs not supported: %v", err)
+	}
+
+	testenv.AssertNoError(t, unix.Setxattr(source, "system.posix_acl_default", dirACL, 0))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+
+	target := filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertExtendedAttribute(t, filepath.Join(target, "file.txt"), "system.posix_acl_access", string(fileACL))
+	assertExtendedAttribute(t, target, "system.posix_acl_default", string(dirACL))
+
+	// ACLs are not extended attributes controlled by the policy.
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--extended-attributes=false")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID = e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[1].SnapshotID
+
+	target = filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertExtendedAttribute(t, filepath.Join(target, "file.txt"), "system.posix_acl_access", string(fileACL))
+}
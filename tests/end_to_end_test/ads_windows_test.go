@@ -0,0 +1,38 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestAlternateDataStreams(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "file.txt"), "contents")
+	mustWriteFile(t, filepath.Join(source, "file.txt")+":stream1", "stream contents")
+	mustWriteFile(t, filepath.Join(source, "file.txt")+":large", strings.Repeat("x", 100000))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+	target := filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertFileContents(t, filepath.Join(target, "file.txt"), "contents")
+	assertFileContents(t, filepath.Join(target, "file.txt")+":stream1", "stream contents")
+
+	// large streams are not stored inline in directory manifests.
+	if _, err := os.Stat(filepath.Join(target, "file.txt") + ":large"); !os.IsNotExist(err) {
+		t.Errorf("large alternate data stream was unexpectedly restored: %v", err)
+	}
+}
@@ -89,4 +89,12 @@ func TestAPIServerRepository(t *testing.T) {
 	if got, want := len(snapshots), 2; got != want {
 		t.Errorf("invalid number of snapshots for foo@bar")
 	}
+
+	// policies are replaced conditionally using remote repository client.
+	e2.RunAndExpectSuccess(t, "policy", "set", "foo@bar", "--keep-daily=5")
+	e2.RunAndExpectSuccess(t, "policy", "set", "foo@bar", "--keep-daily=6")
+
+	if !containsLine(e2.RunAndExpectSuccess(t, "policy", "show", "foo@bar"), "Daily snapshots:     6           (defined for this target)") {
+		t.Errorf("policy was not updated")
+	}
 }
@@ -0,0 +1,58 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--override-username=user", "--override-hostname=host")
+
+	// changes are not recorded until the audit log is enabled.
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--keep-latest=4")
+	e.RunAndVerifyOutputLineCount(t, 0, "repo", "audit-log")
+	e.RunAndVerifyOutputLineCount(t, 0, "blob", "list", "--prefix=kopia.audit")
+
+	e.RunAndExpectSuccess(t, "repo", "audit-log", "enable")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--keep-latest=5")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	lines := e.RunAndExpectSuccess(t, "repo", "audit-log")
+	if !containsChange(lines, "user@host put", "policy") || !containsChange(lines, "user@host delete", "policy") || !containsChange(lines, "user@host put", "snapshot") {
+		t.Errorf("expected changes not found in audit log: %v", lines)
+	}
+
+	e.RunAndExpectSuccess(t, "repo", "audit-log", "verify")
+
+	var auditBlobs []string
+
+	for _, l := range e.RunAndExpectSuccess(t, "blob", "list", "--prefix=kopia.audit.") {
+		auditBlobs = append(auditBlobs, strings.Fields(l)[0])
+	}
+
+	if len(auditBlobs) < 2 {
+		t.Fatalf("unexpected audit blobs: %v", auditBlobs)
+	}
+
+	// removing the latest record is detected using the head.
+	e.RunAndExpectSuccess(t, "blob", "delete", auditBlobs[len(auditBlobs)-1])
+	e.RunAndExpectFailure(t, "repo", "audit-log", "verify")
+}
+
+func containsChange(lines []string, operation, manifestType string) bool {
+	for _, l := range lines {
+		if strings.Contains(l, operation) && strings.Contains(l, " "+manifestType+" ") {
+			return true
+		}
+	}
+
+	return false
+}
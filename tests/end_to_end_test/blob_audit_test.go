@@ -0,0 +1,87 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestBlobAudit(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	manifestFile := filepath.Join(e.ConfigDir, "blob-manifest.json")
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "blob", "audit", "export", "--output", manifestFile)
+
+	var publicKey string
+
+	for _, l := range stderr {
+		if strings.HasPrefix(l, "Public key: ") {
+			publicKey = strings.TrimPrefix(l, "Public key: ")
+		}
+	}
+
+	if publicKey == "" {
+		t.Fatalf("public key not printed: %v", stderr)
+	}
+
+	// blobs deleted by kopia are recorded and not reported as missing.
+	var deletedBlobID string
+
+	for _, l := range e.RunAndExpectSuccess(t, "blob", "list", "--prefix=q") {
+		if f := strings.Fields(l); len(f) > 0 {
+			deletedBlobID = f[0]
+		}
+	}
+
+	if deletedBlobID == "" {
+		t.Fatalf("no blob to delete")
+	}
+
+	e.RunAndExpectSuccess(t, "blob", "delete", deletedBlobID)
+
+	// verification does not need the repository password or connection.
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "blob", "audit", "verify", "filesystem", "--path", e.RepoDir, "--manifest", manifestFile, "--public-key", publicKey)
+
+	// remove one pack blob from the storage
+	var removed bool
+
+	if err := filepath.Walk(e.RepoDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !removed && !info.IsDir() && strings.HasPrefix(filepath.Base(filepath.Dir(filepath.Dir(path))), "p") {
+			removed = true
+			return os.Remove(path)
+		}
+
+		return err
+	}); err != nil || !removed {
+		t.Fatalf("unable to remove pack blob: %v", err)
+	}
+
+	out := e.RunAndExpectFailure(t, "blob", "audit", "verify", "filesystem", "--path", e.RepoDir, "--manifest", manifestFile, "--public-key", publicKey)
+	if !strings.Contains(strings.Join(out, "\n"), "MISSING p") {
+		t.Errorf("missing blob not reported: %v", out)
+	}
+
+	// a manifest modified after export must be rejected.
+	b, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(manifestFile, []byte(strings.Replace(string(b), `"length": `, `"length": 1`, 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e.RunAndExpectFailure(t, "blob", "audit", "verify", "filesystem", "--path", e.RepoDir, "--manifest", manifestFile, "--public-key", publicKey)
+}
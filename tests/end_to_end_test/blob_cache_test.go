@@ -0,0 +1,43 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestBlobCache(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--blob-cache-size-mb=100")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)
+	if got, want := len(si), 1; got != want {
+		t.Fatalf("got %v sources, wanted %v", got, want)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+	e.RunAndExpectSuccess(t, "restore", si[0].Snapshots[0].ObjectID, makeScratchDir(t))
+
+	if !hasCacheLine(e.RunAndExpectSuccess(t, "cache", "info"), "blobs") {
+		t.Errorf("blob cache directory not found")
+	}
+
+	e.RunAndExpectSuccess(t, "cache", "set", "--blob-cache-size-mb=0")
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
+
+func hasCacheLine(lines []string, subdir string) bool {
+	for _, l := range lines {
+		if strings.Contains(l, subdir+":") && !strings.Contains(l, " 0 files") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestCachePrefetch(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)
+	if got, want := len(si), 1; got != want {
+		t.Fatalf("got %v sources, wanted %v", got, want)
+	}
+
+	oid := si[0].Snapshots[0].ObjectID
+
+	e.RunAndExpectSuccess(t, "cache", "clear")
+	e.RunAndExpectSuccess(t, "cache", "prefetch", oid)
+
+	if hasCacheLine(e.RunAndExpectSuccess(t, "cache", "info"), "contents") {
+		t.Errorf("file contents were prefetched without --data")
+	}
+
+	e.RunAndExpectSuccess(t, "cache", "prefetch", oid, "--data")
+
+	if !hasCacheLine(e.RunAndExpectSuccess(t, "cache", "info"), "contents") {
+		t.Errorf("file contents were not prefetched")
+	}
+
+	e.RunAndExpectFailure(t, "cache", "prefetch", "kffbb7c28ea6ab7ae3c3de9a2d5c0a17")
+}
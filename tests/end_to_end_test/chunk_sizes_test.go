@@ -0,0 +1,39 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryCreateWithChunkSizes(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--avg-chunk-size", "100KB")
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--object-splitter", "FIXED-1M", "--avg-chunk-size", "64KiB")
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--avg-chunk-size", "64KiB", "--max-chunk-size", "256KiB")
+
+	var found bool
+
+	for _, l := range e.RunAndExpectSuccess(t, "repo", "status") {
+		if strings.HasPrefix(l, "Chunk sizes:") {
+			found = true
+
+			if got, want := strings.Join(strings.Fields(l), " "), "Chunk sizes: min 32 KiB, avg 64 KiB, max 256 KiB"; got != want {
+				t.Errorf("unexpected chunk sizes: %q, want %q", got, want)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("chunk sizes not found in repository status")
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
@@ -57,4 +57,20 @@ func TestCompression(t *testing.T) {
 	if lines := e.RunAndExpectSuccess(t, "show", entries[0].ObjectID); !reflect.DeepEqual(dataLines, lines) {
 		t.Errorf("invalid object contents")
 	}
+
+	var found bool
+
+	for _, l := range e.RunAndExpectSuccess(t, "snapshot", "list", dataDir, "--compression") {
+		if strings.HasPrefix(strings.TrimSpace(l), "compression saved") {
+			found = true
+
+			if strings.HasPrefix(strings.TrimSpace(l), "compression saved 0 B") {
+				t.Errorf("unexpected compression savings: %v", l)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("compression savings not found in snapshot list")
+	}
 }
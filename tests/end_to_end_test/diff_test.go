@@ -68,3 +68,61 @@ canary
 		}
 	}
 }
+
+func TestDiffExportBundle(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	dataDir := makeScratchDir(t)
+
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(dataDir, "dir1"), 0700))
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(dataDir, "dir2"), 0700))
+	mustWriteFile(t, filepath.Join(dataDir, "unchanged"), "unchanged")
+	mustWriteFile(t, filepath.Join(dataDir, "changed"), "before")
+	mustWriteFile(t, filepath.Join(dataDir, "chmod"), "chmod")
+	mustWriteFile(t, filepath.Join(dataDir, "dir1", "removed"), "removed")
+	mustWriteFile(t, filepath.Join(dataDir, "dir2", "file"), "file")
+	mustWriteFile(t, filepath.Join(dataDir, "becomes-dir"), "becomes-dir")
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+
+	mustWriteFile(t, filepath.Join(dataDir, "changed"), "after")
+	testenv.AssertNoError(t, os.Chmod(filepath.Join(dataDir, "chmod"), 0640))
+	testenv.AssertNoError(t, os.Remove(filepath.Join(dataDir, "dir1", "removed")))
+	testenv.AssertNoError(t, os.RemoveAll(filepath.Join(dataDir, "dir2")))
+	testenv.AssertNoError(t, os.Remove(filepath.Join(dataDir, "becomes-dir")))
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(dataDir, "becomes-dir", "sub"), 0700))
+	mustWriteFile(t, filepath.Join(dataDir, "becomes-dir", "sub", "added"), "added")
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, dataDir)
+	if got, want := len(si[0].Snapshots), 2; got != want {
+		t.Fatalf("got %v snapshots, wanted %v", got, want)
+	}
+
+	oid1 := si[0].Snapshots[0].ObjectID
+	oid2 := si[0].Snapshots[1].ObjectID
+
+	restoreDir := makeScratchDir(t)
+	e.RunAndExpectSuccess(t, "restore", oid1, restoreDir)
+
+	bundleFile := filepath.Join(makeScratchDir(t), "out.kdiff")
+	e.RunAndExpectSuccess(t, "diff", "--export-bundle", bundleFile, oid1, oid2)
+
+	// applying the bundle does not require repository connection.
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "apply-bundle", bundleFile, restoreDir)
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+
+	testenv.AssertNoError(t, os.Chmod(restoreDir, 0700))
+	compareDirs(t, dataDir, restoreDir)
+
+	// bundle with no changes can be applied, too.
+	e.RunAndExpectSuccess(t, "diff", "--export-bundle", bundleFile, oid2, oid2)
+	e.RunAndExpectSuccess(t, "apply-bundle", bundleFile, restoreDir)
+	compareDirs(t, dataDir, restoreDir)
+}
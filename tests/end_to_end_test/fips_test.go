@@ -0,0 +1,42 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestFIPSMode(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// explicitly requested algorithms which are not FIPS-approved are rejected.
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--fips", "--block-hash=BLAKE2B-256-128")
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--fips", "--encryption=CHACHA20-POLY1305-HMAC-SHA256")
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--fips", "--key-derivation=scrypt")
+
+	// by default FIPS-approved algorithms are used.
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--fips", "--pbkdf2-iterations=1000")
+
+	status := e.RunAndExpectSuccess(t, "repo", "status", "--fips")
+	if !containsLine(status, "Hash:                HMAC-SHA256-128") || !containsLine(status, "Key derivation:      pbkdf2-sha256-1000") {
+		t.Errorf("unexpected algorithms of repository created in FIPS mode: %v", status)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1, "--fips")
+
+	// recovery keys are protected using scrypt.
+	e.RunAndExpectFailure(t, "repo", "export-recovery-key", "--fips", "--recovery-passphrase=recovery-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// repositories using other algorithms can't be used in FIPS mode.
+	otherRepoDir := makeScratchDir(t)
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", otherRepoDir)
+	e.RunAndExpectFailure(t, "repo", "status", "--fips")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", otherRepoDir, "--fips")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--fips")
+}
@@ -0,0 +1,59 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestHardLinks(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hard link identity is not captured on Windows")
+	}
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(source, "subdir"), 0700))
+	mustWriteFile(t, filepath.Join(source, "file.txt"), "contents")
+	mustWriteFile(t, filepath.Join(source, "other.txt"), "contents")
+	testenv.AssertNoError(t, os.Link(filepath.Join(source, "file.txt"), filepath.Join(source, "link.txt")))
+	testenv.AssertNoError(t, os.Link(filepath.Join(source, "file.txt"), filepath.Join(source, "subdir", "link.txt")))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+	target := filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertFileContents(t, filepath.Join(target, "link.txt"), "contents")
+	assertSameFile(t, filepath.Join(target, "file.txt"), filepath.Join(target, "link.txt"), true)
+	assertSameFile(t, filepath.Join(target, "file.txt"), filepath.Join(target, "subdir", "link.txt"), true)
+	assertSameFile(t, filepath.Join(target, "file.txt"), filepath.Join(target, "other.txt"), false)
+
+	// restoring again over existing hard links succeeds.
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertSameFile(t, filepath.Join(target, "file.txt"), filepath.Join(target, "link.txt"), true)
+}
+
+func assertSameFile(t *testing.T, path1, path2 string, want bool) {
+	t.Helper()
+
+	st1, err := os.Stat(path1)
+	testenv.AssertNoError(t, err)
+
+	st2, err := os.Stat(path2)
+	testenv.AssertNoError(t, err)
+
+	if got := os.SameFile(st1, st2); got != want {
+		t.Errorf("unexpected SameFile(%v, %v): %v, want %v", path1, path2, got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestManifestExportImport(t *testing.T) {
+	t.Parallel()
+
+	src := testenv.NewCLITest(t)
+	defer src.Cleanup(t)
+	defer src.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	src.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", src.RepoDir)
+	src.RunAndExpectSuccess(t, "policy", "set", sharedTestDataDir1, "--keep-latest=17")
+
+	exportFile := filepath.Join(src.ConfigDir, "manifests.export")
+	src.RunAndExpectSuccess(t, "manifest", "export", exportFile, "--export-password=secret")
+
+	dst := testenv.NewCLITest(t)
+	defer dst.Cleanup(t)
+	defer dst.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	dst.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", dst.RepoDir)
+	dst.RunAndExpectFailure(t, "manifest", "import", exportFile, "--export-password=wrong")
+	dst.RunAndExpectSuccess(t, "manifest", "import", exportFile, "--export-password=secret")
+
+	var found bool
+
+	for _, l := range dst.RunAndExpectSuccess(t, "policy", "show", sharedTestDataDir1) {
+		if strings.Contains(l, "Latest snapshots") && strings.Contains(l, "17") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("imported policy not found")
+	}
+}
@@ -0,0 +1,50 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestMultiStatus(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "verify")
+
+	var summaries []struct {
+		Sources          int `json:"sources"`
+		Snapshots        int `json:"snapshots"`
+		LastVerification *struct {
+			Success bool `json:"success"`
+		} `json:"lastVerification"`
+		Error string `json:"error"`
+	}
+
+	out := e.RunAndExpectSuccess(t, "multi", "status", "--config-dir", e.ConfigDir, "--json")
+	testenv.AssertNoError(t, json.Unmarshal([]byte(strings.Join(out, "\n")), &summaries))
+
+	if got, want := len(summaries), 1; got != want {
+		t.Fatalf("unexpected number of repositories: %v, want %v", got, want)
+	}
+
+	s := summaries[0]
+
+	if s.Error != "" {
+		t.Fatalf("unexpected error: %v", s.Error)
+	}
+
+	if s.Sources != 1 || s.Snapshots != 1 {
+		t.Errorf("unexpected number of sources and snapshots: %v %v", s.Sources, s.Snapshots)
+	}
+
+	if s.LastVerification == nil || !s.LastVerification.Success {
+		t.Errorf("successful verification not reported: %v", s.LastVerification)
+	}
+}
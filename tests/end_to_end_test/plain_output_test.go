@@ -0,0 +1,36 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestPlainOutput(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", sharedTestDataDir1, "--plain", "--plain-status-interval=0")
+
+	var statusLines int
+
+	for _, l := range stderr {
+		if strings.ContainsAny(l, "\r\x1b") {
+			t.Errorf("unexpected control characters in plain output: %q", l)
+		}
+
+		if strings.HasPrefix(l, "0 hashing, ") {
+			statusLines++
+		}
+	}
+
+	if statusLines == 0 {
+		t.Errorf("no status lines in plain output: %v", stderr)
+	}
+}
@@ -1,8 +1,13 @@
 package endtoend_test
 
 import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -27,3 +32,86 @@ func TestDefaultGlobalPolicy(t *testing.T) {
 	// make sure the policy is visible in the policy list
 	e.RunAndVerifyOutputLineCount(t, 1, "policy", "list")
 }
+
+func TestStaleSnapshotWarnings(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	// no stale-after in the policy, no warnings.
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "list", "--warn-stale-snapshots")
+	if hasLineWithPrefix(stderr, "WARNING:") {
+		t.Errorf("unexpected warning: %v", stderr)
+	}
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--stale-after=1s")
+	time.Sleep(2 * time.Second)
+
+	// warnings are printed only when requested.
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "snapshot", "list")
+	if hasLineWithPrefix(stderr, "WARNING:") {
+		t.Errorf("unexpected warning: %v", stderr)
+	}
+
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "snapshot", "list", "--warn-stale-snapshots")
+	if !hasLineWithPrefix(stderr, "WARNING: last successful snapshot of") {
+		t.Errorf("missing stale snapshot warning: %v", stderr)
+	}
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--stale-after=24h")
+
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "snapshot", "list", "--warn-stale-snapshots")
+	if hasLineWithPrefix(stderr, "WARNING:") {
+		t.Errorf("unexpected warning: %v", stderr)
+	}
+}
+
+func TestSplitterPolicy(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	dataDir := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(dataDir, "file"), strings.Repeat("some data ", 100000))
+
+	e.RunAndExpectFailure(t, "policy", "set", dataDir, "--splitter", "NO-SUCH-SPLITTER")
+	e.RunAndExpectSuccess(t, "policy", "set", dataDir, "--splitter", "FIXED-1M")
+
+	if !hasLineWithPrefix(e.RunAndExpectSuccess(t, "policy", "show", dataDir), `Splitter: "FIXED-1M"`) {
+		t.Errorf("splitter not found in policy")
+	}
+
+	var man snapshot.Manifest
+
+	snapshotID := createSnapshotAndGetID(t, e, dataDir)
+	testenv.AssertNoError(t, json.Unmarshal([]byte(strings.Join(e.RunAndExpectSuccess(t, "manifest", "show", snapshotID), "\n")), &man))
+
+	if got, want := man.Splitter, "FIXED-1M"; got != want {
+		t.Errorf("unexpected splitter in manifest: %q, want %q", got, want)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+	e.RunAndExpectSuccess(t, "policy", "set", dataDir, "--splitter", "inherit")
+
+	if !hasLineWithPrefix(e.RunAndExpectSuccess(t, "policy", "show", dataDir), "Splitter: repository default.") {
+		t.Errorf("splitter not reset in policy")
+	}
+}
+
+func hasLineWithPrefix(lines []string, prefix string) bool {
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotProgressEvents(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(source, "sub"), 0700))
+	mustWriteFile(t, filepath.Join(source, "a.txt"), "aaaa")
+	mustWriteFile(t, filepath.Join(source, "sub", "b.txt"), "bbbbbb")
+	mustWriteFile(t, filepath.Join(source, "c.log"), "cc")
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--add-ignore", "*.log")
+
+	lines := e.RunAndExpectSuccess(t, "snapshot", "create", source, "--progress-format=jsonl")
+
+	verifyProgressEvents(t, lines, map[string]string{
+		"a.txt":     "uploaded 4",
+		"sub/b.txt": "uploaded 6",
+		"sub":       "uploaded 6",
+		"c.log":     "ignored 2",
+	})
+
+	progressFile := filepath.Join(makeScratchDir(t), "progress.jsonl")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source, "--progress-format=jsonl", "--progress-file", progressFile)
+
+	b, err := ioutil.ReadFile(progressFile)
+	testenv.AssertNoError(t, err)
+
+	verifyProgressEvents(t, strings.Split(strings.TrimSpace(string(b)), "\n"), map[string]string{
+		"a.txt":     "cached 4",
+		"sub/b.txt": "cached 6",
+		"sub":       "uploaded 6",
+		"c.log":     "ignored 2",
+	})
+}
+
+func verifyProgressEvents(t *testing.T, lines []string, want map[string]string) {
+	t.Helper()
+
+	got := map[string]string{}
+
+	for _, l := range lines {
+		var ev struct {
+			Path   string `json:"path"`
+			Action string `json:"action"`
+			Bytes  int64  `json:"bytes"`
+		}
+
+		if err := json.Unmarshal([]byte(l), &ev); err != nil {
+			t.Fatalf("invalid progress event %q: %v", l, err)
+		}
+
+		got[ev.Path] = fmt.Sprintf("%v %v", ev.Action, ev.Bytes)
+	}
+
+	for p, w := range want {
+		if got[p] != w {
+			t.Errorf("unexpected event for %v: %q, want %q (all events: %v)", p, got[p], w, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRecoveryKey(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.Environment = append(e.Environment, "KOPIA_RECOVERY_PASSPHRASE=recovery-passphrase")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	lines := e.RunAndExpectSuccess(t, "repo", "export-recovery-key")
+	if got, want := len(lines), 1; got != want {
+		t.Fatalf("unexpected number of lines: %v, want %v", got, want)
+	}
+
+	recoveryKey := lines[0]
+
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// simulate loss of the format blob.
+	testenv.AssertNoError(t, os.Remove(filepath.Join(e.RepoDir, "kopia.repository.f")))
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+
+	e.RunAndExpectFailure(t, "repo", "connect", "from-config", "--recovery-key", recoveryKey, "--recovery-passphrase", "wrong-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "connect", "from-config", "--recovery-key", recoveryKey)
+
+	if got, want := len(e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)), 1; got != want {
+		t.Errorf("unexpected number of sources: %v, want %v", got, want)
+	}
+}
+
+func TestRecoveryShares(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	lines := e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--recovery-shares", "4", "--recovery-threshold", "2")
+
+	var shares []string
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "KOPIA-SHARE-") {
+			shares = append(shares, l)
+		}
+	}
+
+	if got, want := len(shares), 4; got != want {
+		t.Fatalf("unexpected number of recovery shares: %v, want %v", got, want)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--no-persist-credentials")
+
+	// simulate lost password.
+	e.Environment = []string{"KOPIA_PASSWORD=lost-password"}
+
+	e.RunAndExpectFailure(t, "snapshot", "list", "--recovery-share", shares[3])
+	e.RunAndExpectSuccess(t, "snapshot", "list", "--recovery-share", shares[3], "--recovery-share", shares[1])
+	e.RunAndExpectSuccess(t, "repo", "change-password", "--recovery-share", shares[0], "--recovery-share", shares[2], "--new-password", "new-password")
+
+	e.Environment = []string{"KOPIA_PASSWORD=new-password"}
+
+	if got, want := len(e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)), 1; got != want {
+		t.Errorf("unexpected number of snapshots: %v, want %v", got, want)
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	lines := e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--generate-recovery-code")
+
+	var code string
+
+	for _, l := range lines {
+		if len(l) == 47 && strings.Count(l, "-") == 7 {
+			code = l
+		}
+	}
+
+	if code == "" {
+		t.Fatalf("recovery code not found in %v", lines)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// simulate lost config file and password.
+	e.Environment = []string{"KOPIA_PASSWORD=lost-password"}
+
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--recovery-code", code[1:])
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--recovery-code", code)
+	e.RunAndExpectSuccess(t, "repo", "change-password", "--recovery-code", code, "--new-password", "new-password")
+
+	e.Environment = []string{"KOPIA_PASSWORD=new-password"}
+
+	if got, want := len(e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)), 1; got != want {
+		t.Errorf("unexpected number of snapshots: %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package endtoend_test
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -46,3 +47,91 @@ func TestReconnectUsingToken(t *testing.T) {
 	e.RunAndExpectSuccess(t, reconnectArgs...)
 	e.RunAndExpectSuccess(t, "repo", "status")
 }
+
+func TestValidateConnection(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "validate-connection", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "repo", "change-password", "--new-password", "new-password")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--password", "new-password")
+
+	if got, want := len(e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)), 1; got != want {
+		t.Errorf("unexpected number of snapshots: %v, want %v", got, want)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "credential", "add", "bob", "--credential-password", "bob-password")
+	e.RunAndExpectFailure(t, "repo", "credential", "add", "bob", "--credential-password", "other-password")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--password", "bob-password")
+
+	if got, want := strings.Join(e.RunAndExpectSuccess(t, "repo", "credential", "list"), ","), "default,bob (current)"; got != want {
+		t.Errorf("unexpected credentials: %v, want %v", got, want)
+	}
+
+	e.RunAndExpectFailure(t, "repo", "credential", "remove", "bob")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "credential", "remove", "bob")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--password", "bob-password")
+}
+
+func TestKeyFileCredentials(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	keyFile := filepath.Join(e.ConfigDir, "server.key")
+	protectedKeyFile := filepath.Join(e.ConfigDir, "protected.key")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "generate-keyfile", keyFile)
+	e.RunAndExpectFailure(t, "repo", "generate-keyfile", keyFile)
+	e.RunAndExpectSuccess(t, "repo", "generate-keyfile", protectedKeyFile, "--protect", "--key-file-passphrase", "key-file-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "credential", "add", "server", "--key-file", keyFile)
+	e.RunAndExpectFailure(t, "repo", "credential", "add", "protected", "--key-file", protectedKeyFile, "--key-file-passphrase", "wrong-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "credential", "add", "protected", "--key-file", protectedKeyFile, "--key-file-passphrase", "key-file-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--key-file", keyFile)
+
+	if got, want := strings.Join(e.RunAndExpectSuccess(t, "repo", "credential", "list"), ","), "default,server (key file) (current),protected (key file)"; got != want {
+		t.Errorf("unexpected credentials: %v, want %v", got, want)
+	}
+
+	e.RunAndExpectFailure(t, "repo", "change-password", "--new-password", "new-password")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--key-file", protectedKeyFile, "--key-file-passphrase", "wrong-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--key-file", protectedKeyFile, "--key-file-passphrase", "key-file-passphrase")
+	e.RunAndExpectSuccess(t, "repo", "status")
+}
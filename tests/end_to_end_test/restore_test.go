@@ -1,7 +1,11 @@
 package endtoend_test
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -165,3 +169,93 @@ func TestSnapshotRestore(t *testing.T) {
 
 	e.RunAndExpectFailure(t, "snapshot", "restore", "--no-overwrite-files", snapID, restoreDir)
 }
+
+func TestRestoreScanCommand(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("scan command test requires shell script")
+	}
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "clean.txt"), "clean")
+	mustWriteFile(t, filepath.Join(source, "infected.txt"), "EICAR")
+
+	// scanner rejects files containing EICAR using exit code 1.
+	scanner := filepath.Join(makeScratchDir(t), "scan.sh")
+	mustWriteFile(t, scanner, "#!/bin/sh\nif grep -q EICAR \"$1\"; then exit 1; fi\nexit 0\n")
+
+	if err := os.Chmod(scanner, 0700); err != nil {
+		t.Fatalf("unable to make scanner executable: %v", err)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, source)
+	restoreDir := makeScratchDir(t)
+
+	e.RunAndExpectSuccess(t, "restore", si[0].Snapshots[0].ObjectID, restoreDir, "--scan-command", scanner)
+
+	if _, err := os.Stat(filepath.Join(restoreDir, "clean.txt")); err != nil {
+		t.Errorf("clean file was not restored: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(restoreDir, "infected.txt")); !os.IsNotExist(err) {
+		t.Errorf("infected file was restored: %v", err)
+	}
+}
+
+func mustWriteFile(t *testing.T, fname, content string) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(fname, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write %v: %v", fname, err)
+	}
+}
+
+func TestBenchmarkRestore(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	testenv.MustCreateDirectoryTree(t, source, testenv.DirectoryTreeOptions{
+		Depth:                1,
+		MaxFilesPerDirectory: 10,
+	})
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, source)
+	if got, want := len(si), 1; got != want {
+		t.Fatalf("got %v sources, wanted %v", got, want)
+	}
+
+	targetDir := makeScratchDir(t)
+
+	// benchmark using both snapshot ID and root object ID
+	for _, id := range []string{si[0].Snapshots[0].SnapshotID, si[0].Snapshots[0].ObjectID} {
+		out := e.RunAndExpectSuccess(t, "benchmark", "restore", "--snapshot", id, "--sample", "1MB", "--target", targetDir)
+		if !strings.HasPrefix(out[0], "Restored ") {
+			t.Errorf("unexpected output: %v", out)
+		}
+	}
+
+	// restored data is removed after benchmark
+	entries, err := ioutil.ReadDir(targetDir)
+	testenv.AssertNoError(t, err)
+
+	if len(entries) != 0 {
+		t.Errorf("unexpected entries left in target directory: %v", entries)
+	}
+
+	e.RunAndExpectFailure(t, "benchmark", "restore", "--snapshot", "no-such-snapshot")
+}
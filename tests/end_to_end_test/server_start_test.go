@@ -67,6 +67,8 @@ func TestServerStart(t *testing.T) {
 
 	var sp serverParameters
 
+	writeTranslations(t, e)
+
 	e.Environment = append(e.Environment, `KOPIA_UI_TITLE_PREFIX=Blah: <script>bleh</script> `)
 	e.RunAndProcessStderr(t, sp.ProcessOutput,
 		"server", "start",
@@ -95,6 +97,7 @@ func TestServerStart(t *testing.T) {
 
 	waitUntilServerStarted(ctx, t, cli)
 	verifyUIServedWithCorrectTitle(t, cli, sp)
+	verifyTranslations(t, cli)
 
 	st := verifyServerConnected(t, cli, true)
 	if got, want := st.Storage, "filesystem"; got != want {
@@ -151,6 +154,8 @@ func TestServerStart(t *testing.T) {
 		t.Fatalf("invalid JSON received: %v", err)
 	}
 
+	verifyShareLink(ctx, t, cli, sp, snaps[0].RootEntry, rootPayload)
+
 	keepDaily := 77
 
 	createResp, err = serverapi.CreateSnapshotSource(ctx, cli, &serverapi.CreateSnapshotSourceRequest{
@@ -250,6 +255,23 @@ func TestServerStartWithoutInitialRepository(t *testing.T) {
 	verifyServerConnected(t, cli, true)
 }
 
+func verifyTranslations(t *testing.T, cli *apiclient.KopiaAPIClient) {
+	t.Helper()
+
+	tr, err := serverapi.Translations(testlogging.Context(t), cli, "pl-PL")
+	if err != nil {
+		t.Fatalf("translations error: %v", err)
+	}
+
+	if got, want := tr.Language, "pl"; got != want {
+		t.Errorf("unexpected language: %v, want %v", got, want)
+	}
+
+	if got, want := tr.Messages["Setting policy for %v\n"], "Ustawianie zasad dla %v\n"; got != want {
+		t.Errorf("unexpected translation: %q, want %q", got, want)
+	}
+}
+
 func verifyServerConnected(t *testing.T, cli *apiclient.KopiaAPIClient, want bool) *serverapi.StatusResponse {
 	t.Helper()
 
@@ -358,6 +380,51 @@ func verifyUIServedWithCorrectTitle(t *testing.T, cli *apiclient.KopiaAPIClient,
 	}
 }
 
+func verifyShareLink(ctx context.Context, t *testing.T, cli *apiclient.KopiaAPIClient, sp serverParameters, objectID string, wantPayload []byte) {
+	resp, err := serverapi.CreateShareLink(ctx, cli, &serverapi.CreateShareLinkRequest{
+		ObjectID: objectID,
+		FileName: "root.json",
+	})
+	if err != nil {
+		t.Fatalf("unable to create share link: %v", err)
+	}
+
+	// share links must work without credentials.
+	anon, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.baseURL,
+		TrustedServerCertificateFingerprint: sp.sha256Fingerprint,
+	})
+	if err != nil {
+		t.Fatalf("unable to create API apiclient")
+	}
+
+	getShared := func(path string) (int, []byte) {
+		hresp, herr := anon.HTTPClient.Get(sp.baseURL + path)
+		if herr != nil {
+			t.Fatalf("unable to get shared link: %v", herr)
+		}
+
+		defer hresp.Body.Close()
+
+		b, herr := ioutil.ReadAll(hresp.Body)
+		if herr != nil {
+			t.Fatalf("error reading response body: %v", herr)
+		}
+
+		return hresp.StatusCode, b
+	}
+
+	code, b := getShared(resp.Path)
+	if code != http.StatusOK || !bytes.Equal(b, wantPayload) {
+		t.Errorf("unexpected shared link response: %v %v", code, string(b))
+	}
+
+	// tamper with the signature.
+	if code, _ = getShared(resp.Path[0:len(resp.Path)-2] + "xx"); code != http.StatusForbidden {
+		t.Errorf("unexpected status code for invalid share link: %v", code)
+	}
+}
+
 func waitUntilServerStarted(ctx context.Context, t *testing.T, cli *apiclient.KopiaAPIClient) {
 	if err := retry.PeriodicallyNoValue(ctx, 1*time.Second, 60, "wait for server start", func() error {
 		_, err := serverapi.Status(testlogging.Context(t), cli)
@@ -0,0 +1,68 @@
+// +build linux darwin
+
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotActions(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "live.db"), "changing")
+
+	// the before action copies the source to a frozen directory, which is uploaded instead and removed afterwards.
+	frozen := filepath.Join(makeScratchDir(t), "frozen")
+
+	e.RunAndExpectSuccess(t, "policy", "set", source,
+		"--before-snapshot-root-action", "cp -r \"$KOPIA_SOURCE_PATH\" "+frozen+" && echo frozen $KOPIA_SNAPSHOT_ID && echo KOPIA_SNAPSHOT_PATH="+frozen,
+		"--after-snapshot-root-action", "rm -rf \"$KOPIA_SNAPSHOT_PATH\" && echo removed $KOPIA_SNAPSHOT_ID")
+
+	// actions are not run unless enabled for the connection.
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	if got := strings.Join(e.RunAndExpectSuccess(t, "snapshot", "list", source, "--action-output"), "\n"); strings.Contains(got, "frozen") {
+		t.Errorf("disabled action was run:\n%v", got)
+	}
+
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--enable-actions")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	if _, err := os.Stat(frozen); !os.IsNotExist(err) {
+		t.Errorf("frozen directory was not removed: %v", err)
+	}
+
+	si := e.ListSnapshotsAndExpectSuccess(t, source)
+	if got, want := si[0].Path, source; got != want {
+		t.Errorf("unexpected source path: %v, want %v", got, want)
+	}
+
+	target := makeScratchDir(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[1].SnapshotID, target)
+	assertFileContents(t, filepath.Join(target, "live.db"), "changing")
+
+	out := strings.Join(e.RunAndExpectSuccess(t, "snapshot", "list", source, "--action-output"), "\n")
+	if !strings.Contains(out, "before: frozen ") || !strings.Contains(out, "after: removed ") {
+		t.Errorf("action output not found in snapshot list:\n%v", out)
+	}
+
+	// failing actions fail the snapshot unless configured to continue.
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--before-snapshot-root-action", "exit 1", "--after-snapshot-root-action", "inherit")
+	e.RunAndExpectFailure(t, "snapshot", "create", source)
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--action-failure-mode", "continue")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+}
@@ -1,9 +1,16 @@
 package endtoend_test
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -88,3 +95,185 @@ func TestInvalidTimeOverride(t *testing.T) {
 	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
 	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1, "--start-time", "2000-01-01 01:01:00 UTC", "--end-time", "1999-01-01 01:01:00 UTC")
 }
+
+func TestSnapshotCreateFilesFrom(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+
+	for _, f := range []string{"a/f1", "a/f2", "b/c/f3", "b/f4", "f5"} {
+		fname := filepath.Join(source, filepath.FromSlash(f))
+		testenv.AssertNoError(t, os.MkdirAll(filepath.Dir(fname), 0700))
+		testenv.AssertNoError(t, ioutil.WriteFile(fname, []byte(f), 0600))
+	}
+
+	listFile := filepath.Join(makeScratchDir(t), "list.txt")
+	testenv.AssertNoError(t, ioutil.WriteFile(listFile, []byte("# selected files\na/f1\n\nb/c\n"+filepath.Join(source, "f5")+"\n"), 0600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source, "--files-from", listFile)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, source)
+	if got, want := len(si), 1; got != want {
+		t.Fatalf("got %v sources, wanted %v", got, want)
+	}
+
+	rootID := si[0].Snapshots[0].ObjectID
+	lines := e.RunAndExpectSuccess(t, "ls", "-r", rootID)
+
+	var want []string
+	for _, f := range []string{"a/", "a/f1", "b/", "b/c/", "b/c/f3", "f5"} {
+		want = append(want, rootID+"/"+f)
+	}
+
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("unexpected snapshot contents: %v, want %v", lines, want)
+	}
+
+	// listing a path that does not exist fails
+	testenv.AssertNoError(t, ioutil.WriteFile(listFile, []byte("no-such-file\n"), 0600))
+	e.RunAndExpectFailure(t, "snapshot", "create", source, "--files-from", listFile)
+
+	// more than one source is not allowed
+	testenv.AssertNoError(t, ioutil.WriteFile(listFile, []byte("f5\n"), 0600))
+	e.RunAndExpectFailure(t, "snapshot", "create", source, sharedTestDataDir1, "--files-from", listFile)
+}
+
+func TestSnapshotPathAtTime(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--override-hostname=foo", "--override-username=foo")
+
+	srcDir := makeScratchDir(t)
+	fileName := filepath.Join(srcDir, "file.txt")
+
+	testenv.AssertNoError(t, ioutil.WriteFile(fileName, []byte("v1"), 0600))
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcDir, "--start-time", "2000-01-01 10:00:00 UTC")
+
+	testenv.AssertNoError(t, ioutil.WriteFile(fileName, []byte("v2"), 0600))
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcDir, "--start-time", "2000-01-02 10:00:00 UTC")
+
+	cases := map[string]string{
+		"2000-01-01T09:00:00Z": "v1",
+		"2000-01-01T21:59:00Z": "v1",
+		"2000-01-01T22:01:00Z": "v2",
+		"2001-01-01T00:00:00Z": "v2",
+	}
+
+	for ts, want := range cases {
+		lines := e.RunAndExpectSuccess(t, "show", "foo@foo:"+fileName+"#"+ts)
+		if got := strings.Join(lines, "\n"); got != want {
+			t.Errorf("unexpected contents of %v at %v: %q, want %q", fileName, ts, got, want)
+		}
+	}
+
+	e.RunAndVerifyOutputLineCount(t, 1, "ls", "foo@foo:"+srcDir+"#2000-01-01T10:00:00Z")
+
+	// equally close to both snapshots.
+	e.RunAndExpectFailure(t, "show", "foo@foo:"+fileName+"#2000-01-01T22:00:00Z")
+	e.RunAndExpectFailure(t, "show", "foo@foo:"+fileName+"#not-a-time")
+	e.RunAndExpectFailure(t, "show", "foo@foo:"+filepath.Join(srcDir, "no-such-file")+"#2000-01-01T10:00:00Z")
+	e.RunAndExpectFailure(t, "show", "bar@bar:"+fileName+"#2000-01-01T10:00:00Z")
+}
+
+func TestSnapshotReport(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	sources := e.ListSnapshotsAndExpectSuccess(t)
+	if got, want := len(sources), 1; got != want {
+		t.Fatalf("unexpected number of sources: %v, want %v", got, want)
+	}
+
+	lines := e.RunAndExpectSuccess(t, "snapshot", "report", sources[0].Snapshots[0].SnapshotID)
+
+	var report snapshot.Report
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+
+	if report.Stats.TotalFileCount == 0 {
+		t.Errorf("report does not include any files: %v", report)
+	}
+
+	e.RunAndExpectFailure(t, "snapshot", "report", "no-such-snapshot")
+}
+
+func TestSnapshotCreateWithParent(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	fname := filepath.Join(source, "dataset")
+	baselineTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeDataset := func(contents string, mtime time.Time) {
+		testenv.AssertNoError(t, ioutil.WriteFile(fname, []byte(contents), 0600))
+		testenv.AssertNoError(t, os.Chtimes(fname, mtime, mtime))
+	}
+
+	writeDataset("baseline", baselineTime)
+	baselineID := createSnapshotAndGetID(t, e, source)
+
+	writeDataset("modified", baselineTime.Add(time.Hour))
+	createSnapshotAndGetID(t, e, source)
+
+	// roll back the dataset, the baseline is not the latest snapshot.
+	writeDataset("baseline", baselineTime)
+
+	e.RunAndExpectFailure(t, "snapshot", "create", source, "--parent", "no-such-snapshot")
+	e.RunAndExpectFailure(t, "snapshot", "create", source, sharedTestDataDir1, "--parent", baselineID)
+
+	if got, want := snapshotCachedFiles(t, e, createSnapshotAndGetID(t, e, source)), 0; got != want {
+		t.Errorf("unexpected number of cached files using latest snapshot as parent: %v, want %v", got, want)
+	}
+
+	if got, want := snapshotCachedFiles(t, e, createSnapshotAndGetID(t, e, source, "--parent", baselineID)), 1; got != want {
+		t.Errorf("unexpected number of cached files using baseline as parent: %v, want %v", got, want)
+	}
+}
+
+func createSnapshotAndGetID(t *testing.T, e *testenv.CLITest, source string, args ...string) string {
+	t.Helper()
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, append([]string{"snapshot", "create", source}, args...)...)
+
+	for _, l := range stderr {
+		if p := strings.Index(l, " and ID "); p >= 0 && strings.HasPrefix(l, "Created snapshot") {
+			return strings.Fields(l[p+len(" and ID "):])[0]
+		}
+	}
+
+	t.Fatalf("snapshot ID not found in output: %v", stderr)
+
+	return ""
+}
+
+func snapshotCachedFiles(t *testing.T, e *testenv.CLITest, snapshotID string) int {
+	t.Helper()
+
+	var man snapshot.Manifest
+
+	testenv.AssertNoError(t, json.Unmarshal([]byte(strings.Join(e.RunAndExpectSuccess(t, "manifest", "show", snapshotID), "\n")), &man))
+
+	return int(man.Stats.CachedFiles)
+}
@@ -0,0 +1,44 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotEstimate(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "a.txt"), "aaaa")
+	mustWriteFile(t, filepath.Join(source, "b.txt"), "bbbbbb")
+	mustWriteFile(t, filepath.Join(source, "c.log"), "ignored")
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--add-ignore", "*.log")
+
+	assertEstimateOutput(t, e, source, "Snapshot excludes 0 directories and 1 files", "Files to read and hash: 2 files, total size 10 B")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	mustWriteFile(t, filepath.Join(source, "d.txt"), "ddd")
+
+	assertEstimateOutput(t, e, source, "Unchanged since previous snapshot: 2 files, total size 10 B", "Files to read and hash: 1 files, total size 3 B")
+}
+
+func assertEstimateOutput(t *testing.T, e *testenv.CLITest, source string, want ...string) {
+	t.Helper()
+
+	out := strings.Join(e.RunAndExpectSuccess(t, "snapshot", "estimate", source, "--quiet"), "\n")
+
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("estimate output does not contain %q:\n%v", w, out)
+		}
+	}
+}
@@ -30,10 +30,12 @@ how are you
 	// take a snapshot of a directory with 1 file
 	e.RunAndExpectSuccess(t, "snap", "create", dataDir)
 
-	// data block + directory block + manifest block
-	expectedContentCount += 3
+	// data block + directory block + report block + manifest block
+	expectedContentCount += 4
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
 
+	var deletedManifestIDs []string
+
 	// now delete all manifests, making the content unreachable
 	for _, line := range e.RunAndExpectSuccess(t, "snap", "list", "-m") {
 		p := strings.Index(line, "manifest:")
@@ -41,9 +43,15 @@ how are you
 			manifestID := strings.TrimPrefix(strings.Split(line[p:], " ")[0], "manifest:")
 			t.Logf("manifestID: %v", manifestID)
 			e.RunAndExpectSuccess(t, "manifest", "rm", manifestID)
+			deletedManifestIDs = append(deletedManifestIDs, manifestID)
 		}
 	}
 
+	// previous versions of snapshot manifests are not retained, since GC does not keep their contents alive.
+	for _, manifestID := range deletedManifestIDs {
+		e.RunAndExpectFailure(t, "manifest", "restore", manifestID)
+	}
+
 	// deletion of manifests creates a new manifest
 	expectedContentCount++
 
@@ -53,19 +61,81 @@ how are you
 	// garbage-collect in dry run mode
 	e.RunAndExpectSuccess(t, "snapshot", "gc")
 
-	// data block + directory block + manifest block + manifest block from manifest deletion
+	// data block + directory block + report block + manifest block + manifest block from manifest deletion
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
 
 	// garbage-collect for real, but contents are too recent so won't be deleted
 	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete")
 
-	// data block + directory block + manifest block + manifest block from manifest deletion
+	// data block + directory block + report block + manifest block + manifest block from manifest deletion
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
 
 	// garbage-collect for real, this time without age limit
 	e.RunAndExpectSuccess(t, "snapshot", "gc", "--delete", "--min-age", "0s")
 
-	// two contents are deleted
-	expectedContentCount -= 2
+	// three contents are deleted
+	expectedContentCount -= 3
 	e.RunAndVerifyOutputLineCount(t, expectedContentCount, "content", "list")
+
+	// deleted snapshots can't be brought back referencing garbage-collected contents.
+	for _, manifestID := range deletedManifestIDs {
+		e.RunAndExpectFailure(t, "manifest", "restore", manifestID)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--all-sources")
+}
+
+func TestMaintenancePlan(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	dataDir := makeScratchDir(t)
+	testenv.AssertNoError(t, os.MkdirAll(dataDir, 0777))
+	testenv.AssertNoError(t, ioutil.WriteFile(filepath.Join(dataDir, "some-file1"), []byte("first version"), 0600))
+	e.RunAndExpectSuccess(t, "snap", "create", dataDir)
+	testenv.AssertNoError(t, ioutil.WriteFile(filepath.Join(dataDir, "some-file1"), []byte("second version"), 0600))
+	e.RunAndExpectSuccess(t, "snap", "create", dataDir)
+
+	// only the latest snapshot is retained from now on.
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--keep-latest=1", "--keep-hourly=0", "--keep-daily=0", "--keep-weekly=0", "--keep-monthly=0", "--keep-annual=0")
+
+	planFile := filepath.Join(e.ConfigDir, "plan.json")
+
+	e.RunAndExpectSuccess(t, "maintenance", "plan", "create", planFile, "--min-age=0s")
+
+	// plans are only applied after verification.
+	e.RunAndExpectFailure(t, "maintenance", "plan", "apply", planFile)
+	e.RunAndExpectSuccess(t, "maintenance", "plan", "verify", planFile)
+
+	// snapshots created after verification were not checked.
+	e.RunAndExpectSuccess(t, "snap", "create", sharedTestDataDir1)
+	e.RunAndExpectFailure(t, "maintenance", "plan", "apply", planFile)
+	e.RunAndExpectSuccess(t, "maintenance", "plan", "verify", planFile)
+
+	// modified plan is rejected.
+	b, err := ioutil.ReadFile(planFile)
+	testenv.AssertNoError(t, err)
+	testenv.AssertNoError(t, ioutil.WriteFile(planFile, []byte(strings.Replace(string(b), `"minContentAge": 0`, `"minContentAge": 1`, 1)), 0600))
+	e.RunAndExpectFailure(t, "maintenance", "plan", "apply", planFile)
+	testenv.AssertNoError(t, ioutil.WriteFile(planFile, b, 0600))
+
+	contentCount := len(e.RunAndExpectSuccess(t, "content", "list"))
+
+	e.RunAndExpectSuccess(t, "maintenance", "plan", "apply", planFile)
+
+	si := e.ListSnapshotsAndExpectSuccess(t, dataDir)
+	if got := len(si[0].Snapshots); got != 1 {
+		t.Fatalf("unexpected number of retained snapshots: %v", got)
+	}
+
+	if got := len(e.RunAndExpectSuccess(t, "content", "list")); got >= contentCount {
+		t.Errorf("contents were not deleted: %v, previously %v", got, contentCount)
+	}
+
+	// retained snapshot can still be restored.
+	e.RunAndExpectSuccess(t, "restore", si[0].Snapshots[0].ObjectID, makeScratchDir(t))
 }
@@ -0,0 +1,36 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotStdin(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	contents := strings.Repeat("database dump\n", 10000)
+	e.RunWithStdinAndExpectSuccess(t, strings.NewReader(contents), "snapshot", "create", "--stdin-name", "db.sql")
+
+	si := e.ListSnapshotsAndExpectSuccess(t)
+	if got, want := len(si), 1; got != want {
+		t.Fatalf("unexpected number of sources: %v, want %v", got, want)
+	}
+
+	if got, want := filepath.Base(si[0].Path), "db.sql"; got != want {
+		t.Errorf("unexpected source path: %v, want %v", si[0].Path, want)
+	}
+
+	target := filepath.Join(makeScratchDir(t), "restored.sql")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[0].SnapshotID, target)
+	assertFileContents(t, target, contents)
+
+	e.RunAndExpectFailure(t, "snapshot", "create", "--stdin-name", "db.sql", makeScratchDir(t))
+}
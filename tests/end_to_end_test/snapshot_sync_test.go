@@ -0,0 +1,66 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotSync(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	testenv.AssertNoError(t, os.MkdirAll(filepath.Join(source, "sub"), 0700))
+	mustWriteFile(t, filepath.Join(source, "a.txt"), "aaaa")
+	mustWriteFile(t, filepath.Join(source, "sub", "b.txt"), "bbbb")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+	target := filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "sync", snapID, target)
+	compareDirs(t, source, target)
+
+	mustWriteFile(t, filepath.Join(target, "a.txt"), "changed")
+	mustWriteFile(t, filepath.Join(target, "extra.txt"), "extra")
+
+	// file with the same size and modification time is considered unchanged.
+	st, err := os.Stat(filepath.Join(source, "sub", "b.txt"))
+	testenv.AssertNoError(t, err)
+	mustWriteFile(t, filepath.Join(target, "sub", "b.txt"), "BBBB")
+	testenv.AssertNoError(t, os.Chtimes(filepath.Join(target, "sub", "b.txt"), st.ModTime(), st.ModTime()))
+
+	e.RunAndExpectSuccess(t, "snapshot", "sync", snapID, target)
+
+	assertFileContents(t, filepath.Join(target, "a.txt"), "aaaa")
+	assertFileContents(t, filepath.Join(target, "sub", "b.txt"), "BBBB")
+	assertFileContents(t, filepath.Join(target, "extra.txt"), "extra")
+
+	e.RunAndExpectSuccess(t, "snapshot", "sync", snapID, target, "--delete")
+
+	if _, err := os.Stat(filepath.Join(target, "extra.txt")); !os.IsNotExist(err) {
+		t.Errorf("extraneous file was not deleted: %v", err)
+	}
+}
+
+func assertFileContents(t *testing.T, fname, want string) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("unable to read %v: %v", fname, err)
+	}
+
+	if got := string(b); got != want {
+		t.Errorf("unexpected contents of %v: %q, want %q", fname, got, want)
+	}
+}
@@ -0,0 +1,73 @@
+// +build linux darwin
+
+package endtoend_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSparseFiles(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fileSize   = 64 << 20
+		dataOffset = 16 << 20
+	)
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	sparseFile := filepath.Join(source, "sparse.img")
+
+	f, err := os.Create(sparseFile)
+	testenv.AssertNoError(t, err)
+
+	_, err = f.WriteAt([]byte("some data in the middle"), dataOffset)
+	testenv.AssertNoError(t, err)
+	testenv.AssertNoError(t, f.Truncate(fileSize))
+	testenv.AssertNoError(t, f.Close())
+
+	if allocatedBytes(t, sparseFile) >= fileSize {
+		t.Skip("sparse files not supported by the file system")
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+	target := filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+
+	want, err := ioutil.ReadFile(sparseFile)
+	testenv.AssertNoError(t, err)
+
+	got, err := ioutil.ReadFile(filepath.Join(target, "sparse.img"))
+	testenv.AssertNoError(t, err)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored sparse file has different contents")
+	}
+
+	if n := allocatedBytes(t, filepath.Join(target, "sparse.img")); n >= fileSize {
+		t.Errorf("restored file is not sparse, %v bytes allocated", n)
+	}
+}
+
+func allocatedBytes(t *testing.T, path string) int64 {
+	t.Helper()
+
+	st, err := os.Stat(path)
+	testenv.AssertNoError(t, err)
+
+	return st.Sys().(*syscall.Stat_t).Blocks * 512 //nolint:gomnd
+}
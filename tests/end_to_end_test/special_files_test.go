@@ -0,0 +1,68 @@
+// +build linux darwin
+
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSpecialFiles(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	testenv.AssertNoError(t, unix.Mkfifo(filepath.Join(source, "fifo"), 0640))
+	testenv.AssertNoError(t, unix.Mknod(filepath.Join(source, "socket"), 0600|unix.S_IFSOCK, 0))
+
+	// creating device nodes requires privileges.
+	withDevice := os.Geteuid() == 0
+	if withDevice {
+		testenv.AssertNoError(t, unix.Mknod(filepath.Join(source, "device"), 0600|unix.S_IFCHR, int(unix.Mkdev(1, 3))))
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+
+	// restoring again over existing special files succeeds.
+	target := filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+
+	assertFileMode(t, filepath.Join(target, "fifo"), os.ModeNamedPipe|0640)
+	assertFileMode(t, filepath.Join(target, "socket"), os.ModeSocket|0600)
+
+	if withDevice {
+		assertFileMode(t, filepath.Join(target, "device"), os.ModeDevice|os.ModeCharDevice|0600)
+
+		st, err := os.Lstat(filepath.Join(target, "device"))
+		testenv.AssertNoError(t, err)
+
+		if got, want := uint64(st.Sys().(*syscall.Stat_t).Rdev), unix.Mkdev(1, 3); got != want { //nolint:unconvert
+			t.Errorf("unexpected device number: %v, want %v", got, want)
+		}
+	}
+}
+
+func assertFileMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+
+	st, err := os.Lstat(path)
+	testenv.AssertNoError(t, err)
+
+	if got := st.Mode(); got != want {
+		t.Errorf("unexpected mode of %v: %v, want %v", path, got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestStorageStats(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", sharedTestDataDir1, "--storage-stats")
+	if !hasStorageStatsLine(stderr, "PUT") {
+		t.Errorf("storage operations not printed: %v", stderr)
+	}
+
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "snapshot", "list")
+	if hasStorageStatsLine(stderr, "") {
+		t.Errorf("unexpected storage operations printed: %v", stderr)
+	}
+}
+
+func hasStorageStatsLine(lines []string, substr string) bool {
+	for _, l := range lines {
+		if strings.HasPrefix(l, "Storage operations:") && strings.Contains(l, substr) {
+			return true
+		}
+	}
+
+	return false
+}
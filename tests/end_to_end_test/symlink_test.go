@@ -0,0 +1,76 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSymlinks(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires elevated privileges on Windows")
+	}
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "file.txt"), "contents")
+	testenv.AssertNoError(t, os.Symlink("file.txt", filepath.Join(source, "link")))
+	testenv.AssertNoError(t, os.Symlink("nonexistent", filepath.Join(source, "dangling")))
+
+	// symlinks are stored as links by default.
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+	target := filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertSymlink(t, filepath.Join(target, "link"), "file.txt")
+	assertSymlink(t, filepath.Join(target, "dangling"), "nonexistent")
+	assertFileContents(t, filepath.Join(target, "link"), "contents")
+
+	// restoring again over existing symlinks succeeds.
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target, "--overwrite-files")
+
+	// when following symlinks, contents of their targets are stored instead.
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--follow-symlinks", "true")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID = e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[1].SnapshotID
+	target = filepath.Join(makeScratchDir(t), "target")
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+
+	st, err := os.Lstat(filepath.Join(target, "link"))
+	testenv.AssertNoError(t, err)
+
+	if !st.Mode().IsRegular() {
+		t.Errorf("followed symlink was not restored as a file: %v", st.Mode())
+	}
+
+	assertFileContents(t, filepath.Join(target, "link"), "contents")
+
+	// dangling symlinks can't be followed and are stored as links.
+	assertSymlink(t, filepath.Join(target, "dangling"), "nonexistent")
+}
+
+func assertSymlink(t *testing.T, fname, want string) {
+	t.Helper()
+
+	got, err := os.Readlink(fname)
+	if err != nil {
+		t.Fatalf("unable to read symlink %v: %v", fname, err)
+	}
+
+	if got != want {
+		t.Errorf("unexpected target of %v: %q, want %q", fname, got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func writeTranslations(t *testing.T, e *testenv.CLITest) {
+	t.Helper()
+
+	dir := filepath.Join(e.ConfigDir, "translations")
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "pl.json"), []byte(`{"Setting policy for %v\n": "Ustawianie zasad dla %v\n"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Environment = append(e.Environment, "KOPIA_TRANSLATIONS_DIR="+dir)
+}
+
+func TestTranslations(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	writeTranslations(t, e)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "policy", "set", "--global", "--keep-latest=5", "--language=pl_PL")
+	if !containsLine(stderr, "Ustawianie zasad dla (global)") {
+		t.Errorf("translated message not found in %v", stderr)
+	}
+
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "policy", "set", "--global", "--keep-latest=5", "--language=de")
+	if !containsLine(stderr, "Setting policy for (global)") {
+		t.Errorf("untranslated message not found in %v", stderr)
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) == want {
+			return true
+		}
+	}
+
+	return false
+}
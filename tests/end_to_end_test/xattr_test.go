@@ -0,0 +1,77 @@
+// +build linux
+
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestExtendedAttributes(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+	defer e.Cleanup(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := makeScratchDir(t)
+	mustWriteFile(t, filepath.Join(source, "file.txt"), "contents")
+
+	if err := unix.Setxattr(filepath.Join(source, "file.txt"), "user.kopia-test", []byte("file-value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	testenv.AssertNoError(t, unix.Setxattr(source, "user.kopia-test", []byte("dir-value"), 0))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID := e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[0].SnapshotID
+
+	target := filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertExtendedAttribute(t, filepath.Join(target, "file.txt"), "user.kopia-test", "file-value")
+	assertExtendedAttribute(t, target, "user.kopia-test", "dir-value")
+
+	target = filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target, "--skip-extended-attributes")
+	assertNoExtendedAttribute(t, filepath.Join(target, "file.txt"), "user.kopia-test")
+
+	// extended attributes are not captured when disabled by the policy.
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--extended-attributes=false")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	snapID = e.ListSnapshotsAndExpectSuccess(t, source)[0].Snapshots[1].SnapshotID
+
+	target = filepath.Join(makeScratchDir(t), "target")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, target)
+	assertNoExtendedAttribute(t, filepath.Join(target, "file.txt"), "user.kopia-test")
+	assertNoExtendedAttribute(t, target, "user.kopia-test")
+}
+
+func assertExtendedAttribute(t *testing.T, path, name, want string) {
+	t.Helper()
+
+	buf := make([]byte, 1024)
+
+	n, err := unix.Getxattr(path, name, buf)
+	if err != nil {
+		t.Fatalf("unable to get extended attribute %v of %v: %v", name, path, err)
+	}
+
+	if got := string(buf[:n]); got != want {
+		t.Errorf("unexpected value of extended attribute %v of %v: %q, want %q", name, path, got, want)
+	}
+}
+
+func assertNoExtendedAttribute(t *testing.T, path, name string) {
+	t.Helper()
+
+	if _, err := unix.Getxattr(path, name, nil); err != unix.ENODATA {
+		t.Errorf("unexpected extended attribute %v of %v: %v", name, path, err)
+	}
+}
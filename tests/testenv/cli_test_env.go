@@ -126,6 +126,19 @@ func (e *CLITest) RunAndExpectSuccess(t *testing.T, args ...string) []string {
 	return stdout
 }
 
+// RunWithStdinAndExpectSuccess runs the given command with the provided standard input, expects it to succeed
+// and returns its output lines.
+func (e *CLITest) RunWithStdinAndExpectSuccess(t *testing.T, stdin io.Reader, args ...string) []string {
+	t.Helper()
+
+	stdout, _, err := e.run(t, stdin, args...)
+	if err != nil {
+		t.Fatalf("'kopia %v' failed with %v", strings.Join(args, " "), err)
+	}
+
+	return stdout
+}
+
 // RunAndProcessStderr runs the given command, and streams its output line-by-line to a given function until it returns false.
 func (e *CLITest) RunAndProcessStderr(t *testing.T, callback func(line string) bool, args ...string) *exec.Cmd {
 	t.Helper()
@@ -201,6 +214,12 @@ func (e *CLITest) RunAndVerifyOutputLineCount(t *testing.T, wantLines int, args
 // Run executes kopia with given arguments and returns the output lines.
 func (e *CLITest) Run(t *testing.T, args ...string) (stdout, stderr []string, err error) {
 	t.Helper()
+
+	return e.run(t, nil, args...)
+}
+
+func (e *CLITest) run(t *testing.T, stdin io.Reader, args ...string) (stdout, stderr []string, err error) {
+	t.Helper()
 	t.Logf("running '%v %v'", e.Exe, strings.Join(args, " "))
 	// nolint:gosec
 	cmdArgs := append(append([]string(nil), e.fixedArgs...), args...)
@@ -208,6 +227,7 @@ func (e *CLITest) Run(t *testing.T, args ...string) (stdout, stderr []string, er
 	// nolint:gosec
 	c := exec.Command(e.Exe, cmdArgs...)
 	c.Env = append(os.Environ(), e.Environment...)
+	c.Stdin = stdin
 
 	errOut := &bytes.Buffer{}
 	c.Stderr = errOut